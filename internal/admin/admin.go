@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// Route is the prefix under which the admin API is served
+	Route = "/api/admin/"
+)
+
+// Server serves the admin API. All requests must carry the configured token as a bearer token.
+type Server struct {
+	token  string
+	mux    *http.ServeMux
+	logger zerolog.Logger
+}
+
+func New(token string, logger zerolog.Logger) *Server {
+	return &Server{
+		token:  token,
+		mux:    http.NewServeMux(),
+		logger: logger,
+	}
+}
+
+// HandleFunc registers an admin endpoint. The pattern's path is relative to Route, e.g. "GET deadletters".
+func (s *Server) HandleFunc(pattern string, handler http.HandlerFunc) {
+	method, path, found := strings.Cut(pattern, " ")
+	if !found {
+		method, path = "", pattern
+	}
+	if method != "" {
+		method += " "
+	}
+	s.mux.HandleFunc(method+Route+strings.TrimPrefix(path, "/"), handler)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Authorize(s.mux).ServeHTTP(w, r)
+}
+
+// Authorize protects a handler served outside of Route with the admin token, e.g. the metrics
+func (s *Server) Authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON responds with the given value encoded as JSON
+func (s *Server) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to write admin API response")
+	}
+}
+
+// writeError responds with the given error message encoded as JSON
+func (s *Server) writeError(w http.ResponseWriter, status int, err error) {
+	s.writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/archive"
+	"github.com/cilium/ariane/internal/budget"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/deadletter"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/graphql"
+	"github.com/cilium/ariane/internal/handlers"
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+type noopHandler struct{}
+
+func (noopHandler) Handles() []string {
+	return []string{"issue_comment"}
+}
+
+func (noopHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	return nil
+}
+
+func doRequest(s *Server, method, path, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func Test_Authorization(t *testing.T) {
+	s := New("secret", zerolog.Nop())
+	s.HandleFunc("GET ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	assert.Equal(t, http.StatusUnauthorized, doRequest(s, "GET", Route+"ping", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(s, "GET", Route+"ping", "wrong").Code)
+	assert.Equal(t, http.StatusOK, doRequest(s, "GET", Route+"ping", "secret").Code)
+
+	metrics := s.Authorize(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for token, expected := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "secret": http.StatusOK} {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		metrics.ServeHTTP(w, r)
+		assert.Equal(t, expected, w.Code, "the metrics are authorized with token %q", token)
+	}
+}
+
+func Test_DeadLetters(t *testing.T) {
+	store, _ := deadletter.NewStore("")
+	assert.NoError(t, store.Add(deadletter.Entry{ID: "delivery-1", EventType: "issue_comment", Payload: []byte(`{}`), FailedAt: time.Now()}))
+	s := New("secret", zerolog.Nop())
+	s.RegisterDeadLetters(deadletter.NewScheduler(store, 1, 0, noopHandler{}))
+
+	w := doRequest(s, "GET", Route+"deadletters", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []deadletter.Entry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+	assert.Nil(t, entries[0].Payload, "payloads are not listed")
+
+	assert.Equal(t, http.StatusOK, doRequest(s, "GET", Route+"deadletters/delivery-1", "secret").Code)
+	assert.Equal(t, http.StatusNoContent, doRequest(s, "POST", Route+"deadletters/delivery-1/requeue", "secret").Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, "DELETE", Route+"deadletters/delivery-1", "secret").Code)
+
+	assert.NoError(t, store.Add(deadletter.Entry{ID: "delivery-2", EventType: "issue_comment", Payload: []byte(`{}`), FailedAt: time.Now(), Dispatched: true}))
+	assert.Equal(t, http.StatusConflict, doRequest(s, "POST", Route+"deadletters/delivery-2/requeue", "secret").Code, "dead letters which dispatched workflows are only requeued if forced")
+	assert.Equal(t, http.StatusNoContent, doRequest(s, "POST", Route+"deadletters/delivery-2/requeue?force=true", "secret").Code)
+}
+
+func Test_Archive(t *testing.T) {
+	store, err := archive.NewStore(t.TempDir(), time.Hour)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Add("delivery-1", "issue_comment", []byte(`{"token":"abc"}`), []string{"failed"}))
+	s := New("secret", zerolog.Nop())
+	s.RegisterArchive(store)
+
+	w := doRequest(s, "GET", Route+"archive", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var entries []archive.Entry
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &entries))
+	assert.Len(t, entries, 1)
+	assert.Nil(t, entries[0].Payload, "payloads are not listed")
+
+	w = doRequest(s, "GET", Route+"archive/delivery-1", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"token":"[scrubbed]"`)
+	assert.Equal(t, http.StatusNotFound, doRequest(s, "GET", Route+"archive/delivery-2", "secret").Code)
+}
+
+func Test_Explain(t *testing.T) {
+	cache := config.NewCache(time.Minute)
+	cache.Set("owner", "repo", "main", &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"foo.yaml": {PathsRegex: "src/"},
+		},
+	})
+	s := New("secret", zerolog.Nop())
+	s.RegisterExplain(cache)
+
+	w := doRequest(s, "GET", Route+"explain?repo=owner/repo&ref=main&comment=/test&files=src/main.go,README.md", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var explanation config.Explanation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &explanation))
+	assert.True(t, explanation.Trigger.Result)
+	assert.Len(t, explanation.Workflows, 1)
+	assert.Equal(t, decision.ReasonPathsMatched, explanation.Workflows[0].Decision.Reason)
+	assert.Equal(t, []config.FileExplanation{
+		{File: "src/main.go", Filters: []string{"paths-regex"}},
+		{File: "README.md", Filters: []string{}},
+	}, explanation.Workflows[0].Files)
+
+	w = doRequest(s, "GET", Route+"explain?repo=owner/repo&ref=main&comment=/test&files=src/main.go&format=case", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var c decision.Case
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &c))
+	assert.Equal(t, []string{"src/main.go"}, c.Files)
+	assert.Equal(t, decision.ActionDispatch, c.Plan.Workflows[0].Action)
+	assert.Empty(t, c.Replay())
+
+	assert.Equal(t, http.StatusNotFound, doRequest(s, "GET", Route+"explain?repo=owner/repo&ref=other&comment=/test", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(s, "GET", Route+"explain?repo=owner&ref=main", "secret").Code)
+}
+
+func Test_Config(t *testing.T) {
+	cache := config.NewCache(time.Minute)
+	cache.Set("owner", "repo", "main", &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"foo.yaml": {PathsRegex: "src/"},
+		},
+	})
+	s := New("secret", zerolog.Nop())
+	s.RegisterConfig(cache)
+
+	w := doRequest(s, "GET", Route+"config?repo=owner/repo&ref=main", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var cached CachedConfig
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cached))
+	assert.Equal(t, "owner/repo", cached.Repo)
+	assert.Equal(t, "main", cached.Ref)
+	assert.True(t, cached.ExpiresAt.After(time.Now()))
+	assert.Len(t, cached.Digest, 64)
+	assert.Equal(t, "src/", cached.Config["workflows"].(map[string]any)["foo.yaml"].(map[string]any)["paths-regex"], "keys are the ones of the config file")
+
+	assert.Equal(t, http.StatusNotFound, doRequest(s, "GET", Route+"config?repo=owner/repo&ref=other", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(s, "GET", Route+"config?repo=owner/repo", "secret").Code)
+}
+
+func Test_GraphQL(t *testing.T) {
+	history := handlers.NewHistoryStore(0)
+	at := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	history.Record(handlers.WorkflowDecision{Repo: "owner/repo", PRNumber: 1, SHA: "sha-1", Workflow: "foo.yaml", Action: decision.ActionDispatch, Decision: decision.Yes(decision.ReasonPathsMatched, "matched"), At: at})
+	history.Record(handlers.WorkflowDecision{Repo: "owner/repo", PRNumber: 1, SHA: "sha-1", Workflow: "bar.yaml", Action: decision.ActionSkip, Decision: decision.No(decision.ReasonPathsNotMatched, "not matched"), At: at})
+	history.Record(handlers.WorkflowDecision{Repo: "owner/other", PRNumber: 2, SHA: "sha-2", Workflow: "foo.yaml", Action: decision.ActionDispatch, Decision: decision.Yes(decision.ReasonPathsMatched, "matched"), At: at})
+	cache := config.NewCache(time.Minute)
+	cache.Set("owner", "repo", "main", &config.ArianeConfig{Triggers: map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}}})
+	cache.Set("owner", "other", "main", &config.ArianeConfig{})
+	s := New("secret", zerolog.Nop())
+	s.RegisterGraphQL(history, cache)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", Route+"graphql", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		return w
+	}
+
+	w := post(`{"query": "query($pr: Int) { decisions(repo: \"owner/repo\", pr: $pr) { workflow action decision { reason } } dispatches(first: 1) { repo } configs(repo: \"owner/repo\") { ref digest } }", "variables": {"pr": 1}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data struct {
+			Decisions []struct {
+				Workflow string
+				Action   string
+				Decision struct{ Reason string }
+			}
+			Dispatches []struct{ Repo string }
+			Configs    []struct{ Ref, Digest string }
+		}
+		Errors []graphql.Error
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Empty(t, response.Errors)
+	if assert.Len(t, response.Data.Decisions, 2) {
+		assert.Equal(t, "bar.yaml", response.Data.Decisions[0].Workflow, "the newest decisions come first")
+		assert.Equal(t, decision.ActionSkip, response.Data.Decisions[0].Action)
+		assert.Equal(t, string(decision.ReasonPathsNotMatched), response.Data.Decisions[0].Decision.Reason)
+	}
+	if assert.Len(t, response.Data.Dispatches, 1) {
+		assert.Equal(t, "owner/other", response.Data.Dispatches[0].Repo)
+	}
+	if assert.Len(t, response.Data.Configs, 1) {
+		assert.Equal(t, "main", response.Data.Configs[0].Ref)
+		assert.Len(t, response.Data.Configs[0].Digest, 64)
+	}
+
+	w = doRequest(s, "GET", Route+"graphql?query="+url.QueryEscape(`{ decisions(action: "skip", bogus: 1) { sha } }`), "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `unknown argument \"bogus\"`)
+
+	assert.Equal(t, http.StatusBadRequest, doRequest(s, "GET", Route+"graphql?query="+url.QueryEscape(`mutation { decisions { sha } }`), "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, post(`not json`).Code)
+}
+
+func Test_LastGreen(t *testing.T) {
+	s := New("secret", zerolog.Nop())
+	s.RegisterLastGreen(handlers.NewGreenStore(0))
+
+	w := doRequest(s, "GET", Route+"last-green?repo=owner/repo&pr=1", "secret")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "no green SHA for owner/repo#1")
+	assert.Equal(t, http.StatusBadRequest, doRequest(s, "GET", Route+"last-green?repo=owner/repo&pr=abc", "secret").Code)
+	assert.Equal(t, http.StatusBadRequest, doRequest(s, "GET", Route+"last-green?repo=owner&pr=1", "secret").Code)
+}
+
+func Test_Dashboard(t *testing.T) {
+	s := New("secret", zerolog.Nop())
+	registry := metrics.NewRegistry()
+	s.RegisterDashboard(registry)
+
+	w := doRequest(s, "GET", Route+"dashboard", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var dashboard metrics.Dashboard
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dashboard))
+	assert.Equal(t, metrics.DashboardUID, dashboard.UID)
+}
+
+func Test_Budget(t *testing.T) {
+	s := New("secret", zerolog.Nop())
+	store := budget.NewStore(0, 1, nil)
+	s.RegisterBudget(store)
+	assert.NoError(t, store.Request(1, "owner/repo"))
+
+	w := doRequest(s, "GET", Route+"budget", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var reports []budget.Report
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &reports))
+	assert.Len(t, reports, 1)
+	assert.Equal(t, []budget.RepositoryRequests{{Repository: "owner/repo", Requests: 1}}, reports[0].Repositories)
+}
+
+func Test_Pause(t *testing.T) {
+	s := New("secret", zerolog.Nop())
+	tracker := load.NewTracker(load.Thresholds{}, zerolog.Nop())
+	s.RegisterPause(tracker)
+
+	assert.Equal(t, http.StatusOK, doRequest(s, "POST", Route+"pause", "secret").Code)
+	assert.Equal(t, load.RefusedPaused, tracker.Refuse())
+	w := doRequest(s, "GET", Route+"pause", "secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var intake Intake
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &intake))
+	assert.True(t, intake.Paused)
+
+	assert.Equal(t, http.StatusOK, doRequest(s, "DELETE", Route+"pause", "secret").Code)
+	assert.Empty(t, tracker.Refuse())
+}
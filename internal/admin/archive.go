@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/cilium/ariane/internal/archive"
+)
+
+// RegisterArchive adds the endpoints to retrieve the payloads archived for failed events:
+//
+//	GET /api/admin/archive      lists archived events, without their payload
+//	GET /api/admin/archive/{id} returns an archived event, including its scrubbed payload
+func (s *Server) RegisterArchive(store *archive.Store) {
+	s.HandleFunc("GET archive", func(w http.ResponseWriter, r *http.Request) {
+		entries := store.List()
+		for i := range entries {
+			entries[i].Payload = nil
+		}
+		s.writeJSON(w, http.StatusOK, entries)
+	})
+
+	s.HandleFunc("GET archive/{id}", func(w http.ResponseWriter, r *http.Request) {
+		entry, err := store.Get(r.PathValue("id"))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, archive.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			s.writeError(w, status, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, entry)
+	})
+}
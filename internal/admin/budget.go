@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"net/http"
+
+	"github.com/cilium/ariane/internal/budget"
+)
+
+// RegisterBudget adds the endpoint reporting the GitHub API consumption of each installation in the current window,
+// broken down by repository, along with the installation tokens created:
+//
+//	GET /api/admin/budget
+func (s *Server) RegisterBudget(store *budget.Store) {
+	s.HandleFunc("GET budget", func(w http.ResponseWriter, r *http.Request) {
+		s.writeJSON(w, http.StatusOK, store.Reports())
+	})
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+// CachedConfig is a config as cached, which decisions are made against
+type CachedConfig struct {
+	Repo      string    `json:"repo"`
+	Ref       string    `json:"ref"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Digest is the SHA-256 of the config as YAML, telling apart the versions of a config
+	Digest string `json:"digest"`
+	// Config uses the keys of .github/ariane-config.yaml, with the projects resolved
+	Config map[string]any `json:"config"`
+}
+
+// RegisterConfig adds the endpoint returning the cached config of a repository:
+//
+//	GET /api/admin/config?repo={owner}/{repo}&ref={ref}
+func (s *Server) RegisterConfig(cache *config.Cache) {
+	s.HandleFunc("GET config", func(w http.ResponseWriter, r *http.Request) {
+		owner, repo, ref, err := repositoryRef(r.URL.Query())
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		arianeConfig, expiresAt, ok := cache.GetWithExpiration(owner, repo, ref)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, fmt.Errorf("no cached config for %s/%s@%s", owner, repo, ref))
+			return
+		}
+
+		cached, err := newCachedConfig(owner, repo, ref, arianeConfig, expiresAt)
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, cached)
+	})
+}
+
+func newCachedConfig(owner, repo, ref string, arianeConfig *config.ArianeConfig, expiresAt time.Time) (CachedConfig, error) {
+	// go through YAML to show the config with the keys of the config file
+	encoded, err := yaml.Marshal(arianeConfig)
+	if err != nil {
+		return CachedConfig{}, err
+	}
+	cached := CachedConfig{Repo: owner + "/" + repo, Ref: ref, ExpiresAt: expiresAt}
+	if err := yaml.Unmarshal(encoded, &cached.Config); err != nil {
+		return CachedConfig{}, err
+	}
+	digest := sha256.Sum256(encoded)
+	cached.Digest = hex.EncodeToString(digest[:])
+	return cached, nil
+}
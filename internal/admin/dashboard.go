@@ -0,0 +1,20 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"net/http"
+
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+// RegisterDashboard adds the endpoint returning a Grafana dashboard graphing the metrics of the registry, to import
+// in Grafana as is:
+//
+//	GET /api/admin/dashboard
+func (s *Server) RegisterDashboard(registry *metrics.Registry) {
+	s.HandleFunc("GET dashboard", func(w http.ResponseWriter, r *http.Request) {
+		s.writeJSON(w, http.StatusOK, registry.Dashboard())
+	})
+}
@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cilium/ariane/internal/deadletter"
+)
+
+// RegisterDeadLetters adds the endpoints to inspect, requeue and drop dead letters:
+//
+//	GET    /api/admin/deadletters              lists dead letters, without their payload
+//	GET    /api/admin/deadletters/{id}         returns a dead letter, including its payload
+//	POST   /api/admin/deadletters/{id}/requeue handles a dead letter again, removing it on success, refusing with
+//	                                           409 the ones which dispatched workflows unless ?force=true is given
+//	DELETE /api/admin/deadletters/{id}         drops a dead letter
+func (s *Server) RegisterDeadLetters(scheduler *deadletter.Scheduler) {
+	s.HandleFunc("GET deadletters", func(w http.ResponseWriter, r *http.Request) {
+		entries := scheduler.Store.List()
+		for i := range entries {
+			entries[i].Payload = nil
+		}
+		s.writeJSON(w, http.StatusOK, entries)
+	})
+
+	s.HandleFunc("GET deadletters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		entry, err := scheduler.Store.Get(r.PathValue("id"))
+		if err != nil {
+			s.writeError(w, deadLetterStatus(err), err)
+			return
+		}
+		s.writeJSON(w, http.StatusOK, entry)
+	})
+
+	s.HandleFunc("POST deadletters/{id}/requeue", func(w http.ResponseWriter, r *http.Request) {
+		// the event is handled through even if the client goes away
+		ctx := context.WithoutCancel(s.logger.WithContext(r.Context()))
+		force := r.URL.Query().Get("force") == "true"
+		if err := scheduler.Requeue(ctx, r.PathValue("id"), force); err != nil {
+			s.writeError(w, deadLetterStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	s.HandleFunc("DELETE deadletters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := scheduler.Store.Remove(r.PathValue("id")); err != nil {
+			s.writeError(w, deadLetterStatus(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func deadLetterStatus(err error) int {
+	if errors.Is(err, deadletter.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, deadletter.ErrDispatched) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/log"
+)
+
+// RegisterExplain adds the endpoint explaining how the cached config of a repository handles a comment:
+//
+//	GET /api/admin/explain?repo={owner}/{repo}&ref={ref}&comment={comment}&files={file},{file}
+//
+// It runs the decision logic, without calling GitHub, and returns which trigger regexes match the comment,
+// and which filters of the triggered workflows the files hit.
+// With format=case, it returns the inputs and plan as a case to add to the corpus of the decision logic instead.
+func (s *Server) RegisterExplain(cache *config.Cache) {
+	s.HandleFunc("GET explain", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		owner, repo, ref, err := repositoryRef(query)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		arianeConfig, ok := cache.Get(owner, repo, ref)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, fmt.Errorf("no cached config for %s/%s@%s", owner, repo, ref))
+			return
+		}
+
+		var files []string
+		if v := query.Get("files"); v != "" {
+			files = strings.Split(v, ",")
+		}
+		if query.Get("format") == "case" {
+			s.writeJSON(w, http.StatusOK, decision.NewCase(arianeConfig.DecisionConfig(), query.Get("comment"), files, decision.RunState{}))
+			return
+		}
+		ctx := log.WithLogger(r.Context(), &s.logger)
+		s.writeJSON(w, http.StatusOK, arianeConfig.Explain(ctx, query.Get("comment"), files))
+	})
+}
+
+// repositoryRef returns the repository, given as repo={owner}/{repo}, and the ref of a query
+func repositoryRef(query url.Values) (string, string, string, error) {
+	owner, repo, ok := strings.Cut(query.Get("repo"), "/")
+	if !ok || owner == "" || repo == "" {
+		return "", "", "", errors.New("repo must be given as owner/repo")
+	}
+	ref := query.Get("ref")
+	if ref == "" {
+		return "", "", "", errors.New("ref must be given")
+	}
+	return owner, repo, ref, nil
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/graphql"
+	"github.com/cilium/ariane/internal/handlers"
+)
+
+// RegisterGraphQL adds the read-only GraphQL endpoint over the decision history and the cached configs, for tooling
+// to query them without an endpoint per report:
+//
+//	POST /api/admin/graphql {"query": "...", "operationName": "...", "variables": {...}}
+//	GET /api/admin/graphql?query={query}&operationName={name}&variables={json}
+//
+// Its Query type has the fields:
+//
+//	decisions(repo, pr, sha, workflow, action, reason, first): the workflow decisions, the newest first
+//	dispatches(repo, pr, sha, workflow, reason, first): the workflow decisions dispatching a workflow
+//	configs(repo): the cached configs, as returned by GET /api/admin/config
+func (s *Server) RegisterGraphQL(history *handlers.HistoryStore, cache *config.Cache) {
+	schema := graphql.Schema{
+		"decisions": func(args map[string]any) (any, error) {
+			filter, first, err := historyFilter(args, "action")
+			if err != nil {
+				return nil, err
+			}
+			return history.Decisions(filter, first), nil
+		},
+		"dispatches": func(args map[string]any) (any, error) {
+			filter, first, err := historyFilter(args)
+			if err != nil {
+				return nil, err
+			}
+			filter.Action = decision.ActionDispatch
+			return history.Decisions(filter, first), nil
+		},
+		"configs": func(args map[string]any) (any, error) {
+			if err := checkArguments(args, "repo"); err != nil {
+				return nil, err
+			}
+			repository, err := stringArgument(args, "repo")
+			if err != nil {
+				return nil, err
+			}
+			configs := []CachedConfig{}
+			for _, entry := range cache.Entries(repository) {
+				cached, err := newCachedConfig(entry.Owner, entry.Repo, entry.Ref, entry.Config, entry.ExpiresAt)
+				if err != nil {
+					return nil, err
+				}
+				configs = append(configs, cached)
+			}
+			return configs, nil
+		},
+	}
+
+	serve := func(w http.ResponseWriter, req graphql.Request) {
+		response := schema.Execute(req)
+		status := http.StatusOK
+		if response.Data == nil {
+			status = http.StatusBadRequest
+		}
+		s.writeJSON(w, status, response)
+	}
+	s.HandleFunc("POST graphql", func(w http.ResponseWriter, r *http.Request) {
+		var req graphql.Request
+		decoder := json.NewDecoder(r.Body)
+		decoder.UseNumber()
+		if err := decoder.Decode(&req); err != nil {
+			s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid GraphQL request: %w", err))
+			return
+		}
+		serve(w, req)
+	})
+	s.HandleFunc("GET graphql", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		req := graphql.Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+		if v := query.Get("variables"); v != "" {
+			decoder := json.NewDecoder(strings.NewReader(v))
+			decoder.UseNumber()
+			if err := decoder.Decode(&req.Variables); err != nil {
+				s.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid GraphQL variables: %w", err))
+				return
+			}
+		}
+		serve(w, req)
+	})
+}
+
+// historyFilter returns the filter and the limit given by the arguments of a history field, which may take the
+// extra arguments
+func historyFilter(args map[string]any, extra ...string) (handlers.HistoryFilter, int, error) {
+	var filter handlers.HistoryFilter
+	if err := checkArguments(args, append([]string{"repo", "pr", "sha", "workflow", "reason", "first"}, extra...)...); err != nil {
+		return filter, 0, err
+	}
+	var reason string
+	var err error
+	for name, value := range map[string]*string{"repo": &filter.Repo, "sha": &filter.SHA, "workflow": &filter.Workflow, "action": &filter.Action, "reason": &reason} {
+		if *value, err = stringArgument(args, name); err != nil {
+			return filter, 0, err
+		}
+	}
+	filter.Reason = decision.Reason(reason)
+	if filter.PRNumber, err = intArgument(args, "pr"); err != nil {
+		return filter, 0, err
+	}
+	first, err := intArgument(args, "first")
+	if err != nil {
+		return filter, 0, err
+	}
+	if first < 0 {
+		return filter, 0, errors.New("argument \"first\" must not be negative")
+	}
+	return filter, first, nil
+}
+
+// checkArguments rejects the arguments a field does not take
+func checkArguments(args map[string]any, names ...string) error {
+	for name := range args {
+		if !slices.Contains(names, name) {
+			return fmt.Errorf("unknown argument %q", name)
+		}
+	}
+	return nil
+}
+
+// stringArgument returns a string argument, empty if not given
+func stringArgument(args map[string]any, name string) (string, error) {
+	value, ok := args[name]
+	if !ok {
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", name)
+	}
+	return s, nil
+}
+
+// intArgument returns an integer argument, zero if not given
+func intArgument(args map[string]any, name string) (int, error) {
+	value, ok := args[name]
+	if !ok {
+		return 0, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("argument %q must be an integer", name)
+	}
+	n, err := number.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("argument %q must be an integer", name)
+	}
+	return int(n), nil
+}
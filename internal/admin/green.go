@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ariane/internal/handlers"
+)
+
+// LastGreen is the last SHA of a pull request for which all the workflows dispatched by Ariane succeeded
+type LastGreen struct {
+	Repo     string `json:"repo"`
+	PRNumber int    `json:"pr"`
+	handlers.GreenSHA
+}
+
+// RegisterLastGreen adds the endpoint returning the last green SHA of a pull request, for bisection and revert tooling:
+//
+//	GET /api/admin/last-green?repo={owner}/{repo}&pr={number}
+func (s *Server) RegisterLastGreen(store *handlers.GreenStore) {
+	s.HandleFunc("GET last-green", func(w http.ResponseWriter, r *http.Request) {
+		owner, repo, ok := strings.Cut(r.URL.Query().Get("repo"), "/")
+		if !ok || owner == "" || repo == "" {
+			s.writeError(w, http.StatusBadRequest, errors.New("repo must be given as owner/repo"))
+			return
+		}
+		prNumber, err := strconv.Atoi(r.URL.Query().Get("pr"))
+		if err != nil || prNumber <= 0 {
+			s.writeError(w, http.StatusBadRequest, errors.New("pr must be given as a pull request number"))
+			return
+		}
+		green, ok := store.Last(owner, repo, prNumber)
+		if !ok {
+			s.writeError(w, http.StatusNotFound, fmt.Errorf("no green SHA for %s/%s#%d", owner, repo, prNumber))
+			return
+		}
+		s.writeJSON(w, http.StatusOK, LastGreen{Repo: owner + "/" + repo, PRNumber: prNumber, GreenSHA: green})
+	})
+}
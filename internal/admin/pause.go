@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package admin
+
+import (
+	"net/http"
+
+	"github.com/cilium/ariane/internal/load"
+)
+
+// Intake reports whether new events are refused, see load.Tracker.Refuse
+type Intake struct {
+	Paused bool `json:"paused"`
+}
+
+// RegisterPause adds the endpoints to pause the intake of new events, answering webhooks with a retriable status
+// until it is resumed, e.g. during maintenance:
+//
+//	GET    /api/admin/pause reports whether the intake is paused
+//	POST   /api/admin/pause pauses it
+//	DELETE /api/admin/pause resumes it
+func (s *Server) RegisterPause(tracker *load.Tracker) {
+	s.HandleFunc("GET pause", func(w http.ResponseWriter, r *http.Request) {
+		s.writeJSON(w, http.StatusOK, Intake{Paused: tracker.Paused()})
+	})
+	s.HandleFunc("POST pause", func(w http.ResponseWriter, r *http.Request) {
+		tracker.SetPaused(true)
+		s.logger.Warn().Msg("Paused the intake of new events")
+		s.writeJSON(w, http.StatusOK, Intake{Paused: true})
+	})
+	s.HandleFunc("DELETE pause", func(w http.ResponseWriter, r *http.Request) {
+		tracker.SetPaused(false)
+		s.logger.Info().Msg("Resumed the intake of new events")
+		s.writeJSON(w, http.StatusOK, Intake{Paused: false})
+	})
+}
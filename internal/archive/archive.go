@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package archive keeps the webhook payloads of events whose handling failed, scrubbed of secrets,
+// so bug reports can be replayed exactly.
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultRetention = 14 * 24 * time.Hour
+
+var ErrNotFound = errors.New("archived event not found")
+
+// validID restricts entry IDs (GitHub delivery IDs) to characters safe to use as file names
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// scrubbed replaces the values of payload fields which may hold secrets
+const scrubbed = "[scrubbed]"
+
+// secretFields are substrings of the payload field names whose values are scrubbed
+var secretFields = []string{"token", "secret", "password", "private_key", "authorization", "credential"}
+
+// Entry is an event whose handling failed at least once, with its payload scrubbed of secrets
+type Entry struct {
+	ID         string          `json:"id"`
+	EventType  string          `json:"eventType"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Errors     []string        `json:"errors"`
+	ArchivedAt time.Time       `json:"archivedAt"`
+}
+
+// Store keeps archived events for the retention period, persisted as one JSON file per entry in a directory
+type Store struct {
+	mu        sync.Mutex
+	dir       string
+	retention time.Duration
+	entries   map[string]Entry
+}
+
+// NewStore creates a store persisted in dir, loading the entries already present which are not expired.
+func NewStore(dir string, retention time.Duration) (*Store, error) {
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+	s := &Store{dir: dir, retention: retention, entries: map[string]Entry{}}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed creating archive directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading archived event %s: %w", file, err)
+		}
+		var e Entry
+		if err := json.Unmarshal(bytes, &e); err != nil {
+			return nil, fmt.Errorf("failed parsing archived event %s: %w", file, err)
+		}
+		s.entries[e.ID] = e
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	return s, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Add archives an event, scrubbing its payload, and replacing any entry with the same ID.
+func (s *Store) Add(id, eventType string, payload []byte, errs []string) error {
+	if !validID.MatchString(id) {
+		return fmt.Errorf("invalid archived event ID %q", id)
+	}
+	scrubbedPayload, err := Scrub(payload)
+	if err != nil {
+		return fmt.Errorf("failed scrubbing payload: %w", err)
+	}
+	e := Entry{ID: id, EventType: eventType, Payload: scrubbedPayload, Errors: errs, ArchivedAt: time.Now()}
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(e.ArchivedAt)
+	if err := os.WriteFile(s.path(id), bytes, 0o640); err != nil {
+		return fmt.Errorf("failed writing archived event: %w", err)
+	}
+	s.entries[id] = e
+	return nil
+}
+
+// Get returns the archived event with the given ID.
+func (s *Store) Get(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return e, nil
+}
+
+// List returns all archived events, oldest first.
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(time.Now())
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ArchivedAt.Before(entries[j].ArchivedAt) })
+	return entries
+}
+
+// prune drops the entries archived for longer than the retention period, s.mu must be held
+func (s *Store) prune(now time.Time) {
+	for id, e := range s.entries {
+		if now.Sub(e.ArchivedAt) <= s.retention {
+			continue
+		}
+		if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+			continue
+		}
+		delete(s.entries, id)
+	}
+}
+
+// Scrub replaces the values of the payload fields whose names suggest they hold secrets, at any depth
+func Scrub(payload []byte) (json.RawMessage, error) {
+	var v any
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(scrub(v))
+}
+
+func scrub(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if isSecretField(key) {
+				v[key] = scrubbed
+				continue
+			}
+			v[key] = scrub(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = scrub(value)
+		}
+	}
+	return v
+}
+
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, field := range secretFields {
+		if strings.Contains(name, field) {
+			return true
+		}
+	}
+	return false
+}
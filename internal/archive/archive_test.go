@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package archive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Scrub(t *testing.T) {
+	payload := []byte(`{"comment":{"body":"/test"},"installation":{"access_tokens_url":"url","id":1},"hook":{"config":{"secret":"s3cr3t"}},"list":[{"Authorization":"Bearer x"}]}`)
+	scrubbedPayload, err := Scrub(payload)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"comment":{"body":"/test"},"installation":{"access_tokens_url":"[scrubbed]","id":1},"hook":{"config":{"secret":"[scrubbed]"}},"list":[{"Authorization":"[scrubbed]"}]}`, string(scrubbedPayload))
+
+	_, err = Scrub([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func Test_Store(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir, time.Hour)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Add("delivery-1", "issue_comment", []byte(`{"token":"abc"}`), []string{"failed"}))
+	assert.Error(t, store.Add("../delivery", "issue_comment", []byte(`{}`), nil), "IDs must be safe to use as file names")
+
+	// entries are loaded back from disk
+	store, err = NewStore(dir, time.Hour)
+	assert.NoError(t, err)
+	entry, err := store.Get("delivery-1")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"token":"[scrubbed]"}`, string(entry.Payload))
+	assert.Equal(t, []string{"failed"}, entry.Errors)
+	assert.Len(t, store.List(), 1)
+
+	// expired entries are dropped
+	store.retention = 0
+	time.Sleep(time.Millisecond)
+	assert.Empty(t, store.List())
+	_, err = store.Get("delivery-1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	store, err = NewStore(dir, time.Hour)
+	assert.NoError(t, err)
+	assert.Empty(t, store.List())
+}
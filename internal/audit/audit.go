@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package audit
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/log"
+)
+
+const (
+	// LogKeyAudit flags log lines which are audit records
+	LogKeyAudit = "audit"
+	// LogKeyAction is the action an audit record is about
+	LogKeyAction = "audit_action"
+)
+
+// Event starts an audit record for the given action, using the logger from ctx.
+// The record is completed with fields by the caller, and emitted with Send or Msg.
+func Event(ctx context.Context, action string) *zerolog.Event {
+	logger := log.FromContext(ctx)
+	if logger == nil {
+		logger = zerolog.Ctx(ctx)
+	}
+	return logger.Info().Bool(LogKeyAudit, true).Str(LogKeyAction, action)
+}
@@ -0,0 +1,219 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package budget accounts for the GitHub API consumption of each app installation, and of each repository within
+// it, enforcing per-installation limits so one hyperactive installation cannot starve the others.
+package budget
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+var (
+	tokensCreatedTotal = metrics.NewCounterVec("ariane_github_tokens_created_total",
+		"Installation tokens created, by installation.", "installation")
+	budgetRejectedTotal = metrics.NewCounterVec("ariane_github_budget_rejected_total",
+		"GitHub API requests refused as their installation used its budget, by installation.", "installation")
+)
+
+const (
+	// DefaultWindow matches the hourly rate limit windows of GitHub
+	DefaultWindow = time.Hour
+)
+
+// ErrExceeded is returned for the requests of installations past their budget, as a transient failure so the
+// events are handled again once the window rolls over
+var ErrExceeded = errors.New("GitHub API budget of the installation exceeded")
+
+// Store counts the requests and token creations of each installation over a window, requests being broken down by
+// repository. A nil Store is valid and counts nothing.
+type Store struct {
+	window       time.Duration
+	defaultLimit int
+	limits       map[int64]int
+	now          func() time.Time
+
+	mu            sync.Mutex
+	installations map[int64]*usage
+}
+
+// usage is the consumption of an installation in the current window
+type usage struct {
+	windowStart  time.Time
+	requests     int
+	rejected     int
+	tokens       int
+	repositories map[string]int
+}
+
+// NewStore creates a store limiting each installation to defaultLimit requests per window, or to its limit in
+// limits if listed. Installations are not limited if their limit is zero.
+func NewStore(window time.Duration, defaultLimit int, limits map[int64]int) *Store {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Store{
+		window:        window,
+		defaultLimit:  defaultLimit,
+		limits:        limits,
+		now:           time.Now,
+		installations: map[int64]*usage{},
+	}
+}
+
+// limit returns the requests an installation is allowed per window, zero if unlimited
+func (s *Store) limit(installationID int64) int {
+	if limit, ok := s.limits[installationID]; ok {
+		return limit
+	}
+	return s.defaultLimit
+}
+
+// current returns the usage of an installation in the current window, starting a new window if the last one is
+// over. s.mu must be held.
+func (s *Store) current(installationID int64) *usage {
+	now := s.now()
+	u, ok := s.installations[installationID]
+	if !ok || now.Sub(u.windowStart) >= s.window {
+		u = &usage{windowStart: now.Truncate(s.window), repositories: map[string]int{}}
+		s.installations[installationID] = u
+	}
+	return u
+}
+
+// Request accounts for a request of an installation on a repository, empty for requests outside repositories. It
+// returns ErrExceeded, as a transient failure, if the installation used its budget for the window.
+func (s *Store) Request(installationID int64, repository string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.current(installationID)
+	if limit := s.limit(installationID); limit > 0 && u.requests >= limit {
+		u.rejected++
+		budgetRejectedTotal.Inc(strconv.FormatInt(installationID, 10))
+		return failure.Wrap(failure.GitHubTransient, fmt.Errorf("%w: %d requests since %s", ErrExceeded, u.requests, u.windowStart.UTC().Format(time.RFC3339)))
+	}
+	u.requests++
+	if repository != "" {
+		u.repositories[repository]++
+	}
+	return nil
+}
+
+// Token accounts for the creation of an installation token
+func (s *Store) Token(installationID int64) {
+	if s == nil {
+		return
+	}
+	tokensCreatedTotal.Inc(strconv.FormatInt(installationID, 10))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current(installationID).tokens++
+}
+
+// Report is the consumption of an installation in the current window
+type Report struct {
+	Installation int64     `json:"installation"`
+	WindowStart  time.Time `json:"window_start"`
+	Window       string    `json:"window"`
+	// Limit is the requests allowed per window, zero if unlimited
+	Limit    int `json:"limit"`
+	Requests int `json:"requests"`
+	// Rejected is the requests refused as the budget was used up
+	Rejected     int                  `json:"rejected"`
+	Tokens       int                  `json:"tokens"`
+	Repositories []RepositoryRequests `json:"repositories"`
+}
+
+type RepositoryRequests struct {
+	Repository string `json:"repository"`
+	Requests   int    `json:"requests"`
+}
+
+// Reports returns the consumption of each installation in its current window, by installation ID, with the
+// repositories of each by decreasing requests
+func (s *Store) Reports() []Report {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reports := make([]Report, 0, len(s.installations))
+	for installationID := range s.installations {
+		u := s.current(installationID)
+		report := Report{
+			Installation: installationID,
+			WindowStart:  u.windowStart,
+			Window:       s.window.String(),
+			Limit:        s.limit(installationID),
+			Requests:     u.requests,
+			Rejected:     u.rejected,
+			Tokens:       u.tokens,
+			Repositories: make([]RepositoryRequests, 0, len(u.repositories)),
+		}
+		for repository, requests := range u.repositories {
+			report.Repositories = append(report.Repositories, RepositoryRequests{Repository: repository, Requests: requests})
+		}
+		sort.Slice(report.Repositories, func(i, j int) bool {
+			a, b := report.Repositories[i], report.Repositories[j]
+			return a.Requests > b.Requests || (a.Requests == b.Requests && a.Repository < b.Repository)
+		})
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Installation < reports[j].Installation })
+	return reports
+}
+
+// Repository returns the "owner/repo" a GitHub API request is about, empty if it is not about a repository
+func Repository(r *http.Request) string {
+	_, path, ok := strings.Cut(r.URL.Path, "/repos/")
+	if !ok {
+		return ""
+	}
+	segments := strings.SplitN(path, "/", 3)
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+		return ""
+	}
+	return segments[0] + "/" + segments[1]
+}
+
+// tokenPath matches the requests creating installation tokens
+var tokenPath = regexp.MustCompile(`/app/installations/(\d+)/access_tokens$`)
+
+// Transport accounts for the installation tokens created through next, the base transport of the client creator,
+// which the installation token requests go through
+func (s *Store) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		res, err := next.RoundTrip(r)
+		if r.Method == http.MethodPost && err == nil && res.StatusCode == http.StatusCreated {
+			if match := tokenPath.FindStringSubmatch(r.URL.Path); match != nil {
+				if installationID, err := strconv.ParseInt(match[1], 10, 64); err == nil {
+					s.Token(installationID)
+				}
+			}
+		}
+		return res, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package budget
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/failure"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)
+	s := NewStore(time.Hour, 2, map[int64]int{2: 0})
+	s.now = func() time.Time { return now }
+
+	assert.NoError(t, s.Request(1, "owner/a"))
+	assert.NoError(t, s.Request(1, "owner/b"))
+	err := s.Request(1, "owner/b")
+	assert.ErrorIs(t, err, ErrExceeded)
+	assert.Equal(t, failure.GitHubTransient, failure.CategoryOf(err))
+
+	// installation 2 is unlimited
+	for range 3 {
+		assert.NoError(t, s.Request(2, "other/repo"))
+	}
+	s.Token(2)
+
+	reports := s.Reports()
+	assert.Len(t, reports, 2)
+	assert.Equal(t, Report{
+		Installation: 1,
+		WindowStart:  time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC),
+		Window:       "1h0m0s",
+		Limit:        2,
+		Requests:     2,
+		Rejected:     1,
+		Repositories: []RepositoryRequests{{Repository: "owner/a", Requests: 1}, {Repository: "owner/b", Requests: 1}},
+	}, reports[0])
+	assert.Equal(t, 3, reports[1].Requests)
+	assert.Equal(t, 1, reports[1].Tokens)
+
+	// the budget is restored when the window rolls over
+	now = now.Add(time.Hour)
+	assert.NoError(t, s.Request(1, "owner/a"))
+	assert.Equal(t, 1, s.Reports()[0].Requests)
+
+	var nilStore *Store
+	assert.NoError(t, nilStore.Request(1, "owner/a"))
+	assert.Nil(t, nilStore.Reports())
+}
+
+func TestRepository(t *testing.T) {
+	for path, want := range map[string]string{
+		"/repos/owner/repo/pulls/1":          "owner/repo",
+		"/api/v3/repos/owner/repo":           "owner/repo",
+		"/repos/owner":                       "",
+		"/app/installations/1/access_tokens": "",
+	} {
+		assert.Equal(t, want, Repository(httptest.NewRequest(http.MethodGet, path, nil)), path)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	s := NewStore(0, 0, nil)
+	transport := s.Transport(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusCreated}, nil
+	}))
+	_, err := transport.RoundTrip(httptest.NewRequest(http.MethodPost, "/app/installations/42/access_tokens", nil))
+	assert.NoError(t, err)
+	_, err = transport.RoundTrip(httptest.NewRequest(http.MethodGet, "/app/installations/42", nil))
+	assert.NoError(t, err)
+
+	reports := s.Reports()
+	assert.Len(t, reports, 1)
+	assert.Equal(t, int64(42), reports[0].Installation)
+	assert.Equal(t, 1, reports[0].Tokens)
+}
@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package chaos injects artificial GitHub API failures and latencies into the client transport, so the resilience
+// features, such as retries, dead letters and back-pressure, can be exercised in integration tests and staging.
+// It is only enabled through the ARIANE_CHAOS_* environment variables, never through the server config, so it
+// cannot be turned on by a production config file.
+package chaos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+// requestIDHeader identifies the injected failures like the GitHub API identifies its requests
+const requestIDHeader = "X-GitHub-Request-Id"
+
+var faultsTotal = metrics.NewCounterVec("ariane_chaos_faults_total",
+	"Faults injected into GitHub API requests in chaos mode, by kind: error or latency.",
+	"kind")
+
+// Config configures the faults injected into GitHub API requests
+type Config struct {
+	// ErrorRate is the fraction of requests failed with Status, between 0 and 1
+	ErrorRate float64
+	// Status is the status of the failed requests, 502 Bad Gateway if zero
+	Status int
+	// Latency delays every request, and Jitter delays them up to that much more, at random
+	Latency time.Duration
+	Jitter  time.Duration
+	// Paths restricts the faults to the requests whose path matches it, e.g. "/actions/", all requests if nil
+	Paths *regexp.Regexp
+	// Seed makes the faults reproducible, random if zero
+	Seed uint64
+}
+
+// Enabled reports whether the config injects any fault
+func (c Config) Enabled() bool {
+	return c.ErrorRate > 0 || c.Latency > 0 || c.Jitter > 0
+}
+
+// FromEnv reads the config from the ARIANE_CHAOS_ERROR_RATE, ARIANE_CHAOS_STATUS, ARIANE_CHAOS_LATENCY,
+// ARIANE_CHAOS_JITTER, ARIANE_CHAOS_PATHS and ARIANE_CHAOS_SEED environment variables. Invalid values are errors,
+// rather than silently disabling faults a test relies on.
+func FromEnv() (Config, error) {
+	var c Config
+	var err error
+	if v, ok := os.LookupEnv("ARIANE_CHAOS_ERROR_RATE"); ok {
+		if c.ErrorRate, err = strconv.ParseFloat(v, 64); err != nil || c.ErrorRate < 0 || c.ErrorRate > 1 {
+			return Config{}, fmt.Errorf("ARIANE_CHAOS_ERROR_RATE: must be between 0 and 1")
+		}
+	}
+	if v, ok := os.LookupEnv("ARIANE_CHAOS_STATUS"); ok {
+		if c.Status, err = strconv.Atoi(v); err != nil || c.Status < 100 || c.Status > 599 {
+			return Config{}, fmt.Errorf("ARIANE_CHAOS_STATUS: must be an HTTP status")
+		}
+	}
+	if v, ok := os.LookupEnv("ARIANE_CHAOS_LATENCY"); ok {
+		if c.Latency, err = time.ParseDuration(v); err != nil {
+			return Config{}, fmt.Errorf("ARIANE_CHAOS_LATENCY: %w", err)
+		}
+	}
+	if v, ok := os.LookupEnv("ARIANE_CHAOS_JITTER"); ok {
+		if c.Jitter, err = time.ParseDuration(v); err != nil {
+			return Config{}, fmt.Errorf("ARIANE_CHAOS_JITTER: %w", err)
+		}
+	}
+	if v, ok := os.LookupEnv("ARIANE_CHAOS_PATHS"); ok && v != "" {
+		if c.Paths, err = regexp.Compile(v); err != nil {
+			return Config{}, fmt.Errorf("ARIANE_CHAOS_PATHS: %w", err)
+		}
+	}
+	if v, ok := os.LookupEnv("ARIANE_CHAOS_SEED"); ok {
+		if c.Seed, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return Config{}, fmt.Errorf("ARIANE_CHAOS_SEED: %w", err)
+		}
+	}
+	return c, nil
+}
+
+type transport struct {
+	next   http.RoundTripper
+	config Config
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	injected int
+}
+
+// Transport injects the faults of config into the requests sent through next. Failed requests are not sent, and
+// are answered with a GitHub API error, whose request ID starts with "chaos-".
+func Transport(next http.RoundTripper, config Config) http.RoundTripper {
+	seed := config.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	if config.Status == 0 {
+		config.Status = http.StatusBadGateway
+	}
+	return &transport{next: next, config: config, rand: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// draw returns the delay of a request, whether it fails, and the number of failures injected so far
+func (t *transport) draw() (time.Duration, bool, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delay := t.config.Latency
+	if t.config.Jitter > 0 {
+		delay += time.Duration(t.rand.Int64N(int64(t.config.Jitter)))
+	}
+	fail := t.config.ErrorRate > 0 && t.rand.Float64() < t.config.ErrorRate
+	if fail {
+		t.injected++
+	}
+	return delay, fail, t.injected
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.config.Paths != nil && !t.config.Paths.MatchString(r.URL.Path) {
+		return t.next.RoundTrip(r)
+	}
+	delay, fail, injected := t.draw()
+	if delay > 0 {
+		faultsTotal.Inc("latency")
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return nil, r.Context().Err()
+		}
+	}
+	if !fail {
+		return t.next.RoundTrip(r)
+	}
+	faultsTotal.Inc("error")
+	if r.Body != nil {
+		_ = r.Body.Close()
+	}
+	body := fmt.Sprintf(`{"message": "%s injected by Ariane chaos mode"}`, http.StatusText(t.config.Status))
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(requestIDHeader, "chaos-"+strconv.Itoa(injected))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", t.config.Status, http.StatusText(t.config.Status)),
+		StatusCode:    t.config.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}, nil
+}
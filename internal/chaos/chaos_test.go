@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package chaos
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/failure"
+)
+
+func TestTransport(t *testing.T) {
+	var sent int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	newClient := func(config Config) *github.Client {
+		client := github.NewClient(&http.Client{Transport: Transport(http.DefaultTransport, config)})
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+		return client
+	}
+
+	client := newClient(Config{ErrorRate: 1, Status: http.StatusServiceUnavailable, Paths: regexp.MustCompile("/actions/")})
+	_, _, err := client.Actions.ListWorkflows(context.Background(), "owner", "repo", nil)
+	assert.Error(t, err)
+	assert.Equal(t, failure.GitHubTransient, failure.CategoryOf(err), "injected failures look like GitHub API errors")
+	assert.Equal(t, "chaos-1", failure.RequestID(err))
+	assert.Equal(t, 0, sent, "failed requests are not sent")
+	_, _, err = client.Repositories.Get(context.Background(), "owner", "repo")
+	assert.NoError(t, err, "requests not matching the paths are left alone")
+	assert.Equal(t, 1, sent)
+
+	// the same seed injects the same failures
+	failures := func() []bool {
+		client := newClient(Config{ErrorRate: 0.5, Seed: 42})
+		var failed []bool
+		for range 10 {
+			_, _, err := client.Repositories.Get(context.Background(), "owner", "repo")
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+	first := failures()
+	assert.Equal(t, first, failures())
+	assert.Contains(t, first, true)
+	assert.Contains(t, first, false)
+
+	client = newClient(Config{Latency: 20 * time.Millisecond})
+	start := time.Now()
+	_, _, err = client.Repositories.Get(context.Background(), "owner", "repo")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, _, err = newClient(Config{Latency: time.Minute}).Repositories.Get(ctx, "owner", "repo")
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "delays end with the request context")
+}
+
+func TestFromEnv(t *testing.T) {
+	config, err := FromEnv()
+	assert.NoError(t, err)
+	assert.False(t, config.Enabled(), "chaos mode is disabled by default")
+
+	t.Setenv("ARIANE_CHAOS_ERROR_RATE", "0.1")
+	t.Setenv("ARIANE_CHAOS_LATENCY", "200ms")
+	t.Setenv("ARIANE_CHAOS_PATHS", "/actions/")
+	config, err = FromEnv()
+	assert.NoError(t, err)
+	assert.True(t, config.Enabled())
+	assert.Equal(t, 0.1, config.ErrorRate)
+	assert.Equal(t, 200*time.Millisecond, config.Latency)
+	assert.True(t, config.Paths.MatchString("/repos/owner/repo/actions/workflows"))
+
+	t.Setenv("ARIANE_CHAOS_ERROR_RATE", "2")
+	_, err = FromEnv()
+	assert.Error(t, err)
+}
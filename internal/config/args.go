@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// types of trigger args
+const (
+	ArgTypeString  = "string"
+	ArgTypeNumber  = "number"
+	ArgTypeBoolean = "boolean"
+	ArgTypeList    = "list"
+	ArgTypeObject  = "object"
+)
+
+var validArgTypes = map[string]bool{
+	ArgTypeString: true, ArgTypeNumber: true, ArgTypeBoolean: true, ArgTypeList: true, ArgTypeObject: true,
+}
+
+// ArgConfig describes a structured input of a trigger, given in a fenced YAML block following the trigger phrase
+type ArgConfig struct {
+	// Type is one of string, number, boolean, list or object, any type is accepted if empty
+	Type        string `yaml:"type,omitempty"`
+	Required    bool   `yaml:"required,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// argsBlockRegex matches a comment made of a trigger phrase followed by a fenced YAML block
+var argsBlockRegex = regexp.MustCompile("(?s)^(.*?)\\n```(?:ya?ml)?[ \\t]*\\n(.*?)\\n?```\\s*$")
+
+// SplitArgsBlock splits a comment into its trigger phrase and the content of the fenced YAML block following it,
+// e.g. "/test\n```yaml\nfocus: foo\n```" into "/test" and "focus: foo". The block is empty if the comment has none.
+func SplitArgsBlock(comment string) (string, string) {
+	comment = strings.ReplaceAll(comment, "\r\n", "\n")
+	submatch := argsBlockRegex.FindStringSubmatch(comment)
+	if submatch == nil {
+		return comment, ""
+	}
+	return strings.TrimSpace(submatch[1]), submatch[2]
+}
+
+// contextOverrideRegex matches a context=<ref> argument ending a trigger phrase
+var contextOverrideRegex = regexp.MustCompile(`(?:^|\s+)context=(\S+)\s*$`)
+
+// SplitContextOverride splits a trigger phrase from the context=<ref> argument ending it, if any, e.g.
+// "/test context=main" into "/test" and "main", so that the phrase matches its trigger without it.
+func SplitContextOverride(comment string) (string, string) {
+	loc := contextOverrideRegex.FindStringSubmatchIndex(comment)
+	if loc == nil {
+		return comment, ""
+	}
+	return comment[:loc[0]], comment[loc[2]:loc[3]]
+}
+
+// dryRunRegex matches the --dry-run modifier following a trigger phrase
+var dryRunRegex = regexp.MustCompile(`\s+--dry-run(\s|$)`)
+
+// SplitDryRun strips the --dry-run modifier from a trigger phrase, e.g. "/test --dry-run" into "/test" and true,
+// so that the phrase matches its trigger without it.
+func SplitDryRun(comment string) (string, bool) {
+	if !dryRunRegex.MatchString(comment) {
+		return comment, false
+	}
+	return dryRunRegex.ReplaceAllString(comment, "$1"), true
+}
+
+// forceRegex matches the --force modifier following a trigger phrase
+var forceRegex = regexp.MustCompile(`\s+--force(\s|$)`)
+
+// SplitForce strips the --force modifier from a trigger phrase, e.g. "/test --force" into "/test" and true, so that
+// the phrase matches its trigger without it.
+func SplitForce(comment string) (string, bool) {
+	if !forceRegex.MatchString(comment) {
+		return comment, false
+	}
+	return forceRegex.ReplaceAllString(comment, "$1"), true
+}
+
+// ignorePathsFilterRegex matches the --ignore-paths-filter modifier following a trigger phrase
+var ignorePathsFilterRegex = regexp.MustCompile(`\s+--ignore-paths-filter(\s|$)`)
+
+// SplitIgnorePathsFilter strips the --ignore-paths-filter modifier from a trigger phrase, e.g.
+// "/test --ignore-paths-filter" into "/test" and true, so that the phrase matches its trigger without it.
+func SplitIgnorePathsFilter(comment string) (string, bool) {
+	if !ignorePathsFilterRegex.MatchString(comment) {
+		return comment, false
+	}
+	return ignorePathsFilterRegex.ReplaceAllString(comment, "$1"), true
+}
+
+// ParseArgs parses the fenced YAML block of a trigger comment, validating it against the args of the
+// trigger matching the comment. The decision tells why the args were rejected, if they were.
+func (config *ArianeConfig) ParseArgs(ctx context.Context, comment, block string) (map[string]any, decision.Decision) {
+	regex, _, trigger := decision.MatchTrigger(config.DecisionConfig(), comment)
+	if !trigger.Result {
+		return nil, trigger
+	}
+	schema := config.Triggers[regex].Args
+
+	var args map[string]any
+	if strings.TrimSpace(block) != "" {
+		if len(schema) == 0 {
+			return nil, decision.No(decision.ReasonInvalidArgs, "trigger %q does not take args", regex)
+		}
+		if err := yaml.Unmarshal([]byte(block), &args); err != nil {
+			return nil, decision.No(decision.ReasonInvalidArgs, "args are not a valid YAML mapping: %v", err)
+		}
+	}
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		arg, ok := schema[name]
+		if !ok {
+			return nil, decision.No(decision.ReasonInvalidArgs, "unknown arg %q", name)
+		}
+		if !argHasType(args[name], arg.Type) {
+			return nil, decision.No(decision.ReasonInvalidArgs, "arg %q must be a %s", name, arg.Type)
+		}
+	}
+
+	names = make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := args[name]; schema[name].Required && !ok {
+			return nil, decision.No(decision.ReasonInvalidArgs, "missing required arg %q", name)
+		}
+	}
+
+	if len(args) == 0 {
+		return nil, decision.Yes(decision.ReasonNoArgs, "no args given")
+	}
+	return args, decision.Yes(decision.ReasonArgsValid, "%d args given", len(args))
+}
+
+// argHasType checks a value decoded from YAML against an arg type
+func argHasType(value any, argType string) bool {
+	switch argType {
+	case ArgTypeString:
+		_, ok := value.(string)
+		return ok
+	case ArgTypeNumber:
+		switch value.(type) {
+		case int, int64, uint64, float64:
+			return true
+		}
+		return false
+	case ArgTypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case ArgTypeList:
+		_, ok := value.([]any)
+		return ok
+	case ArgTypeObject:
+		_, ok := value.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// validateArgs checks the arg types of a trigger
+func validateArgs(trigger string, args map[string]ArgConfig) []error {
+	var errs []error
+	names := make([]string, 0, len(args))
+	for name := range args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if argType := args[name].Type; argType != "" && !validArgTypes[argType] {
+			errs = append(errs, fmt.Errorf("trigger %q: arg %q: invalid type %q", trigger, name, argType))
+		}
+	}
+	return errs
+}
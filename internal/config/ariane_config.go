@@ -5,13 +5,23 @@ package config
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"regexp"
+	"regexp/syntax"
+	"slices"
+	"sort"
 	"strings"
+	"text/template"
+	"time"
+	"unicode"
 
 	"github.com/google/go-github/v75/github"
 	"gopkg.in/yaml.v3"
 
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/failure"
 	"github.com/cilium/ariane/internal/log"
 )
 
@@ -19,156 +29,772 @@ const (
 	ArianeConfigPath = ".github/ariane-config.yaml"
 )
 
+// values of undeclared-inputs
+const (
+	UndeclaredInputsDrop   = "drop"
+	UndeclaredInputsReject = "reject"
+)
+
+// values of changed-files
+const (
+	ChangedFilesPullRequest = "pull-request"
+	ChangedFilesMergeBase   = "merge-base"
+)
+
+// values of head-moved
+const (
+	HeadMovedReevaluate = "reevaluate"
+	HeadMovedAbort      = "abort"
+)
+
+// values of large-pr.policy
+const (
+	LargePRRunAll = "run-all"
+	LargePRForce  = "force"
+)
+
+// values of reporter
+const (
+	ReporterChecks   = "checks"
+	ReporterStatuses = "statuses"
+)
+
+// ErrNotFound is wrapped by the errors of GetArianeConfigFromRepository for refs without a config file
+var ErrNotFound = errors.New("not found")
+
 type ArianeConfig struct {
 	Triggers     map[string]TriggerConfig            `yaml:"triggers"`
 	Workflows    map[string]WorkflowPathsRegexConfig `yaml:"workflows"`
 	AllowedTeams []string                            `yaml:"allowed-teams,omitempty"`
+	// Projects maps the sub-projects of a monorepo to their paths, for workflows and triggers to reference them,
+	// see ResolveProjects
+	Projects map[string]ProjectConfig `yaml:"projects,omitempty"`
+	// NestedTeams also allows the members of the child teams of AllowedTeams, looked up with the GraphQL API
+	NestedTeams bool `yaml:"nested-teams,omitempty"`
+	// IgnorePathsFilterTeams are the teams whose members can dispatch the workflows of a trigger whatever their paths
+	// filters, by ending the trigger phrase with --ignore-paths-filter, e.g. when a filter misses a dependency
+	IgnorePathsFilterTeams []string `yaml:"ignore-paths-filter-teams,omitempty"`
+	// ApprovalReaction, if set, holds trigger comments from users outside of AllowedTeams
+	// until an allowed team member reacts to them with this reaction (e.g. "rocket")
+	ApprovalReaction string `yaml:"approval-reaction,omitempty"`
+	// CancelReaction, if set, lets the authors of held trigger comments cancel them by reacting with this reaction
+	// (e.g. "-1"), as they can by deleting their comment
+	CancelReaction string `yaml:"cancel-reaction,omitempty"`
+	// HoldFirstTimeContributors holds trigger comments from users without merged PRs in the repository until
+	// approved with ApprovalReaction, even if they are members of AllowedTeams or AllowedTeams is empty
+	HoldFirstTimeContributors bool `yaml:"hold-first-time-contributors,omitempty"`
+	// QueuedChecks creates a queued check run named after each workflow when dispatching it, following the
+	// dispatched run once it shows up, so branch protection sees the workflow as pending right away
+	QueuedChecks bool `yaml:"queued-checks,omitempty"`
+	// Reporter is how skipped, queued and merge group checks are reported: ReporterChecks creates check runs,
+	// ReporterStatuses commit statuses, for branch protections requiring status contexts. Check runs are created
+	// if empty.
+	Reporter string `yaml:"reporter,omitempty"`
+	// RunLinks replies to trigger comments with links to their dispatched runs, once found by the server
+	RunLinks bool `yaml:"run-links,omitempty"`
+	// FailedJobs appends the failed jobs of the failed runs dispatched by Ariane, with links to their logs, to the
+	// summary comment of their PR
+	FailedJobs bool `yaml:"failed-jobs,omitempty"`
+	// RunMarker passes the delivery ID of the trigger comment event in the ariane-delivery-id input of every dispatch,
+	// so the dispatched runs showing it in their run-name are told apart from manual dispatches. As GitHub rejects
+	// undeclared inputs, the triggered workflows must all declare it.
+	RunMarker bool `yaml:"run-marker,omitempty"`
+	// UndeclaredInputs checks the inputs of dispatches against the ones each workflow declares, as GitHub rejects
+	// undeclared inputs: UndeclaredInputsDrop dispatches without them, UndeclaredInputsReject refuses the trigger
+	// comment. They are sent as is if empty.
+	UndeclaredInputs string `yaml:"undeclared-inputs,omitempty"`
+	// ChangedFiles is how the files changed by PRs are listed for the paths filters: ChangedFilesPullRequest lists
+	// the files of the PR, ChangedFilesMergeBase compares the PR head with its merge base with the base branch.
+	// The files of the PR are listed if empty.
+	ChangedFiles string `yaml:"changed-files,omitempty"`
+	// Mergeability refuses to dispatch the workflows of PRs which conflict with their base branch, or are too far
+	// behind it, as their runs would be wasted
+	Mergeability MergeabilityConfig `yaml:"mergeability,omitempty"`
+	// LargePR switches to a conservative policy for PRs changing too many files or lines, whose paths filters are
+	// slow to evaluate and misleading, e.g. vendoring PRs
+	LargePR LargePRConfig `yaml:"large-pr,omitempty"`
+	// RetryLimit refuses to re-run or dispatch again workflows retried too often for the same SHA
+	RetryLimit RetryLimitConfig `yaml:"retry-limit,omitempty"`
+	// MergeGroup configures the workflows run for the merge queue
+	MergeGroup MergeGroupConfig `yaml:"merge-group,omitempty"`
+	// CarryOverSkipped re-creates the skipped check runs of workflows on the new head SHA when a PR is synchronized,
+	// as long as their paths filters still exclude the PR changes
+	CarryOverSkipped bool `yaml:"carry-over-skipped,omitempty"`
+	// HeadMoved is how trigger comments are handled when a push moves the head of their PR before their workflows
+	// are dispatched, e.g. while held in a burst: HeadMovedReevaluate evaluates them against the new head,
+	// HeadMovedAbort runs none of the workflows, replying with the head-moved message instead. The workflows are
+	// dispatched for the head the comment was read at if empty.
+	HeadMoved string `yaml:"head-moved,omitempty"`
+	// ReadyForReview is a trigger phrase, e.g. "/test", whose workflows are dispatched when a draft pull request is
+	// marked ready for review by an allowed user, as if they commented it
+	ReadyForReview string `yaml:"ready-for-review,omitempty"`
+	// StatusCommand is a comment, e.g. "/status", replied to like /ariane status with the status of the workflows of
+	// the triggers for the head of the pull request. Only /ariane status is if empty.
+	StatusCommand string `yaml:"status-command,omitempty"`
+	// Welcome configures the comment posted on newly opened pull requests
+	Welcome WelcomeConfig `yaml:"welcome,omitempty"`
+	// Digest posts the failed runs dispatched by Ariane since the previous digest, if the server enables digests
+	Digest DigestConfig `yaml:"digest,omitempty"`
+	// Messages overrides the replies posted by Ariane
+	Messages MessagesConfig `yaml:"messages,omitempty"`
+	// Reactions overrides the reactions acknowledging trigger comments
+	Reactions ReactionsConfig `yaml:"reactions,omitempty"`
+	// Provenance passes the provenance of every dispatch in the ariane-provenance input: the ref and blob SHA of
+	// this config, and the version of the Ariane server. As GitHub rejects undeclared inputs, the triggered
+	// workflows must all declare it.
+	Provenance bool `yaml:"provenance,omitempty"`
+	// Analytics passes the analytics labels of every trigger dispatch in the ariane-analytics input: the trigger
+	// command, the triggering user, the PR or issue number and the attempt, so downstream workflows can tag their
+	// telemetry. As GitHub rejects undeclared inputs, the triggered workflows must all declare it.
+	Analytics bool `yaml:"analytics,omitempty"`
+
+	// Source is where the config was read from, unset for configs which were not read from a repository
+	Source ConfigSource `yaml:"-"`
+}
+
+// ConfigSource identifies the version of a config file read from a repository
+type ConfigSource struct {
+	// Ref is the ref the config was read from, and SHA the blob SHA of the config file there
+	Ref string
+	SHA string
+}
+
+// MergeabilityConfig gates the dispatches of PR workflows on the mergeability of the PR, posting the unmergeable
+// message instead. Tag and issue triggers are not gated.
+type MergeabilityConfig struct {
+	// Enabled refuses PRs which conflict with their base branch
+	Enabled bool `yaml:"enabled,omitempty"`
+	// MaxBehind also refuses PRs more than MaxBehind commits behind their base branch, if set
+	MaxBehind int `yaml:"max-behind,omitempty"`
+}
+
+// LargePRConfig runs all the workflows of the trigger comments on large PRs, whatever their paths filters. Tag and
+// issue triggers are not concerned.
+type LargePRConfig struct {
+	// MaxFiles is how many files a PR can change before it is large, not checked if zero
+	MaxFiles int `yaml:"max-files,omitempty"`
+	// MaxChanges is how many lines (additions and deletions) a PR can change before it is large, not checked if zero
+	MaxChanges int `yaml:"max-changes,omitempty"`
+	// Policy is LargePRRunAll to run all the workflows, or LargePRForce to also require trigger comments to end with
+	// --force, posting the large-pr message otherwise. All the workflows are run if empty.
+	Policy string `yaml:"policy,omitempty"`
+}
+
+// Enabled reports whether any threshold is set
+func (c LargePRConfig) Enabled() bool {
+	return c.MaxFiles > 0 || c.MaxChanges > 0
+}
+
+// DefaultRetryWindow is the window retries are counted over if retry-limit.window is not set
+const DefaultRetryWindow = 24 * time.Hour
+
+// RetryLimitConfig limits how many times trigger comments re-run, or dispatch again, a workflow for the same SHA,
+// to stop retrying flaky workflows until they pass. The workflows retried too often are skipped, and the
+// retry-limited message tells when they can be retried.
+type RetryLimitConfig struct {
+	// MaxRetries is how many times a workflow can be retried for a SHA within Window, unlimited if zero
+	MaxRetries int `yaml:"max-retries,omitempty"`
+	// Window is the period the retries are counted over (e.g. "12h"), DefaultRetryWindow if zero
+	Window time.Duration `yaml:"window,omitempty"`
+}
+
+// WindowOrDefault returns the window retries are counted over
+func (c RetryLimitConfig) WindowOrDefault() time.Duration {
+	if c.Window <= 0 {
+		return DefaultRetryWindow
+	}
+	return c.Window
+}
+
+// MergeGroupConfig configures how the required checks of merge groups are reported. The config of the merge group
+// base branch is used, so that PRs in the queue cannot change it.
+type MergeGroupConfig struct {
+	// RequiredWorkflows maps required checks to the workflow files dispatched on the merge group branch to report them,
+	// the check runs following the dispatched runs. Required checks which are not mapped are marked successful without
+	// running anything.
+	RequiredWorkflows map[string]string `yaml:"required-workflows,omitempty"`
+	// Summary creates an informational check run on merge groups, listing the required checks Ariane marked
+	// successful or dispatched workflows for, and why
+	Summary bool `yaml:"summary,omitempty"`
+}
+
+// Default reactions acknowledging trigger comments, see ReactionsConfig
+const (
+	DefaultDispatchedReaction = "rocket"
+	DefaultNothingRunReaction = "+1"
+	DefaultHeldReaction       = "eyes"
+)
+
+// ReactionsConfig sets the reactions acknowledging trigger comments, the defaults being used if empty
+type ReactionsConfig struct {
+	// Dispatched acknowledges a trigger comment which dispatched or re-ran workflows
+	Dispatched string `yaml:"dispatched,omitempty"`
+	// NothingRun acknowledges a trigger comment whose workflows were all skipped
+	NothingRun string `yaml:"nothing-run,omitempty"`
+	// Held acknowledges a trigger comment held until approved
+	Held string `yaml:"held,omitempty"`
+	// FallbackComment posts the reaction-fallback message when a reaction cannot be created, e.g. because reactions
+	// are disabled in the repository, so the acknowledgement still reaches the comment author
+	FallbackComment bool `yaml:"fallback-comment,omitempty"`
+}
+
+// MessagesConfig holds Go templates for the replies posted by Ariane, see handlers.MessageData for the available fields.
+// Rejection and summary replies are only posted if their template is set, the other replies have defaults.
+type MessagesConfig struct {
+	// Rejection is posted when a trigger comment is ignored because its author is not allowed to run workflows
+	Rejection string `yaml:"rejection,omitempty"`
+	// Summary is posted once the workflows of a trigger comment were dispatched or skipped
+	Summary string `yaml:"summary,omitempty"`
+	// Help is posted in reply to the help command
+	Help string `yaml:"help,omitempty"`
+	// UnknownCommand is posted in reply to an unknown command
+	UnknownCommand string `yaml:"unknown-command,omitempty"`
+	// NothingRun is posted instead of Summary when all the workflows of a trigger comment were skipped
+	NothingRun string `yaml:"nothing-run,omitempty"`
+	// SummaryHistory is how many previous summary and nothing-run messages are kept, collapsed, in the summary
+	// comment, which is edited with each new summary rather than posting new comments
+	SummaryHistory int `yaml:"summary-history,omitempty"`
+	// InvalidInputs is posted when the args of a trigger comment are invalid, or exceed the workflow_dispatch inputs limits
+	InvalidInputs string `yaml:"invalid-inputs,omitempty"`
+	// Deprecated is posted in reply to a trigger comment matching a deprecated trigger
+	Deprecated string `yaml:"deprecated,omitempty"`
+	// Unmergeable is posted when the workflows of a trigger comment are not run as the PR conflicts with its base
+	// branch, or is too far behind it, see MergeabilityConfig
+	Unmergeable string `yaml:"unmergeable,omitempty"`
+	// RunLinks is posted once the runs dispatched for a trigger comment were found, if run-links is set
+	RunLinks string `yaml:"run-links,omitempty"`
+	// Pause is posted when Ariane is paused or resumed on a PR with the off and on commands
+	Pause string `yaml:"pause,omitempty"`
+	// Paused is posted when a trigger comment is ignored as Ariane is paused on the PR
+	Paused string `yaml:"paused,omitempty"`
+	// RetryLimited is posted when workflows were skipped as they were retried too often for the PR head, see
+	// RetryLimitConfig. If no workflow was run, the nothing-run message tells it instead.
+	RetryLimited string `yaml:"retry-limited,omitempty"`
+	// DryRun is posted in reply to a trigger comment ending with --dry-run, with what dispatching its workflows would do
+	DryRun string `yaml:"dry-run,omitempty"`
+	// LastGreen is posted in reply to the last-green command, with the last SHA of the PR for which all the
+	// workflows run by Ariane succeeded
+	LastGreen string `yaml:"last-green,omitempty"`
+	// FailedJobs is appended to the latest summary when a run dispatched by Ariane fails, if failed-jobs is set
+	FailedJobs string `yaml:"failed-jobs,omitempty"`
+	// HeadMoved is posted when the workflows of a trigger comment are not run as the head of the PR moved since
+	// the comment, if head-moved is set to abort
+	HeadMoved string `yaml:"head-moved,omitempty"`
+	// LargePR is posted when the workflows of a trigger comment are not run as the PR is large and the comment does
+	// not end with --force, if large-pr.policy is force
+	LargePR string `yaml:"large-pr,omitempty"`
+	// PolicyDenied is posted when the dispatch policy of the server denies the workflows of a trigger comment
+	PolicyDenied string `yaml:"policy-denied,omitempty"`
+	// SummaryReset replaces the summaries of the summary comment when the PR is force-pushed, as they were about
+	// commits which are gone
+	SummaryReset string `yaml:"summary-reset,omitempty"`
+	// ReactionFallback is posted instead of a reaction which could not be created, if reactions.fallback-comment is set
+	ReactionFallback string `yaml:"reaction-fallback,omitempty"`
+	// Status is posted in reply to the status command, editing the previous status comment of the PR if any
+	Status string `yaml:"status,omitempty"`
+}
+
+// TemplateFuncs returns the functions available in the welcome and messages templates:
+// join, plus name, names and description which look up the friendly names and descriptions of workflow files.
+func (config *ArianeConfig) TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"join":        strings.Join,
+		"name":        config.DisplayName,
+		"description": func(workflow string) string { return config.Workflows[workflow].Description },
+		"names": func(workflows []string) []string {
+			names := make([]string, len(workflows))
+			for i, workflow := range workflows {
+				names[i] = config.DisplayName(workflow)
+			}
+			return names
+		},
+	}
+}
+
+// ReportsStatuses reports whether checks are reported with commit statuses rather than check runs
+func (config *ArianeConfig) ReportsStatuses() bool {
+	return config.Reporter == ReporterStatuses
+}
+
+// DisplayName returns the friendly name of a workflow file, or the file name if it has none
+func (config *ArianeConfig) DisplayName(workflow string) string {
+	if name := config.Workflows[workflow].Name; name != "" {
+		return name
+	}
+	return workflow
+}
+
+// DigestConfig configures where the digest of failed runs is posted. The config of the default branch is used.
+type DigestConfig struct {
+	// Issue is the number of the issue the digest is commented on, if set
+	Issue int `yaml:"issue,omitempty"`
+	// Slack posts the digest to the Slack webhook configured on the Ariane server
+	Slack bool `yaml:"slack,omitempty"`
+	// Template is a Go template for the digest comment, see handlers.DigestData for the available fields
+	Template string `yaml:"template,omitempty"`
+}
+
+type WelcomeConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Template is a Go template for the comment body, see handlers.WelcomeData for the available fields
+	Template string `yaml:"template,omitempty"`
 }
 
 type TriggerConfig struct {
 	Workflows []string `yaml:"workflows"`
+	// Example is the comment shown to contributors to run the trigger in the help and welcome comments, e.g.
+	// "/release-test v1.16.0" for "/release-test (v\S+)", see ArianeConfig.TriggerExample
+	Example string `yaml:"example,omitempty"`
+	// Args are the structured inputs accepted in a fenced YAML block following the trigger phrase
+	Args map[string]ArgConfig `yaml:"args,omitempty"`
+	// Tag dispatches the workflows on the tag given as first submatch of the trigger regex (e.g. "/release-test (v\S+)"),
+	// once checked to exist, rather than on the pull request
+	Tag bool `yaml:"tag,omitempty"`
+	// RequiresSecondApproval only dispatches the workflows once a second allowed user repeats the command, or
+	// approves it with ApprovalReaction, for triggers deploying or mutating shared environments
+	RequiresSecondApproval bool `yaml:"requires-second-approval,omitempty"`
+	// Issues also handles the trigger on plain issues, dispatching its workflows on the default branch with the issue
+	// metadata as inputs, if issue commands are enabled in the server config
+	Issues bool `yaml:"issues,omitempty"`
+	// Projects adds the workflows of these projects, see ResolveProjects
+	Projects []string `yaml:"projects,omitempty"`
+	// ContextOverrides are regexes of the refs the workflow definitions of the trigger may be taken from, when given
+	// as "context=<ref>" at the end of the trigger comment, e.g. to debug workflow changes which are not merged yet.
+	// Overrides are refused if empty.
+	ContextOverrides []string `yaml:"context-overrides,omitempty"`
+	// Deprecated marks the trigger as deprecated: the deprecated message is posted in reply to it, pointing at its
+	// replacement, and it is no longer listed in the help and welcome comments
+	Deprecated *DeprecationConfig `yaml:"deprecated,omitempty"`
+	// CheckNamespace prefixes the names of the check runs, or commit statuses, Ariane creates for the workflows of the
+	// trigger, e.g. "Ariane / e2e" names them "Ariane / e2e / <name>", so they do not collide with the checks created
+	// by the workflows themselves
+	CheckNamespace string `yaml:"check-namespace,omitempty"`
+}
+
+// DeprecationConfig describes how a deprecated trigger is replaced
+type DeprecationConfig struct {
+	// Replacement is the command to use instead, shown in the deprecated message
+	Replacement string `yaml:"replacement,omitempty"`
+	// Refuse no longer runs the workflows of the trigger, only posting the deprecated message
+	Refuse bool `yaml:"refuse,omitempty"`
 }
 
 type WorkflowPathsRegexConfig struct {
 	PathsRegex       string `yaml:"paths-regex"`
 	PathsIgnoreRegex string `yaml:"paths-ignore-regex"`
+	// Statuses only evaluates the paths filters against the changed files with one of these statuses (e.g.
+	// "added"), the workflow not running if there are none. Changes to the workflow itself always count.
+	Statuses []string `yaml:"statuses,omitempty"`
+	// Projects runs the workflow for the changes to the files of these projects, instead of PathsRegex
+	Projects []string `yaml:"projects,omitempty"`
+	// Name and Description are shown to contributors instead of the workflow file name
+	Name        string `yaml:"name,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// IdempotencyKey is a Go template, see IdempotencyData for the available fields. The workflow is skipped if
+	// it already succeeded for a previous dispatch with the same key, even on another SHA.
+	IdempotencyKey string `yaml:"idempotency-key,omitempty"`
+	// CostMinutes is the estimated runner minutes of a run, counting the minutes saved when the workflow is skipped
+	CostMinutes float64 `yaml:"cost-minutes,omitempty"`
+	// Pool is the resource pool the workflow runs on (e.g. "self-hosted-arm"). Its dispatches are queued by the
+	// server while the runs of the pool reach the limit it configures for the pool.
+	Pool string `yaml:"pool,omitempty"`
+	// PathInputs sets inputs of the dispatches from the files changed by the PR, see PathInputsConfig
+	PathInputs []PathInputsConfig `yaml:"path-inputs,omitempty"`
 }
 
 func GetArianeConfigFromRepository(client *github.Client, ctx context.Context, owner string, repoName string, ref string) (*ArianeConfig, error) {
-	fileContent, _, _, err := client.Repositories.GetContents(ctx, owner, repoName, ArianeConfigPath, &github.RepositoryContentGetOptions{Ref: ref})
-	if err != nil {
+	fileContent, _, response, err := client.Repositories.GetContents(ctx, owner, repoName, ArianeConfigPath, &github.RepositoryContentGetOptions{Ref: ref})
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, failure.Errorf(failure.ConfigError, "config file %s %w in repository: %w", ArianeConfigPath, ErrNotFound, err)
+	} else if err != nil {
 		return nil, fmt.Errorf("failed downloading config file from repository: %w", err)
 	}
 
 	configString, err := fileContent.GetContent()
 	if err != nil {
-		return nil, fmt.Errorf("failed reading config file: %w", err)
+		return nil, failure.Errorf(failure.ConfigError, "failed reading config file: %w", err)
 	}
 
 	var config ArianeConfig
 	if err = yaml.Unmarshal([]byte(configString), &config); err != nil {
-		return nil, fmt.Errorf("failed parsing configuration file: %w", err)
+		return nil, failure.Errorf(failure.ConfigError, "failed parsing configuration file: %w", err)
 	}
+	if err = config.ResolveProjects(); err != nil {
+		return nil, failure.Errorf(failure.ConfigError, "invalid projects: %w", err)
+	}
+	config.Source = ConfigSource{Ref: ref, SHA: fileContent.GetSHA()}
 
 	return &config, err
 }
 
-// CheckForTrigger checks if any trigger registered in config match given comment.
-func (config *ArianeConfig) CheckForTrigger(ctx context.Context, comment string) ([]string, []string) {
-	for regex, trigger := range config.Triggers {
-		re, err := regexp.Compile(`^` + regex + `$`)
-		if err != nil {
-			log.FromContext(ctx).Err(err).Msgf("cannot compile regexp %q", regex)
-			continue
+// validReactions are the reactions GitHub supports on comments
+var validReactions = map[string]bool{
+	"+1": true, "-1": true, "laugh": true, "confused": true, "heart": true, "hooray": true, "rocket": true, "eyes": true,
+}
+
+// validFileStatuses are the statuses GitHub reports for the files changed by a pull request or comparison
+var validFileStatuses = map[string]bool{
+	"added": true, "removed": true, "modified": true, "renamed": true, "copied": true, "changed": true, "unchanged": true,
+}
+
+// Validate checks the config for mistakes which would otherwise only show up when handling events,
+// returning all of them joined.
+func (config *ArianeConfig) Validate() error {
+	var errs []error
+
+	triggers := make([]string, 0, len(config.Triggers))
+	for trigger := range config.Triggers {
+		triggers = append(triggers, trigger)
+	}
+	sort.Strings(triggers)
+	for _, trigger := range triggers {
+		if re, err := regexp.Compile(`^` + trigger + `$`); err != nil {
+			errs = append(errs, fmt.Errorf("trigger %q: invalid regex: %w", trigger, err))
+		} else if config.Triggers[trigger].Tag && re.NumSubexp() == 0 {
+			errs = append(errs, fmt.Errorf("trigger %q: tag triggers must capture the tag in a submatch", trigger))
+		}
+		if len(config.Triggers[trigger].Workflows) == 0 {
+			errs = append(errs, fmt.Errorf("trigger %q: no workflows", trigger))
 		}
-		submatch := re.FindStringSubmatch(comment)
-		if submatch != nil {
-			return submatch, trigger.Workflows
+		errs = append(errs, validateArgs(trigger, config.Triggers[trigger].Args)...)
+		for _, override := range config.Triggers[trigger].ContextOverrides {
+			if _, err := regexp.Compile(`^` + override + `$`); err != nil {
+				errs = append(errs, fmt.Errorf("trigger %q: invalid context-overrides regex: %w", trigger, err))
+			}
 		}
 	}
-	return nil, nil
-}
 
-// ShouldRunOnlyWorkflows checks given changed files against .github/workflow pattern
-// Return false if only workflow files changed and the current workflow file is not changed
-// Return true otherwise
-func (config *ArianeConfig) ShouldRunOnlyWorkflows(ctx context.Context, workflow string, files []*github.CommitFile) bool {
-	// Skip the workflow if the committed changes are only for
-	// .github/workflows/* and they do not affect the given workflow
-	for _, file := range files {
-		filename := file.GetFilename()
-		if !strings.HasPrefix(filename, ".github/workflows") || filename == `.github/workflows/`+workflow {
-			return true
+	workflows := make([]string, 0, len(config.Workflows))
+	for workflow := range config.Workflows {
+		workflows = append(workflows, workflow)
+	}
+	sort.Strings(workflows)
+	for _, workflow := range workflows {
+		workflowConfig := config.Workflows[workflow]
+		if workflowConfig.PathsRegex != "" && workflowConfig.PathsIgnoreRegex != "" {
+			errs = append(errs, fmt.Errorf("workflow %q: paths-regex and paths-ignore-regex are mutually exclusive", workflow))
+		}
+		if _, err := regexp.Compile(`^` + workflowConfig.PathsRegex); err != nil {
+			errs = append(errs, fmt.Errorf("workflow %q: invalid paths-regex: %w", workflow, err))
+		}
+		if _, err := regexp.Compile(`^` + workflowConfig.PathsIgnoreRegex); err != nil {
+			errs = append(errs, fmt.Errorf("workflow %q: invalid paths-ignore-regex: %w", workflow, err))
+		}
+		for _, rule := range workflowConfig.PathInputs {
+			if _, err := regexp.Compile(`^` + rule.PathsRegex); err != nil {
+				errs = append(errs, fmt.Errorf("workflow %q: invalid path-inputs paths-regex: %w", workflow, err))
+			}
+			if len(rule.Inputs) == 0 {
+				errs = append(errs, fmt.Errorf("workflow %q: path-inputs rule %q sets no inputs", workflow, rule.PathsRegex))
+			}
+		}
+		for _, status := range workflowConfig.Statuses {
+			if !validFileStatuses[status] {
+				errs = append(errs, fmt.Errorf("workflow %q: unsupported status %q", workflow, status))
+			}
+		}
+		if workflowConfig.CostMinutes < 0 {
+			errs = append(errs, fmt.Errorf("workflow %q: cost-minutes must not be negative", workflow))
 		}
+		if _, err := template.New("idempotency-key").Funcs(config.TemplateFuncs()).Parse(workflowConfig.IdempotencyKey); err != nil {
+			errs = append(errs, fmt.Errorf("workflow %q: invalid idempotency-key: %w", workflow, err))
+		}
+	}
 
+	templates := []struct{ name, text string }{
+		{"welcome.template", config.Welcome.Template},
+		{"digest.template", config.Digest.Template},
+		{"messages.rejection", config.Messages.Rejection},
+		{"messages.summary", config.Messages.Summary},
+		{"messages.help", config.Messages.Help},
+		{"messages.unknown-command", config.Messages.UnknownCommand},
+		{"messages.nothing-run", config.Messages.NothingRun},
+		{"messages.invalid-inputs", config.Messages.InvalidInputs},
+		{"messages.unmergeable", config.Messages.Unmergeable},
+		{"messages.deprecated", config.Messages.Deprecated},
+		{"messages.reaction-fallback", config.Messages.ReactionFallback},
+		{"messages.run-links", config.Messages.RunLinks},
+		{"messages.pause", config.Messages.Pause},
+		{"messages.paused", config.Messages.Paused},
+		{"messages.retry-limited", config.Messages.RetryLimited},
+		{"messages.dry-run", config.Messages.DryRun},
+		{"messages.last-green", config.Messages.LastGreen},
+		{"messages.failed-jobs", config.Messages.FailedJobs},
+		{"messages.head-moved", config.Messages.HeadMoved},
+		{"messages.large-pr", config.Messages.LargePR},
+		{"messages.policy-denied", config.Messages.PolicyDenied},
+		{"messages.summary-reset", config.Messages.SummaryReset},
+		{"messages.status", config.Messages.Status},
 	}
-	return false
+	for _, tmpl := range templates {
+		if _, err := template.New(tmpl.name).Funcs(config.TemplateFuncs()).Parse(tmpl.text); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid template: %w", tmpl.name, err))
+		}
+	}
+
+	if config.Reporter != "" && config.Reporter != ReporterChecks && config.Reporter != ReporterStatuses {
+		errs = append(errs, fmt.Errorf("reporter: must be %q or %q", ReporterChecks, ReporterStatuses))
+	}
+	if config.ChangedFiles != "" && config.ChangedFiles != ChangedFilesPullRequest && config.ChangedFiles != ChangedFilesMergeBase {
+		errs = append(errs, fmt.Errorf("changed-files: must be %q or %q", ChangedFilesPullRequest, ChangedFilesMergeBase))
+	}
+	if config.UndeclaredInputs != "" && config.UndeclaredInputs != UndeclaredInputsDrop && config.UndeclaredInputs != UndeclaredInputsReject {
+		errs = append(errs, fmt.Errorf("undeclared-inputs: must be %q or %q", UndeclaredInputsDrop, UndeclaredInputsReject))
+	}
+	if config.HeadMoved != "" && config.HeadMoved != HeadMovedReevaluate && config.HeadMoved != HeadMovedAbort {
+		errs = append(errs, fmt.Errorf("head-moved: must be %q or %q", HeadMovedReevaluate, HeadMovedAbort))
+	}
+	if config.ApprovalReaction != "" && !validReactions[config.ApprovalReaction] {
+		errs = append(errs, fmt.Errorf("approval-reaction: unsupported reaction %q", config.ApprovalReaction))
+	}
+	if config.Messages.SummaryHistory < 0 {
+		errs = append(errs, errors.New("messages.summary-history: must not be negative"))
+	}
+	if config.CancelReaction != "" && !validReactions[config.CancelReaction] {
+		errs = append(errs, fmt.Errorf("cancel-reaction: unsupported reaction %q", config.CancelReaction))
+	}
+	if config.CancelReaction != "" && config.CancelReaction == config.ApprovalReaction {
+		errs = append(errs, errors.New("cancel-reaction: must differ from approval-reaction"))
+	}
+	reactions := []struct{ name, reaction string }{
+		{"reactions.dispatched", config.Reactions.Dispatched},
+		{"reactions.nothing-run", config.Reactions.NothingRun},
+		{"reactions.held", config.Reactions.Held},
+	}
+	for _, r := range reactions {
+		if r.reaction != "" && !validReactions[r.reaction] {
+			errs = append(errs, fmt.Errorf("%s: unsupported reaction %q", r.name, r.reaction))
+		}
+	}
+	if config.ReadyForReview != "" {
+		if _, _, trigger := decision.MatchTrigger(config.DecisionConfig(), config.ReadyForReview); !trigger.Result {
+			errs = append(errs, fmt.Errorf("ready-for-review: %q does not match any trigger", config.ReadyForReview))
+		}
+	}
+	if config.StatusCommand != "" {
+		if _, _, trigger := decision.MatchTrigger(config.DecisionConfig(), config.StatusCommand); trigger.Result {
+			errs = append(errs, fmt.Errorf("status-command: %q matches a trigger, whose workflows it would never run", config.StatusCommand))
+		}
+	}
+	if config.HoldFirstTimeContributors && config.ApprovalReaction == "" {
+		errs = append(errs, errors.New("hold-first-time-contributors: approval-reaction must be set to release held comments"))
+	}
+	checks := make([]string, 0, len(config.MergeGroup.RequiredWorkflows))
+	for check := range config.MergeGroup.RequiredWorkflows {
+		checks = append(checks, check)
+	}
+	sort.Strings(checks)
+	for _, check := range checks {
+		if config.MergeGroup.RequiredWorkflows[check] == "" {
+			errs = append(errs, fmt.Errorf("merge-group.required-workflows: check %q: no workflow", check))
+		}
+	}
+	if config.Digest.Issue < 0 {
+		errs = append(errs, errors.New("digest.issue: must not be negative"))
+	}
+	if config.Mergeability.MaxBehind < 0 {
+		errs = append(errs, errors.New("mergeability.max-behind: must not be negative"))
+	}
+	if config.LargePR.MaxFiles < 0 {
+		errs = append(errs, errors.New("large-pr.max-files: must not be negative"))
+	}
+	if config.LargePR.MaxChanges < 0 {
+		errs = append(errs, errors.New("large-pr.max-changes: must not be negative"))
+	}
+	if config.LargePR.Policy != "" && config.LargePR.Policy != LargePRRunAll && config.LargePR.Policy != LargePRForce {
+		errs = append(errs, fmt.Errorf("large-pr.policy: must be %q or %q", LargePRRunAll, LargePRForce))
+	}
+	if config.RetryLimit.MaxRetries < 0 {
+		errs = append(errs, errors.New("retry-limit.max-retries: must not be negative"))
+	}
+	if config.RetryLimit.Window < 0 {
+		errs = append(errs, errors.New("retry-limit.window: must not be negative"))
+	}
+
+	return errors.Join(errs...)
 }
 
-// ShouldRunWorkflow compares given list of files against a workflow's PathsRegex / PathsIgnoreRegex and workflow's filename.
-// Return true if any file matches .github/workflows/{workflow} OR .if any file matches PathsRegex
-// OR if any file does NOT match PathsIgnoreRegex AND does NOT have .github/workflow prefix
-// Return false otherwise.
-func (config *ArianeConfig) ShouldRunWorkflow(ctx context.Context, workflow string, files []*github.CommitFile) bool {
-	// No new commits, skip re-running workflows
-	if len(files) == 0 {
-		return false
+// DecisionConfig returns the part of the config the decision logic depends on, see decision.Evaluate
+func (config *ArianeConfig) DecisionConfig() decision.Config {
+	decisionConfig := decision.Config{
+		Triggers:  make(map[string][]string, len(config.Triggers)),
+		Workflows: make(map[string]decision.PathsFilters, len(config.Workflows)),
 	}
+	for regex, trigger := range config.Triggers {
+		decisionConfig.Triggers[regex] = trigger.Workflows
+	}
+	for workflow, workflowConfig := range config.Workflows {
+		decisionConfig.Workflows[workflow] = decision.PathsFilters{PathsRegex: workflowConfig.PathsRegex, PathsIgnoreRegex: workflowConfig.PathsIgnoreRegex}
+	}
+	return decisionConfig
+}
 
-	workflowConfig, exists := config.Workflows[workflow]
-	// No workflow definition for the triggered workflow by a command
-	// 	- /command is expected to trigger one or more workflows
-	//	- these workflows are expected to be defined under the "workflows:" section
-	if !exists {
-		return false
+// filenames returns the names of changed files. Renamed files change both their previous and new path, so they
+// are only ignored if both are, and match paths-regex if either does.
+func filenames(files []*github.CommitFile) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.GetFilename())
+		if file.GetStatus() == "renamed" && file.GetPreviousFilename() != "" {
+			names = append(names, file.GetPreviousFilename())
+		}
 	}
+	return names
+}
 
-	// PathsRegex and PathsIgnoreRegex are both defined - this is UNSUPPORTED!!
-	// default to run the workflow no matter what
-	if workflowConfig.PathsRegex != "" && workflowConfig.PathsIgnoreRegex != "" {
-		return true
+// CheckForTrigger checks if any trigger registered in config match given comment.
+// It returns the trigger submatch and the workflows to trigger, along with the decision.
+func (config *ArianeConfig) CheckForTrigger(ctx context.Context, comment string) ([]string, []string, decision.Decision) {
+	regex, submatch, trigger := decision.MatchTrigger(config.DecisionConfig(), comment)
+	if !trigger.Result {
+		return nil, nil, trigger
 	}
+	return submatch, config.Triggers[regex].Workflows, trigger
+}
+
+// IsStatusCommand reports whether the comment is the status command of the config, see StatusCommand
+func (config *ArianeConfig) IsStatusCommand(comment string) bool {
+	return config.StatusCommand != "" && strings.TrimSpace(comment) == config.StatusCommand
+}
 
-	var re, reIgnore *regexp.Regexp
-	var err error
+// MatchedTrigger returns the config of the trigger matching the comment, if any
+func (config *ArianeConfig) MatchedTrigger(comment string) (TriggerConfig, bool) {
+	regex, _, trigger := decision.MatchTrigger(config.DecisionConfig(), comment)
+	return config.Triggers[regex], trigger.Result
+}
 
-	if workflowConfig.PathsRegex != "" {
-		if re, err = regexp.Compile(`^` + workflowConfig.PathsRegex); err != nil {
-			log.FromContext(ctx).Err(err).Msgf("cannot compile regexp %q", workflowConfig.PathsRegex)
-			return false
+// AllowsContextOverride reports whether the workflow definitions of the trigger may be taken from ref,
+// see TriggerConfig.ContextOverrides
+func (trigger TriggerConfig) AllowsContextOverride(ref string) bool {
+	for _, override := range trigger.ContextOverrides {
+		if re, err := regexp.Compile(`^` + override + `$`); err == nil && re.MatchString(ref) {
+			return true
 		}
 	}
-	if workflowConfig.PathsIgnoreRegex != "" {
-		if reIgnore, err = regexp.Compile(`^` + workflowConfig.PathsIgnoreRegex); err != nil {
-			log.FromContext(ctx).Err(err).Msgf("cannot compile regexp %q", workflowConfig.PathsIgnoreRegex)
-			return false
+	return false
+}
+
+// filesWithStatuses returns the files with one of the statuses the workflow filters on, along with the workflow
+// itself. It returns false with the decision not to run the workflow if files changed, but none is left.
+func (config *ArianeConfig) filesWithStatuses(workflow string, files []*github.CommitFile) ([]*github.CommitFile, decision.Decision, bool) {
+	statuses := config.Workflows[workflow].Statuses
+	if len(statuses) == 0 {
+		return files, decision.Decision{}, true
+	}
+	kept := make([]*github.CommitFile, 0, len(files))
+	for _, file := range files {
+		if slices.Contains(statuses, file.GetStatus()) || file.GetFilename() == ".github/workflows/"+workflow {
+			kept = append(kept, file)
 		}
 	}
+	if len(files) > 0 && len(kept) == 0 {
+		return nil, decision.No(decision.ReasonStatusesNotMatched, "none of the %d changed files is %s", len(files), strings.Join(statuses, " or ")), false
+	}
+	return kept, decision.Decision{}, true
+}
 
-	numberIgnoredFiles := 0
-	for _, file := range files {
-		filename := file.GetFilename()
-		// Run the workflow if:
-		//	Any file under .github/workflows has changed (including the WF itself)
-		// 	PathsRegex has a match
-		// Note: .github/workflows contains env-vars, dependent workflows (e.g. workflow_call),
-		// and other files which may be relevant to the current workflow
-		// TODO: Add intelligence to the "workflows" section of Ariane config to determine dependencies
-		// (common ones [env-vars] + specific of the workflow [dependent WF])
-		// if strings.HasPrefix(filename, ".github/workflows") || re.MatchString(filename) {
-		// 	return true
-		// }
-
-		// Alternatively, only run the workflow if:
-		//	The workflow file has been updated
-		//	PathsRegex has a match
-		if filename == `.github/workflows/`+workflow || (re != nil && re.MatchString(filename)) {
-			return true
-		} else if strings.HasPrefix(filename, ".github/workflows") {
-			// A change on a different workflow (e.g. bar.yaml) does not qualify to re-run
-			// the one we are validating (e.g. foo.yaml)
-			numberIgnoredFiles += 1
+// ShouldRun checks whether a workflow should run for the given files, see decision.ShouldRun
+func (config *ArianeConfig) ShouldRun(ctx context.Context, workflow string, files []*github.CommitFile) decision.Decision {
+	files, d, ok := config.filesWithStatuses(workflow, files)
+	if !ok {
+		return d
+	}
+	return logInvalidRegex(ctx, decision.ShouldRun(config.DecisionConfig(), workflow, filenames(files)))
+}
+
+// TriggersForFiles returns the sorted list of triggers with at least one workflow which would run for the given files,
+// leaving out the deprecated ones.
+func (config *ArianeConfig) TriggersForFiles(ctx context.Context, files []*github.CommitFile) []string {
+	var triggers []string
+	for trigger, triggerConfig := range config.Triggers {
+		if triggerConfig.Deprecated != nil {
 			continue
 		}
-
-		// Flag any finding within PathsIgnoreRegex
-		if reIgnore != nil && reIgnore.MatchString(filename) {
-			numberIgnoredFiles += 1
+		for _, workflow := range triggerConfig.Workflows {
+			if config.ShouldRun(ctx, workflow, files).Result {
+				triggers = append(triggers, trigger)
+				break
+			}
 		}
 	}
+	sort.Strings(triggers)
+	return triggers
+}
 
-	// the workflow (e.g. foo.yaml) does not change
-	// PathsRegex exists (no match, or we would have returned immediately),
-	// PathIgnoreRegex does not exist
-	// expectation: the workflow (e.g. foo.yaml) should not run
-	if re != nil && reIgnore == nil {
-		return false
+// TriggerExample returns the comment shown to contributors to run a trigger: its example if set, or else the shortest
+// comment its regex matches, with "<name>" standing for its named groups and "…" for the characters to fill in,
+// e.g. "/test" for "/test(-.*)?"
+func (config *ArianeConfig) TriggerExample(trigger string) string {
+	if example := config.Triggers[trigger].Example; example != "" {
+		return example
+	}
+	re, err := syntax.Parse(trigger, syntax.Perl)
+	if err != nil {
+		return trigger
+	}
+	return strings.TrimSpace(shortestMatch(re.Simplify()))
+}
+
+// shortestMatch returns the shortest string matched by re, with placeholders for its named groups and character
+// classes
+func shortestMatch(re *syntax.Regexp) string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return string(re.Rune)
+	case syntax.OpCharClass:
+		if len(re.Rune) > 0 && (unicode.IsLetter(re.Rune[0]) || unicode.IsDigit(re.Rune[0])) {
+			return string(re.Rune[0])
+		}
+		return "…"
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return "…"
+	case syntax.OpCapture:
+		if re.Name != "" {
+			return "<" + re.Name + ">"
+		}
+		return shortestMatch(re.Sub[0])
+	case syntax.OpPlus:
+		return shortestMatch(re.Sub[0])
+	case syntax.OpRepeat:
+		return strings.Repeat(shortestMatch(re.Sub[0]), re.Min)
+	case syntax.OpAlternate:
+		shortest := shortestMatch(re.Sub[0])
+		for _, sub := range re.Sub[1:] {
+			if match := shortestMatch(sub); len(match) < len(shortest) {
+				shortest = match
+			}
+		}
+		return shortest
+	case syntax.OpConcat:
+		var match strings.Builder
+		for _, sub := range re.Sub {
+			match.WriteString(shortestMatch(sub))
+		}
+		return match.String()
+	default:
+		// optional parts, and empty matches such as anchors
+		return ""
 	}
+}
 
-	// At this point, we know there are files committed. If all the files match
-	// PathsIgnoreRegex (numberIgnoredFiles == len(files)) or other workflows than,
-	// the one we are evaluating, then do not run the WF
-	// Otherwise, do run it
-	return numberIgnoredFiles < len(files)
+// ShouldRunOnlyWorkflows checks whether only other workflows than the given one changed, see decision.ShouldRunOnlyWorkflows
+func (config *ArianeConfig) ShouldRunOnlyWorkflows(ctx context.Context, workflow string, files []*github.CommitFile) decision.Decision {
+	return decision.ShouldRunOnlyWorkflows(workflow, filenames(files))
 }
 
+// ShouldRunWorkflow compares the files against the paths filters of a workflow, see decision.ShouldRunWorkflow
+func (config *ArianeConfig) ShouldRunWorkflow(ctx context.Context, workflow string, files []*github.CommitFile) decision.Decision {
+	files, d, ok := config.filesWithStatuses(workflow, files)
+	if !ok {
+		return d
+	}
+	return logInvalidRegex(ctx, decision.ShouldRunWorkflow(config.DecisionConfig(), workflow, filenames(files)))
+}
+
+// logInvalidRegex logs decisions made on invalid paths filters, which the config validation should have caught
+func logInvalidRegex(ctx context.Context, d decision.Decision) decision.Decision {
+	if d.Reason == decision.ReasonInvalidPathsRegex {
+		log.FromContext(ctx).Error().Msg(d.Message)
+	}
+	return d
+}
@@ -7,13 +7,16 @@ import (
 	"context"
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-github/v75/github"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
 	"github.com/cilium/ariane/internal/log"
 )
 
@@ -29,7 +32,7 @@ func Test_CheckForTrigger(t *testing.T) {
 		{
 			config: config.ArianeConfig{
 				Triggers: map[string]config.TriggerConfig{
-					"/cute": {[]string{"cte.yaml"}},
+					"/cute": {Workflows: []string{"cte.yaml"}},
 				},
 			},
 			comment:           "/cute",
@@ -39,7 +42,7 @@ func Test_CheckForTrigger(t *testing.T) {
 		{
 			config: config.ArianeConfig{
 				Triggers: map[string]config.TriggerConfig{
-					"/cute": {[]string{"cte.yaml"}},
+					"/cute": {Workflows: []string{"cte.yaml"}},
 				},
 			},
 			comment: "/cute cilium/cute-nationwide",
@@ -47,7 +50,7 @@ func Test_CheckForTrigger(t *testing.T) {
 		{
 			config: config.ArianeConfig{
 				Triggers: map[string]config.TriggerConfig{
-					"/cute (.+)": {[]string{"cte.yaml"}},
+					"/cute (.+)": {Workflows: []string{"cte.yaml"}},
 				},
 			},
 			comment:           "/cute {\"repo\":\"zerohash\"}",
@@ -57,26 +60,214 @@ func Test_CheckForTrigger(t *testing.T) {
 		{
 			config: config.ArianeConfig{
 				Triggers: map[string]config.TriggerConfig{
-					`\invalid-reg-exp`: {[]string{"invalid.yaml"}},
+					`\invalid-reg-exp`: {Workflows: []string{"invalid.yaml"}},
 				},
 			},
 			comment: "/test invalid regex",
 		},
 	}
 	for _, tt := range cases {
-		actualSubmatch, actualWorkflows := tt.config.CheckForTrigger(ctx, tt.comment)
+		actualSubmatch, actualWorkflows, actualDecision := tt.config.CheckForTrigger(ctx, tt.comment)
 
 		assert.Equal(t, tt.expectedSubmatch, actualSubmatch)
 		assert.Equal(t, tt.expectedWorkflows, actualWorkflows)
+		expectedReason := decision.ReasonNoTriggerMatched
+		if tt.expectedSubmatch != nil {
+			expectedReason = decision.ReasonTriggerMatched
+		}
+		assert.Equal(t, expectedReason, actualDecision.Reason)
+		assert.Equal(t, tt.expectedSubmatch != nil, actualDecision.Result)
+	}
+}
+
+func Test_TriggersForFiles(t *testing.T) {
+	config := &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{
+			"/foo":  {Workflows: []string{"foo.yaml"}},
+			"/bar":  {Workflows: []string{"bar.yaml"}},
+			"/test": {Workflows: []string{"foo.yaml", "bar.yaml"}},
+		},
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"bar.yaml": {
+				PathsRegex: "(x|y)/",
+			},
+			"foo.yaml": {
+				PathsIgnoreRegex: "(test|Documentation)/",
+			},
+		},
+	}
+
+	testCases := []struct {
+		FilenamesJson    []byte
+		ExpectedTriggers []string
+	}{
+		{
+			FilenamesJson:    []byte(`[{"filename": "x/handler.go"}]`),
+			ExpectedTriggers: []string{"/bar", "/foo", "/test"},
+		},
+		{
+			FilenamesJson:    []byte(`[{"filename": "Documentation/index.rst"}, {"filename": ".github/workflows/bar.yaml"}]`),
+			ExpectedTriggers: []string{"/bar", "/test"},
+		},
+		{
+			FilenamesJson: []byte(`[{"filename": "Documentation/index.rst"}]`),
+		},
+	}
+
+	for idx, testCase := range testCases {
+		files := []*github.CommitFile{}
+		if err := json.Unmarshal(testCase.FilenamesJson, &files); err != nil {
+			t.Errorf("[TEST%v] TriggersForFiles failed.\nCould not unmarshal the mocked json data.", idx+1)
+		}
+		assert.Equal(t, testCase.ExpectedTriggers, config.TriggersForFiles(context.Background(), files), "[TEST%v]", idx+1)
+	}
+}
+
+func Test_TriggerExample(t *testing.T) {
+	config := &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{
+			`/release-test (v\S+)`: {Example: "/release-test v1.16.0"},
+		},
+	}
+
+	testCases := []struct {
+		Trigger         string
+		ExpectedExample string
+	}{
+		{Trigger: "/test", ExpectedExample: "/test"},
+		{Trigger: "/test(-.*)?", ExpectedExample: "/test"},
+		{Trigger: "^/ci-(e2e|eks)$", ExpectedExample: "/ci-e2e"},
+		{Trigger: "/test-[0-9]+", ExpectedExample: "/test-0"},
+		{Trigger: `/backport (?P<branch>v\S+)`, ExpectedExample: "/backport <branch>"},
+		{Trigger: `/bump (v\S+)`, ExpectedExample: "/bump v…"},
+		{Trigger: `/release-test (v\S+)`, ExpectedExample: "/release-test v1.16.0"},
+		{Trigger: "/invalid(", ExpectedExample: "/invalid("},
+	}
+
+	for idx, testCase := range testCases {
+		assert.Equal(t, testCase.ExpectedExample, config.TriggerExample(testCase.Trigger), "[TEST%v] %v", idx+1, testCase.Trigger)
 	}
 }
 
+func Test_Validate(t *testing.T) {
+	testCases := []struct {
+		Config         config.ArianeConfig
+		ExpectedErrors []string
+	}{
+		{
+			Config: config.ArianeConfig{
+				Triggers:  map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+				Workflows: map[string]config.WorkflowPathsRegexConfig{"foo.yaml": {PathsRegex: "x/"}},
+			},
+		},
+		{
+			Config: config.ArianeConfig{
+				Triggers:         map[string]config.TriggerConfig{"/test(": {Workflows: []string{"foo.yaml"}}, "/empty": {}},
+				Workflows:        map[string]config.WorkflowPathsRegexConfig{"foo.yaml": {PathsRegex: "x/", PathsIgnoreRegex: "y/"}},
+				ApprovalReaction: "thumbsup",
+				Messages:         config.MessagesConfig{Help: "{{ .Triggers "},
+			},
+			ExpectedErrors: []string{
+				`trigger "/empty": no workflows`,
+				`trigger "/test(": invalid regex`,
+				`workflow "foo.yaml": paths-regex and paths-ignore-regex are mutually exclusive`,
+				`approval-reaction: unsupported reaction "thumbsup"`,
+				`messages.help: invalid template`,
+			},
+		},
+		{
+			Config: config.ArianeConfig{
+				Triggers:                  map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}, Args: map[string]config.ArgConfig{"focus": {Type: "array"}}}},
+				HoldFirstTimeContributors: true,
+				Reactions:                 config.ReactionsConfig{Dispatched: "ship", Held: "eyes"},
+				Mergeability:              config.MergeabilityConfig{Enabled: true, MaxBehind: -1},
+				RetryLimit:                config.RetryLimitConfig{MaxRetries: -1},
+				MergeGroup:                config.MergeGroupConfig{RequiredWorkflows: map[string]string{"ci-e2e": "e2e.yaml", "ci-unit": ""}},
+			},
+			ExpectedErrors: []string{
+				`trigger "/test": arg "focus": invalid type "array"`,
+				`hold-first-time-contributors: approval-reaction must be set`,
+				`reactions.dispatched: unsupported reaction "ship"`,
+				`merge-group.required-workflows: check "ci-unit": no workflow`,
+				`mergeability.max-behind: must not be negative`,
+				`retry-limit.max-retries: must not be negative`,
+			},
+		},
+		{
+			Config: config.ArianeConfig{
+				Triggers: map[string]config.TriggerConfig{
+					"/release-test":         {Workflows: []string{"foo.yaml"}, Tag: true},
+					"/release-test-(v\\S+)": {Workflows: []string{"foo.yaml"}, Tag: true},
+				},
+			},
+			ExpectedErrors: []string{
+				`trigger "/release-test": tag triggers must capture the tag in a submatch`,
+			},
+		},
+		{
+			Config: config.ArianeConfig{
+				Triggers:         map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+				UndeclaredInputs: "ignore",
+				HeadMoved:        "dispatch",
+				LargePR:          config.LargePRConfig{MaxFiles: -1, Policy: "skip"},
+			},
+			ExpectedErrors: []string{
+				`undeclared-inputs: must be "drop" or "reject"`,
+				`head-moved: must be "reevaluate" or "abort"`,
+				"large-pr.max-files: must not be negative",
+				`large-pr.policy: must be "run-all" or "force"`,
+			},
+		},
+		{
+			Config: config.ArianeConfig{
+				Triggers: map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+				Workflows: map[string]config.WorkflowPathsRegexConfig{"foo.yaml": {
+					Statuses:   []string{"added", "created"},
+					PathInputs: []config.PathInputsConfig{{PathsRegex: "bpf/(", Inputs: map[string]string{"test-arm64": "true"}}, {PathsRegex: "docs/"}},
+				}},
+				ChangedFiles:   "merge-commit",
+				Reporter:       "status",
+				ReadyForReview: "/tests",
+				StatusCommand:  "/test",
+			},
+			ExpectedErrors: []string{
+				`workflow "foo.yaml": unsupported status "created"`,
+				`workflow "foo.yaml": invalid path-inputs paths-regex`,
+				`workflow "foo.yaml": path-inputs rule "docs/" sets no inputs`,
+				`changed-files: must be "pull-request" or "merge-base"`,
+				`reporter: must be "checks" or "statuses"`,
+				`ready-for-review: "/tests" does not match any trigger`,
+				`status-command: "/test" matches a trigger`,
+			},
+		},
+	}
+
+	for idx, testCase := range testCases {
+		err := testCase.Config.Validate()
+		if len(testCase.ExpectedErrors) == 0 {
+			assert.NoError(t, err, "[TEST%v]", idx+1)
+			continue
+		}
+		for _, expected := range testCase.ExpectedErrors {
+			assert.ErrorContains(t, err, expected, "[TEST%v]", idx+1)
+		}
+	}
+}
+
+func Test_IsStatusCommand(t *testing.T) {
+	arianeConfig := &config.ArianeConfig{}
+	assert.False(t, arianeConfig.IsStatusCommand("/status"), "only /ariane status is replied to by default")
+	arianeConfig.StatusCommand = "/status"
+	assert.True(t, arianeConfig.IsStatusCommand(" /status\n"))
+	assert.False(t, arianeConfig.IsStatusCommand("/status please"))
+}
+
 func Test_ShouldRunOnlyWorkflows(t *testing.T) {
 	config := &config.ArianeConfig{
 		Triggers: map[string]config.TriggerConfig{
-			"/foo":            {[]string{"foo.yaml"}},
-			"/bar":            {[]string{"bar.yaml"}},
-			"/enterprise-foo": {[]string{"enterprise-foo.yaml"}},
+			"/foo":            {Workflows: []string{"foo.yaml"}},
+			"/bar":            {Workflows: []string{"bar.yaml"}},
+			"/enterprise-foo": {Workflows: []string{"enterprise-foo.yaml"}},
 		},
 		Workflows: map[string]config.WorkflowPathsRegexConfig{},
 		AllowedTeams: []string{
@@ -89,48 +280,56 @@ func Test_ShouldRunOnlyWorkflows(t *testing.T) {
 		Workflow       string
 		FilenamesJson  []byte
 		ExpectedResult bool
+		ExpectedCode   decision.Reason
 		ExpectedReason string
 	}{
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/foo.yaml"}, {"filename": "test/testdata.json"}, {"filename": "nocode/Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonWorkflowChanged,
 			ExpectedReason: "changes exist on the \"workflow\" var (foo.yaml) under .github/workflows/",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/bar.yaml"}, {"filename": "test/testdata.json"}, {"filename": "nocode/Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonNonWorkflowChanges,
 			ExpectedReason: "a workflow was changed, however not foo.yaml - Nevertheless, non-workflow files were updated, hence foo.yaml needs to run",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "nocode/Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonNonWorkflowChanges,
 			ExpectedReason: "No workflows were updated - however, there are other files changed, hence the foo.yaml workflow needs to runs",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonNonWorkflowChanges,
 			ExpectedReason: "No workflows were updated, and no regexps exist - there are other files changed, hence the foo.yaml workflow needs to runs",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNoChanges,
 			ExpectedReason: "No changes committed, hence nothing new to test",
 		},
 		{
 			Workflow:       "bar.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "x/lib3/handlers/handler.go"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonNonWorkflowChanges,
 			ExpectedReason: "No workflows were updated, and no regexps exist - there are other files changed, hence the foo.yaml workflow needs to runs.",
 		},
 		{
 			Workflow:       "enterprise-foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/foo.yaml"}, {"filename": ".github/workflows/config/set-env"}]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonOnlyOtherWorkflows,
 			ExpectedReason: "Only workflows were changed, but not the enterprise-foo.yaml one. No need to run the workflow.",
 		},
 	}
@@ -141,7 +340,8 @@ func Test_ShouldRunOnlyWorkflows(t *testing.T) {
 			t.Errorf("[TEST%v] ShouldRunOnlyWorkflow failed.\nCould not unmarshal the mocked json data.", idx+1)
 		}
 		result := config.ShouldRunOnlyWorkflows(context.Background(), testCase.Workflow, files)
-		if result != testCase.ExpectedResult {
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] ShouldRunOnlyWorkflows: %s", idx+1, result.Message)
+		if result.Result != testCase.ExpectedResult {
 			t.Errorf("[TEST%v] ShouldRunOnlyWorkflows failed.\nfiles: %v;\nExpected reason to pass the test: %v", idx+1, files, testCase.ExpectedReason)
 		}
 	}
@@ -150,9 +350,9 @@ func Test_ShouldRunOnlyWorkflows(t *testing.T) {
 func Test_ShouldRunWorkflow(t *testing.T) {
 	config := &config.ArianeConfig{
 		Triggers: map[string]config.TriggerConfig{
-			"/foo":            {[]string{"foo.yaml"}},
-			"/bar":            {[]string{"bar.yaml"}},
-			"/enterprise-foo": {[]string{"enterprise-foo.yaml"}},
+			"/foo":            {Workflows: []string{"foo.yaml"}},
+			"/bar":            {Workflows: []string{"bar.yaml"}},
+			"/enterprise-foo": {Workflows: []string{"enterprise-foo.yaml"}},
 		},
 		Workflows: map[string]config.WorkflowPathsRegexConfig{
 			"bar.yaml": {
@@ -162,6 +362,10 @@ func Test_ShouldRunWorkflow(t *testing.T) {
 				PathsIgnoreRegex: "(test|Documentation|myproject)/",
 			},
 			"enterprise-foo.yaml": {},
+			"migrations.yaml": {
+				PathsRegex: "schemas/",
+				Statuses:   []string{"added"},
+			},
 			"foobar.yaml": {
 				PathsRegex:       "(x|y)/",
 				PathsIgnoreRegex: "(test|Documentation|myproject)/",
@@ -177,6 +381,7 @@ func Test_ShouldRunWorkflow(t *testing.T) {
 		Workflow       string
 		FilenamesJson  []byte
 		ExpectedResult bool
+		ExpectedCode   decision.Reason
 		ExpectedReason string
 	}{
 		// foo.yaml only defines paths-ignore-regex
@@ -184,68 +389,129 @@ func Test_ShouldRunWorkflow(t *testing.T) {
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/foo.yaml"}, {"filename": "test/testdata.json"}, {"filename": "nocode/Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonWorkflowChanged,
 			ExpectedReason: "changes exist on 3 files, and only one needs to be ignored (test/testdata.json) - not matching all 3 files. WF runs.",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/foo.yaml"}, {"filename": ".github/workflows/bar.yaml"}, {"filename": "test/testdata.json"}, {"filename": "Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonWorkflowChanged,
 			ExpectedReason: "changes exist on 4 files, including the workflow to trigger - besides other workflows being modified, as well as matching files on paths-ignore-regex",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/bar.yaml"}, {"filename": "test/testdata.json"}, {"filename": "Documentation/operations-guide.rst"}]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonAllPathsIgnored,
 			ExpectedReason: "changes exist on a file that is not matched by paths-ignore-regex, but it is another workflow",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "nocode/Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPathsNotIgnored,
 			ExpectedReason: "changes exist on a file within the nocode folder (the regexp is actually '^Documentation/')",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "Documentation/operations-guide.rst"}]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonAllPathsIgnored,
 			ExpectedReason: "all changes are matched by paths-ignore-regex",
 		},
 		{
 			Workflow:       "foo.yaml",
 			FilenamesJson:  []byte(`[]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNoChanges,
 			ExpectedReason: "No changes committed, hence nothing new to test",
 		},
+		{
+			Workflow:       "foo.yaml",
+			FilenamesJson:  []byte(`[{"filename": "test/testdata-v2.json", "previous_filename": "test/testdata.json", "status": "renamed"}]`),
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonAllPathsIgnored,
+			ExpectedReason: "a file is renamed within the ignored paths - the workflow will not run",
+		},
+		{
+			Workflow:       "foo.yaml",
+			FilenamesJson:  []byte(`[{"filename": "test/handler.go", "previous_filename": "pkg/handler.go", "status": "renamed"}]`),
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPathsNotIgnored,
+			ExpectedReason: "a file is moved out of the ignored paths into them - the workflow runs",
+		},
 		// bar.yaml only defines paths-regex
 		{
 			Workflow:       "bar.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "x/lib3/handlers/handler.go"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPathsMatched,
 			ExpectedReason: "changes match a file on paths-regex. Workflow will run.",
 		},
 		{
 			Workflow:       "bar.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "Documentation/operations-guide.rst"}]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonPathsNotMatched,
 			ExpectedReason: "changes do not match paths-regex, and the workflow to trigger has not been modified. Workflow will not run.",
 		},
 		{
 			Workflow:       "bar.yaml",
 			FilenamesJson:  []byte(`[{"filename": "test/testdata.json"}, {"filename": "Documentation/operations-guide.rst"}, {"filename": ".github/workflows/bar.yaml"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonWorkflowChanged,
 			ExpectedReason: "changes do not match paths-regex, but the workflow to trigger has changed. Workflow will run.",
 		},
+		{
+			Workflow:       "bar.yaml",
+			FilenamesJson:  []byte(`[{"filename": "z/handler.go", "previous_filename": "x/handler.go", "status": "renamed"}]`),
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPathsMatched,
+			ExpectedReason: "a file is moved out of paths-regex. Workflow will run.",
+		},
+		// migrations.yaml only evaluates paths-regex against added files
+		{
+			Workflow:       "migrations.yaml",
+			FilenamesJson:  []byte(`[{"filename": "schemas/v2.sql", "status": "added"}, {"filename": "pkg/db.go", "status": "modified"}]`),
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPathsMatched,
+			ExpectedReason: "a schema is added. Workflow will run.",
+		},
+		{
+			Workflow:       "migrations.yaml",
+			FilenamesJson:  []byte(`[{"filename": "schemas/v1.sql", "status": "modified"}]`),
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonStatusesNotMatched,
+			ExpectedReason: "a schema is only modified. Workflow will not run.",
+		},
+		{
+			Workflow:       "migrations.yaml",
+			FilenamesJson:  []byte(`[{"filename": "schemas/v1.sql", "status": "modified"}, {"filename": "docs/schemas.md", "status": "added"}]`),
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonPathsNotMatched,
+			ExpectedReason: "the added files do not match paths-regex. Workflow will not run.",
+		},
+		{
+			Workflow:       "migrations.yaml",
+			FilenamesJson:  []byte(`[{"filename": ".github/workflows/migrations.yaml", "status": "modified"}]`),
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonWorkflowChanged,
+			ExpectedReason: "changes to the workflow itself count whatever their status. Workflow will run.",
+		},
 		// enterprise-foo.yaml does not define paths-regex nor paths-ignore-regex
 		{
 			Workflow:       "enterprise-foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/foo.yaml"}, {"filename": ".github/workflows/bar.yaml"}, {"filename": "test/testdata.json"}, {"filename": "nocode/Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPathsNotIgnored,
 			ExpectedReason: "changes exist and no paths-regex or paths-ignore-regex are evaluated - no matter 2 out of 4 files are other workflows than the one that will be triggered",
 		},
 		{
 			Workflow:       "enterprise-foo.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/foo.yaml"}, {"filename": ".github/workflows/bar.yaml"}]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonAllPathsIgnored,
 			ExpectedReason: "changes exist and no paths-regex or paths-ignore-regex are evaluated - however, changes on other workflows do not qualify to trigger the actual workflow (enterprise-foo.yaml). WF will not run",
 		},
 		// foobar.yaml does define both paths-regex and paths-ignore-regex (default: run the workflow)
@@ -253,18 +519,21 @@ func Test_ShouldRunWorkflow(t *testing.T) {
 			Workflow:       "foobar.yaml",
 			FilenamesJson:  []byte(`[{"filename": ".github/workflows/foo.yaml"}, {"filename": ".github/workflows/bar.yaml"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonConflictingPathsFilters,
 			ExpectedReason: "changes exist and both paths-regex and paths-ignore-regex are defined - default to run the workflow without evaluating any further",
 		},
 		{
 			Workflow:       "foobar.yaml",
 			FilenamesJson:  []byte(`[{"filename": "Documentation/operations-guide.rst"}]`),
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonConflictingPathsFilters,
 			ExpectedReason: "changes exist and both paths-regex and paths-ignore-regex are defined - default to run the workflow without evaluating any further",
 		},
 		{
 			Workflow:       "foobar.yaml",
 			FilenamesJson:  []byte(`[]`),
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNoChanges,
 			ExpectedReason: "no changes exist, despite both paths-regex and paths-ignore-regex being defined - the workflow will not run",
 		},
 	}
@@ -275,8 +544,242 @@ func Test_ShouldRunWorkflow(t *testing.T) {
 			t.Errorf("[TEST%v] ShouldrunWorkflow failed.\nCould not unmarshal the mocked json data.", idx+1)
 		}
 		result := config.ShouldRunWorkflow(context.Background(), testCase.Workflow, files)
-		if result != testCase.ExpectedResult {
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] ShouldRunWorkflow: %s", idx+1, result.Message)
+		if result.Result != testCase.ExpectedResult {
 			t.Errorf("[TEST%v] ShouldRunWorkflow failed.\nfiles: %v;\nExpected reason to pass the test: %v", idx+1, files, testCase.ExpectedReason)
 		}
 	}
 }
+
+func Test_ParseArgs(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	ctx := log.WithLogger(context.Background(), &logger)
+	arianeConfig := config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{
+			"/test": {
+				Workflows: []string{"foo.yaml"},
+				Args: map[string]config.ArgConfig{
+					"focus":  {Type: config.ArgTypeList, Required: true},
+					"kernel": {Type: config.ArgTypeString},
+				},
+			},
+			"/build": {Workflows: []string{"build.yaml"}},
+		},
+	}
+	cases := []struct {
+		comment        string
+		expectedArgs   map[string]any
+		expectedReason decision.Reason
+	}{
+		{
+			comment:        "/test\r\n```yaml\r\nfocus: [ipsec]\r\nkernel: \"6.1\"\r\n```",
+			expectedArgs:   map[string]any{"focus": []any{"ipsec"}, "kernel": "6.1"},
+			expectedReason: decision.ReasonArgsValid,
+		},
+		{
+			comment:        "/test\n```\nfocus:\n  - ipsec\n```\n",
+			expectedArgs:   map[string]any{"focus": []any{"ipsec"}},
+			expectedReason: decision.ReasonArgsValid,
+		},
+		{
+			comment:        "/test",
+			expectedReason: decision.ReasonInvalidArgs,
+		},
+		{
+			comment:        "/test\n```yaml\nfocus: ipsec\n```",
+			expectedReason: decision.ReasonInvalidArgs,
+		},
+		{
+			comment:        "/test\n```yaml\nfocus: [ipsec]\nunknown: true\n```",
+			expectedReason: decision.ReasonInvalidArgs,
+		},
+		{
+			comment:        "/test\n```yaml\nfocus: [ipsec\n```",
+			expectedReason: decision.ReasonInvalidArgs,
+		},
+		{
+			comment:        "/build",
+			expectedReason: decision.ReasonNoArgs,
+		},
+		{
+			comment:        "/build\n```yaml\nfocus: [ipsec]\n```",
+			expectedReason: decision.ReasonInvalidArgs,
+		},
+	}
+	for idx, tt := range cases {
+		comment, block := config.SplitArgsBlock(tt.comment)
+		args, actualDecision := arianeConfig.ParseArgs(ctx, comment, block)
+		assert.Equal(t, tt.expectedReason, actualDecision.Reason, "[TEST%v] %s", idx+1, actualDecision.Message)
+		assert.Equal(t, tt.expectedArgs, args, "[TEST%v]", idx+1)
+	}
+}
+
+func Test_SplitContextOverride(t *testing.T) {
+	testCases := []struct {
+		Comment         string
+		ExpectedComment string
+		ExpectedRef     string
+	}{
+		{Comment: "/test context=main", ExpectedComment: "/test", ExpectedRef: "main"},
+		{Comment: "/test foo context=feature/bar ", ExpectedComment: "/test foo", ExpectedRef: "feature/bar"},
+		{Comment: "/test", ExpectedComment: "/test"},
+		{Comment: "/test context=main foo", ExpectedComment: "/test context=main foo"},
+	}
+	for idx, testCase := range testCases {
+		comment, ref := config.SplitContextOverride(testCase.Comment)
+		assert.Equal(t, testCase.ExpectedComment, comment, "[TEST%v]", idx+1)
+		assert.Equal(t, testCase.ExpectedRef, ref, "[TEST%v]", idx+1)
+	}
+}
+
+func Test_SplitDryRun(t *testing.T) {
+	testCases := []struct {
+		Comment         string
+		ExpectedComment string
+		ExpectedDryRun  bool
+	}{
+		{Comment: "/test --dry-run", ExpectedComment: "/test", ExpectedDryRun: true},
+		{Comment: "/test --dry-run context=main", ExpectedComment: "/test context=main", ExpectedDryRun: true},
+		{Comment: "/test foo --dry-run", ExpectedComment: "/test foo", ExpectedDryRun: true},
+		{Comment: "/test --dry-runs", ExpectedComment: "/test --dry-runs"},
+		{Comment: "/test", ExpectedComment: "/test"},
+	}
+	for idx, testCase := range testCases {
+		comment, dryRun := config.SplitDryRun(testCase.Comment)
+		assert.Equal(t, testCase.ExpectedComment, comment, "[TEST%v]", idx+1)
+		assert.Equal(t, testCase.ExpectedDryRun, dryRun, "[TEST%v]", idx+1)
+	}
+}
+
+func Test_SplitForce(t *testing.T) {
+	testCases := []struct {
+		Comment         string
+		ExpectedComment string
+		ExpectedForce   bool
+	}{
+		{Comment: "/test --force", ExpectedComment: "/test", ExpectedForce: true},
+		{Comment: "/test --force context=main", ExpectedComment: "/test context=main", ExpectedForce: true},
+		{Comment: "/test --forced", ExpectedComment: "/test --forced"},
+		{Comment: "/test", ExpectedComment: "/test"},
+	}
+	for idx, testCase := range testCases {
+		comment, force := config.SplitForce(testCase.Comment)
+		assert.Equal(t, testCase.ExpectedComment, comment, "[TEST%v]", idx+1)
+		assert.Equal(t, testCase.ExpectedForce, force, "[TEST%v]", idx+1)
+	}
+}
+
+func Test_SplitIgnorePathsFilter(t *testing.T) {
+	testCases := []struct {
+		Comment         string
+		ExpectedComment string
+		ExpectedIgnore  bool
+	}{
+		{Comment: "/test --ignore-paths-filter", ExpectedComment: "/test", ExpectedIgnore: true},
+		{Comment: "/test --ignore-paths-filter --force", ExpectedComment: "/test --force", ExpectedIgnore: true},
+		{Comment: "/test --ignore-paths-filters", ExpectedComment: "/test --ignore-paths-filters"},
+		{Comment: "/test", ExpectedComment: "/test"},
+	}
+	for idx, testCase := range testCases {
+		comment, ignore := config.SplitIgnorePathsFilter(testCase.Comment)
+		assert.Equal(t, testCase.ExpectedComment, comment, "[TEST%v]", idx+1)
+		assert.Equal(t, testCase.ExpectedIgnore, ignore, "[TEST%v]", idx+1)
+	}
+}
+
+func Test_IdempotencyKey(t *testing.T) {
+	arianeConfig := config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"foo.yaml": {PathsRegex: "src/", IdempotencyKey: "{{ range .MatchedFiles }}{{ .Filename }}@{{ .SHA }} {{ end }}{{ .ExtraArgs }}"},
+			"bar.yaml": {PathsRegex: "src/"},
+		},
+	}
+	files := func(files ...string) []*github.CommitFile {
+		var commitFiles []*github.CommitFile
+		for _, file := range files {
+			filename, SHA, _ := strings.Cut(file, "@")
+			commitFiles = append(commitFiles, &github.CommitFile{Filename: github.String(filename), SHA: github.String(SHA)})
+		}
+		return commitFiles
+	}
+
+	key, err := arianeConfig.IdempotencyKey("foo.yaml", files("src/main.go@1", "docs/README.md@2"), "", nil)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, key)
+
+	same, _ := arianeConfig.IdempotencyKey("foo.yaml", files("src/main.go@1", "docs/README.md@3"), "", nil)
+	assert.Equal(t, key, same, "changes to files outside of the paths filters keep the key")
+	changed, _ := arianeConfig.IdempotencyKey("foo.yaml", files("src/main.go@4", "docs/README.md@2"), "", nil)
+	assert.NotEqual(t, key, changed, "changes to matched files change the key")
+	args, _ := arianeConfig.IdempotencyKey("foo.yaml", files("src/main.go@1", "docs/README.md@2"), "focus", nil)
+	assert.NotEqual(t, key, args, "other arguments change the key")
+
+	none, err := arianeConfig.IdempotencyKey("bar.yaml", files("src/main.go@1"), "", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, none, "workflows without idempotency-key have no key")
+}
+
+func Test_PaginationWithDefaults(t *testing.T) {
+	pagination := config.PaginationConfig{
+		Files:        config.ListLimit{PerPage: 500},
+		WorkflowRuns: config.ListLimit{PerPage: 20, MaxPages: 3},
+	}.WithDefaults()
+	assert.Equal(t, config.DefaultPagination.PullRequests, pagination.PullRequests, "unset limits use the defaults")
+	assert.Equal(t, config.ListLimit{PerPage: 100, MaxPages: config.DefaultPagination.Files.MaxPages}, pagination.Files, "pages are capped at 100 items")
+	assert.Equal(t, config.ListLimit{PerPage: 20, MaxPages: 3}, pagination.WorkflowRuns)
+}
+
+func Test_Overrides(t *testing.T) {
+	timeout := 5 * time.Minute
+	enabled := true
+	overrides := config.Overrides{
+		"cilium/Docs": {
+			IssueCommands:         &enabled,
+			DispatchVerifyTimeout: &timeout,
+			Handlers:              map[string]bool{"merge_group": true},
+			Pagination:            config.PaginationConfig{Files: config.ListLimit{MaxPages: 5}},
+		},
+	}
+	global := config.RepositorySettings{DispatchVerifyTimeout: time.Minute, Handlers: map[string]bool{"merge_group": false, "push": false}, Pagination: config.DefaultPagination}
+
+	assert.Equal(t, global, overrides.For("cilium", "cilium", global), "repositories without overrides keep the global settings")
+
+	settings := overrides.For("cilium", "docs", global)
+	assert.True(t, settings.IssueCommands)
+	assert.Equal(t, timeout, settings.DispatchVerifyTimeout)
+	assert.Equal(t, config.ListLimit{PerPage: config.DefaultPagination.Files.PerPage, MaxPages: 5}, settings.Pagination.Files, "unset limits keep the global values")
+	assert.Equal(t, config.DefaultPagination.PullRequests, settings.Pagination.PullRequests)
+	assert.True(t, settings.HandlerEnabled("merge_group"), "handlers are enabled per repository")
+	assert.False(t, settings.HandlerEnabled("push"), "unlisted handlers keep the global flags")
+	assert.True(t, settings.HandlerEnabled("pull_request"), "handlers are enabled by default")
+	assert.False(t, global.HandlerEnabled("merge_group"), "the global flags are not modified")
+
+	assert.NoError(t, overrides.Validate())
+	err := config.Overrides{"cilium": {}, "cilium/docs/extra": {}}.Validate()
+	assert.ErrorContains(t, err, `"cilium" is not an owner/repo name`)
+	assert.ErrorContains(t, err, `"cilium/docs/extra" is not an owner/repo name`)
+}
+
+func Test_PathInputs(t *testing.T) {
+	arianeConfig := config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"foo.yaml": {PathInputs: []config.PathInputsConfig{
+				{PathsRegex: "bpf/", Inputs: map[string]string{"test-arm64": "true", "kernel": "all"}},
+				{PathsRegex: "bpf/arm64/", Inputs: map[string]string{"kernel": "arm64"}},
+				{PathsRegex: "docs/", Inputs: map[string]string{"docs": "true"}},
+			}},
+		},
+	}
+	files := func(files ...string) []*github.CommitFile {
+		var commitFiles []*github.CommitFile
+		for _, file := range files {
+			commitFiles = append(commitFiles, &github.CommitFile{Filename: github.String(file)})
+		}
+		return commitFiles
+	}
+
+	assert.Equal(t, map[string]string{"test-arm64": "true", "kernel": "all"}, arianeConfig.PathInputs("foo.yaml", files("bpf/lib.h", "main.go")))
+	assert.Equal(t, map[string]string{"test-arm64": "true", "kernel": "arm64"}, arianeConfig.PathInputs("foo.yaml", files("bpf/lib.h", "bpf/arm64/lib.h")), "later rules override earlier ones")
+	assert.Nil(t, arianeConfig.PathInputs("foo.yaml", files("main.go")))
+	assert.Nil(t, arianeConfig.PathInputs("bar.yaml", files("bpf/lib.h")))
+}
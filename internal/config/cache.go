@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+)
+
+// Cache keeps the Ariane configs fetched from repositories for a while, keyed by repository and ref.
+// A nil Cache is valid and caches nothing.
+type Cache struct {
+	cache *gocache.Cache
+}
+
+// NewCache creates a cache whose entries expire after ttl. Caching is disabled if ttl is zero.
+func NewCache(ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &Cache{cache: gocache.New(ttl, 2*ttl)}
+}
+
+func cacheKey(owner, repo, ref string) string {
+	return owner + "/" + repo + "@" + ref
+}
+
+// Get returns the cached config for the given repository and ref, if any.
+func (c *Cache) Get(owner, repo, ref string) (*ArianeConfig, bool) {
+	if c == nil {
+		return nil, false
+	}
+	v, ok := c.cache.Get(cacheKey(owner, repo, ref))
+	if !ok {
+		return nil, false
+	}
+	return v.(*ArianeConfig), true
+}
+
+// GetWithExpiration returns the cached config for the given repository and ref, if any, along with when it expires.
+func (c *Cache) GetWithExpiration(owner, repo, ref string) (*ArianeConfig, time.Time, bool) {
+	if c == nil {
+		return nil, time.Time{}, false
+	}
+	v, expiresAt, ok := c.cache.GetWithExpiration(cacheKey(owner, repo, ref))
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return v.(*ArianeConfig), expiresAt, true
+}
+
+// Set caches the config for the given repository and ref, replacing any cached one.
+func (c *Cache) Set(owner, repo, ref string, config *ArianeConfig) {
+	if c == nil {
+		return
+	}
+	c.cache.SetDefault(cacheKey(owner, repo, ref), config)
+}
+
+// Invalidate drops the cached config for the given repository and ref.
+func (c *Cache) Invalidate(owner, repo, ref string) {
+	if c == nil {
+		return
+	}
+	c.cache.Delete(cacheKey(owner, repo, ref))
+}
+
+// CacheEntry is a config cached for a repository and ref.
+type CacheEntry struct {
+	Owner     string
+	Repo      string
+	Ref       string
+	Config    *ArianeConfig
+	ExpiresAt time.Time
+}
+
+// Entries returns the configs cached for a repository, given as owner/repo, or for all of them if empty, sorted by
+// repository and ref.
+func (c *Cache) Entries(repository string) []CacheEntry {
+	if c == nil {
+		return nil
+	}
+	var entries []CacheEntry
+	for key, item := range c.cache.Items() {
+		name, ref, _ := strings.Cut(key, "@")
+		if repository != "" && name != repository {
+			continue
+		}
+		owner, repo, _ := strings.Cut(name, "/")
+		entries = append(entries, CacheEntry{Owner: owner, Repo: repo, Ref: ref, Config: item.Object.(*ArianeConfig), ExpiresAt: time.Unix(0, item.Expiration)})
+	}
+	slices.SortFunc(entries, func(a, b CacheEntry) int {
+		return strings.Compare(cacheKey(a.Owner, a.Repo, a.Ref), cacheKey(b.Owner, b.Repo, b.Ref))
+	})
+	return entries
+}
+
+// MatchesTrigger reports whether the comment matches a trigger, or the status command, of any config cached for the
+// repository, whatever its ref, and whether any config of the repository is cached at all, so comments can be ignored before looking up which
+// ref their config is read from.
+func (c *Cache) MatchesTrigger(owner, repo, comment string) (matched, known bool) {
+	if c == nil {
+		return false, false
+	}
+	prefix := cacheKey(owner, repo, "")
+	for key, item := range c.cache.Items() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		known = true
+		config := item.Object.(*ArianeConfig)
+		if _, ok := config.MatchedTrigger(comment); ok || config.IsStatusCommand(comment) {
+			return true, true
+		}
+	}
+	return false, known
+}
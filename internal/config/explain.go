@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// filters a changed file can hit, reported in FileExplanation
+const (
+	filterWorkflowFile     = "workflow-file"
+	filterOtherWorkflow    = "other-workflow"
+	filterPathsRegex       = "paths-regex"
+	filterPathsIgnoreRegex = "paths-ignore-regex"
+)
+
+// Explanation details how the config handles a comment and the files changed by a PR, for debugging purposes
+type Explanation struct {
+	Comment string `json:"comment"`
+	// Triggers lists every trigger regex, and whether the comment matches it
+	Triggers []TriggerExplanation `json:"triggers"`
+	Trigger  decision.Decision    `json:"trigger"`
+	// Workflows lists the workflows of the matched trigger, if any
+	Workflows []WorkflowExplanation `json:"workflows,omitempty"`
+}
+
+type TriggerExplanation struct {
+	Regex    string   `json:"regex"`
+	Matched  bool     `json:"matched"`
+	Submatch []string `json:"submatch,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+type WorkflowExplanation struct {
+	Workflow         string `json:"workflow"`
+	Name             string `json:"name"`
+	Configured       bool   `json:"configured"`
+	PathsRegex       string `json:"pathsRegex,omitempty"`
+	PathsIgnoreRegex string `json:"pathsIgnoreRegex,omitempty"`
+	// Files lists the changed files, with the filters of the workflow they hit
+	Files    []FileExplanation `json:"files"`
+	Decision decision.Decision `json:"decision"`
+}
+
+type FileExplanation struct {
+	File    string   `json:"file"`
+	Filters []string `json:"filters"`
+}
+
+// Explain runs the decision logic of the config against a comment and changed files, detailing which
+// trigger regexes match the comment, and which filters of the triggered workflows the files hit.
+func (config *ArianeConfig) Explain(ctx context.Context, comment string, filenames []string) Explanation {
+	explanation := Explanation{Comment: comment}
+
+	regexes := make([]string, 0, len(config.Triggers))
+	for regex := range config.Triggers {
+		regexes = append(regexes, regex)
+	}
+	sort.Strings(regexes)
+	for _, regex := range regexes {
+		trigger := TriggerExplanation{Regex: regex}
+		if re, err := regexp.Compile(`^` + regex + `$`); err != nil {
+			trigger.Error = err.Error()
+		} else {
+			trigger.Submatch = re.FindStringSubmatch(comment)
+			trigger.Matched = trigger.Submatch != nil
+		}
+		explanation.Triggers = append(explanation.Triggers, trigger)
+	}
+
+	files := make([]*github.CommitFile, len(filenames))
+	for i, filename := range filenames {
+		files[i] = &github.CommitFile{Filename: github.String(filename)}
+	}
+
+	_, workflows, trigger := config.CheckForTrigger(ctx, comment)
+	explanation.Trigger = trigger
+	for _, workflow := range workflows {
+		workflowConfig, configured := config.Workflows[workflow]
+		explanation.Workflows = append(explanation.Workflows, WorkflowExplanation{
+			Workflow:         workflow,
+			Name:             config.DisplayName(workflow),
+			Configured:       configured,
+			PathsRegex:       workflowConfig.PathsRegex,
+			PathsIgnoreRegex: workflowConfig.PathsIgnoreRegex,
+			Files:            explainFiles(workflow, workflowConfig, filenames),
+			Decision:         config.ShouldRun(ctx, workflow, files),
+		})
+	}
+	return explanation
+}
+
+// explainFiles returns the filters of a workflow each file hits. Invalid regexes hit no files,
+// the decision of the workflow tells why.
+func explainFiles(workflow string, workflowConfig WorkflowPathsRegexConfig, filenames []string) []FileExplanation {
+	var re, reIgnore *regexp.Regexp
+	if workflowConfig.PathsRegex != "" {
+		re, _ = regexp.Compile(`^` + workflowConfig.PathsRegex)
+	}
+	if workflowConfig.PathsIgnoreRegex != "" {
+		reIgnore, _ = regexp.Compile(`^` + workflowConfig.PathsIgnoreRegex)
+	}
+
+	files := make([]FileExplanation, 0, len(filenames))
+	for _, filename := range filenames {
+		file := FileExplanation{File: filename, Filters: []string{}}
+		if filename == `.github/workflows/`+workflow {
+			file.Filters = append(file.Filters, filterWorkflowFile)
+		} else if strings.HasPrefix(filename, ".github/workflows") {
+			file.Filters = append(file.Filters, filterOtherWorkflow)
+		}
+		if re != nil && re.MatchString(filename) {
+			file.Filters = append(file.Filters, filterPathsRegex)
+		}
+		if reIgnore != nil && reIgnore.MatchString(filename) {
+			file.Filters = append(file.Filters, filterPathsIgnoreRegex)
+		}
+		files = append(files, file)
+	}
+	return files
+}
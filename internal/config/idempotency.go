@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// IdempotencyData is passed to the idempotency-key templates of workflows
+type IdempotencyData struct {
+	Workflow string
+	// Files are all the files changed by the PR, MatchedFiles the ones relevant to the workflow
+	// according to its paths filters, along with their blob SHA
+	Files        []IdempotencyFile
+	MatchedFiles []IdempotencyFile
+	// ExtraArgs and Args are the arguments of the trigger comment, if any
+	ExtraArgs string
+	Args      map[string]any
+}
+
+type IdempotencyFile struct {
+	Filename string
+	SHA      string
+}
+
+// IdempotencyKey renders the idempotency-key template of a workflow, and returns the hash of the result.
+// It returns an empty key if the workflow has no idempotency-key.
+func (config *ArianeConfig) IdempotencyKey(workflow string, files []*github.CommitFile, extraArgs string, args map[string]any) (string, error) {
+	workflowConfig := config.Workflows[workflow]
+	if workflowConfig.IdempotencyKey == "" {
+		return "", nil
+	}
+	tmpl, err := template.New("idempotency-key").Funcs(config.TemplateFuncs()).Parse(workflowConfig.IdempotencyKey)
+	if err != nil {
+		return "", err
+	}
+
+	data := IdempotencyData{Workflow: workflow, ExtraArgs: extraArgs, Args: args}
+	matches := matchFilesFunc(workflow, workflowConfig)
+	for _, file := range files {
+		idempotencyFile := IdempotencyFile{Filename: file.GetFilename(), SHA: file.GetSHA()}
+		data.Files = append(data.Files, idempotencyFile)
+		if matches(idempotencyFile.Filename) {
+			data.MatchedFiles = append(data.MatchedFiles, idempotencyFile)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// matchFilesFunc returns a function reporting whether a file is relevant to a workflow: its own workflow file,
+// files matching its paths-regex, or other files not matching its paths-ignore-regex
+func matchFilesFunc(workflow string, workflowConfig WorkflowPathsRegexConfig) func(string) bool {
+	var re, reIgnore *regexp.Regexp
+	if workflowConfig.PathsRegex != "" {
+		re, _ = regexp.Compile(`^` + workflowConfig.PathsRegex)
+	}
+	if workflowConfig.PathsIgnoreRegex != "" {
+		reIgnore, _ = regexp.Compile(`^` + workflowConfig.PathsIgnoreRegex)
+	}
+	return func(filename string) bool {
+		switch {
+		case filename == `.github/workflows/`+workflow:
+			return true
+		case strings.HasPrefix(filename, ".github/workflows"):
+			return false
+		case re != nil:
+			return re.MatchString(filename)
+		case reIgnore != nil:
+			return !reIgnore.MatchString(filename)
+		}
+		return true
+	}
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RepositorySettings are the server settings which can be overridden per repository
+type RepositorySettings struct {
+	DispatchVerifyTimeout time.Duration
+	IssueCommands         bool
+	// Handlers enables or disables the event handlers by event type, unlisted handlers are enabled
+	Handlers   map[string]bool
+	Pagination PaginationConfig
+}
+
+// HandlerEnabled reports whether the handler of an event type is enabled
+func (s RepositorySettings) HandlerEnabled(handler string) bool {
+	enabled, ok := s.Handlers[handler]
+	return !ok || enabled
+}
+
+// RepositoryOverrides overrides server settings for a repository. Unset fields keep the global values, and so do
+// the unset limits of Pagination and the unlisted Handlers.
+type RepositoryOverrides struct {
+	DispatchVerifyTimeout *time.Duration   `yaml:"dispatchVerifyTimeout"`
+	IssueCommands         *bool            `yaml:"issueCommands"`
+	Handlers              map[string]bool  `yaml:"handlers"`
+	Pagination            PaginationConfig `yaml:"pagination"`
+}
+
+// Overrides maps "owner/repo" to the settings overridden for the repository
+type Overrides map[string]RepositoryOverrides
+
+// For returns the settings of a repository, merging its overrides over the global settings.
+// Repository names are matched case-insensitively, as on GitHub.
+func (o Overrides) For(owner, repo string, global RepositorySettings) RepositorySettings {
+	overrides, ok := o.lookup(owner + "/" + repo)
+	if !ok {
+		return global
+	}
+	settings := global
+	if overrides.DispatchVerifyTimeout != nil {
+		settings.DispatchVerifyTimeout = *overrides.DispatchVerifyTimeout
+	}
+	if overrides.IssueCommands != nil {
+		settings.IssueCommands = *overrides.IssueCommands
+	}
+	if len(overrides.Handlers) > 0 {
+		settings.Handlers = make(map[string]bool, len(global.Handlers)+len(overrides.Handlers))
+		maps.Copy(settings.Handlers, global.Handlers)
+		maps.Copy(settings.Handlers, overrides.Handlers)
+	}
+	settings.Pagination = PaginationConfig{
+		PullRequests: global.Pagination.PullRequests.merge(overrides.Pagination.PullRequests),
+		Files:        global.Pagination.Files.merge(overrides.Pagination.Files),
+		WorkflowRuns: global.Pagination.WorkflowRuns.merge(overrides.Pagination.WorkflowRuns),
+	}
+	return settings
+}
+
+func (o Overrides) lookup(fullName string) (RepositoryOverrides, bool) {
+	if overrides, ok := o[fullName]; ok {
+		return overrides, true
+	}
+	for name, overrides := range o {
+		if strings.EqualFold(name, fullName) {
+			return overrides, true
+		}
+	}
+	return RepositoryOverrides{}, false
+}
+
+// merge returns the limit with the values set in override replacing its own
+func (l ListLimit) merge(override ListLimit) ListLimit {
+	if override.PerPage > 0 {
+		l.PerPage = override.PerPage
+	}
+	if override.MaxPages > 0 {
+		l.MaxPages = override.MaxPages
+	}
+	return l
+}
+
+// Validate checks the overrides are keyed by "owner/repo", returning all the mistakes joined
+func (o Overrides) Validate() error {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		owner, repo, ok := strings.Cut(name, "/")
+		if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+			errs = append(errs, fmt.Errorf("repositories: %q is not an owner/repo name", name))
+		}
+		if timeout := o[name].DispatchVerifyTimeout; timeout != nil && *timeout < 0 {
+			errs = append(errs, fmt.Errorf("repositories: %q: dispatchVerifyTimeout must not be negative", name))
+		}
+	}
+	return errors.Join(errs...)
+}
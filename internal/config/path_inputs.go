@@ -0,0 +1,42 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config
+
+import (
+	"regexp"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// PathInputsConfig sets inputs of the dispatches of a workflow when the PR changes files matching PathsRegex, e.g.
+// test-arm64: "true" for the changes under bpf/, so one workflow covers the variants the diff calls for
+type PathInputsConfig struct {
+	PathsRegex string            `yaml:"paths-regex"`
+	Inputs     map[string]string `yaml:"inputs"`
+}
+
+// PathInputs returns the inputs set by the path-inputs rules of a workflow matching any of the changed files, the
+// later rules overriding the inputs of the earlier ones. It returns nil if no rule matches.
+func (config *ArianeConfig) PathInputs(workflow string, files []*github.CommitFile) map[string]string {
+	var inputs map[string]string
+	for _, rule := range config.Workflows[workflow].PathInputs {
+		re, err := regexp.Compile(`^` + rule.PathsRegex)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !re.MatchString(file.GetFilename()) {
+				continue
+			}
+			if inputs == nil {
+				inputs = map[string]string{}
+			}
+			for input, value := range rule.Inputs {
+				inputs[input] = value
+			}
+			break
+		}
+	}
+	return inputs
+}
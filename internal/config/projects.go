@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// projectTriggerPrefix starts the trigger generated for each project, e.g. "/test cilium-cli"
+const projectTriggerPrefix = "/test "
+
+// ProjectConfig is a sub-project of a monorepo, made of the files under its path prefixes
+type ProjectConfig struct {
+	// Paths are the path prefixes of the files of the project, e.g. "cilium-cli/"
+	Paths []string `yaml:"paths"`
+}
+
+// ResolveProjects expands the projects referenced by workflows and triggers into the paths filters and workflows
+// they stand for, so the rest of the config handling does not know about projects:
+//   - workflows with projects run for the changes to the files under the paths of their projects,
+//   - triggers with projects also run the workflows of their projects,
+//   - each project with workflows gets a "/test <project>" trigger running them, unless already defined.
+//
+// It is called once, when the config is read from the repository.
+func (config *ArianeConfig) ResolveProjects() error {
+	var errs []error
+	names := make([]string, 0, len(config.Projects))
+	for name := range config.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if len(config.Projects[name].Paths) == 0 {
+			errs = append(errs, fmt.Errorf("project %q: no paths", name))
+		}
+	}
+
+	projectWorkflows := map[string][]string{}
+	workflows := make([]string, 0, len(config.Workflows))
+	for workflow := range config.Workflows {
+		workflows = append(workflows, workflow)
+	}
+	sort.Strings(workflows)
+	for _, workflow := range workflows {
+		workflowConfig := config.Workflows[workflow]
+		if len(workflowConfig.Projects) == 0 {
+			continue
+		}
+		if workflowConfig.PathsRegex != "" || workflowConfig.PathsIgnoreRegex != "" {
+			errs = append(errs, fmt.Errorf("workflow %q: projects and paths regexes are mutually exclusive", workflow))
+			continue
+		}
+		var prefixes []string
+		for _, project := range workflowConfig.Projects {
+			projectConfig, ok := config.Projects[project]
+			if !ok {
+				errs = append(errs, fmt.Errorf("workflow %q: unknown project %q", workflow, project))
+				continue
+			}
+			projectWorkflows[project] = append(projectWorkflows[project], workflow)
+			for _, path := range projectConfig.Paths {
+				prefixes = append(prefixes, regexp.QuoteMeta(path))
+			}
+		}
+		if len(prefixes) > 0 {
+			workflowConfig.PathsRegex = "(" + strings.Join(prefixes, "|") + ")"
+			config.Workflows[workflow] = workflowConfig
+		}
+	}
+
+	for regex, trigger := range config.Triggers {
+		for _, project := range trigger.Projects {
+			if _, ok := config.Projects[project]; !ok {
+				errs = append(errs, fmt.Errorf("trigger %q: unknown project %q", regex, project))
+				continue
+			}
+			for _, workflow := range projectWorkflows[project] {
+				if !slices.Contains(trigger.Workflows, workflow) {
+					trigger.Workflows = append(trigger.Workflows, workflow)
+				}
+			}
+		}
+		config.Triggers[regex] = trigger
+	}
+
+	for _, name := range names {
+		regex := regexp.QuoteMeta(projectTriggerPrefix + name)
+		if _, ok := config.Triggers[regex]; ok || len(projectWorkflows[name]) == 0 {
+			continue
+		}
+		if config.Triggers == nil {
+			config.Triggers = map[string]TriggerConfig{}
+		}
+		config.Triggers[regex] = TriggerConfig{Workflows: projectWorkflows[name], Projects: []string{name}}
+	}
+
+	return errors.Join(errs...)
+}
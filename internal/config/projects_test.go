@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package config_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_ResolveProjects(t *testing.T) {
+	var arianeConfig config.ArianeConfig
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+projects:
+  cli:
+    paths: [cilium-cli/, pkg/cli.go]
+  operator:
+    paths: [operator/]
+  docs:
+    paths: [Documentation/]
+triggers:
+  /test:
+    workflows: [lint.yaml]
+    projects: [cli, operator]
+  /test cli:
+    workflows: [lint.yaml]
+workflows:
+  cli-e2e.yaml:
+    projects: [cli]
+  operator-e2e.yaml:
+    projects: [operator]
+`), &arianeConfig))
+	assert.NoError(t, arianeConfig.ResolveProjects())
+	assert.NoError(t, arianeConfig.Validate())
+
+	assert.Equal(t, []string{"lint.yaml", "cli-e2e.yaml", "operator-e2e.yaml"}, arianeConfig.Triggers["/test"].Workflows, "triggers run the workflows of their projects")
+	assert.Equal(t, []string{"lint.yaml"}, arianeConfig.Triggers["/test cli"].Workflows, "defined triggers are not replaced")
+	assert.Equal(t, []string{"operator-e2e.yaml"}, arianeConfig.Triggers[`/test operator`].Workflows, "projects get a trigger")
+	_, ok := arianeConfig.Triggers["/test docs"]
+	assert.False(t, ok, "projects without workflows get no trigger")
+
+	files := func(names ...string) []*github.CommitFile {
+		var commitFiles []*github.CommitFile
+		for _, name := range names {
+			commitFiles = append(commitFiles, &github.CommitFile{Filename: github.String(name)})
+		}
+		return commitFiles
+	}
+	assert.True(t, arianeConfig.ShouldRun(context.Background(), "cli-e2e.yaml", files("cilium-cli/main.go")).Result)
+	assert.True(t, arianeConfig.ShouldRun(context.Background(), "cli-e2e.yaml", files("pkg/cli.go")).Result)
+	assert.False(t, arianeConfig.ShouldRun(context.Background(), "cli-e2e.yaml", files("pkg/cliXgo")).Result, "paths are not regexes")
+	assert.False(t, arianeConfig.ShouldRun(context.Background(), "cli-e2e.yaml", files("operator/main.go")).Result)
+
+	var invalid config.ArianeConfig
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+projects:
+  empty: {}
+triggers:
+  /test:
+    workflows: [lint.yaml]
+    projects: [missing]
+workflows:
+  e2e.yaml:
+    projects: [empty]
+    paths-regex: foo/
+  other.yaml:
+    projects: [missing]
+`), &invalid))
+	err := invalid.ResolveProjects()
+	assert.ErrorContains(t, err, `project "empty": no paths`)
+	assert.ErrorContains(t, err, `workflow "e2e.yaml": projects and paths regexes are mutually exclusive`)
+	assert.ErrorContains(t, err, `workflow "other.yaml": unknown project "missing"`)
+	assert.ErrorContains(t, err, `trigger "/test": unknown project "missing"`)
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
+)
+
+// Factory creates a client creator authenticating with the given app private key
+type Factory func(privateKey []byte) (githubapp.ClientCreator, error)
+
+// Validator checks that a client creator can authenticate as the app
+type Validator func(ctx context.Context, cc githubapp.ClientCreator) error
+
+// ValidateAppAuthentication retrieves the authenticated app, which fails if the private key is not registered for it.
+func ValidateAppAuthentication(ctx context.Context, cc githubapp.ClientCreator) error {
+	client, err := cc.NewAppClient()
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Apps.Get(ctx, "")
+	return err
+}
+
+// ReloadingClientCreator is a githubapp.ClientCreator using the first of several app private keys which
+// authenticates successfully. Keys are read from files, which are reloaded when they change, so the app
+// key can be rotated without restarting Ariane.
+type ReloadingClientCreator struct {
+	paths     []string
+	inline    []byte
+	factory   Factory
+	validator Validator
+	logger    zerolog.Logger
+
+	mu      sync.RWMutex
+	current githubapp.ClientCreator
+	// contents of the key files when they were last loaded
+	loaded [][]byte
+}
+
+// NewReloadingClientCreator loads the private keys from the given paths, falling back to the inline key,
+// and selects the first one which authenticates successfully.
+func NewReloadingClientCreator(ctx context.Context, paths []string, inline []byte, factory Factory, validator Validator, logger zerolog.Logger) (*ReloadingClientCreator, error) {
+	c := &ReloadingClientCreator{
+		paths:     paths,
+		inline:    inline,
+		factory:   factory,
+		validator: validator,
+		logger:    logger,
+	}
+	if err := c.reload(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ReloadingClientCreator) readKeys() ([][]byte, error) {
+	keys := make([][]byte, 0, len(c.paths))
+	for _, path := range c.paths {
+		key, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading private key %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// reload reads the keys, and switches to the first one which authenticates successfully
+func (c *ReloadingClientCreator) reload(ctx context.Context) error {
+	keys, err := c.readKeys()
+	if err != nil {
+		return err
+	}
+
+	candidates := keys
+	if len(c.inline) > 0 {
+		candidates = append(append([][]byte{}, keys...), c.inline)
+	}
+
+	var errs []error
+	for idx, key := range candidates {
+		cc, err := c.factory(key)
+		if err == nil {
+			err = c.validator(ctx, cc)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("private key %d: %w", idx, err))
+			continue
+		}
+
+		c.mu.Lock()
+		c.current = cc
+		c.loaded = keys
+		c.mu.Unlock()
+		c.logger.Info().Msgf("Using app private key %d", idx)
+		return nil
+	}
+	if len(errs) == 0 {
+		return errors.New("no app private key configured")
+	}
+	return fmt.Errorf("no valid app private key: %w", errors.Join(errs...))
+}
+
+// changed returns true if any key file changed since it was last loaded
+func (c *ReloadingClientCreator) changed() bool {
+	keys, err := c.readKeys()
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to read app private keys")
+		return false
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for idx, key := range keys {
+		if !bytes.Equal(key, c.loaded[idx]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch reloads the keys whenever a key file changes, until ctx is cancelled.
+// If none of the new keys is valid, the current one is kept.
+func (c *ReloadingClientCreator) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+			c.logger.Info().Msg("App private keys changed, reloading")
+			if err := c.reload(ctx); err != nil {
+				c.logger.Error().Err(err).Msg("Failed to reload app private keys, keeping the current one")
+			}
+		}
+	}
+}
+
+func (c *ReloadingClientCreator) get() githubapp.ClientCreator {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.current
+}
+
+func (c *ReloadingClientCreator) NewAppClient() (*github.Client, error) {
+	return c.get().NewAppClient()
+}
+
+func (c *ReloadingClientCreator) NewAppV4Client() (*githubv4.Client, error) {
+	return c.get().NewAppV4Client()
+}
+
+func (c *ReloadingClientCreator) NewInstallationClient(installationID int64) (*github.Client, error) {
+	return c.get().NewInstallationClient(installationID)
+}
+
+func (c *ReloadingClientCreator) NewInstallationV4Client(installationID int64) (*githubv4.Client, error) {
+	return c.get().NewInstallationV4Client(installationID)
+}
+
+func (c *ReloadingClientCreator) NewTokenClient(token string) (*github.Client, error) {
+	return c.get().NewTokenClient(token)
+}
+
+func (c *ReloadingClientCreator) NewTokenSourceClient(ts oauth2.TokenSource) (*github.Client, error) {
+	return c.get().NewTokenSourceClient(ts)
+}
+
+func (c *ReloadingClientCreator) NewTokenV4Client(token string) (*githubv4.Client, error) {
+	return c.get().NewTokenV4Client(token)
+}
+
+func (c *ReloadingClientCreator) NewTokenSourceV4Client(ts oauth2.TokenSource) (*githubv4.Client, error) {
+	return c.get().NewTokenSourceV4Client(ts)
+}
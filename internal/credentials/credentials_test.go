@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package credentials
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClientCreator remembers the key it was created with
+type fakeClientCreator struct {
+	githubapp.ClientCreator
+	key string
+}
+
+func fakeFactory(privateKey []byte) (githubapp.ClientCreator, error) {
+	return &fakeClientCreator{key: string(privateKey)}, nil
+}
+
+// fakeValidator only accepts keys prefixed with "valid"
+func fakeValidator(ctx context.Context, cc githubapp.ClientCreator) error {
+	if !strings.HasPrefix(cc.(*fakeClientCreator).key, "valid") {
+		return errors.New("401 Unauthorized")
+	}
+	return nil
+}
+
+func currentKey(c *ReloadingClientCreator) string {
+	return c.get().(*fakeClientCreator).key
+}
+
+func Test_ReloadingClientCreator(t *testing.T) {
+	dir := t.TempDir()
+	newKey := filepath.Join(dir, "new.pem")
+	oldKey := filepath.Join(dir, "old.pem")
+	assert.NoError(t, os.WriteFile(newKey, []byte("revoked"), 0o600))
+	assert.NoError(t, os.WriteFile(oldKey, []byte("valid-old"), 0o600))
+
+	c, err := NewReloadingClientCreator(context.Background(), []string{newKey, oldKey}, []byte("valid-inline"), fakeFactory, fakeValidator, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.Equal(t, "valid-old", currentKey(c), "the first key which authenticates is used")
+	assert.False(t, c.changed())
+
+	assert.NoError(t, os.WriteFile(newKey, []byte("valid-new"), 0o600))
+	assert.True(t, c.changed())
+	assert.NoError(t, c.reload(context.Background()))
+	assert.Equal(t, "valid-new", currentKey(c), "rotated keys are used once reloaded")
+
+	assert.NoError(t, os.WriteFile(newKey, []byte("revoked"), 0o600))
+	assert.NoError(t, os.WriteFile(oldKey, []byte("revoked"), 0o600))
+	assert.NoError(t, c.reload(context.Background()))
+	assert.Equal(t, "valid-inline", currentKey(c), "the inline key is used as fallback")
+
+	_, err = NewReloadingClientCreator(context.Background(), []string{newKey}, nil, fakeFactory, fakeValidator, zerolog.Nop())
+	assert.Error(t, err, "no key authenticates")
+}
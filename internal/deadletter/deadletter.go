@@ -0,0 +1,138 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("dead letter not found")
+
+// ErrDispatched is returned when requeueing a dead letter whose handling dispatched workflows, unless forced
+var ErrDispatched = errors.New("dead letter already dispatched workflows, which requeueing would dispatch again")
+
+// validID restricts entry IDs (GitHub delivery IDs) to characters safe to use as file names
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Entry is an event whose handling failed after all retries
+type Entry struct {
+	ID        string `json:"id"`
+	EventType string `json:"eventType"`
+	Payload   []byte `json:"payload,omitempty"`
+	Error     string `json:"error"`
+	// RequestID is the X-GitHub-Request-Id of the failed GitHub API call, if the error is one
+	RequestID string    `json:"requestId,omitempty"`
+	Attempts  int       `json:"attempts"`
+	FailedAt  time.Time `json:"failedAt"`
+	// Dispatched is set if handling the event dispatched, or re-ran, workflows before failing
+	Dispatched bool `json:"dispatched,omitempty"`
+}
+
+// Store keeps dead letters, persisted as one JSON file per entry in a directory.
+// If no directory is given, entries are only kept in memory.
+type Store struct {
+	mu      sync.Mutex
+	dir     string
+	entries map[string]Entry
+}
+
+// NewStore creates a store persisted in dir, loading the entries already present.
+func NewStore(dir string) (*Store, error) {
+	s := &Store{dir: dir, entries: map[string]Entry{}}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed creating dead letter directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		bytes, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed reading dead letter %s: %w", file, err)
+		}
+		var e Entry
+		if err := json.Unmarshal(bytes, &e); err != nil {
+			return nil, fmt.Errorf("failed parsing dead letter %s: %w", file, err)
+		}
+		s.entries[e.ID] = e
+	}
+	return s, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Add stores an entry, replacing any entry with the same ID.
+func (s *Store) Add(e Entry) error {
+	if !validID.MatchString(e.ID) {
+		return fmt.Errorf("invalid dead letter ID %q", e.ID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != "" {
+		bytes, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(s.path(e.ID), bytes, 0o640); err != nil {
+			return fmt.Errorf("failed writing dead letter: %w", err)
+		}
+	}
+	s.entries[e.ID] = e
+	return nil
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return e, nil
+}
+
+// List returns all entries, oldest failure first.
+func (s *Store) List() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].FailedAt.Before(entries[j].FailedAt) })
+	return entries
+}
+
+// Remove deletes the entry with the given ID.
+func (s *Store) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[id]; !ok {
+		return ErrNotFound
+	}
+	if s.dir != "" {
+		if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed removing dead letter: %w", err)
+		}
+	}
+	delete(s.entries, id)
+	return nil
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/archive"
+	"github.com/cilium/ariane/internal/failure"
+)
+
+// failingHandler fails the first `failures` times it handles an event, with err if set or else a transient GitHub
+// failure, after dispatching a workflow if dispatch is set
+type failingHandler struct {
+	failures int
+	err      error
+	dispatch bool
+	calls    int
+}
+
+func (h *failingHandler) Handles() []string {
+	return []string{"issue_comment"}
+}
+
+func (h *failingHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	h.calls++
+	if h.dispatch {
+		failure.Dispatched(ctx)
+	}
+	if h.calls <= h.failures {
+		if h.err != nil {
+			return h.err
+		}
+		return &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}}
+	}
+	return nil
+}
+
+func Test_Store(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.Add(Entry{ID: "delivery-1", EventType: "issue_comment", Payload: []byte(`{}`)}))
+	assert.Error(t, store.Add(Entry{ID: "../delivery"}), "IDs must be safe to use as file names")
+
+	// entries are loaded back from disk
+	store, err = NewStore(dir)
+	assert.NoError(t, err)
+	entry, err := store.Get("delivery-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{}`), entry.Payload)
+	assert.Len(t, store.List(), 1)
+
+	assert.NoError(t, store.Remove("delivery-1"))
+	assert.ErrorIs(t, store.Remove("delivery-1"), ErrNotFound)
+	store, err = NewStore(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, store.List())
+}
+
+func Test_Scheduler(t *testing.T) {
+	testCases := []struct {
+		Failures           int
+		ExpectedCalls      int
+		ExpectedDeadLetter bool
+		ExpectedArchived   bool
+		ExpectedReason     string
+	}{
+		{
+			Failures:       0,
+			ExpectedCalls:  1,
+			ExpectedReason: "the event succeeds on the first attempt, and is not archived.",
+		},
+		{
+			Failures:         2,
+			ExpectedCalls:    3,
+			ExpectedArchived: true,
+			ExpectedReason:   "the event succeeds on the last attempt, and is archived as it failed before.",
+		},
+		{
+			Failures:           3,
+			ExpectedCalls:      3,
+			ExpectedDeadLetter: true,
+			ExpectedArchived:   true,
+			ExpectedReason:     "the event fails on all attempts, and is recorded as dead letter.",
+		},
+	}
+
+	for idx, testCase := range testCases {
+		store, _ := NewStore("")
+		handler := &failingHandler{failures: testCase.Failures}
+		scheduler := NewScheduler(store, 3, time.Millisecond, handler)
+		scheduler.Archive, _ = archive.NewStore(t.TempDir(), time.Hour)
+
+		err := scheduler.Schedule(context.Background(), githubapp.Dispatch{Handler: handler, EventType: "issue_comment", DeliveryID: "delivery-1", Payload: []byte(`{}`)})
+		assert.Equal(t, testCase.ExpectedDeadLetter, err != nil, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCalls, handler.calls, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedDeadLetter, len(store.List()) == 1, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedArchived, len(scheduler.Archive.List()) == 1, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
+
+func Test_SchedulerCategories(t *testing.T) {
+	testCases := []struct {
+		Err                error
+		Dispatch           bool
+		ExpectedCalls      int
+		ExpectedDeadLetter bool
+		ExpectedReason     string
+	}{
+		{
+			Err:                &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}},
+			ExpectedCalls:      3,
+			ExpectedDeadLetter: true,
+			ExpectedReason:     "transient GitHub failures are retried, and recorded as dead letter.",
+		},
+		{
+			Err:                &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden}},
+			ExpectedCalls:      1,
+			ExpectedDeadLetter: true,
+			ExpectedReason:     "permission failures are not retried, but recorded as dead letter to alert on them.",
+		},
+		{
+			Err:                failure.Errorf(failure.ConfigError, "failed parsing configuration file"),
+			ExpectedCalls:      1,
+			ExpectedDeadLetter: false,
+			ExpectedReason:     "config errors are dropped.",
+		},
+		{
+			Err:                errors.New("failed"),
+			ExpectedCalls:      1,
+			ExpectedDeadLetter: true,
+			ExpectedReason:     "internal failures are not retried, but recorded as dead letter to alert on them.",
+		},
+		{
+			Err:                &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}},
+			Dispatch:           true,
+			ExpectedCalls:      1,
+			ExpectedDeadLetter: true,
+			ExpectedReason:     "transient GitHub failures are not retried once a workflow was dispatched, not to dispatch it twice.",
+		},
+	}
+	for idx, testCase := range testCases {
+		store, _ := NewStore("")
+		handler := &failingHandler{failures: 3, err: testCase.Err, dispatch: testCase.Dispatch}
+		scheduler := NewScheduler(store, 3, time.Millisecond, handler)
+		err := scheduler.Schedule(context.Background(), githubapp.Dispatch{Handler: handler, EventType: "issue_comment", DeliveryID: "delivery-1"})
+		assert.ErrorIs(t, err, testCase.Err, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCalls, handler.calls, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedDeadLetter, len(store.List()) == 1, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		if testCase.ExpectedDeadLetter {
+			entry, _ := store.Get("delivery-1")
+			assert.Equal(t, testCase.ExpectedCalls, entry.Attempts, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		}
+	}
+}
+
+func Test_SchedulerRequestID(t *testing.T) {
+	header := http.Header{}
+	header.Set(failure.RequestIDHeader, "CAFE:1234")
+	store, _ := NewStore("")
+	handler := &failingHandler{failures: 3, err: &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway, Header: header}}}
+	scheduler := NewScheduler(store, 2, time.Millisecond, handler)
+	scheduler.Archive, _ = archive.NewStore(t.TempDir(), time.Hour)
+
+	err := scheduler.Schedule(context.Background(), githubapp.Dispatch{Handler: handler, EventType: "issue_comment", DeliveryID: "delivery-1", Payload: []byte(`{}`)})
+	assert.Error(t, err)
+	entry, err := store.Get("delivery-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "CAFE:1234", entry.RequestID)
+	archived := scheduler.Archive.List()
+	assert.Len(t, archived, 1)
+	assert.Contains(t, archived[0].Errors[0], "X-GitHub-Request-Id: CAFE:1234")
+}
+
+func Test_Requeue(t *testing.T) {
+	store, _ := NewStore("")
+	handler := &failingHandler{failures: 4}
+	scheduler := NewScheduler(store, 3, time.Millisecond, handler)
+
+	assert.Error(t, scheduler.Schedule(context.Background(), githubapp.Dispatch{Handler: handler, EventType: "issue_comment", DeliveryID: "delivery-1"}))
+	assert.NoError(t, scheduler.Requeue(context.Background(), "delivery-1", false))
+	assert.Empty(t, store.List(), "requeued dead letters are removed once handled successfully")
+	assert.ErrorIs(t, scheduler.Requeue(context.Background(), "delivery-1", false), ErrNotFound)
+}
+
+func Test_RequeueDispatched(t *testing.T) {
+	store, _ := NewStore("")
+	handler := &failingHandler{failures: 1, dispatch: true}
+	scheduler := NewScheduler(store, 3, time.Millisecond, handler)
+
+	assert.Error(t, scheduler.Schedule(context.Background(), githubapp.Dispatch{Handler: handler, EventType: "issue_comment", DeliveryID: "delivery-1"}))
+	entry, err := store.Get("delivery-1")
+	assert.NoError(t, err)
+	assert.True(t, entry.Dispatched, "dead letters record that their handling dispatched workflows")
+
+	assert.ErrorIs(t, scheduler.Requeue(context.Background(), "delivery-1", false), ErrDispatched)
+	assert.Equal(t, 1, handler.calls, "dead letters which dispatched workflows are not requeued unless forced")
+	assert.NoError(t, scheduler.Requeue(context.Background(), "delivery-1", true))
+	assert.Equal(t, 2, handler.calls)
+	assert.Empty(t, store.List())
+}
+
+// blockingHandler blocks until its context is done
+type blockingHandler struct{}
+
+func (h *blockingHandler) Handles() []string {
+	return []string{"pull_request"}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func Test_SchedulerTimeout(t *testing.T) {
+	store, _ := NewStore("")
+	handler := &blockingHandler{}
+	scheduler := NewScheduler(store, 3, time.Millisecond, handler)
+	scheduler.Timeout = 10 * time.Millisecond
+
+	timeouts := eventsTotal.Value("pull_request", resultTimeout)
+	err := scheduler.Schedule(context.Background(), githubapp.Dispatch{Handler: handler, EventType: "pull_request", DeliveryID: "delivery-1"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, timeouts+1, eventsTotal.Value("pull_request", resultTimeout), "timeouts are counted distinctly")
+	assert.Len(t, store.List(), 1, "timed out events are recorded as dead letters")
+}
@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/archive"
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/metrics"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+const (
+	DefaultAttempts = 3
+	DefaultBackoff  = time.Second
+)
+
+// results of handling an event, used as metrics labels
+const (
+	resultHandled = "handled"
+	resultFailed  = "failed"
+	resultTimeout = "timeout"
+)
+
+var eventsTotal = metrics.NewCounterVec("ariane_events_total",
+	"Events handled, by event type and result (handled, failed or timeout).",
+	"event", "result")
+
+var failuresTotal = metrics.NewCounterVec("ariane_event_failures_total",
+	"Events whose handling failed after all attempts, by event type and failure category.",
+	"event", "category")
+
+// Scheduler is a githubapp.Scheduler handling events synchronously, retrying failed ones, and recording them in
+// the dead letter store once all attempts failed. Failures which cannot be fixed by handling the event again,
+// see failure.Category, are neither retried nor recorded.
+type Scheduler struct {
+	Store    *Store
+	Attempts int
+	// Backoff is the delay before the first retry, doubled on each subsequent retry
+	Backoff time.Duration
+	// Timeout is the overall deadline for handling an event, including retries, disabled if zero.
+	// It is propagated to the handlers, and to the work they spawn.
+	Timeout time.Duration
+	// Archive keeps the scrubbed payloads of events which failed at least once, disabled if nil
+	Archive *archive.Store
+	// Clock waits between retries, scheduler.RealClock if nil
+	Clock scheduler.Clock
+	// Load tracks the events being handled, disabled if nil
+	Load *load.Tracker
+
+	handlers map[string]githubapp.EventHandler
+}
+
+// NewScheduler creates a scheduler, registering the handlers used to requeue dead letters.
+func NewScheduler(store *Store, attempts int, backoff time.Duration, handlers ...githubapp.EventHandler) *Scheduler {
+	if attempts < 1 {
+		attempts = 1
+	}
+	s := &Scheduler{
+		Store:    store,
+		Attempts: attempts,
+		Backoff:  backoff,
+		handlers: map[string]githubapp.EventHandler{},
+	}
+	for _, handler := range handlers {
+		for _, event := range handler.Handles() {
+			s.handlers[event] = handler
+		}
+	}
+	return s
+}
+
+func (s *Scheduler) Schedule(ctx context.Context, d githubapp.Dispatch) error {
+	done := s.Load.Start()
+	attempts, dispatched, err := s.execute(ctx, d)
+	done()
+	if err == nil {
+		return nil
+	}
+
+	category := failure.CategoryOf(err)
+	failuresTotal.Inc(d.EventType, string(category))
+	requestID := failure.RequestID(err)
+	logger := zerolog.Ctx(ctx).With().Str("category", string(category)).Logger()
+	if requestID != "" {
+		logger = logger.With().Str(failure.LogKeyRequestID, requestID).Logger()
+	}
+	record := audit.Event(ctx, "event_failed").Str("category", string(category)).Int("attempts", attempts).Err(err)
+	if requestID != "" {
+		record = record.Str(failure.LogKeyRequestID, requestID)
+	}
+	record.Send()
+	if !category.Retryable() && !category.Alert() {
+		logger.Warn().Err(err).Msg("Event failed, dropped as handling it again cannot succeed")
+		return err
+	}
+
+	entry := Entry{
+		ID:         d.DeliveryID,
+		EventType:  d.EventType,
+		Payload:    d.Payload,
+		Error:      err.Error(),
+		RequestID:  requestID,
+		Attempts:   attempts,
+		FailedAt:   time.Now(),
+		Dispatched: dispatched,
+	}
+	if storeErr := s.Store.Add(entry); storeErr != nil {
+		logger.Error().Err(storeErr).Msg("Failed to record dead letter")
+	} else if category.Alert() {
+		logger.Error().Err(err).Msgf("Event failed after %d attempts, recorded as dead letter", attempts)
+	} else {
+		logger.Warn().Err(err).Msgf("Event failed after %d attempts, recorded as dead letter", attempts)
+	}
+	return err
+}
+
+// execute runs the dispatch within the timeout, retrying with an exponential backoff until it succeeds
+// or all attempts failed, and returns the number of failed attempts, and whether they dispatched workflows
+func (s *Scheduler) execute(ctx context.Context, d githubapp.Dispatch) (int, bool, error) {
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+		defer cancel()
+	}
+
+	failures, dispatched, err := s.retry(ctx, d)
+	if len(failures) > 0 && s.Archive != nil {
+		if archiveErr := s.Archive.Add(d.DeliveryID, d.EventType, d.Payload, failures); archiveErr != nil {
+			zerolog.Ctx(ctx).Error().Err(archiveErr).Msg("Failed to archive event payload")
+		}
+	}
+	switch {
+	case err == nil:
+		eventsTotal.Inc(d.EventType, resultHandled)
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		eventsTotal.Inc(d.EventType, resultTimeout)
+		zerolog.Ctx(ctx).Warn().Err(err).Msgf("Event handling timed out after %s", s.Timeout)
+	default:
+		eventsTotal.Inc(d.EventType, resultFailed)
+	}
+	return len(failures), dispatched, err
+}
+
+// retry returns the errors of the failed attempts, along with the error of the last one, and whether the last one
+// dispatched workflows. Only the attempts which failed before dispatching any workflow are retried, as handling the
+// event again would dispatch them twice.
+func (s *Scheduler) retry(ctx context.Context, d githubapp.Dispatch) ([]string, bool, error) {
+	backoff := scheduler.Backoff{Initial: s.Backoff, Attempts: s.Attempts}
+	var failures []string
+	var lastDispatched bool
+	err := scheduler.Retry(ctx, s.Clock, backoff, func(ctx context.Context, attempt int) error {
+		ctx, dispatched := failure.TrackDispatches(ctx)
+		err := d.Execute(ctx)
+		if err == nil {
+			return nil
+		}
+		failures = append(failures, describeFailure(err))
+		lastDispatched = dispatched()
+		if !failure.CategoryOf(err).Retryable() {
+			return scheduler.Stop(err)
+		}
+		if lastDispatched {
+			zerolog.Ctx(ctx).Debug().Err(err).Msg("Event handling failed after dispatching workflows, not retrying")
+			return scheduler.Stop(err)
+		}
+		if attempt < s.Attempts {
+			zerolog.Ctx(ctx).Debug().Err(err).Msgf("Event handling failed (attempt %d/%d), retrying in %s", attempt, s.Attempts, backoff.Delay(attempt))
+		}
+		return err
+	})
+	return failures, lastDispatched, err
+}
+
+// describeFailure describes a failed attempt, with the ID of the failed GitHub API call, if any, for escalations
+func describeFailure(err error) string {
+	if requestID := failure.RequestID(err); requestID != "" {
+		return fmt.Sprintf("%s (%s: %s)", err, failure.RequestIDHeader, requestID)
+	}
+	return err.Error()
+}
+
+// Requeue handles a dead letter again, removing it from the store if it succeeds. Dead letters which dispatched
+// workflows are refused with ErrDispatched unless force is set, as their workflows would be dispatched again. ctx
+// should not be cancelled with the request asking for it, or the handling would be abandoned halfway if the client
+// went away.
+func (s *Scheduler) Requeue(ctx context.Context, id string, force bool) error {
+	entry, err := s.Store.Get(id)
+	if err != nil {
+		return err
+	}
+	if entry.Dispatched && !force {
+		return ErrDispatched
+	}
+	handler, ok := s.handlers[entry.EventType]
+	if !ok {
+		return fmt.Errorf("no handler registered for event %q", entry.EventType)
+	}
+
+	d := githubapp.Dispatch{
+		Handler:    handler,
+		EventType:  entry.EventType,
+		DeliveryID: entry.ID,
+		Payload:    entry.Payload,
+	}
+	if attempts, dispatched, err := s.execute(ctx, d); err != nil {
+		entry.Error = err.Error()
+		entry.Dispatched = entry.Dispatched || dispatched
+		entry.RequestID = failure.RequestID(err)
+		entry.Attempts += attempts
+		entry.FailedAt = time.Now()
+		if storeErr := s.Store.Add(entry); storeErr != nil {
+			zerolog.Ctx(ctx).Error().Err(storeErr).Msg("Failed to update dead letter")
+		}
+		return err
+	}
+	return s.Store.Remove(id)
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Case is an input of the decision logic, a config with a comment, the changed files and the previous runs, along
+// with the plan it gave when recorded. Cases are stored as JSON in a corpus, and replayed to guard refactors of the
+// decision logic.
+type Case struct {
+	Config  Config   `json:"config"`
+	Comment string   `json:"comment"`
+	Files   []string `json:"files"`
+	Runs    RunState `json:"runs"`
+	Plan    Plan     `json:"plan"`
+}
+
+// NewCase evaluates the decision logic against its inputs, and records them along with the plan
+func NewCase(config Config, comment string, files []string, runs RunState) Case {
+	return Case{Config: config, Comment: comment, Files: files, Runs: runs, Plan: Evaluate(config, comment, files, runs)}
+}
+
+// ReadCase reads a case from a JSON file of a corpus
+func ReadCase(path string) (Case, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Case{}, err
+	}
+	var c Case
+	if err := json.Unmarshal(content, &c); err != nil {
+		return Case{}, fmt.Errorf("failed parsing case %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Replay evaluates the inputs of the case again, and returns how the plan differs from the recorded one. Plans are
+// compared on their results, reasons and actions, so that rewording the messages does not break recorded cases.
+func (c Case) Replay() []string {
+	recorded, replayed := outcomes(c.Plan), outcomes(Evaluate(c.Config, c.Comment, c.Files, c.Runs))
+	var diffs []string
+	for i := 0; i < max(len(recorded), len(replayed)); i++ {
+		switch {
+		case i >= len(replayed):
+			diffs = append(diffs, fmt.Sprintf("recorded %s, missing now", recorded[i]))
+		case i >= len(recorded):
+			diffs = append(diffs, fmt.Sprintf("not recorded, now %s", replayed[i]))
+		case recorded[i] != replayed[i]:
+			diffs = append(diffs, fmt.Sprintf("recorded %s, now %s", recorded[i], replayed[i]))
+		}
+	}
+	return diffs
+}
+
+// outcomes lists the results, reasons and actions of a plan, in order
+func outcomes(plan Plan) []string {
+	list := []string{fmt.Sprintf("trigger %q: %t (%s)", plan.Regex, plan.Trigger.Result, plan.Trigger.Reason)}
+	for _, workflow := range plan.Workflows {
+		outcome := fmt.Sprintf("%s: %s, skip %t (%s)", workflow.Workflow, workflow.Action, workflow.Skip.Result, workflow.Skip.Reason)
+		if workflow.Run != nil {
+			outcome += fmt.Sprintf(", run %t (%s)", workflow.Run.Result, workflow.Run.Reason)
+		}
+		list = append(list, outcome)
+	}
+	return list
+}
@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package decision_test
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// Test_Corpus replays the recorded cases of testdata/corpus, see decision.Case
+func Test_Corpus(t *testing.T) {
+	paths, err := filepath.Glob("testdata/corpus/*.json")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, paths)
+	for _, path := range paths {
+		c, err := decision.ReadCase(path)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Empty(t, c.Replay(), "%s: the plan differs from the recorded one", path)
+	}
+
+	// replays detect the changes of reasons and actions
+	c, err := decision.ReadCase("testdata/corpus/docs-only.json")
+	assert.NoError(t, err)
+	c.Plan.Workflows[2].Action = decision.ActionSkip
+	assert.Equal(t, []string{"recorded baz.yaml: skip, skip false (no_previous_run), run true (non_workflow_changes), now baz.yaml: dispatch, skip false (no_previous_run), run true (non_workflow_changes)"}, c.Replay())
+}
+
+// FuzzEvaluate checks properties of the decision logic which must hold whatever the config, comment and changed files:
+// plans are deterministic, triggered workflows are all planned, and whether a workflow runs does not depend on the
+// order of the files and never turns off as more files change.
+func FuzzEvaluate(f *testing.F) {
+	f.Add("foo/", "docs/", "/test", "foo/main.go\ndocs/README.md")
+	f.Add("(foo|common)/", `.*\.md$`, "/test", ".github/workflows/foo.yaml\n.github/workflows/other.yaml")
+	f.Add("foo/(", "", "/test-foo", "README.md")
+	f.Add("", "", "/test", "")
+	f.Fuzz(func(t *testing.T, pathsRegex, pathsIgnoreRegex, comment, changes string) {
+		config := decision.Config{
+			Triggers: map[string][]string{
+				"/test":          {"foo.yaml", "bar.yaml", "baz.yaml", "both.yaml"},
+				"/test-(\\S+)":   {"foo.yaml"},
+				"/test-(\\S+)-.": {"bar.yaml"},
+			},
+			Workflows: map[string]decision.PathsFilters{
+				"foo.yaml":  {PathsRegex: pathsRegex},
+				"bar.yaml":  {PathsIgnoreRegex: pathsIgnoreRegex},
+				"both.yaml": {PathsRegex: pathsRegex, PathsIgnoreRegex: pathsIgnoreRegex},
+			},
+		}
+		var files []string
+		if changes != "" {
+			files = strings.Split(changes, "\n")
+		}
+
+		plan := decision.Evaluate(config, comment, files, decision.RunState{})
+		assert.Equal(t, plan, decision.Evaluate(config, comment, files, decision.RunState{}), "plans are deterministic")
+		if !plan.Trigger.Result {
+			assert.Empty(t, plan.Workflows, "no workflow is planned without a trigger")
+			return
+		}
+		assert.Len(t, plan.Workflows, len(config.Triggers[plan.Regex]), "every workflow of the trigger is planned")
+
+		reversed := slices.Clone(files)
+		slices.Reverse(reversed)
+		for _, workflow := range plan.Workflows {
+			// without previous runs, workflows run exactly when the paths filters match the changes
+			if assert.NotNil(t, workflow.Run, workflow.Workflow) {
+				assert.Equal(t, workflow.Run.Result, workflow.Action == decision.ActionDispatch, workflow.Workflow)
+			}
+			run := decision.ShouldRun(config, workflow.Workflow, files)
+			assert.Equal(t, run.Result, decision.ShouldRun(config, workflow.Workflow, reversed).Result, "%s: the order of the files does not matter", workflow.Workflow)
+			for i := range files {
+				if decision.ShouldRun(config, workflow.Workflow, files[:i]).Result {
+					assert.True(t, run.Result, "%s: running for %q, but not for %q", workflow.Workflow, files[:i], files)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package decision defines the outcome of each step deciding whether, and which, workflows Ariane runs.
+package decision
+
+import (
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// Reason is a machine-readable code explaining a decision, stable to be used in audit records and metrics labels
+type Reason string
+
+const (
+	// MatchTrigger
+	ReasonTriggerMatched   Reason = "trigger_matched"
+	ReasonNoTriggerMatched Reason = "no_trigger_matched"
+
+	// isAllowedTeamMember
+	ReasonNoAllowedTeams          Reason = "no_allowed_teams"
+	ReasonTeamMember              Reason = "team_member"
+	ReasonChildTeamMember         Reason = "child_team_member"
+	ReasonNotTeamMember           Reason = "not_team_member"
+	ReasonMembershipLookupFailure Reason = "membership_lookup_failure"
+
+	// isPriorContributor
+	ReasonPriorContributor         Reason = "prior_contributor"
+	ReasonFirstTimeContributor     Reason = "first_time_contributor"
+	ReasonContributorLookupFailure Reason = "contributor_lookup_failure"
+
+	// checkDeprecation
+	ReasonTriggerCurrent    Reason = "trigger_current"
+	ReasonTriggerDeprecated Reason = "trigger_deprecated"
+	ReasonTriggerRetired    Reason = "trigger_retired"
+
+	// checkSecondApproval
+	ReasonSecondApprovalGiven       Reason = "second_approval_given"
+	ReasonSecondApprovalRequired    Reason = "second_approval_required"
+	ReasonSecondApprovalUnavailable Reason = "second_approval_unavailable"
+
+	// resolveTag
+	ReasonTagFound         Reason = "tag_found"
+	ReasonTagNotFound      Reason = "tag_not_found"
+	ReasonTagLookupFailure Reason = "tag_lookup_failure"
+
+	// checkContextOverride
+	ReasonContextOverridden         Reason = "context_overridden"
+	ReasonContextOverrideNotAllowed Reason = "context_override_not_allowed"
+	ReasonContextRefNotFound        Reason = "context_ref_not_found"
+	ReasonContextRefLookupFailure   Reason = "context_ref_lookup_failure"
+
+	// checkMergeability
+	ReasonMergeable                 Reason = "mergeable"
+	ReasonMergeabilityUnknown       Reason = "mergeability_unknown"
+	ReasonMergeabilityLookupFailure Reason = "mergeability_lookup_failure"
+	ReasonMergeConflict             Reason = "merge_conflict"
+	ReasonTooFarBehind              Reason = "too_far_behind"
+
+	// checkRetries
+	ReasonRetryAllowed Reason = "retry_allowed"
+	ReasonRetryLimited Reason = "retry_limited"
+
+	// checkIdempotency
+	ReasonNoIdempotentDispatch      Reason = "no_idempotent_dispatch"
+	ReasonIdempotentRunSucceeded    Reason = "idempotent_run_succeeded"
+	ReasonIdempotentRunNotSucceeded Reason = "idempotent_run_not_succeeded"
+
+	// PreviousRun / shouldSkipWorkflow
+	ReasonPreviousRunSucceeded Reason = "previous_run_succeeded"
+	ReasonPreviousRunFailed    Reason = "previous_run_failed"
+	ReasonPreviousRunRerun     Reason = "previous_run_rerun"
+	ReasonPreviousRunPending   Reason = "previous_run_pending"
+	ReasonNoPreviousRun        Reason = "no_previous_run"
+	ReasonRunLookupFailure     Reason = "run_lookup_failure"
+
+	// ShouldRunWorkflow / ShouldRunOnlyWorkflows
+	ReasonNoChanges               Reason = "no_changes"
+	ReasonWorkflowChanged         Reason = "workflow_changed"
+	ReasonOnlyOtherWorkflows      Reason = "only_other_workflows_changed"
+	ReasonNonWorkflowChanges      Reason = "non_workflow_changes"
+	ReasonWorkflowNotConfigured   Reason = "workflow_not_configured"
+	ReasonConflictingPathsFilters Reason = "conflicting_paths_filters"
+	ReasonInvalidPathsRegex       Reason = "invalid_paths_regex"
+	ReasonPathsMatched            Reason = "paths_matched"
+	ReasonPathsNotMatched         Reason = "paths_not_matched"
+	ReasonAllPathsIgnored         Reason = "all_paths_ignored"
+	ReasonPathsNotIgnored         Reason = "paths_not_ignored"
+	ReasonTagTrigger              Reason = "tag_trigger"
+	ReasonIssueTrigger            Reason = "issue_trigger"
+
+	// ArianeConfig.filesWithStatuses
+	ReasonStatusesNotMatched Reason = "statuses_not_matched"
+
+	// ParseArgs
+	ReasonNoArgs      Reason = "no_args"
+	ReasonArgsValid   Reason = "args_valid"
+	ReasonInvalidArgs Reason = "invalid_args"
+
+	// validateDispatchInputs
+	ReasonInputsValid    Reason = "inputs_valid"
+	ReasonTooManyInputs  Reason = "too_many_inputs"
+	ReasonInputsTooLarge Reason = "inputs_too_large"
+
+	// burstBatch.coalesce
+	ReasonCoalesced    Reason = "coalesced"
+	ReasonNotCoalesced Reason = "not_coalesced"
+
+	// checkPaused
+	ReasonNotPaused Reason = "not_paused"
+	ReasonPaused    Reason = "paused"
+
+	// declaredInputs
+	ReasonInputsDeclared          Reason = "inputs_declared"
+	ReasonUndeclaredInputsDropped Reason = "undeclared_inputs_dropped"
+	ReasonUndeclaredInputs        Reason = "undeclared_inputs"
+
+	// checkLargePR
+	ReasonNotLargePR Reason = "not_large_pr"
+	ReasonLargePR    Reason = "large_pr"
+
+	// checkPolicy
+	ReasonNoPolicy       Reason = "no_policy"
+	ReasonPolicyAllowed  Reason = "policy_allowed"
+	ReasonPolicyDenied   Reason = "policy_denied"
+	ReasonPolicyExcluded Reason = "policy_excluded"
+
+	// checkHead
+	ReasonHeadCurrent Reason = "head_current"
+	ReasonHeadMoved   Reason = "head_moved"
+
+	// checkPathsFilterOverride
+	ReasonPathsFilterIgnored        Reason = "paths_filter_ignored"
+	ReasonPathsFilterOverrideDenied Reason = "paths_filter_override_denied"
+
+	// dispatchWorkflow
+	ReasonWorkflowNotFound Reason = "workflow_not_found"
+)
+
+// Decision is the outcome of one step of the decision logic. Result is the answer to the question
+// the step asks (e.g. "should the workflow run?"), Reason explains it, and Message details it for humans.
+type Decision struct {
+	Result  bool   `json:"result"`
+	Reason  Reason `json:"reason"`
+	Message string `json:"message"`
+}
+
+// Yes returns a positive decision
+func Yes(reason Reason, format string, args ...any) Decision {
+	return Decision{Result: true, Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+// No returns a negative decision
+func No(reason Reason, format string, args ...any) Decision {
+	return Decision{Result: false, Reason: reason, Message: fmt.Sprintf(format, args...)}
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%t (%s): %s", d.Result, d.Reason, d.Message)
+}
+
+// MarshalZerologObject allows logging a decision with zerolog.Event.Object
+func (d Decision) MarshalZerologObject(e *zerolog.Event) {
+	e.Bool("result", d.Result).Str("reason", string(d.Reason)).Str("message", d.Message)
+}
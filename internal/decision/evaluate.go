@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package decision
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// rerunMaxAge is how long after their creation GitHub allows workflow runs to be re-run
+const rerunMaxAge = 30 * 24 * time.Hour
+
+// actions planned for a workflow, see WorkflowPlan
+const (
+	ActionDispatch = "dispatch"
+	ActionRerun    = "rerun"
+	ActionSkip     = "skip"
+)
+
+// Config is the part of a repository config the decision logic depends on
+type Config struct {
+	// Triggers maps trigger regexes to the workflows they run
+	Triggers map[string][]string `json:"triggers"`
+	// Workflows holds the paths filters of the workflows defined in the "workflows" section
+	Workflows map[string]PathsFilters `json:"workflows,omitempty"`
+}
+
+type PathsFilters struct {
+	PathsRegex       string `json:"pathsRegex,omitempty"`
+	PathsIgnoreRegex string `json:"pathsIgnoreRegex,omitempty"`
+}
+
+// RunState holds the runs of workflows for the head SHA of a PR, as listed by GitHub, newest first.
+// Now is the time the runs are looked at, deciding whether failed runs can still be re-run.
+type RunState struct {
+	SHA  string                           `json:"sha,omitempty"`
+	Runs map[string][]*github.WorkflowRun `json:"runs,omitempty"`
+	Now  time.Time                        `json:"now"`
+}
+
+// Plan is the outcome of Evaluate: the trigger the comment matches, and what to do with each of its workflows
+type Plan struct {
+	Trigger   Decision       `json:"trigger"`
+	Regex     string         `json:"regex,omitempty"`
+	Submatch  []string       `json:"submatch,omitempty"`
+	Workflows []WorkflowPlan `json:"workflows,omitempty"`
+}
+
+type WorkflowPlan struct {
+	Workflow string `json:"workflow"`
+	// Skip decides whether previous runs make dispatching the workflow unnecessary
+	Skip Decision `json:"skip"`
+	// Run decides whether the paths filters of the workflow match the changed files, if it is not skipped
+	Run    *Decision `json:"run,omitempty"`
+	Action string    `json:"action"`
+}
+
+// Evaluate runs the decision logic against a comment, the files changed by a PR, and the previous runs of
+// its workflows. It has no side effects: the same arguments always give the same plan.
+func Evaluate(config Config, comment string, files []string, runs RunState) Plan {
+	regex, submatch, trigger := MatchTrigger(config, comment)
+	plan := Plan{Trigger: trigger, Regex: regex, Submatch: submatch}
+	if !trigger.Result {
+		return plan
+	}
+
+	for _, workflow := range config.Triggers[regex] {
+		run, skip := PreviousRun(workflow, runs.SHA, runs.Runs[workflow])
+		workflowPlan := WorkflowPlan{Workflow: workflow, Skip: skip}
+		switch {
+		case skip.Result:
+			workflowPlan.Action = ActionSkip
+		case skip.Reason == ReasonPreviousRunFailed && CanRerun(run, runs.Now):
+			workflowPlan.Skip = Rerun(workflow, runs.SHA, run)
+			workflowPlan.Action = ActionRerun
+		default:
+			shouldRun := ShouldRun(config, workflow, files)
+			workflowPlan.Run = &shouldRun
+			workflowPlan.Action = ActionSkip
+			if shouldRun.Result {
+				workflowPlan.Action = ActionDispatch
+			}
+		}
+		plan.Workflows = append(plan.Workflows, workflowPlan)
+	}
+	return plan
+}
+
+// MatchTrigger returns the first trigger regex, in sorted order, matching the comment along with the submatch.
+// Invalid regexes never match, the config validation reports them.
+func MatchTrigger(config Config, comment string) (string, []string, Decision) {
+	regexes := make([]string, 0, len(config.Triggers))
+	for regex := range config.Triggers {
+		regexes = append(regexes, regex)
+	}
+	sort.Strings(regexes)
+	for _, regex := range regexes {
+		re, err := regexp.Compile(`^` + regex + `$`)
+		if err != nil {
+			continue
+		}
+		if submatch := re.FindStringSubmatch(comment); submatch != nil {
+			return regex, submatch, Yes(ReasonTriggerMatched, "comment matches trigger %q", regex)
+		}
+	}
+	return "", nil, No(ReasonNoTriggerMatched, "comment does not match any trigger")
+}
+
+// ShouldRun checks whether a workflow should run for the given files, using its paths filters
+// if the workflow is defined in the "workflows" section, or the workflows-only heuristic otherwise.
+func ShouldRun(config Config, workflow string, files []string) Decision {
+	if _, ok := config.Workflows[workflow]; ok {
+		return ShouldRunWorkflow(config, workflow, files)
+	}
+	// Runs this if the "workflows" section in ariane-config.yaml
+	// does not contain the worfklow (e.g. foo.yaml)
+	return ShouldRunOnlyWorkflows(workflow, files)
+}
+
+// ShouldRunOnlyWorkflows checks given changed files against .github/workflow pattern
+// Return false if only workflow files changed and the current workflow file is not changed
+// Return true otherwise
+func ShouldRunOnlyWorkflows(workflow string, files []string) Decision {
+	// Skip the workflow if the committed changes are only for
+	// .github/workflows/* and they do not affect the given workflow
+	for _, filename := range files {
+		if filename == `.github/workflows/`+workflow {
+			return Yes(ReasonWorkflowChanged, "workflow %s changed", workflow)
+		}
+		if !strings.HasPrefix(filename, ".github/workflows") {
+			return Yes(ReasonNonWorkflowChanges, "%s changed outside of .github/workflows", filename)
+		}
+	}
+	if len(files) == 0 {
+		return No(ReasonNoChanges, "no files changed")
+	}
+	return No(ReasonOnlyOtherWorkflows, "only other workflows than %s changed", workflow)
+}
+
+// ShouldRunWorkflow compares given list of files against a workflow's PathsRegex / PathsIgnoreRegex and workflow's filename.
+// Return true if any file matches .github/workflows/{workflow} OR .if any file matches PathsRegex
+// OR if any file does NOT match PathsIgnoreRegex AND does NOT have .github/workflow prefix
+// Return false otherwise.
+func ShouldRunWorkflow(config Config, workflow string, files []string) Decision {
+	// No new commits, skip re-running workflows
+	if len(files) == 0 {
+		return No(ReasonNoChanges, "no files changed")
+	}
+
+	filters, exists := config.Workflows[workflow]
+	// No workflow definition for the triggered workflow by a command
+	// 	- /command is expected to trigger one or more workflows
+	//	- these workflows are expected to be defined under the "workflows:" section
+	if !exists {
+		return No(ReasonWorkflowNotConfigured, "workflow %s is not defined in the workflows section", workflow)
+	}
+
+	// PathsRegex and PathsIgnoreRegex are both defined - this is UNSUPPORTED!!
+	// default to run the workflow no matter what
+	if filters.PathsRegex != "" && filters.PathsIgnoreRegex != "" {
+		return Yes(ReasonConflictingPathsFilters, "workflow %s defines both paths-regex and paths-ignore-regex, running it unconditionally", workflow)
+	}
+
+	var re, reIgnore *regexp.Regexp
+	var err error
+
+	if filters.PathsRegex != "" {
+		if re, err = regexp.Compile(`^` + filters.PathsRegex); err != nil {
+			return No(ReasonInvalidPathsRegex, "cannot compile paths-regex %q of workflow %s: %v", filters.PathsRegex, workflow, err)
+		}
+	}
+	if filters.PathsIgnoreRegex != "" {
+		if reIgnore, err = regexp.Compile(`^` + filters.PathsIgnoreRegex); err != nil {
+			return No(ReasonInvalidPathsRegex, "cannot compile paths-ignore-regex %q of workflow %s: %v", filters.PathsIgnoreRegex, workflow, err)
+		}
+	}
+
+	numberIgnoredFiles := 0
+	for _, filename := range files {
+		// Run the workflow if:
+		//	Any file under .github/workflows has changed (including the WF itself)
+		// 	PathsRegex has a match
+		// Note: .github/workflows contains env-vars, dependent workflows (e.g. workflow_call),
+		// and other files which may be relevant to the current workflow
+		// TODO: Add intelligence to the "workflows" section of Ariane config to determine dependencies
+		// (common ones [env-vars] + specific of the workflow [dependent WF])
+		// if strings.HasPrefix(filename, ".github/workflows") || re.MatchString(filename) {
+		// 	return true
+		// }
+
+		// Alternatively, only run the workflow if:
+		//	The workflow file has been updated
+		//	PathsRegex has a match
+		if filename == `.github/workflows/`+workflow {
+			return Yes(ReasonWorkflowChanged, "workflow %s changed", workflow)
+		} else if re != nil && re.MatchString(filename) {
+			return Yes(ReasonPathsMatched, "%s matches paths-regex %q", filename, filters.PathsRegex)
+		} else if strings.HasPrefix(filename, ".github/workflows") {
+			// A change on a different workflow (e.g. bar.yaml) does not qualify to re-run
+			// the one we are validating (e.g. foo.yaml)
+			numberIgnoredFiles += 1
+			continue
+		}
+
+		// Flag any finding within PathsIgnoreRegex
+		if reIgnore != nil && reIgnore.MatchString(filename) {
+			numberIgnoredFiles += 1
+		}
+	}
+
+	// the workflow (e.g. foo.yaml) does not change
+	// PathsRegex exists (no match, or we would have returned immediately),
+	// PathIgnoreRegex does not exist
+	// expectation: the workflow (e.g. foo.yaml) should not run
+	if re != nil && reIgnore == nil {
+		return No(ReasonPathsNotMatched, "no changed file matches paths-regex %q", filters.PathsRegex)
+	}
+
+	// At this point, we know there are files committed. If all the files match
+	// PathsIgnoreRegex (numberIgnoredFiles == len(files)) or other workflows than,
+	// the one we are evaluating, then do not run the WF
+	// Otherwise, do run it
+	if numberIgnoredFiles < len(files) {
+		return Yes(ReasonPathsNotIgnored, "%d of %d changed files are not ignored", len(files)-numberIgnoredFiles, len(files))
+	}
+	return No(ReasonAllPathsIgnored, "all changed files are ignored by paths-ignore-regex %q or are other workflows", filters.PathsIgnoreRegex)
+}
+
+// PreviousRun decides whether a workflow already ran for a SHA, given its runs listed newest first. It looks at
+// the run with the newest attempt rather than the last created one, as failed runs may have been re-run since.
+// The run is returned along with the decision, if any.
+func PreviousRun(workflow, SHA string, runs []*github.WorkflowRun) (*github.WorkflowRun, Decision) {
+	if len(runs) == 0 {
+		return nil, No(ReasonNoPreviousRun, "workflow %s did not run for %s yet", workflow, SHA)
+	}
+
+	// an attempt in progress, whether of a new run or a re-run, will report on its own
+	var newest *github.WorkflowRun
+	for _, run := range runs {
+		if run.GetStatus() != "completed" {
+			return run, Yes(ReasonPreviousRunPending, "attempt %d of run %d of workflow %s for %s is %s", run.GetRunAttempt(), run.GetID(), workflow, SHA, run.GetStatus())
+		}
+		if newest == nil || runStartedAt(run).After(runStartedAt(newest)) {
+			newest = run
+		}
+	}
+
+	conc := newest.GetConclusion()
+	if conc == "success" || conc == "skipped" {
+		return newest, Yes(ReasonPreviousRunSucceeded, "workflow %s completed with conclusion %s for %s, and there are no changes since the last run", workflow, conc, SHA)
+	}
+	return newest, No(ReasonPreviousRunFailed, "attempt %d of run %d of workflow %s for %s completed with conclusion %s", newest.GetRunAttempt(), newest.GetID(), workflow, SHA, conc)
+}
+
+// Rerun is the decision to re-run the failed jobs of a run, rather than dispatching its workflow again
+func Rerun(workflow, SHA string, run *github.WorkflowRun) Decision {
+	return Yes(ReasonPreviousRunRerun, "re-running the failed jobs of run %d of workflow %s for %s, attempt %d completed with conclusion %s", run.GetID(), workflow, SHA, run.GetRunAttempt(), run.GetConclusion())
+}
+
+// runStartedAt returns when the latest attempt of a run started
+func runStartedAt(run *github.WorkflowRun) time.Time {
+	if run.RunStartedAt != nil {
+		return run.GetRunStartedAt().Time
+	}
+	return run.GetCreatedAt().Time
+}
+
+// CanRerun reports whether the failed jobs of a run can be re-run at the given time, rather than
+// dispatching the workflow again
+func CanRerun(run *github.WorkflowRun, now time.Time) bool {
+	switch run.GetConclusion() {
+	case "failure", "cancelled", "timed_out":
+	default:
+		return false
+	}
+	return run.CreatedAt == nil || now.Sub(run.GetCreatedAt().Time) < rerunMaxAge
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package decision_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_Evaluate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	config := decision.Config{
+		Triggers: map[string][]string{
+			"/test": {"foo.yaml", "bar.yaml", "baz.yaml"},
+		},
+		Workflows: map[string]decision.PathsFilters{
+			"foo.yaml": {PathsRegex: "foo/"},
+			"bar.yaml": {PathsIgnoreRegex: "docs/"},
+		},
+	}
+	run := func(id int64, status, conclusion string, created time.Time) *github.WorkflowRun {
+		return &github.WorkflowRun{
+			ID:         github.Int64(id),
+			Status:     github.String(status),
+			Conclusion: github.String(conclusion),
+			RunAttempt: github.Int(1),
+			CreatedAt:  &github.Timestamp{Time: created},
+		}
+	}
+
+	testCases := []struct {
+		Comment         string
+		Files           []string
+		Runs            map[string][]*github.WorkflowRun
+		ExpectedTrigger decision.Reason
+		ExpectedActions map[string]string
+		ExpectedReasons map[string]decision.Reason
+	}{
+		{
+			Comment:         "/retest",
+			ExpectedTrigger: decision.ReasonNoTriggerMatched,
+		},
+		{
+			Comment:         "/test",
+			Files:           []string{"docs/README.md"},
+			ExpectedTrigger: decision.ReasonTriggerMatched,
+			ExpectedActions: map[string]string{"foo.yaml": decision.ActionSkip, "bar.yaml": decision.ActionSkip, "baz.yaml": decision.ActionDispatch},
+			ExpectedReasons: map[string]decision.Reason{"foo.yaml": decision.ReasonPathsNotMatched, "bar.yaml": decision.ReasonAllPathsIgnored, "baz.yaml": decision.ReasonNonWorkflowChanges},
+		},
+		{
+			Comment: "/test",
+			Files:   []string{"foo/main.go"},
+			Runs: map[string][]*github.WorkflowRun{
+				"foo.yaml": {run(1, "completed", "success", now.Add(-time.Hour))},
+				"bar.yaml": {run(2, "completed", "failure", now.Add(-time.Hour))},
+				"baz.yaml": {run(3, "completed", "failure", now.Add(-60*24*time.Hour))},
+			},
+			ExpectedTrigger: decision.ReasonTriggerMatched,
+			ExpectedActions: map[string]string{"foo.yaml": decision.ActionSkip, "bar.yaml": decision.ActionRerun, "baz.yaml": decision.ActionDispatch},
+			ExpectedReasons: map[string]decision.Reason{"foo.yaml": decision.ReasonPreviousRunSucceeded, "bar.yaml": decision.ReasonPreviousRunRerun, "baz.yaml": decision.ReasonNonWorkflowChanges},
+		},
+		{
+			Comment: "/test",
+			Files:   []string{"foo/main.go"},
+			Runs: map[string][]*github.WorkflowRun{
+				"foo.yaml": {run(1, "completed", "failure", now.Add(-time.Hour)), run(2, "in_progress", "", now.Add(-2*time.Hour))},
+			},
+			ExpectedTrigger: decision.ReasonTriggerMatched,
+			ExpectedActions: map[string]string{"foo.yaml": decision.ActionSkip, "bar.yaml": decision.ActionDispatch, "baz.yaml": decision.ActionDispatch},
+			ExpectedReasons: map[string]decision.Reason{"foo.yaml": decision.ReasonPreviousRunPending, "bar.yaml": decision.ReasonPathsNotIgnored, "baz.yaml": decision.ReasonNonWorkflowChanges},
+		},
+	}
+
+	for idx, testCase := range testCases {
+		plan := decision.Evaluate(config, testCase.Comment, testCase.Files, decision.RunState{SHA: "mock-sha", Runs: testCase.Runs, Now: now})
+		assert.Equal(t, testCase.ExpectedTrigger, plan.Trigger.Reason, "[TEST%v] trigger: %s", idx+1, plan.Trigger.Message)
+		assert.Len(t, plan.Workflows, len(testCase.ExpectedActions), "[TEST%v]", idx+1)
+		for _, workflowPlan := range plan.Workflows {
+			reason := workflowPlan.Skip
+			if workflowPlan.Run != nil {
+				reason = *workflowPlan.Run
+			}
+			assert.Equal(t, testCase.ExpectedActions[workflowPlan.Workflow], workflowPlan.Action, "[TEST%v] %s: %s", idx+1, workflowPlan.Workflow, reason.Message)
+			assert.Equal(t, testCase.ExpectedReasons[workflowPlan.Workflow], reason.Reason, "[TEST%v] %s: %s", idx+1, workflowPlan.Workflow, reason.Message)
+		}
+
+		// the same arguments always give the same plan
+		assert.Equal(t, plan, decision.Evaluate(config, testCase.Comment, testCase.Files, decision.RunState{SHA: "mock-sha", Runs: testCase.Runs, Now: now}), "[TEST%v]", idx+1)
+	}
+}
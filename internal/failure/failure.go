@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package failure categorizes the errors returned by handlers, to decide whether to retry them, drop them or
+// alert on them, and which HTTP status to answer GitHub with so that failed deliveries are the ones worth
+// redelivering.
+package failure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Category is the kind of a handler failure
+type Category string
+
+const (
+	// ConfigError is a missing or invalid repository config, which the repository maintainers must fix
+	ConfigError Category = "config-error"
+	// PermissionDenied is a GitHub API call the app installation is not allowed to make
+	PermissionDenied Category = "permission-denied"
+	// GitHubTransient is a GitHub API failure likely to go away, e.g. a server error or a rate limit
+	GitHubTransient Category = "github-transient"
+	// GitHubPermanent is a GitHub API call rejected for good, e.g. a missing resource or an invalid request
+	GitHubPermanent Category = "github-permanent"
+	// Internal is any other failure
+	Internal Category = "internal"
+)
+
+// Categories lists all categories
+var Categories = []Category{ConfigError, PermissionDenied, GitHubTransient, GitHubPermanent, Internal}
+
+// Retryable reports whether handling the event again may succeed without anyone's intervention. Internal failures
+// are not, as handling the event again would most likely fail the same way.
+func (c Category) Retryable() bool {
+	return c == GitHubTransient
+}
+
+// Alert reports whether the failure needs the attention of the Ariane operators. Other failures are either
+// retried until they go away, or are up to the repository maintainers.
+func (c Category) Alert() bool {
+	return c == PermissionDenied || c == Internal
+}
+
+// StatusCode is the HTTP status answered to GitHub. Failures which a redelivery cannot fix are acknowledged,
+// so that redelivering failed deliveries only redelivers the others.
+func (c Category) StatusCode() int {
+	switch c {
+	case ConfigError, GitHubPermanent:
+		return http.StatusOK
+	case GitHubTransient:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// Error is an error of a known category
+type Error struct {
+	Category Category
+	Err      error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap sets the category of err, nil if err is nil
+func Wrap(category Category, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Category: category, Err: err}
+}
+
+// Errorf formats an error of the given category, wrapping the %w operands like fmt.Errorf
+func Errorf(category Category, format string, args ...any) error {
+	return &Error{Category: category, Err: fmt.Errorf(format, args...)}
+}
+
+// CategoryOf returns the category set on err with Wrap, or else infers it from the GitHub API error err wraps.
+// Errors which are neither are Internal.
+func CategoryOf(err error) Category {
+	var categorized *Error
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+
+	var rateLimit *github.RateLimitError
+	var abuseRateLimit *github.AbuseRateLimitError
+	if errors.As(err, &rateLimit) || errors.As(err, &abuseRateLimit) {
+		return GitHubTransient
+	}
+	var response *github.ErrorResponse
+	if errors.As(err, &response) && response.Response != nil {
+		switch status := response.Response.StatusCode; {
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return PermissionDenied
+		case status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+			return GitHubTransient
+		case status >= http.StatusBadRequest:
+			return GitHubPermanent
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return GitHubTransient
+	}
+	return Internal
+}
+
+const (
+	// RequestIDHeader identifies GitHub API requests, which GitHub support asks for when investigating failures
+	RequestIDHeader = "X-GitHub-Request-Id"
+	// LogKeyRequestID is the log field of the request IDs of failed GitHub API calls
+	LogKeyRequestID = "github_request_id"
+)
+
+// RequestID returns the ID GitHub gave the failed API call err wraps, empty if err is not a GitHub API error or the
+// response had none
+func RequestID(err error) string {
+	var response *http.Response
+	var errorResponse *github.ErrorResponse
+	var rateLimit *github.RateLimitError
+	var abuseRateLimit *github.AbuseRateLimitError
+	switch {
+	case errors.As(err, &errorResponse):
+		response = errorResponse.Response
+	case errors.As(err, &rateLimit):
+		response = rateLimit.Response
+	case errors.As(err, &abuseRateLimit):
+		response = abuseRateLimit.Response
+	}
+	if response == nil {
+		return ""
+	}
+	return response.Header.Get(RequestIDHeader)
+}
+
+type dispatchesKey struct{}
+
+// TrackDispatches returns a context recording the workflows dispatched while handling an event, and a function
+// reporting whether any was, since handling the event again after a dispatch would dispatch the workflow twice
+func TrackDispatches(ctx context.Context) (context.Context, func() bool) {
+	dispatched := &atomic.Bool{}
+	return context.WithValue(ctx, dispatchesKey{}, dispatched), dispatched.Load
+}
+
+// Dispatched records that a workflow was dispatched, or re-run, while handling the event of ctx, if it is tracked
+// with TrackDispatches
+func Dispatched(ctx context.Context) {
+	if dispatched, ok := ctx.Value(dispatchesKey{}).(*atomic.Bool); ok {
+		dispatched.Store(true)
+	}
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package failure_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/failure"
+)
+
+func githubError(status int) error {
+	return &github.ErrorResponse{Response: &http.Response{StatusCode: status}}
+}
+
+func TestCategoryOf(t *testing.T) {
+	testCases := []struct {
+		Err              error
+		ExpectedCategory failure.Category
+		ExpectedReason   string
+	}{
+		{
+			Err:              fmt.Errorf("failed loading config: %w", failure.Wrap(failure.ConfigError, githubError(http.StatusNotFound))),
+			ExpectedCategory: failure.ConfigError,
+			ExpectedReason:   "the category set on wrapped errors wins over the GitHub status.",
+		},
+		{
+			Err:              fmt.Errorf("failed dispatching: %w", githubError(http.StatusForbidden)),
+			ExpectedCategory: failure.PermissionDenied,
+			ExpectedReason:   "403 responses are permission failures.",
+		},
+		{
+			Err:              &github.RateLimitError{Response: &http.Response{StatusCode: http.StatusForbidden}},
+			ExpectedCategory: failure.GitHubTransient,
+			ExpectedReason:   "rate limits are transient, even though GitHub answers 403.",
+		},
+		{
+			Err:              githubError(http.StatusBadGateway),
+			ExpectedCategory: failure.GitHubTransient,
+			ExpectedReason:   "server errors are transient.",
+		},
+		{
+			Err:              githubError(http.StatusUnprocessableEntity),
+			ExpectedCategory: failure.GitHubPermanent,
+			ExpectedReason:   "other client errors are permanent.",
+		},
+		{
+			Err:              context.DeadlineExceeded,
+			ExpectedCategory: failure.GitHubTransient,
+			ExpectedReason:   "timeouts are transient.",
+		},
+		{
+			Err:              errors.New("failed to parse payload"),
+			ExpectedCategory: failure.Internal,
+			ExpectedReason:   "uncategorized errors are internal.",
+		},
+	}
+	for idx, testCase := range testCases {
+		assert.Equal(t, testCase.ExpectedCategory, failure.CategoryOf(testCase.Err), "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+	assert.Nil(t, failure.Wrap(failure.Internal, nil))
+}
+
+func TestRequestID(t *testing.T) {
+	header := http.Header{}
+	header.Set(failure.RequestIDHeader, "CAFE:1234:5678")
+	err := fmt.Errorf("failed dispatching: %w", &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway, Header: header}})
+	assert.Equal(t, "CAFE:1234:5678", failure.RequestID(err))
+	assert.Equal(t, "CAFE:1234:5678", failure.RequestID(&github.RateLimitError{Response: &http.Response{Header: header}}))
+	assert.Equal(t, "", failure.RequestID(githubError(http.StatusNotFound)))
+	assert.Equal(t, "", failure.RequestID(errors.New("failed to parse payload")))
+}
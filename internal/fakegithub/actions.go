@@ -0,0 +1,303 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package fakegithub
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Dispatch is a workflow_dispatch event created through the fake
+type Dispatch struct {
+	// Workflow is the file name of the dispatched workflow
+	Workflow string
+	Ref      string
+	Inputs   map[string]any
+	// RunID is the run the dispatch created
+	RunID int64
+}
+
+// AddWorkflow adds a workflow, whose file name in .github/workflows is file
+func (r *Repo) AddWorkflow(file, name string) *github.Workflow {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	return clone(r.addWorkflow(file, name))
+}
+
+func (r *Repo) addWorkflow(file, name string) *github.Workflow {
+	workflow := &github.Workflow{
+		ID:      github.Ptr(r.server.newID()),
+		Name:    github.Ptr(name),
+		Path:    github.Ptr(".github/workflows/" + file),
+		State:   github.Ptr("active"),
+		HTMLURL: github.Ptr("https://github.com/" + r.Owner + "/" + r.Name + "/blob/" + r.DefaultBranch + "/.github/workflows/" + file),
+	}
+	r.workflows[file] = workflow
+	return workflow
+}
+
+// workflow returns a workflow by file name or ID
+func (r *Repo) workflow(fileOrID string) (*github.Workflow, bool) {
+	if workflow, ok := r.workflows[fileOrID]; ok {
+		return workflow, true
+	}
+	for _, workflow := range r.workflows {
+		if strconv.FormatInt(workflow.GetID(), 10) == fileOrID {
+			return workflow, true
+		}
+	}
+	return nil, false
+}
+
+// AddRun adds a run of a workflow for a SHA, as run for a pull_request event, e.g. a run preceding the tested
+// trigger comment. The workflow is added if needed, named after its file.
+func (r *Repo) AddRun(workflow, SHA, status, conclusion string) *github.WorkflowRun {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	githubWorkflow, ok := r.workflows[workflow]
+	if !ok {
+		githubWorkflow = r.addWorkflow(workflow, workflow)
+	}
+	var branch string
+	for name, head := range r.branches {
+		if head == SHA {
+			branch = name
+		}
+	}
+	run := r.newRun(githubWorkflow, "pull_request", branch, SHA)
+	setRunStatus(run, status, conclusion)
+	return clone(run)
+}
+
+// UpdateRun sets the status and conclusion of a run, returning it as found in workflow_run webhook payloads, or nil
+// if the run is unknown
+func (r *Repo) UpdateRun(id int64, status, conclusion string) *github.WorkflowRun {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	run, ok := r.run(id)
+	if !ok {
+		return nil
+	}
+	setRunStatus(run, status, conclusion)
+	return clone(run)
+}
+
+// Runs returns the runs of a workflow, the newest first
+func (r *Repo) Runs(workflow string) []*github.WorkflowRun {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	var runs []*github.WorkflowRun
+	for i := len(r.runs) - 1; i >= 0; i-- {
+		if strings.HasSuffix(r.runs[i].GetPath(), "/"+workflow) {
+			runs = append(runs, clone(r.runs[i]))
+		}
+	}
+	return runs
+}
+
+// Dispatches returns the workflow_dispatch events created through the fake, in order
+func (r *Repo) Dispatches() []Dispatch {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	return clone(r.dispatches)
+}
+
+func (r *Repo) newRun(workflow *github.Workflow, event, branch, SHA string) *github.WorkflowRun {
+	var number int
+	for _, run := range r.runs {
+		if run.GetWorkflowID() == workflow.GetID() {
+			number++
+		}
+	}
+	id := r.server.newID()
+	now := &github.Timestamp{Time: time.Now()}
+	run := &github.WorkflowRun{
+		ID:           github.Ptr(id),
+		Name:         workflow.Name,
+		DisplayTitle: workflow.Name,
+		WorkflowID:   workflow.ID,
+		Path:         workflow.Path,
+		HeadBranch:   github.Ptr(branch),
+		HeadSHA:      github.Ptr(SHA),
+		Event:        github.Ptr(event),
+		Status:       github.Ptr("queued"),
+		RunNumber:    github.Ptr(number + 1),
+		RunAttempt:   github.Ptr(1),
+		HTMLURL:      github.Ptr("https://github.com/" + r.Owner + "/" + r.Name + "/actions/runs/" + strconv.FormatInt(id, 10)),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		RunStartedAt: now,
+		Repository:   r.repository(),
+	}
+	r.runs = append(r.runs, run)
+	return run
+}
+
+func (r *Repo) run(id int64) (*github.WorkflowRun, bool) {
+	for _, run := range r.runs {
+		if run.GetID() == id {
+			return run, true
+		}
+	}
+	return nil, false
+}
+
+func setRunStatus(run *github.WorkflowRun, status, conclusion string) {
+	run.Status = github.Ptr(status)
+	run.Conclusion = nil
+	if conclusion != "" {
+		run.Conclusion = github.Ptr(conclusion)
+	}
+	run.UpdatedAt = &github.Timestamp{Time: time.Now()}
+}
+
+func listWorkflows(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	workflows := []*github.Workflow{}
+	for _, workflow := range repo.workflows {
+		workflows = append(workflows, workflow)
+	}
+	slices.SortFunc(workflows, func(a, b *github.Workflow) int { return cmp.Compare(a.GetID(), b.GetID()) })
+	page := paginate(w, r, workflows)
+	writeJSON(w, http.StatusOK, &github.Workflows{TotalCount: github.Ptr(len(workflows)), Workflows: page})
+}
+
+func getWorkflow(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	workflow, ok := repo.workflow(r.PathValue("workflow"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, workflow)
+}
+
+// listWorkflowRuns lists the runs of a workflow, the newest first, filtered by event, branch, head_sha, status and
+// creation date, as far as Ariane filters them
+func listWorkflowRuns(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	workflow, ok := repo.workflow(r.PathValue("workflow"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	filterWorkflowRuns(w, r, repo, func(run *github.WorkflowRun) bool { return run.GetWorkflowID() == workflow.GetID() })
+}
+
+// listRepositoryWorkflowRuns lists the runs of all the workflows, filtered as listWorkflowRuns does
+func listRepositoryWorkflowRuns(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	filterWorkflowRuns(w, r, repo, func(*github.WorkflowRun) bool { return true })
+}
+
+// filterWorkflowRuns lists the runs of a repository selected by include, the newest first, filtered by the query
+// parameters of a request
+func filterWorkflowRuns(w http.ResponseWriter, r *http.Request, repo *Repo, include func(*github.WorkflowRun) bool) {
+	var createdAfter time.Time
+	if created, ok := strings.CutPrefix(r.FormValue("created"), ">="); ok {
+		createdAfter, _ = time.Parse(time.RFC3339, created)
+	}
+	runs := []*github.WorkflowRun{}
+	for i := len(repo.runs) - 1; i >= 0; i-- {
+		run := repo.runs[i]
+		switch {
+		case !include(run),
+			!matches(r.FormValue("event"), run.GetEvent()),
+			!matches(r.FormValue("branch"), run.GetHeadBranch()),
+			!matches(r.FormValue("head_sha"), run.GetHeadSHA()),
+			// the status filter takes statuses and conclusions alike
+			!matches(r.FormValue("status"), run.GetStatus()) && !matches(r.FormValue("status"), run.GetConclusion()),
+			run.GetCreatedAt().Before(createdAfter):
+			continue
+		}
+		runs = append(runs, run)
+	}
+	page := paginate(w, r, runs)
+	writeJSON(w, http.StatusOK, &github.WorkflowRuns{TotalCount: github.Ptr(len(runs)), WorkflowRuns: page})
+}
+
+// matches reports whether a value passes a list filter, which is unset if empty
+func matches(filter, value string) bool {
+	return filter == "" || filter == value
+}
+
+// createDispatch dispatches a workflow on a branch or tag, creating its run right away
+func createDispatch(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	workflow, ok := repo.workflow(r.PathValue("workflow"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	var event github.CreateWorkflowDispatchEventRequest
+	if !decode(w, r, &event) {
+		return
+	}
+	SHA, ok := repo.resolve(event.Ref)
+	if !ok || SHA == event.Ref {
+		writeError(w, http.StatusUnprocessableEntity, "No ref found for: "+event.Ref)
+		return
+	}
+	run := repo.newRun(workflow, "workflow_dispatch", trimRef(event.Ref), SHA)
+	file := strings.TrimPrefix(workflow.GetPath(), ".github/workflows/")
+	repo.dispatches = append(repo.dispatches, Dispatch{Workflow: file, Ref: event.Ref, Inputs: event.Inputs, RunID: run.GetID()})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// workflowRun returns the run of a request, answering 404 Not Found if it is unknown
+func (r *Repo) workflowRun(w http.ResponseWriter, req *http.Request) (*github.WorkflowRun, bool) {
+	id, ok := pathID(w, req, "id")
+	if !ok {
+		return nil, false
+	}
+	run, ok := r.run(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+	return run, ok
+}
+
+func getWorkflowRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if run, ok := repo.workflowRun(w, r); ok {
+		writeJSON(w, http.StatusOK, run)
+	}
+}
+
+// cancelWorkflowRun cancels a run right away, unlike GitHub which cancels it asynchronously
+func cancelWorkflowRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	run, ok := repo.workflowRun(w, r)
+	if !ok {
+		return
+	}
+	if run.GetStatus() == "completed" {
+		writeError(w, http.StatusConflict, "Cannot cancel a workflow run that is completed.")
+		return
+	}
+	setRunStatus(run, "completed", "cancelled")
+	writeJSON(w, http.StatusAccepted, struct{}{})
+}
+
+// listWorkflowJobs lists the jobs of a run, which the fake does not model
+func listWorkflowJobs(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if _, ok := repo.workflowRun(w, r); ok {
+		writeJSON(w, http.StatusOK, &github.Jobs{TotalCount: github.Ptr(0), Jobs: []*github.WorkflowJob{}})
+	}
+}
+
+// rerunWorkflowRun queues a new attempt of a completed run, re-running all its jobs or only the failed ones alike
+func rerunWorkflowRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	run, ok := repo.workflowRun(w, r)
+	if !ok {
+		return
+	}
+	if run.GetStatus() != "completed" {
+		writeError(w, http.StatusForbidden, "This workflow run is not completed")
+		return
+	}
+	setRunStatus(run, "queued", "")
+	run.RunAttempt = github.Ptr(run.GetRunAttempt() + 1)
+	run.RunStartedAt = run.UpdatedAt
+	writeJSON(w, http.StatusCreated, struct{}{})
+}
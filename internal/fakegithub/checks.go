@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package fakegithub
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// CheckRuns returns the check runs of a SHA, in creation order
+func (r *Repo) CheckRuns(SHA string) []*github.CheckRun {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	var checkRuns []*github.CheckRun
+	for _, checkRun := range r.checkRuns {
+		if checkRun.GetHeadSHA() == SHA {
+			checkRuns = append(checkRuns, clone(checkRun))
+		}
+	}
+	return checkRuns
+}
+
+// Statuses returns the commit statuses of a SHA, in creation order
+func (r *Repo) Statuses(SHA string) []*github.RepoStatus {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	var statuses []*github.RepoStatus
+	for _, status := range r.statuses {
+		if status.GetURL() == statusURL(r, SHA) {
+			statuses = append(statuses, clone(status))
+		}
+	}
+	return statuses
+}
+
+// statusURL identifies the commit statuses of a SHA, which github.RepoStatus does not hold otherwise
+func statusURL(repo *Repo, SHA string) string {
+	return "https://api.github.com/repos/" + repo.Owner + "/" + repo.Name + "/statuses/" + SHA
+}
+
+func createCheckRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	var opts github.CreateCheckRunOptions
+	if !decode(w, r, &opts) {
+		return
+	}
+	if opts.Name == "" || opts.HeadSHA == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request")
+		return
+	}
+	status := opts.Status
+	if status == nil {
+		status = github.Ptr("queued")
+	}
+	now := &github.Timestamp{Time: time.Now()}
+	checkRun := &github.CheckRun{
+		ID:          github.Ptr(repo.server.newID()),
+		Name:        github.Ptr(opts.Name),
+		HeadSHA:     github.Ptr(opts.HeadSHA),
+		DetailsURL:  opts.DetailsURL,
+		ExternalID:  opts.ExternalID,
+		Status:      status,
+		Conclusion:  opts.Conclusion,
+		StartedAt:   now,
+		CompletedAt: opts.CompletedAt,
+		Output:      opts.Output,
+		App:         &github.App{Slug: github.Ptr("ariane")},
+	}
+	repo.checkRuns = append(repo.checkRuns, checkRun)
+	writeJSON(w, http.StatusCreated, checkRun)
+}
+
+func updateCheckRun(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var opts github.UpdateCheckRunOptions
+	if !decode(w, r, &opts) {
+		return
+	}
+	for _, checkRun := range repo.checkRuns {
+		if checkRun.GetID() != id {
+			continue
+		}
+		if opts.Name != "" {
+			checkRun.Name = github.Ptr(opts.Name)
+		}
+		if opts.DetailsURL != nil {
+			checkRun.DetailsURL = opts.DetailsURL
+		}
+		if opts.ExternalID != nil {
+			checkRun.ExternalID = opts.ExternalID
+		}
+		if opts.Status != nil {
+			checkRun.Status = opts.Status
+		}
+		if opts.Conclusion != nil {
+			// setting a conclusion completes the check run
+			checkRun.Conclusion = opts.Conclusion
+			checkRun.Status = github.Ptr("completed")
+		}
+		if opts.CompletedAt != nil {
+			checkRun.CompletedAt = opts.CompletedAt
+		}
+		if opts.Output != nil {
+			checkRun.Output = opts.Output
+		}
+		writeJSON(w, http.StatusOK, checkRun)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// listCheckRuns lists the check runs of a ref, filtered by check_name and status
+func listCheckRuns(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	SHA, ok := repo.resolve(r.PathValue("ref"))
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "No commit found for SHA: "+r.PathValue("ref"))
+		return
+	}
+	checkRuns := []*github.CheckRun{}
+	for _, checkRun := range repo.checkRuns {
+		if checkRun.GetHeadSHA() == SHA && matches(r.FormValue("check_name"), checkRun.GetName()) && matches(r.FormValue("status"), checkRun.GetStatus()) {
+			checkRuns = append(checkRuns, checkRun)
+		}
+	}
+	page := paginate(w, r, checkRuns)
+	writeJSON(w, http.StatusOK, &github.ListCheckRunsResults{Total: github.Ptr(len(checkRuns)), CheckRuns: page})
+}
+
+func createStatus(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	var status github.RepoStatus
+	if !decode(w, r, &status) {
+		return
+	}
+	switch status.GetState() {
+	case "error", "failure", "pending", "success":
+	default:
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	now := &github.Timestamp{Time: time.Now()}
+	status.ID = github.Ptr(repo.server.newID())
+	status.URL = github.Ptr(statusURL(repo, r.PathValue("sha")))
+	if status.Context == nil {
+		status.Context = github.Ptr("default")
+	}
+	status.CreatedAt, status.UpdatedAt = now, now
+	repo.statuses = append(repo.statuses, &status)
+	writeJSON(w, http.StatusCreated, &status)
+}
+
+// getCombinedStatus combines the latest commit status of each context of a ref, failing if any failed, pending if
+// any is pending or none was set, and succeeding otherwise
+func getCombinedStatus(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	SHA, ok := repo.resolve(r.PathValue("ref"))
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "No commit found for SHA: "+r.PathValue("ref"))
+		return
+	}
+	latest := map[string]*github.RepoStatus{}
+	statuses := []*github.RepoStatus{}
+	// the latest statuses come first
+	for i := len(repo.statuses) - 1; i >= 0; i-- {
+		status := repo.statuses[i]
+		if status.GetURL() != statusURL(repo, SHA) || latest[status.GetContext()] != nil {
+			continue
+		}
+		latest[status.GetContext()] = status
+		statuses = append(statuses, status)
+	}
+	state := "success"
+	for _, status := range statuses {
+		switch status.GetState() {
+		case "error", "failure":
+			state = "failure"
+		case "pending":
+			if state == "success" {
+				state = "pending"
+			}
+		}
+	}
+	if len(statuses) == 0 {
+		state = "pending"
+	}
+	writeJSON(w, http.StatusOK, &github.CombinedStatus{
+		State:      github.Ptr(state),
+		SHA:        github.Ptr(SHA),
+		TotalCount: github.Ptr(len(statuses)),
+		Statuses:   statuses,
+	})
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package fakegithub is a stateful fake of the GitHub API endpoints used by Ariane, for tests. Its repositories hold
+// pull requests, workflows, runs, check runs, commit statuses and comments, which the requests of the tested code
+// read and change, so tests can follow multi-step flows, e.g. a dispatch creating a run which is then looked up.
+package fakegithub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// DefaultBotLogin is the login of the app bot user, authoring the comments and reactions created through the fake
+const DefaultBotLogin = "ariane[bot]"
+
+// Server is a fake GitHub API server. Requests for unknown repositories, or routes it does not serve, are answered
+// with 404 Not Found, like GitHub does.
+type Server struct {
+	*httptest.Server
+	// BotLogin authors the comments and reactions created through the fake, DefaultBotLogin by default
+	BotLogin string
+
+	mu sync.Mutex
+	// overrides serves the requests of the routes replaced with Handle, before the fake
+	overrides *http.ServeMux
+	repos     map[string]*Repo
+	// teams maps "org/team" to the state of the membership of each login
+	teams  map[string]map[string]string
+	nextID int64
+}
+
+// New starts a fake GitHub API server, which must be closed once done
+func New() *Server {
+	s := &Server{
+		BotLogin:  DefaultBotLogin,
+		overrides: http.NewServeMux(),
+		repos:     map[string]*Repo{},
+		teams:     map[string]map[string]string{},
+	}
+	mux := http.NewServeMux()
+	s.registerRoutes(mux)
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := s.overrides.Handler(r); pattern != "" {
+			s.overrides.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	return s
+}
+
+// Client returns a client of the fake
+func (s *Server) Client() *github.Client {
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	return client
+}
+
+// Handle serves the requests matching pattern with handler instead of the fake, e.g. to inject failures. Patterns
+// are http.ServeMux patterns, e.g. "POST /repos/owner/repo/check-runs".
+func (s *Server) Handle(pattern string, handler http.HandlerFunc) {
+	s.overrides.HandleFunc(pattern, handler)
+}
+
+// AddRepo adds a repository, whose default branch is main
+func (s *Server) AddRepo(owner, name string) *Repo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := &Repo{
+		server:        s,
+		Owner:         owner,
+		Name:          name,
+		DefaultBranch: "main",
+		id:            s.newID(),
+		branches:      map[string]string{"main": name + "-main-sha"},
+		tags:          map[string]string{},
+		files:         map[string]string{},
+		pulls:         map[int]*pullRequest{},
+		workflows:     map[string]*github.Workflow{},
+		reactions:     map[int64][]*github.Reaction{},
+	}
+	s.repos[owner+"/"+name] = repo
+	return repo
+}
+
+// AddTeamMember sets the state of the membership of a user to a team, "active" or "pending"
+func (s *Server) AddTeamMember(org, team, login, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := org + "/" + team
+	if s.teams[key] == nil {
+		s.teams[key] = map[string]string{}
+	}
+	s.teams[key][login] = state
+}
+
+// newID returns a new identifier, unique across the objects of the fake. s.mu must be held.
+func (s *Server) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// bot returns the app bot user
+func (s *Server) bot() *github.User {
+	return &github.User{Login: github.Ptr(s.BotLogin), Type: github.Ptr("Bot")}
+}
+
+// repoHandler serves a request for a repository with s.mu held, answering 404 if the repository is unknown
+func (s *Server) repoHandler(handler func(w http.ResponseWriter, r *http.Request, repo *Repo)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		repo, ok := s.repos[r.PathValue("owner")+"/"+r.PathValue("repo")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		handler(w, r, repo)
+	}
+}
+
+func (s *Server) registerRoutes(mux *http.ServeMux) {
+	// app authentication, any JWT is accepted
+	mux.HandleFunc("POST /app/installations/{id}/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusCreated, &github.InstallationToken{
+			Token:     github.Ptr("fake-token-" + r.PathValue("id")),
+			ExpiresAt: &github.Timestamp{Time: time.Now().Add(time.Hour)},
+		})
+	})
+	// github.com does not report an installed version, unlike GitHub Enterprise Server
+	mux.HandleFunc("GET /meta", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, &github.APIMeta{})
+	})
+	mux.HandleFunc("GET /orgs/{org}/teams/{team}/memberships/{login}", s.getTeamMembership)
+
+	mux.HandleFunc("GET /repos/{owner}/{repo}", s.repoHandler(getRepository))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/contents/{path...}", s.repoHandler(getContents))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/commits/{ref...}", s.repoHandler(getCommit))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls", s.repoHandler(listPullRequests))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}", s.repoHandler(getPullRequest))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/{number}/files", s.repoHandler(listPullRequestFiles))
+
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/workflows", s.repoHandler(listWorkflows))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/workflows/{workflow}", s.repoHandler(getWorkflow))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/workflows/{workflow}/runs", s.repoHandler(listWorkflowRuns))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/actions/workflows/{workflow}/dispatches", s.repoHandler(createDispatch))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/runs", s.repoHandler(listRepositoryWorkflowRuns))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/runs/{id}", s.repoHandler(getWorkflowRun))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/actions/runs/{id}/cancel", s.repoHandler(cancelWorkflowRun))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/actions/runs/{id}/jobs", s.repoHandler(listWorkflowJobs))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/actions/runs/{id}/rerun", s.repoHandler(rerunWorkflowRun))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/actions/runs/{id}/rerun-failed-jobs", s.repoHandler(rerunWorkflowRun))
+
+	mux.HandleFunc("POST /repos/{owner}/{repo}/check-runs", s.repoHandler(createCheckRun))
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/check-runs/{id}", s.repoHandler(updateCheckRun))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/commits/{ref}/check-runs", s.repoHandler(listCheckRuns))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/statuses/{sha}", s.repoHandler(createStatus))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/commits/{ref}/status", s.repoHandler(getCombinedStatus))
+
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues/{number}/comments", s.repoHandler(listComments))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/comments", s.repoHandler(createComment))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues/comments", s.repoHandler(listRepositoryComments))
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/issues/comments/{id}", s.repoHandler(editComment))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues/comments/{id}/reactions", s.repoHandler(listReactions))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/comments/{id}/reactions", s.repoHandler(createReaction))
+	mux.HandleFunc("GET /repos/{owner}/{repo}/pulls/comments/{id}/reactions", s.repoHandler(listReactions))
+	mux.HandleFunc("POST /repos/{owner}/{repo}/pulls/comments/{id}/reactions", s.repoHandler(createReaction))
+}
+
+func (s *Server) getTeamMembership(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.teams[r.PathValue("org")+"/"+r.PathValue("team")][r.PathValue("login")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, &github.Membership{State: github.Ptr(state), Role: github.Ptr("member")})
+}
+
+// writeJSON answers a request with a JSON payload
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers a request with an error, in the format of the GitHub API
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+// decode decodes the JSON payload of a request, answering 400 Bad Request if it is invalid
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return false
+	}
+	return true
+}
+
+// pathID parses the numeric path value of a request, answering 404 Not Found if it is invalid
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return 0, false
+	}
+	return id, true
+}
+
+// paginate returns the items of the requested page of a list, following the page and per_page query parameters,
+// and links the next page, if any, like GitHub does
+func paginate[T any](w http.ResponseWriter, r *http.Request, items []T) []T {
+	perPage, err := strconv.Atoi(r.FormValue("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 30
+	}
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.RequestURI()))
+	}
+	return items[start:end]
+}
+
+// clone returns a deep copy of an object of the fake, so callers cannot race with the requests changing it
+func clone[T any](v T) T {
+	var c T
+	bytes, _ := json.Marshal(v)
+	_ = json.Unmarshal(bytes, &c)
+	return c
+}
+
+// trimRef returns a branch or tag name, without its refs/heads/ or refs/tags/ prefix
+func trimRef(ref string) string {
+	ref = strings.TrimPrefix(ref, "refs/")
+	ref = strings.TrimPrefix(ref, "heads/")
+	return strings.TrimPrefix(ref, "tags/")
+}
@@ -0,0 +1,236 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package fakegithub
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepositories(t *testing.T) {
+	fake := New()
+	defer fake.Close()
+	client := fake.Client()
+	ctx := context.Background()
+
+	repo := fake.AddRepo("owner", "repo")
+	repo.SetFile(".github/ariane-config.yaml", "triggers: {}\n")
+	repo.SetTag("v1.0.0", "tag-sha")
+	repo.AddPullRequest(PullRequest{Number: 1, Author: "author", HeadRef: "feature", HeadSHA: "head-sha", Files: []string{"a.go", "b.go", "c.go"}})
+	repo.AddPullRequest(PullRequest{Number: 2, Author: "author", HeadRef: "feature", HeadSHA: "fork-sha", Fork: "fork/repo"})
+
+	repository, _, err := client.Repositories.Get(ctx, "owner", "repo")
+	assert.NoError(t, err)
+	assert.Equal(t, "main", repository.GetDefaultBranch())
+	_, res, err := client.Repositories.Get(ctx, "owner", "unknown")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	content, _, _, err := client.Repositories.GetContents(ctx, "owner", "repo", ".github/ariane-config.yaml", &github.RepositoryContentGetOptions{Ref: "feature"})
+	assert.NoError(t, err)
+	decoded, err := content.GetContent()
+	assert.NoError(t, err)
+	assert.Equal(t, "triggers: {}\n", decoded)
+
+	SHA, _, err := client.Repositories.GetCommitSHA1(ctx, "owner", "repo", "refs/heads/feature", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "head-sha", SHA)
+	SHA, _, err = client.Repositories.GetCommitSHA1(ctx, "owner", "repo", "refs/tags/v1.0.0", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "tag-sha", SHA)
+	_, _, err = client.Repositories.GetCommitSHA1(ctx, "owner", "repo", "refs/tags/v0.0.0", "")
+	assert.Error(t, err)
+
+	prs, _, err := client.PullRequests.List(ctx, "owner", "repo", &github.PullRequestListOptions{State: "open"})
+	assert.NoError(t, err)
+	assert.Len(t, prs, 2)
+	assert.Equal(t, "fork", prs[0].GetHead().GetRepo().GetOwner().GetLogin())
+	assert.Equal(t, "owner", prs[1].GetHead().GetRepo().GetOwner().GetLogin())
+
+	// the files are paged
+	files, res, err := client.PullRequests.ListFiles(ctx, "owner", "repo", 1, &github.ListOptions{PerPage: 2})
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, 2, res.NextPage)
+	files, res, err = client.PullRequests.ListFiles(ctx, "owner", "repo", 1, &github.ListOptions{PerPage: 2, Page: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, "c.go", files[0].GetFilename())
+	assert.Zero(t, res.NextPage)
+
+	pr := repo.PushPullRequest(1, "new-sha")
+	assert.Equal(t, "new-sha", pr.GetHead().GetSHA())
+	SHA, _, err = client.Repositories.GetCommitSHA1(ctx, "owner", "repo", "feature", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "new-sha", SHA)
+}
+
+func TestTeams(t *testing.T) {
+	fake := New()
+	defer fake.Close()
+	client := fake.Client()
+
+	fake.AddTeamMember("owner", "team", "member", "active")
+	fake.AddTeamMember("owner", "team", "invited", "pending")
+
+	membership, _, err := client.Teams.GetTeamMembershipBySlug(context.Background(), "owner", "team", "member")
+	assert.NoError(t, err)
+	assert.Equal(t, "active", membership.GetState())
+	membership, _, err = client.Teams.GetTeamMembershipBySlug(context.Background(), "owner", "team", "invited")
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", membership.GetState())
+	_, res, err := client.Teams.GetTeamMembershipBySlug(context.Background(), "owner", "team", "stranger")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestActions(t *testing.T) {
+	fake := New()
+	defer fake.Close()
+	client := fake.Client()
+	ctx := context.Background()
+
+	repo := fake.AddRepo("owner", "repo")
+	repo.AddPullRequest(PullRequest{Number: 1, HeadRef: "feature", HeadSHA: "head-sha"})
+	repo.AddWorkflow("foo.yaml", "Foo")
+	previous := repo.AddRun("bar.yaml", "head-sha", "completed", "failure")
+
+	// dispatching creates a run, which can be looked up
+	_, err := client.Actions.CreateWorkflowDispatchEventByFileName(ctx, "owner", "repo", "foo.yaml", github.CreateWorkflowDispatchEventRequest{
+		Ref:    "feature",
+		Inputs: map[string]any{"PR-number": "1"},
+	})
+	assert.NoError(t, err)
+	_, err = client.Actions.CreateWorkflowDispatchEventByFileName(ctx, "owner", "repo", "unknown.yaml", github.CreateWorkflowDispatchEventRequest{Ref: "feature"})
+	assert.Error(t, err)
+	_, err = client.Actions.CreateWorkflowDispatchEventByFileName(ctx, "owner", "repo", "foo.yaml", github.CreateWorkflowDispatchEventRequest{Ref: "unknown"})
+	assert.Error(t, err)
+
+	dispatches := repo.Dispatches()
+	assert.Len(t, dispatches, 1)
+	assert.Equal(t, "foo.yaml", dispatches[0].Workflow)
+	assert.Equal(t, map[string]any{"PR-number": "1"}, dispatches[0].Inputs)
+
+	runs, _, err := client.Actions.ListWorkflowRunsByFileName(ctx, "owner", "repo", "foo.yaml", &github.ListWorkflowRunsOptions{Event: "workflow_dispatch", Branch: "feature"})
+	assert.NoError(t, err)
+	assert.Len(t, runs.WorkflowRuns, 1)
+	run := runs.WorkflowRuns[0]
+	assert.Equal(t, dispatches[0].RunID, run.GetID())
+	assert.Equal(t, "head-sha", run.GetHeadSHA())
+	assert.Equal(t, "queued", run.GetStatus())
+	runs, _, err = client.Actions.ListWorkflowRunsByFileName(ctx, "owner", "repo", "foo.yaml", &github.ListWorkflowRunsOptions{HeadSHA: "other-sha"})
+	assert.NoError(t, err)
+	assert.Empty(t, runs.WorkflowRuns)
+
+	completed := repo.UpdateRun(run.GetID(), "completed", "success")
+	assert.Equal(t, "success", completed.GetConclusion())
+	run, _, err = client.Actions.GetWorkflowRunByID(ctx, "owner", "repo", run.GetID())
+	assert.NoError(t, err)
+	assert.Equal(t, "completed", run.GetStatus())
+
+	// re-running a run starts a new attempt
+	_, err = client.Actions.RerunFailedJobsByID(ctx, "owner", "repo", previous.GetID())
+	assert.NoError(t, err)
+	rerun := repo.Runs("bar.yaml")[0]
+	assert.Equal(t, "queued", rerun.GetStatus())
+	assert.Equal(t, 2, rerun.GetRunAttempt())
+	_, err = client.Actions.RerunFailedJobsByID(ctx, "owner", "repo", previous.GetID())
+	assert.Error(t, err)
+
+	// the runs of all the workflows can be listed, and cancelled until they complete
+	runs, _, err = client.Actions.ListRepositoryWorkflowRuns(ctx, "owner", "repo", &github.ListWorkflowRunsOptions{Branch: "feature"})
+	assert.NoError(t, err)
+	assert.Len(t, runs.WorkflowRuns, 2)
+	_, err = client.Actions.CancelWorkflowRunByID(ctx, "owner", "repo", rerun.GetID())
+	assert.ErrorAs(t, err, new(*github.AcceptedError))
+	assert.Equal(t, "cancelled", repo.Runs("bar.yaml")[0].GetConclusion())
+	res, err := client.Actions.CancelWorkflowRunByID(ctx, "owner", "repo", rerun.GetID())
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+}
+
+func TestChecks(t *testing.T) {
+	fake := New()
+	defer fake.Close()
+	client := fake.Client()
+	ctx := context.Background()
+
+	repo := fake.AddRepo("owner", "repo")
+	repo.AddPullRequest(PullRequest{Number: 1, HeadRef: "feature", HeadSHA: "head-sha"})
+
+	checkRun, _, err := client.Checks.CreateCheckRun(ctx, "owner", "repo", github.CreateCheckRunOptions{
+		Name:       "Foo",
+		HeadSHA:    "head-sha",
+		ExternalID: github.Ptr("dispatch/foo.yaml"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "queued", checkRun.GetStatus())
+	_, _, err = client.Checks.UpdateCheckRun(ctx, "owner", "repo", checkRun.GetID(), github.UpdateCheckRunOptions{Name: "Foo", Conclusion: github.Ptr("success")})
+	assert.NoError(t, err)
+
+	checkRuns, _, err := client.Checks.ListCheckRunsForRef(ctx, "owner", "repo", "feature", &github.ListCheckRunsOptions{CheckName: github.Ptr("Foo")})
+	assert.NoError(t, err)
+	assert.Len(t, checkRuns.CheckRuns, 1)
+	assert.Equal(t, "completed", checkRuns.CheckRuns[0].GetStatus())
+	assert.Equal(t, "success", checkRuns.CheckRuns[0].GetConclusion())
+	assert.Len(t, repo.CheckRuns("head-sha"), 1)
+
+	_, _, err = client.Repositories.CreateStatus(ctx, "owner", "repo", "head-sha", &github.RepoStatus{State: github.Ptr("pending"), Context: github.Ptr("Foo")})
+	assert.NoError(t, err)
+	_, _, err = client.Repositories.CreateStatus(ctx, "owner", "repo", "head-sha", &github.RepoStatus{State: github.Ptr("success"), Context: github.Ptr("Foo")})
+	assert.NoError(t, err)
+	combined, _, err := client.Repositories.GetCombinedStatus(ctx, "owner", "repo", "head-sha", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "success", combined.GetState())
+	assert.Equal(t, 1, combined.GetTotalCount())
+	assert.Len(t, repo.Statuses("head-sha"), 2)
+}
+
+func TestIssues(t *testing.T) {
+	fake := New()
+	defer fake.Close()
+	client := fake.Client()
+	ctx := context.Background()
+
+	repo := fake.AddRepo("owner", "repo")
+	trigger := repo.AddComment(1, "author", "/test")
+	repo.AddReaction(trigger.GetID(), "maintainer", "rocket")
+
+	reply, _, err := client.Issues.CreateComment(ctx, "owner", "repo", 1, &github.IssueComment{Body: github.Ptr("Dispatched")})
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBotLogin, reply.GetUser().GetLogin())
+	_, _, err = client.Issues.EditComment(ctx, "owner", "repo", reply.GetID(), &github.IssueComment{Body: github.Ptr("Edited")})
+	assert.NoError(t, err)
+	comments := repo.Comments(1)
+	assert.Len(t, comments, 2)
+	assert.Equal(t, "/test", comments[0].GetBody())
+	assert.Equal(t, "Edited", comments[1].GetBody())
+
+	_, _, err = client.Reactions.CreateIssueCommentReaction(ctx, "owner", "repo", trigger.GetID(), "+1")
+	assert.NoError(t, err)
+	_, _, err = client.Reactions.CreateIssueCommentReaction(ctx, "owner", "repo", trigger.GetID(), "unknown")
+	assert.Error(t, err)
+	reactions, _, err := client.Reactions.ListIssueCommentReactions(ctx, "owner", "repo", trigger.GetID(), &github.ListReactionOptions{Content: "rocket"})
+	assert.NoError(t, err)
+	assert.Len(t, reactions, 1)
+	assert.Equal(t, "maintainer", reactions[0].GetUser().GetLogin())
+	assert.Len(t, repo.Reactions(trigger.GetID()), 2)
+}
+
+func TestHandle(t *testing.T) {
+	fake := New()
+	defer fake.Close()
+	client := fake.Client()
+
+	fake.AddRepo("owner", "repo")
+	fake.Handle("GET /repos/owner/repo", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	_, res, err := client.Repositories.Get(context.Background(), "owner", "repo")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+}
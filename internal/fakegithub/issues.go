@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package fakegithub
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// comment is a comment of the conversation of an issue or pull request
+type comment struct {
+	number  int
+	comment *github.IssueComment
+}
+
+// AddComment adds a comment to the conversation of an issue or pull request, returning it as found in issue_comment
+// webhook payloads
+func (r *Repo) AddComment(number int, author, body string) *github.IssueComment {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	return clone(r.addComment(number, &github.User{Login: github.Ptr(author), Type: github.Ptr("User")}, body))
+}
+
+func (r *Repo) addComment(number int, author *github.User, body string) *github.IssueComment {
+	id := r.server.newID()
+	now := &github.Timestamp{Time: time.Now()}
+	issueComment := &github.IssueComment{
+		ID:                github.Ptr(id),
+		Body:              github.Ptr(body),
+		User:              author,
+		AuthorAssociation: github.Ptr("MEMBER"),
+		HTMLURL:           github.Ptr("https://github.com/" + r.Owner + "/" + r.Name + "/issues/" + strconv.Itoa(number) + "#issuecomment-" + strconv.FormatInt(id, 10)),
+		IssueURL:          github.Ptr("https://api.github.com/repos/" + r.Owner + "/" + r.Name + "/issues/" + strconv.Itoa(number)),
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	r.comments = append(r.comments, &comment{number: number, comment: issueComment})
+	return issueComment
+}
+
+// Comments returns the comments of the conversation of an issue or pull request, in creation order
+func (r *Repo) Comments(number int) []*github.IssueComment {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	var comments []*github.IssueComment
+	for _, c := range r.comments {
+		if c.number == number {
+			comments = append(comments, clone(c.comment))
+		}
+	}
+	return comments
+}
+
+// AddReaction adds the reaction of a user to a comment, e.g. the approval of a maintainer
+func (r *Repo) AddReaction(commentID int64, login, content string) {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	r.addReaction(commentID, &github.User{Login: github.Ptr(login), Type: github.Ptr("User")}, content)
+}
+
+func (r *Repo) addReaction(commentID int64, user *github.User, content string) *github.Reaction {
+	reaction := &github.Reaction{
+		ID:        github.Ptr(r.server.newID()),
+		User:      user,
+		Content:   github.Ptr(content),
+		CreatedAt: &github.Timestamp{Time: time.Now()},
+	}
+	r.reactions[commentID] = append(r.reactions[commentID], reaction)
+	return reaction
+}
+
+// Reactions returns the reactions to a comment, an issue comment or a review comment, in creation order
+func (r *Repo) Reactions(commentID int64) []*github.Reaction {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	return clone(r.reactions[commentID])
+}
+
+func listComments(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	comments := []*github.IssueComment{}
+	for _, c := range repo.comments {
+		if c.number == number {
+			comments = append(comments, c.comment)
+		}
+	}
+	writeJSON(w, http.StatusOK, paginate(w, r, comments))
+}
+
+// listRepositoryComments lists the comments of all the issues and pull requests, in creation order
+func listRepositoryComments(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	comments := []*github.IssueComment{}
+	for _, c := range repo.comments {
+		comments = append(comments, c.comment)
+	}
+	writeJSON(w, http.StatusOK, paginate(w, r, comments))
+}
+
+func createComment(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	var body github.IssueComment
+	if !decode(w, r, &body) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, repo.addComment(number, repo.server.bot(), body.GetBody()))
+}
+
+func editComment(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var body github.IssueComment
+	if !decode(w, r, &body) {
+		return
+	}
+	for _, c := range repo.comments {
+		if c.comment.GetID() == id {
+			c.comment.Body = body.Body
+			c.comment.UpdatedAt = &github.Timestamp{Time: time.Now()}
+			writeJSON(w, http.StatusOK, c.comment)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// listReactions lists the reactions to a comment, filtered by content
+func listReactions(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	reactions := []*github.Reaction{}
+	for _, reaction := range repo.reactions[id] {
+		if matches(r.FormValue("content"), reaction.GetContent()) {
+			reactions = append(reactions, reaction)
+		}
+	}
+	writeJSON(w, http.StatusOK, paginate(w, r, reactions))
+}
+
+// createReaction reacts to a comment, answering with the existing reaction if the bot already reacted the same way
+func createReaction(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var body github.Reaction
+	if !decode(w, r, &body) {
+		return
+	}
+	if !validReactions[body.GetContent()] {
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed")
+		return
+	}
+	for _, reaction := range repo.reactions[id] {
+		if reaction.GetUser().GetLogin() == repo.server.BotLogin && reaction.GetContent() == body.GetContent() {
+			writeJSON(w, http.StatusOK, reaction)
+			return
+		}
+	}
+	writeJSON(w, http.StatusCreated, repo.addReaction(id, repo.server.bot(), body.GetContent()))
+}
+
+// validReactions are the reactions GitHub supports on comments
+var validReactions = map[string]bool{
+	"+1": true, "-1": true, "laugh": true, "confused": true, "heart": true, "hooray": true, "rocket": true, "eyes": true,
+}
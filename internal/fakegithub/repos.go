@@ -0,0 +1,263 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package fakegithub
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// Repo is a repository of the fake, whose state is shared with the requests served for it
+type Repo struct {
+	server *Server
+	// Owner, Name and DefaultBranch are not changed once the repository is added
+	Owner         string
+	Name          string
+	DefaultBranch string
+
+	id int64
+	// branches and tags map their names to their head SHA
+	branches map[string]string
+	tags     map[string]string
+	// files are served on every ref
+	files      map[string]string
+	pulls      map[int]*pullRequest
+	workflows  map[string]*github.Workflow
+	runs       []*github.WorkflowRun
+	dispatches []Dispatch
+	checkRuns  []*github.CheckRun
+	statuses   []*github.RepoStatus
+	comments   []*comment
+	// reactions are keyed by comment ID, whether the comment is known or not
+	reactions map[int64][]*github.Reaction
+}
+
+// PullRequest describes a pull request to add to a repository
+type PullRequest struct {
+	Number int
+	Title  string
+	Author string
+	// HeadRef and HeadSHA are the branch of the pull request and its head. The branch is added to the repository,
+	// unless the pull request comes from a fork.
+	HeadRef string
+	HeadSHA string
+	// Fork is the "owner/repo" the pull request comes from, if not from the repository itself
+	Fork string
+	// BaseRef is the branch the pull request targets, the default branch if empty
+	BaseRef string
+	// Files are the names of the files changed by the pull request
+	Files []string
+	// Closed pull requests are not listed with the open ones
+	Closed bool
+}
+
+type pullRequest struct {
+	pr    *github.PullRequest
+	files []*github.CommitFile
+}
+
+// Repository returns the repository, as found in webhook payloads
+func (r *Repo) Repository() *github.Repository {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	return r.repository()
+}
+
+func (r *Repo) repository() *github.Repository {
+	return &github.Repository{
+		ID:            github.Ptr(r.id),
+		Owner:         &github.User{Login: github.Ptr(r.Owner)},
+		Name:          github.Ptr(r.Name),
+		FullName:      github.Ptr(r.Owner + "/" + r.Name),
+		DefaultBranch: github.Ptr(r.DefaultBranch),
+		HTMLURL:       github.Ptr("https://github.com/" + r.Owner + "/" + r.Name),
+	}
+}
+
+// SetBranch sets the head of a branch, adding it if needed
+func (r *Repo) SetBranch(branch, SHA string) {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	r.branches[branch] = SHA
+}
+
+// SetTag sets the commit a tag points at, adding it if needed
+func (r *Repo) SetTag(tag, SHA string) {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	r.tags[tag] = SHA
+}
+
+// SetFile sets the content of a file, on every ref
+func (r *Repo) SetFile(path, content string) {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	r.files[path] = content
+}
+
+// AddPullRequest adds an open pull request, returning it as found in webhook payloads
+func (r *Repo) AddPullRequest(pr PullRequest) *github.PullRequest {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	baseRef := pr.BaseRef
+	if baseRef == "" {
+		baseRef = r.DefaultBranch
+	}
+	head := r.repository()
+	if pr.Fork != "" {
+		owner, name, _ := strings.Cut(pr.Fork, "/")
+		head = &github.Repository{Owner: &github.User{Login: github.Ptr(owner)}, Name: github.Ptr(name), FullName: github.Ptr(pr.Fork)}
+	} else {
+		r.branches[pr.HeadRef] = pr.HeadSHA
+	}
+	state := "open"
+	if pr.Closed {
+		state = "closed"
+	}
+	githubPR := &github.PullRequest{
+		ID:      github.Ptr(r.server.newID()),
+		Number:  github.Ptr(pr.Number),
+		State:   github.Ptr(state),
+		Title:   github.Ptr(pr.Title),
+		User:    &github.User{Login: github.Ptr(pr.Author)},
+		HTMLURL: github.Ptr("https://github.com/" + r.Owner + "/" + r.Name + "/pull/" + strconv.Itoa(pr.Number)),
+		Head:    &github.PullRequestBranch{Ref: github.Ptr(pr.HeadRef), SHA: github.Ptr(pr.HeadSHA), Repo: head},
+		Base:    &github.PullRequestBranch{Ref: github.Ptr(baseRef), SHA: github.Ptr(r.branches[baseRef]), Repo: r.repository()},
+	}
+	files := make([]*github.CommitFile, 0, len(pr.Files))
+	for _, file := range pr.Files {
+		files = append(files, &github.CommitFile{Filename: github.Ptr(file), Status: github.Ptr("modified")})
+	}
+	r.pulls[pr.Number] = &pullRequest{pr: githubPR, files: files}
+	return clone(githubPR)
+}
+
+// PushPullRequest moves the head of a pull request, as a push to its branch does, returning it as found in webhook
+// payloads
+func (r *Repo) PushPullRequest(number int, SHA string) *github.PullRequest {
+	r.server.mu.Lock()
+	defer r.server.mu.Unlock()
+	pull, ok := r.pulls[number]
+	if !ok {
+		return nil
+	}
+	pull.pr.Head.SHA = github.Ptr(SHA)
+	if pull.pr.Head.GetRepo().GetFullName() == r.Owner+"/"+r.Name {
+		r.branches[pull.pr.Head.GetRef()] = SHA
+	}
+	return clone(pull.pr)
+}
+
+// resolve returns the SHA a ref points at: a branch, a tag, or a SHA known to the repository
+func (r *Repo) resolve(ref string) (string, bool) {
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/") || strings.HasPrefix(ref, "heads/"):
+		SHA, ok := r.branches[trimRef(ref)]
+		return SHA, ok
+	case strings.HasPrefix(ref, "refs/tags/") || strings.HasPrefix(ref, "tags/"):
+		SHA, ok := r.tags[trimRef(ref)]
+		return SHA, ok
+	}
+	if SHA, ok := r.branches[ref]; ok {
+		return SHA, true
+	}
+	if SHA, ok := r.tags[ref]; ok {
+		return SHA, true
+	}
+	known := slices.Concat(slices.Collect(maps.Values(r.branches)), slices.Collect(maps.Values(r.tags)))
+	for _, pull := range r.pulls {
+		known = append(known, pull.pr.GetHead().GetSHA())
+	}
+	return ref, slices.Contains(known, ref)
+}
+
+// gitBlobSHA returns the SHA of a file content, as git computes it
+func gitBlobSHA(content string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(content), content)))
+	return hex.EncodeToString(sum[:])
+}
+
+func getRepository(w http.ResponseWriter, _ *http.Request, repo *Repo) {
+	writeJSON(w, http.StatusOK, repo.repository())
+}
+
+func getContents(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	path := r.PathValue("path")
+	content, ok := repo.files[path]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writeJSON(w, http.StatusOK, &github.RepositoryContent{
+		Type:     github.Ptr("file"),
+		Name:     github.Ptr(path[strings.LastIndex(path, "/")+1:]),
+		Path:     github.Ptr(path),
+		Encoding: github.Ptr("base64"),
+		Content:  github.Ptr(base64.StdEncoding.EncodeToString([]byte(content))),
+		SHA:      github.Ptr(gitBlobSHA(content)),
+	})
+}
+
+// getCommit serves the commit a ref points at, or only its SHA with the application/vnd.github.sha media type
+func getCommit(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	SHA, ok := repo.resolve(r.PathValue("ref"))
+	if !ok {
+		writeError(w, http.StatusUnprocessableEntity, "No commit found for SHA: "+r.PathValue("ref"))
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept"), "sha") {
+		_, _ = w.Write([]byte(SHA))
+		return
+	}
+	writeJSON(w, http.StatusOK, &github.RepositoryCommit{SHA: github.Ptr(SHA)})
+}
+
+func listPullRequests(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	state := r.FormValue("state")
+	if state == "" {
+		state = "open"
+	}
+	numbers := slices.Sorted(maps.Keys(repo.pulls))
+	// the newest pull requests come first
+	slices.Reverse(numbers)
+	prs := []*github.PullRequest{}
+	for _, number := range numbers {
+		if pr := repo.pulls[number].pr; state == "all" || pr.GetState() == state {
+			prs = append(prs, pr)
+		}
+	}
+	writeJSON(w, http.StatusOK, paginate(w, r, prs))
+}
+
+// pullRequest returns the pull request of a request, answering 404 Not Found if it is unknown
+func (r *Repo) pullRequest(w http.ResponseWriter, req *http.Request) (*pullRequest, bool) {
+	number, err := strconv.Atoi(req.PathValue("number"))
+	pull, ok := r.pulls[number]
+	if err != nil || !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return nil, false
+	}
+	return pull, true
+}
+
+func getPullRequest(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if pull, ok := repo.pullRequest(w, r); ok {
+		writeJSON(w, http.StatusOK, pull.pr)
+	}
+}
+
+func listPullRequestFiles(w http.ResponseWriter, r *http.Request, repo *Repo) {
+	if pull, ok := repo.pullRequest(w, r); ok {
+		writeJSON(w, http.StatusOK, paginate(w, r, pull.files))
+	}
+}
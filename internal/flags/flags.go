@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package flags reads the feature flags of each installation from a file in a designated repository of its account,
+// so organization admins, rather than Ariane operators, can toggle the event handlers for their repositories.
+package flags
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	gocache "github.com/patrickmn/go-cache"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+const (
+	DefaultPath = "ariane-flags.yaml"
+	DefaultTTL  = 5 * time.Minute
+)
+
+// Flags are the feature flags of an installation, e.g.
+//
+//	handlers:
+//	  pull_request_review_comment: true
+//	repositories:
+//	  docs:
+//	    handlers:
+//	      merge_group: false
+type Flags struct {
+	// Handlers enables or disables the event handlers for all the repositories of the account
+	Handlers map[string]bool `yaml:"handlers"`
+	// Repositories overrides the flags per repository, keyed by repository name
+	Repositories map[string]RepositoryFlags `yaml:"repositories"`
+}
+
+// RepositoryFlags are the feature flags of a repository, over the ones of its installation
+type RepositoryFlags struct {
+	Handlers map[string]bool `yaml:"handlers"`
+}
+
+// Apply returns the settings of a repository with its flags applied over them
+func (f Flags) Apply(repo string, settings config.RepositorySettings) config.RepositorySettings {
+	handlers := maps.Clone(settings.Handlers)
+	if handlers == nil {
+		handlers = map[string]bool{}
+	}
+	maps.Copy(handlers, f.Handlers)
+	for name, overrides := range f.Repositories {
+		if strings.EqualFold(name, repo) {
+			maps.Copy(handlers, overrides.Handlers)
+		}
+	}
+	settings.Handlers = handlers
+	return settings
+}
+
+// Store reads and caches the flags of installations. A nil Store is valid and reads no flags.
+type Store struct {
+	// Repository is the repository of each account the flags are read from, e.g. ".github"
+	Repository string
+	// Path is the path of the flags file in the default branch of Repository, DefaultPath if empty
+	Path string
+	// NewClient returns a client of an installation
+	NewClient func(installationID int64) (*github.Client, error)
+
+	cache *gocache.Cache
+}
+
+func NewStore(repository, path string, ttl time.Duration, newClient func(installationID int64) (*github.Client, error)) *Store {
+	if path == "" {
+		path = DefaultPath
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Store{Repository: repository, Path: path, NewClient: newClient, cache: gocache.New(ttl, ttl)}
+}
+
+// For returns the flags of an installation, read from the account owning the repository of an event. Accounts
+// without flags file have no flags.
+func (s *Store) For(ctx context.Context, installationID int64, owner string) (Flags, error) {
+	if s == nil {
+		return Flags{}, nil
+	}
+	key := strconv.FormatInt(installationID, 10) + "/" + owner
+	if cached, ok := s.cache.Get(key); ok {
+		return cached.(Flags), nil
+	}
+	flags, err := s.read(ctx, installationID, owner)
+	if err != nil {
+		return Flags{}, err
+	}
+	s.cache.SetDefault(key, flags)
+	return flags, nil
+}
+
+func (s *Store) read(ctx context.Context, installationID int64, owner string) (Flags, error) {
+	client, err := s.NewClient(installationID)
+	if err != nil {
+		return Flags{}, err
+	}
+	file, _, response, err := client.Repositories.GetContents(ctx, owner, s.Repository, s.Path, nil)
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return Flags{}, nil
+	} else if err != nil {
+		return Flags{}, fmt.Errorf("failed downloading flags file %s/%s/%s: %w", owner, s.Repository, s.Path, err)
+	}
+	content, err := file.GetContent()
+	if err != nil {
+		return Flags{}, fmt.Errorf("failed reading flags file %s/%s/%s: %w", owner, s.Repository, s.Path, err)
+	}
+	var flags Flags
+	if err := yaml.Unmarshal([]byte(content), &flags); err != nil {
+		return Flags{}, fmt.Errorf("failed parsing flags file %s/%s/%s: %w", owner, s.Repository, s.Path, err)
+	}
+	return flags, nil
+}
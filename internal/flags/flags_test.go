@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package flags
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func TestStore(t *testing.T) {
+	var reads int
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/cilium/.github/contents/ariane-flags.yaml", func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		content := base64.StdEncoding.EncodeToString([]byte("handlers:\n  pull_request_review_comment: true\nrepositories:\n  Docs:\n    handlers:\n      merge_group: false\n"))
+		_ = json.NewEncoder(w).Encode(github.RepositoryContent{Content: github.Ptr(content), Encoding: github.Ptr("base64")})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	store := NewStore(".github", "", 0, func(installationID int64) (*github.Client, error) {
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+		return client, nil
+	})
+
+	flags, err := store.For(context.Background(), 1, "cilium")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"pull_request_review_comment": true}, flags.Handlers)
+	_, err = store.For(context.Background(), 1, "cilium")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reads, "flags are cached")
+
+	global := config.RepositorySettings{Handlers: map[string]bool{"pull_request_review_comment": false, "push": false}}
+	assert.Equal(t, map[string]bool{"pull_request_review_comment": true, "push": false, "merge_group": false}, flags.Apply("docs", global).Handlers)
+	assert.Equal(t, map[string]bool{"pull_request_review_comment": true, "push": false}, flags.Apply("cilium", global).Handlers)
+	assert.False(t, global.Handlers["pull_request_review_comment"], "the global settings are left untouched")
+
+	// accounts without flags file have no flags
+	flags, err = store.For(context.Background(), 2, "other")
+	assert.NoError(t, err)
+	assert.Empty(t, flags.Handlers)
+
+	var nilStore *Store
+	flags, err = nilStore.For(context.Background(), 1, "cilium")
+	assert.NoError(t, err)
+	assert.Empty(t, flags.Handlers)
+}
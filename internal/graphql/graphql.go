@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package graphql executes read-only GraphQL queries over resolvers returning plain Go values. It implements the
+// subset of GraphQL tooling queries need: named or anonymous queries, arguments, variables, aliases and nested
+// selections. There is no type system: the results are projected on the selected fields as they encode to JSON, the
+// fields missing from a result being null, and the resolvers check their own arguments.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// Resolver resolves a field of the Query type given its arguments, in which the numbers are json.Number and the
+// enum values strings
+type Resolver func(args map[string]any) (any, error)
+
+// Schema maps the fields of the Query type to their resolvers
+type Schema map[string]Resolver
+
+// Request is a GraphQL request, as POSTed to GraphQL endpoints
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Error is a GraphQL error. Path is the path of the field it is about, if any.
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+// Response is a GraphQL response. Data is nil if the request could not be executed at all.
+type Response struct {
+	Data   Object  `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Member is a field of a result object
+type Member struct {
+	Key   string
+	Value any
+}
+
+// Object is a result object, whose fields encode to JSON in the order they were selected in
+type Object []Member
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, member := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(member.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(member.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Get returns the value of a field of the object
+func (o Object) Get(key string) (any, bool) {
+	for _, member := range o {
+		if member.Key == key {
+			return member.Value, true
+		}
+	}
+	return nil, false
+}
+
+// fieldError is an error about a field of the result
+type fieldError struct {
+	path    []any
+	message string
+}
+
+func (e *fieldError) Error() string {
+	return e.message
+}
+
+// Execute executes a request. The errors of a root field make it null without failing the other ones.
+func (s Schema) Execute(req Request) Response {
+	operations, err := parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := selectOperation(operations, req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	variables, err := op.variableValues(req.Variables)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	response := Response{Data: Object{}}
+	for _, f := range op.selections {
+		if _, ok := response.Data.Get(f.key()); ok {
+			continue
+		}
+		value, err := s.resolve(f, variables)
+		if err != nil {
+			path := []any{f.key()}
+			if fieldErr, ok := err.(*fieldError); ok {
+				path = fieldErr.path
+			}
+			response.Errors = append(response.Errors, Error{Message: err.Error(), Path: path})
+			value = nil
+		}
+		response.Data = append(response.Data, Member{Key: f.key(), Value: value})
+	}
+	return response
+}
+
+func selectOperation(operations []operation, name string) (operation, error) {
+	if name == "" {
+		if len(operations) > 1 {
+			return operation{}, fmt.Errorf("operationName must be given to select one of the %d operations", len(operations))
+		}
+		return operations[0], nil
+	}
+	for _, op := range operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return operation{}, fmt.Errorf("unknown operation %q", name)
+}
+
+// variableValues returns the values of the variables of an operation, given or defaulted, the variables without
+// either being null
+func (op operation) variableValues(given map[string]any) (map[string]any, error) {
+	values := map[string]any{}
+	for _, definition := range op.variables {
+		if value, ok := given[definition.name]; ok {
+			values[definition.name] = value
+		} else {
+			values[definition.name] = definition.defaultValue
+		}
+	}
+	for name := range given {
+		if !slices.ContainsFunc(op.variables, func(definition variableDefinition) bool { return definition.name == name }) {
+			return nil, fmt.Errorf("variable $%s is not defined by the operation", name)
+		}
+	}
+	return values, nil
+}
+
+// resolve resolves a root field and projects its result on the selected subfields
+func (s Schema) resolve(f field, variables map[string]any) (any, error) {
+	if f.name == "__typename" {
+		return "Query", nil
+	}
+	resolver, ok := s[f.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q of Query", f.name)
+	}
+	args := map[string]any{}
+	for _, arg := range f.arguments {
+		value, err := argumentValue(arg.value, variables)
+		if err != nil {
+			return nil, err
+		}
+		// null arguments are the same as missing ones
+		if value != nil {
+			args[arg.name] = value
+		}
+	}
+	result, err := resolver(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// go through JSON to walk the result as its API representation
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return project(value, f, []any{f.key()})
+}
+
+// argumentValue replaces the variables in an argument value by their values
+func argumentValue(value any, variables map[string]any) (any, error) {
+	switch v := value.(type) {
+	case variable:
+		value, ok := variables[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined by the operation", v)
+		}
+		return value, nil
+	case []any:
+		list := make([]any, 0, len(v))
+		for _, item := range v {
+			item, err := argumentValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case map[string]any:
+		object := map[string]any{}
+		for key, item := range v {
+			item, err := argumentValue(item, variables)
+			if err != nil {
+				return nil, err
+			}
+			object[key] = item
+		}
+		return object, nil
+	}
+	return value, nil
+}
+
+// project keeps the selected subfields of a field's value, walking through its lists
+func project(value any, f field, path []any) (any, error) {
+	switch v := value.(type) {
+	case []any:
+		list := make([]any, 0, len(v))
+		for i, item := range v {
+			item, err := project(item, f, append(slices.Clone(path), i))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		return list, nil
+	case map[string]any:
+		if f.selections == nil {
+			return nil, &fieldError{path: path, message: fmt.Sprintf("field %q is an object and must have a selection of subfields", f.name)}
+		}
+		object := Object{}
+		for _, selection := range f.selections {
+			if _, ok := object.Get(selection.key()); ok {
+				continue
+			}
+			selectionPath := append(slices.Clone(path), selection.key())
+			if len(selection.arguments) > 0 {
+				return nil, &fieldError{path: selectionPath, message: fmt.Sprintf("field %q takes no arguments, only the fields of Query do", selection.name)}
+			}
+			item, err := project(v[selection.name], selection, selectionPath)
+			if err != nil {
+				return nil, err
+			}
+			object = append(object, Member{Key: selection.key(), Value: item})
+		}
+		return object, nil
+	case nil:
+		return nil, nil
+	}
+	if f.selections != nil {
+		return nil, &fieldError{path: path, message: fmt.Sprintf("field %q is a scalar and cannot have a selection of subfields", f.name)}
+	}
+	return value, nil
+}
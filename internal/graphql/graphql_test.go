@@ -0,0 +1,165 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testItem struct {
+	Name   string            `json:"name"`
+	Count  int               `json:"count"`
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+func testSchema() Schema {
+	items := []testItem{
+		{Name: "foo", Count: 1, Labels: map[string]string{"team": "a"}},
+		{Name: "bar", Count: 2},
+	}
+	return Schema{
+		"items": func(args map[string]any) (any, error) {
+			first := len(items)
+			if v, ok := args["first"]; ok {
+				n, err := v.(json.Number).Int64()
+				if err != nil {
+					return nil, err
+				}
+				first = int(n)
+			}
+			return items[:first], nil
+		},
+		"item": func(args map[string]any) (any, error) {
+			for _, item := range items {
+				if item.Name == args["name"] {
+					return item, nil
+				}
+			}
+			return nil, nil
+		},
+		"fail": func(args map[string]any) (any, error) {
+			return nil, errors.New("failed")
+		},
+	}
+}
+
+func execute(query string, operationName string, variables map[string]any) string {
+	response := testSchema().Execute(Request{Query: query, OperationName: operationName, Variables: variables})
+	encoded, _ := json.Marshal(response)
+	return string(encoded)
+}
+
+func Test_Execute(t *testing.T) {
+	tests := []struct {
+		query         string
+		operationName string
+		variables     map[string]any
+		expected      string
+	}{
+		{
+			query:    `{ items { name count } }`,
+			expected: `{"data":{"items":[{"name":"foo","count":1},{"name":"bar","count":2}]}}`,
+		},
+		{
+			// the fields are in the order of the selection, missing ones being null
+			query:    `{ items(first: 2) { count name labels { team } } }`,
+			expected: `{"data":{"items":[{"count":1,"name":"foo","labels":{"team":"a"}},{"count":2,"name":"bar","labels":null}]}}`,
+		},
+		{
+			query: `# comments and commas are ignored
+				query Named($name: String! = "foo", $first: Int) {
+					first: item(name: $name) { name },
+					rest: items(first: $first) { name }
+					__typename
+				}`,
+			variables: map[string]any{"first": json.Number("1")},
+			expected:  `{"data":{"first":{"name":"foo"},"rest":[{"name":"foo"}],"__typename":"Query"}}`,
+		},
+		{
+			query:         `query A { item(name: "foo") { name } } query B { item(name: bar) { name } }`,
+			operationName: "B",
+			expected:      `{"data":{"item":{"name":"bar"}}}`,
+		},
+		{
+			// the errors of a field do not fail the other ones
+			query:    `{ fail items(first: 1) { name } unknown }`,
+			expected: `{"data":{"fail":null,"items":[{"name":"foo"}],"unknown":null},"errors":[{"message":"failed","path":["fail"]},{"message":"unknown field \"unknown\" of Query","path":["unknown"]}]}`,
+		},
+		{
+			query:    `{ items }`,
+			expected: `{"data":{"items":null},"errors":[{"message":"field \"items\" is an object and must have a selection of subfields","path":["items",0]}]}`,
+		},
+		{
+			query:    `{ items { name { first } } }`,
+			expected: `{"data":{"items":null},"errors":[{"message":"field \"name\" is a scalar and cannot have a selection of subfields","path":["items",0,"name"]}]}`,
+		},
+		{
+			query:    `{ items { count(min: 1) } }`,
+			expected: `{"data":{"items":null},"errors":[{"message":"field \"count\" takes no arguments, only the fields of Query do","path":["items",0,"count"]}]}`,
+		},
+		{
+			query:    `{ item(name: $name) { name } }`,
+			expected: `{"data":{"item":null},"errors":[{"message":"variable $name is not defined by the operation","path":["item"]}]}`,
+		},
+		{
+			query:     `{ items { name } }`,
+			variables: map[string]any{"name": "foo"},
+			expected:  `{"errors":[{"message":"variable $name is not defined by the operation"}]}`,
+		},
+		{
+			query:    `query A { items { name } } query B { items { count } }`,
+			expected: `{"errors":[{"message":"operationName must be given to select one of the 2 operations"}]}`,
+		},
+		{
+			query:    `mutation { items { name } }`,
+			expected: `{"errors":[{"message":"syntax error at 1:1: mutation operations are not supported, the API is read-only"}]}`,
+		},
+		{
+			query:    "{\n  items { ...fields }\n}",
+			expected: `{"errors":[{"message":"syntax error at 2:11: fragments are not supported"}]}`,
+		},
+		{
+			query:    `{ items @include(if: true) { name } }`,
+			expected: `{"errors":[{"message":"syntax error at 1:9: directives are not supported"}]}`,
+		},
+		{
+			query:    `{ items { name }`,
+			expected: `{"errors":[{"message":"syntax error at 1:17: expected a name, found end of query"}]}`,
+		},
+		{
+			query:    `{ item(name: "foo) { name } }`,
+			expected: `{"errors":[{"message":"syntax error at 1:14: unterminated string"}]}`,
+		},
+		{
+			query:    ``,
+			expected: `{"errors":[{"message":"syntax error at 1:1: no operation"}]}`,
+		},
+	}
+
+	for i, test := range tests {
+		assert.Equal(t, test.expected, execute(test.query, test.operationName, test.variables), "[TEST%v]", i)
+	}
+}
+
+func Test_parseValues(t *testing.T) {
+	operations, err := parse(`{ f(a: -1.5e3, b: "a\"é", c: [1 true null ENUM], d: {e: $v}) }`)
+	assert.NoError(t, err)
+	if assert.Len(t, operations, 1) && assert.Len(t, operations[0].selections, 1) {
+		assert.Equal(t, []argument{
+			{name: "a", value: json.Number("-1.5e3")},
+			{name: "b", value: `a"é`},
+			{name: "c", value: []any{json.Number("1"), true, nil, "ENUM"}},
+			{name: "d", value: map[string]any{"e": variable("v")}},
+		}, operations[0].selections[0].arguments)
+	}
+
+	_, err = parse(`query($v: Int = $w) { f }`)
+	assert.ErrorContains(t, err, "variables are not allowed in default values")
+	_, err = parse(`{ f(a: 1.) }`)
+	assert.ErrorContains(t, err, "invalid number 1.")
+}
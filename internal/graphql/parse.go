@@ -0,0 +1,439 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunctuator
+	tokenName
+	tokenNumber
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	// pos is the byte offset of the token in the query
+	pos int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenString:
+		return "string " + t.value
+	}
+	return fmt.Sprintf("%q", t.value)
+}
+
+// syntaxError returns an error locating pos in the query as line:column
+func syntaxError(query string, pos int, format string, args ...any) error {
+	line := strings.Count(query[:pos], "\n") + 1
+	column := pos - strings.LastIndex(query[:pos], "\n")
+	return fmt.Errorf("syntax error at %d:%d: %s", line, column, fmt.Sprintf(format, args...))
+}
+
+// lex splits a query into tokens, dropping the whitespace, commas and comments GraphQL ignores
+func lex(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(query[i:], "..."):
+			tokens = append(tokens, token{kind: tokenPunctuator, value: "...", pos: i})
+			i += 3
+		case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+			tokens = append(tokens, token{kind: tokenPunctuator, value: string(c), pos: i})
+			i++
+		case c == '_' || isLetter(c):
+			start := i
+			for i < len(query) && (query[i] == '_' || isLetter(query[i]) || isDigit(query[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenName, value: query[start:i], pos: start})
+		case c == '-' || isDigit(c):
+			start := i
+			i++
+			for i < len(query) && (isDigit(query[i]) || strings.IndexByte(".eE+-", query[i]) >= 0) {
+				i++
+			}
+			// the JSON number syntax is the GraphQL one
+			if !json.Valid([]byte(query[start:i])) {
+				return nil, syntaxError(query, start, "invalid number %s", query[start:i])
+			}
+			tokens = append(tokens, token{kind: tokenNumber, value: query[start:i], pos: start})
+		case c == '"':
+			if strings.HasPrefix(query[i:], `"""`) {
+				return nil, syntaxError(query, i, "block strings are not supported")
+			}
+			start := i
+			for i++; i < len(query) && query[i] != '"'; i++ {
+				if query[i] == '\\' {
+					i++
+				}
+				if i >= len(query) || query[i] == '\n' {
+					return nil, syntaxError(query, start, "unterminated string")
+				}
+			}
+			if i >= len(query) {
+				return nil, syntaxError(query, start, "unterminated string")
+			}
+			i++
+			// so are the string escapes
+			var value string
+			if err := json.Unmarshal([]byte(query[start:i]), &value); err != nil {
+				return nil, syntaxError(query, start, "invalid string %s", query[start:i])
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, pos: start})
+		default:
+			return nil, syntaxError(query, i, "unexpected character %q", c)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(query)}), nil
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// operation is a query of a document
+type operation struct {
+	name       string
+	variables  []variableDefinition
+	selections []field
+}
+
+type variableDefinition struct {
+	name         string
+	defaultValue any
+}
+
+type field struct {
+	alias     string
+	name      string
+	arguments []argument
+	// selections is nil for the fields without a selection of subfields
+	selections []field
+}
+
+// key is the key of the field in the result
+func (f field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argument struct {
+	name  string
+	value any
+}
+
+// variable is a reference to a variable in an argument value
+type variable string
+
+type parser struct {
+	query  string
+	tokens []token
+	next   int
+}
+
+// parse parses the operations of a document
+func parse(query string) ([]operation, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{query: query, tokens: tokens}
+	var operations []operation
+	for p.peek().kind != tokenEOF {
+		operation, err := p.operation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	if len(operations) == 0 {
+		return nil, syntaxError(query, 0, "no operation")
+	}
+	return operations, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return syntaxError(p.query, t.pos, format, args...)
+}
+
+// is reports whether the next token is the given punctuator
+func (p *parser) is(punctuator string) bool {
+	t := p.peek()
+	return t.kind == tokenPunctuator && t.value == punctuator
+}
+
+func (p *parser) expect(punctuator string) error {
+	if t := p.advance(); t.kind != tokenPunctuator || t.value != punctuator {
+		return p.errorf(t, "expected %q, found %s", punctuator, t)
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.advance()
+	if t.kind != tokenName {
+		return "", p.errorf(t, "expected a name, found %s", t)
+	}
+	return t.value, nil
+}
+
+// unsupported rejects the directives, which the API has none of
+func (p *parser) unsupported() error {
+	if p.is("@") {
+		return p.errorf(p.peek(), "directives are not supported")
+	}
+	return nil
+}
+
+func (p *parser) operation() (operation, error) {
+	var op operation
+	// the query shorthand is a bare selection set
+	if p.is("{") {
+		selections, err := p.selectionSet()
+		op.selections = selections
+		return op, err
+	}
+	t := p.advance()
+	switch {
+	case t.kind == tokenName && t.value == "query":
+	case t.kind == tokenName && (t.value == "mutation" || t.value == "subscription"):
+		return op, p.errorf(t, "%s operations are not supported, the API is read-only", t.value)
+	case t.kind == tokenName && t.value == "fragment":
+		return op, p.errorf(t, "fragments are not supported")
+	default:
+		return op, p.errorf(t, "expected an operation, found %s", t)
+	}
+	if p.peek().kind == tokenName {
+		op.name = p.advance().value
+	}
+	if p.is("(") {
+		variables, err := p.variableDefinitions()
+		if err != nil {
+			return op, err
+		}
+		op.variables = variables
+	}
+	if err := p.unsupported(); err != nil {
+		return op, err
+	}
+	selections, err := p.selectionSet()
+	op.selections = selections
+	return op, err
+}
+
+func (p *parser) variableDefinitions() ([]variableDefinition, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var definitions []variableDefinition
+	for !p.is(")") {
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		// the types are not checked, the resolvers checking their arguments
+		if err := p.skipType(); err != nil {
+			return nil, err
+		}
+		definition := variableDefinition{name: name}
+		if p.is("=") {
+			p.advance()
+			if definition.defaultValue, err = p.value(true); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.unsupported(); err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, definition)
+	}
+	p.advance()
+	return definitions, nil
+}
+
+func (p *parser) skipType() error {
+	if p.is("[") {
+		p.advance()
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expect("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.is("!") {
+		p.advance()
+	}
+	return nil
+}
+
+func (p *parser) selectionSet() ([]field, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []field
+	for !p.is("}") {
+		if p.is("...") {
+			return nil, p.errorf(p.peek(), "fragments are not supported")
+		}
+		selection, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	if len(selections) == 0 {
+		return nil, p.errorf(p.peek(), "empty selection set")
+	}
+	p.advance()
+	return selections, nil
+}
+
+func (p *parser) field() (field, error) {
+	var f field
+	name, err := p.name()
+	if err != nil {
+		return f, err
+	}
+	f.name = name
+	if p.is(":") {
+		p.advance()
+		if f.name, err = p.name(); err != nil {
+			return f, err
+		}
+		f.alias = name
+	}
+	if p.is("(") {
+		p.advance()
+		for !p.is(")") {
+			name, err := p.name()
+			if err != nil {
+				return f, err
+			}
+			if err := p.expect(":"); err != nil {
+				return f, err
+			}
+			value, err := p.value(false)
+			if err != nil {
+				return f, err
+			}
+			f.arguments = append(f.arguments, argument{name: name, value: value})
+		}
+		p.advance()
+	}
+	if err := p.unsupported(); err != nil {
+		return f, err
+	}
+	if p.is("{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// value parses a value: the numbers are json.Number, the enum values strings, and the variables, which constant
+// values cannot reference, variable
+func (p *parser) value(constant bool) (any, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokenNumber:
+		return json.Number(t.value), nil
+	case tokenString:
+		return t.value, nil
+	case tokenName:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return t.value, nil
+	case tokenPunctuator:
+		switch t.value {
+		case "$":
+			if constant {
+				return nil, p.errorf(t, "variables are not allowed in default values")
+			}
+			name, err := p.name()
+			return variable(name), err
+		case "[":
+			list := []any{}
+			for !p.is("]") {
+				value, err := p.value(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, value)
+			}
+			p.advance()
+			return list, nil
+		case "{":
+			object := map[string]any{}
+			for !p.is("}") {
+				name, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if object[name], err = p.value(constant); err != nil {
+					return nil, err
+				}
+			}
+			p.advance()
+			return object, nil
+		}
+	}
+	return nil, p.errorf(t, "expected a value, found %s", t)
+}
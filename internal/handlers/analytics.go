@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"strings"
+	"time"
+)
+
+// analyticsInput is the workflow_dispatch input carrying the analytics labels of dispatches, if enabled in the
+// repository config
+const analyticsInput = "ariane-analytics"
+
+// Analytics labels a dispatch with what triggered it, so downstream workflows can tag their telemetry with it,
+// e.g. to break the runner cost down per trigger or per team
+type Analytics struct {
+	// Trigger is the command of the trigger phrase, e.g. "/test"
+	Trigger string `json:"trigger"`
+	// User is the login of the user who triggered the dispatch
+	User       string `json:"user"`
+	Repository string `json:"repository"`
+	// PRNumber or IssueNumber is the pull request or plain issue the dispatch was triggered on
+	PRNumber    int `json:"pr-number,omitempty"`
+	IssueNumber int `json:"issue-number,omitempty"`
+	// Attempt counts the runs of the workflow for the SHA, the first run being attempt 1
+	Attempt int `json:"attempt"`
+}
+
+func newAnalytics(owner, repo string, number int, isIssue bool, submatch []string, user string) Analytics {
+	analytics := Analytics{User: user, Repository: owner + "/" + repo, Attempt: 1}
+	if len(submatch) > 0 {
+		if fields := strings.Fields(submatch[0]); len(fields) > 0 {
+			analytics.Trigger = fields[0]
+		}
+	}
+	if isIssue {
+		analytics.IssueNumber = number
+	} else {
+		analytics.PRNumber = number
+	}
+	return analytics
+}
+
+// input encodes the analytics labels as the value of analyticsInput
+func (a Analytics) input() string {
+	// the fields are plain strings and numbers, encoding cannot fail
+	encoded, _ := json.Marshal(a)
+	return string(encoded)
+}
+
+// withAttempt returns the inputs of the dispatch of a workflow with the attempt of its analytics labels set, from
+// the runs of the workflow recorded for the SHA. Inputs without analytics labels, e.g. as the workflow does not
+// declare them, are returned as is.
+func (h *PRCommentHandler) withAttempt(ctx context.Context, t triggerDispatch, workflow string, inputs map[string]interface{}) map[string]interface{} {
+	encoded, ok := inputs[analyticsInput].(string)
+	if !ok {
+		return inputs
+	}
+	var analytics Analytics
+	if err := json.Unmarshal([]byte(encoded), &analytics); err != nil {
+		return inputs
+	}
+	analytics.Attempt = len(h.Retries.runs(ctx, t.owner, t.repo, workflow, t.SHA, time.Time{})) + 1
+	// the inputs are shared by the workflows of the trigger
+	inputs = maps.Clone(inputs)
+	inputs[analyticsInput] = analytics.input()
+	return inputs
+}
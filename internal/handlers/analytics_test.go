@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newAnalytics(t *testing.T) {
+	analytics := newAnalytics("owner", "repo", 1, false, []string{"/test foo", "foo"}, "author")
+	assert.Equal(t, Analytics{Trigger: "/test", User: "author", Repository: "owner/repo", PRNumber: 1, Attempt: 1}, analytics)
+	assert.Equal(t, `{"trigger":"/test","user":"author","repository":"owner/repo","pr-number":1,"attempt":1}`, analytics.input())
+
+	analytics = newAnalytics("owner", "repo", 2, true, []string{"/redeploy"}, "author")
+	assert.Equal(t, `{"trigger":"/redeploy","user":"author","repository":"owner/repo","issue-number":2,"attempt":1}`, analytics.input())
+}
+
+func Test_withAttempt(t *testing.T) {
+	handler := &PRCommentHandler{Retries: NewRetryStore(nil, 0)}
+	dispatch := triggerDispatch{owner: "owner", repo: "repo", SHA: "sha"}
+	inputs := map[string]interface{}{
+		"PR-number":    "1",
+		analyticsInput: newAnalytics("owner", "repo", 1, false, []string{"/test"}, "author").input(),
+	}
+
+	handler.Retries.record(context.Background(), "owner", "repo", "foo.yaml", "sha", time.Now())
+	handler.Retries.record(context.Background(), "owner", "repo", "foo.yaml", "sha", time.Now())
+	retried := handler.withAttempt(context.Background(), dispatch, "foo.yaml", inputs)
+	assert.Equal(t, `{"trigger":"/test","user":"author","repository":"owner/repo","pr-number":1,"attempt":3}`, retried[analyticsInput])
+	assert.Equal(t, "1", retried["PR-number"])
+	assert.Contains(t, inputs[analyticsInput], `"attempt":1`, "the inputs shared by the workflows are left untouched")
+
+	first := handler.withAttempt(context.Background(), dispatch, "bar.yaml", inputs)
+	assert.Contains(t, first[analyticsInput], `"attempt":1`)
+
+	// workflows not declaring the analytics input are dispatched without it
+	assert.Equal(t, map[string]interface{}{"PR-number": "1"}, handler.withAttempt(context.Background(), dispatch, "foo.yaml", map[string]interface{}{"PR-number": "1"}))
+}
@@ -0,0 +1,354 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/log"
+	"github.com/cilium/ariane/internal/state"
+)
+
+const (
+	DefaultApprovalExpiry = 24 * time.Hour
+)
+
+// heldComment is a trigger comment from a user outside of the allowed teams, or from a first-time contributor,
+// waiting for approval
+type heldComment struct {
+	event        *github.IssueCommentEvent
+	allowedTeams []string
+	// nestedTeams also lets the members of the child teams of the allowed teams approve the comment
+	nestedTeams bool
+	reaction    string
+	// cancelReaction lets the comment author cancel it, if set
+	cancelReaction string
+	// secondApproval is set for comments of allowed users held until a second allowed user approves them,
+	// by reacting or by repeating the command
+	secondApproval bool
+	// deliveryID is the delivery ID of the held comment event, used as run marker once approved
+	deliveryID string
+	// reviewComment is set for pull request review comments, whose reactions are listed through other endpoints
+	reviewComment bool
+	// headSHA is the head of the pull request the comment was held at, the only one its approval applies to
+	headSHA string
+	heldAt  time.Time
+}
+
+// storedHeldComment is the encoding of a heldComment in the state store
+type storedHeldComment struct {
+	Event          *github.IssueCommentEvent `json:"event"`
+	AllowedTeams   []string                  `json:"allowedTeams,omitempty"`
+	NestedTeams    bool                      `json:"nestedTeams,omitempty"`
+	Reaction       string                    `json:"reaction,omitempty"`
+	CancelReaction string                    `json:"cancelReaction,omitempty"`
+	SecondApproval bool                      `json:"secondApproval,omitempty"`
+	DeliveryID     string                    `json:"deliveryID,omitempty"`
+	ReviewComment  bool                      `json:"reviewComment,omitempty"`
+	HeadSHA        string                    `json:"headSHA,omitempty"`
+	HeldAt         time.Time                 `json:"heldAt"`
+}
+
+func (c heldComment) stored() storedHeldComment {
+	return storedHeldComment{
+		Event:          c.event,
+		AllowedTeams:   c.allowedTeams,
+		NestedTeams:    c.nestedTeams,
+		Reaction:       c.reaction,
+		CancelReaction: c.cancelReaction,
+		SecondApproval: c.secondApproval,
+		DeliveryID:     c.deliveryID,
+		ReviewComment:  c.reviewComment,
+		HeadSHA:        c.headSHA,
+		HeldAt:         c.heldAt,
+	}
+}
+
+func (c storedHeldComment) held() heldComment {
+	return heldComment{
+		event:          c.Event,
+		allowedTeams:   c.AllowedTeams,
+		nestedTeams:    c.NestedTeams,
+		reaction:       c.Reaction,
+		cancelReaction: c.CancelReaction,
+		secondApproval: c.SecondApproval,
+		deliveryID:     c.DeliveryID,
+		reviewComment:  c.ReviewComment,
+		headSHA:        c.HeadSHA,
+		heldAt:         c.HeldAt,
+	}
+}
+
+const approvalStorePrefix = "approval/"
+
+// ApprovalStore keeps track of held trigger comments, keyed by comment ID
+type ApprovalStore struct {
+	state  state.Store
+	expiry time.Duration
+}
+
+// NewApprovalStore creates an approval store kept in store, in memory if nil
+func NewApprovalStore(store state.Store, expiry time.Duration) *ApprovalStore {
+	if expiry <= 0 {
+		expiry = DefaultApprovalExpiry
+	}
+	return &ApprovalStore{state: orMemory(store), expiry: expiry}
+}
+
+func approvalStoreKey(commentID int64) string {
+	return approvalStorePrefix + strconv.FormatInt(commentID, 10)
+}
+
+func (s *ApprovalStore) add(ctx context.Context, commentID int64, c heldComment) {
+	setState(ctx, s.state, approvalStoreKey(commentID), c.stored(), s.expiry)
+}
+
+// remove drops a held comment, and reports whether it was held
+func (s *ApprovalStore) remove(ctx context.Context, commentID int64) bool {
+	return deleteState(ctx, s.state, approvalStoreKey(commentID))
+}
+
+// held returns the held comments, keyed by comment ID
+func (s *ApprovalStore) held(ctx context.Context) map[int64]heldComment {
+	held := map[int64]heldComment{}
+	for key, c := range listState[storedHeldComment](ctx, s.state, approvalStorePrefix) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(key, approvalStorePrefix), 10, 64)
+		if err != nil || c.Event == nil {
+			continue
+		}
+		held[id] = c.held()
+	}
+	return held
+}
+
+// findRepeat returns a comment held for a second approval on the same PR, with the same body from another author
+func (s *ApprovalStore) findRepeat(ctx context.Context, event *github.IssueCommentEvent) (int64, heldComment, bool) {
+	for id, c := range s.held(ctx) {
+		if c.secondApproval &&
+			c.event.GetRepo().GetID() == event.GetRepo().GetID() &&
+			c.event.GetIssue().GetNumber() == event.GetIssue().GetNumber() &&
+			strings.TrimSpace(c.event.GetComment().GetBody()) == strings.TrimSpace(event.GetComment().GetBody()) &&
+			c.event.GetComment().GetUser().GetLogin() != event.GetComment().GetUser().GetLogin() {
+			return id, c, true
+		}
+	}
+	return 0, heldComment{}, false
+}
+
+// pending returns a snapshot of held comments, dropping the expired ones
+func (s *ApprovalStore) pending(ctx context.Context, now time.Time) map[int64]heldComment {
+	pending := s.held(ctx)
+	for id, c := range pending {
+		if now.Sub(c.heldAt) > s.expiry {
+			s.remove(ctx, id)
+			delete(pending, id)
+		}
+	}
+	return pending
+}
+
+// Len returns the number of held comments
+func (s *ApprovalStore) Len() int {
+	return len(s.held(context.Background()))
+}
+
+// holdForApproval records a trigger comment from a non-allowed user, or waiting for a second approval, at the head SHA
+// of its pull request, and reacts with the held reaction ("eyes" by default) to signal it awaits approval
+func (h *PRCommentHandler) holdForApproval(ctx context.Context, client *github.Client, event *github.IssueCommentEvent, arianeConfig *config.ArianeConfig, SHA string, secondApproval bool, logger zerolog.Logger) error {
+	commentID := event.GetComment().GetID()
+	h.Approvals.add(ctx, commentID, heldComment{
+		event:          event,
+		allowedTeams:   arianeConfig.AllowedTeams,
+		nestedTeams:    arianeConfig.NestedTeams,
+		reaction:       arianeConfig.ApprovalReaction,
+		cancelReaction: arianeConfig.CancelReaction,
+		secondApproval: secondApproval,
+		deliveryID:     deliveryIDFromContext(ctx),
+		reviewComment:  isReviewComment(ctx),
+		headSHA:        SHA,
+		heldAt:         h.Scheduler.Now(),
+	})
+	logger.Info().Msgf("Holding trigger comment %d from %s until a maintainer reacts with %q", commentID, event.GetComment().GetUser().GetLogin(), arianeConfig.ApprovalReaction)
+
+	owner := event.GetRepo().GetOwner().GetLogin()
+	repo := event.GetRepo().GetName()
+	reaction := reactionOrDefault(arianeConfig.Reactions.Held, config.DefaultHeldReaction)
+	return h.reactToComment(ctx, client, arianeConfig, owner, repo, event.GetIssue().GetNumber(), commentID, event.GetComment().GetUser().GetLogin(), reaction, logger)
+}
+
+// PollApprovals schedules checking the reactions of held comments every interval, releasing the ones approved
+// by a member of the allowed teams, until ctx is cancelled or the returned function is called.
+func (h *PRCommentHandler) PollApprovals(ctx context.Context, interval time.Duration) context.CancelFunc {
+	return h.Scheduler.Every(ctx, interval, h.pollApprovals)
+}
+
+func (h *PRCommentHandler) pollApprovals(ctx context.Context) {
+	for commentID, held := range h.Approvals.pending(ctx, h.Scheduler.Now()) {
+		installationID := githubapp.GetInstallationIDFromEvent(held.event)
+		repository := held.event.GetRepo()
+		ctx, logger := githubapp.PreparePRContext(ctx, installationID, repository, held.event.GetIssue().GetNumber())
+		ctx = log.WithLogger(ctx, &logger)
+		ctx = withDeliveryID(ctx, held.deliveryID)
+
+		client, err := h.NewInstallationClient(installationID)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to create installation client")
+			continue
+		}
+
+		if h.isCancelled(ctx, client, held, logger) {
+			h.cancelHeld(ctx, commentID, "cancel_reaction", logger)
+			continue
+		}
+
+		approver := h.findApprover(ctx, client, held, logger)
+		if approver == "" {
+			continue
+		}
+
+		logger.Info().Msgf("Trigger comment %d approved by %s", commentID, approver)
+		h.Approvals.remove(ctx, commentID)
+		h.handleApproved(ctx, commentID, held, logger)
+	}
+}
+
+// cancelHeld drops a held comment before it is approved, e.g. because its author deleted it
+func (h *PRCommentHandler) cancelHeld(ctx context.Context, commentID int64, reason string, logger zerolog.Logger) {
+	if h.Approvals == nil || !h.Approvals.remove(ctx, commentID) {
+		return
+	}
+	logger.Info().Msgf("Held trigger comment %d cancelled (%s)", commentID, reason)
+	audit.Event(ctx, "trigger_cancelled").Int64("comment_id", commentID).Str("reason", reason).Send()
+}
+
+// isCancelled reports whether the author of a held comment reacted to it with the cancel reaction
+func (h *PRCommentHandler) isCancelled(ctx context.Context, client *github.Client, held heldComment, logger zerolog.Logger) bool {
+	if held.cancelReaction == "" {
+		return false
+	}
+	owner := held.event.GetRepo().GetOwner().GetLogin()
+	repo := held.event.GetRepo().GetName()
+	author := held.event.GetComment().GetUser().GetLogin()
+
+	opts := &github.ListReactionOptions{Content: held.cancelReaction, ListOptions: github.ListOptions{PerPage: 100}}
+	reactions, err := listCommentReactions(ctx, client, owner, repo, held, opts)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list reactions of held comment")
+		return false
+	}
+	for _, reaction := range reactions {
+		if reaction.GetContent() == held.cancelReaction && reaction.GetUser().GetLogin() == author {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSecondApproval decides whether a trigger comment requiring a second approval can proceed, because it repeats
+// the command of a held comment from another user. The held comment is released, as the repeat is handled instead.
+func (h *PRCommentHandler) checkSecondApproval(ctx context.Context, event *github.IssueCommentEvent) decision.Decision {
+	if h.Approvals == nil {
+		return decision.No(decision.ReasonSecondApprovalUnavailable, "held comments are not polled, so a second approval cannot be given")
+	}
+	if id, held, ok := h.Approvals.findRepeat(ctx, event); ok && h.Approvals.remove(ctx, id) {
+		return decision.Yes(decision.ReasonSecondApprovalGiven, "%s repeated the command of %s", event.GetComment().GetUser().GetLogin(), held.event.GetComment().GetUser().GetLogin())
+	}
+	return decision.No(decision.ReasonSecondApprovalRequired, "a second allowed user must repeat the command or approve it")
+}
+
+// handleApproved handles an approved comment within the handler timeout, if set
+func (h *PRCommentHandler) handleApproved(ctx context.Context, commentID int64, held heldComment, logger zerolog.Logger) {
+	if h.HandlerTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.HandlerTimeout)
+		defer cancel()
+	}
+	if held.reviewComment {
+		ctx = withReviewComment(ctx)
+	}
+	ctx = withApprovedHead(ctx, held.headSHA)
+	if err := h.handleEvent(ctx, held.event, true); err != nil {
+		logger.Error().Err(err).Msgf("Failed to handle approved comment %d", commentID)
+	}
+}
+
+type approvedHeadKey struct{}
+
+// withApprovedHead records the head SHA of the pull request an approved comment was held at
+func withApprovedHead(ctx context.Context, SHA string) context.Context {
+	return context.WithValue(ctx, approvedHeadKey{}, SHA)
+}
+
+// checkApprovedHead checks the head of the pull request of an approved comment is still the one it was held at, so
+// the commits pushed since, which the approver did not review, are not run. Comments held without their head SHA
+// are refused, as what was approved cannot be told.
+func checkApprovedHead(ctx context.Context, SHA string) decision.Decision {
+	approved, _ := ctx.Value(approvedHeadKey{}).(string)
+	if approved == "" {
+		return decision.No(decision.ReasonHeadMoved, "the head of the pull request may have moved")
+	}
+	if approved != SHA {
+		return decision.No(decision.ReasonHeadMoved, "the head of the pull request moved from %s to %s", shortSHA(approved), shortSHA(SHA))
+	}
+	return decision.Yes(decision.ReasonHeadCurrent, "head %s of the pull request is the approved one", shortSHA(SHA))
+}
+
+// canApprove reports whether a user can approve held comments: members of the allowed teams if any, users with
+// write access to the repository otherwise, as comments of first-time contributors are held even without allowed teams
+func (h *PRCommentHandler) canApprove(ctx context.Context, client *github.Client, teams *config.ArianeConfig, owner, repo, user string, logger zerolog.Logger) bool {
+	if len(teams.AllowedTeams) > 0 {
+		return h.isAllowedTeamMember(ctx, client, teams, owner, user, logger).Result
+	}
+	permission, _, err := client.Repositories.GetPermissionLevel(ctx, owner, repo, user)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to retrieve the permission of %s", user)
+		return false
+	}
+	switch permission.GetPermission() {
+	case "admin", "maintain", "write":
+		return true
+	}
+	return false
+}
+
+// findApprover returns the login of an allowed team member who reacted to the held comment with the approval reaction, if any
+func (h *PRCommentHandler) findApprover(ctx context.Context, client *github.Client, held heldComment, logger zerolog.Logger) string {
+	owner := held.event.GetRepo().GetOwner().GetLogin()
+	repo := held.event.GetRepo().GetName()
+	author := held.event.GetComment().GetUser().GetLogin()
+
+	// comments held for a second approval can only be approved by repeating the command without approval reaction
+	if held.reaction == "" {
+		return ""
+	}
+	opts := &github.ListReactionOptions{Content: held.reaction, ListOptions: github.ListOptions{PerPage: 100}}
+	reactions, err := listCommentReactions(ctx, client, owner, repo, held, opts)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list reactions of held comment")
+		return ""
+	}
+
+	teams := &config.ArianeConfig{AllowedTeams: held.allowedTeams, NestedTeams: held.nestedTeams}
+	for _, reaction := range reactions {
+		user := reaction.GetUser().GetLogin()
+		// the comment author cannot approve their own comment, and neither can Ariane
+		if user == author || user == "" || h.isOwnLogin(user) {
+			continue
+		}
+		if h.canApprove(ctx, client, teams, owner, repo, user, logger) {
+			return user
+		}
+	}
+	return ""
+}
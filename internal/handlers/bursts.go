@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// Bursts holds the trigger comments posted on a PR within Window of the first one, to dispatch their workflows
+// together once it elapsed. A nil Bursts holds nothing, and workflows are dispatched right away.
+type Bursts struct {
+	Window time.Duration
+
+	mu      sync.Mutex
+	pending map[string][]pendingTrigger
+}
+
+// pendingTrigger is a trigger comment waiting for the end of its burst, with the context of its event
+type pendingTrigger struct {
+	ctx     context.Context
+	trigger triggerDispatch
+}
+
+// NewBursts creates a store coalescing the trigger comments posted within window. Coalescing is disabled if window is zero.
+func NewBursts(window time.Duration) *Bursts {
+	if window <= 0 {
+		return nil
+	}
+	return &Bursts{Window: window, pending: map[string][]pendingTrigger{}}
+}
+
+// add holds a trigger comment until the end of its burst, and reports whether it starts the burst
+func (b *Bursts) add(key string, pending pendingTrigger) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending[key] = append(b.pending[key], pending)
+	return len(b.pending[key]) == 1
+}
+
+// take returns the trigger comments of a burst, in the order they were posted, and forgets them
+func (b *Bursts) take(key string) []pendingTrigger {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pending := b.pending[key]
+	delete(b.pending, key)
+	return pending
+}
+
+// addToBurst holds a trigger comment until the end of the burst of its PR, starting the burst if it is the first
+func (h *PRCommentHandler) addToBurst(ctx context.Context, trigger triggerDispatch) {
+	key := fmt.Sprintf("%s/%s#%d", trigger.owner, trigger.repo, trigger.prNumber)
+	// the comment is handled after its event, with the values of the event context
+	if !h.Bursts.add(key, pendingTrigger{ctx: context.WithoutCancel(ctx), trigger: trigger}) {
+		trigger.logger.Debug().Msg("Trigger comment joins the burst of the pull request")
+		return
+	}
+	trigger.logger.Debug().Msgf("Trigger comment starts a burst, dispatching in %s", h.Bursts.Window)
+	h.Scheduler.After(context.WithoutCancel(ctx), h.Bursts.Window, func(context.Context) {
+		h.dispatchBurst(h.Bursts.take(key))
+	})
+}
+
+// dispatchBurst dispatches the workflows of the trigger comments of a burst in turn, each within the handler
+// timeout if set, sharing their lookups and dispatches
+func (h *PRCommentHandler) dispatchBurst(pending []pendingTrigger) {
+	batch := newBurstBatch()
+	for _, p := range pending {
+		ctx := p.ctx
+		var cancel context.CancelFunc = func() {}
+		if h.HandlerTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, h.HandlerTimeout)
+		}
+		if err := h.dispatchWorkflows(ctx, p.trigger, batch); err != nil {
+			p.trigger.logger.Error().Err(err).Msgf("Failed to dispatch the workflows of comment %d", p.trigger.commentID)
+		}
+		cancel()
+	}
+}
+
+// burstBatch shares the lookups and dispatches of the trigger comments of a burst, so a workflow is looked up and
+// dispatched once for all of them. A nil burstBatch shares nothing.
+type burstBatch struct {
+	files map[string][]*github.CommitFile
+	skips map[string]decision.Decision
+	// dispatched holds the workflows dispatched for the burst, with their ref and inputs
+	dispatched map[string]bool
+}
+
+func newBurstBatch() *burstBatch {
+	return &burstBatch{files: map[string][]*github.CommitFile{}, skips: map[string]decision.Decision{}, dispatched: map[string]bool{}}
+}
+
+// prFiles returns the files changed by the PR at SHA, listing them once per burst
+func (b *burstBatch) prFiles(SHA string, list func() ([]*github.CommitFile, error)) ([]*github.CommitFile, error) {
+	if b == nil {
+		return list()
+	}
+	if files, ok := b.files[SHA]; ok {
+		return files, nil
+	}
+	files, err := list()
+	if err != nil {
+		return nil, err
+	}
+	b.files[SHA] = files
+	return files, nil
+}
+
+// skip returns whether to skip a workflow for its previous runs on SHA, deciding once per burst, so that failed runs
+// are re-run once
+func (b *burstBatch) skip(workflow, SHA string, decide func() decision.Decision) decision.Decision {
+	if b == nil {
+		return decide()
+	}
+	key := workflow + "@" + SHA
+	if d, ok := b.skips[key]; ok {
+		return d
+	}
+	d := decide()
+	b.skips[key] = d
+	return d
+}
+
+// coalesce decides whether a workflow was already dispatched for the burst on the same ref with the same inputs,
+// other than the run marker which differs for each comment, and records the dispatch otherwise
+func (b *burstBatch) coalesce(workflow string, event github.CreateWorkflowDispatchEventRequest) decision.Decision {
+	inputs := make(map[string]interface{}, len(event.Inputs))
+	for input, value := range event.Inputs {
+		if input != runMarkerInput {
+			inputs[input] = value
+		}
+	}
+	// maps are encoded with sorted keys
+	encoded, err := json.Marshal(inputs)
+	if err != nil {
+		return decision.No(decision.ReasonNotCoalesced, "inputs cannot be compared: %v", err)
+	}
+	key := workflow + "@" + event.Ref + " " + string(encoded)
+	if b.dispatched[key] {
+		return decision.Yes(decision.ReasonCoalesced, "workflow %s was dispatched with the same inputs for another comment of the burst", workflow)
+	}
+	b.dispatched[key] = true
+	return decision.No(decision.ReasonNotCoalesced, "workflow %s was not dispatched with the same inputs for the burst yet", workflow)
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+func TestBursts(t *testing.T) {
+	assert.Nil(t, NewBursts(0), "coalescing is disabled without a window")
+
+	var mu sync.Mutex
+	var fileLookups, runLookups int
+	var dispatched []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls/1/files", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fileLookups++
+		_ = json.NewEncoder(w).Encode([]*github.CommitFile{{Filename: github.String("src/main.go")}})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}/runs", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		runLookups++
+		_ = json.NewEncoder(w).Encode(github.WorkflowRuns{TotalCount: github.Int(0)})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/{workflow}/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		dispatched = append(dispatched, r.PathValue("workflow"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/comments/{id}/reactions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(github.Reaction{})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.IssueComment{})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(github.IssueComment{})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	clock := scheduler.NewFakeClock(time.Now())
+	handler := &PRCommentHandler{Bursts: NewBursts(time.Minute), Scheduler: scheduler.Scheduler{Clock: clock}}
+	arianeConfig := &config.ArianeConfig{}
+	trigger := func(commentID int64, workflows []string, submatch []string) triggerDispatch {
+		return triggerDispatch{
+			client:        client,
+			arianeConfig:  arianeConfig,
+			owner:         "owner",
+			repo:          "repo",
+			prNumber:      1,
+			commentID:     commentID,
+			commentAuthor: "contributor",
+			contextRef:    "pr/feature",
+			SHA:           "mock-sha",
+			workflows:     workflows,
+			event:         handler.createWorkflowDispatchEvent(1, "pr/feature", "mock-sha", submatch, nil),
+			submatch:      submatch,
+			logger:        zerolog.Nop(),
+		}
+	}
+
+	handler.addToBurst(context.Background(), trigger(1, []string{"foo.yaml", "bar.yaml"}, []string{"/test"}))
+	clock.BlockUntil(1)
+	// the same inputs as the first comment for foo.yaml, then other ones
+	handler.addToBurst(context.Background(), trigger(2, []string{"foo.yaml"}, []string{"/test-foo"}))
+	handler.addToBurst(context.Background(), trigger(3, []string{"foo.yaml"}, []string{"/test-foo focus", "focus"}))
+	mu.Lock()
+	assert.Empty(t, dispatched, "nothing is dispatched before the end of the burst")
+	mu.Unlock()
+
+	clock.Advance(time.Minute)
+	handler.Scheduler.Wait()
+	assert.Equal(t, []string{"foo.yaml", "bar.yaml", "foo.yaml"}, dispatched, "workflows are dispatched again only with other inputs")
+	assert.Equal(t, 1, fileLookups, "the PR files are listed once for the burst")
+	assert.Equal(t, 2, runLookups, "the previous runs of each workflow are listed once for the burst")
+}
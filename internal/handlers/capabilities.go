@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+)
+
+// Capabilities are the Actions API endpoints supported by the GitHub instance, which older GitHub Enterprise Server
+// versions lack. A nil Capabilities supports every endpoint, as github.com does.
+type Capabilities struct {
+	// Version is the version of GitHub Enterprise Server the capabilities were detected on
+	Version string
+	// RerunFailedJobs re-runs the failed jobs of a run, and single jobs, instead of all its jobs
+	RerunFailedJobs bool
+	// RerunWorkflow re-runs all the jobs of a run, failed runs being dispatched again otherwise
+	RerunWorkflow bool
+}
+
+// capabilityMatrix is the first GitHub Enterprise Server version supporting each capability, as major and minor
+var capabilityMatrix = []struct {
+	name    string
+	version [2]int
+	field   func(*Capabilities) *bool
+}{
+	{"rerun-workflow", [2]int{3, 1}, func(c *Capabilities) *bool { return &c.RerunWorkflow }},
+	{"rerun-failed-jobs", [2]int{3, 5}, func(c *Capabilities) *bool { return &c.RerunFailedJobs }},
+}
+
+// parseServerVersion returns the major and minor of a GitHub Enterprise Server version, e.g. "3.4.2"
+func parseServerVersion(version string) ([2]int, error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return [2]int{}, fmt.Errorf("invalid version %q", version)
+	}
+	var majorMinor [2]int
+	for i := range majorMinor {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil {
+			return [2]int{}, fmt.Errorf("invalid version %q: %w", version, err)
+		}
+		majorMinor[i] = n
+	}
+	return majorMinor, nil
+}
+
+// capabilitiesOf returns the capabilities of a GitHub Enterprise Server version, per capabilityMatrix
+func capabilitiesOf(version string) (*Capabilities, error) {
+	majorMinor, err := parseServerVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	capabilities := &Capabilities{Version: version}
+	for _, capability := range capabilityMatrix {
+		if majorMinor[0] > capability.version[0] || (majorMinor[0] == capability.version[0] && majorMinor[1] >= capability.version[1]) {
+			*capability.field(capabilities) = true
+		}
+	}
+	return capabilities, nil
+}
+
+// missing returns the names of the capabilities not supported
+func (c *Capabilities) missing() []string {
+	var names []string
+	for _, capability := range capabilityMatrix {
+		if !*capability.field(c) {
+			names = append(names, capability.name)
+		}
+	}
+	return names
+}
+
+func (c *Capabilities) rerunFailedJobs() bool {
+	return c == nil || c.RerunFailedJobs
+}
+
+func (c *Capabilities) rerunWorkflow() bool {
+	return c == nil || c.RerunWorkflow
+}
+
+// DetectCapabilities looks up the version of GitHub Enterprise Server in the API metadata, returning nil on
+// github.com, which reports none. Capabilities are assumed supported if the version cannot be looked up.
+func DetectCapabilities(ctx context.Context, cc githubapp.ClientCreator, logger zerolog.Logger) *Capabilities {
+	client, err := cc.NewAppClient()
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to create a client to detect the GitHub capabilities, assuming all are supported")
+		return nil
+	}
+	return detectCapabilities(ctx, client, logger)
+}
+
+func detectCapabilities(ctx context.Context, client *github.Client, logger zerolog.Logger) *Capabilities {
+	// the installed version is only reported by GitHub Enterprise Server, and not part of github.APIMeta
+	req, err := client.NewRequest("GET", "meta", nil)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to detect the GitHub capabilities, assuming all are supported")
+		return nil
+	}
+	var meta struct {
+		InstalledVersion string `json:"installed_version"`
+	}
+	if _, err := client.Do(ctx, req, &meta); err != nil {
+		logger.Warn().Err(err).Msg("Failed to detect the GitHub capabilities, assuming all are supported")
+		return nil
+	}
+	if meta.InstalledVersion == "" {
+		return nil
+	}
+	capabilities, err := capabilitiesOf(meta.InstalledVersion)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to detect the GitHub capabilities, assuming all are supported")
+		return nil
+	}
+	if missing := capabilities.missing(); len(missing) > 0 {
+		logger.Warn().Strs("missing", missing).Msgf("GitHub Enterprise Server %s lacks some Actions API endpoints, falling back to the supported ones", capabilities.Version)
+	} else {
+		logger.Info().Msgf("GitHub Enterprise Server %s supports all the Actions API endpoints used", capabilities.Version)
+	}
+	return capabilities
+}
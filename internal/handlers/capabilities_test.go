@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/poll"
+)
+
+func Test_detectCapabilities(t *testing.T) {
+	var version string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /meta", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"verifiable_password_authentication": true, "installed_version": version})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	logger := zerolog.Nop()
+
+	assert.Nil(t, detectCapabilities(context.Background(), client, logger), "github.com reports no version, and supports everything")
+
+	version = "3.12.4"
+	assert.Equal(t, &Capabilities{Version: "3.12.4", RerunFailedJobs: true, RerunWorkflow: true}, detectCapabilities(context.Background(), client, logger))
+
+	version = "3.4.2"
+	capabilities := detectCapabilities(context.Background(), client, logger)
+	assert.Equal(t, &Capabilities{Version: "3.4.2", RerunWorkflow: true}, capabilities)
+	assert.Equal(t, []string{"rerun-failed-jobs"}, capabilities.missing())
+
+	version = "2.22.0"
+	assert.Equal(t, &Capabilities{Version: "2.22.0"}, detectCapabilities(context.Background(), client, logger))
+
+	version = "next"
+	assert.Nil(t, detectCapabilities(context.Background(), client, logger), "capabilities are assumed supported on unknown versions")
+}
+
+func Test_rerunFailedJobsFallback(t *testing.T) {
+	var mu sync.Mutex
+	var reruns []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/actions/runs/99/{rerun}", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		reruns = append(reruns, r.PathValue("rerun"))
+		w.WriteHeader(http.StatusCreated)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{
+		Poll:         poll.Poller{Interval: time.Millisecond, Timeout: time.Second},
+		Capabilities: &Capabilities{Version: "3.4.2", RerunWorkflow: true},
+	}
+	handler.rerunFailedJobs(context.Background(), client, "owner", "repo", "foobar.yaml", int64(99), zerolog.Nop())
+	handler.Scheduler.Wait()
+	assert.Equal(t, []string{"rerun"}, reruns, "all the jobs are re-run where re-running the failed ones is not supported")
+}
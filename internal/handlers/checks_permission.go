@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"errors"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/failure"
+)
+
+// checksDeniedExpiry is how long check runs are not created in a repository after being denied, so that granting
+// the Checks permission is picked up without a restart
+const checksDeniedExpiry = time.Hour
+
+// defaultChecksFallbackSummaryMessage replaces the skipped check runs in the summary comment, when the app lacks the
+// Checks permission and the summary message is not set
+const defaultChecksFallbackSummaryMessage = `@{{ .Author }} Ariane cannot create check runs in this repository, the workflows were handled as follows:
+{{ range .Dispatched }}
+- {{ name . }}: dispatched{{ end }}{{ range .Skipped }}
+- {{ name .Workflow }}: skipped, {{ .Reason.Message }}{{ end }}
+`
+
+var errChecksDenied = errors.New("creating check runs is denied in the repository")
+
+// checksDenied records the repositories where creating check runs was denied, keyed by owner/repo, as the app
+// lacks the Checks (read and write) permission
+var checksDenied = gocache.New(checksDeniedExpiry, checksDeniedExpiry)
+
+// lacksChecksPermission reports whether creating check runs was denied in a repository recently
+func lacksChecksPermission(owner, repo string) bool {
+	_, denied := checksDenied.Get(owner + "/" + repo)
+	return denied
+}
+
+// denyChecks records that creating a check run in a repository failed with err, if it was denied, warning once
+// per checksDeniedExpiry. It reports whether creating the check run was denied.
+func denyChecks(owner, repo string, err error, logger zerolog.Logger) bool {
+	if failure.CategoryOf(err) != failure.PermissionDenied {
+		return false
+	}
+	if checksDenied.Add(owner+"/"+repo, true, gocache.DefaultExpiration) == nil {
+		logger.Warn().Err(err).Msgf("Creating check runs is denied in %s/%s, reporting workflows in the summary comment instead until the app is granted the Checks permission", owner, repo)
+	}
+	return true
+}
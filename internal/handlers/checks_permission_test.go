@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_checksDenied(t *testing.T) {
+	var checkRuns int
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/no-checks/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.Workflow{Name: github.Ptr(r.PathValue("workflow"))})
+	})
+	mux.HandleFunc("POST /repos/owner/no-checks/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		checkRuns++
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message": "Resource not accessible by integration"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	ctx := context.Background()
+	arianeConfig := &config.ArianeConfig{}
+	skipped := decision.No(decision.ReasonPathsNotMatched, "no changed file matches")
+	assert.False(t, lacksChecksPermission("owner", "no-checks"))
+	assert.NoError(t, markWorkflowAsSkipped(ctx, nil, client, arianeConfig, "owner", "no-checks", "foo.yaml", "sha", "", skipped, zerolog.Nop()), "denied check runs do not fail the trigger")
+	assert.True(t, lacksChecksPermission("owner", "no-checks"))
+	assert.NoError(t, markWorkflowAsSkipped(ctx, nil, client, arianeConfig, "owner", "no-checks", "bar.yaml", "sha", "", skipped, zerolog.Nop()))
+	assert.Equal(t, 1, checkRuns, "check runs are not created again once denied")
+
+	handler := &PRCommentHandler{}
+	_, err := handler.createQueuedCheck(ctx, client, "owner", "no-checks", "foo.yaml", "foo", "sha", "", zerolog.Nop())
+	assert.ErrorIs(t, err, errChecksDenied)
+	assert.Equal(t, 1, checkRuns)
+}
@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// checkContextOverride decides whether the workflow definitions of a trigger can be taken from the ref given with
+// context=<ref>, rather than from the ref chosen by determineContextRef. The trigger must allow the ref, which must exist.
+func (h *PRCommentHandler) checkContextOverride(ctx context.Context, client *github.Client, owner, repo, ref string, triggerConfig config.TriggerConfig, tag string, logger zerolog.Logger) decision.Decision {
+	if tag != "" {
+		return decision.No(decision.ReasonContextOverrideNotAllowed, "tag triggers run the workflow definitions of their tag %s", tag)
+	}
+	if !triggerConfig.AllowsContextOverride(ref) {
+		return decision.No(decision.ReasonContextOverrideNotAllowed, "the trigger does not allow taking the workflow definitions from %s", ref)
+	}
+	if _, _, err := client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, ""); err != nil {
+		var errResponse *github.ErrorResponse
+		if errors.As(err, &errResponse) && (errResponse.Response.StatusCode == http.StatusNotFound || errResponse.Response.StatusCode == http.StatusUnprocessableEntity) {
+			return decision.No(decision.ReasonContextRefNotFound, "ref %s does not exist", ref)
+		}
+		logger.Error().Err(err).Msgf("Failed to resolve context ref %s", ref)
+		return decision.No(decision.ReasonContextRefLookupFailure, "failed to resolve ref %s", ref)
+	}
+	return decision.Yes(decision.ReasonContextOverridden, "the workflow definitions are taken from %s", ref)
+}
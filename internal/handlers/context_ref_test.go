@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_checkContextOverride(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/commits/main", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("main-sha"))
+	})
+	mux.HandleFunc("GET /repos/owner/repo/commits/feature/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	triggerConfig := config.TriggerConfig{ContextOverrides: []string{"main", "feature/.*"}}
+	testCases := []struct {
+		Ref            string
+		Trigger        config.TriggerConfig
+		Tag            string
+		ExpectedResult bool
+		ExpectedCode   decision.Reason
+		ExpectedReason string
+	}{
+		{
+			Ref:            "main",
+			Trigger:        triggerConfig,
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonContextOverridden,
+			ExpectedReason: "allowed existing refs override the context.",
+		},
+		{
+			Ref:            "main",
+			Trigger:        config.TriggerConfig{},
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonContextOverrideNotAllowed,
+			ExpectedReason: "triggers without context overrides refuse them.",
+		},
+		{
+			Ref:            "mainline",
+			Trigger:        triggerConfig,
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonContextOverrideNotAllowed,
+			ExpectedReason: "context overrides must match whole refs.",
+		},
+		{
+			Ref:            "feature/missing",
+			Trigger:        triggerConfig,
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonContextRefNotFound,
+			ExpectedReason: "missing refs are refused.",
+		},
+		{
+			Ref:            "main",
+			Trigger:        triggerConfig,
+			Tag:            "v1.0.0",
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonContextOverrideNotAllowed,
+			ExpectedReason: "tag triggers run on their tag.",
+		},
+	}
+	handler := &PRCommentHandler{}
+	for idx, testCase := range testCases {
+		result := handler.checkContextOverride(context.Background(), client, "owner", "repo", testCase.Ref, testCase.Trigger, testCase.Tag, zerolog.Nop())
+		assert.Equal(t, testCase.ExpectedResult, result.Result, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+// steps of the decision logic handling a trigger comment, used as metrics labels
+const (
+	stepTrigger        = "trigger"
+	stepPause          = "pause"
+	stepMembership     = "membership"
+	stepContributor    = "contributor"
+	stepArgs           = "args"
+	stepDeprecation    = "deprecation"
+	stepInputs         = "inputs"
+	stepSecondApproval = "second_approval"
+	stepTag            = "tag"
+	stepContextRef     = "context_ref"
+	stepMergeability   = "mergeability"
+	stepRetries        = "retries"
+	stepSkip           = "skip"
+	stepIdempotency    = "idempotency"
+	stepCoalesce       = "coalesce"
+	stepHead           = "head"
+	stepLargePR        = "large_pr"
+	stepPolicy         = "policy"
+	stepPathsOverride  = "paths_override"
+	stepRun            = "run"
+	stepDispatch       = "dispatch"
+	stepCarryOver      = "carry_over"
+)
+
+var decisionsTotal = metrics.NewCounterVec("ariane_decisions_total",
+	"Decisions taken while handling trigger comments, by step, result and reason.",
+	"step", "result", "reason")
+
+// checkDeprecation decides whether the workflows of a trigger run, which deprecated triggers do unless refused
+func checkDeprecation(command string, triggerConfig config.TriggerConfig) decision.Decision {
+	switch {
+	case triggerConfig.Deprecated == nil:
+		return decision.Yes(decision.ReasonTriggerCurrent, "%s is not deprecated", command)
+	case triggerConfig.Deprecated.Refuse:
+		return decision.No(decision.ReasonTriggerRetired, "%s is deprecated and no longer runs", command)
+	default:
+		return decision.Yes(decision.ReasonTriggerDeprecated, "%s is deprecated but still runs", command)
+	}
+}
+
+// recordDecision logs and counts a decision taken at the given step, and returns it
+func recordDecision(logger zerolog.Logger, step string, d decision.Decision) decision.Decision {
+	decisionsTotal.Inc(step, strconv.FormatBool(d.Result), string(d.Reason))
+	logger.Debug().Str("step", step).Object("decision", d).Msg("Decision taken")
+	return d
+}
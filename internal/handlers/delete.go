@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/log"
+)
+
+// DeleteHandler cancels the runs dispatched by Ariane on branches once they are deleted, e.g. the branch of a pull
+// request closed by its author, and completes the check runs following them, so they do not keep running for code
+// nobody will merge
+type DeleteHandler struct {
+	githubapp.ClientCreator
+	// RunChecks tracks the check runs following the dispatched runs, shared with the handlers creating them
+	RunChecks *RunChecks
+}
+
+func (h *DeleteHandler) Handles() []string {
+	return []string{"delete"}
+}
+
+func (h *DeleteHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	var event github.DeleteEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("failed to parse delete event payload: %w", err)
+	}
+	if event.GetRefType() != "branch" {
+		return nil
+	}
+
+	installationID := githubapp.GetInstallationIDFromEvent(&event)
+	repository := event.GetRepo()
+	owner := repository.GetOwner().GetLogin()
+	repo := repository.GetName()
+	branch := event.GetRef()
+	ctx, logger := githubapp.PrepareRepoContext(ctx, installationID, repository)
+	ctx = log.WithLogger(ctx, &logger)
+
+	client, err := h.NewInstallationClient(installationID)
+	if err != nil {
+		return err
+	}
+
+	// the runs of a deleted branch are few, and its outstanding ones fewer
+	runs, _, err := client.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, &github.ListWorkflowRunsOptions{
+		Branch:      branch,
+		Event:       "workflow_dispatch",
+		ListOptions: github.ListOptions{PerPage: 100},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to list the runs of deleted branch %s", branch)
+		return err
+	}
+
+	var errs []error
+	for _, run := range runs.WorkflowRuns {
+		if run.GetStatus() == "completed" {
+			continue
+		}
+		check, tracked := h.RunChecks.get(run.GetID())
+		if !tracked {
+			check, tracked, err = findRunCheck(ctx, client, owner, repo, run)
+			if err != nil {
+				logger.Error().Err(err).Msgf("Failed to list the check runs of run %d", run.GetID())
+				errs = append(errs, err)
+				continue
+			}
+		}
+		// runs dispatched by others are left alone
+		if !tracked && !isMarkedRun(run) {
+			continue
+		}
+		if err := cancelRun(ctx, client, owner, repo, run); err != nil {
+			logger.Error().Err(err).Msgf("Failed to cancel run %d of deleted branch %s", run.GetID(), branch)
+			errs = append(errs, err)
+			continue
+		}
+		logger.Info().Msgf("Cancelled run %d of deleted branch %s", run.GetID(), branch)
+		audit.Event(ctx, "run_cancelled").Str("branch", branch).Int64("run_id", run.GetID()).Str("workflow", strings.TrimPrefix(run.GetPath(), ".github/workflows/")).Send()
+		if tracked {
+			cancelCheck(ctx, client, check, fmt.Sprintf("Branch %s was deleted, Ariane cancelled [%s #%d](%s).", branch, run.GetName(), run.GetRunNumber(), run.GetHTMLURL()), logger)
+			h.RunChecks.remove(run.GetID())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// cancelRun cancels a run, which may have completed since it was listed
+func cancelRun(ctx context.Context, client *github.Client, owner, repo string, run *github.WorkflowRun) error {
+	response, err := client.Actions.CancelWorkflowRunByID(ctx, owner, repo, run.GetID())
+	// GitHub answers 409 Conflict for the runs which completed already
+	if response != nil && response.StatusCode == http.StatusConflict {
+		return nil
+	}
+	var accepted *github.AcceptedError
+	if errors.As(err, &accepted) {
+		return nil
+	}
+	return err
+}
+
+// cancelCheck completes the check run following a cancelled run, as the workflow_run event of the cancelled run may
+// not be delivered before long
+func cancelCheck(ctx context.Context, client *github.Client, check trackedCheck, summary string, logger zerolog.Logger) {
+	title := "Branch deleted"
+	if check.commitStatus {
+		if err := createStatus(ctx, client, check.owner, check.repo, check.SHA, check.name, statusState("cancelled"), title, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to complete pending commit status")
+		}
+		return
+	}
+	_, _, err := client.Checks.UpdateCheckRun(ctx, check.owner, check.repo, check.checkRunID, github.UpdateCheckRunOptions{
+		Name:       check.name,
+		Status:     github.String("completed"),
+		Conclusion: github.String("cancelled"),
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to complete check run")
+	}
+}
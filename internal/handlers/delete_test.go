@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/cilium/ariane/internal/fakegithub"
+)
+
+func TestDeleteHandle(t *testing.T) {
+	fake := fakegithub.New()
+	defer fake.Close()
+	client := fake.Client()
+	ctx := context.Background()
+	repo := fake.AddRepo("owner", "repo")
+	repo.AddPullRequest(fakegithub.PullRequest{Number: 1, HeadRef: "feature", HeadSHA: "head-sha"})
+	runs := map[string]int64{}
+	for _, workflow := range []string{"foo.yaml", "bar.yaml", "baz.yaml"} {
+		repo.AddWorkflow(workflow, workflow)
+		_, err := client.Actions.CreateWorkflowDispatchEventByFileName(ctx, "owner", "repo", workflow, github.CreateWorkflowDispatchEventRequest{Ref: "feature"})
+		assert.NoError(t, err)
+		runs[workflow] = repo.Runs(workflow)[0].GetID()
+	}
+	repo.UpdateRun(runs["bar.yaml"], "in_progress", "")
+
+	// foo.yaml is followed by a tracked check run, bar.yaml by a check run created before a restart, and baz.yaml was
+	// dispatched by someone else
+	runChecks := NewRunChecks()
+	checkRun, _, err := client.Checks.CreateCheckRun(ctx, "owner", "repo", github.CreateCheckRunOptions{Name: "Foo", HeadSHA: "head-sha", ExternalID: github.String(runExternalID(runs["foo.yaml"]))})
+	assert.NoError(t, err)
+	runChecks.add(runs["foo.yaml"], trackedCheck{owner: "owner", repo: "repo", name: "Foo", checkRunID: checkRun.GetID()})
+	_, _, err = client.Checks.CreateCheckRun(ctx, "owner", "repo", github.CreateCheckRunOptions{Name: "Bar", HeadSHA: "head-sha", ExternalID: github.String(runExternalID(runs["bar.yaml"]))})
+	assert.NoError(t, err)
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(1)).Return(client, nil).AnyTimes()
+	handler := &DeleteHandler{ClientCreator: mockClientCreator, RunChecks: runChecks}
+	handle := func(refType string) error {
+		payload, _ := json.Marshal(&github.DeleteEvent{
+			Ref:          github.String("feature"),
+			RefType:      github.String(refType),
+			Repo:         repo.Repository(),
+			Installation: &github.Installation{ID: github.Int64(1)},
+		})
+		return handler.Handle(ctx, "delete", "delivery", payload)
+	}
+
+	// deleted tags have no runs of their own
+	assert.NoError(t, handle("tag"))
+	assert.Equal(t, "queued", repo.Runs("foo.yaml")[0].GetStatus())
+
+	assert.NoError(t, handle("branch"))
+	assert.Equal(t, "cancelled", repo.Runs("foo.yaml")[0].GetConclusion())
+	assert.Equal(t, "cancelled", repo.Runs("bar.yaml")[0].GetConclusion())
+	assert.Equal(t, "queued", repo.Runs("baz.yaml")[0].GetStatus())
+	checkRuns := repo.CheckRuns("head-sha")
+	if assert.Len(t, checkRuns, 2) {
+		for _, checkRun := range checkRuns {
+			assert.Equal(t, "completed", checkRun.GetStatus())
+			assert.Equal(t, "cancelled", checkRun.GetConclusion())
+			assert.Equal(t, "Branch deleted", checkRun.GetOutput().GetTitle())
+		}
+	}
+	_, tracked := runChecks.get(runs["foo.yaml"])
+	assert.False(t, tracked)
+
+	// the runs cancelled already are not cancelled again
+	assert.NoError(t, handle("branch"))
+}
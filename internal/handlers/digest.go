@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/log"
+	"github.com/cilium/ariane/internal/notify"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+const defaultDigestTemplate = `{{ len .Failures }} workflow runs dispatched by Ariane failed since {{ .Since.UTC.Format "2006-01-02 15:04 MST" }}:
+{{ range .Failures }}
+- [{{ .Name }} #{{ .RunNumber }}]({{ .URL }}) on ` + "`{{ .Branch }}`" + `: {{ .Conclusion }}{{ end }}
+`
+
+// DigestData is passed to the digest template
+type DigestData struct {
+	Repository string
+	Since      time.Time
+	Failures   []FailedRun
+}
+
+// FailedRun is a failed run dispatched by Ariane, reported in the next digest
+type FailedRun struct {
+	Workflow    string
+	Name        string
+	RunNumber   int
+	URL         string
+	Branch      string
+	Conclusion  string
+	CompletedAt time.Time
+}
+
+// digestRepository holds the failed runs of a repository since the previous digest
+type digestRepository struct {
+	installationID int64
+	repository     *github.Repository
+	runs           []FailedRun
+}
+
+// FailureDigest collects the failed runs dispatched by Ariane until they are posted, by repository.
+// A nil FailureDigest is valid and collects nothing.
+type FailureDigest struct {
+	mu           sync.Mutex
+	repositories map[string]*digestRepository
+}
+
+func NewFailureDigest() *FailureDigest {
+	return &FailureDigest{repositories: map[string]*digestRepository{}}
+}
+
+// isFailedConclusion reports whether a completed run conclusion is worth reporting in the digest
+func isFailedConclusion(conclusion string) bool {
+	return checkConclusion(conclusion) == "failure" || conclusion == "timed_out"
+}
+
+// record adds a completed run to the digest of its repository, if it failed
+func (d *FailureDigest) record(installationID int64, repository *github.Repository, run *github.WorkflowRun) {
+	if d == nil || !isFailedConclusion(run.GetConclusion()) {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	digest, ok := d.repositories[repository.GetFullName()]
+	if !ok {
+		digest = &digestRepository{installationID: installationID, repository: repository}
+		d.repositories[repository.GetFullName()] = digest
+	}
+	digest.runs = append(digest.runs, FailedRun{
+		Workflow:    strings.TrimPrefix(run.GetPath(), ".github/workflows/"),
+		Name:        run.GetName(),
+		RunNumber:   run.GetRunNumber(),
+		URL:         run.GetHTMLURL(),
+		Branch:      run.GetHeadBranch(),
+		Conclusion:  run.GetConclusion(),
+		CompletedAt: run.GetUpdatedAt().Time,
+	})
+}
+
+// drain returns the collected failed runs, sorted by repository, and forgets them
+func (d *FailureDigest) drain() []digestRepository {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	repositories := make([]digestRepository, 0, len(d.repositories))
+	for _, digest := range d.repositories {
+		repositories = append(repositories, *digest)
+	}
+	d.repositories = map[string]*digestRepository{}
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].repository.GetFullName() < repositories[j].repository.GetFullName()
+	})
+	return repositories
+}
+
+// Digester periodically posts the failed runs dispatched by Ariane to the issue or Slack channel configured by
+// their repository, so CI triage does not need custom scripts
+type Digester struct {
+	githubapp.ClientCreator
+	// ConfigCache caches the Ariane configs fetched from repositories, if enabled
+	ConfigCache *config.Cache
+	Failures    *FailureDigest
+	// SlackWebhookURL is the Slack incoming webhook digests are posted to, for the repositories enabling it
+	SlackWebhookURL string
+	// SigningSecret signs the payloads posted to Slack, see notify.SignRequest
+	SigningSecret string
+	// HTTPClient posts to Slack, http.DefaultClient if nil
+	HTTPClient *http.Client
+	Scheduler  scheduler.Scheduler
+}
+
+// Run posts the digests every interval, until ctx is done or the returned function is called
+func (d *Digester) Run(ctx context.Context, interval time.Duration) context.CancelFunc {
+	return d.Scheduler.Every(ctx, interval, func(ctx context.Context) {
+		d.post(ctx, d.Scheduler.Now().Add(-interval))
+	})
+}
+
+// post posts the digest of each repository with failed runs since the previous one
+func (d *Digester) post(ctx context.Context, since time.Time) {
+	for _, digest := range d.Failures.drain() {
+		ctx, logger := githubapp.PrepareRepoContext(ctx, digest.installationID, digest.repository)
+		ctx = log.WithLogger(ctx, &logger)
+		if err := d.postRepository(ctx, digest, since, logger); err != nil {
+			logger.Error().Err(err).Msgf("Failed to post the digest of %d failed runs", len(digest.runs))
+		}
+	}
+}
+
+func (d *Digester) postRepository(ctx context.Context, digest digestRepository, since time.Time, logger zerolog.Logger) error {
+	client, err := d.NewInstallationClient(digest.installationID)
+	if err != nil {
+		return err
+	}
+	owner := digest.repository.GetOwner().GetLogin()
+	repo := digest.repository.GetName()
+	arianeConfig, err := getArianeConfig(ctx, d.ConfigCache, client, owner, repo, digest.repository.GetDefaultBranch())
+	if err != nil {
+		if failure.CategoryOf(err) == failure.ConfigError {
+			logger.Debug().Err(err).Msg("No valid Ariane config on the default branch, dropping digest")
+			return nil
+		}
+		return err
+	}
+
+	data := DigestData{Repository: digest.repository.GetFullName(), Since: since, Failures: digest.runs}
+	var errs []error
+	if arianeConfig.Digest.Issue > 0 {
+		body, err := renderTemplate(arianeConfig, "digest", arianeConfig.Digest.Template, defaultDigestTemplate, data)
+		if err != nil {
+			return err
+		}
+		// commenting on an issue requires the issues: write permission
+		if _, _, err := client.Issues.CreateComment(ctx, owner, repo, arianeConfig.Digest.Issue, &github.IssueComment{Body: &body}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to comment on issue #%d: %w", arianeConfig.Digest.Issue, err))
+		}
+	}
+	if arianeConfig.Digest.Slack {
+		if d.SlackWebhookURL == "" {
+			logger.Warn().Msg("Digest to Slack enabled but no Slack webhook is configured on the server")
+		} else if err := d.postSlack(ctx, slackDigest(data)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to post to Slack: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// slackDigest renders a digest as Slack mrkdwn, which does not support Markdown links
+func slackDigest(data DigestData) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*: %d workflow runs dispatched by Ariane failed since %s:", data.Repository, len(data.Failures), data.Since.UTC().Format("2006-01-02 15:04 MST"))
+	for _, run := range data.Failures {
+		fmt.Fprintf(&b, "\n• <%s|%s #%d> on `%s`: %s", run.URL, run.Name, run.RunNumber, run.Branch, run.Conclusion)
+	}
+	return b.String()
+}
+
+// postSlack posts a message to the Slack incoming webhook
+func (d *Digester) postSlack(ctx context.Context, text string) error {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.SlackWebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	notify.SignRequest(req, d.SigningSecret, payload)
+	httpClient := d.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	github "github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/notify"
+)
+
+func TestDigester(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+	configGetArianeConfigFromRepository = func(client *github.Client, ctx context.Context, owner, repoName, ref string) (*config.ArianeConfig, error) {
+		assert.Equal(t, "main", ref, "the config of the default branch is used")
+		return &config.ArianeConfig{Digest: config.DigestConfig{Issue: 12, Slack: true}}, nil
+	}
+
+	var comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/issues/12/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	var slackMessages []string
+	slackServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.True(t, notify.Verify("secret", body, r.Header.Get(notify.SignatureHeader)), "the payload is signed")
+		var message struct{ Text string }
+		_ = json.Unmarshal(body, &message)
+		slackMessages = append(slackMessages, message.Text)
+	}))
+	defer slackServer.Close()
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(1)).Return(client, nil).AnyTimes()
+
+	digester := &Digester{
+		ClientCreator:   mockClientCreator,
+		Failures:        NewFailureDigest(),
+		SlackWebhookURL: slackServer.URL,
+		SigningSecret:   "secret",
+	}
+	repository := &github.Repository{
+		FullName:      github.String("owner/repo"),
+		Name:          github.String("repo"),
+		Owner:         &github.User{Login: github.String("owner")},
+		DefaultBranch: github.String("main"),
+	}
+	run := func(runNumber int, conclusion string) *github.WorkflowRun {
+		return &github.WorkflowRun{
+			Name:       github.String("E2E"),
+			RunNumber:  github.Int(runNumber),
+			HTMLURL:    github.String("https://github.com/owner/repo/actions/runs/1"),
+			HeadBranch: github.String("pr/feature"),
+			Conclusion: github.String(conclusion),
+		}
+	}
+	digester.Failures.record(1, repository, run(1, "success"))
+	digester.Failures.record(1, repository, run(2, "failure"))
+	digester.Failures.record(1, repository, run(3, "timed_out"))
+
+	since := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	digester.post(context.Background(), since)
+	assert.Equal(t, []string{`2 workflow runs dispatched by Ariane failed since 2024-01-02 03:04 UTC:
+
+- [E2E #2](https://github.com/owner/repo/actions/runs/1) on ` + "`pr/feature`" + `: failure
+- [E2E #3](https://github.com/owner/repo/actions/runs/1) on ` + "`pr/feature`" + `: timed_out
+`}, comments, "successful runs are not reported")
+	assert.Equal(t, []string{"*owner/repo*: 2 workflow runs dispatched by Ariane failed since 2024-01-02 03:04 UTC:" +
+		"\n• <https://github.com/owner/repo/actions/runs/1|E2E #2> on `pr/feature`: failure" +
+		"\n• <https://github.com/owner/repo/actions/runs/1|E2E #3> on `pr/feature`: timed_out"}, slackMessages)
+
+	// failed runs are only reported once, and nothing is posted without failed runs
+	digester.post(context.Background(), since)
+	assert.Len(t, comments, 1)
+	assert.Len(t, slackMessages, 1)
+}
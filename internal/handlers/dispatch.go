@@ -0,0 +1,455 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/metrics"
+	"github.com/cilium/ariane/internal/poll"
+)
+
+const (
+	DefaultDispatchVerifyTimeout = time.Minute
+
+	// maxDispatchInputs and maxDispatchInputsSize are the limits of GitHub on the inputs of a workflow_dispatch event,
+	// see https://docs.github.com/en/actions/writing-workflows/workflow-syntax-for-github-actions#onworkflow_dispatchinputs
+	maxDispatchInputs     = 10
+	maxDispatchInputsSize = 65535
+
+	// runLinkCheckPrefix prefixes the name of the check runs linking to dispatched workflow runs
+	runLinkCheckPrefix = "Ariane / "
+)
+
+var errRunNotFound = errors.New("dispatched workflow run not found")
+
+var configDriftTotal = metrics.NewCounterVec("ariane_config_drift_total",
+	"Workflows of the config triggers which GitHub did not find when dispatching them, by repository and workflow.",
+	"repository", "workflow")
+
+// checkName returns the name of a check run, or commit status, Ariane creates for a workflow, prefixed with the check
+// namespace of its trigger, if any, see config.TriggerConfig.CheckNamespace
+func checkName(checkNamespace, name string) string {
+	if checkNamespace == "" {
+		return name
+	}
+	return checkNamespace + " / " + name
+}
+
+// checkNamespaceOf returns the check namespace of a check run, or commit status, Ariane named after a workflow
+func checkNamespaceOf(name, workflowName string) string {
+	namespace, _ := strings.CutSuffix(name, " / "+workflowName)
+	if namespace == name {
+		return ""
+	}
+	return namespace
+}
+
+// dispatchedRun identifies a workflow_dispatch event sent by Ariane
+type dispatchedRun struct {
+	workflow string
+	ref      string
+	SHA      string
+	// marker is the run marker passed to the dispatched workflow, if enabled
+	marker       string
+	dispatchedAt time.Time
+	// queuedCheck is the check run created at dispatch time, if any
+	queuedCheck *trackedCheck
+	// runLinks collects the run once found, to reply with links to the runs of the trigger comment
+	runLinks *runLinks
+	// poolSlot is the slot of the workflow resource pool held until the run completes, if the pool is limited
+	poolSlot *poolSlot
+	// checkNamespace prefixes the name of the check run linking to the run, instead of runLinkCheckPrefix, if set
+	checkNamespace string
+}
+
+// validateDispatchInputs checks the inputs of a workflow_dispatch event against the limits of GitHub, which
+// would otherwise reject the event, so the author of the trigger comment can be told why.
+func validateDispatchInputs(inputs map[string]interface{}) decision.Decision {
+	if len(inputs) > maxDispatchInputs {
+		return decision.No(decision.ReasonTooManyInputs, "%d inputs exceed the limit of %d inputs", len(inputs), maxDispatchInputs)
+	}
+	payload, err := json.Marshal(inputs)
+	if err != nil {
+		return decision.No(decision.ReasonInputsTooLarge, "inputs cannot be encoded: %v", err)
+	}
+	if len(payload) > maxDispatchInputsSize {
+		return decision.No(decision.ReasonInputsTooLarge, "inputs of %d characters exceed the limit of %d characters", len(payload), maxDispatchInputsSize)
+	}
+	return decision.Yes(decision.ReasonInputsValid, "%d inputs of %d characters", len(inputs), len(payload))
+}
+
+// declaredInputs checks the inputs of a dispatch against the workflow_dispatch inputs each workflow declares at ref,
+// as GitHub rejects dispatches with undeclared inputs. It returns the inputs to dispatch each workflow with, without
+// the undeclared ones, unless the config rejects them. Workflows which cannot be looked up are left to dispatching
+// to report the error.
+func declaredInputs(ctx context.Context, cache *WorkflowCache, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo, ref string, workflows []string, inputs map[string]interface{}, logger zerolog.Logger) (map[string]map[string]interface{}, decision.Decision) {
+	workflowInputs := map[string]map[string]interface{}{}
+	var dropped []string
+	for _, workflow := range workflows {
+		file, err := cache.getWorkflowFile(ctx, client, owner, repo, workflow, ref)
+		if err != nil {
+			logger.Warn().Err(err).Msgf("Failed to look up the inputs of workflow %s", workflow)
+			continue
+		}
+		declared := map[string]interface{}{}
+		var undeclared []string
+		for input, value := range inputs {
+			if file.inputs[input] {
+				declared[input] = value
+			} else {
+				undeclared = append(undeclared, input)
+			}
+		}
+		if len(undeclared) == 0 {
+			continue
+		}
+		sort.Strings(undeclared)
+		if arianeConfig.UndeclaredInputs == config.UndeclaredInputsReject {
+			return nil, decision.No(decision.ReasonUndeclaredInputs, "workflow %s does not declare the inputs %s", workflow, strings.Join(undeclared, ", "))
+		}
+		workflowInputs[workflow] = declared
+		dropped = append(dropped, fmt.Sprintf("%s from %s", strings.Join(undeclared, ", "), workflow))
+	}
+	if len(dropped) > 0 {
+		return workflowInputs, decision.Yes(decision.ReasonUndeclaredInputsDropped, "undeclared inputs dropped: %s", strings.Join(dropped, "; "))
+	}
+	return workflowInputs, decision.Yes(decision.ReasonInputsDeclared, "the workflows declare all the inputs")
+}
+
+// withPathInputs returns the inputs of the dispatch of a workflow with the inputs set by its path-inputs rules for
+// the changed files. The inputs the dispatch sets already, e.g. PR-number, are left as is.
+func withPathInputs(arianeConfig *config.ArianeConfig, workflow string, files []*github.CommitFile, inputs map[string]interface{}, logger zerolog.Logger) map[string]interface{} {
+	pathInputs := arianeConfig.PathInputs(workflow, files)
+	if len(pathInputs) == 0 {
+		return inputs
+	}
+	// the inputs are shared by the workflows of the trigger
+	inputs = maps.Clone(inputs)
+	if inputs == nil {
+		inputs = map[string]interface{}{}
+	}
+	for input, value := range pathInputs {
+		if _, ok := inputs[input]; ok {
+			logger.Warn().Msgf("Ignoring path input %s, set by the dispatch already", input)
+			continue
+		}
+		inputs[input] = value
+	}
+	return inputs
+}
+
+// pathDispatchInputs merges the inputs set by the path-inputs rules of each workflow into the inputs of its dispatch,
+// and checks them like the inputs of the trigger: against the limits of GitHub, and against the inputs the workflow
+// declares, if enabled. It returns the inputs to dispatch the workflows changed by their rules with, or a decision
+// refusing the trigger.
+func (h *PRCommentHandler) pathDispatchInputs(ctx context.Context, t triggerDispatch, files []*github.CommitFile) (map[string]map[string]interface{}, decision.Decision) {
+	workflowInputs := map[string]map[string]interface{}{}
+	for _, workflow := range t.workflows {
+		inputs := t.event.Inputs
+		if declared, ok := t.workflowInputs[workflow]; ok {
+			inputs = declared
+		}
+		// the path inputs only add to the inputs of the dispatch
+		merged := withPathInputs(t.arianeConfig, workflow, files, inputs, t.logger)
+		if len(merged) == len(inputs) {
+			continue
+		}
+		if valid := validateDispatchInputs(merged); !valid.Result {
+			return nil, decision.No(valid.Reason, "with the path inputs of workflow %s, %s", workflow, valid.Message)
+		}
+		if t.arianeConfig.UndeclaredInputs != "" {
+			filtered, declared := declaredInputs(ctx, h.Workflows, t.client, t.arianeConfig, t.owner, t.repo, t.contextRef, []string{workflow}, merged, t.logger)
+			if !declared.Result {
+				return nil, declared
+			}
+			if inputs, ok := filtered[workflow]; ok {
+				merged = inputs
+			}
+		}
+		workflowInputs[workflow] = merged
+	}
+	return workflowInputs, decision.Yes(decision.ReasonInputsValid, "path inputs set for %d workflows", len(workflowInputs))
+}
+
+// dispatchFailure explains why dispatching a workflow failed, telling what to check when GitHub denied it
+func dispatchFailure(displayName string, err error) string {
+	if failure.CategoryOf(err) != failure.PermissionDenied {
+		return fmt.Sprintf("Dispatching `%s` failed.", displayName)
+	}
+	reason := err.Error()
+	var response *github.ErrorResponse
+	if errors.As(err, &response) && response.Message != "" {
+		reason = response.Message
+	}
+	return fmt.Sprintf("GitHub denied dispatching `%s`: %s.\n\n"+
+		"Check that GitHub Actions are enabled in the repository settings, that the workflow is not disabled, "+
+		"and that the Ariane app is granted the `actions: write` permission.", displayName, strings.TrimSuffix(reason, "."))
+}
+
+// failDeniedDispatch completes the queued check run of a workflow whose dispatch GitHub denied, or creates one on SHA,
+// with a failure explaining what to check, so the problem shows on the PR rather than only in the logs
+func failDeniedDispatch(ctx context.Context, workflows *WorkflowCache, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo, workflow, SHA, checkNamespace string, queuedCheck *trackedCheck, dispatchErr error, logger zerolog.Logger) {
+	title := "Dispatch denied"
+	summary := dispatchFailure(arianeConfig.DisplayName(workflow), dispatchErr)
+	output := &github.CheckRunOutput{Title: &title, Summary: &summary}
+	if queuedCheck != nil && queuedCheck.commitStatus {
+		if err := createStatus(ctx, client, owner, repo, queuedCheck.SHA, queuedCheck.name, "failure", summary, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to complete pending commit status")
+		}
+		return
+	}
+	if queuedCheck != nil {
+		_, _, err := client.Checks.UpdateCheckRun(ctx, owner, repo, queuedCheck.checkRunID, github.UpdateCheckRunOptions{
+			Name:       queuedCheck.name,
+			Status:     github.String("completed"),
+			Conclusion: github.String("failure"),
+			Output:     output,
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to complete queued check run")
+		}
+		return
+	}
+
+	// name the check run like the runs of the workflow, as the queued check runs
+	name := arianeConfig.DisplayName(workflow)
+	if githubWorkflow, err := workflows.getWorkflow(ctx, client, owner, repo, workflow); err == nil {
+		name = githubWorkflow.GetName()
+	}
+	name = checkName(checkNamespace, name)
+	if arianeConfig.ReportsStatuses() {
+		if err := createStatus(ctx, client, owner, repo, SHA, name, "failure", summary, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to create dispatch denied commit status")
+		}
+		return
+	}
+	_, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       name,
+		HeadSHA:    SHA,
+		ExternalID: github.String("dispatch/" + workflow),
+		Status:     github.String("completed"),
+		Conclusion: github.String("failure"),
+		Output:     output,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create dispatch denied check run")
+	}
+}
+
+// isWorkflowNotFound reports whether GitHub answered a dispatch with 404 Not Found, the workflow file having been
+// deleted or renamed since the config triggering it was written
+func isWorkflowNotFound(err error) bool {
+	var response *github.ErrorResponse
+	return errors.As(err, &response) && response.Response != nil && response.Response.StatusCode == http.StatusNotFound
+}
+
+// workflowNotFound is the decision skipping a workflow which GitHub did not find when dispatching it
+func workflowNotFound(workflow string) decision.Decision {
+	return decision.No(decision.ReasonWorkflowNotFound, "workflow %s was not found in the repository, it may have been deleted or renamed", workflow)
+}
+
+// skipMissingWorkflow completes the queued check run of a workflow which GitHub did not find when dispatching it, or
+// creates one on SHA, as skipped with a note asking to update the config, and records the config drift. Failing the
+// trigger instead would hold back the other workflows of the config for everyone until the config is fixed.
+func skipMissingWorkflow(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo, workflow, SHA, checkNamespace string, queuedCheck *trackedCheck, reason decision.Decision, logger zerolog.Logger) {
+	logger.Warn().Str("workflow", workflow).Msg("Workflow of the config triggers not found, the config drifted from the workflow files")
+	audit.Event(ctx, "config_drift").Str("workflow", workflow).Object("decision", reason).Send()
+	configDriftTotal.Inc(owner+"/"+repo, workflow)
+
+	title := skippedCheckTitle
+	summary := fmt.Sprintf("%s was skipped: %s (`%s`).\n\n"+
+		"Update or remove `%s` in the triggers of `.github/ariane-config.yaml`.", arianeConfig.DisplayName(workflow), reason.Message, reason.Reason, workflow)
+	description := fmt.Sprintf("%s%s (%s)", skippedStatusPrefix, reason.Message, reason.Reason)
+	output := &github.CheckRunOutput{Title: &title, Summary: &summary}
+	if queuedCheck != nil && queuedCheck.commitStatus {
+		if err := createStatus(ctx, client, owner, repo, queuedCheck.SHA, queuedCheck.name, "success", description, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to complete pending commit status")
+		}
+		return
+	}
+	if queuedCheck != nil {
+		_, _, err := client.Checks.UpdateCheckRun(ctx, owner, repo, queuedCheck.checkRunID, github.UpdateCheckRunOptions{
+			Name:       queuedCheck.name,
+			Status:     github.String("completed"),
+			Conclusion: github.String("skipped"),
+			Output:     output,
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to complete queued check run")
+		}
+		return
+	}
+
+	// the workflow cannot be looked up for its name anymore
+	name := checkName(checkNamespace, arianeConfig.DisplayName(workflow))
+	if arianeConfig.ReportsStatuses() {
+		if err := createStatus(ctx, client, owner, repo, SHA, name, "success", description, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to set commit status")
+		}
+		return
+	}
+	// the skipped workflows are reported in the summary comment instead, see denyChecks
+	if lacksChecksPermission(owner, repo) {
+		return
+	}
+	_, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       name,
+		HeadSHA:    SHA,
+		ExternalID: github.String(skippedExternalID(workflow)),
+		Status:     github.String("completed"),
+		Conclusion: github.String("skipped"),
+		Output:     output,
+	})
+	if err != nil && !denyChecks(owner, repo, err, logger) {
+		logger.Error().Err(err).Msg("Failed to set check run")
+	}
+}
+
+// verifyDispatch polls the runs of a workflow until the run created by the given dispatch shows up, see findDispatchedRun
+func (h *PRCommentHandler) verifyDispatch(ctx context.Context, client *github.Client, owner, repo string, dispatch dispatchedRun) (*github.WorkflowRun, error) {
+	return findDispatchedRun(ctx, h.poller(h.settings(owner, repo).DispatchVerifyTimeout), client, owner, repo, dispatch)
+}
+
+// findDispatchedRun polls the runs of a workflow until the run created by the given dispatch shows up.
+// workflow_dispatch does not return the created run, so the newest run for the dispatched ref created
+// after the dispatch is assumed to be the one, unless the dispatch passed a run marker which the run shows.
+func findDispatchedRun(ctx context.Context, poller poll.Poller, client *github.Client, owner, repo string, dispatch dispatchedRun) (*github.WorkflowRun, error) {
+	runListOpts := &github.ListWorkflowRunsOptions{
+		Event:   "workflow_dispatch",
+		Branch:  dispatch.ref,
+		Created: ">=" + dispatch.dispatchedAt.Add(-5*time.Second).UTC().Format(time.RFC3339),
+		// most recent runs come first, other runs may have been dispatched since
+		ListOptions: github.ListOptions{PerPage: 10},
+	}
+	// the newest run may have been dispatched by anyone
+	if dispatch.marker == "" && dispatch.SHA == "" {
+		return nil, errRunNotFound
+	}
+	var run *github.WorkflowRun
+	err := poller.Until(ctx, func(ctx context.Context) (bool, error) {
+		runs, _, err := client.Actions.ListWorkflowRunsByFileName(ctx, owner, repo, dispatch.workflow, runListOpts)
+		if err != nil {
+			return false, err
+		}
+		for _, candidate := range runs.WorkflowRuns {
+			if isDispatchedRun(candidate, dispatch) {
+				run = candidate
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		if errors.Is(err, poll.ErrTimeout) || ctx.Err() != nil {
+			return nil, errRunNotFound
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+// isDispatchedRun reports whether a run is the one created by a dispatch: the run showing its marker if enabled, or
+// else a run of its SHA, which the run-name of the workflow may show when the dispatched ref points elsewhere
+func isDispatchedRun(run *github.WorkflowRun, dispatch dispatchedRun) bool {
+	if dispatch.marker != "" {
+		return strings.Contains(run.GetDisplayTitle(), dispatch.marker)
+	}
+	return run.GetHeadSHA() == dispatch.SHA || strings.Contains(run.GetDisplayTitle(), dispatch.SHA)
+}
+
+// linkDispatchedRun waits for the run created by a dispatch, and creates or updates a check run on the PR head SHA
+// linking to it, since workflow_dispatch runs are not associated with the PR otherwise.
+func (h *PRCommentHandler) linkDispatchedRun(ctx context.Context, client *github.Client, owner, repo string, dispatch dispatchedRun, logger zerolog.Logger) error {
+	run, err := h.verifyDispatch(ctx, client, owner, repo, dispatch)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to find the run dispatched for workflow %s", dispatch.workflow)
+		// the run would not be seen completing either
+		h.Pools.release(dispatch.poolSlot)
+		if dispatch.queuedCheck != nil {
+			// the context is likely expired by now
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+			defer cancel()
+			abandonQueuedCheck(ctx, client, *dispatch.queuedCheck, "neutral", fmt.Sprintf("The run dispatched for `%s` could not be found.", dispatch.workflow), logger)
+		}
+		return err
+	}
+	logger.Debug().Msgf("Workflow %s dispatched as run %d", dispatch.workflow, run.GetID())
+	dispatch.runLinks.found(dispatch.workflow, run)
+	if run.GetStatus() == "completed" {
+		h.Pools.release(dispatch.poolSlot)
+	} else {
+		h.Pools.bind(dispatch.poolSlot, run.GetID())
+	}
+
+	// the queued check run follows the run from now on, instead of linking it from a separate check run
+	if dispatch.queuedCheck != nil {
+		h.RunChecks.add(run.GetID(), *dispatch.queuedCheck)
+		if err := updateCheckFromRun(ctx, client, *dispatch.queuedCheck, run); err != nil {
+			logger.Error().Err(err).Msg("Failed to update queued check run")
+			return err
+		}
+		return nil
+	}
+
+	name := runLinkCheckPrefix + run.GetName()
+	if dispatch.checkNamespace != "" {
+		name = checkName(dispatch.checkNamespace, run.GetName())
+	}
+	externalID := "run-link/" + dispatch.workflow
+	title := "Workflow run dispatched"
+	summary := fmt.Sprintf("[%s #%d](%s) was dispatched on `%s`.", run.GetName(), run.GetRunNumber(), run.GetHTMLURL(), dispatch.ref)
+	output := &github.CheckRunOutput{Title: &title, Summary: &summary}
+
+	checkRuns, _, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, dispatch.SHA, &github.ListCheckRunsOptions{CheckName: &name})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list check runs")
+		return err
+	}
+	for _, checkRun := range checkRuns.CheckRuns {
+		if checkRun.GetExternalID() != externalID {
+			continue
+		}
+		_, _, err := client.Checks.UpdateCheckRun(ctx, owner, repo, checkRun.GetID(), github.UpdateCheckRunOptions{
+			Name:       name,
+			DetailsURL: run.HTMLURL,
+			Status:     github.String("completed"),
+			Conclusion: github.String("neutral"),
+			Output:     output,
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to update run link check run")
+		}
+		return err
+	}
+
+	_, _, err = client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       name,
+		HeadSHA:    dispatch.SHA,
+		DetailsURL: run.HTMLURL,
+		ExternalID: &externalID,
+		Status:     github.String("completed"),
+		Conclusion: github.String("neutral"),
+		Output:     output,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create run link check run")
+	}
+	return err
+}
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+const defaultDryRunMessage = `@{{ .Author }} dry run of ` + "`{{ .Command }}`" + `, nothing was dispatched:
+{{ range .Plan }}
+- {{ name .Workflow }}: would {{ .Action }}, as {{ if .Run }}{{ .Run.Message }}{{ else }}{{ .Skip.Message }}{{ end }}{{ end }}
+`
+
+// previewPlan replies to a trigger comment ending with --dry-run with what dispatching its workflows would do,
+// without dispatching nor re-running anything. Anyone can preview the plan of a trigger, whatever their membership.
+func (h *PRCommentHandler) previewPlan(ctx context.Context, t triggerDispatch, command string) error {
+	plan, err := h.planWorkflows(ctx, t)
+	if err != nil {
+		return err
+	}
+	actions := make([]string, len(plan))
+	for i, workflow := range plan {
+		actions[i] = workflow.Workflow + ":" + workflow.Action
+	}
+	audit.Event(ctx, "trigger_previewed").Str("author", t.commentAuthor).Strs("plan", actions).Send()
+	data := MessageData{Author: t.commentAuthor, Command: command, Plan: plan}
+	return h.postMessage(ctx, t.client, t.arianeConfig, t.owner, t.repo, t.prNumber, "dry-run", t.arianeConfig.Messages.DryRun, defaultDryRunMessage, data, t.logger)
+}
+
+// planWorkflows decides what dispatchWorkflows would do with each workflow of a trigger comment, in the same order,
+// without side effects
+func (h *PRCommentHandler) planWorkflows(ctx context.Context, t triggerDispatch) ([]decision.WorkflowPlan, error) {
+	client, arianeConfig, logger := t.client, t.arianeConfig, t.logger
+
+	var files []*github.CommitFile
+	if !t.isIssue {
+		var err error
+		files, err = getChangedFiles(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, t.baseSHA, t.SHA, t.settings.Pagination.WithDefaults().Files, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	large, allowed := h.checkLargeDispatch(t, files)
+	policyAllowed, policyExcluded, err := h.checkPolicy(ctx, t, files)
+	if err != nil {
+		return nil, err
+	}
+
+	var extraArgs string
+	if len(t.submatch) > 1 {
+		extraArgs = t.submatch[1]
+	}
+
+	plan := make([]decision.WorkflowPlan, 0, len(t.workflows))
+	for _, workflow := range t.workflows {
+		// the trigger comment would be refused without --force
+		if !allowed {
+			plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Skip: large, Action: decision.ActionSkip})
+			continue
+		}
+		// the workflows denied by the dispatch policy
+		if !policyAllowed.Result {
+			plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Skip: policyAllowed, Action: decision.ActionSkip})
+			continue
+		}
+		if excluded, ok := policyExcluded[workflow]; ok {
+			plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Skip: excluded, Action: decision.ActionSkip})
+			continue
+		}
+		limited := h.checkRetries(ctx, t.owner, t.repo, workflow, t.SHA, arianeConfig.RetryLimit)
+		if !t.isIssue && t.contextOverride == "" {
+			run, previous := h.previousRun(ctx, client, t.owner, t.repo, workflow, t.SHA, logger)
+			if previous.Result {
+				plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Skip: previous, Action: decision.ActionSkip})
+				continue
+			}
+			if previous.Reason == decision.ReasonPreviousRunFailed && decision.CanRerun(run, h.Scheduler.Now()) && h.Capabilities.rerunWorkflow() && !limited.Result {
+				plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Skip: decision.Rerun(workflow, t.SHA, run), Action: decision.ActionRerun})
+				continue
+			}
+		}
+
+		if t.tag == "" && !t.isIssue && t.contextOverride == "" {
+			idempotencyKey, err := arianeConfig.IdempotencyKey(workflow, files, extraArgs, t.args)
+			if err != nil {
+				logger.Error().Err(err).Str("workflow", workflow).Msg("Failed to render idempotency key")
+			}
+			if idempotencyKey != "" {
+				if skip := h.checkIdempotency(ctx, client, t.owner, t.repo, workflow, idempotencyKey, logger); skip.Result {
+					plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Skip: skip, Action: decision.ActionSkip})
+					continue
+				}
+			}
+		}
+
+		var run decision.Decision
+		switch {
+		case t.tag != "":
+			run = decision.Yes(decision.ReasonTagTrigger, "workflow %s is dispatched on tag %s", workflow, t.tag)
+		case t.isIssue:
+			run = decision.Yes(decision.ReasonIssueTrigger, "workflow %s is dispatched on %s for issue #%d", workflow, t.contextRef, t.prNumber)
+		case large.Result:
+			run = largePRRun(workflow, large)
+		default:
+			run = ignorePathsFilter(t, workflow, h.shouldRunWorkflow(ctx, arianeConfig, workflow, files))
+		}
+		if run.Result && limited.Result {
+			plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Skip: limited, Action: decision.ActionSkip})
+			continue
+		}
+		action := decision.ActionSkip
+		if run.Result {
+			action = decision.ActionDispatch
+		}
+		plan = append(plan, decision.WorkflowPlan{Workflow: workflow, Run: &run, Action: action})
+	}
+	return plan, nil
+}
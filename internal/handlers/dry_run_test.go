@@ -0,0 +1,87 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_previewPlan(t *testing.T) {
+	var comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls/1/files", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.CommitFile{{Filename: github.Ptr("docs/README.md")}})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}/runs", func(w http.ResponseWriter, r *http.Request) {
+		runs := &github.WorkflowRuns{}
+		if r.PathValue("workflow") == "done.yaml" {
+			runs.WorkflowRuns = []*github.WorkflowRun{{ID: github.Ptr(int64(1)), Status: github.Ptr("completed"), Conclusion: github.Ptr("success"), CreatedAt: &github.Timestamp{Time: time.Now()}}}
+		}
+		runs.TotalCount = github.Ptr(len(runs.WorkflowRuns))
+		_ = json.NewEncoder(w).Encode(runs)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/{workflow}/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("nothing is dispatched on dry runs")
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	arianeConfig := &config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"src.yaml":  {PathsRegex: "src/"},
+			"docs.yaml": {PathsRegex: "docs/"},
+		},
+	}
+	dispatch := triggerDispatch{
+		client:        client,
+		arianeConfig:  arianeConfig,
+		settings:      config.RepositorySettings{Pagination: config.DefaultPagination},
+		owner:         "owner",
+		repo:          "repo",
+		prNumber:      1,
+		commentAuthor: "contributor",
+		contextRef:    "main",
+		SHA:           "mock-sha",
+		workflows:     []string{"done.yaml", "src.yaml", "docs.yaml"},
+		logger:        zerolog.Nop(),
+	}
+
+	plan, err := handler.planWorkflows(context.Background(), dispatch)
+	assert.NoError(t, err)
+	assert.Len(t, plan, 3)
+	assert.Equal(t, decision.ActionSkip, plan[0].Action)
+	assert.Equal(t, decision.ReasonPreviousRunSucceeded, plan[0].Skip.Reason)
+	assert.Equal(t, decision.ActionSkip, plan[1].Action)
+	assert.Equal(t, decision.ReasonPathsNotMatched, plan[1].Run.Reason)
+	assert.Equal(t, decision.ActionDispatch, plan[2].Action)
+
+	assert.NoError(t, handler.previewPlan(context.Background(), dispatch, "/test"))
+	assert.Len(t, comments, 1)
+	assert.Contains(t, comments[0], "@contributor dry run of `/test`, nothing was dispatched:\n\n- done.yaml: would skip, as ")
+	assert.Contains(t, comments[0], "- src.yaml: would skip, as no changed file matches paths-regex \"src/\"")
+	assert.Contains(t, comments[0], "- docs.yaml: would dispatch, as ")
+}
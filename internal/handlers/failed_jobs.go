@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+)
+
+const defaultFailedJobsMessage = `{{ range .Runs }}:x: {{ name .Workflow }}: [{{ .Name }} #{{ .RunNumber }}]({{ .URL }}) failed{{ end }}, failed jobs:
+{{ range .FailedJobs }}
+- [{{ .Name }}]({{ .URL }}){{ end }}
+`
+
+// JobLink links to the logs of a failed job of a run dispatched by Ariane
+type JobLink struct {
+	Name string
+	URL  string
+}
+
+// failedJobsMarker is a hidden marker identifying the failed jobs of a run appended to a summary, so they are only
+// appended once if the workflow_run event is delivered again
+func failedJobsMarker(runID int64) string {
+	return fmt.Sprintf("<!-- ariane-failed-jobs run=%d -->", runID)
+}
+
+// appendFailedJobs appends the failed jobs of a failed run dispatched by Ariane, with links to their logs, to the
+// latest summary of the summary comment of the open PRs whose head is the run head SHA, if failed-jobs is set.
+// PRs without a summary comment are left alone.
+func (h *PRCommentHandler) appendFailedJobs(ctx context.Context, client *github.Client, repository *github.Repository, run *github.WorkflowRun, logger zerolog.Logger) error {
+	owner := repository.GetOwner().GetLogin()
+	repo := repository.GetName()
+
+	arianeConfig, err := getArianeConfigOrDefault(ctx, h.ConfigCache, client, repository, run.GetHeadBranch(), logger)
+	if err != nil {
+		return fmt.Errorf("failed to get config: %w", err)
+	}
+	if !arianeConfig.FailedJobs {
+		return nil
+	}
+
+	prs, _, err := client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, run.GetHeadSHA(), &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return fmt.Errorf("failed to list pull requests of %s: %w", run.GetHeadSHA(), err)
+	}
+	var prNumbers []int
+	for _, pr := range prs {
+		if pr.GetState() == "open" && pr.GetHead().GetSHA() == run.GetHeadSHA() {
+			prNumbers = append(prNumbers, pr.GetNumber())
+		}
+	}
+	if len(prNumbers) == 0 {
+		return nil
+	}
+
+	jobs, _, err := client.Actions.ListWorkflowJobs(ctx, owner, repo, run.GetID(), &github.ListWorkflowJobsOptions{Filter: "latest", ListOptions: github.ListOptions{PerPage: 100}})
+	if err != nil {
+		return fmt.Errorf("failed to list jobs of run %d: %w", run.GetID(), err)
+	}
+	data := MessageData{
+		Runs: []RunLink{{Workflow: path.Base(run.GetPath()), Name: run.GetName(), RunNumber: run.GetRunNumber(), URL: run.GetHTMLURL()}},
+	}
+	for _, job := range jobs.Jobs {
+		if isFailedConclusion(job.GetConclusion()) {
+			data.FailedJobs = append(data.FailedJobs, JobLink{Name: job.GetName(), URL: job.GetHTMLURL()})
+		}
+	}
+	if len(data.FailedJobs) == 0 {
+		return nil
+	}
+	body, err := renderTemplate(arianeConfig, "failed-jobs", arianeConfig.Messages.FailedJobs, defaultFailedJobsMessage, data)
+	if err != nil {
+		return fmt.Errorf("failed to render failed-jobs message template: %w", err)
+	}
+	marker := failedJobsMarker(run.GetID())
+
+	for _, prNumber := range prNumbers {
+		summary, err := h.findSummaryComment(ctx, client, owner, repo, prNumber)
+		if err != nil {
+			return fmt.Errorf("failed to list comments of PR %d: %w", prNumber, err)
+		}
+		if summary == nil || strings.Contains(summary.GetBody(), marker) {
+			continue
+		}
+		summaries := parseSummaries(summary.GetBody())
+		if len(summaries) == 0 {
+			continue
+		}
+		summaries[0] += "\n\n" + marker + "\n" + strings.TrimSpace(body)
+		comment := &github.IssueComment{Body: github.String(renderSummaries(summaries))}
+		if _, _, err := client.Issues.EditComment(ctx, owner, repo, summary.GetID(), comment); err != nil {
+			return fmt.Errorf("failed to edit summary comment of PR %d: %w", prNumber, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_appendFailedJobs(t *testing.T) {
+	summary := renderSummaries([]string{"latest", "previous"})
+	var edited []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/commits/{sha}/pulls", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.PullRequest{
+			{Number: github.Int(1), State: github.String("open"), Head: &github.PullRequestBranch{SHA: github.String("sha")}},
+			// the SHA is no longer the head of the PR
+			{Number: github.Int(2), State: github.String("open"), Head: &github.PullRequestBranch{SHA: github.String("other")}},
+		})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/runs/4/jobs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "latest", r.URL.Query().Get("filter"))
+		_ = json.NewEncoder(w).Encode(&github.Jobs{
+			TotalCount: github.Int(2),
+			Jobs: []*github.WorkflowJob{
+				{Name: github.String("build"), Conclusion: github.String("success"), HTMLURL: github.String("https://github.com/owner/repo/actions/runs/4/job/1")},
+				{Name: github.String("test (amd64)"), Conclusion: github.String("failure"), HTMLURL: github.String("https://github.com/owner/repo/actions/runs/4/job/2")},
+			},
+		})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		body := summary
+		if len(edited) > 0 {
+			body = edited[len(edited)-1]
+		}
+		_ = json.NewEncoder(w).Encode([]*github.IssueComment{{ID: github.Int64(5), Body: github.String(body)}})
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/issues/comments/5", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		edited = append(edited, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{ConfigCache: config.NewCache(time.Minute)}
+	repository := &github.Repository{Name: github.String("repo"), Owner: &github.User{Login: github.String("owner")}}
+	run := &github.WorkflowRun{
+		ID:         github.Int64(4),
+		Name:       github.String("Foo"),
+		Path:       github.String(".github/workflows/foo.yaml"),
+		RunNumber:  github.Int(42),
+		HeadBranch: github.String("main"),
+		HeadSHA:    github.String("sha"),
+		Conclusion: github.String("failure"),
+		HTMLURL:    github.String("https://github.com/owner/repo/actions/runs/4"),
+	}
+
+	// disabled by default
+	handler.ConfigCache.Set("owner", "repo", "main", &config.ArianeConfig{})
+	assert.NoError(t, handler.appendFailedJobs(context.Background(), client, repository, run, zerolog.Nop()))
+	assert.Empty(t, edited)
+
+	handler.ConfigCache.Set("owner", "repo", "main", &config.ArianeConfig{FailedJobs: true})
+	assert.NoError(t, handler.appendFailedJobs(context.Background(), client, repository, run, zerolog.Nop()))
+	if assert.Len(t, edited, 1) {
+		summaries := parseSummaries(edited[0])
+		assert.Equal(t, []string{
+			"latest\n\n" + failedJobsMarker(4) + "\n" +
+				":x: foo.yaml: [Foo #42](https://github.com/owner/repo/actions/runs/4) failed, failed jobs:\n\n" +
+				"- [test (amd64)](https://github.com/owner/repo/actions/runs/4/job/2)",
+			"previous",
+		}, summaries)
+	}
+
+	// redelivered events do not append the failed jobs again
+	assert.NoError(t, handler.appendFailedJobs(context.Background(), client, repository, run, zerolog.Nop()))
+	assert.Len(t, edited, 1)
+}
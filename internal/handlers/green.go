@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	gocache "github.com/patrickmn/go-cache"
+)
+
+const (
+	DefaultGreenExpiry = 30 * 24 * time.Hour
+)
+
+const defaultLastGreenMessage = `@{{ .Author }} {{ with .Green }}the last commit of this pull request for which all the workflows run by Ariane succeeded is {{ .SHA }}: {{ join (names .Workflows) ", " }}.` +
+	`{{ else }}no commit of this pull request is known to have passed all the workflows run by Ariane.{{ end }}`
+
+// GreenSHA is the last head SHA of a pull request for which all the workflows dispatched by Ariane succeeded
+type GreenSHA struct {
+	SHA string `json:"sha"`
+	// At is when the last of the workflows completed
+	At        time.Time `json:"at"`
+	Workflows []string  `json:"workflows"`
+	// dispatchedAt is when the workflows were first dispatched for SHA, telling which of two green SHAs is the newer
+	dispatchedAt time.Time
+}
+
+// shaOutcomes are the conclusions of the workflows dispatched for a head SHA of a pull request, empty while pending
+type shaOutcomes struct {
+	prNumber     int
+	dispatchedAt time.Time
+	conclusions  map[string]string
+}
+
+// GreenStore tracks the outcomes of the workflows dispatched for the head SHAs of pull requests, and the last SHA of
+// each pull request for which all of them succeeded. A nil GreenStore is valid and tracks nothing.
+type GreenStore struct {
+	mu    sync.Mutex
+	cache *gocache.Cache
+}
+
+func NewGreenStore(expiry time.Duration) *GreenStore {
+	if expiry <= 0 {
+		expiry = DefaultGreenExpiry
+	}
+	return &GreenStore{cache: gocache.New(expiry, expiry)}
+}
+
+func greenSHAKey(owner, repo, SHA string) string {
+	return owner + "/" + repo + "@" + SHA
+}
+
+func greenPRKey(owner, repo string, prNumber int) string {
+	return owner + "/" + repo + "#" + strconv.Itoa(prNumber)
+}
+
+// dispatched records a workflow dispatched for a head SHA of a pull request, pending until its run completes
+func (s *GreenStore) dispatched(owner, repo string, prNumber int, SHA, workflow string, at time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := greenSHAKey(owner, repo, SHA)
+	outcomes := shaOutcomes{prNumber: prNumber, dispatchedAt: at, conclusions: map[string]string{}}
+	if v, ok := s.cache.Get(key); ok {
+		outcomes = v.(shaOutcomes)
+	}
+	outcomes.conclusions[workflow] = ""
+	s.cache.SetDefault(key, outcomes)
+}
+
+// completed records the conclusion of a run dispatched by Ariane, and the SHA of its pull request as the last green
+// one once all the workflows dispatched for it succeeded, unless a SHA dispatched later already is
+func (s *GreenStore) completed(owner, repo string, run *github.WorkflowRun) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.cache.Get(greenSHAKey(owner, repo, run.GetHeadSHA()))
+	if !ok {
+		return
+	}
+	outcomes := v.(shaOutcomes)
+	workflow := strings.TrimPrefix(run.GetPath(), ".github/workflows/")
+	if _, dispatched := outcomes.conclusions[workflow]; !dispatched {
+		return
+	}
+	// re-run attempts replace the conclusion of the previous attempt
+	outcomes.conclusions[workflow] = run.GetConclusion()
+
+	workflows := make([]string, 0, len(outcomes.conclusions))
+	for workflow, conclusion := range outcomes.conclusions {
+		if conclusion != "success" {
+			return
+		}
+		workflows = append(workflows, workflow)
+	}
+	slices.Sort(workflows)
+	key := greenPRKey(owner, repo, outcomes.prNumber)
+	if v, ok := s.cache.Get(key); ok && v.(GreenSHA).dispatchedAt.After(outcomes.dispatchedAt) {
+		return
+	}
+	s.cache.SetDefault(key, GreenSHA{SHA: run.GetHeadSHA(), At: run.GetUpdatedAt().Time, Workflows: workflows, dispatchedAt: outcomes.dispatchedAt})
+}
+
+// Last returns the last green SHA of a pull request, if any
+func (s *GreenStore) Last(owner, repo string, prNumber int) (GreenSHA, bool) {
+	if s == nil {
+		return GreenSHA{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.cache.Get(greenPRKey(owner, repo, prNumber))
+	if !ok {
+		return GreenSHA{}, false
+	}
+	return v.(GreenSHA), true
+}
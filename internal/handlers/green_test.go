@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func completedRun(workflow, SHA, conclusion string, at time.Time) *github.WorkflowRun {
+	return &github.WorkflowRun{
+		Path:       github.Ptr(".github/workflows/" + workflow),
+		HeadSHA:    github.Ptr(SHA),
+		Status:     github.Ptr("completed"),
+		Conclusion: github.Ptr(conclusion),
+		UpdatedAt:  &github.Timestamp{Time: at},
+	}
+}
+
+func Test_GreenStore(t *testing.T) {
+	store := NewGreenStore(0)
+	now := time.Now()
+
+	store.dispatched("owner", "repo", 1, "sha-1", "foo.yaml", now)
+	store.dispatched("owner", "repo", 1, "sha-1", "bar.yaml", now)
+	store.completed("owner", "repo", completedRun("foo.yaml", "sha-1", "success", now))
+	store.completed("owner", "repo", completedRun("bar.yaml", "sha-1", "failure", now))
+	store.completed("owner", "repo", completedRun("other.yaml", "sha-1", "success", now))
+	_, ok := store.Last("owner", "repo", 1)
+	assert.False(t, ok, "a workflow failed")
+
+	store.completed("owner", "repo", completedRun("bar.yaml", "sha-1", "success", now.Add(time.Minute)))
+	green, ok := store.Last("owner", "repo", 1)
+	assert.True(t, ok, "the failed workflow succeeded once re-run")
+	assert.Equal(t, "sha-1", green.SHA)
+	assert.Equal(t, []string{"bar.yaml", "foo.yaml"}, green.Workflows)
+	assert.Equal(t, now.Add(time.Minute), green.At)
+
+	store.dispatched("owner", "repo", 1, "sha-2", "foo.yaml", now.Add(time.Hour))
+	store.completed("owner", "repo", completedRun("foo.yaml", "sha-2", "success", now.Add(2*time.Hour)))
+	green, _ = store.Last("owner", "repo", 1)
+	assert.Equal(t, "sha-2", green.SHA)
+
+	store.completed("owner", "repo", completedRun("foo.yaml", "sha-1", "success", now.Add(3*time.Hour)))
+	green, _ = store.Last("owner", "repo", 1)
+	assert.Equal(t, "sha-2", green.SHA, "older SHAs completing later are not the last green one")
+
+	_, ok = store.Last("owner", "repo", 2)
+	assert.False(t, ok)
+	var nilStore *GreenStore
+	nilStore.dispatched("owner", "repo", 1, "sha-1", "foo.yaml", now)
+	_, ok = nilStore.Last("owner", "repo", 1)
+	assert.False(t, ok)
+}
+
+func Test_handleCommandLastGreen(t *testing.T) {
+	var comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{Green: NewGreenStore(0)}
+	handler.Green.dispatched("owner", "repo", 1, "mock-sha", "foo.yaml", time.Now())
+	handler.Green.completed("owner", "repo", completedRun("foo.yaml", "mock-sha", "success", time.Now()))
+	arianeConfig := &config.ArianeConfig{}
+
+	for _, prNumber := range []int{1, 2} {
+		assert.NoError(t, handler.handleCommand(context.Background(), client, arianeConfig, "owner", "repo", prNumber, "mock-sha", "contributor", "last-green", zerolog.Nop()))
+	}
+	assert.Equal(t, []string{
+		"@contributor the last commit of this pull request for which all the workflows run by Ariane succeeded is mock-sha: foo.yaml.",
+		"@contributor no commit of this pull request is known to have passed all the workflows run by Ariane.",
+	}, comments)
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	gocache "github.com/patrickmn/go-cache"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+const (
+	// DefaultHeadExpiry covers the dispatches queued for a slot of their resource pool
+	DefaultHeadExpiry = 24 * time.Hour
+)
+
+const defaultHeadMovedMessage = "@{{ .Author }} the workflows were not run, as {{ .Reason.Message }} since your comment. " +
+	"Comment again to run them on the new head."
+
+// HeadStore records the head SHAs pushed to pull requests, as seen in their synchronize events, keyed by pull
+// request, so dispatches can tell the head moved since their trigger comment was read. A nil HeadStore is valid and
+// records nothing.
+type HeadStore struct {
+	cache *gocache.Cache
+}
+
+func NewHeadStore(expiry time.Duration) *HeadStore {
+	if expiry <= 0 {
+		expiry = DefaultHeadExpiry
+	}
+	return &HeadStore{cache: gocache.New(expiry, expiry)}
+}
+
+func headStoreKey(owner, repo string, prNumber int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, prNumber)
+}
+
+// pushed records the new head SHA of a pull request
+func (s *HeadStore) pushed(owner, repo string, prNumber int, SHA string) {
+	if s == nil {
+		return
+	}
+	s.cache.SetDefault(headStoreKey(owner, repo, prNumber), SHA)
+}
+
+// head returns the last head SHA pushed to a pull request, if any was seen
+func (s *HeadStore) head(owner, repo string, prNumber int) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	v, ok := s.cache.Get(headStoreKey(owner, repo, prNumber))
+	if !ok {
+		return "", false
+	}
+	return v.(string), true
+}
+
+// checkHead checks the head of the pull request of a dispatch is still the SHA its trigger comment was read at. As
+// synchronize events may be delivered out of order, a push seen since is confirmed by looking up the pull request,
+// which is returned if its head moved.
+func (h *PRCommentHandler) checkHead(ctx context.Context, t triggerDispatch) (*github.PullRequest, decision.Decision, error) {
+	current := decision.Yes(decision.ReasonHeadCurrent, "head %s of the pull request is current", shortSHA(t.SHA))
+	if head, ok := h.Heads.head(t.owner, t.repo, t.prNumber); !ok || head == t.SHA {
+		return nil, current, nil
+	}
+	pr, err := h.getPullRequest(ctx, t.client, t.owner, t.repo, t.prNumber, t.logger)
+	if err != nil {
+		return nil, decision.Decision{}, err
+	}
+	if pr.GetHead().GetSHA() == t.SHA {
+		return nil, current, nil
+	}
+	return pr, decision.No(decision.ReasonHeadMoved, "the head of the pull request moved from %s to %s", shortSHA(t.SHA), shortSHA(pr.GetHead().GetSHA())), nil
+}
+
+// retarget points a dispatch at the new head of its pull request, so its workflows are evaluated against it. The
+// workflow definitions are still taken from the overridden context, if any.
+func retarget(t triggerDispatch, pr *github.PullRequest) triggerDispatch {
+	contextRef, SHA := determineContextRef(pr, t.owner, t.repo, t.logger)
+	if t.contextOverride != "" {
+		contextRef = t.contextRef
+	}
+	t.contextRef, t.SHA, t.baseSHA = contextRef, SHA, pr.GetBase().GetSHA()
+
+	retargetInputs := func(inputs map[string]interface{}) map[string]interface{} {
+		inputs = maps.Clone(inputs)
+		inputs["context-ref"] = contextRef
+		inputs["SHA"] = SHA
+		return inputs
+	}
+	t.event.Ref = contextRef
+	t.event.Inputs = retargetInputs(t.event.Inputs)
+	if t.workflowInputs != nil {
+		workflowInputs := make(map[string]map[string]interface{}, len(t.workflowInputs))
+		for workflow, inputs := range t.workflowInputs {
+			workflowInputs[workflow] = retargetInputs(inputs)
+		}
+		t.workflowInputs = workflowInputs
+	}
+	return t
+}
+
+// followHead handles a push to the pull request of a dispatch since its trigger comment was read, as configured by
+// head-moved. It returns the dispatch to go on with, retargeted at the new head for HeadMovedReevaluate, and false if
+// the workflows are not to be dispatched, the author having been told why for HeadMovedAbort.
+func (h *PRCommentHandler) followHead(ctx context.Context, t triggerDispatch) (triggerDispatch, bool, error) {
+	if t.arianeConfig.HeadMoved == "" || t.isIssue || t.tag != "" {
+		return t, true, nil
+	}
+	pr, head, err := h.checkHead(ctx, t)
+	if err != nil {
+		t.logger.Error().Err(err).Msg("Failed to look up the head of the pull request")
+		return t, false, err
+	}
+	if recordDecision(t.logger, stepHead, head).Result {
+		return t, true, nil
+	}
+	audit.Event(ctx, "head_moved").Str("author", t.commentAuthor).Str("head_moved", t.arianeConfig.HeadMoved).Object("decision", head).Send()
+	if t.arianeConfig.HeadMoved == config.HeadMovedAbort {
+		data := MessageData{Author: t.commentAuthor, Reason: head}
+		return t, false, h.postMessage(ctx, t.client, t.arianeConfig, t.owner, t.repo, t.prNumber, "head-moved", t.arianeConfig.Messages.HeadMoved, defaultHeadMovedMessage, data, t.logger)
+	}
+	t.logger.Info().Msgf("Evaluating the trigger comment against the new head %s", pr.GetHead().GetSHA())
+	return retarget(t, pr), true, nil
+}
+
+// shortSHA abbreviates a SHA the way GitHub shows commits
+func shortSHA(SHA string) string {
+	if len(SHA) > 7 {
+		return SHA[:7]
+	}
+	return SHA
+}
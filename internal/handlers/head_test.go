@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_followHead(t *testing.T) {
+	var comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.PullRequest{{
+			Number: github.Int(1),
+			Head: &github.PullRequestBranch{
+				SHA:  github.String("2222222222"),
+				Ref:  github.String("feature"),
+				Repo: &github.Repository{Name: github.String("repo"), Owner: &github.User{Login: github.String("owner")}},
+			},
+			Base: &github.PullRequestBranch{SHA: github.String("base2"), Ref: github.String("main")},
+		}})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{Heads: NewHeadStore(0)}
+	dispatch := func(headMoved string) triggerDispatch {
+		return triggerDispatch{
+			client:        client,
+			arianeConfig:  &config.ArianeConfig{HeadMoved: headMoved},
+			owner:         "owner",
+			repo:          "repo",
+			prNumber:      1,
+			commentAuthor: "author",
+			contextRef:    "feature",
+			SHA:           "1111111111",
+			baseSHA:       "base1",
+			event:         handler.createWorkflowDispatchEvent(1, "feature", "1111111111", []string{"/test"}, nil),
+			workflowInputs: map[string]map[string]interface{}{
+				"foo.yaml": {"SHA": "1111111111", "context-ref": "feature"},
+			},
+			logger: zerolog.Nop(),
+		}
+	}
+
+	// no push was seen since the comment
+	followed, proceed, err := handler.followHead(context.Background(), dispatch(config.HeadMovedReevaluate))
+	assert.NoError(t, err)
+	assert.True(t, proceed)
+	assert.Equal(t, "1111111111", followed.SHA)
+
+	handler.Heads.pushed("owner", "repo", 1, "2222222222")
+
+	// disabled by default
+	followed, proceed, err = handler.followHead(context.Background(), dispatch(""))
+	assert.NoError(t, err)
+	assert.True(t, proceed)
+	assert.Equal(t, "1111111111", followed.SHA)
+
+	original := dispatch(config.HeadMovedReevaluate)
+	followed, proceed, err = handler.followHead(context.Background(), original)
+	assert.NoError(t, err)
+	assert.True(t, proceed)
+	assert.Equal(t, "2222222222", followed.SHA)
+	assert.Equal(t, "base2", followed.baseSHA)
+	assert.Equal(t, "2222222222", followed.event.Inputs["SHA"])
+	assert.Equal(t, "2222222222", followed.workflowInputs["foo.yaml"]["SHA"])
+	assert.Equal(t, "1111111111", original.event.Inputs["SHA"], "the inputs of the original dispatch are left alone")
+
+	_, proceed, err = handler.followHead(context.Background(), dispatch(config.HeadMovedAbort))
+	assert.NoError(t, err)
+	assert.False(t, proceed)
+	assert.Equal(t, []string{"@author the workflows were not run, as the head of the pull request moved from 1111111 to 2222222 since your comment. " +
+		"Comment again to run them on the new head."}, comments)
+
+	// a push delivered out of order is confirmed on the pull request
+	current := dispatch(config.HeadMovedAbort)
+	current.SHA = "2222222222"
+	handler.Heads.pushed("owner", "repo", 1, "1111111111")
+	_, proceed, err = handler.followHead(context.Background(), current)
+	assert.NoError(t, err)
+	assert.True(t, proceed)
+	assert.Len(t, comments, 1)
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// DefaultHistorySize is how many workflow decisions the history keeps, the oldest being dropped first
+const DefaultHistorySize = 10000
+
+// WorkflowDecision is what Ariane did with a workflow of a trigger comment, and why
+type WorkflowDecision struct {
+	Repo     string `json:"repo"`
+	PRNumber int    `json:"pr"`
+	// Issue is set for the trigger comments of plain issues, whose number is PRNumber
+	Issue    bool   `json:"issue,omitempty"`
+	SHA      string `json:"sha"`
+	Workflow string `json:"workflow"`
+	Trigger  string `json:"trigger"`
+	Author   string `json:"author"`
+	// Action is decision.ActionDispatch, decision.ActionRerun or decision.ActionSkip
+	Action   string            `json:"action"`
+	Decision decision.Decision `json:"decision"`
+	At       time.Time         `json:"at"`
+}
+
+// HistoryFilter selects workflow decisions, the unset fields selecting all of them
+type HistoryFilter struct {
+	Repo     string
+	PRNumber int
+	SHA      string
+	Workflow string
+	Action   string
+	Reason   decision.Reason
+}
+
+func (f HistoryFilter) matches(d WorkflowDecision) bool {
+	return (f.Repo == "" || f.Repo == d.Repo) &&
+		(f.PRNumber == 0 || f.PRNumber == d.PRNumber) &&
+		(f.SHA == "" || f.SHA == d.SHA) &&
+		(f.Workflow == "" || f.Workflow == d.Workflow) &&
+		(f.Action == "" || f.Action == d.Action) &&
+		(f.Reason == "" || f.Reason == d.Decision.Reason)
+}
+
+// HistoryStore keeps the last workflow decisions taken for trigger comments, in memory, for tooling to query them.
+// A nil HistoryStore is valid and keeps nothing.
+type HistoryStore struct {
+	mu   sync.Mutex
+	size int
+	// decisions is a ring buffer, next being the index of the oldest decision once it is full
+	decisions []WorkflowDecision
+	next      int
+}
+
+func NewHistoryStore(size int) *HistoryStore {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &HistoryStore{size: size}
+}
+
+// record adds the decision taken for a workflow of a trigger comment
+func (s *HistoryStore) record(t triggerDispatch, workflow, action string, d decision.Decision, at time.Time) {
+	if s == nil {
+		return
+	}
+	var trigger string
+	if len(t.submatch) > 0 {
+		trigger = t.submatch[0]
+	}
+	s.Record(WorkflowDecision{
+		Repo:     t.owner + "/" + t.repo,
+		PRNumber: t.prNumber,
+		Issue:    t.isIssue,
+		SHA:      t.SHA,
+		Workflow: workflow,
+		Trigger:  trigger,
+		Author:   t.commentAuthor,
+		Action:   action,
+		Decision: d,
+		At:       at,
+	})
+}
+
+// Record adds a workflow decision
+func (s *HistoryStore) Record(workflowDecision WorkflowDecision) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.decisions) < s.size {
+		s.decisions = append(s.decisions, workflowDecision)
+		return
+	}
+	s.decisions[s.next] = workflowDecision
+	s.next = (s.next + 1) % s.size
+}
+
+// Decisions returns up to limit decisions selected by filter, the newest first, all of them if limit is not positive
+func (s *HistoryStore) Decisions(filter HistoryFilter, limit int) []WorkflowDecision {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var decisions []WorkflowDecision
+	for i := range s.decisions {
+		// walk back from the newest decision
+		d := s.decisions[(s.next-1-i+2*len(s.decisions))%len(s.decisions)]
+		if !filter.matches(d) {
+			continue
+		}
+		decisions = append(decisions, d)
+		if len(decisions) == limit {
+			break
+		}
+	}
+	return decisions
+}
+
+// skipAction returns the action of a skipped workflow, which was re-run rather than dispatched if its previous run
+// failed
+func skipAction(skipped SkippedWorkflow) string {
+	if skipped.Reason.Reason == decision.ReasonPreviousRunRerun {
+		return decision.ActionRerun
+	}
+	return decision.ActionSkip
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_HistoryStore(t *testing.T) {
+	store := NewHistoryStore(3)
+	at := time.Now()
+	t1 := triggerDispatch{owner: "owner", repo: "repo", prNumber: 1, SHA: "sha-1", commentAuthor: "alice", submatch: []string{"/test"}}
+	t2 := triggerDispatch{owner: "owner", repo: "repo", prNumber: 2, SHA: "sha-2", commentAuthor: "bob", submatch: []string{"/test"}}
+	store.record(t1, "foo.yaml", decision.ActionDispatch, decision.Yes(decision.ReasonPathsMatched, ""), at)
+	store.record(t1, "bar.yaml", decision.ActionSkip, decision.No(decision.ReasonPathsNotMatched, ""), at)
+	store.record(t2, "foo.yaml", decision.ActionDispatch, decision.Yes(decision.ReasonPathsMatched, ""), at)
+
+	decisions := store.Decisions(HistoryFilter{}, 0)
+	if assert.Len(t, decisions, 3) {
+		assert.Equal(t, WorkflowDecision{Repo: "owner/repo", PRNumber: 2, SHA: "sha-2", Workflow: "foo.yaml", Trigger: "/test", Author: "bob", Action: decision.ActionDispatch, Decision: decision.Yes(decision.ReasonPathsMatched, ""), At: at}, decisions[0])
+		assert.Equal(t, "bar.yaml", decisions[1].Workflow)
+	}
+
+	// the oldest decision is dropped once the store is full
+	store.record(t2, "bar.yaml", decision.ActionRerun, decision.Yes(decision.ReasonPreviousRunRerun, ""), at)
+	decisions = store.Decisions(HistoryFilter{PRNumber: 1}, 0)
+	if assert.Len(t, decisions, 1) {
+		assert.Equal(t, "bar.yaml", decisions[0].Workflow)
+	}
+	decisions = store.Decisions(HistoryFilter{Workflow: "bar.yaml"}, 1)
+	if assert.Len(t, decisions, 1) {
+		assert.Equal(t, decision.ActionRerun, decisions[0].Action)
+	}
+	assert.Len(t, store.Decisions(HistoryFilter{Reason: decision.ReasonPathsMatched}, 0), 1)
+	assert.Empty(t, store.Decisions(HistoryFilter{Repo: "owner/other"}, 0))
+
+	var nilStore *HistoryStore
+	nilStore.record(t1, "foo.yaml", decision.ActionDispatch, decision.Decision{}, at)
+	assert.Empty(t, nilStore.Decisions(HistoryFilter{}, 0))
+}
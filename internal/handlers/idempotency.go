@@ -0,0 +1,65 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/state"
+)
+
+const (
+	DefaultIdempotencyExpiry = 7 * 24 * time.Hour
+)
+
+// IdempotencyStore records the SHA workflows were dispatched for, keyed by their idempotency key.
+// A nil IdempotencyStore is valid and records nothing.
+type IdempotencyStore struct {
+	state  state.Store
+	expiry time.Duration
+}
+
+// NewIdempotencyStore creates an idempotency store kept in store, in memory if nil
+func NewIdempotencyStore(store state.Store, expiry time.Duration) *IdempotencyStore {
+	if expiry <= 0 {
+		expiry = DefaultIdempotencyExpiry
+	}
+	return &IdempotencyStore{state: orMemory(store), expiry: expiry}
+}
+
+func idempotencyStoreKey(owner, repo, workflow, key string) string {
+	return "idempotency/" + owner + "/" + repo + "/" + workflow + "@" + key
+}
+
+func (s *IdempotencyStore) add(ctx context.Context, owner, repo, workflow, key, SHA string) {
+	if s == nil {
+		return
+	}
+	setState(ctx, s.state, idempotencyStoreKey(owner, repo, workflow, key), SHA, s.expiry)
+}
+
+func (s *IdempotencyStore) get(ctx context.Context, owner, repo, workflow, key string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	return getState[string](ctx, s.state, idempotencyStoreKey(owner, repo, workflow, key))
+}
+
+// checkIdempotency decides whether a workflow can be skipped because it already succeeded for a previous
+// dispatch with the same idempotency key, e.g. before a rebase which did not change its relevant files.
+func (h *PRCommentHandler) checkIdempotency(ctx context.Context, client *github.Client, owner, repo, workflow, key string, logger zerolog.Logger) decision.Decision {
+	SHA, ok := h.Idempotency.get(ctx, owner, repo, workflow, key)
+	if !ok {
+		return decision.No(decision.ReasonNoIdempotentDispatch, "workflow %s was not dispatched with idempotency key %s yet", workflow, key)
+	}
+	if _, previous := h.previousRun(ctx, client, owner, repo, workflow, SHA, logger); previous.Reason != decision.ReasonPreviousRunSucceeded {
+		return decision.No(decision.ReasonIdempotentRunNotSucceeded, "workflow %s was dispatched with the same idempotency key for %s, but did not succeed: %s", workflow, SHA, previous.Message)
+	}
+	return decision.Yes(decision.ReasonIdempotentRunSucceeded, "workflow %s already succeeded for %s with the same idempotency key", workflow, SHA)
+}
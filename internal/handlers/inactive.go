@@ -0,0 +1,35 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/cilium/ariane/internal/audit"
+)
+
+// reasons for skipping events on resources Ariane cannot act on, used in audit records
+const (
+	reasonRepositoryArchived = "repository_archived"
+	reasonConversationLocked = "conversation_locked"
+)
+
+// skipInactive reports whether an event is about an archived repository or a locked conversation, recording
+// an audit entry if so. Archived repositories are read-only and locked conversations reject reactions and
+// comments, so handling such events would only fail with 403 errors.
+func skipInactive(ctx context.Context, repository *github.Repository, locked bool) bool {
+	var reason string
+	switch {
+	case repository.GetArchived():
+		reason = reasonRepositoryArchived
+	case locked:
+		reason = reasonConversationLocked
+	default:
+		return false
+	}
+	audit.Event(ctx, "event_skipped").Str("reason", reason).Send()
+	return true
+}
@@ -8,24 +8,137 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/go-github/v75/github"
 	"github.com/palantir/go-githubapp/githubapp"
 	"github.com/rs/zerolog"
 
+	"github.com/cilium/ariane/internal/audit"
 	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/failure"
 	"github.com/cilium/ariane/internal/log"
+	"github.com/cilium/ariane/internal/policy"
+	"github.com/cilium/ariane/internal/poll"
+	"github.com/cilium/ariane/internal/scheduler"
 )
 
 var configGetArianeConfigFromRepository = config.GetArianeConfigFromRepository
 
+const (
+	skippedCheckTitle = "Skipped by Ariane"
+	// skippedExternalIDPrefix prefixes the external ID of skipped check runs, followed by the workflow file
+	skippedExternalIDPrefix = "skipped/"
+)
+
+// getArianeConfig returns the config cached for the repository and ref, fetching it from the repository on a miss
+func getArianeConfig(ctx context.Context, cache *config.Cache, client *github.Client, owner, repo, ref string) (*config.ArianeConfig, error) {
+	if arianeConfig, ok := cache.Get(owner, repo, ref); ok {
+		return arianeConfig, nil
+	}
+	arianeConfig, err := configGetArianeConfigFromRepository(client, ctx, owner, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	cache.Set(owner, repo, ref, arianeConfig)
+	return arianeConfig, nil
+}
+
+// getArianeConfigOrDefault returns the config of the given ref, falling back to the config of the default branch if
+// the ref has none, e.g. for old PR branches predating the config. The fallback is recorded in the audit trail.
+func getArianeConfigOrDefault(ctx context.Context, cache *config.Cache, client *github.Client, repository *github.Repository, ref string, logger zerolog.Logger) (*config.ArianeConfig, error) {
+	owner := repository.GetOwner().GetLogin()
+	repo := repository.GetName()
+	arianeConfig, err := getArianeConfig(ctx, cache, client, owner, repo, ref)
+	defaultBranch := repository.GetDefaultBranch()
+	if !errors.Is(err, config.ErrNotFound) || defaultBranch == "" || defaultBranch == ref {
+		return arianeConfig, err
+	}
+	logger.Info().Msgf("No config file on %s, falling back to the config of the default branch %s", ref, defaultBranch)
+	audit.Event(ctx, "config_fallback").Str("ref", ref).Str("default_branch", defaultBranch).Send()
+	return getArianeConfig(ctx, cache, client, owner, repo, defaultBranch)
+}
+
+// matchesDefaultTrigger reports whether the comment matches a trigger, or the status command, of the config of the
+// default branch of the repository, fetched and cached if the config cache is enabled, and whether that config
+// could be fetched at all
+func (h *PRCommentHandler) matchesDefaultTrigger(ctx context.Context, client *github.Client, repository *github.Repository, comment string, logger zerolog.Logger) (matched, known bool) {
+	defaultBranch := repository.GetDefaultBranch()
+	if defaultBranch == "" {
+		return false, false
+	}
+	arianeConfig, err := getArianeConfig(ctx, h.ConfigCache, client, repository.GetOwner().GetLogin(), repository.GetName(), defaultBranch)
+	if err != nil {
+		// the config of the pull request is looked up as usual, e.g. as the default branch has none yet
+		logger.Debug().Err(err).Msgf("Failed to retrieve the config of the default branch %s to match the comment against", defaultBranch)
+		return false, false
+	}
+	_, ok := arianeConfig.MatchedTrigger(comment)
+	return ok || arianeConfig.IsStatusCommand(comment), true
+}
+
 type PRCommentHandler struct {
 	githubapp.ClientCreator
-	RunDelay time.Duration
+	// Poll controls how Ariane waits on GitHub state, e.g. for a re-run job to complete
+	Poll poll.Poller
+	// Approvals holds trigger comments from non-allowed users until a maintainer approves them, if enabled
+	Approvals *ApprovalStore
+	// DispatchVerifyTimeout controls how long dispatched runs are looked up, every Poll.Interval, to link
+	// them from a check run on the PR. Linking is disabled if DispatchVerifyTimeout is zero.
+	DispatchVerifyTimeout time.Duration
+	// RunChecks tracks the check runs created at dispatch time, if enabled in the repository config
+	RunChecks *RunChecks
+	// ConfigCache caches the Ariane configs fetched from repositories, if enabled
+	ConfigCache *config.Cache
+	// Idempotency records the dispatches of workflows with an idempotency key, if enabled
+	Idempotency *IdempotencyStore
+	// BotLogin is the login of the app bot user (e.g. "my-ariane[bot]"), whose comments and reactions are ignored
+	BotLogin string
+	// TrustedBots are the logins of the bots whose trigger comments are handled, comments of other bots are ignored
+	TrustedBots []string
+	// HandlerTimeout is the deadline for handling approved comments, as webhook events get from the scheduler
+	HandlerTimeout time.Duration
+	// Workflows caches the workflows looked up to name check runs, if enabled
+	Workflows *WorkflowCache
+	// IssueCommands handles the comments of plain issues for the triggers enabled on issues, which are
+	// otherwise ignored without any API call
+	IssueCommands bool
+	// Pagination bounds the GitHub lists walked while handling comments, see config.DefaultPagination for unset values
+	Pagination config.PaginationConfig
+	// Overrides replaces DispatchVerifyTimeout, IssueCommands and Pagination for some repositories
+	Overrides config.Overrides
+	// Bursts coalesces the trigger comments posted on a PR in quick succession, dispatching their workflows together,
+	// if enabled
+	Bursts *Bursts
+	// Pools queues the dispatches of workflows whose resource pool is at its limit, if any pool is limited
+	Pools *Pools
+	// Pauses records the PRs Ariane was paused on with `/ariane off`, ignoring their trigger comments
+	Pauses *PauseStore
+	// Version is the version of the server, recorded in the provenance of dispatches
+	Version string
+	// Retries records the runs of workflows for each SHA, to limit their retries if enabled in the repository config
+	Retries *RetryStore
+	// Capabilities falls back to the supported endpoints to re-run failed runs on older GitHub Enterprise Server
+	// versions, all being supported if nil
+	Capabilities *Capabilities
+	// Green tracks the last SHA of each pull request for which all the dispatched workflows succeeded, shared with
+	// the WorkflowRunHandler following their runs
+	Green *GreenStore
+	// Heads records the heads pushed to pull requests, shared with the PullRequestHandler seeing the pushes, so
+	// trigger comments can follow a push racing them, see followHead
+	Heads *HeadStore
+	// History keeps the decisions taken for the workflows of trigger comments, for the admin API to query
+	History *HistoryStore
+	// Policy evaluates the dispatch plans of trigger comments, allowing, denying or narrowing them down, if set
+	Policy *policy.Engine
+
+	// Scheduler runs the background work spawned while handling events, and the polling of held comments
+	Scheduler scheduler.Scheduler
 }
 
 func (h *PRCommentHandler) Handles() []string {
@@ -38,117 +151,716 @@ func (h *PRCommentHandler) Handle(ctx context.Context, eventType, deliveryID str
 		return fmt.Errorf("failed to parse issue_comment event payload: %w", err)
 	}
 
-	// only handle PR comments, not issue comments
-	if !event.GetIssue().IsPullRequest() {
+	return h.handleEvent(withDeliveryID(ctx, deliveryID), &event, false)
+}
+
+// handleEvent processes an issue comment event. approved is set when the comment was held
+// and has since been approved by an allowed team member, bypassing the membership check.
+func (h *PRCommentHandler) handleEvent(ctx context.Context, event *github.IssueCommentEvent, approved bool) error {
+	// only handle PR comments, and issue comments if enabled
+	settings := h.settings(event.GetRepo().GetOwner().GetLogin(), event.GetRepo().GetName())
+	isIssue := !event.GetIssue().IsPullRequest()
+	if isIssue && !settings.IssueCommands {
 		zerolog.Ctx(ctx).Debug().Msg("Issue comment event is not for a pull request")
 		return nil
 	}
 
-	installationID := githubapp.GetInstallationIDFromEvent(&event)
+	installationID := githubapp.GetInstallationIDFromEvent(event)
 	repository := event.GetRepo()
 	prNumber := event.GetIssue().GetNumber()
 	ctx, logger := githubapp.PreparePRContext(ctx, installationID, repository, prNumber)
 	ctx = log.WithLogger(ctx, &logger)
 
+	// deleting a held trigger comment cancels it
+	if event.GetAction() == "deleted" {
+		h.cancelHeld(ctx, event.GetComment().GetID(), "comment_deleted", logger)
+		return nil
+	}
+
 	// only handle new comments
 	logger.Debug().Msgf("Event action is %s", event.GetAction())
 	if event.GetAction() != "created" {
 		return nil
 	}
 
-	client, err := h.NewInstallationClient(installationID)
-	if err != nil {
-		return err
+	// ignore the replies posted by Ariane itself
+	if h.isOwnLogin(event.GetComment().GetUser().GetLogin()) {
+		logger.Debug().Msg("Issue comment was created by Ariane itself")
+		return nil
+	}
+
+	if skipInactive(ctx, repository, event.GetIssue().GetLocked()) {
+		return nil
 	}
 
 	repositoryOwner := repository.GetOwner().GetLogin()
 	repositoryName := repository.GetName()
 	commentID := event.GetComment().GetID()
 	commentAuthor := event.GetComment().GetUser().GetLogin()
-	commentBody := event.GetComment().GetBody()
+	// structured args may follow the trigger phrase in a fenced YAML block
+	commentBody, argsBlock := config.SplitArgsBlock(event.GetComment().GetBody())
+	// the plan of a trigger can be previewed by ending it with --dry-run, see previewPlan
+	commentBody, dryRun := config.SplitDryRun(commentBody)
+	// the workflows of large PRs may require the trigger phrase to end with --force, see checkLargeDispatch
+	commentBody, force := config.SplitForce(commentBody)
+	// trusted reviewers may run the workflows whatever their paths filters, see checkPathsFilterOverride
+	commentBody, ignorePaths := config.SplitIgnorePathsFilter(commentBody)
+	// the workflow definitions may be taken from another ref, given as context=<ref> ending the trigger phrase
+	commentBody, contextOverride := config.SplitContextOverride(commentBody)
 
 	var botUser bool
 
-	// only handle non-bot comments
+	// only handle the comments of trusted bots, skipping their membership check
 	if strings.HasSuffix(commentAuthor, "[bot]") {
-		if !strings.HasPrefix(commentAuthor, repositoryOwner) {
+		if !h.isTrustedBot(commentAuthor) {
 			logger.Debug().Msgf("Issue comment was created by an unsupported bot: %s", commentAuthor)
 			return nil
 		}
-		// comment created by the cilium-* [bot]
 		botUser = true
 	}
 
-	// Get PR metadata and validate PR author permissions
-	pr, err := h.getPullRequest(ctx, client, repositoryOwner, repositoryName, prNumber, logger)
-	if err != nil {
-		return err
+	// ignore the comments neither addressed to Ariane nor matching a trigger of the configs of the repository before
+	// looking up their pull request, as most comments are not for Ariane: they are matched against the configs cached
+	// for the repository without any GitHub API call, or else against the config of the default branch, fetched once
+	var client *github.Client
+	var err error
+	if _, ok := parseCommand(commentBody); !ok {
+		matched, known := h.ConfigCache.MatchesTrigger(repositoryOwner, repositoryName, commentBody)
+		if !known {
+			if client, err = h.NewInstallationClient(installationID); err != nil {
+				return err
+			}
+			matched, known = h.matchesDefaultTrigger(ctx, client, repository, commentBody, logger)
+		}
+		if known && !matched {
+			logger.Debug().Msg("Issue comment does not match any trigger of the configs of the repository")
+			return nil
+		}
+	}
+
+	if client == nil {
+		if client, err = h.NewInstallationClient(installationID); err != nil {
+			return err
+		}
 	}
 
-	contextRef, SHA := h.determineContextRef(pr, repositoryOwner, repositoryName, logger)
+	var contextRef, SHA, baseSHA, baseRef string
+	if isIssue {
+		// plain issues run the workflows on the default branch
+		contextRef = repository.GetDefaultBranch()
+		baseRef = contextRef
+		if SHA, _, err = client.Repositories.GetCommitSHA1(ctx, repositoryOwner, repositoryName, "refs/heads/"+contextRef, ""); err != nil {
+			logger.Error().Err(err).Msgf("Failed to retrieve the head of the default branch %s", contextRef)
+			return err
+		}
+	} else {
+		// Get PR metadata and validate PR author permissions
+		pr, err := h.getPullRequest(ctx, client, repositoryOwner, repositoryName, prNumber, logger)
+		if err != nil {
+			return err
+		}
+		contextRef, SHA = determineContextRef(pr, repositoryOwner, repositoryName, logger)
+		baseSHA, baseRef = pr.GetBase().GetSHA(), pr.GetBase().GetRef()
+	}
 
 	// retrieve Ariane configuration (triggers, etc.) from repository based on chosen context
-	arianeConfig, err := configGetArianeConfigFromRepository(client, ctx, repositoryOwner, repositoryName, contextRef)
+	arianeConfig, err := getArianeConfigOrDefault(ctx, h.ConfigCache, client, repository, contextRef, logger)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to retrieve config file")
 		return err
 	}
 
+	// plain issues only handle the triggers enabled on issues
+	if isIssue {
+		if triggerConfig, ok := arianeConfig.MatchedTrigger(commentBody); !ok || !triggerConfig.Issues {
+			logger.Debug().Msg("Issue comment does not match a trigger enabled on issues")
+			return nil
+		}
+	}
+
+	// reply to comments addressed to Ariane itself, unless they match a trigger
+	if command, ok := parseCommand(commentBody); ok && !botUser {
+		if submatch, _, _ := arianeConfig.CheckForTrigger(ctx, commentBody); submatch == nil {
+			return h.handleCommand(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, SHA, commentAuthor, command, logger)
+		}
+	}
+	// the status command of the config is a shorthand for /ariane status, see ArianeConfig.StatusCommand
+	if arianeConfig.IsStatusCommand(commentBody) && !botUser {
+		return h.handleCommand(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, SHA, commentAuthor, "status", logger)
+	}
+
+	// preview what the trigger would do, whoever commented it, without dispatching anything
+	if dryRun {
+		submatch, workflowsToTrigger, trigger := arianeConfig.CheckForTrigger(ctx, commentBody)
+		if !trigger.Result {
+			return nil
+		}
+		args, argsDecision := arianeConfig.ParseArgs(ctx, commentBody, argsBlock)
+		if !argsDecision.Result {
+			return h.rejectInputs(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, argsDecision, logger)
+		}
+		var tag string
+		if triggerConfig, _ := arianeConfig.MatchedTrigger(commentBody); triggerConfig.Tag && len(submatch) > 1 {
+			tag = submatch[1]
+		}
+		// the paths filters are only ignored in the plan of the users who may ignore them
+		if ignorePaths {
+			ignorePaths = checkPathsFilterOverride(ctx, client, arianeConfig, repositoryOwner, commentAuthor, logger).Result
+		}
+		return h.previewPlan(ctx, triggerDispatch{
+			client:          client,
+			arianeConfig:    arianeConfig,
+			settings:        settings,
+			owner:           repositoryOwner,
+			repo:            repositoryName,
+			prNumber:        prNumber,
+			isIssue:         isIssue,
+			commentID:       commentID,
+			commentAuthor:   commentAuthor,
+			tag:             tag,
+			contextOverride: contextOverride,
+			contextRef:      contextRef,
+			SHA:             SHA,
+			baseSHA:         baseSHA,
+			baseRef:         baseRef,
+			labels:          labelNames(event.GetIssue().Labels),
+			workflows:       workflowsToTrigger,
+			submatch:        submatch,
+			args:            args,
+			force:           force,
+			ignorePaths:     ignorePaths,
+			logger:          logger,
+		}, submatch[0])
+	}
+
 	// only handle comments coming from an allowed organization, if specified
-	if !botUser && !h.isAllowedTeamMember(ctx, client, arianeConfig, repositoryOwner, commentAuthor, logger) {
-		// TODO It would be beneficial to provide feedback indicating that the test run was rejected.
-		// Initially considered updating the comment with a "no entry" emoji, but given the limited
-		// selection of emojis that can be used, none appeared to be entirely fitting.
-		// Maybe alternative feedback mechanisms should be explored to communicate the rejection status clearly.
-		return nil
+	if !botUser && !approved {
+		if membership := recordDecision(logger, stepMembership, h.isAllowedTeamMember(ctx, client, arianeConfig, repositoryOwner, commentAuthor, logger)); !membership.Result {
+			submatch, _, _ := arianeConfig.CheckForTrigger(ctx, commentBody)
+			// hold trigger comments until a maintainer approves them with a reaction, if configured
+			if submatch != nil && arianeConfig.ApprovalReaction != "" && h.Approvals != nil {
+				return h.holdForApproval(ctx, client, event, arianeConfig, SHA, false, logger)
+			}
+			if submatch == nil {
+				return nil
+			}
+			audit.Event(ctx, "trigger_rejected").Str("author", commentAuthor).Object("decision", membership).Send()
+			// reply with the rejection message, if configured
+			data := MessageData{Author: commentAuthor, Reason: membership}
+			return h.postMessage(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, "rejection", arianeConfig.Messages.Rejection, "", data, logger)
+		}
+
+		// hold trigger comments of first-time contributors until a maintainer approves them, whatever their membership
+		if arianeConfig.HoldFirstTimeContributors && arianeConfig.ApprovalReaction != "" && h.Approvals != nil {
+			if submatch, _, _ := arianeConfig.CheckForTrigger(ctx, commentBody); submatch != nil {
+				if contributor := recordDecision(logger, stepContributor, h.isPriorContributor(ctx, client, repositoryOwner, repositoryName, commentAuthor, logger)); !contributor.Result {
+					audit.Event(ctx, "trigger_held").Str("author", commentAuthor).Object("decision", contributor).Send()
+					return h.holdForApproval(ctx, client, event, arianeConfig, SHA, false, logger)
+				}
+			}
+		}
+	}
+	// approvals only apply to the head the comment was held at, not to the commits pushed since, unreviewed
+	if approved && !isIssue {
+		if head := recordDecision(logger, stepHead, checkApprovedHead(ctx, SHA)); !head.Result {
+			audit.Event(ctx, "head_moved").Str("author", commentAuthor).Object("decision", head).Send()
+			data := MessageData{Author: commentAuthor, Reason: head}
+			return h.postMessage(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, "head-moved", arianeConfig.Messages.HeadMoved, defaultHeadMovedMessage, data, logger)
+		}
 	}
 
 	// only handle comments matching a registered trigger, and retrieve associated list of workflows to trigger
-	submatch, workflowsToTrigger := arianeConfig.CheckForTrigger(ctx, commentBody)
+	submatch, workflowsToTrigger, trigger := arianeConfig.CheckForTrigger(ctx, commentBody)
 	// the command on commentBody (e.g. /test-this) does not match any "triggers"
-	if submatch == nil {
+	if !recordDecision(logger, stepTrigger, trigger).Result {
 		return nil
 	}
 	logger.Debug().Msgf("Found trigger phrase: %q", submatch)
-	workflowDispatchEvent := h.createWorkflowDispatchEvent(prNumber, contextRef, SHA, submatch)
+	// allowed users can pause Ariane on a PR, e.g. during a rework, see togglePause
+	if paused := recordDecision(logger, stepPause, h.checkPaused(ctx, repositoryOwner, repositoryName, prNumber)); !paused.Result {
+		return h.rejectPaused(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, paused, logger)
+	}
+	args, argsDecision := arianeConfig.ParseArgs(ctx, commentBody, argsBlock)
+	if !recordDecision(logger, stepArgs, argsDecision).Result {
+		return h.rejectInputs(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, argsDecision, logger)
+	}
+	triggerConfig, _ := arianeConfig.MatchedTrigger(commentBody)
+	// point the authors of deprecated commands at their replacement, running their workflows unless refused
+	if deprecation := recordDecision(logger, stepDeprecation, checkDeprecation(submatch[0], triggerConfig)); deprecation.Reason != decision.ReasonTriggerCurrent {
+		audit.Event(ctx, "trigger_deprecated").Str("author", commentAuthor).Object("decision", deprecation).Send()
+		// failing to reply does not keep the workflows of deprecated triggers from running, postMessage logs it
+		err := h.replyDeprecated(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, submatch[0], *triggerConfig.Deprecated, logger)
+		if !deprecation.Result {
+			return err
+		}
+	}
+	// triggers deploying or mutating shared environments only run once a second allowed user repeats the command,
+	// or approves it with a reaction
+	if triggerConfig.RequiresSecondApproval && !approved {
+		if second := recordDecision(logger, stepSecondApproval, h.checkSecondApproval(ctx, event)); !second.Result {
+			if second.Reason != decision.ReasonSecondApprovalRequired {
+				audit.Event(ctx, "trigger_rejected").Str("author", commentAuthor).Object("decision", second).Send()
+				return nil
+			}
+			audit.Event(ctx, "trigger_held").Str("author", commentAuthor).Object("decision", second).Send()
+			return h.holdForApproval(ctx, client, event, arianeConfig, SHA, true, logger)
+		}
+	}
+
+	// only trusted reviewers may dispatch the workflows whatever their paths filters, e.g. when they miss a dependency
+	if ignorePaths {
+		override := recordDecision(logger, stepPathsOverride, checkPathsFilterOverride(ctx, client, arianeConfig, repositoryOwner, commentAuthor, logger))
+		if !override.Result {
+			return h.rejectInputs(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, override, logger)
+		}
+		audit.Event(ctx, "paths_filter_ignored").Str("author", commentAuthor).Strs("workflows", workflowsToTrigger).Object("decision", override).Send()
+	}
+	// dispatch the workflows of tag triggers on the given tag, rather than on the PR
+	var tag string
+	if triggerConfig.Tag && len(submatch) > 1 {
+		tag = submatch[1]
+		tagSHA, resolved := h.resolveTag(ctx, client, repositoryOwner, repositoryName, tag, logger)
+		if !recordDecision(logger, stepTag, resolved).Result {
+			return h.rejectInputs(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, resolved, logger)
+		}
+		contextRef, SHA = "refs/tags/"+tag, tagSHA
+	}
+	// take the workflow definitions from the ref given by the author, if the trigger allows it
+	if contextOverride != "" {
+		override := recordDecision(logger, stepContextRef, h.checkContextOverride(ctx, client, repositoryOwner, repositoryName, contextOverride, triggerConfig, tag, logger))
+		if !override.Result {
+			return h.rejectInputs(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, override, logger)
+		}
+		audit.Event(ctx, "context_overridden").Str("author", commentAuthor).Str("context_ref", contextOverride).Object("decision", override).Send()
+		contextRef = contextOverride
+	}
+	// refuse to run the workflows of PRs conflicting with, or too far behind, their base branch, if enabled
+	if tag == "" && !isIssue && arianeConfig.Mergeability.Enabled {
+		if mergeable := recordDecision(logger, stepMergeability, h.checkMergeability(ctx, client, repositoryOwner, repositoryName, prNumber, arianeConfig.Mergeability, logger)); !mergeable.Result {
+			return h.rejectUnmergeable(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, mergeable, logger)
+		}
+	}
+	workflowDispatchEvent := h.createWorkflowDispatchEvent(prNumber, contextRef, SHA, submatch, args)
+	if isIssue {
+		setIssueInputs(workflowDispatchEvent.Inputs, event.GetIssue())
+	}
+	// tell the runs dispatched by Ariane apart from manual dispatches, if enabled
+	var marker string
+	if deliveryID := deliveryIDFromContext(ctx); arianeConfig.RunMarker && deliveryID != "" {
+		marker = runMarker(deliveryID)
+		workflowDispatchEvent.Inputs[runMarkerInput] = marker
+	}
+	// tell downstream workflows which config authorized them, if enabled
+	if arianeConfig.Provenance {
+		workflowDispatchEvent.Inputs[provenanceInput] = newProvenance(arianeConfig, h.Version).input()
+	}
+	// label the dispatches for the telemetry of downstream workflows, if enabled
+	if arianeConfig.Analytics {
+		workflowDispatchEvent.Inputs[analyticsInput] = newAnalytics(repositoryOwner, repositoryName, prNumber, isIssue, submatch, commentAuthor).input()
+	}
+	// tell the author when GitHub would reject the inputs, e.g. because of too long arguments
+	if inputs := recordDecision(logger, stepInputs, validateDispatchInputs(workflowDispatchEvent.Inputs)); !inputs.Result {
+		return h.rejectInputs(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, inputs, logger)
+	}
+	// GitHub rejects the inputs workflows do not declare, drop or refuse them, if enabled
+	var workflowInputs map[string]map[string]interface{}
+	if arianeConfig.UndeclaredInputs != "" {
+		var declared decision.Decision
+		workflowInputs, declared = declaredInputs(ctx, h.Workflows, client, arianeConfig, repositoryOwner, repositoryName, contextRef, workflowsToTrigger, workflowDispatchEvent.Inputs, logger)
+		if declared := recordDecision(logger, stepInputs, declared); !declared.Result {
+			return h.rejectInputs(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, commentAuthor, declared, logger)
+		}
+	}
+
+	dispatch := triggerDispatch{
+		client:          client,
+		arianeConfig:    arianeConfig,
+		settings:        settings,
+		owner:           repositoryOwner,
+		repo:            repositoryName,
+		prNumber:        prNumber,
+		isIssue:         isIssue,
+		commentID:       commentID,
+		commentAuthor:   commentAuthor,
+		commentURL:      event.GetComment().GetHTMLURL(),
+		tag:             tag,
+		contextOverride: contextOverride,
+		contextRef:      contextRef,
+		SHA:             SHA,
+		baseSHA:         baseSHA,
+		baseRef:         baseRef,
+		labels:          labelNames(event.GetIssue().Labels),
+		workflows:       workflowsToTrigger,
+		event:           workflowDispatchEvent,
+		workflowInputs:  workflowInputs,
+		marker:          marker,
+		submatch:        submatch,
+		args:            args,
+		force:           force,
+		ignorePaths:     ignorePaths,
+		checkNamespace:  triggerConfig.CheckNamespace,
+		logger:          logger,
+	}
+	// dispatch the workflows of the trigger comments of a burst together, if enabled
+	if h.Bursts != nil {
+		h.addToBurst(ctx, dispatch)
+		return nil
+	}
+	return h.dispatchWorkflows(ctx, dispatch, nil)
+}
+
+// triggerDispatch is a trigger comment which passed all the checks, whose workflows are about to be dispatched
+type triggerDispatch struct {
+	client       *github.Client
+	arianeConfig *config.ArianeConfig
+	settings     config.RepositorySettings
+	owner        string
+	repo         string
+	prNumber     int
+	isIssue      bool
+	// commentID, commentAuthor and commentURL identify the trigger comment
+	commentID     int64
+	commentAuthor string
+	commentURL    string
+	// tag and contextOverride are set for tag triggers and overridden contexts, dispatched on contextRef
+	tag             string
+	contextOverride string
+	contextRef      string
+	SHA             string
+	// baseSHA is the base of the PR, its changed files being compared with the merge base if enabled
+	baseSHA string
+	// baseRef and labels are the base branch of the PR, the default branch for plain issues, and its labels
+	baseRef   string
+	labels    []string
+	workflows []string
+	event     github.CreateWorkflowDispatchEventRequest
+	// workflowInputs replaces the inputs of event for the workflows not declaring all of them, see declaredInputs
+	workflowInputs map[string]map[string]interface{}
+	marker         string
+	submatch       []string
+	args           map[string]any
+	// force is set for trigger comments ending with --force, running the workflows of large PRs
+	force bool
+	// ignorePaths is set for trigger comments ending with --ignore-paths-filter by trusted reviewers, running the
+	// workflows their paths filters would skip
+	ignorePaths bool
+	// checkNamespace prefixes the names of the check runs created for the workflows, see
+	// config.TriggerConfig.CheckNamespace
+	checkNamespace string
+	logger         zerolog.Logger
+}
+
+// dispatchWorkflows dispatches or skips the workflows of a trigger comment, and acknowledges it. The lookups and
+// dispatches are shared with the other trigger comments of its burst, if any.
+func (h *PRCommentHandler) dispatchWorkflows(ctx context.Context, t triggerDispatch, batch *burstBatch) error {
+	// a push may have moved the head of the PR since the trigger comment was read, e.g. while held in a burst
+	t, proceed, err := h.followHead(ctx, t)
+	if !proceed {
+		return err
+	}
+	client, arianeConfig, logger := t.client, t.arianeConfig, t.logger
 
-	files, err := h.getPRFiles(ctx, client, repositoryOwner, repositoryName, prNumber, logger)
+	// plain issues have no changed files, nor previous runs to skip their workflows for
+	var files []*github.CommitFile
+	if !t.isIssue {
+		files, err = batch.prFiles(t.SHA, func() ([]*github.CommitFile, error) {
+			return getChangedFiles(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, t.baseSHA, t.SHA, t.settings.Pagination.WithDefaults().Files, logger)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// the paths filters of large PRs are slow to evaluate and misleading, all their workflows are run, if enabled
+	large, allowed := h.checkLargeDispatch(t, files)
+	if !allowed {
+		return h.rejectLargePR(ctx, t, large)
+	}
+	// the dispatch policy of the server, if any, has the last word on the workflows run
+	policyAllowed, policyExcluded, err := h.checkPolicy(ctx, t, files)
 	if err != nil {
 		return err
 	}
+	if !policyAllowed.Result {
+		return h.rejectPolicy(ctx, t, policyAllowed)
+	}
+	// the path inputs of the workflows are checked before any of them is dispatched, tag and issue triggers do not
+	// dispatch the changes of a pull request
+	if t.tag == "" && !t.isIssue {
+		pathInputs, checked := h.pathDispatchInputs(ctx, t, files)
+		if !recordDecision(logger, stepInputs, checked).Result {
+			return h.rejectInputs(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, t.commentAuthor, checked, logger)
+		}
+		if len(pathInputs) > 0 {
+			workflowInputs := maps.Clone(t.workflowInputs)
+			if workflowInputs == nil {
+				workflowInputs = map[string]map[string]interface{}{}
+			}
+			maps.Copy(workflowInputs, pathInputs)
+			t.workflowInputs = workflowInputs
+		}
+	}
 
-	for _, workflow := range workflowsToTrigger {
-		if h.shouldSkipWorkflow(ctx, client, repositoryOwner, repositoryName, workflow, SHA, logger) {
+	var extraArgs string
+	if len(t.submatch) > 1 {
+		extraArgs = t.submatch[1]
+	}
+
+	summary := MessageData{Author: t.commentAuthor}
+	// reply with links to the dispatched runs once found, if enabled
+	var links *runLinks
+	if arianeConfig.RunLinks && t.settings.DispatchVerifyTimeout > 0 {
+		links = &runLinks{}
+	}
+	// workflows retried too often for the SHA are neither re-run nor dispatched again, if enabled
+	var retryLimited []SkippedWorkflow
+	for _, workflow := range t.workflows {
+		workflowLogger := logger.With().Str("workflow", workflow).Logger()
+		if excluded, ok := policyExcluded[workflow]; ok {
+			recordDecision(workflowLogger, stepPolicy, excluded)
+			audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", excluded).Send()
+			summary.Skipped = append(summary.Skipped, SkippedWorkflow{Workflow: workflow, Reason: excluded})
 			continue
 		}
+		limited := h.checkRetries(ctx, t.owner, t.repo, workflow, t.SHA, arianeConfig.RetryLimit)
+		if arianeConfig.RetryLimit.MaxRetries > 0 {
+			recordDecision(workflowLogger, stepRetries, limited)
+		}
+		// previous runs of the SHA ran other workflow definitions than the overridden ones
+		if !t.isIssue && t.contextOverride == "" {
+			skip := batch.skip(workflow, t.SHA, func() decision.Decision {
+				return h.shouldSkipWorkflow(ctx, client, t.owner, t.repo, workflow, t.SHA, !limited.Result, logger)
+			})
+			if skip := recordDecision(workflowLogger, stepSkip, skip); skip.Result {
+				audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", skip).Send()
+				summary.Skipped = append(summary.Skipped, SkippedWorkflow{Workflow: workflow, Reason: skip})
+				continue
+			}
+		}
+
+		// skip workflows which already succeeded for the same inputs on another SHA, e.g. before a rebase,
+		// which does not apply to tags, issues and overridden contexts
+		var idempotencyKey string
+		if t.tag == "" && !t.isIssue && t.contextOverride == "" {
+			idempotencyKey, err = arianeConfig.IdempotencyKey(workflow, files, extraArgs, t.args)
+			if err != nil {
+				workflowLogger.Error().Err(err).Msg("Failed to render idempotency key")
+			}
+		}
+		if idempotencyKey != "" {
+			if skip := recordDecision(workflowLogger, stepIdempotency, h.checkIdempotency(ctx, client, t.owner, t.repo, workflow, idempotencyKey, logger)); skip.Result {
+				audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", skip).Send()
+				summary.Skipped = append(summary.Skipped, SkippedWorkflow{Workflow: workflow, Reason: skip})
+				continue
+			}
+		}
 
-		if h.shouldRunWorkflow(ctx, arianeConfig, workflow, files) {
-			if err := h.triggerWorkflow(ctx, client, repositoryOwner, repositoryName, workflow, workflowDispatchEvent, logger); err != nil {
+		// tag and issue triggers ignore the paths filters, which match the PR changes
+		var run decision.Decision
+		switch {
+		case t.tag != "":
+			run = decision.Yes(decision.ReasonTagTrigger, "workflow %s is dispatched on tag %s", workflow, t.tag)
+		case t.isIssue:
+			run = decision.Yes(decision.ReasonIssueTrigger, "workflow %s is dispatched on %s for issue #%d", workflow, t.contextRef, t.prNumber)
+		case large.Result:
+			run = largePRRun(workflow, large)
+		default:
+			run = ignorePathsFilter(t, workflow, h.shouldRunWorkflow(ctx, arianeConfig, workflow, files))
+		}
+		if run.Result && limited.Result {
+			audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", limited).Send()
+			summary.Skipped = append(summary.Skipped, SkippedWorkflow{Workflow: workflow, Reason: limited})
+			retryLimited = append(retryLimited, SkippedWorkflow{Workflow: workflow, Reason: limited})
+			continue
+		}
+		dispatchEvent := t.event
+		if inputs, ok := t.workflowInputs[workflow]; ok {
+			dispatchEvent.Inputs = inputs
+		}
+		// another trigger comment of the burst may have dispatched the workflow with the same inputs already
+		if run.Result && batch != nil {
+			if coalesced := recordDecision(workflowLogger, stepCoalesce, batch.coalesce(workflow, dispatchEvent)); coalesced.Result {
+				audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", coalesced).Send()
+				summary.Skipped = append(summary.Skipped, SkippedWorkflow{Workflow: workflow, Reason: coalesced})
+				continue
+			}
+		}
+		if run := recordDecision(workflowLogger, stepRun, run); run.Result {
+			dispatchEvent.Inputs = h.withAttempt(ctx, t, workflow, dispatchEvent.Inputs)
+			audit.Event(ctx, "workflow_dispatched").Str("workflow", workflow).Object("decision", run).Object("provenance", newProvenance(arianeConfig, h.Version)).Send()
+			// the workflows of a resource pool at its limit are dispatched once a run of the pool completes
+			pool := arianeConfig.Workflows[workflow].Pool
+			slot, queued := h.Pools.acquire(pool, func(slot *poolSlot) {
+				h.dispatchQueued(ctx, t, workflow, dispatchEvent, slot)
+			})
+			if queued {
+				workflowLogger.Info().Msgf("Resource pool %s is at its limit, queueing the dispatch", pool)
+				audit.Event(ctx, "workflow_queued").Str("workflow", workflow).Str("pool", pool).Send()
+				summary.Queued = append(summary.Queued, workflow)
+			} else if err := h.dispatchWorkflow(ctx, t, workflow, dispatchEvent, links, slot); isWorkflowNotFound(err) {
+				// the workflow was deleted or renamed since the config was written, the other ones still run
+				missing := recordDecision(workflowLogger, stepDispatch, workflowNotFound(workflow))
+				audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", missing).Send()
+				summary.Skipped = append(summary.Skipped, SkippedWorkflow{Workflow: workflow, Reason: missing})
+				continue
+			} else if err != nil {
 				return err
 			}
+			summary.Dispatched = append(summary.Dispatched, workflow)
+			h.History.record(t, workflow, decision.ActionDispatch, run, h.Scheduler.Now())
+			h.Retries.record(ctx, t.owner, t.repo, workflow, t.SHA, h.Scheduler.Now())
+			// tag and issue triggers do not test the head of a pull request
+			if t.tag == "" && !t.isIssue {
+				h.Green.dispatched(t.owner, t.repo, t.prNumber, t.SHA, workflow, h.Scheduler.Now())
+			}
+			if idempotencyKey != "" {
+				h.Idempotency.add(ctx, t.owner, t.repo, workflow, idempotencyKey, t.SHA)
+			}
 		} else {
-			if err := h.markWorkflowAsSkipped(ctx, client, repositoryOwner, repositoryName, workflow, SHA, logger); err != nil {
+			audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", run).Send()
+			summary.Skipped = append(summary.Skipped, SkippedWorkflow{Workflow: workflow, Reason: run})
+			if err := markWorkflowAsSkipped(ctx, h.Workflows, client, arianeConfig, t.owner, t.repo, workflow, t.SHA, t.checkNamespace, run, logger); err != nil {
 				return err
 			}
 		}
 	}
 
-	if err := h.reactToComment(ctx, client, repositoryOwner, repositoryName, commentID, logger); err != nil {
+	for _, skipped := range summary.Skipped {
+		recordSavings(arianeConfig, t.owner+"/"+t.repo, skipped.Workflow, skipped.Reason)
+		h.History.record(t, skipped.Workflow, skipAction(skipped), skipped.Reason, h.Scheduler.Now())
+	}
+
+	// nothing new was run, tell the author why rather than letting them wait for runs
+	if len(summary.Dispatched) == 0 && len(summary.Skipped) > 0 {
+		reaction := reactionOrDefault(arianeConfig.Reactions.NothingRun, config.DefaultNothingRunReaction)
+		if err := h.reactToComment(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, t.commentID, t.commentAuthor, reaction, logger); err != nil {
+			return err
+		}
+		return h.postSummary(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, "nothing-run", arianeConfig.Messages.NothingRun, defaultNothingRunMessage, summary, logger)
+	}
+
+	// tell when the workflows retried too often can be retried, the nothing-run message telling it otherwise
+	if len(retryLimited) > 0 {
+		data := MessageData{Author: t.commentAuthor, Skipped: retryLimited}
+		if err := h.postMessage(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, "retry-limited", arianeConfig.Messages.RetryLimited, defaultRetryLimitedMessage, data, logger); err != nil {
+			return err
+		}
+	}
+
+	if links != nil && len(summary.Dispatched) > 0 {
+		// the runs are looked up for up to the verify timeout, leave as much time to reply
+		ctx, cancel := detach(ctx, 2*t.settings.DispatchVerifyTimeout)
+		h.Scheduler.Go(ctx, func(ctx context.Context) {
+			defer cancel()
+			_ = h.postRunLinks(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, t.commentAuthor, t.commentURL, links, logger)
+		})
+	}
+
+	reaction := reactionOrDefault(arianeConfig.Reactions.Dispatched, config.DefaultDispatchedReaction)
+	if err := h.reactToComment(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, t.commentID, t.commentAuthor, reaction, logger); err != nil {
 		return err
 	}
 
+	// reply with the summary message, if configured, or if the skipped workflows have no check runs
+	var defaultSummary string
+	if len(summary.Skipped) > 0 && !arianeConfig.ReportsStatuses() && lacksChecksPermission(t.owner, t.repo) {
+		defaultSummary = defaultChecksFallbackSummaryMessage
+	}
+	return h.postSummary(ctx, client, arianeConfig, t.owner, t.repo, t.prNumber, "summary", arianeConfig.Messages.Summary, defaultSummary, summary, logger)
+}
+
+// dispatchWorkflow dispatches a workflow of a trigger comment, and links the dispatched run in the background.
+// The slot of the workflow resource pool, if any, is held until the run completes.
+func (h *PRCommentHandler) dispatchWorkflow(ctx context.Context, t triggerDispatch, workflow string, event github.CreateWorkflowDispatchEventRequest, links *runLinks, slot *poolSlot) error {
+	client, arianeConfig, logger := t.client, t.arianeConfig, t.logger
+	dispatch := dispatchedRun{workflow: workflow, ref: t.contextRef, SHA: t.SHA, marker: t.marker, dispatchedAt: time.Now(), runLinks: links, poolSlot: slot, checkNamespace: t.checkNamespace}
+	// show the workflow as pending right away, the check run follows the dispatched run once found
+	if arianeConfig.QueuedChecks && t.settings.DispatchVerifyTimeout > 0 {
+		createQueued := h.createQueuedCheck
+		if arianeConfig.ReportsStatuses() {
+			createQueued = h.createQueuedStatus
+		}
+		if check, err := createQueued(ctx, client, t.owner, t.repo, workflow, arianeConfig.DisplayName(workflow), t.SHA, t.checkNamespace, logger); err == nil {
+			dispatch.queuedCheck = &check
+		}
+	}
+	if err := h.triggerWorkflow(ctx, client, t.owner, t.repo, workflow, event, logger); err != nil {
+		h.Pools.release(slot)
+		if isWorkflowNotFound(err) {
+			h.Workflows.forget(t.owner, t.repo, workflow)
+			skipMissingWorkflow(ctx, client, arianeConfig, t.owner, t.repo, workflow, t.SHA, t.checkNamespace, dispatch.queuedCheck, workflowNotFound(workflow), logger)
+		} else if failure.CategoryOf(err) == failure.PermissionDenied {
+			failDeniedDispatch(ctx, h.Workflows, client, arianeConfig, t.owner, t.repo, workflow, t.SHA, t.checkNamespace, dispatch.queuedCheck, err, logger)
+		} else if dispatch.queuedCheck != nil {
+			abandonQueuedCheck(ctx, client, *dispatch.queuedCheck, "neutral", dispatchFailure(workflow, err), logger)
+		}
+		return err
+	}
+	if t.settings.DispatchVerifyTimeout > 0 {
+		ctx, cancel := detach(ctx, t.settings.DispatchVerifyTimeout)
+		links.add()
+		h.Scheduler.Go(ctx, func(ctx context.Context) {
+			defer cancel()
+			defer links.done()
+			_ = h.linkDispatchedRun(ctx, client, t.owner, t.repo, dispatch, logger)
+		})
+	}
 	return nil
 }
 
+// dispatchQueued dispatches in the background a workflow which waited for a slot of its resource pool, within the
+// handler timeout if set. Its run is not linked from the reply of the trigger comment, which was posted already.
+func (h *PRCommentHandler) dispatchQueued(ctx context.Context, t triggerDispatch, workflow string, event github.CreateWorkflowDispatchEventRequest, slot *poolSlot) {
+	ctx = context.WithoutCancel(ctx)
+	var cancel context.CancelFunc = func() {}
+	if h.HandlerTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, h.HandlerTimeout)
+	}
+	h.Scheduler.Go(ctx, func(ctx context.Context) {
+		defer cancel()
+		// the workflows evaluated against a head moved since are not dispatched, if head-moved is set
+		if t.arianeConfig.HeadMoved != "" && !t.isIssue && t.tag == "" {
+			if _, head, err := h.checkHead(ctx, t); err == nil && !recordDecision(t.logger, stepHead, head).Result {
+				h.Pools.release(slot)
+				audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", head).Send()
+				return
+			}
+		}
+		t.logger.Info().Msgf("Dispatching workflow %s queued for a slot of resource pool %s", workflow, slot.pool)
+		if err := h.dispatchWorkflow(ctx, t, workflow, event, nil, slot); err != nil && !isWorkflowNotFound(err) {
+			t.logger.Error().Err(err).Msgf("Failed to dispatch workflow %s queued for its resource pool", workflow)
+		}
+	})
+}
+
+// settings returns the server settings of a repository, with its overrides
+func (h *PRCommentHandler) settings(owner, repo string) config.RepositorySettings {
+	return h.Overrides.For(owner, repo, config.RepositorySettings{
+		DispatchVerifyTimeout: h.DispatchVerifyTimeout,
+		IssueCommands:         h.IssueCommands,
+		Pagination:            h.Pagination,
+	})
+}
+
+// isOwnLogin reports whether a login is the app bot user
+func (h *PRCommentHandler) isOwnLogin(login string) bool {
+	return h.BotLogin != "" && strings.EqualFold(login, h.BotLogin)
+}
+
+// isTrustedBot reports whether a login is one of the trusted bots
+func (h *PRCommentHandler) isTrustedBot(login string) bool {
+	return slices.ContainsFunc(h.TrustedBots, func(bot string) bool { return strings.EqualFold(login, strings.TrimSpace(bot)) })
+}
+
 // getPullRequest returns a PR object to retrieve a pull request metadata
 func (h *PRCommentHandler) getPullRequest(ctx context.Context, client *github.Client, owner, repo string, prNumber int, logger zerolog.Logger) (*github.PullRequest, error) {
+	limit := h.settings(owner, repo).Pagination.WithDefaults().PullRequests
 	opt := &github.PullRequestListOptions{
 		State: "open",
 		ListOptions: github.ListOptions{
-			PerPage: 100,
+			PerPage: limit.PerPage,
 		},
 	}
-	for {
+	for page := 1; page <= limit.MaxPages; page++ {
 		prs, res, err := client.PullRequests.List(ctx, owner, repo, opt)
 		if err != nil {
 			logger.Error().Err(err).Msgf("Failed to retrieve pull request")
@@ -171,7 +883,7 @@ func (h *PRCommentHandler) getPullRequest(ctx context.Context, client *github.Cl
 	return nil, err
 }
 
-func (h *PRCommentHandler) determineContextRef(pr *github.PullRequest, owner, repo string, logger zerolog.Logger) (string, string) {
+func determineContextRef(pr *github.PullRequest, owner, repo string, logger zerolog.Logger) (string, string) {
 	SHA := pr.GetHead().GetSHA()
 	prOwner := pr.GetHead().GetRepo().GetOwner().GetLogin()
 	prRepo := pr.GetHead().GetRepo().GetName()
@@ -188,31 +900,64 @@ func (h *PRCommentHandler) determineContextRef(pr *github.PullRequest, owner, re
 	return contextRef, SHA
 }
 
-// isAllowedTeamMember uses the "Get team membership for a user" to infer if a user can run Ariane
+// isAllowedTeamMember uses the "Get team membership for a user" to infer if a user can run Ariane, and the GraphQL API
+// to look at the child teams of the allowed teams as well, if enabled
 // See https://docs.github.com/en/rest/teams/members?apiVersion=2022-11-28#get-team-membership-for-a-user
-func (h *PRCommentHandler) isAllowedTeamMember(ctx context.Context, client *github.Client, config *config.ArianeConfig, owner, author string, logger zerolog.Logger) bool {
+func (h *PRCommentHandler) isAllowedTeamMember(ctx context.Context, client *github.Client, config *config.ArianeConfig, owner, author string, logger zerolog.Logger) decision.Decision {
 	// No list of allowed teams translate into everyone is allowed
 	if len(config.AllowedTeams) == 0 {
-		return true
+		return decision.Yes(decision.ReasonNoAllowedTeams, "no allowed teams configured")
 	}
+	return isTeamMember(ctx, client, config.NestedTeams, owner, config.AllowedTeams, "allowed", author, logger)
+}
 
-	for _, teamName := range config.AllowedTeams {
+// isTeamMember checks whether a user is an active member of any of teams, or of their child teams if nested is set.
+// kind names the teams in the decision, e.g. "allowed".
+func isTeamMember(ctx context.Context, client *github.Client, nested bool, owner string, teams []string, kind, author string, logger zerolog.Logger) decision.Decision {
+	for _, teamName := range teams {
 		membership, res, err := client.Teams.GetTeamMembershipBySlug(ctx, owner, teamName, author)
 		if err != nil && (res == nil || res.StatusCode != 404) {
 			logger.Error().Err(err).Msgf("Failed to retrieve issue comment author's membership to allowlist orgs/teams")
-			return false
+			return decision.No(decision.ReasonMembershipLookupFailure, "failed to retrieve the membership of %s to team %s", author, teamName)
 		}
 		if res.StatusCode == 404 || membership.GetState() != "active" {
-			logger.Debug().Msgf("User %s is not an (active) member of the team %s", author, teamName)
-			continue
+			// users belonging only to a child team of a team are not members of the team itself
+			if !nested {
+				continue
+			}
+			nestedMember, err := isNestedTeamMember(ctx, client, owner, teamName, author)
+			if err != nil {
+				logger.Error().Err(err).Msgf("Failed to retrieve the membership of %s to the child teams of %s", author, teamName)
+				return decision.No(decision.ReasonMembershipLookupFailure, "failed to retrieve the membership of %s to the child teams of team %s", author, teamName)
+			}
+			if !nestedMember {
+				continue
+			}
+			return decision.Yes(decision.ReasonChildTeamMember, "%s is a member of a child team of team %s", author, teamName)
 		}
-		return true
+		return decision.Yes(decision.ReasonTeamMember, "%s is an active member of team %s", author, teamName)
 	}
-	return false
+	return decision.No(decision.ReasonNotTeamMember, "%s is not an active member of any %s team", author, kind)
 }
 
-// Creates a reference for a workflow, in order to run it via workflow_dispatch
-func (h *PRCommentHandler) createWorkflowDispatchEvent(prNumber int, contextRef, SHA string, submatch []string) github.CreateWorkflowDispatchEventRequest {
+// isPriorContributor uses the search API to check whether a user already had PRs merged in the repository.
+// Users whose history cannot be retrieved are not considered prior contributors.
+func (h *PRCommentHandler) isPriorContributor(ctx context.Context, client *github.Client, owner, repo, author string, logger zerolog.Logger) decision.Decision {
+	query := fmt.Sprintf("repo:%s/%s is:pr is:merged author:%s", owner, repo, author)
+	result, _, err := client.Search.Issues(ctx, query, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 1}})
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to search merged pull requests of %s", author)
+		return decision.No(decision.ReasonContributorLookupFailure, "failed to search the merged pull requests of %s", author)
+	}
+	if result.GetTotal() == 0 {
+		return decision.No(decision.ReasonFirstTimeContributor, "%s has no merged pull requests in %s/%s", author, owner, repo)
+	}
+	return decision.Yes(decision.ReasonPriorContributor, "%s has %d merged pull requests in %s/%s", author, result.GetTotal(), owner, repo)
+}
+
+// Creates a reference for a workflow, in order to run it via workflow_dispatch.
+// Structured args are passed JSON encoded in the args input, if any.
+func (h *PRCommentHandler) createWorkflowDispatchEvent(prNumber int, contextRef, SHA string, submatch []string, args map[string]any) github.CreateWorkflowDispatchEventRequest {
 	workflowDispatchEvent := github.CreateWorkflowDispatchEventRequest{
 		Ref: contextRef,
 		// These are parameters (inputs) on workflow_dispatch
@@ -229,14 +974,51 @@ func (h *PRCommentHandler) createWorkflowDispatchEvent(prNumber int, contextRef,
 			workflowDispatchEvent.Inputs["extra-args"] = string(extraArgs)
 		}
 	}
+	if len(args) > 0 {
+		encodedArgs, err := json.Marshal(args)
+		if err == nil {
+			workflowDispatchEvent.Inputs["args"] = string(encodedArgs)
+		}
+	}
 	return workflowDispatchEvent
 }
 
-// getPRFiles returns the list of files updated as part of a PR
-func (h *PRCommentHandler) getPRFiles(ctx context.Context, client *github.Client, owner, repo string, prNumber int, logger zerolog.Logger) ([]*github.CommitFile, error) {
+// setIssueInputs replaces the PR number input with the metadata of the plain issue a trigger comment is on
+func setIssueInputs(inputs map[string]interface{}, issue *github.Issue) {
+	delete(inputs, "PR-number")
+	inputs["issue-number"] = strconv.Itoa(issue.GetNumber())
+	inputs["issue-title"] = issue.GetTitle()
+}
+
+// compareFilesLimit is the most files GitHub returns when comparing two commits
+const compareFilesLimit = 300
+
+// getChangedFiles returns the files changed by a PR for its paths filters: the files of the PR, or the files changed
+// between the merge base of baseSHA and headSHA and headSHA if changed-files is set to merge-base. Comparisons
+// reaching the limit of files GitHub returns fall back to the files of the PR, which are paged further.
+func getChangedFiles(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, baseSHA, headSHA string, limit config.ListLimit, logger zerolog.Logger) ([]*github.CommitFile, error) {
+	if arianeConfig.ChangedFiles != config.ChangedFilesMergeBase || baseSHA == "" {
+		return getPRFiles(ctx, client, owner, repo, prNumber, limit, logger)
+	}
+	// the three-dot comparison starts from the merge base
+	comparison, _, err := client.Repositories.CompareCommits(ctx, owner, repo, baseSHA, headSHA, &github.ListOptions{PerPage: 1})
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to compare %s with its merge base with %s", headSHA, baseSHA)
+		return nil, err
+	}
+	if len(comparison.Files) >= compareFilesLimit {
+		logger.Warn().Msgf("PR #%d changes at least %d files since its merge base, listing the files of the PR instead", prNumber, len(comparison.Files))
+		return getPRFiles(ctx, client, owner, repo, prNumber, limit, logger)
+	}
+	return comparison.Files, nil
+}
+
+// getPRFiles returns the list of files updated as part of a PR, truncated to the first limit.MaxPages pages
+// as GitHub itself truncates it to 3000 files
+func getPRFiles(ctx context.Context, client *github.Client, owner, repo string, prNumber int, limit config.ListLimit, logger zerolog.Logger) ([]*github.CommitFile, error) {
 	var files []*github.CommitFile
-	opt := &github.ListOptions{PerPage: 500}
-	for {
+	opt := &github.ListOptions{PerPage: limit.PerPage}
+	for page := 1; ; page++ {
 		newFiles, response, err := client.PullRequests.ListFiles(ctx, owner, repo, prNumber, opt)
 		if err != nil {
 			logger.Error().Err(err).Msgf("Failed to retrieve list of files from PR")
@@ -246,63 +1028,84 @@ func (h *PRCommentHandler) getPRFiles(ctx context.Context, client *github.Client
 		if response.NextPage == 0 {
 			break
 		}
+		if page == limit.MaxPages {
+			logger.Warn().Msgf("PR #%d changes more than %d files, only the first ones are considered", prNumber, len(files))
+			break
+		}
 		opt.Page = response.NextPage
 	}
 	return files, nil
 }
 
-func (h *PRCommentHandler) shouldSkipWorkflow(ctx context.Context, client *github.Client, owner, repo, workflow, SHA string, logger zerolog.Logger) bool {
-	runListOpts := &github.ListWorkflowRunsOptions{HeadSHA: SHA, ListOptions: github.ListOptions{PerPage: 1}}
-	runs, _, err := client.Actions.ListWorkflowRunsByFileName(ctx, owner, repo, workflow, runListOpts)
-	if err != nil {
-		logger.Err(err).Msgf("Failed to retrieve list of workflow %s runs for sha=%s", workflow, SHA)
-		return false
-	}
-
-	// Decide if any available workflow needs to be re-run (i.e. in case it failed)
-	if runs != nil && len(runs.WorkflowRuns) > 0 {
-		lastRun := runs.WorkflowRuns[0]
-		logger.Debug().Msgf("shouldSkipWorkflow? %s/%s:%s, workflow: %s, status: %s, conclusion: %s", owner, repo, SHA, workflow, lastRun.GetStatus(), lastRun.GetConclusion())
-		if lastRun.GetStatus() == "completed" {
-			conc := lastRun.GetConclusion()
-			if conc == "success" || conc == "skipped" {
-				logger.Debug().Msgf("Skipping, workflow %s run successfully with the conclusion %s, and there are no changes since the last run", workflow, conc)
-				return true
-			}
-			if conc == "failure" {
-				return false
-				// BUG(auriaave): https://github.com/cilium/ariane/issues/45
-				// var wg sync.WaitGroup
-				// h.rerunFailedJobs(ctx, client, owner, repo, workflow, lastRun.GetID(), &wg, logger)
-				// return true
-			}
+// shouldSkipWorkflow decides whether dispatching a workflow for a SHA can be skipped, because it already
+// succeeded for it, one of its runs is in progress, or the failed jobs of its newest attempt are re-run instead,
+// if rerun is set
+func (h *PRCommentHandler) shouldSkipWorkflow(ctx context.Context, client *github.Client, owner, repo, workflow, SHA string, rerun bool, logger zerolog.Logger) decision.Decision {
+	run, previous := h.previousRun(ctx, client, owner, repo, workflow, SHA, logger)
+	if previous.Reason != decision.ReasonPreviousRunFailed || !decision.CanRerun(run, h.Scheduler.Now()) {
+		return previous
+	}
+	if !rerun || !h.Capabilities.rerunWorkflow() {
+		// failed runs are dispatched again where they cannot be re-run
+		return previous
+	}
+	h.rerunFailedJobs(ctx, client, owner, repo, workflow, run.GetID(), logger)
+	h.Retries.record(ctx, owner, repo, workflow, SHA, h.Scheduler.Now())
+	return decision.Rerun(workflow, SHA, run)
+}
+
+// previousRun lists the runs of a workflow for a SHA, and decides whether it already ran for it, see decision.PreviousRun
+func (h *PRCommentHandler) previousRun(ctx context.Context, client *github.Client, owner, repo, workflow, SHA string, logger zerolog.Logger) (*github.WorkflowRun, decision.Decision) {
+	limit := h.settings(owner, repo).Pagination.WithDefaults().WorkflowRuns
+	runListOpts := &github.ListWorkflowRunsOptions{HeadSHA: SHA, ListOptions: github.ListOptions{PerPage: limit.PerPage}}
+	var workflowRuns []*github.WorkflowRun
+	for page := 1; page <= limit.MaxPages; page++ {
+		runs, response, err := client.Actions.ListWorkflowRunsByFileName(ctx, owner, repo, workflow, runListOpts)
+		if err != nil {
+			logger.Err(err).Msgf("Failed to retrieve list of workflow %s runs for sha=%s", workflow, SHA)
+			return nil, decision.No(decision.ReasonRunLookupFailure, "failed to retrieve the runs of workflow %s for %s", workflow, SHA)
 		}
-	} else {
-		logger.Debug().Msgf("cannot skip workflow %s on %s/%s:%s. 'runs' value is nil? %v. Otherwise, no checks run for this workflow", workflow, owner, repo, SHA, runs == nil)
+		workflowRuns = append(workflowRuns, runs.WorkflowRuns...)
+		if response.NextPage == 0 {
+			break
+		}
+		runListOpts.Page = response.NextPage
 	}
-	// Other conclusions will not be skipped
-	return false
+	return decision.PreviousRun(workflow, SHA, workflowRuns)
 }
 
-func (h *PRCommentHandler) rerunFailedJobs(ctx context.Context, client *github.Client, owner, repo, workflow string, runID int64, wg *sync.WaitGroup, logger zerolog.Logger) {
+// poller waits on GitHub state every Poll.Interval for at most timeout, on the scheduler clock
+func (h *PRCommentHandler) poller(timeout time.Duration) poll.Poller {
+	return poll.Poller{Interval: h.Poll.Interval, Timeout: timeout, Clock: h.Scheduler.Clock}
+}
+
+func (h *PRCommentHandler) rerunFailedJobs(ctx context.Context, client *github.Client, owner, repo, workflow string, runID int64, logger zerolog.Logger) {
 	jobListOpts := &github.ListWorkflowJobsOptions{ListOptions: github.ListOptions{PerPage: 200}}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		ctx, cancel := context.WithTimeout(context.Background(), h.RunDelay+time.Second*5)
+	failure.Dispatched(ctx)
+	ctx, cancel := detach(ctx, h.Poll.Timeout)
+	h.Scheduler.Go(ctx, func(ctx context.Context) {
 		defer cancel()
 
+		if !h.Capabilities.rerunFailedJobs() {
+			logger.Debug().Msgf("re-running workflow %s run_id %d, as re-running its failed jobs is not supported", workflow, runID)
+			if _, err := client.Actions.RerunWorkflowByID(ctx, owner, repo, runID); err != nil {
+				logger.Error().Err(err).Msgf("Failed to re-run workflow %s run_id %d", workflow, runID)
+			}
+			return
+		}
+
 		jobs, _, err := client.Actions.ListWorkflowJobs(ctx, owner, repo, runID, jobListOpts)
 		if err != nil {
 			logger.Err(err).Msgf("Failed to list workflow %s jobs run_id %d", workflow, runID)
 			return
 		}
 
-		var jobID int64
+		var jobID, attempt int64
 		// Find the commit-status-start job
 		for _, job := range jobs.Jobs {
 			if job.GetName() == "Commit Status Start" {
 				jobID = job.GetID()
+				attempt = job.GetRunAttempt()
 				break
 			}
 		}
@@ -312,23 +1115,29 @@ func (h *PRCommentHandler) rerunFailedJobs(ctx context.Context, client *github.C
 				logger.Error().Err(err).Msgf("Failed to re-run commit-status-start job_id %d", jobID)
 				return
 			}
-			time.Sleep(h.RunDelay)
+			// wait for the new run attempt re-running the job to complete, before re-running the failed jobs
+			err := h.poller(h.Poll.Timeout).Until(ctx, func(ctx context.Context) (bool, error) {
+				run, _, err := client.Actions.GetWorkflowRunByID(ctx, owner, repo, runID)
+				if err != nil {
+					return false, err
+				}
+				return int64(run.GetRunAttempt()) > attempt && run.GetStatus() == "completed", nil
+			})
+			if err != nil {
+				logger.Error().Err(err).Msgf("Failed waiting for commit-status-start job_id %d to complete", jobID)
+				return
+			}
 		}
 
 		logger.Debug().Msgf("re-running failed workflow %s run_id %d", workflow, runID)
 		if _, err := client.Actions.RerunFailedJobsByID(ctx, owner, repo, runID); err != nil {
 			logger.Error().Err(err).Msgf("Failed to re-run workflow %s job_id %d", workflow, runID)
 		}
-	}()
+	})
 }
 
-func (h *PRCommentHandler) shouldRunWorkflow(ctx context.Context, config *config.ArianeConfig, workflow string, files []*github.CommitFile) bool {
-	if _, ok := config.Workflows[workflow]; ok {
-		return config.ShouldRunWorkflow(ctx, workflow, files)
-	}
-	// Runs this if the "workflows" section in ariane-config.yaml
-	// does not contain the worfklow (e.g. foo.yaml)
-	return config.ShouldRunOnlyWorkflows(ctx, workflow, files)
+func (h *PRCommentHandler) shouldRunWorkflow(ctx context.Context, config *config.ArianeConfig, workflow string, files []*github.CommitFile) decision.Decision {
+	return config.ShouldRun(ctx, workflow, files)
 }
 
 func (h *PRCommentHandler) triggerWorkflow(ctx context.Context, client *github.Client, owner, repo, workflow string, event github.CreateWorkflowDispatchEventRequest, logger zerolog.Logger) error {
@@ -336,33 +1145,86 @@ func (h *PRCommentHandler) triggerWorkflow(ctx context.Context, client *github.C
 		logger.Error().Err(err).Msg("Failed to create workflow dispatch event")
 		return err
 	}
+	failure.Dispatched(ctx)
 	return nil
 }
 
-func (h *PRCommentHandler) markWorkflowAsSkipped(ctx context.Context, client *github.Client, owner, repo, workflow, SHA string, logger zerolog.Logger) error {
-	githubWorkflow, _, err := client.Actions.GetWorkflowByFileName(ctx, owner, repo, workflow)
+// skippedExternalID identifies the check run marking a workflow as skipped
+func skippedExternalID(workflow string) string {
+	return skippedExternalIDPrefix + workflow
+}
+
+// markWorkflowAsSkipped creates a skipped check run for the workflow, explaining why it was skipped
+func markWorkflowAsSkipped(ctx context.Context, workflows *WorkflowCache, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo, workflow, SHA, checkNamespace string, reason decision.Decision, logger zerolog.Logger) error {
+	githubWorkflow, err := workflows.getWorkflow(ctx, client, owner, repo, workflow)
 	if err != nil {
 		logger.Error().Err(err).Msg("Failed to retrieve workflow")
 		return err
 	}
+	name := checkName(checkNamespace, githubWorkflow.GetName())
+
+	// the workflow file links the commit status, to carry it over to new PR heads
+	if arianeConfig.ReportsStatuses() {
+		description := fmt.Sprintf("%s%s (%s)", skippedStatusPrefix, reason.Message, reason.Reason)
+		if err := createStatus(ctx, client, owner, repo, SHA, name, "success", description, githubWorkflow.GetHTMLURL()); err != nil {
+			logger.Error().Err(err).Msg("Failed to set commit status")
+			return err
+		}
+		return nil
+	}
 
+	// the skipped workflows are reported in the summary comment instead, see denyChecks
+	if lacksChecksPermission(owner, repo) {
+		logger.Debug().Msg("Creating check runs is denied in the repository, not marking the workflow as skipped")
+		return nil
+	}
+	title := skippedCheckTitle
+	summary := fmt.Sprintf("%s was skipped: %s (`%s`).", arianeConfig.DisplayName(workflow), reason.Message, reason.Reason)
+	if description := arianeConfig.Workflows[workflow].Description; description != "" {
+		summary += "\n\n" + description
+	}
 	checkRunOptions := github.CreateCheckRunOptions{
-		Name:       githubWorkflow.GetName(),
+		Name:       name,
 		HeadSHA:    SHA,
 		Status:     github.String("completed"),
 		Conclusion: github.String("skipped"),
+		ExternalID: github.String(skippedExternalID(workflow)),
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
 	}
 	if _, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, checkRunOptions); err != nil {
+		if denyChecks(owner, repo, err, logger) {
+			return nil
+		}
 		logger.Error().Err(err).Msg("Failed to set check run")
 		return err
 	}
 	return nil
 }
 
-func (h *PRCommentHandler) reactToComment(ctx context.Context, client *github.Client, owner, repo string, commentID int64, logger zerolog.Logger) error {
-	if _, _, err := client.Reactions.CreateIssueCommentReaction(ctx, owner, repo, commentID, "rocket"); err != nil {
+// reactToComment acknowledges a trigger comment with a reaction, falling back to a comment if the reaction cannot be
+// created and reactions.fallback-comment is set
+func (h *PRCommentHandler) reactToComment(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, commentID int64, author, reaction string, logger zerolog.Logger) error {
+	// workflows dispatched for other events, e.g. pull requests marked ready for review, have no comment to react to
+	if commentID == 0 {
+		return nil
+	}
+	err := createCommentReaction(ctx, client, owner, repo, commentID, reaction)
+	if err == nil {
+		return nil
+	}
+	if !arianeConfig.Reactions.FallbackComment {
 		logger.Error().Err(err).Msg("Failed to react to comment")
 		return err
 	}
-	return nil
+	logger.Warn().Err(err).Msgf("Failed to react to comment with %q, replying with a comment instead", reaction)
+	data := MessageData{Author: author, Reaction: reactionEmojis[reaction]}
+	return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "reaction-fallback", arianeConfig.Messages.ReactionFallback, defaultReactionFallbackMessage, data, logger)
+}
+
+// reactionOrDefault returns the configured reaction, or defaultReaction if it is not set
+func reactionOrDefault(reaction, defaultReaction string) string {
+	if reaction == "" {
+		return defaultReaction
+	}
+	return reaction
 }
@@ -8,12 +8,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/http/httputil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
-	"sync"
 	"testing"
 	"time"
 
@@ -21,6 +23,10 @@ import (
 
 	github "github.com/google/go-github/v75/github"
 	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/fakegithub"
+	"github.com/cilium/ariane/internal/poll"
+	"github.com/cilium/ariane/internal/scheduler"
 	"github.com/rs/zerolog"
 	githubv4 "github.com/shurcooL/githubv4"
 	gomock "go.uber.org/mock/gomock"
@@ -37,7 +43,6 @@ func TestHandle_NotaPR(t *testing.T) {
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second,
 	}
 
 	payload := []byte(`{
@@ -72,7 +77,6 @@ func TestHandle_ActionNotCreated(t *testing.T) {
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second,
 	}
 	// Action can be created, edited, or delited
 	// The GHApp only reacts to "created"
@@ -104,12 +108,54 @@ func TestHandle_ActionNotCreated(t *testing.T) {
 func TestHandle_IsInvalidBot(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	mockClientCreator := NewMockClientCreator(mockCtrl)
-	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(nil, nil)
+	// comments of unsupported bots are ignored before creating a client
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Times(0)
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second,
+		TrustedBots:   []string{"owner-test [bot]"},
+	}
+
+	// bots are only trusted if listed, even if their login starts with the repository owner
+	for _, login := range []string{"user [bot]", "owner-other[bot]"} {
+		payload := []byte(`{
+			"issue": {
+				"pull_request": {}
+			},
+			"action": "created",
+			"repository": {
+				"owner": {
+					"login": "owner"
+				},
+				"name": "repo"
+			},
+			"comment": {
+				"id": 1,
+				"user": {
+					"login": "` + login + `"
+				},
+				"body": "trigger"
+			}
+		}`)
+
+		err := handler.Handle(context.Background(), "issue_comment", "deliveryID", payload)
+		assert.NoError(t, err, login)
 	}
+}
+
+func TestHandle_NoCachedTrigger(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	// comments matching no trigger of the cached configs are ignored before creating a client
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Times(0)
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+		ConfigCache:   config.NewCache(time.Minute),
+	}
+	handler.ConfigCache.Set("owner", "repo", "main", &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+	})
 
 	payload := []byte(`{
 		"issue": {
@@ -125,9 +171,113 @@ func TestHandle_IsInvalidBot(t *testing.T) {
 		"comment": {
 			"id": 1,
 			"user": {
-				"login": "user [bot]"
+				"login": "user"
 			},
-			"body": "trigger"
+			"body": "looks good to me"
+		}
+	}`)
+
+	err := handler.Handle(context.Background(), "issue_comment", "deliveryID", payload)
+	assert.NoError(t, err)
+
+	matched, known := handler.ConfigCache.MatchesTrigger("owner", "repo", "/test")
+	assert.True(t, matched)
+	assert.True(t, known)
+	matched, known = handler.ConfigCache.MatchesTrigger("owner", "other", "/test")
+	assert.False(t, matched)
+	assert.False(t, known)
+}
+
+func TestHandle_ColdConfigCache(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+
+	var fetched []string
+	configGetArianeConfigFromRepository = func(client *github.Client, ctx context.Context, owner, repoName, ref string) (*config.ArianeConfig, error) {
+		fetched = append(fetched, ref)
+		return &config.ArianeConfig{
+			Triggers: map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+		}, nil
+	}
+
+	fake := fakegithub.New()
+	defer fake.Close()
+	repo := fake.AddRepo("owner", "repo")
+	repo.AddPullRequest(fakegithub.PullRequest{Number: 1, HeadRef: "pr/owner/mybugfix", HeadSHA: "mock-sha"})
+	var lookups int
+	fake.Handle("GET /repos/owner/repo/pulls/1", func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(fake.Client(), nil).AnyTimes()
+
+	comment := func(handler *PRCommentHandler, body string) {
+		payload, _ := json.Marshal(&github.IssueCommentEvent{
+			Action:  github.Ptr("created"),
+			Repo:    repo.Repository(),
+			Issue:   &github.Issue{Number: github.Ptr(1), PullRequestLinks: &github.PullRequestLinks{}},
+			Comment: repo.AddComment(1, "user", body),
+		})
+		assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", payload))
+	}
+
+	testCases := []struct {
+		ConfigCache     *config.Cache
+		ExpectedFetched []string
+		ExpectedReason  string
+	}{
+		{
+			ConfigCache:     config.NewCache(time.Minute),
+			ExpectedFetched: []string{"main"},
+			ExpectedReason:  "the config of the default branch is fetched once into a cold cache, to match the next comments against",
+		},
+		{
+			ExpectedFetched: []string{"main", "main"},
+			ExpectedReason:  "the config of the default branch is fetched for each comment if the cache is disabled",
+		},
+	}
+
+	for idx, testCase := range testCases {
+		fetched, lookups = nil, 0
+		handler := &PRCommentHandler{ClientCreator: mockClientCreator, ConfigCache: testCase.ConfigCache}
+		comment(handler, "looks good to me")
+		comment(handler, "thanks!")
+		assert.Equal(t, testCase.ExpectedFetched, fetched, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, 0, lookups, "[TEST%v] comments matching no trigger do not look up their pull request", idx+1)
+	}
+}
+
+func TestHandle_IsOwnBot(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Times(0)
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+		BotLogin:      "owner-ariane[bot]",
+	}
+
+	// replies posted by Ariane are ignored, even though they look like comments of a valid bot
+	payload := []byte(`{
+		"issue": {
+			"pull_request": {}
+		},
+		"action": "created",
+		"repository": {
+			"owner": {
+				"login": "owner"
+			},
+			"name": "repo"
+		},
+		"comment": {
+			"id": 1,
+			"user": {
+				"login": "owner-ariane[bot]"
+			},
+			"body": "/test"
 		}
 	}`)
 
@@ -135,6 +285,62 @@ func TestHandle_IsInvalidBot(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHandle_Inactive(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Times(0)
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+	}
+
+	// no API calls are made for locked conversations or archived repositories
+	payloads := []string{`{
+		"issue": {
+			"pull_request": {},
+			"locked": true
+		},
+		"action": "created",
+		"repository": {
+			"owner": {
+				"login": "owner"
+			},
+			"name": "repo"
+		},
+		"comment": {
+			"id": 1,
+			"user": {
+				"login": "user"
+			},
+			"body": "/test"
+		}
+	}`, `{
+		"issue": {
+			"pull_request": {}
+		},
+		"action": "created",
+		"repository": {
+			"owner": {
+				"login": "owner"
+			},
+			"name": "repo",
+			"archived": true
+		},
+		"comment": {
+			"id": 1,
+			"user": {
+				"login": "user"
+			},
+			"body": "/test"
+		}
+	}`}
+
+	for _, payload := range payloads {
+		err := handler.Handle(context.Background(), "issue_comment", "deliveryID", []byte(payload))
+		assert.NoError(t, err)
+	}
+}
+
 func TestHandle_IsValidBot(t *testing.T) {
 	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
 	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
@@ -152,7 +358,7 @@ func TestHandle_IsValidBot(t *testing.T) {
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second,
+		TrustedBots:   []string{"owner-test [bot]"},
 	}
 
 	payload := []byte(`{
@@ -196,7 +402,6 @@ func TestHandle(t *testing.T) {
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second,
 	}
 
 	payload := []byte(`{
@@ -223,6 +428,284 @@ func TestHandle(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHandle_IssueCommand(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+
+	configGetArianeConfigFromRepository = mockGetArianeConfigFromRepository
+
+	mockServer := setMockServer()
+	defer mockServer.Close()
+	// record the dispatches, forwarding all requests to the mock server
+	mockURL, _ := url.Parse(mockServer.URL)
+	proxy := httputil.NewSingleHostReverseProxy(mockURL)
+	var dispatches []github.CreateWorkflowDispatchEventRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/dispatches") {
+			var dispatch github.CreateWorkflowDispatchEventRequest
+			body, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &dispatch)
+			dispatches = append(dispatches, dispatch)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(client, nil).AnyTimes()
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+		IssueCommands: true,
+	}
+
+	payload := func(body string) []byte {
+		return []byte(fmt.Sprintf(`{
+			"issue": {
+				"number": 7,
+				"title": "Docs are stale"
+			},
+			"action": "created",
+			"repository": {
+				"owner": {
+					"login": "owner"
+				},
+				"name": "repo",
+				"default_branch": "main"
+			},
+			"comment": {
+				"id": 1,
+				"user": {
+					"login": "trustedauthor"
+				},
+				"body": %q
+			}
+		}`, body))
+	}
+
+	// triggers not enabled on issues are ignored
+	assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", payload("/test")))
+	assert.Empty(t, dispatches)
+
+	assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", payload("/redeploy-test")))
+	assert.Len(t, dispatches, 1)
+	assert.Equal(t, "main", dispatches[0].Ref)
+	assert.Equal(t, map[string]interface{}{"issue-number": "7", "issue-title": "Docs are stale", "context-ref": "main", "SHA": "main-sha"}, dispatches[0].Inputs)
+}
+
+func TestHandle_SecondApproval(t *testing.T) {
+	configGetArianeConfigFromRepository = mockGetArianeConfigFromRepository
+
+	mockServer := setMockServer()
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(client, nil).AnyTimes()
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+		Approvals:     NewApprovalStore(nil, time.Hour),
+	}
+
+	payload := func(commentID, author string) []byte {
+		return []byte(`{
+			"issue": {
+				"pull_request": {}
+			},
+			"action": "created",
+			"repository": {
+				"owner": {
+					"login": "owner"
+				},
+				"name": "repo"
+			},
+			"comment": {
+				"id": ` + commentID + `,
+				"user": {
+					"login": "` + author + `"
+				},
+				"body": "/deploy-test"
+			}
+		}`)
+	}
+
+	assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", payload("6", "trustedauthor")))
+	assert.Equal(t, 1, handler.Approvals.Len(), "trigger comments requiring a second approval are held, even from allowed users")
+	assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", payload("7", "trustedauthor")))
+	assert.Equal(t, 2, handler.Approvals.Len(), "repeating one's own command is not a second approval")
+	assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", payload("8", "secondauthor")))
+	assert.Equal(t, 1, handler.Approvals.Len(), "a second allowed user repeating the command releases it")
+}
+
+func TestHandle_HoldForApproval(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+
+	configGetArianeConfigFromRepository = mockGetArianeConfigFromRepository
+
+	mockServer := setMockServer()
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(client, nil).AnyTimes()
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+		Approvals:     NewApprovalStore(nil, time.Hour),
+	}
+
+	for _, commentID := range []string{"2", "3", "4", "5"} {
+		payload := []byte(`{
+			"issue": {
+				"pull_request": {}
+			},
+			"action": "created",
+			"repository": {
+				"owner": {
+					"login": "owner"
+				},
+				"name": "repo"
+			},
+			"comment": {
+				"id": ` + commentID + `,
+				"user": {
+					"login": "unknownauthor"
+				},
+				"body": "/test"
+			}
+		}`)
+
+		err := handler.Handle(context.Background(), "issue_comment", "deliveryID", payload)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 4, handler.Approvals.Len(), "trigger comments from non-allowed users are held")
+
+	deleted := []byte(`{
+		"issue": {
+			"pull_request": {}
+		},
+		"action": "deleted",
+		"repository": {
+			"owner": {
+				"login": "owner"
+			},
+			"name": "repo"
+		},
+		"comment": {
+			"id": 5,
+			"user": {
+				"login": "unknownauthor"
+			},
+			"body": "/test"
+		}
+	}`)
+	assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", deleted))
+	assert.Equal(t, 3, handler.Approvals.Len(), "deleted comments are cancelled")
+
+	handler.pollApprovals(context.Background())
+	assert.Equal(t, 1, handler.Approvals.Len(), "only the comment approved by an allowed user is released, and the one reacted to with the cancel reaction is dropped")
+	_, stillHeld := handler.Approvals.pending(context.Background(), time.Now())[3]
+	assert.True(t, stillHeld, "comment authors cannot approve their own comments")
+
+	assert.Empty(t, handler.Approvals.pending(context.Background(), time.Now().Add(2*time.Hour)), "held comments expire")
+}
+
+func TestHandle_ApprovedHeadMoved(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+
+	configGetArianeConfigFromRepository = func(client *github.Client, ctx context.Context, owner, repoName, ref string) (*config.ArianeConfig, error) {
+		return &config.ArianeConfig{
+			AllowedTeams:     []string{"organization-members"},
+			ApprovalReaction: "rocket",
+			Triggers:         map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}},
+		}, nil
+	}
+
+	fake := fakegithub.New()
+	defer fake.Close()
+	repo := fake.AddRepo("owner", "repo")
+	repo.AddPullRequest(fakegithub.PullRequest{Number: 1, HeadRef: "pr/owner/mybugfix", HeadSHA: "held-sha", Files: []string{"foo.go"}})
+	repo.AddWorkflow("foo.yaml", "Foo")
+	fake.AddTeamMember("owner", "organization-members", "trustedauthor", "active")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(fake.Client(), nil).AnyTimes()
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+		Approvals:     NewApprovalStore(nil, time.Hour),
+	}
+
+	comment := repo.AddComment(1, "unknownauthor", "/test")
+	payload, _ := json.Marshal(&github.IssueCommentEvent{
+		Action:  github.Ptr("created"),
+		Repo:    repo.Repository(),
+		Issue:   &github.Issue{Number: github.Ptr(1), PullRequestLinks: &github.PullRequestLinks{}},
+		Comment: comment,
+	})
+	assert.NoError(t, handler.Handle(context.Background(), "issue_comment", "deliveryID", payload))
+	assert.Equal(t, 1, handler.Approvals.Len(), "trigger comments from non-allowed users are held")
+
+	// the author pushes after a maintainer approved the comment, but before the approval is polled
+	repo.AddReaction(comment.GetID(), "trustedauthor", "rocket")
+	repo.PushPullRequest(1, "pushed-sha")
+	handler.pollApprovals(context.Background())
+	handler.Scheduler.Wait()
+
+	assert.Equal(t, 0, handler.Approvals.Len(), "approved comments are released")
+	assert.Empty(t, repo.Dispatches(), "the commits pushed since the comment was approved are not run")
+	comments := repo.Comments(1)
+	if assert.Len(t, comments, 2) {
+		assert.Contains(t, comments[1].GetBody(), "the head of the pull request moved from held-sh to pushed-")
+	}
+}
+
+func Test_isPriorContributor(t *testing.T) {
+	mockServer := setMockServer()
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	handler := &PRCommentHandler{}
+	var logger zerolog.Logger
+	testCases := []struct {
+		Author         string
+		ExpectedResult bool
+		ExpectedCode   decision.Reason
+		ExpectedReason string
+	}{
+		{
+			Author:         "trustedauthor",
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPriorContributor,
+			ExpectedReason: "trustedauthor has merged pull requests.",
+		},
+		{
+			Author:         "newcontributor",
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonFirstTimeContributor,
+			ExpectedReason: "newcontributor has no merged pull requests.",
+		},
+	}
+	for idx, testCase := range testCases {
+		result := handler.isPriorContributor(context.Background(), client, "owner", "repo", testCase.Author, logger)
+		assert.Equal(t, testCase.ExpectedResult, result.Result, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
+
 func Test_isAllowedTeamMember(t *testing.T) {
 	mockServer := setMockServer()
 	defer mockServer.Close()
@@ -234,7 +717,6 @@ func Test_isAllowedTeamMember(t *testing.T) {
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second,
 	}
 
 	var logger zerolog.Logger
@@ -242,6 +724,7 @@ func Test_isAllowedTeamMember(t *testing.T) {
 		ArianeConfig   *config.ArianeConfig
 		Author         string
 		ExpectedResult bool
+		ExpectedCode   decision.Reason
 		ExpectedReason string
 	}{
 		{
@@ -250,6 +733,7 @@ func Test_isAllowedTeamMember(t *testing.T) {
 			},
 			Author:         "trustedauthor",
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonTeamMember,
 			ExpectedReason: "trustedauthor is an active member of organization-members.",
 		},
 		{
@@ -258,6 +742,7 @@ func Test_isAllowedTeamMember(t *testing.T) {
 			},
 			Author:         "unknownauthor",
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNotTeamMember,
 			ExpectedReason: "unknown is a non-active member of organization-members.",
 		},
 		{
@@ -266,21 +751,85 @@ func Test_isAllowedTeamMember(t *testing.T) {
 			},
 			Author:         "author",
 			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNotTeamMember,
 			ExpectedReason: "author cannot be found under non-existing-organization.",
 		},
 	}
 	for idx, testCase := range testCases {
 		result := handler.isAllowedTeamMember(context.Background(), client, testCase.ArianeConfig, "owner", testCase.Author, logger)
-		if result != testCase.ExpectedResult {
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] isAllowedTeamMember: %s", idx+1, result.Message)
+		if result.Result != testCase.ExpectedResult {
 			t.Errorf(
 				`[TEST%v] isAllowedTeamMember failed.
 				result: %v, expected: %v
 				Expected reason to pass the test: %v`,
-				idx+1, result, testCase.ExpectedResult, testCase.ExpectedReason)
+				idx+1, result.Result, testCase.ExpectedResult, testCase.ExpectedReason)
 		}
 	}
 }
 
+func Test_getArianeConfigOrDefault(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+	var refs []string
+	configGetArianeConfigFromRepository = func(client *github.Client, ctx context.Context, owner, repoName, ref string) (*config.ArianeConfig, error) {
+		refs = append(refs, ref)
+		switch ref {
+		case "main":
+			return &config.ArianeConfig{AllowedTeams: []string{"main-team"}}, nil
+		case "invalid-branch":
+			return nil, fmt.Errorf("failed parsing configuration file")
+		default:
+			return nil, fmt.Errorf("config file %s %w in repository", config.ArianeConfigPath, config.ErrNotFound)
+		}
+	}
+
+	var logs bytes.Buffer
+	logger := zerolog.New(&logs)
+	ctx := logger.WithContext(context.Background())
+	repository := &github.Repository{
+		Owner:         &github.User{Login: github.String("owner")},
+		Name:          github.String("repo"),
+		DefaultBranch: github.String("main"),
+	}
+
+	arianeConfig, err := getArianeConfigOrDefault(ctx, nil, nil, repository, "old-branch", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"main-team"}, arianeConfig.AllowedTeams, "refs without config fall back to the default branch")
+	assert.Equal(t, []string{"old-branch", "main"}, refs)
+	assert.Contains(t, logs.String(), `"audit_action":"config_fallback"`)
+
+	refs = nil
+	_, err = getArianeConfigOrDefault(ctx, nil, nil, repository, "invalid-branch", logger)
+	assert.Error(t, err, "invalid configs do not fall back")
+	assert.Equal(t, []string{"invalid-branch"}, refs)
+}
+
+func Test_resolveTag(t *testing.T) {
+	mockServer := setMockServer()
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	handler := &PRCommentHandler{}
+	var logger zerolog.Logger
+	testCases := []struct {
+		Tag          string
+		ExpectedSHA  string
+		ExpectedCode decision.Reason
+	}{
+		{Tag: "v1.0.0", ExpectedSHA: "tag-sha", ExpectedCode: decision.ReasonTagFound},
+		{Tag: "v9.9.9", ExpectedCode: decision.ReasonTagNotFound},
+		{Tag: "", ExpectedCode: decision.ReasonTagNotFound},
+		{Tag: "v0.0.0-error", ExpectedCode: decision.ReasonTagLookupFailure},
+	}
+	for idx, testCase := range testCases {
+		SHA, result := handler.resolveTag(context.Background(), client, "owner", "repo", testCase.Tag, logger)
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] resolveTag: %s", idx+1, result.Message)
+		assert.Equal(t, testCase.ExpectedSHA, SHA, "[TEST%v]", idx+1)
+	}
+}
+
 func Test_rerunFailedJobs(t *testing.T) {
 	mockServer := setMockServer()
 	defer mockServer.Close()
@@ -292,14 +841,13 @@ func Test_rerunFailedJobs(t *testing.T) {
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second * 30000,
+		Poll:          poll.Poller{Interval: time.Millisecond, Timeout: time.Second},
 	}
 
 	logWriter := &LogWriter{}
 	logger := zerolog.New(logWriter)
-	var wg sync.WaitGroup
-	handler.rerunFailedJobs(context.Background(), client, "owner", "repo", "foobar.yaml", int64(99), &wg, logger)
-	wg.Wait()
+	handler.rerunFailedJobs(context.Background(), client, "owner", "repo", "foobar.yaml", int64(99), logger)
+	handler.Scheduler.Wait()
 	var result struct {
 		Level   string `json:"level,omitempty"`
 		Message string `json:"message,omitempty"`
@@ -317,6 +865,10 @@ func Test_rerunFailedJobs(t *testing.T) {
 	// This part will need extra implementation on mockServer (to respond with an appropriate job)
 }
 
+// foobarCreatedAt is when the failed run of foobar.yaml was created, long ago so that only the clock of the handler
+// decides whether it can still be re-run
+var foobarCreatedAt = time.Date(2024, time.January, 2, 0, 0, 0, 0, time.UTC)
+
 func Test_shouldSkipWorkflow(t *testing.T) {
 	mockServer := setMockServer()
 	defer mockServer.Close()
@@ -328,136 +880,393 @@ func Test_shouldSkipWorkflow(t *testing.T) {
 
 	handler := &PRCommentHandler{
 		ClientCreator: mockClientCreator,
-		RunDelay:      time.Second,
 	}
 
 	var logger zerolog.Logger
 	testCases := []struct {
-		Workflow       string
+		Workflow string
+		// Age is how long after the creation of the failed run of foobar.yaml the workflow is evaluated
+		Age            time.Duration
 		ExpectedResult bool
+		ExpectedCode   decision.Reason
 		ExpectedReason string
 	}{
 		{
 			Workflow:       "foo.yaml",
-			ExpectedResult: false,
-			ExpectedReason: "cancelled jobs are not skipped.",
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPreviousRunPending,
+			ExpectedReason: "runs in progress are not dispatched again.",
 		},
 		{
 			Workflow:       "bar.yaml",
 			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPreviousRunSucceeded,
 			ExpectedReason: "status=completed, conclusion=success are skipped.",
 		},
 		{
 			Workflow:       "foobar.yaml",
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPreviousRunRerun,
+			ExpectedReason: "status=completed, conclusion=failure are re-run, and skipped.",
+		},
+		{
+			Workflow:       "foobar.yaml",
+			Age:            31 * 24 * time.Hour,
 			ExpectedResult: false,
-			ExpectedReason: "status=completed, conclusion=failure are not skipped.",
-			// BUG(auriaave): https://github.com/cilium/ariane/issues/45
-			// ExpectedResult: true,
-			// ExpectedReason: "status=completed, conclusion=failure are re-run, and skipped.",
+			ExpectedCode:   decision.ReasonPreviousRunFailed,
+			ExpectedReason: "failed runs too old to be re-run, as the clock of the handler tells, are dispatched again.",
+		},
+		{
+			Workflow:       "rerun.yaml",
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonPreviousRunSucceeded,
+			ExpectedReason: "the newest attempt decides, rather than the last created run.",
+		},
+		{
+			Workflow:       "unknown.yaml",
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNoPreviousRun,
+			ExpectedReason: "workflows without runs are not skipped.",
 		},
 	}
 
 	for idx, testCase := range testCases {
-		result := handler.shouldSkipWorkflow(context.Background(), client, "owner", "repo", testCase.Workflow, "mock-sha", logger)
-		if result != testCase.ExpectedResult {
+		handler.Scheduler.Clock = scheduler.NewFakeClock(foobarCreatedAt.Add(time.Hour + testCase.Age))
+		result := handler.shouldSkipWorkflow(context.Background(), client, "owner", "repo", testCase.Workflow, "mock-sha", true, logger)
+		handler.Scheduler.Wait()
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] shouldSkipWorkflow: %s", idx+1, result.Message)
+		if result.Result != testCase.ExpectedResult {
 			t.Errorf(
 				`[TEST%v] shouldSkipWorkflow failed.
 				result: %v, expected: %v
 				Expected reason to pass the test: %v`,
-				idx+1, result, testCase.ExpectedResult, testCase.ExpectedReason)
+				idx+1, result.Result, testCase.ExpectedResult, testCase.ExpectedReason)
 		}
 	}
 }
 
-// Helper functions
+func Test_validateDispatchInputs(t *testing.T) {
+	handler := &PRCommentHandler{}
+	testCases := []struct {
+		Submatch       []string
+		ExpectedResult bool
+		ExpectedCode   decision.Reason
+		ExpectedReason string
+	}{
+		{
+			Submatch:       []string{"/test"},
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonInputsValid,
+			ExpectedReason: "inputs without extra-args are within the limits.",
+		},
+		{
+			Submatch:       []string{"/test foo", "foo"},
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonInputsValid,
+			ExpectedReason: "short extra-args are within the limits.",
+		},
+		{
+			Submatch:       []string{"/test ...", strings.Repeat("a", maxDispatchInputsSize)},
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonInputsTooLarge,
+			ExpectedReason: "extra-args exceeding the size limit would be rejected by GitHub.",
+		},
+	}
+	for idx, testCase := range testCases {
+		event := handler.createWorkflowDispatchEvent(1, "main", "mock-sha", testCase.Submatch, nil)
+		result := validateDispatchInputs(event.Inputs)
+		assert.Equal(t, testCase.ExpectedResult, result.Result, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
 
-func setMockServer() *httptest.Server {
+	inputs := map[string]interface{}{}
+	for i := 0; i <= maxDispatchInputs; i++ {
+		inputs[strconv.Itoa(i)] = "value"
+	}
+	assert.Equal(t, decision.ReasonTooManyInputs, validateDispatchInputs(inputs).Reason)
+}
+
+func Test_linkDispatchedRun(t *testing.T) {
+	mockServer := setMockServer()
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+
+	handler := &PRCommentHandler{
+		ClientCreator: mockClientCreator,
+		Poll:          poll.Poller{Interval: time.Millisecond},
+	}
+
+	var logger zerolog.Logger
+	testCases := []struct {
+		Workflow       string
+		ExpectedError  error
+		ExpectedReason string
+	}{
+		{
+			Workflow:       "foo.yaml",
+			ExpectedReason: "the dispatched run is found, and a check run linking to it is created.",
+		},
+		{
+			Workflow:       "bar.yaml",
+			ExpectedError:  errRunNotFound,
+			ExpectedReason: "no run shows up before the timeout.",
+		},
+	}
+
+	for idx, testCase := range testCases {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		dispatch := dispatchedRun{workflow: testCase.Workflow, ref: "pr/owner/mybugfix", SHA: "mock-sha", dispatchedAt: time.Now()}
+		err := handler.linkDispatchedRun(ctx, client, "owner", "repo", dispatch, logger)
+		cancel()
+		assert.Equal(t, testCase.ExpectedError, err, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
+
+func Test_failDeniedDispatch(t *testing.T) {
+	var created []github.CreateCheckRunOptions
+	var updated []github.UpdateCheckRunOptions
 	mux := http.NewServeMux()
-	mux.HandleFunc("/repos/owner/repo/pulls", func(w http.ResponseWriter, r *http.Request) {
-		number := 0
-		prs := []*github.PullRequest{
-			{
-				Number: &number,
-				Head: &github.PullRequestBranch{
-					Ref: github.String("pr/owner/mybugfix"),
-					SHA: github.String("mock-sha"),
-					Repo: &github.Repository{
-						Owner: &github.User{Login: github.String("owner")},
-						Name:  github.String("repo"),
-					},
-				},
-				Base: &github.PullRequestBranch{
-					Ref: github.String("main"),
-				},
-			},
-		}
-		if err := json.NewEncoder(w).Encode(prs); err != nil {
-			http.Error(w, "setMockServer: could not encode the PRs payload in JSON for the HTTP response.", http.StatusInternalServerError)
-		}
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/foo.yaml", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(github.Workflow{Name: github.String("Foo CI"), Path: github.String(".github/workflows/foo.yaml")})
 	})
-	mux.HandleFunc("/repos/owner/repo/pulls/0", func(w http.ResponseWriter, r *http.Request) {
-		pr := &github.PullRequest{
-			Head: &github.PullRequestBranch{
-				Ref: github.String("pr/owner/mybugfix"),
-				SHA: github.String("mock-sha"),
-				Repo: &github.Repository{
-					Owner: &github.User{Login: github.String("owner")},
-					Name:  github.String("repo"),
-				},
-			},
-			Base: &github.PullRequestBranch{
-				Ref: github.String("main"),
-			},
-		}
-		if err := json.NewEncoder(w).Encode(pr); err != nil {
-			http.Error(w, "setMockServer: could not encode the PR payload in JSON for the HTTP response.", http.StatusInternalServerError)
-		}
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.CreateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		created = append(created, opts)
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Int64(1)})
 	})
-	mux.HandleFunc("/repos/owner/repo/pulls/0/files", func(w http.ResponseWriter, r *http.Request) {
-		// https://docs.github.com/en/rest/pulls/pulls?apiVersion=2022-11-28#list-pull-requests-files
-		files := []*github.CommitFile{
-			{
-				Filename: github.String(".github/workflows/foo.yaml"),
-			},
-		}
-		if err := json.NewEncoder(w).Encode(files); err != nil {
-			http.Error(w, "setMockServer: could not encode the files payload in JSON for the HTTP response.", http.StatusInternalServerError)
-		}
+	mux.HandleFunc("PATCH /repos/owner/repo/check-runs/42", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.UpdateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		updated = append(updated, opts)
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Int64(42)})
 	})
-	mux.HandleFunc("/orgs/owner/teams/organization-members/memberships/{author}", func(w http.ResponseWriter, r *http.Request) {
-		author := r.PathValue("author")
-		var membership *github.Membership
-
-		switch author {
-		case "trustedauthor":
-			membership = &github.Membership{
-				State: github.String("active"),
-			}
-		case "unknownauthor":
-			membership = &github.Membership{
-				State: github.String("pending"),
-			}
-		}
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
 
-		if err := json.NewEncoder(w).Encode(membership); err != nil {
-			http.Error(w, "setMockServer: could not encode the membership payload in JSON for the HTTP response.", http.StatusInternalServerError)
-		}
+	var logger zerolog.Logger
+	arianeConfig := &config.ArianeConfig{}
+	denied := &github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusForbidden},
+		Message:  "Actions has been disabled for this repository.",
+	}
+
+	failDeniedDispatch(context.Background(), nil, client, arianeConfig, "owner", "repo", "foo.yaml", "mock-sha", "", nil, denied, logger)
+	assert.Len(t, created, 1, "a failing check run is created without a queued one")
+	assert.Equal(t, "Foo CI", created[0].Name)
+	assert.Equal(t, "mock-sha", created[0].HeadSHA)
+	assert.Equal(t, "failure", created[0].GetConclusion())
+	assert.Contains(t, created[0].Output.GetSummary(), "GitHub denied dispatching `foo.yaml`: Actions has been disabled for this repository.")
+
+	queued := &trackedCheck{owner: "owner", repo: "repo", name: "Foo CI", checkRunID: 42}
+	failDeniedDispatch(context.Background(), nil, client, arianeConfig, "owner", "repo", "foo.yaml", "mock-sha", "", queued, denied, logger)
+	assert.Len(t, created, 1)
+	assert.Len(t, updated, 1, "the queued check run is completed instead")
+	assert.Equal(t, "failure", updated[0].GetConclusion())
+
+	assert.Equal(t, "Dispatching `foo.yaml` failed.", dispatchFailure("foo.yaml", &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusUnprocessableEntity}}))
+}
+
+func Test_skipMissingWorkflow(t *testing.T) {
+	var created []github.CreateCheckRunOptions
+	var updated []github.UpdateCheckRunOptions
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.CreateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		created = append(created, opts)
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Int64(1)})
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/check-runs/42", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.UpdateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		updated = append(updated, opts)
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Int64(42)})
 	})
-	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/foo.yaml/dispatches", func(w http.ResponseWriter, r *http.Request) {
-		// https://docs.github.com/en/rest/actions/workflows?apiVersion=2022-11-28#create-a-workflow-dispatch-event
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	var logger zerolog.Logger
+	arianeConfig := &config.ArianeConfig{}
+	notFound := &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusNotFound}, Message: "Not Found"}
+	assert.True(t, isWorkflowNotFound(fmt.Errorf("failed dispatching: %w", notFound)))
+	assert.False(t, isWorkflowNotFound(&github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden}}))
+	assert.False(t, isWorkflowNotFound(nil))
+
+	reason := workflowNotFound("foo.yaml")
+	assert.False(t, reason.Result)
+	assert.Equal(t, decision.ReasonWorkflowNotFound, reason.Reason)
+
+	skipMissingWorkflow(context.Background(), client, arianeConfig, "owner", "repo", "foo.yaml", "mock-sha", "", nil, reason, logger)
+	if assert.Len(t, created, 1, "a skipped check run is created without a queued one") {
+		assert.Equal(t, "foo.yaml", created[0].Name, "the missing workflow is named by its file")
+		assert.Equal(t, "mock-sha", created[0].HeadSHA)
+		assert.Equal(t, "skipped", created[0].GetConclusion())
+		assert.Contains(t, created[0].Output.GetSummary(), "workflow foo.yaml was not found in the repository")
+		assert.Contains(t, created[0].Output.GetSummary(), "Update or remove `foo.yaml` in the triggers")
+	}
+
+	queued := &trackedCheck{owner: "owner", repo: "repo", name: "Foo CI", checkRunID: 42}
+	skipMissingWorkflow(context.Background(), client, arianeConfig, "owner", "repo", "foo.yaml", "mock-sha", "", queued, reason, logger)
+	assert.Len(t, created, 1)
+	if assert.Len(t, updated, 1, "the queued check run is completed instead") {
+		assert.Equal(t, "skipped", updated[0].GetConclusion())
+	}
+
+	cache := NewWorkflowCache(time.Hour)
+	cache.set("owner/repo/foo.yaml", &github.Workflow{})
+	cache.set("owner/repo/foo.yaml@main", workflowFile{})
+	cache.set("owner/repo/foo.yaml.bak", &github.Workflow{})
+	cache.forget("owner", "repo", "foo.yaml")
+	_, ok := cache.get("owner/repo/foo.yaml@main")
+	assert.False(t, ok, "the cached lookups of the missing workflow are dropped")
+	_, ok = cache.get("owner/repo/foo.yaml.bak")
+	assert.True(t, ok)
+}
+
+func Test_dispatchWorkflowsMissingWorkflow(t *testing.T) {
+	var dispatched, checks []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/{workflow}/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("workflow") == "renamed.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "Not Found"}`))
+			return
+		}
+		dispatched = append(dispatched, r.PathValue("workflow"))
 		w.WriteHeader(http.StatusNoContent)
-		if _, err := w.Write([]byte(fmt.Sprintf("Status: %v\n", http.StatusNoContent))); err != nil {
-			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.CreateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		checks = append(checks, opts.Name+" "+opts.GetConclusion())
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Int64(1)})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/comments/{id}/reactions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(github.Reaction{})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	err := handler.dispatchWorkflows(context.Background(), triggerDispatch{
+		client:        client,
+		arianeConfig:  &config.ArianeConfig{},
+		owner:         "owner",
+		repo:          "repo",
+		prNumber:      1,
+		isIssue:       true,
+		commentID:     1,
+		commentAuthor: "contributor",
+		contextRef:    "main",
+		SHA:           "mock-sha",
+		workflows:     []string{"renamed.yaml", "foo.yaml"},
+		event:         handler.createWorkflowDispatchEvent(1, "main", "mock-sha", []string{"/test"}, nil),
+		logger:        zerolog.Nop(),
+	}, nil)
+	assert.NoError(t, err, "a workflow missing from the repository does not fail the trigger")
+	assert.Equal(t, []string{"foo.yaml"}, dispatched, "the other workflows are still dispatched")
+	assert.Equal(t, []string{"renamed.yaml skipped"}, checks)
+	assert.Equal(t, float64(1), configDriftTotal.Value("owner/repo", "renamed.yaml"))
+}
+
+func Test_getChangedFiles(t *testing.T) {
+	compared := 2
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls/1/files", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.CommitFile{{Filename: github.Ptr("base.go")}, {Filename: github.Ptr("head.go")}})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/compare/{basehead}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "base-sha...head-sha", r.PathValue("basehead"))
+		files := []*github.CommitFile{{Filename: github.Ptr("test/data-v2.json"), PreviousFilename: github.Ptr("test/data.json"), Status: github.Ptr("renamed")}}
+		for len(files) < compared {
+			files = append(files, &github.CommitFile{Filename: github.Ptr(fmt.Sprintf("file%d.go", len(files)))})
 		}
+		_ = json.NewEncoder(w).Encode(github.CommitsComparison{Files: files})
 	})
-	mux.HandleFunc("/repos/owner/repo/actions/workflows/{workflow}/runs", func(w http.ResponseWriter, r *http.Request) {
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	files, err := getChangedFiles(ctx, client, &config.ArianeConfig{}, "owner", "repo", 1, "base-sha", "head-sha", config.DefaultPagination.Files, logger)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2, "the files of the PR are listed by default")
+
+	arianeConfig := &config.ArianeConfig{ChangedFiles: config.ChangedFilesMergeBase}
+	files, err = getChangedFiles(ctx, client, arianeConfig, "owner", "repo", 1, "base-sha", "head-sha", config.DefaultPagination.Files, logger)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, "test/data.json", files[0].GetPreviousFilename())
+
+	compared = compareFilesLimit
+	files, err = getChangedFiles(ctx, client, arianeConfig, "owner", "repo", 1, "base-sha", "head-sha", config.DefaultPagination.Files, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "base.go", files[0].GetFilename(), "comparisons reaching the limit fall back to the files of the PR")
+}
+
+// Helper functions
+
+func setMockServer() *httptest.Server {
+	fake := fakegithub.New()
+	repo := fake.AddRepo("owner", "repo")
+	repo.SetBranch("main", "main-sha")
+	repo.SetTag("v1.0.0", "tag-sha")
+	// the comments are on PR 0
+	repo.AddPullRequest(fakegithub.PullRequest{
+		Number:  0,
+		HeadRef: "pr/owner/mybugfix",
+		HeadSHA: "mock-sha",
+		Files:   []string{".github/workflows/foo.yaml"},
+	})
+	// only foo.yaml can be dispatched
+	repo.AddWorkflow("foo.yaml", "Foo")
+	fake.AddTeamMember("owner", "organization-members", "trustedauthor", "active")
+	fake.AddTeamMember("owner", "organization-members", "secondauthor", "active")
+	fake.AddTeamMember("owner", "organization-members", "unknownauthor", "pending")
+	// comment 2 has been approved by a trusted author, comment 3 only by its own author,
+	// and comment 4 has been cancelled by its own author
+	repo.AddReaction(2, "trustedauthor", "rocket")
+	repo.AddReaction(3, "unknownauthor", "rocket")
+	repo.AddReaction(4, "unknownauthor", "-1")
+
+	// the runs of the workflows are fixtures, rather than created by dispatches
+	fake.Handle("/repos/owner/repo/actions/workflows/{workflow}/runs", func(w http.ResponseWriter, r *http.Request) {
 		// https://docs.github.com/en/rest/actions/workflow-runs?apiVersion=2022-11-28#list-workflow-runs-for-a-workflow
 		workflow := r.PathValue("workflow")
 		SHA := r.FormValue("head_sha")
 		var workflowRuns *github.WorkflowRuns
 
-		// search specific workflows, filtering by HeadSHA of the PR
-		if SHA != "mock-sha" {
+		// search runs created by workflow_dispatch events
+		if r.FormValue("event") == "workflow_dispatch" {
+			workflowRuns = &github.WorkflowRuns{
+				TotalCount:   github.Int(0),
+				WorkflowRuns: []*github.WorkflowRun{},
+			}
+			if workflow == "foo.yaml" {
+				workflowRuns.TotalCount = github.Int(1)
+				workflowRuns.WorkflowRuns = append(workflowRuns.WorkflowRuns, &github.WorkflowRun{
+					ID:        github.Int64(3),
+					Name:      github.String("Foo"),
+					HeadSHA:   github.String("mock-sha"),
+					RunNumber: github.Int(42),
+					HTMLURL:   github.String("https://github.com/owner/repo/actions/runs/3"),
+				})
+			}
+		} else if SHA != "mock-sha" {
 			workflowRuns = &github.WorkflowRuns{
 				TotalCount:   github.Int(0),
 				WorkflowRuns: []*github.WorkflowRun{},
@@ -468,7 +1277,7 @@ func setMockServer() *httptest.Server {
 				WorkflowRuns: []*github.WorkflowRun{
 					{
 						ID:      github.Int64(1),
-						Status:  github.String("cancelled"),
+						Status:  github.String("in_progress"),
 						HeadSHA: github.String(SHA),
 					},
 				},
@@ -485,6 +1294,29 @@ func setMockServer() *httptest.Server {
 					},
 				},
 			}
+		} else if workflow == "rerun.yaml" {
+			// the last created run failed, but an older run succeeded when re-run afterwards
+			workflowRuns = &github.WorkflowRuns{
+				TotalCount: github.Int(2),
+				WorkflowRuns: []*github.WorkflowRun{
+					{
+						ID:           github.Int64(5),
+						Status:       github.String("completed"),
+						Conclusion:   github.String("failure"),
+						HeadSHA:      github.String(SHA),
+						RunAttempt:   github.Int(1),
+						RunStartedAt: &github.Timestamp{Time: time.Now().Add(-time.Hour)},
+					},
+					{
+						ID:           github.Int64(4),
+						Status:       github.String("completed"),
+						Conclusion:   github.String("success"),
+						HeadSHA:      github.String(SHA),
+						RunAttempt:   github.Int(2),
+						RunStartedAt: &github.Timestamp{Time: time.Now().Add(-time.Minute)},
+					},
+				},
+			}
 		} else if workflow == "foobar.yaml" {
 			workflowRuns = &github.WorkflowRuns{
 				TotalCount: github.Int(1),
@@ -494,6 +1326,7 @@ func setMockServer() *httptest.Server {
 						Status:     github.String("completed"),
 						Conclusion: github.String("failure"),
 						HeadSHA:    github.String(SHA),
+						CreatedAt:  &github.Timestamp{Time: foobarCreatedAt},
 					},
 				},
 			}
@@ -508,7 +1341,21 @@ func setMockServer() *httptest.Server {
 			http.Error(w, "setMockServer: could not encode the workflowRuns payload in JSON for the HTTP response.", http.StatusInternalServerError)
 		}
 	})
-	mux.HandleFunc("/repos/owner/repo/actions/runs/{runID}/jobs", func(w http.ResponseWriter, r *http.Request) {
+	fake.Handle("GET /search/issues", func(w http.ResponseWriter, r *http.Request) {
+		// https://docs.github.com/en/rest/search/search?apiVersion=2022-11-28#search-issues-and-pull-requests
+		result := &github.IssuesSearchResult{Total: github.Int(0)}
+		if strings.Contains(r.FormValue("q"), "author:trustedauthor") {
+			result.Total = github.Int(3)
+		}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			http.Error(w, "setMockServer: could not encode the search payload in JSON for the HTTP response.", http.StatusInternalServerError)
+		}
+	})
+	fake.Handle("GET /repos/owner/repo/commits/refs/tags/v0.0.0-error", func(w http.ResponseWriter, r *http.Request) {
+		// errors are simulated with tag v0.0.0-error
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	})
+	fake.Handle("/repos/owner/repo/actions/runs/{runID}/jobs", func(w http.ResponseWriter, r *http.Request) {
 		runID := r.PathValue("runID")
 		if runID != "99" {
 			http.Error(w, http.StatusText(http.StatusNotImplemented), http.StatusNotImplemented)
@@ -528,7 +1375,7 @@ func setMockServer() *httptest.Server {
 			http.Error(w, "setMockServer: could not encode the jobs payload in JSON for the HTTP response.", http.StatusInternalServerError)
 		}
 	})
-	mux.HandleFunc("POST /repos/owner/repo/actions/runs/{runID}/rerun-failed-jobs", func(w http.ResponseWriter, r *http.Request) {
+	fake.Handle("POST /repos/owner/repo/actions/runs/{runID}/rerun-failed-jobs", func(w http.ResponseWriter, r *http.Request) {
 		// https://docs.github.com/en/rest/actions/workflow-runs?apiVersion=2022-11-28#re-run-failed-jobs-from-a-workflow-run
 		runID := r.PathValue("runID")
 		if runID != "99" {
@@ -539,17 +1386,7 @@ func setMockServer() *httptest.Server {
 			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		}
 	})
-	mux.HandleFunc("POST /repos/owner/repo/issues/comments/1/reactions", func(w http.ResponseWriter, r *http.Request) {
-		// https://docs.github.com/en/rest/reactions/reactions?apiVersion=2022-11-28#create-reaction-for-an-issue-comment
-		reaction := &github.Reaction{
-			ID:      github.Int64(1),
-			Content: github.String(r.PostFormValue("content")),
-		}
-		if err := json.NewEncoder(w).Encode(reaction); err != nil {
-			http.Error(w, "setMockServer: could not encode the reaction payload in JSON for the HTTP response.", http.StatusInternalServerError)
-		}
-	})
-	return httptest.NewServer(mux)
+	return fake.Server
 }
 
 func readYAMLFile(filePath string) (*config.ArianeConfig, error) {
@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+const defaultLargePRMessage = "@{{ .Author }} the workflows were not run, as {{ .Reason.Message }}: paths filters are not " +
+	"evaluated on such pull requests. Comment `{{ .Command }} --force` to run all of them."
+
+// checkLargePR decides whether a PR is large, from the files it changes, see config.LargePRConfig. The files listed
+// may stop at the pagination limit, which is as large as PRs get for the paths filters anyway.
+func checkLargePR(largePR config.LargePRConfig, files []*github.CommitFile) decision.Decision {
+	var changes int
+	for _, file := range files {
+		changes += file.GetChanges()
+	}
+	if largePR.MaxFiles > 0 && len(files) > largePR.MaxFiles {
+		return decision.Yes(decision.ReasonLargePR, "the pull request changes %d files, more than the %d of large-pr.max-files", len(files), largePR.MaxFiles)
+	}
+	if largePR.MaxChanges > 0 && changes > largePR.MaxChanges {
+		return decision.Yes(decision.ReasonLargePR, "the pull request changes %d lines, more than the %d of large-pr.max-changes", changes, largePR.MaxChanges)
+	}
+	return decision.No(decision.ReasonNotLargePR, "the pull request changes %d files and %d lines", len(files), changes)
+}
+
+// largePRRun is the decision to run a workflow of a large PR, whatever its paths filters
+func largePRRun(workflow string, large decision.Decision) decision.Decision {
+	return decision.Yes(decision.ReasonLargePR, "workflow %s runs whatever its paths filters, as %s", workflow, large.Message)
+}
+
+// checkLargeDispatch decides whether the trigger comment of a dispatch is on a large PR, and whether its workflows can
+// run: the comment must end with --force if large-pr.policy is force. The decision is recorded if large-pr is set.
+func (h *PRCommentHandler) checkLargeDispatch(t triggerDispatch, files []*github.CommitFile) (large decision.Decision, allowed bool) {
+	if !t.arianeConfig.LargePR.Enabled() || t.isIssue || t.tag != "" {
+		return decision.Decision{}, true
+	}
+	large = recordDecision(t.logger, stepLargePR, checkLargePR(t.arianeConfig.LargePR, files))
+	return large, !large.Result || t.arianeConfig.LargePR.Policy != config.LargePRForce || t.force
+}
+
+// rejectLargePR tells the author of a trigger comment on a large PR to comment it again with --force
+func (h *PRCommentHandler) rejectLargePR(ctx context.Context, t triggerDispatch, large decision.Decision) error {
+	audit.Event(ctx, "trigger_rejected").Str("author", t.commentAuthor).Object("decision", large).Send()
+	var command string
+	if len(t.submatch) > 0 {
+		command = t.submatch[0]
+	}
+	data := MessageData{Author: t.commentAuthor, Command: command, Reason: large}
+	return h.postMessage(ctx, t.client, t.arianeConfig, t.owner, t.repo, t.prNumber, "large-pr", t.arianeConfig.Messages.LargePR, defaultLargePRMessage, data, t.logger)
+}
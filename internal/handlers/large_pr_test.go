@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_checkLargePR(t *testing.T) {
+	files := []*github.CommitFile{
+		{Filename: github.String("vendor/a.go"), Changes: github.Int(600)},
+		{Filename: github.String("vendor/b.go"), Changes: github.Int(500)},
+		{Filename: github.String("go.mod"), Changes: github.Int(2)},
+	}
+
+	large := checkLargePR(config.LargePRConfig{MaxFiles: 2}, files)
+	assert.True(t, large.Result)
+	assert.Equal(t, "the pull request changes 3 files, more than the 2 of large-pr.max-files", large.Message)
+
+	large = checkLargePR(config.LargePRConfig{MaxFiles: 10, MaxChanges: 1000}, files)
+	assert.True(t, large.Result)
+	assert.Equal(t, "the pull request changes 1102 lines, more than the 1000 of large-pr.max-changes", large.Message)
+
+	large = checkLargePR(config.LargePRConfig{MaxFiles: 3, MaxChanges: 2000}, files)
+	assert.False(t, large.Result)
+	assert.Equal(t, decision.ReasonNotLargePR, large.Reason)
+}
+
+func Test_checkLargeDispatch(t *testing.T) {
+	files := make([]*github.CommitFile, 5)
+	handler := &PRCommentHandler{}
+	dispatch := func(policy string, force bool) triggerDispatch {
+		return triggerDispatch{
+			arianeConfig: &config.ArianeConfig{LargePR: config.LargePRConfig{MaxFiles: 4, Policy: policy}},
+			force:        force,
+			logger:       zerolog.Nop(),
+		}
+	}
+
+	large, allowed := handler.checkLargeDispatch(dispatch(config.LargePRRunAll, false), files)
+	assert.True(t, large.Result)
+	assert.True(t, allowed)
+	assert.Equal(t, "workflow foo.yaml runs whatever its paths filters, as the pull request changes 5 files, more than the 4 of large-pr.max-files",
+		largePRRun("foo.yaml", large).Message)
+
+	_, allowed = handler.checkLargeDispatch(dispatch(config.LargePRForce, false), files)
+	assert.False(t, allowed)
+	_, allowed = handler.checkLargeDispatch(dispatch(config.LargePRForce, true), files)
+	assert.True(t, allowed)
+	_, allowed = handler.checkLargeDispatch(dispatch(config.LargePRForce, false), files[:4])
+	assert.True(t, allowed)
+
+	// tag triggers are not concerned
+	tag := dispatch(config.LargePRForce, false)
+	tag.tag = "v1.0.0"
+	large, allowed = handler.checkLargeDispatch(tag, files)
+	assert.False(t, large.Result)
+	assert.True(t, allowed)
+}
+
+func Test_rejectLargePR(t *testing.T) {
+	var comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	dispatch := triggerDispatch{
+		client:        client,
+		arianeConfig:  &config.ArianeConfig{LargePR: config.LargePRConfig{MaxFiles: 1, Policy: config.LargePRForce}},
+		owner:         "owner",
+		repo:          "repo",
+		prNumber:      1,
+		commentAuthor: "author",
+		submatch:      []string{"/test"},
+		logger:        zerolog.Nop(),
+	}
+	large, _ := handler.checkLargeDispatch(dispatch, make([]*github.CommitFile, 2))
+	assert.NoError(t, handler.rejectLargePR(context.Background(), dispatch, large))
+	assert.Equal(t, []string{"@author the workflows were not run, as the pull request changes 2 files, more than the 1 of large-pr.max-files: " +
+		"paths filters are not evaluated on such pull requests. Comment `/test --force` to run all of them."}, comments)
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+const (
+	// runMarkerInput is the workflow_dispatch input carrying the run marker, if enabled in the repository config
+	runMarkerInput = "ariane-delivery-id"
+	// runMarkerPrefix starts run markers, so runs showing one in their name are known to be dispatched by Ariane
+	runMarkerPrefix = "ariane/"
+)
+
+// origins of completed workflow_dispatch runs, used as metrics labels
+const (
+	originAriane = "ariane"
+	originOther  = "other"
+)
+
+var dispatchedRunsTotal = metrics.NewCounterVec("ariane_dispatched_runs_total",
+	"Completed workflow_dispatch runs, by repository and origin (ariane for the runs showing a run marker, other otherwise).",
+	"repository", "origin")
+
+type deliveryIDKey struct{}
+
+// withDeliveryID records the delivery ID of the event being handled, used as run marker
+func withDeliveryID(ctx context.Context, deliveryID string) context.Context {
+	return context.WithValue(ctx, deliveryIDKey{}, deliveryID)
+}
+
+// deliveryIDFromContext returns the delivery ID of the event being handled, if any
+func deliveryIDFromContext(ctx context.Context) string {
+	deliveryID, _ := ctx.Value(deliveryIDKey{}).(string)
+	return deliveryID
+}
+
+// runMarker returns the marker passed to the workflows dispatched for an event
+func runMarker(deliveryID string) string {
+	return runMarkerPrefix + deliveryID
+}
+
+// isMarkedRun reports whether a run shows a run marker in its name, i.e. was dispatched by Ariane
+func isMarkedRun(run *github.WorkflowRun) bool {
+	return strings.Contains(run.GetDisplayTitle(), runMarkerPrefix)
+}
+
+// recordDispatchedRun counts a completed workflow_dispatch run by origin
+func recordDispatchedRun(repository string, run *github.WorkflowRun) {
+	origin := originOther
+	if isMarkedRun(run) {
+		origin = originAriane
+	}
+	dispatchedRunsTotal.Inc(repository, origin)
+}
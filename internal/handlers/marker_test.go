@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/poll"
+)
+
+func Test_verifyDispatchMarker(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/foo.yaml/runs", func(w http.ResponseWriter, r *http.Request) {
+		// a manual dispatch followed the one of Ariane
+		_ = json.NewEncoder(w).Encode(github.WorkflowRuns{
+			TotalCount: github.Int(2),
+			WorkflowRuns: []*github.WorkflowRun{
+				{ID: github.Int64(2), DisplayTitle: github.String("Foo"), HeadSHA: github.String("other-sha")},
+				{ID: github.Int64(1), DisplayTitle: github.String("Foo [ariane/delivery-1]"), HeadSHA: github.String("mock-sha")},
+			},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{Poll: poll.Poller{Interval: time.Millisecond}, DispatchVerifyTimeout: time.Second}
+	dispatch := dispatchedRun{workflow: "foo.yaml", ref: "main", SHA: "mock-sha", dispatchedAt: time.Now()}
+
+	run, err := handler.verifyDispatch(context.Background(), client, "owner", "repo", dispatch)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), run.GetID(), "without marker, the run of the dispatched SHA is the dispatched one")
+
+	_, err = handler.verifyDispatch(context.Background(), client, "owner", "repo", dispatchedRun{workflow: "foo.yaml", ref: "main", dispatchedAt: time.Now()})
+	assert.ErrorIs(t, err, errRunNotFound, "the newest run is not assumed to be the dispatched one")
+
+	run, err = handler.verifyDispatch(context.Background(), client, "owner", "repo", dispatchedRun{workflow: "foo.yaml", ref: "main", SHA: "other-sha", dispatchedAt: time.Now()})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), run.GetID())
+
+	dispatch.marker = runMarker("delivery-1")
+	run, err = handler.verifyDispatch(context.Background(), client, "owner", "repo", dispatch)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), run.GetID(), "the run showing the marker is the dispatched one")
+
+	dispatch.marker = runMarker("delivery-2")
+	handler.DispatchVerifyTimeout = 10 * time.Millisecond
+	_, err = handler.verifyDispatch(context.Background(), client, "owner", "repo", dispatch)
+	assert.ErrorIs(t, err, errRunNotFound)
+}
+
+func Test_recordDispatchedRun(t *testing.T) {
+	ariane := dispatchedRunsTotal.Value("owner/repo", originAriane)
+	other := dispatchedRunsTotal.Value("owner/repo", originOther)
+
+	recordDispatchedRun("owner/repo", &github.WorkflowRun{DisplayTitle: github.String("Foo [ariane/delivery-1]")})
+	recordDispatchedRun("owner/repo", &github.WorkflowRun{DisplayTitle: github.String("Foo")})
+	assert.Equal(t, ariane+1, dispatchedRunsTotal.Value("owner/repo", originAriane))
+	assert.Equal(t, other+1, dispatchedRunsTotal.Value("owner/repo", originOther))
+}
@@ -7,14 +7,37 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/google/go-github/v75/github"
-	"github.com/cilium/ariane/internal/log"
 	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/log"
+	"github.com/cilium/ariane/internal/poll"
+	"github.com/cilium/ariane/internal/scheduler"
 )
 
+// MergeGroupHandler reports the required checks of merge groups: the ones mapped to workflows in the config of the
+// base branch follow the runs of these workflows dispatched on the merge group branch, the others are marked successful.
 type MergeGroupHandler struct {
 	githubapp.ClientCreator
+	// ConfigCache caches the Ariane configs fetched from repositories, if enabled
+	ConfigCache *config.Cache
+	// RunChecks tracks the check runs following the dispatched runs, shared with the WorkflowRunHandler updating them
+	RunChecks *RunChecks
+	// Poll controls how often dispatched runs are looked up
+	Poll poll.Poller
+	// DispatchVerifyTimeout controls how long dispatched runs are looked up, DefaultDispatchVerifyTimeout if zero.
+	// Check runs whose run is not found by then fail, so the merge group does not wait for them forever.
+	DispatchVerifyTimeout time.Duration
+	// Scheduler runs the lookups of dispatched runs in the background
+	Scheduler scheduler.Scheduler
+	// Version is the version of the server, recorded in the provenance of dispatches
+	Version string
 }
 
 func (*MergeGroupHandler) Handles() []string {
@@ -52,18 +75,49 @@ func (m *MergeGroupHandler) Handle(ctx context.Context, eventType, deliveryID st
 		return err
 	}
 
+	// repositories without a valid config keep all their required checks marked successful
+	var requiredWorkflows map[string]string
+	arianeConfig, err := getArianeConfig(ctx, m.ConfigCache, client, repositoryOwner, repositoryName, strings.TrimPrefix(branchRef, "refs/heads/"))
+	switch {
+	case err == nil:
+		requiredWorkflows = arianeConfig.MergeGroup.RequiredWorkflows
+	case failure.CategoryOf(err) == failure.ConfigError:
+		logger.Debug().Err(err).Msg("No config for the merge group base branch")
+	default:
+		logger.Error().Err(err).Msg("Failed to retrieve config file")
+		return err
+	}
+
 	headSHA := event.GetMergeGroup().GetHeadSHA()
+	var summary []mergeGroupCheck
 	for _, ch := range branchPro.GetRequiredStatusChecks().GetChecks() {
 		// required checks' appID is 0 for any source configuration
 		// if appID is not equal to 0 this means check is handled by some other app or by GitHub
 		// we skipp these checks
 		if ch.GetAppID() != 0 {
 			logger.Debug().Str("Status Check", ch.Context).Msg("Not managed by Ariane")
+			summary = append(summary, mergeGroupCheck{Name: ch.Context, Outcome: mergeGroupLeftAlone, Reason: fmt.Sprintf("reported by app %d", ch.GetAppID())})
+			continue
+		}
+
+		if workflow, ok := requiredWorkflows[ch.Context]; ok {
+			if err := m.dispatchRequiredWorkflow(ctx, client, arianeConfig, repositoryOwner, repositoryName, event.GetMergeGroup(), ch.Context, workflow, logger); err != nil {
+				return err
+			}
+			summary = append(summary, mergeGroupCheck{Name: ch.Context, Outcome: mergeGroupDispatched, Reason: fmt.Sprintf("mapped to `%s`", workflow)})
 			continue
 		}
 
 		// setting the check status as completed and conclusion as success, without actually running it
 		logger.Debug().Str("Status Check", ch.Context).Msg("Setting status to completed, conclusion to success")
+		if arianeConfig != nil && arianeConfig.ReportsStatuses() {
+			if err := createStatus(ctx, client, repositoryOwner, repositoryName, headSHA, ch.Context, "success", "Marked successful by Ariane", ""); err != nil {
+				logger.Error().Err(err).Msgf("Failed to set commit status, %s", ch.Context)
+				continue
+			}
+			summary = append(summary, mergeGroupCheck{Name: ch.Context, Outcome: mergeGroupMarkedSuccessful, Reason: "not mapped in `merge-group.required-workflows`"})
+			continue
+		}
 		checkRunOptions := github.CreateCheckRunOptions{
 			Name:       ch.Context,
 			HeadSHA:    headSHA,
@@ -72,8 +126,154 @@ func (m *MergeGroupHandler) Handle(ctx context.Context, eventType, deliveryID st
 		}
 		if _, _, err := client.Checks.CreateCheckRun(ctx, repositoryOwner, repositoryName, checkRunOptions); err != nil {
 			logger.Error().Err(err).Msgf("Failed to set check run, %s", ch.Context)
+			continue
+		}
+		summary = append(summary, mergeGroupCheck{Name: ch.Context, Outcome: mergeGroupMarkedSuccessful, Reason: "not mapped in `merge-group.required-workflows`"})
+	}
+
+	if arianeConfig != nil && arianeConfig.MergeGroup.Summary {
+		postMergeGroupSummary(ctx, client, repositoryOwner, repositoryName, headSHA, summary, arianeConfig.ReportsStatuses(), logger)
+	}
+	return nil
+}
+
+// mergeGroupSummaryCheck names the informational check run summarizing the required checks of a merge group
+const mergeGroupSummaryCheck = "Ariane merge group"
+
+// Outcomes of the required checks of merge groups, see mergeGroupCheck
+const (
+	mergeGroupMarkedSuccessful = "marked successful"
+	mergeGroupDispatched       = "dispatched"
+	mergeGroupLeftAlone        = "left alone"
+)
+
+// mergeGroupCheck is a required check of a merge group, as listed in the summary check run
+type mergeGroupCheck struct {
+	Name    string
+	Outcome string
+	Reason  string
+}
+
+// mergeGroupSummary renders the summary check run output listing what Ariane did with each required check
+func mergeGroupSummary(checks []mergeGroupCheck) string {
+	if len(checks) == 0 {
+		return "The base branch has no required checks."
+	}
+	var b strings.Builder
+	b.WriteString("| Required check | Outcome | Reason |\n| --- | --- | --- |\n")
+	for _, check := range checks {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", check.Name, check.Outcome, check.Reason)
+	}
+	return b.String()
+}
+
+// postMergeGroupSummary creates a neutral check run on the merge group summarizing its required checks, so admins
+// reviewing a merged PR can see what Ariane reported. Failures are only logged, as the check run is informational.
+// With statuses set, a successful commit status only tells how many required checks were marked successful.
+func postMergeGroupSummary(ctx context.Context, client *github.Client, owner, repo, headSHA string, checks []mergeGroupCheck, statuses bool, logger zerolog.Logger) {
+	completed := 0
+	for _, check := range checks {
+		if check.Outcome == mergeGroupMarkedSuccessful {
+			completed++
+		}
+	}
+	title := fmt.Sprintf("%d required checks marked successful by Ariane", completed)
+	if statuses {
+		if err := createStatus(ctx, client, owner, repo, headSHA, mergeGroupSummaryCheck, "success", title, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to create merge group summary commit status")
+		}
+		return
+	}
+	summary := mergeGroupSummary(checks)
+	_, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       mergeGroupSummaryCheck,
+		HeadSHA:    headSHA,
+		Status:     github.String("completed"),
+		Conclusion: github.String("neutral"),
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create merge group summary check run")
+	}
+}
+
+// dispatchRequiredWorkflow creates a queued check run for a required check, dispatches its workflow on the merge group
+// branch, and has the check run follow the dispatched run once it shows up
+func (m *MergeGroupHandler) dispatchRequiredWorkflow(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, mergeGroup *github.MergeGroup, checkName, workflow string, logger zerolog.Logger) error {
+	logger = logger.With().Str("Status Check", checkName).Str("workflow", workflow).Logger()
+	branch := strings.TrimPrefix(mergeGroup.GetHeadRef(), "refs/heads/")
+
+	title := "Workflow dispatched"
+	summary := fmt.Sprintf("Ariane dispatched %s on the merge group, waiting for the run to start.", arianeConfig.DisplayName(workflow))
+	var check trackedCheck
+	if arianeConfig.ReportsStatuses() {
+		if err := createStatus(ctx, client, owner, repo, mergeGroup.GetHeadSHA(), checkName, "pending", summary, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to create pending commit status")
+			return err
+		}
+		check = trackedCheck{owner: owner, repo: repo, name: checkName, commitStatus: true, SHA: mergeGroup.GetHeadSHA()}
+	} else {
+		checkRun, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+			Name:       checkName,
+			HeadSHA:    mergeGroup.GetHeadSHA(),
+			ExternalID: github.String("dispatch/" + workflow),
+			Status:     github.String("queued"),
+			Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to create queued check run")
+			return err
 		}
+		check = trackedCheck{owner: owner, repo: repo, name: checkName, checkRunID: checkRun.GetID()}
 	}
 
+	// merge groups have no PR to pass the number of, only the run marker and provenance are passed if enabled
+	dispatch := dispatchedRun{workflow: workflow, ref: branch, SHA: mergeGroup.GetHeadSHA(), dispatchedAt: m.Scheduler.Now(), queuedCheck: &check}
+	inputs := map[string]interface{}{}
+	if deliveryID := deliveryIDFromContext(ctx); arianeConfig.RunMarker && deliveryID != "" {
+		dispatch.marker = runMarker(deliveryID)
+		inputs[runMarkerInput] = dispatch.marker
+	}
+	if arianeConfig.Provenance {
+		inputs[provenanceInput] = newProvenance(arianeConfig, m.Version).input()
+	}
+	if _, err := client.Actions.CreateWorkflowDispatchEventByFileName(ctx, owner, repo, workflow, github.CreateWorkflowDispatchEventRequest{Ref: branch, Inputs: inputs}); err != nil {
+		logger.Error().Err(err).Msg("Failed to create workflow dispatch event")
+		abandonQueuedCheck(ctx, client, check, "failure", dispatchFailure(workflow, err), logger)
+		return err
+	}
+	failure.Dispatched(ctx)
+	logger.Info().Msgf("Dispatched workflow %s on merge group branch %s", workflow, branch)
+
+	timeout := m.DispatchVerifyTimeout
+	if timeout <= 0 {
+		timeout = DefaultDispatchVerifyTimeout
+	}
+	ctx, cancel := detach(ctx, timeout)
+	m.Scheduler.Go(ctx, func(ctx context.Context) {
+		defer cancel()
+		m.followDispatchedRun(ctx, client, dispatch, timeout, logger)
+	})
 	return nil
 }
+
+// followDispatchedRun waits for the run created by a dispatch, and has the queued check run follow it
+func (m *MergeGroupHandler) followDispatchedRun(ctx context.Context, client *github.Client, dispatch dispatchedRun, timeout time.Duration, logger zerolog.Logger) {
+	check := *dispatch.queuedCheck
+	poller := poll.Poller{Interval: m.Poll.Interval, Timeout: timeout, Clock: m.Scheduler.Clock}
+	run, err := findDispatchedRun(ctx, poller, client, check.owner, check.repo, dispatch)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to find the run dispatched for workflow %s", dispatch.workflow)
+		// the context is likely expired by now
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		abandonQueuedCheck(ctx, client, check, "failure", fmt.Sprintf("The run dispatched for `%s` could not be found.", dispatch.workflow), logger)
+		return
+	}
+	logger.Debug().Msgf("Workflow %s dispatched as run %d", dispatch.workflow, run.GetID())
+
+	m.RunChecks.add(run.GetID(), check)
+	if err := updateCheckFromRun(ctx, client, check, run); err != nil {
+		logger.Error().Err(err).Msg("Failed to update queued check run")
+	}
+}
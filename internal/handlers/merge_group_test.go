@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/poll"
+)
+
+func TestMergeGroupHandle(t *testing.T) {
+	var mu sync.Mutex
+	var created []github.CreateCheckRunOptions
+	var updated []github.UpdateCheckRunOptions
+	var dispatched []github.CreateWorkflowDispatchEventRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/owner/repo/branches/", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(github.Protection{RequiredStatusChecks: &github.RequiredStatusChecks{Checks: &[]*github.RequiredStatusCheck{
+			{Context: "ci-e2e", AppID: github.Ptr(int64(0))},
+			{Context: "ci-unit", AppID: github.Ptr(int64(0))},
+			{Context: "other-app", AppID: github.Ptr(int64(15))},
+		}}})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.CreateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		mu.Lock()
+		created = append(created, opts)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Ptr(int64(7))})
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/check-runs/7", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.UpdateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		mu.Lock()
+		updated = append(updated, opts)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Ptr(int64(7))})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/e2e.yaml/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		var request github.CreateWorkflowDispatchEventRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		mu.Lock()
+		dispatched = append(dispatched, request)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/e2e.yaml/runs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gh-readonly-queue/main/pr-1-abc", r.URL.Query().Get("branch"))
+		_ = json.NewEncoder(w).Encode(github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{
+			{ID: github.Ptr(int64(42)), Name: github.Ptr("E2E"), Status: github.Ptr("in_progress"), HeadSHA: github.Ptr("mg-sha")},
+		}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+	configGetArianeConfigFromRepository = func(client *github.Client, ctx context.Context, owner, repoName, ref string) (*config.ArianeConfig, error) {
+		assert.Equal(t, "main", ref, "the config of the base branch is used")
+		return &config.ArianeConfig{MergeGroup: config.MergeGroupConfig{RequiredWorkflows: map[string]string{"ci-e2e": "e2e.yaml"}, Summary: true}}, nil
+	}
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(client, nil).AnyTimes()
+	handler := &MergeGroupHandler{
+		ClientCreator: mockClientCreator,
+		RunChecks:     NewRunChecks(),
+		Poll:          poll.Poller{Interval: time.Millisecond},
+	}
+
+	payload := []byte(`{"action": "checks_requested", "repository": {"owner": {"login": "owner"}, "name": "repo"},
+		"merge_group": {"head_sha": "mg-sha", "head_ref": "refs/heads/gh-readonly-queue/main/pr-1-abc", "base_ref": "refs/heads/main"}}`)
+	assert.NoError(t, handler.Handle(context.Background(), "merge_group", "deliveryID", payload))
+	handler.Scheduler.Wait()
+
+	assert.Len(t, created, 3, "checks of other apps are left alone")
+	assert.Equal(t, "ci-e2e", created[0].Name)
+	assert.Equal(t, "queued", created[0].GetStatus(), "mapped checks wait for their workflow run")
+	assert.Equal(t, "ci-unit", created[1].Name)
+	assert.Equal(t, "success", created[1].GetConclusion(), "checks which are not mapped are marked successful")
+	assert.Equal(t, mergeGroupSummaryCheck, created[2].Name)
+	assert.Equal(t, "neutral", created[2].GetConclusion(), "the summary does not gate the merge group")
+	assert.Equal(t, "mg-sha", created[2].HeadSHA)
+	assert.Equal(t, "1 required checks marked successful by Ariane", created[2].GetOutput().GetTitle())
+	assert.Equal(t, `| Required check | Outcome | Reason |
+| --- | --- | --- |
+| ci-e2e | dispatched | mapped to `+"`e2e.yaml`"+` |
+| ci-unit | marked successful | not mapped in `+"`merge-group.required-workflows`"+` |
+| other-app | left alone | reported by app 15 |
+`, created[2].GetOutput().GetSummary())
+
+	assert.Len(t, dispatched, 1)
+	assert.Equal(t, "gh-readonly-queue/main/pr-1-abc", dispatched[0].Ref)
+	assert.Len(t, updated, 1)
+	assert.Equal(t, "ci-e2e", updated[0].Name, "the check run keeps the name of the required check")
+	assert.Equal(t, "in_progress", updated[0].GetStatus())
+	check, ok := handler.RunChecks.get(42)
+	assert.True(t, ok, "the check run follows the dispatched run")
+	assert.Equal(t, int64(7), check.checkRunID)
+}
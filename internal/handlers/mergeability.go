@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// mergeableStateDirty is the mergeable_state of PRs conflicting with their base branch
+const mergeableStateDirty = "dirty"
+
+// checkMergeability decides whether the workflows of a PR can be dispatched, refusing PRs which conflict with their
+// base branch, or are more than mergeability.MaxBehind commits behind it. The workflows run when the mergeability is
+// not computed yet, as GitHub does it in the background, or could not be retrieved.
+func (h *PRCommentHandler) checkMergeability(ctx context.Context, client *github.Client, owner, repo string, prNumber int, mergeability config.MergeabilityConfig, logger zerolog.Logger) decision.Decision {
+	// only single pull requests come with their mergeability, not listed ones
+	pr, _, err := client.PullRequests.Get(ctx, owner, repo, prNumber)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to retrieve the mergeability of PR #%d", prNumber)
+		return decision.Yes(decision.ReasonMergeabilityLookupFailure, "failed to retrieve the mergeability of PR #%d", prNumber)
+	}
+	base := pr.GetBase().GetRef()
+	if pr.GetMergeableState() == mergeableStateDirty || (pr.Mergeable != nil && !pr.GetMergeable()) {
+		return decision.No(decision.ReasonMergeConflict, "PR #%d conflicts with its base branch %s", prNumber, base)
+	}
+
+	if mergeability.MaxBehind > 0 {
+		comparison, _, err := client.Repositories.CompareCommits(ctx, owner, repo, base, pr.GetHead().GetSHA(), &github.ListOptions{PerPage: 1})
+		if err != nil {
+			logger.Error().Err(err).Msgf("Failed to compare PR #%d with its base branch %s", prNumber, base)
+			return decision.Yes(decision.ReasonMergeabilityLookupFailure, "failed to compare PR #%d with its base branch %s", prNumber, base)
+		}
+		if behind := comparison.GetBehindBy(); behind > mergeability.MaxBehind {
+			return decision.No(decision.ReasonTooFarBehind, "PR #%d is %d commits behind its base branch %s, more than %d", prNumber, behind, base, mergeability.MaxBehind)
+		}
+	}
+
+	if pr.Mergeable == nil {
+		return decision.Yes(decision.ReasonMergeabilityUnknown, "the mergeability of PR #%d is not computed yet", prNumber)
+	}
+	return decision.Yes(decision.ReasonMergeable, "PR #%d can be merged into its base branch %s", prNumber, base)
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_checkMergeability(t *testing.T) {
+	mux := http.NewServeMux()
+	pullRequest := func(mergeable *bool, state string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(github.PullRequest{
+				Mergeable:      mergeable,
+				MergeableState: github.Ptr(state),
+				Base:           &github.PullRequestBranch{Ref: github.Ptr("main")},
+				Head:           &github.PullRequestBranch{SHA: github.Ptr("head-sha")},
+			})
+		}
+	}
+	mux.HandleFunc("GET /repos/owner/repo/pulls/1", pullRequest(github.Ptr(true), "clean"))
+	mux.HandleFunc("GET /repos/owner/repo/pulls/2", pullRequest(github.Ptr(false), "dirty"))
+	mux.HandleFunc("GET /repos/owner/repo/pulls/3", pullRequest(nil, "unknown"))
+	mux.HandleFunc("GET /repos/owner/repo/compare/main...head-sha", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(github.CommitsComparison{BehindBy: github.Ptr(12)})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	testCases := []struct {
+		PRNumber       int
+		MaxBehind      int
+		ExpectedResult bool
+		ExpectedCode   decision.Reason
+		ExpectedReason string
+	}{
+		{
+			PRNumber:       1,
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonMergeable,
+			ExpectedReason: "PRs without conflicts are mergeable.",
+		},
+		{
+			PRNumber:       2,
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonMergeConflict,
+			ExpectedReason: "PRs conflicting with their base branch are refused.",
+		},
+		{
+			PRNumber:       3,
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonMergeabilityUnknown,
+			ExpectedReason: "PRs whose mergeability is not computed yet run.",
+		},
+		{
+			PRNumber:       1,
+			MaxBehind:      10,
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonTooFarBehind,
+			ExpectedReason: "PRs more than max-behind commits behind their base branch are refused.",
+		},
+		{
+			PRNumber:       1,
+			MaxBehind:      20,
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonMergeable,
+			ExpectedReason: "PRs less than max-behind commits behind their base branch are mergeable.",
+		},
+		{
+			PRNumber:       4,
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonMergeabilityLookupFailure,
+			ExpectedReason: "lookup failures do not block the workflows.",
+		},
+	}
+
+	handler := &PRCommentHandler{}
+	for idx, testCase := range testCases {
+		mergeability := config.MergeabilityConfig{Enabled: true, MaxBehind: testCase.MaxBehind}
+		result := handler.checkMergeability(context.Background(), client, "owner", "repo", testCase.PRNumber, mergeability, zerolog.Nop())
+		assert.Equal(t, testCase.ExpectedResult, result.Result, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
@@ -0,0 +1,346 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// commandPrefix starts the comments addressed to Ariane itself rather than triggering workflows, e.g. "/ariane help"
+const commandPrefix = "/ariane"
+
+const defaultHelpMessage = `@{{ .Author }} the following commands can be commented to run CI workflows:
+{{ range .Triggers }}
+- ` + "`{{ .Command }}`" + `: {{ join (names .Workflows) ", " }}{{ range $workflow := .Workflows }}{{ with description $workflow }}
+  - {{ name $workflow }}: {{ . }}{{ end }}{{ end }}{{ end }}
+`
+
+const defaultUnknownCommandMessage = `@{{ .Author }} unknown command ` + "`{{ .Command }}`" + `, comment ` + "`" + commandPrefix + ` help` + "`" + ` to list the available commands.`
+
+const defaultInvalidInputsMessage = `@{{ .Author }} the workflows were not run, as the arguments of your command are invalid or cannot be passed to them: {{ .Reason.Message }}.`
+
+const defaultUnmergeableMessage = `@{{ .Author }} the workflows were not run, as {{ .Reason.Message }}. Please rebase it, then comment again.`
+
+const defaultDeprecatedMessage = "@{{ .Author }} `{{ .Command }}` is deprecated{{ if .Replacement }}, please use `{{ .Replacement }}` instead{{ end }}." +
+	"{{ if .Refused }} The workflows were not run.{{ end }}"
+
+const defaultReactionFallbackMessage = `@{{ .Author }} {{ .Reaction }}`
+
+// reactionEmojis are the emoji shortcodes of the reactions GitHub supports on comments, used in fallback comments
+var reactionEmojis = map[string]string{
+	"+1": ":+1:", "-1": ":-1:", "laugh": ":laughing:", "confused": ":confused:",
+	"heart": ":heart:", "hooray": ":tada:", "rocket": ":rocket:", "eyes": ":eyes:",
+}
+
+// summaryMarker is a hidden marker identifying the summary comment, edited with the summary of each trigger comment
+// rather than posting new comments. summaryEntryMarker starts each summary kept in it, the latest first.
+const (
+	summaryMarker      = "<!-- ariane-summary -->"
+	summaryEntryMarker = "<!-- ariane-summary-entry -->"
+	summaryHistoryOpen = "<details><summary>Previous summaries</summary>"
+	summaryHistoryEnd  = "</details>"
+)
+
+const defaultSummaryResetMessage = "The previous summaries were cleared, as the pull request was force-pushed to {{ .Head }}."
+
+const defaultNothingRunMessage = `@{{ .Author }} no workflow was run, as all of them were skipped:
+{{ range .Skipped }}
+- {{ name .Workflow }}: {{ .Reason.Message }}{{ end }}
+`
+
+// MessageData is passed to the message templates. Besides Author, fields are only set for some messages:
+// Triggers for help, Command for unknown-command, Reason for rejection, invalid-inputs and paused, Dispatched and
+// Skipped for summary and nothing-run, Skipped for retry-limited, Command and Plan for dry-run, Green for last-green, Paused for pause, CommentURL and Runs for run-links, Reaction (as an emoji shortcode, e.g. ":rocket:") for reaction-fallback, Head and Statuses for status.
+// Workflows are given as file names, see ArianeConfig.TemplateFuncs to show their friendly names.
+type MessageData struct {
+	Author     string
+	Command    string
+	Triggers   []WelcomeTrigger
+	Reason     decision.Decision
+	Dispatched []string
+	Skipped    []SkippedWorkflow
+	Reaction   string
+	// Queued are the dispatched workflows waiting for a slot of their resource pool
+	Queued []string
+	// Replacement is the command replacing a deprecated one, and Refused is set if its workflows were not run
+	Replacement string
+	Refused     bool
+	// Paused is set when Ariane was paused on the pull request, and unset when it was resumed
+	Paused bool
+	// Runs links to the dispatched runs found for the trigger comment at CommentURL
+	CommentURL string
+	Runs       []RunLink
+	// Plan is what dispatching the workflows of a trigger comment ending with --dry-run would do
+	Plan []decision.WorkflowPlan
+	// Green is the last SHA of the pull request for which all the workflows run by Ariane succeeded, if any
+	Green *GreenSHA
+	// FailedJobs are the failed jobs of the failed run Runs links to, appended to the summary if failed-jobs is set
+	FailedJobs []JobLink
+	// Head is the SHA the pull request was force-pushed to, for summary-reset, or its head SHA, for status
+	Head string
+	// Statuses are the latest runs of the workflows of the config triggers for Head, for status
+	Statuses []WorkflowStatus
+}
+
+type SkippedWorkflow struct {
+	Workflow string
+	Reason   decision.Decision
+}
+
+// renderTemplate executes a welcome or message template, using defaultText if text is empty
+func renderTemplate(arianeConfig *config.ArianeConfig, name, text, defaultText string, data any) (string, error) {
+	if text == "" {
+		text = defaultText
+	}
+	tmpl, err := template.New(name).Funcs(arianeConfig.TemplateFuncs()).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseCommand returns the command of a comment addressed to Ariane, e.g. "help" for "/ariane help"
+func parseCommand(comment string) (string, bool) {
+	fields := strings.Fields(comment)
+	if len(fields) == 0 || fields[0] != commandPrefix {
+		return "", false
+	}
+	if len(fields) == 1 {
+		return "", true
+	}
+	return fields[1], true
+}
+
+// allTriggers lists the triggers of the config which are not deprecated, sorted by command, with an example comment
+// running each, see config.ArianeConfig.TriggerExample
+func allTriggers(arianeConfig *config.ArianeConfig) []WelcomeTrigger {
+	triggers := make([]WelcomeTrigger, 0, len(arianeConfig.Triggers))
+	for command, trigger := range arianeConfig.Triggers {
+		if trigger.Deprecated != nil {
+			continue
+		}
+		triggers = append(triggers, WelcomeTrigger{Command: arianeConfig.TriggerExample(command), Workflows: trigger.Workflows})
+	}
+	sort.Slice(triggers, func(i, j int) bool { return triggers[i].Command < triggers[j].Command })
+	return triggers
+}
+
+// handleCommand replies to a comment addressed to Ariane
+func (h *PRCommentHandler) handleCommand(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, SHA, author, command string, logger zerolog.Logger) error {
+	switch command {
+	case "help":
+		data := MessageData{Author: author, Triggers: allTriggers(arianeConfig)}
+		return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "help", arianeConfig.Messages.Help, defaultHelpMessage, data, logger)
+	case "off", "on":
+		return h.togglePause(ctx, client, arianeConfig, owner, repo, prNumber, author, command == "off", logger)
+	case "last-green":
+		data := MessageData{Author: author}
+		if green, ok := h.Green.Last(owner, repo, prNumber); ok {
+			data.Green = &green
+		}
+		return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "last-green", arianeConfig.Messages.LastGreen, defaultLastGreenMessage, data, logger)
+	case "status":
+		return h.postStatus(ctx, client, arianeConfig, owner, repo, prNumber, SHA, author, logger)
+	default:
+		data := MessageData{Author: author, Command: strings.TrimSpace(commandPrefix + " " + command)}
+		return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "unknown-command", arianeConfig.Messages.UnknownCommand, defaultUnknownCommandMessage, data, logger)
+	}
+}
+
+// rejectInputs records a trigger comment rejected because of its args or inputs, and replies with the reason
+func (h *PRCommentHandler) rejectInputs(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, author string, reason decision.Decision, logger zerolog.Logger) error {
+	audit.Event(ctx, "trigger_rejected").Str("author", author).Object("decision", reason).Send()
+	data := MessageData{Author: author, Reason: reason}
+	return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "invalid-inputs", arianeConfig.Messages.InvalidInputs, defaultInvalidInputsMessage, data, logger)
+}
+
+// rejectUnmergeable tells the author of a trigger comment that its workflows were not run, as the PR conflicts with
+// its base branch or is too far behind it
+func (h *PRCommentHandler) rejectUnmergeable(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, author string, reason decision.Decision, logger zerolog.Logger) error {
+	audit.Event(ctx, "trigger_rejected").Str("author", author).Object("decision", reason).Send()
+	data := MessageData{Author: author, Reason: reason}
+	return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "unmergeable", arianeConfig.Messages.Unmergeable, defaultUnmergeableMessage, data, logger)
+}
+
+// replyDeprecated tells the author of a trigger comment matching a deprecated trigger which command to use instead
+func (h *PRCommentHandler) replyDeprecated(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, author, command string, deprecation config.DeprecationConfig, logger zerolog.Logger) error {
+	data := MessageData{Author: author, Command: command, Replacement: deprecation.Replacement, Refused: deprecation.Refuse}
+	return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "deprecated", arianeConfig.Messages.Deprecated, defaultDeprecatedMessage, data, logger)
+}
+
+// postRunLinks replies to a trigger comment with links to its dispatched runs, once they were all looked up.
+// Nothing is posted if none of them were found.
+func (h *PRCommentHandler) postRunLinks(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, author, commentURL string, links *runLinks, logger zerolog.Logger) error {
+	runs := links.wait()
+	if len(runs) == 0 {
+		logger.Debug().Msg("No dispatched run found, not replying with run links")
+		return nil
+	}
+	data := MessageData{Author: author, CommentURL: commentURL, Runs: runs}
+	return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "run-links", arianeConfig.Messages.RunLinks, defaultRunLinksMessage, data, logger)
+}
+
+// postMessage renders a message template and posts it as a PR comment. Nothing is posted if both the
+// configured and default templates are empty.
+func (h *PRCommentHandler) postMessage(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, name, text, defaultText string, data MessageData, logger zerolog.Logger) error {
+	if text == "" && defaultText == "" {
+		return nil
+	}
+	body, err := renderTemplate(arianeConfig, name, text, defaultText, data)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to render %s message template", name)
+		return err
+	}
+	if _, _, err := client.Issues.CreateComment(ctx, owner, repo, prNumber, &github.IssueComment{Body: github.String(body)}); err != nil {
+		logger.Error().Err(err).Msgf("Failed to post %s message", name)
+		return err
+	}
+	return nil
+}
+
+// postSummary renders a summary message template, and edits the summary comment of the PR with it, keeping the
+// previous summaries collapsed below it up to the configured history. The comment is created if not posted yet.
+func (h *PRCommentHandler) postSummary(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, name, text, defaultText string, data MessageData, logger zerolog.Logger) error {
+	if text == "" && defaultText == "" {
+		return nil
+	}
+	body, err := renderTemplate(arianeConfig, name, text, defaultText, data)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to render %s message template", name)
+		return err
+	}
+
+	previous, err := h.findSummaryComment(ctx, client, owner, repo, prNumber)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list PR comments")
+		return err
+	}
+	if previous == nil {
+		comment := &github.IssueComment{Body: github.String(renderSummaries([]string{body}))}
+		if _, _, err := client.Issues.CreateComment(ctx, owner, repo, prNumber, comment); err != nil {
+			logger.Error().Err(err).Msgf("Failed to post %s message", name)
+			return err
+		}
+		return nil
+	}
+
+	summaries := append([]string{body}, parseSummaries(previous.GetBody())...)
+	if history := arianeConfig.Messages.SummaryHistory; len(summaries) > history+1 {
+		summaries = summaries[:max(history, 0)+1]
+	}
+	comment := &github.IssueComment{Body: github.String(renderSummaries(summaries))}
+	if _, _, err := client.Issues.EditComment(ctx, owner, repo, previous.GetID(), comment); err != nil {
+		logger.Error().Err(err).Msgf("Failed to edit %s message", name)
+		return err
+	}
+	return nil
+}
+
+// resetSummary replaces the summaries of the summary comment of a PR with the summary-reset message if the PR was
+// force-pushed from before to after, rather than keeping summaries of commits which are gone. The comment is kept,
+// so the PR has a single summary comment for its whole life.
+func (h *PRCommentHandler) resetSummary(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, pusher, before, after string, logger zerolog.Logger) error {
+	if before == "" {
+		return nil
+	}
+	comparison, _, err := client.Repositories.CompareCommits(ctx, owner, repo, before, after, &github.ListOptions{PerPage: 1})
+	if err != nil {
+		// the previous head may have been garbage collected, leave the summaries alone
+		logger.Warn().Err(err).Msgf("Failed to compare %s with %s, not resetting the summary comment", before, after)
+		return nil
+	}
+	// the new head descends from the previous one unless the PR was force-pushed
+	if status := comparison.GetStatus(); status == "ahead" || status == "identical" {
+		return nil
+	}
+	previous, err := h.findSummaryComment(ctx, client, owner, repo, prNumber)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list PR comments")
+		return err
+	}
+	if previous == nil {
+		return nil
+	}
+	body, err := renderTemplate(arianeConfig, "summary-reset", arianeConfig.Messages.SummaryReset, defaultSummaryResetMessage, MessageData{Author: pusher, Head: after})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to render summary-reset message template")
+		return err
+	}
+	comment := &github.IssueComment{Body: github.String(renderSummaries([]string{body}))}
+	if _, _, err := client.Issues.EditComment(ctx, owner, repo, previous.GetID(), comment); err != nil {
+		logger.Error().Err(err).Msg("Failed to reset the summary comment")
+		return err
+	}
+	logger.Info().Msgf("Reset the summary comment, as the pull request was force-pushed from %s to %s", before, after)
+	return nil
+}
+
+// findSummaryComment returns the last summary comment posted by Ariane on a PR, if any
+func (h *PRCommentHandler) findSummaryComment(ctx context.Context, client *github.Client, owner, repo string, prNumber int) (*github.IssueComment, error) {
+	return h.findMarkedComment(ctx, client, owner, repo, prNumber, summaryMarker)
+}
+
+// findMarkedComment returns the last comment posted by Ariane on a PR starting with marker, if any
+func (h *PRCommentHandler) findMarkedComment(ctx context.Context, client *github.Client, owner, repo string, prNumber int, marker string) (*github.IssueComment, error) {
+	var found *github.IssueComment
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, response, err := client.Issues.ListComments(ctx, owner, repo, prNumber, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, comment := range comments {
+			// without a configured login, rely on the marker alone
+			if strings.HasPrefix(comment.GetBody(), marker) && (h.BotLogin == "" || h.isOwnLogin(comment.GetUser().GetLogin())) {
+				found = comment
+			}
+		}
+		if response.NextPage == 0 {
+			return found, nil
+		}
+		opts.Page = response.NextPage
+	}
+}
+
+// renderSummaries renders the body of the summary comment, the latest summary first and the previous ones collapsed
+func renderSummaries(summaries []string) string {
+	var b strings.Builder
+	b.WriteString(summaryMarker + "\n" + summaryEntryMarker + "\n" + summaries[0])
+	if len(summaries) > 1 {
+		b.WriteString("\n\n" + summaryHistoryOpen + "\n")
+		for _, summary := range summaries[1:] {
+			b.WriteString("\n" + summaryEntryMarker + "\n" + summary + "\n")
+		}
+		b.WriteString("\n" + summaryHistoryEnd)
+	}
+	return b.String()
+}
+
+// parseSummaries returns the summaries kept in the body of the summary comment, the latest first
+func parseSummaries(body string) []string {
+	entries := strings.Split(body, summaryEntryMarker)
+	summaries := make([]string, 0, len(entries))
+	for _, entry := range entries[1:] {
+		entry = strings.TrimSpace(entry)
+		entry = strings.TrimSpace(strings.TrimSuffix(entry, summaryHistoryOpen))
+		entry = strings.TrimSpace(strings.TrimSuffix(entry, summaryHistoryEnd))
+		summaries = append(summaries, entry)
+	}
+	return summaries
+}
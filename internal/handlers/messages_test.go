@@ -0,0 +1,282 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_parseCommand(t *testing.T) {
+	testCases := []struct {
+		Comment         string
+		ExpectedCommand string
+		ExpectedFound   bool
+	}{
+		{Comment: "/ariane help", ExpectedCommand: "help", ExpectedFound: true},
+		{Comment: "  /ariane   help  please", ExpectedCommand: "help", ExpectedFound: true},
+		{Comment: "/ariane", ExpectedCommand: "", ExpectedFound: true},
+		{Comment: "/ariane-test", ExpectedFound: false},
+		{Comment: "/test", ExpectedFound: false},
+		{Comment: "", ExpectedFound: false},
+	}
+	for idx, testCase := range testCases {
+		command, found := parseCommand(testCase.Comment)
+		assert.Equal(t, testCase.ExpectedFound, found, "[TEST%v]", idx+1)
+		assert.Equal(t, testCase.ExpectedCommand, command, "[TEST%v]", idx+1)
+	}
+}
+
+func Test_renderMessages(t *testing.T) {
+	arianeConfig := &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{
+			"/test":  {Workflows: []string{"foo.yaml", "bar.yaml"}},
+			"/build": {Workflows: []string{"build.yaml"}},
+			"/old":   {Workflows: []string{"build.yaml"}, Deprecated: &config.DeprecationConfig{Replacement: "/build"}},
+		},
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"build.yaml": {Name: "Build", Description: "Builds the images"},
+		},
+	}
+
+	body, err := renderTemplate(arianeConfig, "help", "", defaultHelpMessage, MessageData{Author: "contributor", Triggers: allTriggers(arianeConfig)})
+	assert.NoError(t, err)
+	assert.Contains(t, body, "@contributor")
+	assert.Contains(t, body, "- `/build`: Build\n  - Build: Builds the images\n- `/test`: foo.yaml, bar.yaml")
+	assert.NotContains(t, body, "/old", "deprecated triggers are not listed")
+
+	deprecated := MessageData{Author: "contributor", Command: "/old", Replacement: "/build", Refused: true}
+	body, err = renderTemplate(arianeConfig, "deprecated", "", defaultDeprecatedMessage, deprecated)
+	assert.NoError(t, err)
+	assert.Equal(t, "@contributor `/old` is deprecated, please use `/build` instead. The workflows were not run.", body)
+
+	body, err = renderTemplate(arianeConfig, "unknown-command", "", defaultUnknownCommandMessage, MessageData{Author: "contributor", Command: "/ariane foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, "@contributor unknown command `/ariane foo`, comment `/ariane help` to list the available commands.", body)
+
+	summary := MessageData{
+		Author:     "contributor",
+		Dispatched: []string{"foo.yaml"},
+		Skipped:    []SkippedWorkflow{{Workflow: "bar.yaml", Reason: decision.No(decision.ReasonPathsNotMatched, "no changed file matches paths-regex")}},
+	}
+	text := `Ran {{ join .Dispatched ", " }}{{ range .Skipped }}, skipped {{ name .Workflow }} ({{ .Reason.Reason }}){{ end }}`
+	body, err = renderTemplate(arianeConfig, "summary", text, "", summary)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ran foo.yaml, skipped bar.yaml (paths_not_matched)", body)
+
+	nothingRun := MessageData{
+		Author:  "contributor",
+		Skipped: []SkippedWorkflow{{Workflow: "build.yaml", Reason: decision.Yes(decision.ReasonPreviousRunSucceeded, "last run succeeded")}},
+	}
+	body, err = renderTemplate(arianeConfig, "nothing-run", "", defaultNothingRunMessage, nothingRun)
+	assert.NoError(t, err)
+	assert.Equal(t, "@contributor no workflow was run, as all of them were skipped:\n\n- Build: last run succeeded\n", body)
+}
+
+func Test_postSummary(t *testing.T) {
+	var edited, created []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		// PR 1 has no summary yet, PR 2 has one with two summaries
+		comments := []*github.IssueComment{
+			{ID: github.Int64(10), User: &github.User{Login: github.String("contributor")}, Body: github.String("/test")},
+		}
+		if r.PathValue("number") == "2" {
+			comments = append(comments, &github.IssueComment{
+				ID:   github.Int64(20),
+				User: &github.User{Login: github.String("ariane[bot]")},
+				Body: github.String(renderSummaries([]string{"second", "first"})),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(comments)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		created = append(created, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(comment)
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/issues/comments/20", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		edited = append(edited, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{BotLogin: "ariane[bot]"}
+	arianeConfig := &config.ArianeConfig{Messages: config.MessagesConfig{SummaryHistory: 1}}
+	var logger zerolog.Logger
+
+	assert.NoError(t, handler.postSummary(context.Background(), client, arianeConfig, "owner", "repo", 1, "summary", "third", "", MessageData{}, logger))
+	assert.Equal(t, []string{renderSummaries([]string{"third"})}, created, "the summary comment is created if not posted yet")
+
+	assert.NoError(t, handler.postSummary(context.Background(), client, arianeConfig, "owner", "repo", 2, "summary", "third", "", MessageData{}, logger))
+	assert.Len(t, edited, 1, "the summary comment is edited")
+	assert.Equal(t, []string{"third", "second"}, parseSummaries(edited[0]), "previous summaries are kept up to the configured history")
+	assert.Contains(t, edited[0], summaryHistoryOpen)
+}
+
+func Test_resetSummary(t *testing.T) {
+	var edited []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/compare/{basehead}", func(w http.ResponseWriter, r *http.Request) {
+		status := "ahead"
+		if r.PathValue("basehead") == "old-sha...forced-sha" {
+			status = "diverged"
+		}
+		_ = json.NewEncoder(w).Encode(github.CommitsComparison{Status: github.String(status)})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.IssueComment{{
+			ID:   github.Int64(20),
+			User: &github.User{Login: github.String("ariane[bot]")},
+			Body: github.String(renderSummaries([]string{"second", "first"})),
+		}})
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/issues/comments/20", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		edited = append(edited, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{BotLogin: "ariane[bot]"}
+	arianeConfig := &config.ArianeConfig{}
+	var logger zerolog.Logger
+
+	assert.NoError(t, handler.resetSummary(context.Background(), client, arianeConfig, "owner", "repo", 1, "contributor", "old-sha", "new-sha", logger))
+	assert.Empty(t, edited, "summaries are kept when new commits are pushed")
+
+	assert.NoError(t, handler.resetSummary(context.Background(), client, arianeConfig, "owner", "repo", 1, "contributor", "old-sha", "forced-sha", logger))
+	assert.Len(t, edited, 1, "the summary comment is edited rather than a new one posted")
+	assert.Equal(t, []string{"The previous summaries were cleared, as the pull request was force-pushed to forced-sha."}, parseSummaries(edited[0]))
+}
+
+func Test_postRunLinks(t *testing.T) {
+	var created []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		created = append(created, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	arianeConfig := &config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{"foo.yaml": {Name: "Foo"}},
+	}
+	var logger zerolog.Logger
+
+	// the lookups complete in any order, the reply waits for all of them
+	links := &runLinks{}
+	for _, workflow := range []string{"foo.yaml", "bar.yaml", "baz.yaml"} {
+		links.add()
+		go func() {
+			defer links.done()
+			if workflow != "baz.yaml" {
+				links.found(workflow, &github.WorkflowRun{Name: github.String("CI"), RunNumber: github.Int(7), HTMLURL: github.String("https://github.com/owner/repo/actions/runs/" + workflow)})
+			}
+		}()
+	}
+	assert.NoError(t, handler.postRunLinks(context.Background(), client, arianeConfig, "owner", "repo", 1, "contributor", "https://github.com/owner/repo/pull/1#issuecomment-10", links, logger))
+	assert.Equal(t, []string{`@contributor the runs dispatched for [your comment](https://github.com/owner/repo/pull/1#issuecomment-10):
+
+- bar.yaml: [CI #7](https://github.com/owner/repo/actions/runs/bar.yaml)
+- Foo: [CI #7](https://github.com/owner/repo/actions/runs/foo.yaml)
+`}, created, "runs not found are left out")
+
+	// nothing is posted without runs found
+	links = &runLinks{}
+	links.add()
+	links.done()
+	assert.NoError(t, handler.postRunLinks(context.Background(), client, arianeConfig, "owner", "repo", 1, "contributor", "", links, logger))
+	assert.Len(t, created, 1)
+}
+
+func Test_reactToComment(t *testing.T) {
+	var created []string
+	mux := http.NewServeMux()
+	// reactions are disabled on the repository
+	mux.HandleFunc("POST /repos/owner/repo/issues/comments/{id}/reactions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		created = append(created, comment.GetBody())
+		_ = json.NewEncoder(w).Encode(comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	var logger zerolog.Logger
+
+	arianeConfig := &config.ArianeConfig{}
+	assert.Error(t, handler.reactToComment(context.Background(), client, arianeConfig, "owner", "repo", 1, 10, "contributor", "rocket", logger))
+	assert.Empty(t, created, "no comment is posted unless the fallback is enabled")
+
+	arianeConfig.Reactions.FallbackComment = true
+	assert.NoError(t, handler.reactToComment(context.Background(), client, arianeConfig, "owner", "repo", 1, 10, "contributor", "rocket", logger))
+	assert.Equal(t, []string{"@contributor :rocket:"}, created)
+}
+
+func Test_checkDeprecation(t *testing.T) {
+	testCases := []struct {
+		Trigger        config.TriggerConfig
+		ExpectedResult bool
+		ExpectedCode   decision.Reason
+		ExpectedReason string
+	}{
+		{
+			Trigger:        config.TriggerConfig{},
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonTriggerCurrent,
+			ExpectedReason: "current triggers run.",
+		},
+		{
+			Trigger:        config.TriggerConfig{Deprecated: &config.DeprecationConfig{Replacement: "/test"}},
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonTriggerDeprecated,
+			ExpectedReason: "deprecated triggers still run.",
+		},
+		{
+			Trigger:        config.TriggerConfig{Deprecated: &config.DeprecationConfig{Replacement: "/test", Refuse: true}},
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonTriggerRetired,
+			ExpectedReason: "deprecated triggers no longer run once refused.",
+		},
+	}
+	for idx, testCase := range testCases {
+		result := checkDeprecation("/test-all", testCase.Trigger)
+		assert.Equal(t, testCase.ExpectedResult, result.Result, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// checkPathsFilterOverride decides whether the author of a trigger comment ending with --ignore-paths-filter may
+// dispatch its workflows whatever their paths filters: only the members of ignore-paths-filter-teams may
+func checkPathsFilterOverride(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, author string, logger zerolog.Logger) decision.Decision {
+	if len(arianeConfig.IgnorePathsFilterTeams) == 0 {
+		return decision.No(decision.ReasonPathsFilterOverrideDenied, "no teams may ignore the paths filters of the workflows")
+	}
+	return isTeamMember(ctx, client, arianeConfig.NestedTeams, owner, arianeConfig.IgnorePathsFilterTeams, "ignore-paths-filter", author, logger)
+}
+
+// ignorePathsFilter overrides the decision of the paths filters not to run a workflow, for trigger comments ending
+// with --ignore-paths-filter, so that the overridden workflows are told apart from the ones the filters run
+func ignorePathsFilter(t triggerDispatch, workflow string, run decision.Decision) decision.Decision {
+	if run.Result || !t.ignorePaths {
+		return run
+	}
+	return decision.Yes(decision.ReasonPathsFilterIgnored, "%s asked to run workflow %s whatever its paths filters, by which %s", t.commentAuthor, workflow, run.Message)
+}
@@ -0,0 +1,27 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_ignorePathsFilter(t *testing.T) {
+	matched := decision.Yes(decision.ReasonPathsMatched, "docs/README.md matches")
+	notMatched := decision.No(decision.ReasonPathsNotMatched, "no changed files match")
+
+	dispatch := triggerDispatch{commentAuthor: "maintainer"}
+	assert.Equal(t, notMatched, ignorePathsFilter(dispatch, "foo.yaml", notMatched))
+
+	dispatch.ignorePaths = true
+	assert.Equal(t, matched, ignorePathsFilter(dispatch, "foo.yaml", matched), "the workflows run by their paths filters are told apart")
+	run := ignorePathsFilter(dispatch, "foo.yaml", notMatched)
+	assert.True(t, run.Result)
+	assert.Equal(t, decision.ReasonPathsFilterIgnored, run.Reason)
+	assert.Equal(t, "maintainer asked to run workflow foo.yaml whatever its paths filters, by which no changed files match", run.Message)
+}
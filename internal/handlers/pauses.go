@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/state"
+)
+
+const (
+	DefaultPauseExpiry = 30 * 24 * time.Hour
+)
+
+const defaultPauseMessage = "@{{ .Author }} {{ if .Paused }}Ariane is paused on this pull request: trigger comments are ignored, " +
+	"and skipped checks are not carried over to new commits, until `" + commandPrefix + " on` is commented." +
+	"{{ else }}Ariane is resumed on this pull request.{{ end }}"
+
+const defaultPausedMessage = "@{{ .Author }} the workflows were not run, as {{ .Reason.Message }}. Comment `" + commandPrefix + " on` to resume it."
+
+// PauseStore records the pull requests Ariane is paused on, with the login of the user who paused it, keyed by
+// pull request. A nil PauseStore is valid and pauses nothing.
+type PauseStore struct {
+	state  state.Store
+	expiry time.Duration
+}
+
+// NewPauseStore creates a pause store kept in store, in memory if nil
+func NewPauseStore(store state.Store, expiry time.Duration) *PauseStore {
+	if expiry <= 0 {
+		expiry = DefaultPauseExpiry
+	}
+	return &PauseStore{state: orMemory(store), expiry: expiry}
+}
+
+func pauseStoreKey(owner, repo string, prNumber int) string {
+	return fmt.Sprintf("pause/%s/%s#%d", owner, repo, prNumber)
+}
+
+func (s *PauseStore) pause(ctx context.Context, owner, repo string, prNumber int, author string) {
+	if s == nil {
+		return
+	}
+	setState(ctx, s.state, pauseStoreKey(owner, repo, prNumber), author, s.expiry)
+}
+
+func (s *PauseStore) resume(ctx context.Context, owner, repo string, prNumber int) {
+	if s == nil {
+		return
+	}
+	deleteState(ctx, s.state, pauseStoreKey(owner, repo, prNumber))
+}
+
+// pausedBy returns the login of the user who paused Ariane on a pull request, if paused
+func (s *PauseStore) pausedBy(ctx context.Context, owner, repo string, prNumber int) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	return getState[string](ctx, s.state, pauseStoreKey(owner, repo, prNumber))
+}
+
+// checkPaused decides whether the workflows of a trigger comment run, which they do not on paused pull requests
+func (h *PRCommentHandler) checkPaused(ctx context.Context, owner, repo string, prNumber int) decision.Decision {
+	if author, paused := h.Pauses.pausedBy(ctx, owner, repo, prNumber); paused {
+		return decision.No(decision.ReasonPaused, "Ariane was paused on pull request #%d by @%s", prNumber, author)
+	}
+	return decision.Yes(decision.ReasonNotPaused, "Ariane is not paused on pull request #%d", prNumber)
+}
+
+// togglePause pauses or resumes Ariane on a pull request, as commented by an allowed user
+func (h *PRCommentHandler) togglePause(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, author string, paused bool, logger zerolog.Logger) error {
+	if membership := recordDecision(logger, stepMembership, h.isAllowedTeamMember(ctx, client, arianeConfig, owner, author, logger)); !membership.Result {
+		audit.Event(ctx, "pause_rejected").Str("author", author).Bool("paused", paused).Object("decision", membership).Send()
+		data := MessageData{Author: author, Reason: membership}
+		return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "rejection", arianeConfig.Messages.Rejection, "", data, logger)
+	}
+
+	if paused {
+		h.Pauses.pause(ctx, owner, repo, prNumber, author)
+		audit.Event(ctx, "pr_paused").Str("author", author).Send()
+	} else {
+		h.Pauses.resume(ctx, owner, repo, prNumber)
+		audit.Event(ctx, "pr_resumed").Str("author", author).Send()
+	}
+	data := MessageData{Author: author, Paused: paused}
+	return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "pause", arianeConfig.Messages.Pause, defaultPauseMessage, data, logger)
+}
+
+// rejectPaused tells the author of a trigger comment that its workflows were not run, as Ariane is paused on the PR
+func (h *PRCommentHandler) rejectPaused(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, author string, reason decision.Decision, logger zerolog.Logger) error {
+	audit.Event(ctx, "trigger_rejected").Str("author", author).Object("decision", reason).Send()
+	data := MessageData{Author: author, Reason: reason}
+	return h.postMessage(ctx, client, arianeConfig, owner, repo, prNumber, "paused", arianeConfig.Messages.Paused, defaultPausedMessage, data, logger)
+}
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_togglePause(t *testing.T) {
+	var comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orgs/owner/teams/organization-members/memberships/{author}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("author") != "trustedauthor" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(github.Membership{State: github.String("active")})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{Pauses: NewPauseStore(nil, 0)}
+	arianeConfig := &config.ArianeConfig{AllowedTeams: []string{"organization-members"}}
+	ctx := context.Background()
+	logger := zerolog.Nop()
+
+	assert.NoError(t, handler.handleCommand(ctx, client, arianeConfig, "owner", "repo", 1, "mock-sha", "unknownauthor", "off", logger))
+	assert.Empty(t, comments, "the rejection message is only posted if set")
+	assert.True(t, handler.checkPaused(context.Background(), "owner", "repo", 1).Result, "users outside of the allowed teams cannot pause Ariane")
+
+	assert.NoError(t, handler.handleCommand(ctx, client, arianeConfig, "owner", "repo", 1, "mock-sha", "trustedauthor", "off", logger))
+	paused := handler.checkPaused(context.Background(), "owner", "repo", 1)
+	assert.False(t, paused.Result)
+	assert.Equal(t, decision.ReasonPaused, paused.Reason)
+	assert.Equal(t, "Ariane was paused on pull request #1 by @trustedauthor", paused.Message)
+	assert.True(t, handler.checkPaused(context.Background(), "owner", "repo", 2).Result, "other pull requests are not paused")
+
+	assert.NoError(t, handler.rejectPaused(ctx, client, arianeConfig, "owner", "repo", 1, "contributor", paused, logger))
+
+	assert.NoError(t, handler.handleCommand(ctx, client, arianeConfig, "owner", "repo", 1, "mock-sha", "trustedauthor", "on", logger))
+	assert.True(t, handler.checkPaused(context.Background(), "owner", "repo", 1).Result)
+
+	assert.Equal(t, []string{
+		"@trustedauthor Ariane is paused on this pull request: trigger comments are ignored, and skipped checks are not carried over to new commits, until `/ariane on` is commented.",
+		"@contributor the workflows were not run, as Ariane was paused on pull request #1 by @trustedauthor. Comment `/ariane on` to resume it.",
+		"@trustedauthor Ariane is resumed on this pull request.",
+	}, comments)
+}
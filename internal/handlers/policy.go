@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/go-github/v75/github"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/policy"
+)
+
+const defaultPolicyDeniedMessage = "@{{ .Author }} the workflows were not run, as {{ .Reason.Message }}."
+
+// labelNames returns the names of the labels of a PR or issue
+func labelNames(labels []*github.Label) []string {
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		names = append(names, label.GetName())
+	}
+	return names
+}
+
+// policyInput returns the dispatch plan of a trigger comment, as evaluated by the dispatch policy
+func (h *PRCommentHandler) policyInput(t triggerDispatch, files []*github.CommitFile) policy.Input {
+	filenames := make([]string, 0, len(files))
+	for _, file := range files {
+		filenames = append(filenames, file.GetFilename())
+	}
+	var command string
+	if len(t.submatch) > 0 {
+		command = t.submatch[0]
+	}
+	return policy.Input{
+		Repository: t.owner + "/" + t.repo,
+		Number:     t.prNumber,
+		Issue:      t.isIssue,
+		Author:     t.commentAuthor,
+		Command:    command,
+		Labels:     t.labels,
+		Files:      filenames,
+		BaseBranch: t.baseRef,
+		Ref:        t.contextRef,
+		SHA:        t.SHA,
+		Tag:        t.tag,
+		Workflows:  t.workflows,
+		Time:       h.Scheduler.Now(),
+	}
+}
+
+// checkPolicy evaluates the dispatch plan of a trigger comment against the dispatch policy, if any. It returns
+// whether the plan is allowed, and the workflows the policy excluded from it, if it narrowed it down.
+func (h *PRCommentHandler) checkPolicy(ctx context.Context, t triggerDispatch, files []*github.CommitFile) (allowed decision.Decision, excluded map[string]decision.Decision, err error) {
+	if h.Policy == nil {
+		return decision.Yes(decision.ReasonNoPolicy, "no dispatch policy is set"), nil, nil
+	}
+	result, err := h.Policy.Evaluate(ctx, h.policyInput(t, files))
+	if err != nil {
+		t.logger.Error().Err(err).Msg("Failed to evaluate the dispatch policy")
+		return decision.Decision{}, nil, err
+	}
+	if !result.Allow {
+		if result.Reason == "" {
+			return recordDecision(t.logger, stepPolicy, decision.No(decision.ReasonPolicyDenied, "the dispatch policy denied them")), nil, nil
+		}
+		return recordDecision(t.logger, stepPolicy, decision.No(decision.ReasonPolicyDenied, "the dispatch policy denied them: %s", result.Reason)), nil, nil
+	}
+	if result.Workflows != nil {
+		excluded = map[string]decision.Decision{}
+		for _, workflow := range t.workflows {
+			if !slices.Contains(result.Workflows, workflow) {
+				excluded[workflow] = decision.No(decision.ReasonPolicyExcluded, "workflow %s is excluded by the dispatch policy", workflow)
+			}
+		}
+	}
+	return recordDecision(t.logger, stepPolicy, decision.Yes(decision.ReasonPolicyAllowed, "the dispatch policy allowed %d of the %d workflows", len(t.workflows)-len(excluded), len(t.workflows))), excluded, nil
+}
+
+// rejectPolicy tells the author of a trigger comment the dispatch policy denied its workflows
+func (h *PRCommentHandler) rejectPolicy(ctx context.Context, t triggerDispatch, denied decision.Decision) error {
+	audit.Event(ctx, "trigger_rejected").Str("author", t.commentAuthor).Object("decision", denied).Send()
+	data := MessageData{Author: t.commentAuthor, Reason: denied}
+	return h.postMessage(ctx, t.client, t.arianeConfig, t.owner, t.repo, t.prNumber, "policy-denied", t.arianeConfig.Messages.PolicyDenied, defaultPolicyDeniedMessage, data, t.logger)
+}
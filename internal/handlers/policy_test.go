@@ -0,0 +1,92 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/policy"
+)
+
+func Test_checkPolicy(t *testing.T) {
+	var input policy.Input
+	var result string
+	policyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Input policy.Input `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		input = request.Input
+		_, _ = w.Write([]byte(result))
+	}))
+	defer policyServer.Close()
+
+	var comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	dispatch := triggerDispatch{
+		client:        client,
+		arianeConfig:  &config.ArianeConfig{},
+		owner:         "owner",
+		repo:          "repo",
+		prNumber:      1,
+		commentAuthor: "author",
+		baseRef:       "main",
+		labels:        []string{"area/ci"},
+		workflows:     []string{"foo.yaml", "bar.yaml"},
+		submatch:      []string{"/test"},
+		logger:        zerolog.Nop(),
+	}
+	files := []*github.CommitFile{{Filename: github.String("main.go")}}
+
+	// no policy is set
+	handler := &PRCommentHandler{}
+	allowed, excluded, err := handler.checkPolicy(context.Background(), dispatch, files)
+	assert.NoError(t, err)
+	assert.True(t, allowed.Result)
+	assert.Empty(t, excluded)
+
+	handler.Policy = &policy.Engine{URL: policyServer.URL}
+	result = `{"result": {"allow": true, "workflows": ["foo.yaml"]}}`
+	allowed, excluded, err = handler.checkPolicy(context.Background(), dispatch, files)
+	assert.NoError(t, err)
+	assert.True(t, allowed.Result)
+	assert.Equal(t, "the dispatch policy allowed 1 of the 2 workflows", allowed.Message)
+	assert.Equal(t, decision.ReasonPolicyExcluded, excluded["bar.yaml"].Reason)
+	assert.NotContains(t, excluded, "foo.yaml")
+	assert.Equal(t, "owner/repo", input.Repository)
+	assert.Equal(t, "/test", input.Command)
+	assert.Equal(t, "main", input.BaseBranch)
+	assert.Equal(t, []string{"area/ci"}, input.Labels)
+	assert.Equal(t, []string{"main.go"}, input.Files)
+
+	result = `{"result": {"allow": false, "reason": "main.go is frozen"}}`
+	allowed, _, err = handler.checkPolicy(context.Background(), dispatch, files)
+	assert.NoError(t, err)
+	assert.False(t, allowed.Result)
+	assert.NoError(t, handler.rejectPolicy(context.Background(), dispatch, allowed))
+	assert.Equal(t, []string{"@author the workflows were not run, as the dispatch policy denied them: main.go is frozen."}, comments)
+}
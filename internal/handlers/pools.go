@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cilium/ariane/internal/metrics"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+const (
+	// DefaultPoolHoldTimeout is how long a dispatch holds its pool slot if its run is never seen completing
+	DefaultPoolHoldTimeout = 6 * time.Hour
+)
+
+var (
+	poolRunning = metrics.NewGaugeVec("ariane_pool_running",
+		"Dispatches holding a slot of a resource pool, by pool.",
+		"pool")
+	poolQueued = metrics.NewGaugeVec("ariane_pool_queued",
+		"Dispatches waiting for a slot of a resource pool, by pool.",
+		"pool")
+)
+
+// poolSlot is held by a dispatched workflow of a limited pool until its run completes
+type poolSlot struct {
+	pool string
+	// runID is the dispatched run once found, zero until then
+	runID int64
+	// cancelHold stops the timer releasing the slot after the hold timeout
+	cancelHold context.CancelFunc
+}
+
+// Pools limits how many dispatched runs of the workflows tagged with each resource pool run at once, queueing the
+// dispatches past the limit until a run of the pool completes. Pools without a limit are not limited.
+// A nil Pools is valid and limits nothing.
+type Pools struct {
+	// HoldTimeout releases the slots whose run was not seen completing, DefaultPoolHoldTimeout if zero
+	HoldTimeout time.Duration
+	Scheduler   scheduler.Scheduler
+
+	mu      sync.Mutex
+	limits  map[string]int
+	running map[string]int
+	queued  map[string][]func(*poolSlot)
+	// runs are the slots whose run was found, by run ID
+	runs map[int64]*poolSlot
+}
+
+// NewPools returns Pools limiting each pool to its number of concurrent runs, nil if no pool is limited
+func NewPools(limits map[string]int, holdTimeout time.Duration) *Pools {
+	if len(limits) == 0 {
+		return nil
+	}
+	return &Pools{
+		HoldTimeout: holdTimeout,
+		limits:      limits,
+		running:     map[string]int{},
+		queued:      map[string][]func(*poolSlot){},
+		runs:        map[int64]*poolSlot{},
+	}
+}
+
+// acquire takes a slot of the pool for a dispatch. It returns the slot if one is free, or queues start to be
+// called with one once freed and reports it queued. The slot is nil for workflows of pools without a limit.
+func (p *Pools) acquire(pool string, start func(*poolSlot)) (*poolSlot, bool) {
+	if p == nil || p.limits[pool] <= 0 {
+		return nil, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running[pool] >= p.limits[pool] {
+		p.queued[pool] = append(p.queued[pool], start)
+		poolQueued.Set(float64(len(p.queued[pool])), pool)
+		return nil, true
+	}
+	return p.take(pool), false
+}
+
+// take occupies a slot of the pool, which is released after the hold timeout at the latest
+func (p *Pools) take(pool string) *poolSlot {
+	p.running[pool]++
+	poolRunning.Set(float64(p.running[pool]), pool)
+	slot := &poolSlot{pool: pool}
+	timeout := p.HoldTimeout
+	if timeout <= 0 {
+		timeout = DefaultPoolHoldTimeout
+	}
+	slot.cancelHold = p.Scheduler.After(context.Background(), timeout, func(context.Context) {
+		p.release(slot)
+	})
+	return slot
+}
+
+// bind has the slot held until the given run completes
+func (p *Pools) bind(slot *poolSlot, runID int64) {
+	if p == nil || slot == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	slot.runID = runID
+	p.runs[runID] = slot
+}
+
+// completed releases the slot held by a run, if any
+func (p *Pools) completed(runID int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	slot, ok := p.runs[runID]
+	p.mu.Unlock()
+	if ok {
+		p.release(slot)
+	}
+}
+
+// release frees a slot, starting the oldest dispatch queued for its pool. Releasing a slot twice is a no-op.
+func (p *Pools) release(slot *poolSlot) {
+	if p == nil || slot == nil {
+		return
+	}
+	p.mu.Lock()
+	if slot.cancelHold == nil {
+		p.mu.Unlock()
+		return
+	}
+	slot.cancelHold()
+	slot.cancelHold = nil
+	delete(p.runs, slot.runID)
+	p.running[slot.pool]--
+
+	var start func(*poolSlot)
+	var next *poolSlot
+	if queued := p.queued[slot.pool]; len(queued) > 0 {
+		start, p.queued[slot.pool] = queued[0], queued[1:]
+		next = p.take(slot.pool)
+	}
+	poolRunning.Set(float64(p.running[slot.pool]), slot.pool)
+	poolQueued.Set(float64(len(p.queued[slot.pool])), slot.pool)
+	p.mu.Unlock()
+
+	if start != nil {
+		start(next)
+	}
+}
@@ -0,0 +1,135 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/poll"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+func running(p *Pools, pool string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running[pool]
+}
+
+func TestPools(t *testing.T) {
+	assert.Nil(t, NewPools(nil, 0), "pools are not limited without limits")
+
+	clock := scheduler.NewFakeClock(time.Now())
+	pools := NewPools(map[string]int{"arm": 1}, time.Hour)
+	pools.Scheduler = scheduler.Scheduler{Clock: clock}
+
+	var started []*poolSlot
+	start := func(slot *poolSlot) { started = append(started, slot) }
+	slot, queued := pools.acquire("gke", start)
+	assert.Nil(t, slot, "pools without a limit hold no slot")
+	assert.False(t, queued)
+
+	slot, queued = pools.acquire("arm", start)
+	assert.NotNil(t, slot)
+	assert.False(t, queued)
+	_, queued = pools.acquire("arm", start)
+	assert.True(t, queued, "dispatches past the limit are queued")
+	assert.Empty(t, started)
+
+	pools.bind(slot, 42)
+	pools.completed(42)
+	assert.Len(t, started, 1, "the queued dispatch starts once the run completes")
+	assert.NotNil(t, started[0])
+	assert.Equal(t, 1, running(pools, "arm"))
+	pools.release(slot)
+	assert.Equal(t, 1, running(pools, "arm"), "releasing a slot twice is a no-op")
+
+	// both slots waited on the hold timeout
+	clock.BlockUntil(2)
+	clock.Advance(time.Hour)
+	pools.Scheduler.Wait()
+	assert.Equal(t, 0, running(pools, "arm"), "slots of runs never seen completing are released after the hold timeout")
+}
+
+func TestPoolsDispatch(t *testing.T) {
+	var mu sync.Mutex
+	var dispatched []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/{workflow}/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		dispatched = append(dispatched, r.PathValue("workflow"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}/runs", func(w http.ResponseWriter, r *http.Request) {
+		runID := map[string]int64{"arm-e2e.yaml": 42, "arm-unit.yaml": 43}[r.PathValue("workflow")]
+		_ = json.NewEncoder(w).Encode(github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{
+			{ID: github.Ptr(runID), Name: github.Ptr("E2E"), Status: github.Ptr("in_progress"), HeadSHA: github.Ptr("mock-sha")},
+		}})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/commits/mock-sha/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(github.ListCheckRunsResults{})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(github.CheckRun{ID: github.Ptr(int64(7))})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/comments/{id}/reactions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(github.Reaction{})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	pools := NewPools(map[string]int{"arm": 1}, time.Hour)
+	handler := &PRCommentHandler{Pools: pools, DispatchVerifyTimeout: time.Minute, Poll: poll.Poller{Interval: time.Millisecond}}
+	arianeConfig := &config.ArianeConfig{Workflows: map[string]config.WorkflowPathsRegexConfig{
+		"arm-e2e.yaml":  {Pool: "arm"},
+		"arm-unit.yaml": {Pool: "arm"},
+		"gke.yaml":      {Pool: "gke"},
+	}}
+	dispatch := triggerDispatch{
+		client:        client,
+		arianeConfig:  arianeConfig,
+		settings:      config.RepositorySettings{DispatchVerifyTimeout: time.Minute},
+		owner:         "owner",
+		repo:          "repo",
+		prNumber:      1,
+		isIssue:       true,
+		commentID:     1,
+		commentAuthor: "contributor",
+		contextRef:    "main",
+		SHA:           "mock-sha",
+		workflows:     []string{"arm-e2e.yaml", "arm-unit.yaml", "gke.yaml"},
+		event:         handler.createWorkflowDispatchEvent(1, "main", "mock-sha", []string{"/test"}, nil),
+		logger:        zerolog.Nop(),
+	}
+	assert.NoError(t, handler.dispatchWorkflows(context.Background(), dispatch, nil))
+	handler.Scheduler.Wait()
+	assert.Equal(t, []string{"arm-e2e.yaml", "gke.yaml"}, dispatched, "the dispatch past the limit of its pool is queued")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(client, nil).AnyTimes()
+	workflowRunHandler := &WorkflowRunHandler{ClientCreator: mockClientCreator, Pools: pools}
+	payload := []byte(`{"action": "completed", "repository": {"owner": {"login": "owner"}, "name": "repo", "full_name": "owner/repo"},
+		"workflow_run": {"id": 42, "event": "workflow_dispatch", "status": "completed", "conclusion": "success", "head_sha": "mock-sha"}}`)
+	assert.NoError(t, workflowRunHandler.Handle(context.Background(), "workflow_run", "deliveryID", payload))
+	handler.Scheduler.Wait()
+	assert.Equal(t, []string{"arm-e2e.yaml", "gke.yaml", "arm-unit.yaml"}, dispatched, "the queued dispatch runs once the run of the pool completes")
+	assert.Equal(t, 1, running(pools, "arm"))
+}
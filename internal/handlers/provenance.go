@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+// provenanceInput is the workflow_dispatch input carrying the provenance of dispatches, if enabled in the
+// repository config
+const provenanceInput = "ariane-provenance"
+
+// Provenance identifies the config which authorized and parameterized a dispatch, and the Ariane server which sent
+// it, so the policy behind each run can be reconstructed
+type Provenance struct {
+	// ConfigRef is the ref the config was read from, and ConfigSHA the blob SHA of the config file there
+	ConfigRef string `json:"config-ref"`
+	ConfigSHA string `json:"config-sha"`
+	Version   string `json:"version"`
+}
+
+func newProvenance(arianeConfig *config.ArianeConfig, version string) Provenance {
+	return Provenance{ConfigRef: arianeConfig.Source.Ref, ConfigSHA: arianeConfig.Source.SHA, Version: version}
+}
+
+// input encodes the provenance as the value of provenanceInput
+func (p Provenance) input() string {
+	// the fields are plain strings, encoding cannot fail
+	encoded, _ := json.Marshal(p)
+	return string(encoded)
+}
+
+func (p Provenance) MarshalZerologObject(e *zerolog.Event) {
+	e.Str("config_ref", p.ConfigRef).Str("config_sha", p.ConfigSHA).Str("version", p.Version)
+}
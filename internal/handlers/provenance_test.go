@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_newProvenance(t *testing.T) {
+	arianeConfig := &config.ArianeConfig{Source: config.ConfigSource{Ref: "main", SHA: "config-sha"}}
+	provenance := newProvenance(arianeConfig, "1.2.3")
+	assert.Equal(t, Provenance{ConfigRef: "main", ConfigSHA: "config-sha", Version: "1.2.3"}, provenance)
+	assert.Equal(t, `{"config-ref":"main","config-sha":"config-sha","version":"1.2.3"}`, provenance.input())
+
+	// configs which were not read from a repository have no source
+	assert.Equal(t, `{"config-ref":"","config-sha":"","version":"1.2.3"}`, newProvenance(&config.ArianeConfig{}, "1.2.3").input())
+}
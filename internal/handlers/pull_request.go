@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/log"
+)
+
+// welcomeMarker is a hidden marker identifying the welcome comment, so it is only posted once per PR
+const welcomeMarker = "<!-- ariane-welcome -->"
+
+const defaultWelcomeTemplate = `Thanks for your contribution @{{ .Author }}! :wave:
+
+Based on the files changed in this pull request, the following commands can be commented to run CI workflows:
+{{ range .Triggers }}
+- ` + "`{{ .Command }}`" + `: {{ join (names .Workflows) ", " }}{{ end }}
+`
+
+// WelcomeData is passed to the welcome comment template
+type WelcomeData struct {
+	Author   string
+	Triggers []WelcomeTrigger
+}
+
+type WelcomeTrigger struct {
+	// Command is a comment running the trigger, see config.ArianeConfig.TriggerExample
+	Command   string
+	Workflows []string
+}
+
+type PullRequestHandler struct {
+	githubapp.ClientCreator
+	// ConfigCache caches the Ariane configs fetched from repositories, if enabled
+	ConfigCache *config.Cache
+	// Workflows caches the workflows looked up to name check runs, if enabled
+	Workflows *WorkflowCache
+	// Pagination bounds the GitHub lists walked while handling pull requests, see config.DefaultPagination for unset values
+	Pagination config.PaginationConfig
+	// Overrides replaces Pagination for some repositories
+	Overrides config.Overrides
+	// Pauses records the PRs Ariane was paused on, whose skipped checks are not carried over, shared with the
+	// PRCommentHandler pausing them
+	Pauses *PauseStore
+	// Comments dispatches the ready-for-review trigger of pull requests marked ready for review, as if commented
+	Comments *PRCommentHandler
+}
+
+// pagination returns the pagination settings of a repository, with its overrides
+func (h *PullRequestHandler) pagination(owner, repo string) config.PaginationConfig {
+	return h.Overrides.For(owner, repo, config.RepositorySettings{Pagination: h.Pagination}).Pagination.WithDefaults()
+}
+
+func (h *PullRequestHandler) Handles() []string {
+	return []string{"pull_request"}
+}
+
+func (h *PullRequestHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	var event github.PullRequestEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("failed to parse pull_request event payload: %w", err)
+	}
+
+	// only handle newly opened PRs, PRs whose head changed, and draft PRs marked ready for review
+	action := event.GetAction()
+	if action != "opened" && action != "synchronize" && action != "ready_for_review" {
+		return nil
+	}
+
+	installationID := githubapp.GetInstallationIDFromEvent(&event)
+	repository := event.GetRepo()
+	pr := event.GetPullRequest()
+	prNumber := pr.GetNumber()
+	ctx, logger := githubapp.PreparePRContext(ctx, installationID, repository, prNumber)
+	ctx = log.WithLogger(ctx, &logger)
+
+	// trigger comments being handled follow the pushes to their PR, see PRCommentHandler.followHead
+	if action == "synchronize" && h.Comments != nil {
+		h.Comments.Heads.pushed(repository.GetOwner().GetLogin(), repository.GetName(), prNumber, pr.GetHead().GetSHA())
+	}
+
+	if skipInactive(ctx, repository, pr.GetLocked()) {
+		return nil
+	}
+
+	client, err := h.NewInstallationClient(installationID)
+	if err != nil {
+		return err
+	}
+
+	repositoryOwner := repository.GetOwner().GetLogin()
+	repositoryName := repository.GetName()
+
+	contextRef, _ := determineContextRef(pr, repositoryOwner, repositoryName, logger)
+	arianeConfig, err := getArianeConfigOrDefault(ctx, h.ConfigCache, client, repository, contextRef, logger)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to retrieve config file")
+		return err
+	}
+
+	// the summaries of the previous heads are about commits which are gone once force-pushed
+	if action == "synchronize" && h.Comments != nil {
+		if err := h.Comments.resetSummary(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, event.GetSender().GetLogin(), event.GetBefore(), pr.GetHead().GetSHA(), logger); err != nil {
+			return err
+		}
+	}
+
+	if (action == "opened" && !arianeConfig.Welcome.Enabled) || (action == "synchronize" && !arianeConfig.CarryOverSkipped) ||
+		(action == "ready_for_review" && (arianeConfig.ReadyForReview == "" || h.Comments == nil)) {
+		return nil
+	}
+	if author, paused := h.Pauses.pausedBy(ctx, repositoryOwner, repositoryName, prNumber); paused && action != "opened" {
+		logger.Debug().Msgf("Ariane was paused on the pull request by %s, ignoring %s action", author, action)
+		return nil
+	}
+	if action == "ready_for_review" {
+		return h.Comments.dispatchReadyForReview(ctx, client, arianeConfig, repositoryOwner, repositoryName, pr, event.GetSender().GetLogin(), contextRef, logger)
+	}
+
+	files, err := getChangedFiles(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, pr.GetBase().GetSHA(), pr.GetHead().GetSHA(), h.pagination(repositoryOwner, repositoryName).Files, logger)
+	if err != nil {
+		return err
+	}
+
+	if action == "synchronize" {
+		return h.carryOverSkippedChecks(ctx, client, arianeConfig, repositoryOwner, repositoryName, event.GetBefore(), pr.GetHead().GetSHA(), files, logger)
+	}
+	return h.postWelcomeComment(ctx, client, arianeConfig, repositoryOwner, repositoryName, prNumber, pr.GetUser().GetLogin(), files, logger)
+}
+
+// carryOverSkippedChecks re-creates the check runs, or commit statuses, of the workflows skipped on the previous head
+// SHA on the new one, if their paths filters still exclude the PR changes, so authors do not need to trigger them again
+func (h *PullRequestHandler) carryOverSkippedChecks(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo, before, after string, files []*github.CommitFile, logger zerolog.Logger) error {
+	listSkipped := skippedCheckRuns
+	if arianeConfig.ReportsStatuses() {
+		listSkipped = skippedStatuses
+	}
+	skipped, err := listSkipped(ctx, client, owner, repo, before)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to list the checks of %s", before)
+		return err
+	}
+
+	for _, check := range skipped {
+		workflow := check.workflow
+		workflowLogger := logger.With().Str("workflow", workflow).Logger()
+		run := recordDecision(workflowLogger, stepCarryOver, arianeConfig.ShouldRun(ctx, workflow, files))
+		if run.Result {
+			continue
+		}
+		// keep the check namespace of the trigger the workflow was skipped for
+		githubWorkflow, err := h.Workflows.getWorkflow(ctx, client, owner, repo, workflow)
+		if err != nil {
+			workflowLogger.Error().Err(err).Msg("Failed to retrieve workflow")
+			return err
+		}
+		audit.Event(ctx, "workflow_skipped").Str("workflow", workflow).Object("decision", run).Send()
+		if err := markWorkflowAsSkipped(ctx, h.Workflows, client, arianeConfig, owner, repo, workflow, after, checkNamespaceOf(check.name, githubWorkflow.GetName()), run, workflowLogger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skippedCheck is a check run, or commit status, marking a workflow as skipped
+type skippedCheck struct {
+	workflow string
+	name     string
+}
+
+// skippedCheckRuns returns the workflows marked as skipped on a SHA by check runs
+func skippedCheckRuns(ctx context.Context, client *github.Client, owner, repo, SHA string) ([]skippedCheck, error) {
+	var workflows []skippedCheck
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		checkRuns, res, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, SHA, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, checkRun := range checkRuns.CheckRuns {
+			if workflow, ok := strings.CutPrefix(checkRun.GetExternalID(), skippedExternalIDPrefix); ok && checkRun.GetConclusion() == "skipped" {
+				workflows = append(workflows, skippedCheck{workflow: workflow, name: checkRun.GetName()})
+			}
+		}
+		if res.NextPage == 0 {
+			return workflows, nil
+		}
+		opts.ListOptions.Page = res.NextPage
+	}
+}
+
+// skippedStatuses returns the workflows marked as skipped on a SHA by commit statuses, the newest of each context
+func skippedStatuses(ctx context.Context, client *github.Client, owner, repo, SHA string) ([]skippedCheck, error) {
+	var workflows []skippedCheck
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		combined, res, err := client.Repositories.GetCombinedStatus(ctx, owner, repo, SHA, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range combined.Statuses {
+			if workflow, ok := skippedStatusWorkflow(status); ok {
+				workflows = append(workflows, skippedCheck{workflow: workflow, name: status.GetContext()})
+			}
+		}
+		if res.NextPage == 0 {
+			return workflows, nil
+		}
+		opts.Page = res.NextPage
+	}
+}
+
+// postWelcomeComment posts the welcome comment listing the triggers relevant to the changed files, unless already posted
+// or none is relevant
+func (h *PullRequestHandler) postWelcomeComment(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, author string, files []*github.CommitFile, logger zerolog.Logger) error {
+	comments, _, err := client.Issues.ListComments(ctx, owner, repo, prNumber, &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list PR comments")
+		return err
+	}
+	for _, comment := range comments {
+		if strings.Contains(comment.GetBody(), welcomeMarker) {
+			logger.Debug().Msg("Welcome comment already posted")
+			return nil
+		}
+	}
+
+	data := WelcomeData{Author: author}
+	for _, trigger := range arianeConfig.TriggersForFiles(ctx, files) {
+		data.Triggers = append(data.Triggers, WelcomeTrigger{
+			Command:   arianeConfig.TriggerExample(trigger),
+			Workflows: arianeConfig.Triggers[trigger].Workflows,
+		})
+	}
+	if len(data.Triggers) == 0 {
+		logger.Debug().Msg("No trigger relevant to the changed files, not posting welcome comment")
+		return nil
+	}
+
+	body, err := renderWelcome(arianeConfig, data)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to render welcome comment template")
+		return err
+	}
+
+	if _, _, err := client.Issues.CreateComment(ctx, owner, repo, prNumber, &github.IssueComment{Body: github.String(body)}); err != nil {
+		logger.Error().Err(err).Msg("Failed to post welcome comment")
+		return err
+	}
+	return nil
+}
+
+func renderWelcome(arianeConfig *config.ArianeConfig, data WelcomeData) (string, error) {
+	body, err := renderTemplate(arianeConfig, "welcome", arianeConfig.Welcome.Template, defaultWelcomeTemplate, data)
+	if err != nil {
+		return "", err
+	}
+	return welcomeMarker + "\n" + body, nil
+}
@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	github "github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/fakegithub"
+)
+
+func TestPullRequestHandle(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+
+	configGetArianeConfigFromRepository = func(client *github.Client, ctx context.Context, owner, repoName, ref string) (*config.ArianeConfig, error) {
+		return &config.ArianeConfig{
+			Triggers: map[string]config.TriggerConfig{
+				"/test(-.*)?":          {Workflows: []string{"foo.yaml"}},
+				`/release-test (v\S+)`: {Workflows: []string{"foo.yaml"}, Example: "/release-test v1.16.0"},
+				"/docs":                {Workflows: []string{"docs.yaml"}},
+			},
+			Workflows: map[string]config.WorkflowPathsRegexConfig{
+				"foo.yaml":  {PathsRegex: "pkg/", Name: "Foo tests"},
+				"docs.yaml": {PathsRegex: "Documentation/"},
+			},
+			Welcome: config.WelcomeConfig{Enabled: true},
+		}, nil
+	}
+
+	fake := fakegithub.New()
+	defer fake.Close()
+	repo := fake.AddRepo("owner", "repo")
+	repo.AddPullRequest(fakegithub.PullRequest{Number: 1, HeadRef: "pr/owner/mybugfix", HeadSHA: "mock-sha", Files: []string{"pkg/foo.go"}})
+	repo.AddPullRequest(fakegithub.PullRequest{Number: 2, HeadRef: "pr/owner/typo", HeadSHA: "typo-sha", Files: []string{"README.md"}})
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(fake.Client(), nil).AnyTimes()
+
+	handler := &PullRequestHandler{
+		ClientCreator: mockClientCreator,
+	}
+
+	payload := func(number int, SHA string) []byte {
+		return []byte(fmt.Sprintf(`{
+			"action": "opened",
+			"repository": {
+				"owner": {
+					"login": "owner"
+				},
+				"name": "repo"
+			},
+			"pull_request": {
+				"number": %d,
+				"user": {
+					"login": "newcontributor"
+				},
+				"head": {
+					"ref": "pr/owner/mybugfix",
+					"sha": %q,
+					"repo": {
+						"owner": {
+							"login": "owner"
+						},
+						"name": "repo"
+					}
+				},
+				"base": {
+					"ref": "main"
+				}
+			}
+		}`, number, SHA))
+	}
+
+	assert.NoError(t, handler.Handle(context.Background(), "pull_request", "deliveryID", payload(1, "mock-sha")))
+	comments := repo.Comments(1)
+	if assert.Len(t, comments, 1) {
+		assert.Equal(t, welcomeMarker+"\n"+`Thanks for your contribution @newcontributor! :wave:
+
+Based on the files changed in this pull request, the following commands can be commented to run CI workflows:
+
+- `+"`/release-test v1.16.0`"+`: Foo tests
+- `+"`/test`"+`: Foo tests
+`, comments[0].GetBody(), "the triggers are listed with example comments rather than their regex")
+	}
+
+	assert.NoError(t, handler.Handle(context.Background(), "pull_request", "deliveryID", payload(1, "mock-sha")))
+	assert.Len(t, repo.Comments(1), 1, "the welcome comment is only posted once")
+
+	assert.NoError(t, handler.Handle(context.Background(), "pull_request", "deliveryID", payload(2, "typo-sha")))
+	assert.Empty(t, repo.Comments(2), "no welcome comment is posted if no trigger is relevant to the changed files")
+}
+
+func Test_renderWelcome(t *testing.T) {
+	data := WelcomeData{
+		Author: "newcontributor",
+		Triggers: []WelcomeTrigger{
+			{Command: "/test", Workflows: []string{"foo.yaml", "bar.yaml"}},
+		},
+	}
+
+	arianeConfig := &config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"foo.yaml": {Name: "Foo tests"},
+		},
+	}
+
+	body, err := renderWelcome(arianeConfig, data)
+	assert.NoError(t, err)
+	assert.Contains(t, body, welcomeMarker)
+	assert.Contains(t, body, "@newcontributor")
+	assert.Contains(t, body, "- `/test`: Foo tests, bar.yaml")
+
+	arianeConfig.Welcome.Template = "Hi {{ .Author }}, try {{ range .Triggers }}{{ .Command }}{{ end }}"
+	body, err = renderWelcome(arianeConfig, data)
+	assert.NoError(t, err)
+	assert.Equal(t, welcomeMarker+"\nHi newcontributor, try /test", body)
+
+	arianeConfig.Welcome.Template = "{{ .Unknown"
+	_, err = renderWelcome(arianeConfig, data)
+	assert.Error(t, err)
+}
+
+func Test_carryOverSkippedChecks(t *testing.T) {
+	var created []github.CreateCheckRunOptions
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/commits/old-sha/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		checkRuns := &github.ListCheckRunsResults{
+			Total: github.Int(3),
+			CheckRuns: []*github.CheckRun{
+				{Name: github.String("Foo"), Conclusion: github.String("skipped"), ExternalID: github.String("skipped/foo.yaml")},
+				{Name: github.String("Bar"), Conclusion: github.String("skipped"), ExternalID: github.String("skipped/bar.yaml")},
+				{Name: github.String("Baz"), Conclusion: github.String("success"), ExternalID: github.String("run/1")},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(checkRuns)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.Workflow{Name: github.String(r.PathValue("workflow"))})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.CreateCheckRunOptions
+		_ = json.NewDecoder(r.Body).Decode(&opts)
+		created = append(created, opts)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&github.CheckRun{ID: github.Int64(1)})
+	})
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	arianeConfig := &config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"foo.yaml": {PathsRegex: "src/"},
+			"bar.yaml": {PathsRegex: "docs/"},
+		},
+		CarryOverSkipped: true,
+	}
+	files := []*github.CommitFile{{Filename: github.String("docs/README.md")}}
+
+	handler := &PullRequestHandler{}
+	err := handler.carryOverSkippedChecks(context.Background(), client, arianeConfig, "owner", "repo", "old-sha", "new-sha", files, zerolog.Nop())
+	assert.NoError(t, err)
+	// bar.yaml now runs for the docs changes, only foo.yaml is still skipped
+	assert.Len(t, created, 1)
+	assert.Equal(t, "foo.yaml", created[0].Name)
+	assert.Equal(t, "new-sha", created[0].HeadSHA)
+	assert.Equal(t, "skipped", created[0].GetConclusion())
+	assert.Equal(t, "skipped/foo.yaml", created[0].GetExternalID())
+}
+
+func Test_checkNamespaceOf(t *testing.T) {
+	assert.Equal(t, "", checkNamespaceOf("E2E", "E2E"))
+	assert.Equal(t, "Ariane / e2e", checkNamespaceOf(checkName("Ariane / e2e", "E2E"), "E2E"))
+	assert.Equal(t, "", checkNamespaceOf("Other", "E2E"))
+}
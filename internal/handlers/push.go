@@ -0,0 +1,142 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/log"
+)
+
+// configCheckName is the check run reporting whether the default branch config is valid
+const configCheckName = runLinkCheckPrefix + "config"
+
+// PushHandler keeps the config cache up to date, and validates the config when it changes on the default branch
+type PushHandler struct {
+	githubapp.ClientCreator
+	// ConfigCache caches the Ariane configs fetched from repositories, if enabled
+	ConfigCache *config.Cache
+	// Workflows caches the workflows checked when validating the config, if enabled
+	Workflows *WorkflowCache
+}
+
+func (h *PushHandler) Handles() []string {
+	return []string{"push"}
+}
+
+func (h *PushHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	var event github.PushEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("failed to parse push event payload: %w", err)
+	}
+
+	branch, isBranch := strings.CutPrefix(event.GetRef(), "refs/heads/")
+	if !isBranch || event.GetDeleted() || !touchesConfig(event.Commits) {
+		return nil
+	}
+
+	installationID := githubapp.GetInstallationIDFromEvent(&event)
+	repository := event.GetRepo()
+	repositoryOwner := repository.GetOwner().GetLogin()
+	repositoryName := repository.GetName()
+	ctx, logger := githubapp.PrepareRepoContext(ctx, installationID, toRepository(repository))
+	ctx = log.WithLogger(ctx, &logger)
+
+	// only the default branch config is refreshed, other branches fetch it again on their next event
+	h.ConfigCache.Invalidate(repositoryOwner, repositoryName, branch)
+	if branch != repository.GetDefaultBranch() {
+		logger.Debug().Msgf("Config changed on branch %s, dropped from cache", branch)
+		return nil
+	}
+
+	client, err := h.NewInstallationClient(installationID)
+	if err != nil {
+		return err
+	}
+
+	SHA := event.GetAfter()
+	arianeConfig, err := configGetArianeConfigFromRepository(client, ctx, repositoryOwner, repositoryName, SHA)
+	if err == nil {
+		err = arianeConfig.Validate()
+	}
+	if err != nil {
+		logger.Error().Err(err).Msgf("Config on default branch %s is invalid", branch)
+		audit.Event(ctx, "config_invalid").Str("branch", branch).Str("sha", SHA).Err(err).Send()
+		return h.reportConfig(ctx, client, repositoryOwner, repositoryName, SHA, err, nil, logger)
+	}
+
+	h.ConfigCache.Set(repositoryOwner, repositoryName, branch, arianeConfig)
+	logger.Info().Msgf("Config on default branch %s refreshed", branch)
+
+	// workflows which cannot be dispatched don't invalidate the config, but would only fail once triggered
+	warnings := errors.Join(preflightWorkflows(ctx, h.Workflows, client, arianeConfig, repositoryOwner, repositoryName, SHA)...)
+	if warnings != nil {
+		logger.Warn().Err(warnings).Msgf("Config on default branch %s triggers workflows which cannot be dispatched", branch)
+	}
+	return h.reportConfig(ctx, client, repositoryOwner, repositoryName, SHA, nil, warnings, logger)
+}
+
+// touchesConfig checks whether any of the pushed commits changed the Ariane config file
+func touchesConfig(commits []*github.HeadCommit) bool {
+	for _, commit := range commits {
+		for _, files := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, file := range files {
+				if file == config.ArianeConfigPath {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// reportConfig creates a check run on the pushed commit, failing if the config is invalid, and neutral if it is
+// valid with warnings
+func (h *PushHandler) reportConfig(ctx context.Context, client *github.Client, owner, repo, SHA string, configErr, warnings error, logger zerolog.Logger) error {
+	conclusion := "success"
+	title := "Ariane config is valid"
+	summary := fmt.Sprintf("`%s` was validated successfully.", config.ArianeConfigPath)
+	if warnings != nil {
+		conclusion = "neutral"
+		title = "Ariane config is valid, with warnings"
+		summary = fmt.Sprintf("`%s` is valid, but some triggers will fail:\n\n```\n%s\n```", config.ArianeConfigPath, warnings)
+	}
+	if configErr != nil {
+		conclusion = "failure"
+		title = "Ariane config is invalid"
+		summary = fmt.Sprintf("`%s` is invalid, comment triggers may not work as expected:\n\n```\n%s\n```", config.ArianeConfigPath, configErr)
+	}
+
+	_, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       configCheckName,
+		HeadSHA:    SHA,
+		Status:     github.String("completed"),
+		Conclusion: github.String(conclusion),
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to create config check run")
+	}
+	return err
+}
+
+// toRepository converts the repository of a push event, which has a dedicated type
+func toRepository(repository *github.PushEventRepository) *github.Repository {
+	return &github.Repository{
+		ID:       repository.ID,
+		Name:     repository.Name,
+		FullName: repository.FullName,
+		Owner:    &github.User{Login: repository.GetOwner().Login},
+	}
+}
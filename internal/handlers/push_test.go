@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	github "github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func pushPayload(ref, file string) []byte {
+	return []byte(fmt.Sprintf(`{
+		"ref": %q,
+		"after": "mock-sha",
+		"repository": {
+			"owner": {
+				"login": "owner"
+			},
+			"name": "repo",
+			"default_branch": "main"
+		},
+		"commits": [
+			{
+				"modified": [%q]
+			}
+		]
+	}`, ref, file))
+}
+
+func TestPushHandle(t *testing.T) {
+	oldconfigGetArianeConfigFromRepository := configGetArianeConfigFromRepository
+	defer func() { configGetArianeConfigFromRepository = oldconfigGetArianeConfigFromRepository }()
+
+	configGetArianeConfigFromRepository = mockGetArianeConfigFromRepository
+
+	mockServer := setMockServer()
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(client, nil).Times(1)
+
+	stale := &config.ArianeConfig{}
+	handler := &PushHandler{
+		ClientCreator: mockClientCreator,
+		ConfigCache:   config.NewCache(time.Minute),
+	}
+	handler.ConfigCache.Set("owner", "repo", "main", stale)
+	handler.ConfigCache.Set("owner", "repo", "feature", stale)
+
+	// files other than the config are ignored
+	err := handler.Handle(context.Background(), "push", "deliveryID", pushPayload("refs/heads/main", "README.md"))
+	assert.NoError(t, err)
+	cached, _ := handler.ConfigCache.Get("owner", "repo", "main")
+	assert.Same(t, stale, cached)
+
+	// other branches are only dropped from the cache
+	err = handler.Handle(context.Background(), "push", "deliveryID", pushPayload("refs/heads/feature", config.ArianeConfigPath))
+	assert.NoError(t, err)
+	_, found := handler.ConfigCache.Get("owner", "repo", "feature")
+	assert.False(t, found)
+
+	// the default branch config is refreshed and validated
+	err = handler.Handle(context.Background(), "push", "deliveryID", pushPayload("refs/heads/main", config.ArianeConfigPath))
+	assert.NoError(t, err)
+	cached, found = handler.ConfigCache.Get("owner", "repo", "main")
+	assert.True(t, found)
+	assert.NotSame(t, stale, cached)
+	assert.NotEmpty(t, cached.Triggers)
+}
@@ -0,0 +1,209 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/state"
+)
+
+const (
+	// DefaultQueueSize is how many events wait for a worker of the event queue before new ones are refused
+	DefaultQueueSize = 1000
+	// DefaultPersistedEventExpiry is how long persisted events are replayed for, older ones being stale
+	DefaultPersistedEventExpiry = 24 * time.Hour
+
+	queueStatePrefix = "queue/"
+)
+
+// persistedEvents are the event types whose queued events are persisted: the trigger comments and the merge groups,
+// which users would have to trigger again if lost
+var persistedEvents = map[string]bool{"issue_comment": true, "merge_group": true}
+
+// persistedEvent is a queued event kept in the state store until it is handled
+type persistedEvent struct {
+	EventType  string    `json:"eventType"`
+	DeliveryID string    `json:"deliveryID"`
+	Payload    []byte    `json:"payload"`
+	QueuedAt   time.Time `json:"queuedAt"`
+}
+
+// Queue is a githubapp.Scheduler acknowledging webhooks right away, and handling their events in the background with
+// a bounded number of workers, so bursts of events do not hold webhook deliveries until GitHub times them out.
+// The events are handled through the next scheduler, e.g. the one retrying them and recording dead letters. Events
+// received while the queue is full are refused with githubapp.ErrCapacityExceeded, for GitHub to redeliver them.
+type Queue struct {
+	// Load counts the queued events in the queue depth, if set
+	Load *load.Tracker
+	// State keeps the queued trigger comment and merge group events until they are handled, if set, so the ones
+	// still queued or being handled when Ariane stops are handled again on startup by Replay
+	State state.Store
+
+	next   githubapp.Scheduler
+	events chan queuedEvent
+	wg     sync.WaitGroup
+
+	// mu guards closed, so no event is queued once the queue is closed
+	mu     sync.RWMutex
+	closed bool
+}
+
+type queuedEvent struct {
+	ctx      context.Context
+	dispatch githubapp.Dispatch
+	// dequeued is called once a worker starts handling the event
+	dequeued func()
+	// persisted is set for the events kept in the state store until handled
+	persisted bool
+}
+
+// NewQueue starts the workers of a queue holding up to size events, DefaultQueueSize if not positive
+func NewQueue(next githubapp.Scheduler, workers, size int) *Queue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	q := &Queue{next: next, events: make(chan queuedEvent, size)}
+	for range workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+func (q *Queue) Schedule(ctx context.Context, d githubapp.Dispatch) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return githubapp.ErrCapacityExceeded
+	}
+	// the event outlives the delivery request, keep its logger and values only
+	event := queuedEvent{ctx: context.WithoutCancel(ctx), dispatch: d, dequeued: q.Load.Queue()}
+	if q.State != nil && persistedEvents[d.EventType] {
+		// the event is persisted before it is acknowledged
+		event.persisted = true
+		setState(ctx, q.State, queueStatePrefix+d.DeliveryID, persistedEvent{
+			EventType:  d.EventType,
+			DeliveryID: d.DeliveryID,
+			Payload:    d.Payload,
+			QueuedAt:   time.Now(),
+		}, DefaultPersistedEventExpiry)
+	}
+	select {
+	case q.events <- event:
+		return nil
+	default:
+		event.dequeued()
+		if event.persisted {
+			// GitHub is answered with a retriable status, the event is not queued
+			deleteState(ctx, q.State, queueStatePrefix+d.DeliveryID)
+		}
+		zerolog.Ctx(ctx).Warn().Msgf("Event queue is full with %d events, refusing event", cap(q.events))
+		return githubapp.ErrCapacityExceeded
+	}
+}
+
+// Replay queues the events persisted by a previous run which were not handled, oldest first, with the handlers of
+// their event type, until all are queued, the queue is closed or ctx is done. It returns how many were queued.
+func (q *Queue) Replay(ctx context.Context, handlers ...githubapp.EventHandler) int {
+	if q.State == nil {
+		return 0
+	}
+	byEventType := map[string]githubapp.EventHandler{}
+	for _, handler := range handlers {
+		for _, eventType := range handler.Handles() {
+			byEventType[eventType] = handler
+		}
+	}
+	events := slices.SortedFunc(maps.Values(listState[persistedEvent](ctx, q.State, queueStatePrefix)), func(a, b persistedEvent) int {
+		return a.QueuedAt.Compare(b.QueuedAt)
+	})
+
+	replayed := 0
+	for _, e := range events {
+		logger := zerolog.Ctx(ctx).With().
+			Str(githubapp.LogKeyEventType, e.EventType).
+			Str(githubapp.LogKeyDeliveryID, e.DeliveryID).
+			Logger()
+		handler, ok := byEventType[e.EventType]
+		if !ok {
+			logger.Warn().Msg("No handler registered for persisted event, dropping it")
+			deleteState(ctx, q.State, queueStatePrefix+e.DeliveryID)
+			continue
+		}
+		event := queuedEvent{
+			ctx:       logger.WithContext(context.WithoutCancel(ctx)),
+			dispatch:  githubapp.Dispatch{Handler: handler, EventType: e.EventType, DeliveryID: e.DeliveryID, Payload: e.Payload},
+			dequeued:  q.Load.Queue(),
+			persisted: true,
+		}
+		if !q.enqueue(ctx, event) {
+			event.dequeued()
+			break
+		}
+		logger.Info().Msgf("Replaying event queued at %s", e.QueuedAt.Format(time.RFC3339))
+		replayed++
+	}
+	return replayed
+}
+
+// enqueue waits for the queue to have room for event, and reports whether it was queued before the queue was closed
+// or ctx was done
+func (q *Queue) enqueue(ctx context.Context, event queuedEvent) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+	for event := range q.events {
+		event.dequeued()
+		// the next scheduler reports the failures, retrying the events or recording them as dead letters
+		if err := q.next.Schedule(event.ctx, event.dispatch); err != nil {
+			zerolog.Ctx(event.ctx).Debug().Err(err).Msg("Queued event failed")
+		}
+		if event.persisted {
+			deleteState(event.ctx, q.State, queueStatePrefix+event.dispatch.DeliveryID)
+		}
+	}
+}
+
+// Close stops queuing events, and waits for the queued ones to be handled, or for ctx to be done
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/state"
+)
+
+// blockingHandler handles events once released, reporting the deliveries it handled
+type blockingHandler struct {
+	release chan struct{}
+	handled chan string
+}
+
+func (h *blockingHandler) Handles() []string {
+	return []string{"issue_comment"}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	<-h.release
+	h.handled <- deliveryID
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	handler := &blockingHandler{release: make(chan struct{}), handled: make(chan string, 3)}
+	queue := NewQueue(githubapp.DefaultScheduler(), 1, 1)
+	queue.Load = load.NewTracker(load.Thresholds{}, zerolog.Nop())
+	dispatch := func(deliveryID string) githubapp.Dispatch {
+		return githubapp.Dispatch{Handler: handler, EventType: "issue_comment", DeliveryID: deliveryID}
+	}
+
+	// the first event occupies the worker, the second waits for it, and the third is refused
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, queue.Schedule(ctx, dispatch("first")))
+	assert.Eventually(t, func() bool { return queue.Load.Sample().QueueDepth == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, queue.Schedule(ctx, dispatch("second")))
+	assert.ErrorIs(t, queue.Schedule(ctx, dispatch("third")), githubapp.ErrCapacityExceeded)
+	assert.Equal(t, 1, queue.Load.Sample().QueueDepth)
+	// the events outlive the delivery requests
+	cancel()
+
+	close(handler.release)
+	assert.NoError(t, queue.Close(context.Background()))
+	assert.Equal(t, "first", <-handler.handled)
+	assert.Equal(t, "second", <-handler.handled)
+	assert.Empty(t, handler.handled)
+	assert.Equal(t, 0, queue.Load.Sample().QueueDepth)
+
+	assert.ErrorIs(t, queue.Schedule(context.Background(), dispatch("closed")), githubapp.ErrCapacityExceeded)
+}
+
+func TestQueuePersist(t *testing.T) {
+	ctx := context.Background()
+	store := state.NewMemory()
+	handler := &blockingHandler{release: make(chan struct{}), handled: make(chan string, 2)}
+	defer close(handler.release)
+	queue := NewQueue(githubapp.DefaultScheduler(), 1, 2)
+	queue.State = store
+
+	// only the trigger comments and merge groups are persisted
+	assert.NoError(t, queue.Schedule(ctx, githubapp.Dispatch{Handler: handler, EventType: "issue_comment", DeliveryID: "first", Payload: []byte(`{}`)}))
+	assert.NoError(t, queue.Schedule(ctx, githubapp.Dispatch{Handler: handler, EventType: "push", DeliveryID: "second", Payload: []byte(`{}`)}))
+	persisted, err := store.List(ctx, queueStatePrefix)
+	assert.NoError(t, err)
+	assert.Len(t, persisted, 1)
+
+	// Ariane is killed while handling the first event, which the next run replays, dropping the events without handler
+	setState(ctx, store, queueStatePrefix+"orphan", persistedEvent{EventType: "merge_group", DeliveryID: "orphan"}, 0)
+	replayHandler := &blockingHandler{release: make(chan struct{}), handled: make(chan string, 1)}
+	close(replayHandler.release)
+	replayed := NewQueue(githubapp.DefaultScheduler(), 1, 0)
+	replayed.State = store
+	assert.Equal(t, 1, replayed.Replay(ctx, replayHandler))
+	assert.NoError(t, replayed.Close(ctx))
+	assert.Equal(t, "first", <-replayHandler.handled)
+	persisted, err = store.List(ctx, queueStatePrefix)
+	assert.NoError(t, err)
+	assert.Empty(t, persisted)
+
+	var notPersisted Queue
+	assert.Zero(t, notPersisted.Replay(ctx, replayHandler))
+}
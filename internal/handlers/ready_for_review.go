@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// dispatchReadyForReview dispatches the workflows of the ready-for-review trigger of a pull request marked ready for
+// review, as if the user who marked it commented the trigger phrase. Triggers which need args, a tag or a second
+// approval are not dispatched.
+func (h *PRCommentHandler) dispatchReadyForReview(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, pr *github.PullRequest, sender, contextRef string, logger zerolog.Logger) error {
+	phrase := arianeConfig.ReadyForReview
+	prNumber := pr.GetNumber()
+
+	if membership := recordDecision(logger, stepMembership, h.isAllowedTeamMember(ctx, client, arianeConfig, owner, sender, logger)); !membership.Result {
+		audit.Event(ctx, "trigger_rejected").Str("author", sender).Str("trigger", phrase).Object("decision", membership).Send()
+		return nil
+	}
+	submatch, workflows, trigger := arianeConfig.CheckForTrigger(ctx, phrase)
+	if !recordDecision(logger, stepTrigger, trigger).Result {
+		logger.Warn().Msgf("ready-for-review %q does not match any trigger", phrase)
+		return nil
+	}
+	if paused := recordDecision(logger, stepPause, h.checkPaused(ctx, owner, repo, prNumber)); !paused.Result {
+		return nil
+	}
+	args, argsDecision := arianeConfig.ParseArgs(ctx, phrase, "")
+	if !recordDecision(logger, stepArgs, argsDecision).Result {
+		logger.Warn().Msgf("ready-for-review %q cannot be dispatched without args: %s", phrase, argsDecision.Message)
+		return nil
+	}
+	triggerConfig, _ := arianeConfig.MatchedTrigger(phrase)
+	if triggerConfig.Tag || triggerConfig.RequiresSecondApproval {
+		logger.Warn().Msgf("ready-for-review %q is a tag trigger or requires a second approval, not dispatching it", phrase)
+		return nil
+	}
+	if arianeConfig.Mergeability.Enabled {
+		if mergeable := recordDecision(logger, stepMergeability, h.checkMergeability(ctx, client, owner, repo, prNumber, arianeConfig.Mergeability, logger)); !mergeable.Result {
+			return h.rejectUnmergeable(ctx, client, arianeConfig, owner, repo, prNumber, sender, mergeable, logger)
+		}
+	}
+
+	SHA := pr.GetHead().GetSHA()
+	event := h.createWorkflowDispatchEvent(prNumber, contextRef, SHA, submatch, args)
+	var marker string
+	if deliveryID := deliveryIDFromContext(ctx); arianeConfig.RunMarker && deliveryID != "" {
+		marker = runMarker(deliveryID)
+		event.Inputs[runMarkerInput] = marker
+	}
+	if arianeConfig.Provenance {
+		event.Inputs[provenanceInput] = newProvenance(arianeConfig, h.Version).input()
+	}
+	if arianeConfig.Analytics {
+		event.Inputs[analyticsInput] = newAnalytics(owner, repo, prNumber, false, submatch, sender).input()
+	}
+	if inputs := recordDecision(logger, stepInputs, validateDispatchInputs(event.Inputs)); !inputs.Result {
+		return h.rejectInputs(ctx, client, arianeConfig, owner, repo, prNumber, sender, inputs, logger)
+	}
+	var workflowInputs map[string]map[string]interface{}
+	if arianeConfig.UndeclaredInputs != "" {
+		var declared decision.Decision
+		workflowInputs, declared = declaredInputs(ctx, h.Workflows, client, arianeConfig, owner, repo, contextRef, workflows, event.Inputs, logger)
+		if declared := recordDecision(logger, stepInputs, declared); !declared.Result {
+			return h.rejectInputs(ctx, client, arianeConfig, owner, repo, prNumber, sender, declared, logger)
+		}
+	}
+
+	audit.Event(ctx, "ready_for_review_triggered").Str("author", sender).Str("trigger", phrase).Send()
+	return h.dispatchWorkflows(ctx, triggerDispatch{
+		client:         client,
+		arianeConfig:   arianeConfig,
+		settings:       h.settings(owner, repo),
+		owner:          owner,
+		repo:           repo,
+		prNumber:       prNumber,
+		commentAuthor:  sender,
+		commentURL:     pr.GetHTMLURL(),
+		contextRef:     contextRef,
+		SHA:            SHA,
+		baseSHA:        pr.GetBase().GetSHA(),
+		baseRef:        pr.GetBase().GetRef(),
+		labels:         labelNames(pr.Labels),
+		workflows:      workflows,
+		event:          event,
+		workflowInputs: workflowInputs,
+		marker:         marker,
+		submatch:       submatch,
+		args:           args,
+		checkNamespace: triggerConfig.CheckNamespace,
+		logger:         logger,
+	}, nil)
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_dispatchReadyForReview(t *testing.T) {
+	var dispatched []string
+	var inputs []map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/pulls/1/files", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.CommitFile{{Filename: github.Ptr("src/main.go")}})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}/runs", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.WorkflowRuns{TotalCount: github.Ptr(0)})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/{workflow}/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		var event github.CreateWorkflowDispatchEventRequest
+		_ = json.NewDecoder(r.Body).Decode(&event)
+		dispatched = append(dispatched, r.PathValue("workflow"))
+		inputs = append(inputs, event.Inputs)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.Workflow{Name: github.Ptr(r.PathValue("workflow"))})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&github.CheckRun{})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/comments/{id}/reactions", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("there is no trigger comment to react to")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	arianeConfig := &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{
+			"/test":   {Workflows: []string{"src.yaml", "docs.yaml"}},
+			"/deploy": {Workflows: []string{"deploy.yaml"}, RequiresSecondApproval: true},
+		},
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"src.yaml":  {PathsRegex: "src/"},
+			"docs.yaml": {PathsRegex: "docs/"},
+		},
+		ReadyForReview: "/test",
+	}
+	pr := &github.PullRequest{
+		Number: github.Ptr(1),
+		Head:   &github.PullRequestBranch{SHA: github.Ptr("mock-sha")},
+		Base:   &github.PullRequestBranch{SHA: github.Ptr("base-sha")},
+	}
+
+	assert.NoError(t, handler.dispatchReadyForReview(context.Background(), client, arianeConfig, "owner", "repo", pr, "maintainer", "main", zerolog.Nop()))
+	assert.Equal(t, []string{"src.yaml"}, dispatched, "the paths filters apply as for trigger comments")
+	assert.Equal(t, "mock-sha", inputs[0]["SHA"])
+
+	arianeConfig.ReadyForReview = "/deploy"
+	assert.NoError(t, handler.dispatchReadyForReview(context.Background(), client, arianeConfig, "owner", "repo", pr, "maintainer", "main", zerolog.Nop()))
+	assert.Len(t, dispatched, 1, "triggers requiring a second approval are not dispatched")
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/state"
+)
+
+const (
+	DefaultRetryExpiry = 7 * 24 * time.Hour
+)
+
+const defaultRetryLimitedMessage = `@{{ .Author }} some workflows were not run again, as they were retried too often:
+{{ range .Skipped }}
+- {{ name .Workflow }}: {{ .Reason.Message }}{{ end }}
+`
+
+// RetryStore records when workflows were run for each SHA, dispatched or re-run, keyed by workflow and SHA.
+// A nil RetryStore is valid and records nothing.
+type RetryStore struct {
+	// mu serializes the runs recorded by this replica, the runs recorded concurrently by replicas sharing the state
+	// store may be lost
+	mu     sync.Mutex
+	state  state.Store
+	expiry time.Duration
+}
+
+// NewRetryStore creates a retry store kept in store, in memory if nil
+func NewRetryStore(store state.Store, expiry time.Duration) *RetryStore {
+	if expiry <= 0 {
+		expiry = DefaultRetryExpiry
+	}
+	return &RetryStore{state: orMemory(store), expiry: expiry}
+}
+
+func retryStoreKey(owner, repo, workflow, SHA string) string {
+	return "retries/" + owner + "/" + repo + "/" + workflow + "@" + SHA
+}
+
+// record adds a run of a workflow for a SHA
+func (s *RetryStore) record(ctx context.Context, owner, repo, workflow, SHA string, at time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := retryStoreKey(owner, repo, workflow, SHA)
+	runs, _ := getState[[]time.Time](ctx, s.state, key)
+	setState(ctx, s.state, key, append(runs, at), s.expiry)
+}
+
+// runs returns the times a workflow was run for a SHA since the given time, oldest first
+func (s *RetryStore) runs(ctx context.Context, owner, repo, workflow, SHA string, since time.Time) []time.Time {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recorded, _ := getState[[]time.Time](ctx, s.state, retryStoreKey(owner, repo, workflow, SHA))
+	var runs []time.Time
+	for _, at := range recorded {
+		if at.After(since) {
+			runs = append(runs, at)
+		}
+	}
+	return runs
+}
+
+// checkRetries decides whether a workflow is skipped as it was retried too often for a SHA, the first run of a
+// workflow for a SHA not counting as a retry
+func (h *PRCommentHandler) checkRetries(ctx context.Context, owner, repo, workflow, SHA string, limit config.RetryLimitConfig) decision.Decision {
+	if limit.MaxRetries == 0 {
+		return decision.No(decision.ReasonRetryAllowed, "the retries of workflow %s are not limited", workflow)
+	}
+	window := limit.WindowOrDefault()
+	now := h.Scheduler.Now()
+	runs := h.Retries.runs(ctx, owner, repo, workflow, SHA, now.Add(-window))
+	if len(runs) <= limit.MaxRetries {
+		return decision.No(decision.ReasonRetryAllowed, "workflow %s was retried %d out of %d times for %s within %s", workflow, max(len(runs)-1, 0), limit.MaxRetries, SHA, window)
+	}
+	// the runs past the limit need to leave the window
+	cooldown := runs[len(runs)-limit.MaxRetries-1].Add(window).Sub(now)
+	return decision.Yes(decision.ReasonRetryLimited, "workflow %s was already retried %d times for %s within %s, and can be retried again in %s", workflow, len(runs)-1, SHA, window, cooldown.Round(time.Second))
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+func Test_checkRetries(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Now())
+	handler := &PRCommentHandler{Retries: NewRetryStore(nil, 0), Scheduler: scheduler.Scheduler{Clock: clock}}
+	limit := config.RetryLimitConfig{MaxRetries: 1, Window: time.Hour}
+
+	assert.False(t, handler.checkRetries(context.Background(), "owner", "repo", "foo.yaml", "mock-sha", config.RetryLimitConfig{}).Result, "retries are not limited by default")
+	assert.Equal(t, decision.ReasonRetryAllowed, handler.checkRetries(context.Background(), "owner", "repo", "foo.yaml", "mock-sha", limit).Reason)
+
+	handler.Retries.record(context.Background(), "owner", "repo", "foo.yaml", "mock-sha", clock.Now())
+	assert.False(t, handler.checkRetries(context.Background(), "owner", "repo", "foo.yaml", "mock-sha", limit).Result, "the first run is not a retry")
+	clock.Advance(10 * time.Minute)
+	handler.Retries.record(context.Background(), "owner", "repo", "foo.yaml", "mock-sha", clock.Now())
+	limited := handler.checkRetries(context.Background(), "owner", "repo", "foo.yaml", "mock-sha", limit)
+	assert.True(t, limited.Result)
+	assert.Equal(t, decision.ReasonRetryLimited, limited.Reason)
+	assert.Equal(t, "workflow foo.yaml was already retried 1 times for mock-sha within 1h0m0s, and can be retried again in 50m0s", limited.Message)
+	assert.False(t, handler.checkRetries(context.Background(), "owner", "repo", "foo.yaml", "other-sha", limit).Result, "retries are counted by SHA")
+	assert.False(t, handler.checkRetries(context.Background(), "owner", "repo", "bar.yaml", "mock-sha", limit).Result, "retries are counted by workflow")
+
+	clock.Advance(50 * time.Minute)
+	assert.False(t, handler.checkRetries(context.Background(), "owner", "repo", "foo.yaml", "mock-sha", limit).Result, "runs leave the window")
+}
+
+func Test_dispatchWorkflowsRetryLimit(t *testing.T) {
+	var dispatched, comments []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/actions/workflows/{workflow}/dispatches", func(w http.ResponseWriter, r *http.Request) {
+		dispatched = append(dispatched, r.PathValue("workflow"))
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/comments/{id}/reactions", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(github.Reaction{})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comments = append(comments, comment.GetBody())
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{Retries: NewRetryStore(nil, 0)}
+	arianeConfig := &config.ArianeConfig{RetryLimit: config.RetryLimitConfig{MaxRetries: 1}}
+	dispatch := func(workflows ...string) {
+		t.Helper()
+		assert.NoError(t, handler.dispatchWorkflows(context.Background(), triggerDispatch{
+			client:        client,
+			arianeConfig:  arianeConfig,
+			owner:         "owner",
+			repo:          "repo",
+			prNumber:      1,
+			isIssue:       true,
+			commentID:     1,
+			commentAuthor: "contributor",
+			contextRef:    "main",
+			SHA:           "mock-sha",
+			workflows:     workflows,
+			event:         handler.createWorkflowDispatchEvent(1, "main", "mock-sha", []string{"/test"}, nil),
+			logger:        zerolog.Nop(),
+		}, nil))
+	}
+
+	dispatch("foo.yaml")
+	dispatch("foo.yaml")
+	assert.Empty(t, comments)
+	dispatch("foo.yaml", "bar.yaml")
+	assert.Equal(t, []string{"foo.yaml", "foo.yaml", "bar.yaml"}, dispatched, "the workflow retried too often is not dispatched again")
+	assert.Len(t, comments, 1)
+	assert.Contains(t, comments[0], "@contributor some workflows were not run again, as they were retried too often:\n\n- foo.yaml: workflow foo.yaml was already retried 1 times for mock-sha within 24h0m0s")
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-github/v75/github"
+)
+
+// PRReviewCommentHandler handles the trigger phrases of pull request review comments, left inline on the diff, as
+// PRCommentHandler handles the comments of the pull request conversation
+type PRReviewCommentHandler struct {
+	Comments *PRCommentHandler
+}
+
+func (h *PRReviewCommentHandler) Handles() []string {
+	return []string{"pull_request_review_comment"}
+}
+
+func (h *PRReviewCommentHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	var event github.PullRequestReviewCommentEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("failed to parse pull_request_review_comment event payload: %w", err)
+	}
+
+	return h.Comments.handleEvent(withReviewComment(withDeliveryID(ctx, deliveryID)), reviewCommentEvent(&event), false)
+}
+
+// reviewCommentEvent returns a review comment event as the issue comment event of its pull request, which is what
+// PRCommentHandler handles
+func reviewCommentEvent(event *github.PullRequestReviewCommentEvent) *github.IssueCommentEvent {
+	pr := event.GetPullRequest()
+	comment := event.GetComment()
+	return &github.IssueCommentEvent{
+		Action: event.Action,
+		Issue: &github.Issue{
+			Number:           pr.Number,
+			Title:            pr.Title,
+			State:            pr.State,
+			Locked:           pr.Locked,
+			User:             pr.User,
+			Labels:           pr.Labels,
+			HTMLURL:          pr.HTMLURL,
+			PullRequestLinks: &github.PullRequestLinks{URL: pr.URL, HTMLURL: pr.HTMLURL},
+		},
+		Comment: &github.IssueComment{
+			ID:                comment.ID,
+			Body:              comment.Body,
+			User:              comment.User,
+			HTMLURL:           comment.HTMLURL,
+			AuthorAssociation: comment.AuthorAssociation,
+			CreatedAt:         comment.CreatedAt,
+			UpdatedAt:         comment.UpdatedAt,
+		},
+		Repo:         event.Repo,
+		Sender:       event.Sender,
+		Installation: event.Installation,
+	}
+}
+
+type reviewCommentKey struct{}
+
+// withReviewComment records that the comment being handled is a review comment, whose reactions go through the pull
+// request comment endpoints rather than the issue comment ones
+func withReviewComment(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reviewCommentKey{}, true)
+}
+
+// isReviewComment reports whether the comment being handled is a review comment
+func isReviewComment(ctx context.Context) bool {
+	reviewComment, _ := ctx.Value(reviewCommentKey{}).(bool)
+	return reviewComment
+}
+
+// createCommentReaction reacts to the comment being handled, a review comment or an issue comment
+func createCommentReaction(ctx context.Context, client *github.Client, owner, repo string, commentID int64, reaction string) error {
+	if isReviewComment(ctx) {
+		_, _, err := client.Reactions.CreatePullRequestCommentReaction(ctx, owner, repo, commentID, reaction)
+		return err
+	}
+	_, _, err := client.Reactions.CreateIssueCommentReaction(ctx, owner, repo, commentID, reaction)
+	return err
+}
+
+// listCommentReactions lists the reactions to a held comment, a review comment or an issue comment
+func listCommentReactions(ctx context.Context, client *github.Client, owner, repo string, held heldComment, opts *github.ListReactionOptions) ([]*github.Reaction, error) {
+	if held.reviewComment {
+		reactions, _, err := client.Reactions.ListPullRequestCommentReactions(ctx, owner, repo, held.event.GetComment().GetID(), opts)
+		return reactions, err
+	}
+	reactions, _, err := client.Reactions.ListIssueCommentReactions(ctx, owner, repo, held.event.GetComment().GetID(), opts)
+	return reactions, err
+}
@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_reviewCommentEvent(t *testing.T) {
+	event := reviewCommentEvent(&github.PullRequestReviewCommentEvent{
+		Action: github.String("created"),
+		PullRequest: &github.PullRequest{
+			Number: github.Int(1),
+			URL:    github.String("https://api.github.com/repos/owner/repo/pulls/1"),
+			Labels: []*github.Label{{Name: github.String("area/ci")}},
+		},
+		Comment: &github.PullRequestComment{
+			ID:   github.Int64(42),
+			Body: github.String("/test"),
+			User: &github.User{Login: github.String("author")},
+		},
+		Repo: &github.Repository{Name: github.String("repo"), Owner: &github.User{Login: github.String("owner")}},
+	})
+	assert.Equal(t, "created", event.GetAction())
+	assert.True(t, event.GetIssue().IsPullRequest())
+	assert.Equal(t, 1, event.GetIssue().GetNumber())
+	assert.Equal(t, []string{"area/ci"}, labelNames(event.GetIssue().Labels))
+	assert.Equal(t, int64(42), event.GetComment().GetID())
+	assert.Equal(t, "/test", event.GetComment().GetBody())
+	assert.Equal(t, "author", event.GetComment().GetUser().GetLogin())
+	assert.Equal(t, "owner", event.GetRepo().GetOwner().GetLogin())
+}
+
+func Test_createCommentReaction(t *testing.T) {
+	var reacted []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /repos/owner/repo/issues/comments/42/reactions", func(w http.ResponseWriter, r *http.Request) {
+		reacted = append(reacted, "issue")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("POST /repos/owner/repo/pulls/comments/42/reactions", func(w http.ResponseWriter, r *http.Request) {
+		reacted = append(reacted, "review")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	assert.NoError(t, createCommentReaction(context.Background(), client, "owner", "repo", 42, "+1"))
+	assert.NoError(t, createCommentReaction(withReviewComment(context.Background()), client, "owner", "repo", 42, "+1"))
+	assert.Equal(t, []string{"issue", "review"}, reacted)
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/google/go-github/v75/github"
+)
+
+const defaultRunLinksMessage = `@{{ .Author }} the runs dispatched for [your comment]({{ .CommentURL }}):
+{{ range .Runs }}
+- {{ name .Workflow }}: [{{ .Name }} #{{ .RunNumber }}]({{ .URL }}){{ end }}
+`
+
+// RunLink links to a run dispatched for a trigger comment
+type RunLink struct {
+	Workflow  string
+	Name      string
+	RunNumber int
+	URL       string
+}
+
+// runLinks collects the runs found for the workflows dispatched by a trigger comment, to reply with links to all
+// of them once they were all looked up. A nil runLinks collects nothing.
+type runLinks struct {
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	runs []RunLink
+}
+
+// add counts a dispatched run being looked up, done must be called once the lookup is over
+func (r *runLinks) add() {
+	if r == nil {
+		return
+	}
+	r.wg.Add(1)
+}
+
+func (r *runLinks) done() {
+	if r == nil {
+		return
+	}
+	r.wg.Done()
+}
+
+// found records the run found for a dispatched workflow
+func (r *runLinks) found(workflow string, run *github.WorkflowRun) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, RunLink{Workflow: workflow, Name: run.GetName(), RunNumber: run.GetRunNumber(), URL: run.GetHTMLURL()})
+}
+
+// wait returns the runs found, sorted by workflow, once all the lookups are over
+func (r *runLinks) wait() []RunLink {
+	if r == nil {
+		return nil
+	}
+	r.wg.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sort.Slice(r.runs, func(i, j int) bool { return r.runs[i].Workflow < r.runs[j].Workflow })
+	return r.runs
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+var (
+	dispatchesAvoidedTotal = metrics.NewCounterVec("ariane_dispatches_avoided_total",
+		"Workflow dispatches avoided by paths filters and prior successes, by repository, workflow and reason.",
+		"repository", "workflow", "reason")
+	runnerMinutesAvoidedTotal = metrics.NewCounterVec("ariane_runner_minutes_avoided_total",
+		"Estimated runner minutes avoided by paths filters and prior successes, using the cost-minutes of workflows.",
+		"repository", "workflow", "reason")
+)
+
+// savingReasons are the reasons of skip decisions which avoid a run, rather than deferring to another one
+var savingReasons = map[decision.Reason]bool{
+	decision.ReasonPreviousRunSucceeded:   true,
+	decision.ReasonIdempotentRunSucceeded: true,
+	decision.ReasonNoChanges:              true,
+	decision.ReasonOnlyOtherWorkflows:     true,
+	decision.ReasonPathsNotMatched:        true,
+	decision.ReasonAllPathsIgnored:        true,
+	decision.ReasonStatusesNotMatched:     true,
+}
+
+// SavingsMetrics returns the metrics counting avoided dispatches, worth persisting across restarts
+func SavingsMetrics() []*metrics.Vec {
+	return []*metrics.Vec{dispatchesAvoidedTotal, runnerMinutesAvoidedTotal}
+}
+
+// recordSavings counts a skipped workflow as an avoided dispatch, if skipping it avoided a run
+func recordSavings(arianeConfig *config.ArianeConfig, repository, workflow string, d decision.Decision) {
+	if !savingReasons[d.Reason] {
+		return
+	}
+	dispatchesAvoidedTotal.Inc(repository, workflow, string(d.Reason))
+	if cost := arianeConfig.Workflows[workflow].CostMinutes; cost > 0 {
+		runnerMinutesAvoidedTotal.Add(cost, repository, workflow, string(d.Reason))
+	}
+}
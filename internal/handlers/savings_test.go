@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_recordSavings(t *testing.T) {
+	arianeConfig := &config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{"e2e.yaml": {CostMinutes: 30}},
+	}
+	repository := "owner/savings"
+
+	recordSavings(arianeConfig, repository, "e2e.yaml", decision.No(decision.ReasonPathsNotMatched, "no match"))
+	recordSavings(arianeConfig, repository, "e2e.yaml", decision.No(decision.ReasonPathsNotMatched, "no match"))
+	recordSavings(arianeConfig, repository, "lint.yaml", decision.Yes(decision.ReasonPreviousRunSucceeded, "succeeded"))
+	// runs in progress are not avoided, only deferred to
+	recordSavings(arianeConfig, repository, "e2e.yaml", decision.Yes(decision.ReasonPreviousRunPending, "in progress"))
+
+	assert.Equal(t, float64(2), dispatchesAvoidedTotal.Value(repository, "e2e.yaml", string(decision.ReasonPathsNotMatched)))
+	assert.Equal(t, float64(60), runnerMinutesAvoidedTotal.Value(repository, "e2e.yaml", string(decision.ReasonPathsNotMatched)))
+	assert.Equal(t, float64(1), dispatchesAvoidedTotal.Value(repository, "lint.yaml", string(decision.ReasonPreviousRunSucceeded)))
+	assert.Equal(t, float64(0), runnerMinutesAvoidedTotal.Value(repository, "lint.yaml", string(decision.ReasonPreviousRunSucceeded)))
+	assert.Equal(t, float64(0), dispatchesAvoidedTotal.Value(repository, "e2e.yaml", string(decision.ReasonPreviousRunPending)))
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/state"
+)
+
+// The stores of the handlers keep their entries in a state.Store, JSON encoded under a prefix of their own. Failures
+// of the state store are logged and the entries considered unset, so an unavailable backend degrades the features
+// relying on it, e.g. retries are not limited, instead of failing the handling of events.
+
+// orMemory returns store, or a memory store if nil
+func orMemory(store state.Store) state.Store {
+	if store == nil {
+		return state.NewMemory()
+	}
+	return store
+}
+
+// getState decodes the value of a key, and reports whether it is set
+func getState[T any](ctx context.Context, store state.Store, key string) (T, bool) {
+	var value T
+	data, ok, err := store.Get(ctx, key)
+	if err == nil && ok {
+		err = json.Unmarshal(data, &value)
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("Failed to get %s from the state store", key)
+		return value, false
+	}
+	return value, ok
+}
+
+// setState encodes the value of a key, expiring after ttl
+func setState(ctx context.Context, store state.Store, key string, value any, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err == nil {
+		err = store.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("Failed to set %s in the state store", key)
+	}
+}
+
+// deleteState unsets a key, and reports whether it was set
+func deleteState(ctx context.Context, store state.Store, key string) bool {
+	deleted, err := store.Delete(ctx, key)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("Failed to delete %s from the state store", key)
+	}
+	return deleted
+}
+
+// listState decodes the values of the keys starting with prefix, keyed by key
+func listState[T any](ctx context.Context, store state.Store, prefix string) map[string]T {
+	data, err := store.List(ctx, prefix)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("Failed to list %s from the state store", prefix)
+		return nil
+	}
+	values := make(map[string]T, len(data))
+	for key, v := range data {
+		var value T
+		if err := json.Unmarshal(v, &value); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msgf("Failed to decode %s from the state store", key)
+			continue
+		}
+		values[key] = value
+	}
+	return values
+}
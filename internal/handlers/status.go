@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+// statusMarker is a hidden marker identifying the status comment, edited with each new status rather than posting
+// new comments
+const statusMarker = "<!-- ariane-status -->"
+
+// statusNotRun is the status of the workflows without a run for the head of the pull request
+const statusNotRun = "not run"
+
+const defaultStatusMessage = `@{{ .Author }} status of the workflows run by Ariane for {{ .Head }}:
+{{ if .Statuses }}
+| Workflow | Status | Conclusion | Run |
+| -------- | ------ | ---------- | --- |
+{{ range .Statuses }}| {{ name .Workflow }} | {{ .Status }} | {{ or .Conclusion "-" }} | {{ if .URL }}[#{{ .RunNumber }}]({{ .URL }}){{ else }}-{{ end }} |
+{{ end }}{{ else }}
+No workflow is configured in the triggers.{{ end }}`
+
+// WorkflowStatus is the latest run dispatched for a workflow on the head of a pull request, for the status message
+type WorkflowStatus struct {
+	Workflow string
+	// Status is the status of the run, e.g. queued or completed, or "not run" if the workflow has no run
+	Status     string
+	Conclusion string
+	RunNumber  int
+	URL        string
+}
+
+// postStatus replies to the status command with the latest dispatched run of each workflow of the config triggers
+// for SHA, editing the status comment of the PR if any, so the PR keeps a single, up to date, status comment
+func (h *PRCommentHandler) postStatus(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo string, prNumber int, SHA, author string, logger zerolog.Logger) error {
+	statuses, err := h.workflowStatuses(ctx, client, arianeConfig, owner, repo, SHA)
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to retrieve the workflow runs for sha=%s", SHA)
+		return err
+	}
+	data := MessageData{Author: author, Head: SHA, Statuses: statuses}
+	body, err := renderTemplate(arianeConfig, "status", arianeConfig.Messages.Status, defaultStatusMessage, data)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to render status message template")
+		return err
+	}
+	comment := &github.IssueComment{Body: github.String(statusMarker + "\n" + body)}
+
+	previous, err := h.findMarkedComment(ctx, client, owner, repo, prNumber, statusMarker)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to list PR comments")
+		return err
+	}
+	if previous == nil {
+		if _, _, err := client.Issues.CreateComment(ctx, owner, repo, prNumber, comment); err != nil {
+			logger.Error().Err(err).Msg("Failed to post status message")
+			return err
+		}
+		return nil
+	}
+	if _, _, err := client.Issues.EditComment(ctx, owner, repo, previous.GetID(), comment); err != nil {
+		logger.Error().Err(err).Msg("Failed to edit status message")
+		return err
+	}
+	return nil
+}
+
+// workflowStatuses lists the runs dispatched for SHA, and returns the latest one of each workflow of the config
+// triggers, sorted by workflow
+func (h *PRCommentHandler) workflowStatuses(ctx context.Context, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo, SHA string) ([]WorkflowStatus, error) {
+	statuses := map[string]*WorkflowStatus{}
+	for _, trigger := range allTriggers(arianeConfig) {
+		for _, workflow := range trigger.Workflows {
+			statuses[workflow] = &WorkflowStatus{Workflow: workflow, Status: statusNotRun}
+		}
+	}
+
+	limit := h.settings(owner, repo).Pagination.WithDefaults().WorkflowRuns
+	opts := &github.ListWorkflowRunsOptions{Event: "workflow_dispatch", HeadSHA: SHA, ListOptions: github.ListOptions{PerPage: limit.PerPage}}
+	for page := 1; page <= limit.MaxPages; page++ {
+		runs, response, err := client.Actions.ListRepositoryWorkflowRuns(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		// most recent runs come first
+		for _, run := range runs.WorkflowRuns {
+			status, ok := statuses[strings.TrimPrefix(run.GetPath(), ".github/workflows/")]
+			if !ok || status.URL != "" {
+				continue
+			}
+			status.Status, status.Conclusion = run.GetStatus(), run.GetConclusion()
+			status.RunNumber, status.URL = run.GetRunNumber(), run.GetHTMLURL()
+		}
+		if response.NextPage == 0 {
+			break
+		}
+		opts.Page = response.NextPage
+	}
+
+	sorted := make([]WorkflowStatus, 0, len(statuses))
+	for _, status := range statuses {
+		sorted = append(sorted, *status)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Workflow < sorted[j].Workflow })
+	return sorted, nil
+}
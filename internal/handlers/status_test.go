@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_handleCommandStatus(t *testing.T) {
+	var comments []*github.IssueComment
+	var edited []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/actions/runs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "mock-sha", r.URL.Query().Get("head_sha"))
+		assert.Equal(t, "workflow_dispatch", r.URL.Query().Get("event"))
+		// most recent runs come first
+		_ = json.NewEncoder(w).Encode(github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{
+			{Path: github.Ptr(".github/workflows/foo.yaml"), Status: github.Ptr("in_progress"), RunNumber: github.Ptr(12), HTMLURL: github.Ptr("https://github.com/owner/repo/actions/runs/12")},
+			{Path: github.Ptr(".github/workflows/foo.yaml"), Status: github.Ptr("completed"), Conclusion: github.Ptr("failure"), RunNumber: github.Ptr(11), HTMLURL: github.Ptr("https://github.com/owner/repo/actions/runs/11")},
+			{Path: github.Ptr(".github/workflows/bar.yaml"), Status: github.Ptr("completed"), Conclusion: github.Ptr("success"), RunNumber: github.Ptr(10), HTMLURL: github.Ptr("https://github.com/owner/repo/actions/runs/10")},
+			{Path: github.Ptr(".github/workflows/other.yaml"), Status: github.Ptr("completed"), Conclusion: github.Ptr("success"), RunNumber: github.Ptr(9), HTMLURL: github.Ptr("https://github.com/owner/repo/actions/runs/9")},
+		}})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(comments)
+	})
+	mux.HandleFunc("POST /repos/owner/repo/issues/1/comments", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		comment.ID = github.Ptr(int64(len(comments) + 1))
+		comments = append(comments, &comment)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/issues/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var comment github.IssueComment
+		_ = json.NewDecoder(r.Body).Decode(&comment)
+		edited = append(edited, r.PathValue("id"))
+		_ = json.NewEncoder(w).Encode(&comment)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	arianeConfig := &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{
+			"/test":     {Workflows: []string{"foo.yaml", "bar.yaml"}},
+			"/test-e2e": {Workflows: []string{"e2e.yaml", "foo.yaml"}},
+		},
+		Workflows: map[string]config.WorkflowPathsRegexConfig{"foo.yaml": {Name: "Foo CI"}},
+	}
+
+	assert.NoError(t, handler.handleCommand(context.Background(), client, arianeConfig, "owner", "repo", 1, "mock-sha", "contributor", "status", zerolog.Nop()))
+	if assert.Len(t, comments, 1) {
+		assert.Equal(t, statusMarker+"\n"+`@contributor status of the workflows run by Ariane for mock-sha:
+
+| Workflow | Status | Conclusion | Run |
+| -------- | ------ | ---------- | --- |
+| bar.yaml | completed | success | [#10](https://github.com/owner/repo/actions/runs/10) |
+| e2e.yaml | not run | - | - |
+| Foo CI | in_progress | - | [#12](https://github.com/owner/repo/actions/runs/12) |
+`, comments[0].GetBody())
+	}
+
+	assert.NoError(t, handler.handleCommand(context.Background(), client, arianeConfig, "owner", "repo", 1, "mock-sha", "contributor", "status", zerolog.Nop()))
+	assert.Len(t, comments, 1, "the status comment is edited rather than posted again")
+	assert.Equal(t, []string{"1"}, edited)
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+)
+
+// statusDescriptionLimit is the longest description GitHub accepts on commit statuses
+const statusDescriptionLimit = 140
+
+// skippedStatusPrefix starts the description of the commit statuses of skipped workflows, whose target URL is the
+// workflow file, so they can be carried over to new PR heads
+const skippedStatusPrefix = "Skipped by Ariane: "
+
+// statusDescription truncates a description to the length GitHub accepts
+func statusDescription(description string) string {
+	runes := []rune(description)
+	if len(runes) <= statusDescriptionLimit {
+		return description
+	}
+	return string(runes[:statusDescriptionLimit-1]) + "…"
+}
+
+// statusState maps a check run conclusion to a commit status state, which has no neutral nor skipped state.
+// Neutral and skipped conclusions do not block merging, as their check runs would not.
+func statusState(conclusion string) string {
+	switch conclusion {
+	case "success", "neutral", "skipped":
+		return "success"
+	case "cancelled", "stale":
+		return "error"
+	default:
+		return "failure"
+	}
+}
+
+// runStatusState maps the status and conclusion of a workflow run to a commit status state
+func runStatusState(run *github.WorkflowRun) string {
+	if run.GetStatus() != "completed" {
+		return "pending"
+	}
+	return statusState(checkConclusion(run.GetConclusion()))
+}
+
+// createStatus creates a commit status on SHA. Statuses are not updated, the newest one of a context on a SHA is shown.
+func createStatus(ctx context.Context, client *github.Client, owner, repo, SHA, statusContext, state, description, targetURL string) error {
+	status := &github.RepoStatus{
+		State:       github.String(state),
+		Context:     github.String(statusContext),
+		Description: github.String(statusDescription(description)),
+	}
+	if targetURL != "" {
+		status.TargetURL = github.String(targetURL)
+	}
+	_, _, err := client.Repositories.CreateStatus(ctx, owner, repo, SHA, status)
+	return err
+}
+
+// createQueuedStatus creates a pending commit status named after the workflow, like createQueuedCheck for
+// repositories reporting commit statuses
+func (h *PRCommentHandler) createQueuedStatus(ctx context.Context, client *github.Client, owner, repo, workflow, displayName, SHA, checkNamespace string, logger zerolog.Logger) (trackedCheck, error) {
+	githubWorkflow, err := h.Workflows.getWorkflow(ctx, client, owner, repo, workflow)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to retrieve workflow")
+		return trackedCheck{}, err
+	}
+	name := checkName(checkNamespace, githubWorkflow.GetName())
+	if err := createStatus(ctx, client, owner, repo, SHA, name, "pending", "Ariane dispatched "+displayName+", waiting for the run to start", ""); err != nil {
+		logger.Error().Err(err).Msg("Failed to create pending commit status")
+		return trackedCheck{}, err
+	}
+	return trackedCheck{owner: owner, repo: repo, name: name, commitStatus: true, SHA: SHA}, nil
+}
+
+// skippedStatusWorkflow returns the workflow file of the commit status of a skipped workflow, if it is one
+func skippedStatusWorkflow(status *github.RepoStatus) (string, bool) {
+	if status.GetState() != "success" || !strings.HasPrefix(status.GetDescription(), skippedStatusPrefix) || status.GetTargetURL() == "" {
+		return "", false
+	}
+	return path.Base(status.GetTargetURL()), true
+}
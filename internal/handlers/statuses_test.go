@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_statusDescription(t *testing.T) {
+	assert.Equal(t, "short", statusDescription("short"))
+	truncated := statusDescription(strings.Repeat("é", 200))
+	assert.Len(t, []rune(truncated), statusDescriptionLimit)
+	assert.True(t, strings.HasSuffix(truncated, "…"))
+
+	assert.Equal(t, "success", statusState("skipped"))
+	assert.Equal(t, "error", statusState("cancelled"))
+	assert.Equal(t, "failure", statusState("timed_out"))
+	assert.Equal(t, "pending", runStatusState(&github.WorkflowRun{Status: github.Ptr("in_progress")}))
+	assert.Equal(t, "failure", runStatusState(&github.WorkflowRun{Status: github.Ptr("completed"), Conclusion: github.Ptr("startup_failure")}))
+}
+
+func Test_carryOverSkippedStatuses(t *testing.T) {
+	statuses := map[string][]*github.RepoStatus{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/commits/{sha}/status", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(github.CombinedStatus{Statuses: statuses[r.PathValue("sha")]})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/statuses/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		var status github.RepoStatus
+		_ = json.NewDecoder(r.Body).Decode(&status)
+		statuses[r.PathValue("sha")] = append(statuses[r.PathValue("sha")], &status)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&status)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.Workflow{
+			Name:    github.Ptr(strings.TrimSuffix(r.PathValue("workflow"), ".yaml")),
+			HTMLURL: github.Ptr("https://github.com/owner/repo/blob/main/.github/workflows/" + r.PathValue("workflow")),
+		})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("no check run is created when reporting commit statuses")
+	})
+	mockServer := httptest.NewServer(mux)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	arianeConfig := &config.ArianeConfig{
+		Workflows: map[string]config.WorkflowPathsRegexConfig{
+			"foo.yaml": {PathsRegex: "src/"},
+			"bar.yaml": {PathsRegex: "docs/"},
+		},
+		Reporter: config.ReporterStatuses,
+	}
+	ctx := context.Background()
+	logger := zerolog.Nop()
+	files := []*github.CommitFile{{Filename: github.Ptr("test/README.md")}}
+	for _, workflow := range []string{"foo.yaml", "bar.yaml"} {
+		assert.NoError(t, markWorkflowAsSkipped(ctx, nil, client, arianeConfig, "owner", "repo", workflow, "old-sha", "", arianeConfig.ShouldRun(ctx, workflow, files), logger))
+	}
+	assert.Len(t, statuses["old-sha"], 2)
+	assert.Equal(t, "foo", statuses["old-sha"][0].GetContext())
+	assert.Equal(t, "success", statuses["old-sha"][0].GetState())
+	assert.Equal(t, "Skipped by Ariane: no changed file matches paths-regex \"src/\" (paths_not_matched)", statuses["old-sha"][0].GetDescription())
+
+	// bar.yaml now runs for the docs changes, only foo.yaml is still skipped
+	handler := &PullRequestHandler{}
+	files = []*github.CommitFile{{Filename: github.Ptr("docs/README.md")}}
+	assert.NoError(t, handler.carryOverSkippedChecks(ctx, client, arianeConfig, "owner", "repo", "old-sha", "new-sha", files, logger))
+	assert.Len(t, statuses["new-sha"], 1)
+	assert.Equal(t, "foo", statuses["new-sha"][0].GetContext())
+
+	// the pending commit status follows the dispatched run
+	check := trackedCheck{owner: "owner", repo: "repo", name: "foo", commitStatus: true, SHA: "new-sha"}
+	run := &github.WorkflowRun{Status: github.Ptr("completed"), Conclusion: github.Ptr("failure"), HTMLURL: github.Ptr("https://github.com/owner/repo/actions/runs/1")}
+	assert.NoError(t, updateCheckFromRun(ctx, client, check, run))
+	assert.Equal(t, "failure", statuses["new-sha"][1].GetState())
+	assert.Equal(t, "https://github.com/owner/repo/actions/runs/1", statuses["new-sha"][1].GetTargetURL())
+}
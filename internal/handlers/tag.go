@@ -0,0 +1,32 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/decision"
+)
+
+// resolveTag checks that a tag given to a tag trigger exists, and returns the SHA of the commit it points to
+func (h *PRCommentHandler) resolveTag(ctx context.Context, client *github.Client, owner, repo, tag string, logger zerolog.Logger) (string, decision.Decision) {
+	if tag == "" {
+		return "", decision.No(decision.ReasonTagNotFound, "no tag given")
+	}
+	SHA, _, err := client.Repositories.GetCommitSHA1(ctx, owner, repo, "refs/tags/"+tag, "")
+	if err != nil {
+		var errResponse *github.ErrorResponse
+		if errors.As(err, &errResponse) && (errResponse.Response.StatusCode == http.StatusNotFound || errResponse.Response.StatusCode == http.StatusUnprocessableEntity) {
+			return "", decision.No(decision.ReasonTagNotFound, "tag %s does not exist", tag)
+		}
+		logger.Error().Err(err).Msgf("Failed to resolve tag %s", tag)
+		return "", decision.No(decision.ReasonTagLookupFailure, "failed to resolve tag %s", tag)
+	}
+	return SHA, decision.Yes(decision.ReasonTagFound, "tag %s points to %s", tag, SHA)
+}
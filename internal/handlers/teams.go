@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/shurcooL/githubv4"
+)
+
+// maxTeamMemberPages bounds the pages of team members matching a login, which the members query matches against
+// logins and names alike
+const maxTeamMemberPages = 5
+
+// graphQLClientURL returns the URL of the GraphQL API on the host of a REST client
+func graphQLClientURL(client *github.Client) string {
+	url := *client.BaseURL
+	if strings.HasSuffix(url.Path, "/api/v3/") {
+		// GitHub Enterprise Server serves the REST API under /api/v3 and the GraphQL API under /api/graphql
+		url.Path = strings.TrimSuffix(url.Path, "v3/") + "graphql"
+	} else {
+		url.Path = strings.TrimSuffix(url.Path, "/") + "/graphql"
+	}
+	return url.String()
+}
+
+// isNestedTeamMember uses the GraphQL API to check whether a user is a member of a team or of any of its child
+// teams, as GetTeamMembershipBySlug only tells about the direct members
+func isNestedTeamMember(ctx context.Context, client *github.Client, owner, team, author string) (bool, error) {
+	var query struct {
+		Organization struct {
+			Team struct {
+				Members struct {
+					Nodes    []struct{ Login string }
+					PageInfo struct {
+						EndCursor   githubv4.String
+						HasNextPage bool
+					}
+				} `graphql:"members(query: $login, membership: ALL, first: 100, after: $cursor)"`
+			} `graphql:"team(slug: $team)"`
+		} `graphql:"organization(login: $owner)"`
+	}
+	variables := map[string]any{
+		"owner":  githubv4.String(owner),
+		"team":   githubv4.String(team),
+		"login":  githubv4.String(author),
+		"cursor": (*githubv4.String)(nil),
+	}
+	// the GraphQL client shares the authentication of the REST client
+	v4 := githubv4.NewEnterpriseClient(graphQLClientURL(client), client.Client())
+	for page := 0; page < maxTeamMemberPages; page++ {
+		if err := v4.Query(ctx, &query, variables); err != nil {
+			return false, err
+		}
+		members := query.Organization.Team.Members
+		for _, member := range members.Nodes {
+			if strings.EqualFold(member.Login, author) {
+				return true, nil
+			}
+		}
+		if !members.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(members.PageInfo.EndCursor)
+	}
+	return false, nil
+}
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_isAllowedTeamMemberNested(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orgs/owner/teams/parent/memberships/{user}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /graphql", func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		var logins []string
+		hasNextPage := false
+		switch {
+		case request.Variables["login"] == "childmember":
+			// members are matched on their login and name, the exact login must be looked for
+			logins = []string{"childmember-bot", "childmember"}
+		case request.Variables["login"] == "paged" && request.Variables["cursor"] == nil:
+			logins, hasNextPage = []string{"paged-1"}, true
+		case request.Variables["login"] == "paged":
+			logins = []string{"paged"}
+		}
+		nodes := []map[string]string{}
+		for _, login := range logins {
+			nodes = append(nodes, map[string]string{"login": login})
+		}
+		encoded, _ := json.Marshal(nodes)
+		fmt.Fprintf(w, `{"data": {"organization": {"team": {"members": {"nodes": %s, "pageInfo": {"endCursor": "next", "hasNextPage": %v}}}}}}`, encoded, hasNextPage)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	enterprise := github.NewClient(nil)
+	enterprise.BaseURL, _ = url.Parse("https://github.example.com/api/v3/")
+	assert.Equal(t, "https://github.example.com/api/graphql", graphQLClientURL(enterprise), "GitHub Enterprise Server serves GraphQL under /api/graphql")
+
+	handler := &PRCommentHandler{}
+	testCases := []struct {
+		ArianeConfig   *config.ArianeConfig
+		Author         string
+		ExpectedResult bool
+		ExpectedCode   decision.Reason
+		ExpectedReason string
+	}{
+		{
+			ArianeConfig:   &config.ArianeConfig{AllowedTeams: []string{"parent"}, NestedTeams: true},
+			Author:         "childmember",
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonChildTeamMember,
+			ExpectedReason: "childmember belongs to a child team of parent.",
+		},
+		{
+			ArianeConfig:   &config.ArianeConfig{AllowedTeams: []string{"parent"}},
+			Author:         "childmember",
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNotTeamMember,
+			ExpectedReason: "child teams are only looked at if nested-teams is set.",
+		},
+		{
+			ArianeConfig:   &config.ArianeConfig{AllowedTeams: []string{"parent"}, NestedTeams: true},
+			Author:         "paged",
+			ExpectedResult: true,
+			ExpectedCode:   decision.ReasonChildTeamMember,
+			ExpectedReason: "all the pages of matching members are looked at.",
+		},
+		{
+			ArianeConfig:   &config.ArianeConfig{AllowedTeams: []string{"parent"}, NestedTeams: true},
+			Author:         "outsider",
+			ExpectedResult: false,
+			ExpectedCode:   decision.ReasonNotTeamMember,
+			ExpectedReason: "outsider belongs to no child team of parent.",
+		},
+	}
+	for idx, testCase := range testCases {
+		result := handler.isAllowedTeamMember(context.Background(), client, testCase.ArianeConfig, "owner", testCase.Author, zerolog.Nop())
+		assert.Equal(t, testCase.ExpectedResult, result.Result, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedCode, result.Reason, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
+
+func Test_findApproverNested(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /orgs/owner/teams/parent/memberships/{user}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /graphql", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"organization": {"team": {"members": {"nodes": [{"login": "childmember"}], "pageInfo": {"hasNextPage": false}}}}}}`)
+	})
+	mux.HandleFunc("GET /repos/owner/repo/issues/comments/1/reactions", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.Reaction{{User: &github.User{Login: github.String("childmember")}, Content: github.String("rocket")}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	event := &github.IssueCommentEvent{
+		Repo:    &github.Repository{Owner: &github.User{Login: github.String("owner")}, Name: github.String("repo")},
+		Comment: &github.IssueComment{ID: github.Int64(1), User: &github.User{Login: github.String("outsider")}},
+	}
+	handler := &PRCommentHandler{}
+	for _, nested := range []bool{false, true} {
+		// held comments are stored, and approved once read back
+		held := heldComment{event: event, allowedTeams: []string{"parent"}, nestedTeams: nested, reaction: "rocket"}.stored().held()
+		approver := handler.findApprover(context.Background(), client, held, zerolog.Nop())
+		assert.Equal(t, nested, approver == "childmember", "members of child teams only approve held comments if nested-teams is set (nested=%v)", nested)
+	}
+}
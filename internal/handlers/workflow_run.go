@@ -0,0 +1,269 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/log"
+)
+
+// trackedCheck is a check run created at dispatch time, following the status of the dispatched run
+type trackedCheck struct {
+	owner      string
+	repo       string
+	name       string
+	checkRunID int64
+	// commitStatus is set for the commit statuses named name on SHA, reported instead of check runs
+	commitStatus bool
+	SHA          string
+}
+
+// RunChecks tracks the check runs created at dispatch time by workflow run ID.
+// A nil RunChecks is valid and tracks nothing, check runs are then looked up by the run head SHA.
+type RunChecks struct {
+	mu     sync.Mutex
+	checks map[int64]trackedCheck
+}
+
+func NewRunChecks() *RunChecks {
+	return &RunChecks{checks: map[int64]trackedCheck{}}
+}
+
+func (r *RunChecks) add(runID int64, check trackedCheck) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[runID] = check
+}
+
+func (r *RunChecks) get(runID int64) (trackedCheck, bool) {
+	if r == nil {
+		return trackedCheck{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	check, ok := r.checks[runID]
+	return check, ok
+}
+
+func (r *RunChecks) remove(runID int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.checks, runID)
+}
+
+// runExternalID identifies the check run following a workflow run
+func runExternalID(runID int64) string {
+	return "run/" + strconv.FormatInt(runID, 10)
+}
+
+// WorkflowRunHandler updates the check runs created at dispatch time as the dispatched runs progress
+type WorkflowRunHandler struct {
+	githubapp.ClientCreator
+	RunChecks *RunChecks
+	// Digest collects the failed runs dispatched by Ariane, those showing a run marker or followed by a check run,
+	// if digests are enabled
+	Digest *FailureDigest
+	// Pools frees the resource pool slots held by the completed runs, shared with the PRCommentHandler dispatching them
+	Pools *Pools
+	// Green records the conclusions of the completed runs, shared with the PRCommentHandler dispatching them
+	Green *GreenStore
+	// Comments appends the failed jobs of the failed runs dispatched by Ariane to the summary comment of their PR
+	Comments *PRCommentHandler
+}
+
+func (h *WorkflowRunHandler) Handles() []string {
+	return []string{"workflow_run"}
+}
+
+func (h *WorkflowRunHandler) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	var event github.WorkflowRunEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return fmt.Errorf("failed to parse workflow_run event payload: %w", err)
+	}
+
+	// only runs dispatched by Ariane may have a check run following them, all are counted by origin
+	run := event.GetWorkflowRun()
+	if run.GetEvent() != "workflow_dispatch" {
+		return nil
+	}
+	if run.GetStatus() == "completed" {
+		recordDispatchedRun(event.GetRepo().GetFullName(), run)
+		h.Pools.completed(run.GetID())
+		h.Green.completed(event.GetRepo().GetOwner().GetLogin(), event.GetRepo().GetName(), run)
+	}
+
+	installationID := githubapp.GetInstallationIDFromEvent(&event)
+	repository := event.GetRepo()
+	ctx, logger := githubapp.PrepareRepoContext(ctx, installationID, repository)
+	ctx = log.WithLogger(ctx, &logger)
+
+	client, err := h.NewInstallationClient(installationID)
+	if err != nil {
+		return err
+	}
+
+	owner := repository.GetOwner().GetLogin()
+	repo := repository.GetName()
+
+	completed := run.GetStatus() == "completed"
+	if completed && isMarkedRun(run) {
+		h.Digest.record(installationID, repository, run)
+	}
+
+	check, tracked := h.RunChecks.get(run.GetID())
+	if !tracked {
+		// the check run was created before a restart, look it up on the run head SHA
+		check, tracked, err = findRunCheck(ctx, client, owner, repo, run)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to list check runs")
+			return err
+		}
+	}
+	if h.Comments != nil && completed && isFailedConclusion(run.GetConclusion()) && (tracked || isMarkedRun(run)) {
+		// the failed jobs are a convenience, failing to append them does not prevent updating the check run
+		if err := h.Comments.appendFailedJobs(ctx, client, repository, run, logger); err != nil {
+			logger.Warn().Err(err).Msgf("Failed to append failed jobs of run %d to the summary comment", run.GetID())
+		}
+	}
+	if !tracked {
+		return nil
+	}
+
+	if err := updateCheckFromRun(ctx, client, check, run); err != nil {
+		logger.Error().Err(err).Msgf("Failed to update check run following run %d", run.GetID())
+		return err
+	}
+	if completed {
+		if !isMarkedRun(run) {
+			h.Digest.record(installationID, repository, run)
+		}
+		h.RunChecks.remove(run.GetID())
+	}
+	return nil
+}
+
+// findRunCheck looks up the check run following a workflow run on the run head SHA. The check runs following runs
+// dispatched for merge groups are named after the required check rather than the workflow, so all are looked at.
+func findRunCheck(ctx context.Context, client *github.Client, owner, repo string, run *github.WorkflowRun) (trackedCheck, bool, error) {
+	checkRuns, _, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, run.GetHeadSHA(), &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}})
+	if err != nil {
+		return trackedCheck{}, false, err
+	}
+	for _, checkRun := range checkRuns.CheckRuns {
+		if checkRun.GetExternalID() == runExternalID(run.GetID()) {
+			return trackedCheck{owner: owner, repo: repo, name: checkRun.GetName(), checkRunID: checkRun.GetID()}, true, nil
+		}
+	}
+	return trackedCheck{}, false, nil
+}
+
+// checkStatus maps a workflow run status to a check run status
+func checkStatus(runStatus string) string {
+	switch runStatus {
+	case "in_progress", "completed":
+		return runStatus
+	default:
+		// requested, waiting, pending
+		return "queued"
+	}
+}
+
+// checkConclusion maps a workflow run conclusion to a check run conclusion
+func checkConclusion(runConclusion string) string {
+	switch runConclusion {
+	case "success", "failure", "neutral", "cancelled", "skipped", "timed_out", "action_required", "stale":
+		return runConclusion
+	default:
+		// startup_failure
+		return "failure"
+	}
+}
+
+// updateCheckFromRun updates a check run to reflect the status of the workflow run it follows
+func updateCheckFromRun(ctx context.Context, client *github.Client, check trackedCheck, run *github.WorkflowRun) error {
+	title := "Workflow run " + run.GetStatus()
+	if check.commitStatus {
+		return createStatus(ctx, client, check.owner, check.repo, check.SHA, check.name, runStatusState(run), title, run.GetHTMLURL())
+	}
+	summary := fmt.Sprintf("[%s #%d](%s) was dispatched by Ariane.", run.GetName(), run.GetRunNumber(), run.GetHTMLURL())
+	opts := github.UpdateCheckRunOptions{
+		Name:       check.name,
+		DetailsURL: run.HTMLURL,
+		ExternalID: github.String(runExternalID(run.GetID())),
+		Status:     github.String(checkStatus(run.GetStatus())),
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
+	}
+	if run.GetStatus() == "completed" {
+		opts.Conclusion = github.String(checkConclusion(run.GetConclusion()))
+	}
+	_, _, err := client.Checks.UpdateCheckRun(ctx, check.owner, check.repo, check.checkRunID, opts)
+	return err
+}
+
+// createQueuedCheck creates a queued check run named after the workflow, in the check namespace of its trigger if any,
+// so branch protection sees the workflow as pending right away, until the dispatched run shows up.
+func (h *PRCommentHandler) createQueuedCheck(ctx context.Context, client *github.Client, owner, repo, workflow, displayName, SHA, checkNamespace string, logger zerolog.Logger) (trackedCheck, error) {
+	githubWorkflow, err := h.Workflows.getWorkflow(ctx, client, owner, repo, workflow)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to retrieve workflow")
+		return trackedCheck{}, err
+	}
+
+	if lacksChecksPermission(owner, repo) {
+		return trackedCheck{}, errChecksDenied
+	}
+	name := checkName(checkNamespace, githubWorkflow.GetName())
+	title := "Workflow dispatched"
+	summary := fmt.Sprintf("Ariane dispatched %s, waiting for the run to start.", displayName)
+	checkRun, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, github.CreateCheckRunOptions{
+		Name:       name,
+		HeadSHA:    SHA,
+		ExternalID: github.String("dispatch/" + workflow),
+		Status:     github.String("queued"),
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &summary},
+	})
+	if err != nil {
+		if !denyChecks(owner, repo, err, logger) {
+			logger.Error().Err(err).Msg("Failed to create queued check run")
+		}
+		return trackedCheck{}, err
+	}
+	return trackedCheck{owner: owner, repo: repo, name: name, checkRunID: checkRun.GetID()}, nil
+}
+
+// abandonQueuedCheck completes a queued check run whose dispatched run could not be started or found, with the given
+// conclusion: neutral for PRs, so the missing run does not block them, failure for merge groups
+func abandonQueuedCheck(ctx context.Context, client *github.Client, check trackedCheck, conclusion, reason string, logger zerolog.Logger) {
+	title := "Workflow run not found"
+	if check.commitStatus {
+		if err := createStatus(ctx, client, check.owner, check.repo, check.SHA, check.name, statusState(conclusion), reason, ""); err != nil {
+			logger.Error().Err(err).Msg("Failed to complete pending commit status")
+		}
+		return
+	}
+	_, _, err := client.Checks.UpdateCheckRun(ctx, check.owner, check.repo, check.checkRunID, github.UpdateCheckRunOptions{
+		Name:       check.name,
+		Status:     github.String("completed"),
+		Conclusion: github.String(conclusion),
+		Output:     &github.CheckRunOutput{Title: &title, Summary: &reason},
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to complete queued check run")
+	}
+}
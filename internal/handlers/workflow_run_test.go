@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	github "github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	gomock "go.uber.org/mock/gomock"
+
+	"github.com/cilium/ariane/internal/poll"
+)
+
+// setCheckRunsMockServer serves the check runs and workflows endpoints, recording check run updates
+func setCheckRunsMockServer(updates *[]github.UpdateCheckRunOptions, mu *sync.Mutex) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.Workflow{Name: github.String("Foo")})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}/runs", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.WorkflowRuns{
+			TotalCount: github.Int(1),
+			WorkflowRuns: []*github.WorkflowRun{{
+				ID:        github.Int64(3),
+				Name:      github.String("Foo"),
+				HeadSHA:   github.String("mock-sha"),
+				RunNumber: github.Int(42),
+				Status:    github.String("queued"),
+				HTMLURL:   github.String("https://github.com/owner/repo/actions/runs/3"),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /repos/owner/repo/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&github.CheckRun{ID: github.Int64(7)})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/commits/{ref}/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(&github.ListCheckRunsResults{
+			Total:     github.Int(1),
+			CheckRuns: []*github.CheckRun{{ID: github.Int64(8), Name: github.String("Foo"), ExternalID: github.String("run/4")}},
+		})
+	})
+	mux.HandleFunc("PATCH /repos/owner/repo/check-runs/{checkRunID}", func(w http.ResponseWriter, r *http.Request) {
+		var opts github.UpdateCheckRunOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		opts.ExternalID = github.String(r.PathValue("checkRunID") + ":" + opts.GetExternalID())
+		mu.Lock()
+		*updates = append(*updates, opts)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(&github.CheckRun{})
+	})
+	return httptest.NewServer(mux)
+}
+
+func Test_queuedCheck(t *testing.T) {
+	var updates []github.UpdateCheckRunOptions
+	var mu sync.Mutex
+	mockServer := setCheckRunsMockServer(&updates, &mu)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	handler := &PRCommentHandler{
+		Poll:      poll.Poller{Interval: time.Millisecond},
+		RunChecks: NewRunChecks(),
+	}
+
+	var logger zerolog.Logger
+	check, err := handler.createQueuedCheck(context.Background(), client, "owner", "repo", "foo.yaml", "Foo", "mock-sha", "", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, trackedCheck{owner: "owner", repo: "repo", name: "Foo", checkRunID: 7}, check)
+
+	// the queued check run follows the dispatched run once found
+	dispatch := dispatchedRun{workflow: "foo.yaml", ref: "main", SHA: "mock-sha", dispatchedAt: time.Now(), queuedCheck: &check}
+	assert.NoError(t, handler.linkDispatchedRun(context.Background(), client, "owner", "repo", dispatch, logger))
+	tracked, ok := handler.RunChecks.get(3)
+	assert.True(t, ok)
+	assert.Equal(t, check, tracked)
+	assert.Len(t, updates, 1)
+	assert.Equal(t, "7:run/3", updates[0].GetExternalID())
+	assert.Equal(t, "queued", updates[0].GetStatus())
+	assert.Equal(t, "https://github.com/owner/repo/actions/runs/3", updates[0].GetDetailsURL())
+
+	// the check runs of triggers with a check namespace are prefixed with it
+	check, err = handler.createQueuedCheck(context.Background(), client, "owner", "repo", "foo.yaml", "Foo", "mock-sha", "Ariane / e2e", logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "Ariane / e2e / Foo", check.name)
+}
+
+func TestWorkflowRunHandle(t *testing.T) {
+	var updates []github.UpdateCheckRunOptions
+	var mu sync.Mutex
+	mockServer := setCheckRunsMockServer(&updates, &mu)
+	defer mockServer.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(mockServer.URL + "/")
+
+	mockCtrl := gomock.NewController(t)
+	mockClientCreator := NewMockClientCreator(mockCtrl)
+	mockClientCreator.EXPECT().NewInstallationClient(int64(0)).Return(client, nil).AnyTimes()
+
+	handler := &WorkflowRunHandler{
+		ClientCreator: mockClientCreator,
+		RunChecks:     NewRunChecks(),
+	}
+	handler.RunChecks.add(3, trackedCheck{owner: "owner", repo: "repo", name: "Foo", checkRunID: 7})
+
+	payload := func(runID int, event, status, conclusion string) []byte {
+		run, _ := json.Marshal(&github.WorkflowRun{
+			ID:         github.Int64(int64(runID)),
+			Name:       github.String("Foo"),
+			Event:      github.String(event),
+			Status:     github.String(status),
+			Conclusion: github.String(conclusion),
+			HeadSHA:    github.String("mock-sha"),
+		})
+		return []byte(`{"repository": {"owner": {"login": "owner"}, "name": "repo"}, "workflow_run": ` + string(run) + `}`)
+	}
+
+	// runs not dispatched by Ariane are ignored
+	assert.NoError(t, handler.Handle(context.Background(), "workflow_run", "deliveryID", payload(3, "push", "completed", "success")))
+	assert.Empty(t, updates)
+
+	// tracked runs update their check run, and are no longer tracked once completed
+	assert.NoError(t, handler.Handle(context.Background(), "workflow_run", "deliveryID", payload(3, "workflow_dispatch", "completed", "startup_failure")))
+	assert.Len(t, updates, 1)
+	assert.Equal(t, "7:run/3", updates[0].GetExternalID())
+	assert.Equal(t, "failure", updates[0].GetConclusion())
+	_, ok := handler.RunChecks.get(3)
+	assert.False(t, ok)
+
+	// untracked runs have their check run looked up on the run head SHA
+	assert.NoError(t, handler.Handle(context.Background(), "workflow_run", "deliveryID", payload(4, "workflow_dispatch", "in_progress", "")))
+	assert.Len(t, updates, 2)
+	assert.Equal(t, "8:run/4", updates[1].GetExternalID())
+	assert.Equal(t, "in_progress", updates[1].GetStatus())
+	assert.Nil(t, updates[1].Conclusion)
+
+	// runs without check run are ignored
+	assert.NoError(t, handler.Handle(context.Background(), "workflow_run", "deliveryID", payload(5, "workflow_dispatch", "completed", "success")))
+	assert.Len(t, updates, 2)
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	gocache "github.com/patrickmn/go-cache"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+// errWorkflowNotFound is returned when a configured workflow file does not exist in the repository
+var errWorkflowNotFound = errors.New("workflow not found")
+
+// WorkflowCache keeps the workflows looked up in repositories for a while, and what their file declares at a given
+// ref. A nil WorkflowCache is valid and caches nothing.
+type WorkflowCache struct {
+	cache *gocache.Cache
+}
+
+// NewWorkflowCache creates a cache whose entries expire after ttl. Caching is disabled if ttl is zero.
+func NewWorkflowCache(ttl time.Duration) *WorkflowCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &WorkflowCache{cache: gocache.New(ttl, 2*ttl)}
+}
+
+func (c *WorkflowCache) get(key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+	return c.cache.Get(key)
+}
+
+func (c *WorkflowCache) set(key string, value any) {
+	if c == nil {
+		return
+	}
+	c.cache.SetDefault(key, value)
+}
+
+// getWorkflow returns a workflow of the repository by file name, or errWorkflowNotFound if there is none
+func (c *WorkflowCache) getWorkflow(ctx context.Context, client *github.Client, owner, repo, workflow string) (*github.Workflow, error) {
+	key := owner + "/" + repo + "/" + workflow
+	if v, ok := c.get(key); ok {
+		return v.(*github.Workflow), nil
+	}
+	githubWorkflow, response, err := client.Actions.GetWorkflowByFileName(ctx, owner, repo, workflow)
+	if response != nil && response.StatusCode == http.StatusNotFound {
+		return nil, errWorkflowNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	c.set(key, githubWorkflow)
+	return githubWorkflow, nil
+}
+
+// forget drops the cached lookups of a workflow, e.g. once GitHub no longer finds it
+func (c *WorkflowCache) forget(owner, repo, workflow string) {
+	if c == nil {
+		return
+	}
+	key := owner + "/" + repo + "/" + workflow
+	for cached := range c.cache.Items() {
+		if cached == key || strings.HasPrefix(cached, key+"@") {
+			c.cache.Delete(cached)
+		}
+	}
+}
+
+// workflowFile is what Ariane needs to know of a workflow file to dispatch it
+type workflowFile struct {
+	// dispatchable is set if the workflow declares the workflow_dispatch trigger, without which dispatching it fails
+	dispatchable bool
+	// inputs are the names of the workflow_dispatch inputs, GitHub rejects dispatches with other inputs
+	inputs  map[string]bool
+	runName string
+}
+
+// getWorkflowFile returns what the workflow file declares at ref
+func (c *WorkflowCache) getWorkflowFile(ctx context.Context, client *github.Client, owner, repo, workflow, ref string) (workflowFile, error) {
+	githubWorkflow, err := c.getWorkflow(ctx, client, owner, repo, workflow)
+	if err != nil {
+		return workflowFile{}, err
+	}
+	key := owner + "/" + repo + "/" + workflow + "@" + ref
+	if v, ok := c.get(key); ok {
+		return v.(workflowFile), nil
+	}
+	content, _, _, err := client.Repositories.GetContents(ctx, owner, repo, githubWorkflow.GetPath(), &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return workflowFile{}, fmt.Errorf("failed downloading workflow file: %w", err)
+	}
+	text, err := content.GetContent()
+	if err != nil {
+		return workflowFile{}, fmt.Errorf("failed reading workflow file: %w", err)
+	}
+	file, err := parseWorkflowFile([]byte(text))
+	if err != nil {
+		return workflowFile{}, err
+	}
+	c.set(key, file)
+	return file, nil
+}
+
+// parseWorkflowFile checks whether the `on` triggers of a workflow file include workflow_dispatch, whether they
+// are given as a single event, a list of events, or a map of events, and which inputs it declares
+func parseWorkflowFile(content []byte) (workflowFile, error) {
+	var workflow struct {
+		RunName string    `yaml:"run-name"`
+		On      yaml.Node `yaml:"on"`
+	}
+	if err := yaml.Unmarshal(content, &workflow); err != nil {
+		return workflowFile{}, fmt.Errorf("failed parsing workflow file: %w", err)
+	}
+	file := workflowFile{inputs: map[string]bool{}, runName: workflow.RunName}
+	switch workflow.On.Kind {
+	case yaml.ScalarNode:
+		file.dispatchable = workflow.On.Value == "workflow_dispatch"
+	case yaml.SequenceNode:
+		for _, event := range workflow.On.Content {
+			if event.Value == "workflow_dispatch" {
+				file.dispatchable = true
+			}
+		}
+	case yaml.MappingNode:
+		// keys and values alternate in mapping nodes
+		for i := 0; i+1 < len(workflow.On.Content); i += 2 {
+			if workflow.On.Content[i].Value != "workflow_dispatch" {
+				continue
+			}
+			file.dispatchable = true
+			var dispatch struct {
+				Inputs map[string]yaml.Node `yaml:"inputs"`
+			}
+			if err := workflow.On.Content[i+1].Decode(&dispatch); err != nil {
+				return workflowFile{}, fmt.Errorf("failed parsing workflow_dispatch trigger: %w", err)
+			}
+			for input := range dispatch.Inputs {
+				file.inputs[input] = true
+			}
+		}
+	}
+	return file, nil
+}
+
+// preflightWorkflows checks that the workflows of the config triggers exist and declare workflow_dispatch at ref,
+// returning a warning for each one which can never be dispatched. With run markers enabled, it also checks that they
+// declare the run marker input and show it in their run name.
+func preflightWorkflows(ctx context.Context, cache *WorkflowCache, client *github.Client, arianeConfig *config.ArianeConfig, owner, repo, ref string) []error {
+	seen := map[string]bool{}
+	var workflows []string
+	for _, trigger := range arianeConfig.Triggers {
+		for _, workflow := range trigger.Workflows {
+			if !seen[workflow] {
+				seen[workflow] = true
+				workflows = append(workflows, workflow)
+			}
+		}
+	}
+	sort.Strings(workflows)
+
+	var warnings []error
+	for _, workflow := range workflows {
+		file, err := cache.getWorkflowFile(ctx, client, owner, repo, workflow, ref)
+		switch {
+		case errors.Is(err, errWorkflowNotFound):
+			warnings = append(warnings, fmt.Errorf("workflow %q: not found in the repository", workflow))
+		case err != nil:
+			warnings = append(warnings, fmt.Errorf("workflow %q: could not be checked: %w", workflow, err))
+		case !file.dispatchable:
+			warnings = append(warnings, fmt.Errorf("workflow %q: does not declare the workflow_dispatch trigger, so it can never be dispatched", workflow))
+		case arianeConfig.RunMarker && !file.inputs[runMarkerInput]:
+			warnings = append(warnings, fmt.Errorf("workflow %q: does not declare the %s input, so it can never be dispatched with run-marker set", workflow, runMarkerInput))
+		case arianeConfig.RunMarker && !strings.Contains(file.runName, "inputs."+runMarkerInput):
+			warnings = append(warnings, fmt.Errorf("workflow %q: does not show the %s input in its run-name, so its dispatched runs cannot be told apart", workflow, runMarkerInput))
+		case arianeConfig.Provenance && !file.inputs[provenanceInput]:
+			warnings = append(warnings, fmt.Errorf("workflow %q: does not declare the %s input, so it can never be dispatched with provenance set", workflow, provenanceInput))
+		case arianeConfig.Analytics && !file.inputs[analyticsInput]:
+			warnings = append(warnings, fmt.Errorf("workflow %q: does not declare the %s input, so it can never be dispatched with analytics set", workflow, analyticsInput))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,232 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/decision"
+)
+
+func Test_parseWorkflowFile(t *testing.T) {
+	testCases := []struct {
+		Content        string
+		ExpectedResult bool
+		ExpectedInputs map[string]bool
+		ExpectedReason string
+	}{
+		{
+			Content:        "on: workflow_dispatch",
+			ExpectedResult: true,
+			ExpectedReason: "workflow_dispatch is the single event.",
+		},
+		{
+			Content:        "on: [push, workflow_dispatch]",
+			ExpectedResult: true,
+			ExpectedReason: "workflow_dispatch is in the list of events.",
+		},
+		{
+			Content:        "on:\n  workflow_dispatch:\n    inputs:\n      PR-number:\n        required: true\n      ariane-delivery-id:\n",
+			ExpectedResult: true,
+			ExpectedInputs: map[string]bool{"PR-number": true, "ariane-delivery-id": true},
+			ExpectedReason: "workflow_dispatch is in the map of events, with its inputs.",
+		},
+		{
+			Content:        "on:\n  push:\n    branches: [main]\n",
+			ExpectedResult: false,
+			ExpectedReason: "only push is declared.",
+		},
+	}
+
+	for idx, testCase := range testCases {
+		file, err := parseWorkflowFile([]byte(testCase.Content))
+		assert.NoError(t, err, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		assert.Equal(t, testCase.ExpectedResult, file.dispatchable, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		if testCase.ExpectedInputs == nil {
+			testCase.ExpectedInputs = map[string]bool{}
+		}
+		assert.Equal(t, testCase.ExpectedInputs, file.inputs, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
+
+func Test_preflightWorkflows(t *testing.T) {
+	files := map[string]string{
+		"foo.yaml": "on: workflow_dispatch",
+		"bar.yaml": "on: push",
+	}
+	var lookups int
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		workflow := r.PathValue("workflow")
+		if _, ok := files[workflow]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(github.Workflow{Name: github.String(workflow), Path: github.String(".github/workflows/" + workflow)})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/.github/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		content := base64.StdEncoding.EncodeToString([]byte(files[r.PathValue("workflow")]))
+		_ = json.NewEncoder(w).Encode(github.RepositoryContent{Type: github.String("file"), Encoding: github.String("base64"), Content: github.String(content)})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	arianeConfig := &config.ArianeConfig{
+		Triggers: map[string]config.TriggerConfig{
+			"/test":     {Workflows: []string{"foo.yaml", "bar.yaml"}},
+			"/test-foo": {Workflows: []string{"foo.yaml", "baz.yaml"}},
+		},
+	}
+	cache := NewWorkflowCache(time.Minute)
+
+	warnings := preflightWorkflows(context.Background(), cache, client, arianeConfig, "owner", "repo", "mock-sha")
+	assert.Len(t, warnings, 2)
+	assert.ErrorContains(t, warnings[0], `workflow "bar.yaml": does not declare the workflow_dispatch trigger`)
+	assert.ErrorContains(t, warnings[1], `workflow "baz.yaml": not found`)
+	assert.Equal(t, 3, lookups, "each workflow is looked up once")
+
+	// found workflows are cached
+	preflightWorkflows(context.Background(), cache, client, arianeConfig, "owner", "repo", "mock-sha")
+	assert.Equal(t, 4, lookups, "only the missing workflow is looked up again")
+
+	// with run markers, workflows must declare the marker input
+	arianeConfig.RunMarker = true
+	arianeConfig.Triggers = map[string]config.TriggerConfig{"/test": {Workflows: []string{"foo.yaml"}}}
+	warnings = preflightWorkflows(context.Background(), cache, client, arianeConfig, "owner", "repo", "mock-sha")
+	assert.Len(t, warnings, 1)
+	assert.ErrorContains(t, warnings[0], `workflow "foo.yaml": does not declare the ariane-delivery-id input`)
+
+	// with provenance, workflows must declare the provenance input
+	arianeConfig.RunMarker = false
+	arianeConfig.Provenance = true
+	warnings = preflightWorkflows(context.Background(), cache, client, arianeConfig, "owner", "repo", "mock-sha")
+	assert.Len(t, warnings, 1)
+	assert.ErrorContains(t, warnings[0], `workflow "foo.yaml": does not declare the ariane-provenance input`)
+
+	// with analytics, workflows must declare the analytics input
+	arianeConfig.Provenance = false
+	arianeConfig.Analytics = true
+	warnings = preflightWorkflows(context.Background(), cache, client, arianeConfig, "owner", "repo", "mock-sha")
+	assert.Len(t, warnings, 1)
+	assert.ErrorContains(t, warnings[0], `workflow "foo.yaml": does not declare the ariane-analytics input`)
+}
+
+func Test_declaredInputs(t *testing.T) {
+	files := map[string]string{
+		"foo.yaml": "on:\n  workflow_dispatch:\n    inputs:\n      PR-number:\n      SHA:\n",
+		"bar.yaml": "on:\n  workflow_dispatch:\n    inputs:\n      PR-number:\n      SHA:\n      extra-args:\n",
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		workflow := r.PathValue("workflow")
+		if _, ok := files[workflow]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(github.Workflow{Name: github.String(workflow), Path: github.String(".github/workflows/" + workflow)})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/.github/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		content := base64.StdEncoding.EncodeToString([]byte(files[r.PathValue("workflow")]))
+		_ = json.NewEncoder(w).Encode(github.RepositoryContent{Type: github.String("file"), Encoding: github.String("base64"), Content: github.String(content)})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	var logger zerolog.Logger
+	inputs := map[string]interface{}{"PR-number": "1", "SHA": "mock-sha", "extra-args": `""`}
+	workflows := []string{"foo.yaml", "bar.yaml", "missing.yaml"}
+
+	arianeConfig := &config.ArianeConfig{UndeclaredInputs: config.UndeclaredInputsDrop}
+	workflowInputs, d := declaredInputs(context.Background(), nil, client, arianeConfig, "owner", "repo", "main", workflows, inputs, logger)
+	assert.True(t, d.Result)
+	assert.Equal(t, decision.ReasonUndeclaredInputsDropped, d.Reason)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"foo.yaml": {"PR-number": "1", "SHA": "mock-sha"},
+	}, workflowInputs, "only the workflows not declaring all the inputs get their own, missing workflows keep them all")
+
+	arianeConfig.UndeclaredInputs = config.UndeclaredInputsReject
+	_, d = declaredInputs(context.Background(), nil, client, arianeConfig, "owner", "repo", "main", workflows, inputs, logger)
+	assert.False(t, d.Result)
+	assert.Equal(t, decision.ReasonUndeclaredInputs, d.Reason)
+	assert.Equal(t, "workflow foo.yaml does not declare the inputs extra-args", d.Message)
+
+	_, d = declaredInputs(context.Background(), nil, client, arianeConfig, "owner", "repo", "main", []string{"bar.yaml"}, inputs, logger)
+	assert.Equal(t, decision.ReasonInputsDeclared, d.Reason)
+}
+
+func Test_pathDispatchInputs(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/repo/actions/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(github.Workflow{Name: github.String(r.PathValue("workflow")), Path: github.String(".github/workflows/" + r.PathValue("workflow"))})
+	})
+	mux.HandleFunc("GET /repos/owner/repo/contents/.github/workflows/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		content := base64.StdEncoding.EncodeToString([]byte("on:\n  workflow_dispatch:\n    inputs:\n      PR-number:\n      SHA:\n      test-arm64:\n"))
+		_ = json.NewEncoder(w).Encode(github.RepositoryContent{Type: github.String("file"), Encoding: github.String("base64"), Content: github.String(content)})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	handler := &PRCommentHandler{}
+	files := []*github.CommitFile{{Filename: github.String("bpf/lib.h")}}
+	arianeConfig := &config.ArianeConfig{Workflows: map[string]config.WorkflowPathsRegexConfig{
+		"foo.yaml": {PathInputs: []config.PathInputsConfig{{PathsRegex: "bpf/", Inputs: map[string]string{"test-arm64": "true", "debug": "true"}}}},
+	}}
+	dispatch := triggerDispatch{
+		client:       client,
+		arianeConfig: arianeConfig,
+		owner:        "owner",
+		repo:         "repo",
+		contextRef:   "main",
+		workflows:    []string{"foo.yaml", "bar.yaml"},
+		event:        github.CreateWorkflowDispatchEventRequest{Inputs: map[string]interface{}{"PR-number": "1", "SHA": "mock-sha"}},
+		logger:       zerolog.Nop(),
+	}
+
+	workflowInputs, d := handler.pathDispatchInputs(context.Background(), dispatch, files)
+	assert.True(t, d.Result)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"foo.yaml": {"PR-number": "1", "SHA": "mock-sha", "test-arm64": "true", "debug": "true"},
+	}, workflowInputs, "only the workflows with path inputs get their own inputs")
+	assert.Len(t, dispatch.event.Inputs, 2, "the inputs of the trigger are left as is")
+
+	arianeConfig.UndeclaredInputs = config.UndeclaredInputsDrop
+	workflowInputs, d = handler.pathDispatchInputs(context.Background(), dispatch, files)
+	assert.True(t, d.Result)
+	assert.Equal(t, map[string]interface{}{"PR-number": "1", "SHA": "mock-sha", "test-arm64": "true"}, workflowInputs["foo.yaml"], "the undeclared path inputs are dropped")
+
+	arianeConfig.UndeclaredInputs = config.UndeclaredInputsReject
+	_, d = handler.pathDispatchInputs(context.Background(), dispatch, files)
+	assert.False(t, d.Result)
+	assert.Equal(t, decision.ReasonUndeclaredInputs, d.Reason)
+	assert.Equal(t, "workflow foo.yaml does not declare the inputs debug", d.Message)
+
+	arianeConfig.UndeclaredInputs = ""
+	for i := len(dispatch.event.Inputs); i < maxDispatchInputs-1; i++ {
+		dispatch.event.Inputs[fmt.Sprintf("input-%d", i)] = "value"
+	}
+	_, d = handler.pathDispatchInputs(context.Background(), dispatch, files)
+	assert.False(t, d.Result, "the path inputs may not exceed the limits of GitHub")
+	assert.Equal(t, decision.ReasonTooManyInputs, d.Reason)
+	assert.Equal(t, "with the path inputs of workflow foo.yaml, 11 inputs exceed the limit of 10 inputs", d.Message)
+}
@@ -0,0 +1,268 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package load tracks the events being handled, exposing the queue depth, the age of the oldest event and the
+// utilization of each worker as metrics, and warning when they cross thresholds, so saturation shows before
+// GitHub times out deliveries.
+package load
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/metrics"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+const (
+	DefaultSampleInterval = 15 * time.Second
+)
+
+var (
+	queueDepth = metrics.NewGaugeVec("ariane_event_queue_depth",
+		"Events received and not handled yet.")
+	oldestEventAge = metrics.NewGaugeVec("ariane_event_oldest_age_seconds",
+		"Age of the oldest event received and not handled yet, zero if there are none.")
+	workerUtilization = metrics.NewGaugeVec("ariane_worker_utilization",
+		"Fraction of the last sample interval each worker spent handling events, by worker.",
+		"worker")
+	refusedTotal = metrics.NewCounterVec("ariane_event_refused_total",
+		"Events refused with a retriable status, by reason: paused or saturated.",
+		"reason")
+)
+
+// reasons events are refused for, see Tracker.Refuse
+const (
+	RefusedPaused    = "paused"
+	RefusedSaturated = "saturated"
+)
+
+// Thresholds are the values past which the load is logged as a warning, each disabled if zero
+type Thresholds struct {
+	QueueDepth int
+	EventAge   time.Duration
+	// Utilization is the average utilization of the workers, between 0 and 1
+	Utilization float64
+}
+
+// worker is a slot handling one event at a time
+type worker struct {
+	busySince time.Time
+	// busy is the time spent handling the events which completed since the last sample
+	busy time.Duration
+}
+
+// Tracker tracks the events being handled. Each event occupies the first idle worker slot until handled.
+// A nil Tracker is valid and tracks nothing.
+type Tracker struct {
+	Thresholds Thresholds
+	Logger     zerolog.Logger
+	Scheduler  scheduler.Scheduler
+	// MaxQueueDepth is the queue depth from which new events are refused, see Refuse, disabled if zero
+	MaxQueueDepth int
+
+	mu       sync.Mutex
+	received map[int]time.Time
+	workers  []worker
+	// queued are the events waiting for a worker of the event queue, if any, see Queue
+	queued     map[uint64]time.Time
+	nextQueued uint64
+	// paused refuses all new events, until resumed
+	paused bool
+	// lastSample starts the interval measured by the next sample, set by the first event or sample
+	lastSample time.Time
+}
+
+func NewTracker(thresholds Thresholds, logger zerolog.Logger) *Tracker {
+	return &Tracker{Thresholds: thresholds, Logger: logger, received: map[int]time.Time{}, queued: map[uint64]time.Time{}}
+}
+
+// Queue records an event received and queued, counted in the queue depth until the returned function is called
+// once a worker starts handling it, see Start
+func (t *Tracker) Queue() func() {
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := t.nextQueued
+	t.nextQueued++
+	t.queued[id] = t.Scheduler.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.queued, id)
+	}
+}
+
+// depth returns the number of events received and not handled yet
+func (t *Tracker) depth() int {
+	return len(t.received) + len(t.queued)
+}
+
+// Start records an event received, and returns the function to call once it is handled
+func (t *Tracker) Start() func() {
+	if t == nil {
+		return func() {}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Scheduler.Now()
+	if t.lastSample.IsZero() {
+		t.lastSample = now
+	}
+	slot := len(t.workers)
+	for i, w := range t.workers {
+		if w.busySince.IsZero() {
+			slot = i
+			break
+		}
+	}
+	if slot == len(t.workers) {
+		t.workers = append(t.workers, worker{})
+	}
+	t.workers[slot].busySince = now
+	t.received[slot] = now
+
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		now := t.Scheduler.Now()
+		w := &t.workers[slot]
+		w.busy += now.Sub(laterOf(w.busySince, t.lastSample))
+		w.busySince = time.Time{}
+		delete(t.received, slot)
+	}
+}
+
+func laterOf(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// Sample is a snapshot of the load
+type Sample struct {
+	QueueDepth int
+	// OldestEventAge is zero if there are no events
+	OldestEventAge time.Duration
+	// Utilization is the fraction of the interval since the previous sample each worker was busy
+	Utilization []float64
+}
+
+// AverageUtilization returns the average utilization of the workers, zero if there are none
+func (s Sample) AverageUtilization() float64 {
+	if len(s.Utilization) == 0 {
+		return 0
+	}
+	var total float64
+	for _, u := range s.Utilization {
+		total += u
+	}
+	return total / float64(len(s.Utilization))
+}
+
+// Sample measures the load since the previous sample, updating the metrics
+func (t *Tracker) Sample() Sample {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.Scheduler.Now()
+	if t.lastSample.IsZero() {
+		t.lastSample = now
+	}
+	interval := now.Sub(t.lastSample)
+
+	sample := Sample{QueueDepth: t.depth(), Utilization: make([]float64, len(t.workers))}
+	for _, receivedAt := range t.received {
+		sample.OldestEventAge = max(sample.OldestEventAge, now.Sub(receivedAt))
+	}
+	for _, queuedAt := range t.queued {
+		sample.OldestEventAge = max(sample.OldestEventAge, now.Sub(queuedAt))
+	}
+	for i := range t.workers {
+		w := &t.workers[i]
+		busy := w.busy
+		if !w.busySince.IsZero() {
+			busy += now.Sub(laterOf(w.busySince, t.lastSample))
+		}
+		w.busy = 0
+		if interval > 0 {
+			sample.Utilization[i] = min(float64(busy)/float64(interval), 1)
+		}
+	}
+	t.lastSample = now
+
+	queueDepth.Set(float64(sample.QueueDepth))
+	oldestEventAge.Set(sample.OldestEventAge.Seconds())
+	for i, u := range sample.Utilization {
+		workerUtilization.Set(u, strconv.Itoa(i))
+	}
+	return sample
+}
+
+// warn logs the values of a sample past the thresholds
+func (t *Tracker) warn(sample Sample) {
+	if t.Thresholds.QueueDepth > 0 && sample.QueueDepth > t.Thresholds.QueueDepth {
+		t.Logger.Warn().Msgf("Event queue depth %d is over %d", sample.QueueDepth, t.Thresholds.QueueDepth)
+	}
+	if t.Thresholds.EventAge > 0 && sample.OldestEventAge > t.Thresholds.EventAge {
+		t.Logger.Warn().Msgf("Oldest event received %s ago, over %s", sample.OldestEventAge.Round(time.Millisecond), t.Thresholds.EventAge)
+	}
+	if utilization := sample.AverageUtilization(); t.Thresholds.Utilization > 0 && utilization > t.Thresholds.Utilization {
+		t.Logger.Warn().Msgf("Average worker utilization %.2f is over %.2f", utilization, t.Thresholds.Utilization)
+	}
+}
+
+// SetPaused pauses or resumes the intake of new events
+func (t *Tracker) SetPaused(paused bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.paused = paused
+}
+
+// Paused reports whether the intake of new events is paused
+func (t *Tracker) Paused() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.paused
+}
+
+// Refuse returns the reason a new event must be refused for, RefusedPaused or RefusedSaturated, or an empty
+// string if it can be handled. Refused events are counted in the ariane_event_refused_total metric.
+func (t *Tracker) Refuse() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var reason string
+	switch {
+	case t.paused:
+		reason = RefusedPaused
+	case t.MaxQueueDepth > 0 && t.depth() >= t.MaxQueueDepth:
+		reason = RefusedSaturated
+	default:
+		return ""
+	}
+	refusedTotal.Inc(reason)
+	return reason
+}
+
+// Run samples the load every interval, warning about the values past the thresholds, until ctx is done or the
+// returned function is called
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) context.CancelFunc {
+	return t.Scheduler.Every(ctx, interval, func(context.Context) {
+		t.warn(t.Sample())
+	})
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package load_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+func TestTracker(t *testing.T) {
+	var logs bytes.Buffer
+	clock := scheduler.NewFakeClock(time.Now())
+	tracker := load.NewTracker(load.Thresholds{QueueDepth: 1, EventAge: 20 * time.Second, Utilization: 0.5}, zerolog.New(&logs))
+	tracker.Scheduler.Clock = clock
+
+	first := tracker.Start()
+	clock.Advance(5 * time.Second)
+	second := tracker.Start()
+	clock.Advance(5 * time.Second)
+	first()
+	third := tracker.Start()
+
+	sample := tracker.Sample()
+	assert.Equal(t, 2, sample.QueueDepth)
+	assert.Equal(t, 5*time.Second, sample.OldestEventAge)
+	assert.Equal(t, []float64{1, 0.5}, sample.Utilization, "idle workers are reused")
+
+	clock.Advance(10 * time.Second)
+	second()
+	third()
+	clock.Advance(10 * time.Second)
+	sample = tracker.Sample()
+	assert.Equal(t, 0, sample.QueueDepth)
+	assert.Equal(t, time.Duration(0), sample.OldestEventAge)
+	assert.Equal(t, []float64{0.5, 0.5}, sample.Utilization, "utilization only covers the interval since the previous sample")
+	assert.Equal(t, 0.5, sample.AverageUtilization())
+
+	var nilTracker *load.Tracker
+	nilTracker.Start()()
+}
+
+func TestTrackerWarnings(t *testing.T) {
+	var logs bytes.Buffer
+	clock := scheduler.NewFakeClock(time.Now())
+	tracker := load.NewTracker(load.Thresholds{QueueDepth: 1, EventAge: 20 * time.Second, Utilization: 0.5}, zerolog.New(&logs))
+	tracker.Scheduler.Clock = clock
+
+	cancel := tracker.Run(t.Context(), 30*time.Second)
+	defer cancel()
+	tracker.Start()
+	tracker.Start()
+	clock.BlockUntil(1)
+	clock.Advance(30 * time.Second)
+	// the next sample waits once the previous one completed
+	clock.BlockUntil(1)
+	assert.Contains(t, logs.String(), "Event queue depth 2 is over 1")
+	assert.Contains(t, logs.String(), "Oldest event received 30s ago, over 20s")
+	assert.Contains(t, logs.String(), "Average worker utilization 1.00 is over 0.50")
+}
+
+func TestTrackerRefuse(t *testing.T) {
+	tracker := load.NewTracker(load.Thresholds{}, zerolog.Nop())
+	tracker.MaxQueueDepth = 2
+
+	first := tracker.Start()
+	assert.Empty(t, tracker.Refuse())
+	second := tracker.Start()
+	assert.Equal(t, load.RefusedSaturated, tracker.Refuse())
+	first()
+	assert.Empty(t, tracker.Refuse())
+
+	tracker.SetPaused(true)
+	assert.True(t, tracker.Paused())
+	assert.Equal(t, load.RefusedPaused, tracker.Refuse())
+	tracker.SetPaused(false)
+	assert.Empty(t, tracker.Refuse())
+	second()
+
+	var nilTracker *load.Tracker
+	assert.Empty(t, nilTracker.Refuse())
+}
+
+func TestTrackerQueue(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Now())
+	tracker := load.NewTracker(load.Thresholds{}, zerolog.Nop())
+	tracker.Scheduler.Clock = clock
+	tracker.MaxQueueDepth = 2
+
+	// queued events count in the queue depth without occupying a worker
+	dequeued := tracker.Queue()
+	clock.Advance(5 * time.Second)
+	done := tracker.Start()
+	assert.Equal(t, load.RefusedSaturated, tracker.Refuse())
+	sample := tracker.Sample()
+	assert.Equal(t, 2, sample.QueueDepth)
+	assert.Equal(t, 5*time.Second, sample.OldestEventAge)
+	assert.Len(t, sample.Utilization, 1)
+
+	dequeued()
+	assert.Empty(t, tracker.Refuse())
+	done()
+	assert.Equal(t, 0, tracker.Sample().QueueDepth)
+
+	var nilTracker *load.Tracker
+	nilTracker.Queue()()
+}
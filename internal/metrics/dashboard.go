@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package metrics
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// DashboardUID identifies the generated dashboard in Grafana, so importing it again replaces it
+	DashboardUID = "ariane"
+	// dashboardRateInterval is the range counters are turned into rates over
+	dashboardRateInterval = "$__rate_interval"
+	panelWidth            = 12
+	panelHeight           = 8
+)
+
+// Dashboard is a Grafana dashboard, with the subset of the dashboard JSON model Ariane generates
+type Dashboard struct {
+	UID           string     `json:"uid"`
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Refresh       string     `json:"refresh"`
+	Time          TimeRange  `json:"time"`
+	Templating    Templating `json:"templating"`
+	Panels        []Panel    `json:"panels"`
+}
+
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type Templating struct {
+	List []Variable `json:"list"`
+}
+
+// Variable is a dashboard variable, the Prometheus datasource queried by the panels
+type Variable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+// Panel is a time series panel graphing one metric
+type Panel struct {
+	ID          int        `json:"id"`
+	Type        string     `json:"type"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Datasource  Datasource `json:"datasource"`
+	GridPos     GridPos    `json:"gridPos"`
+	Targets     []Target   `json:"targets"`
+}
+
+type Datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type GridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type Target struct {
+	RefID        string     `json:"refId"`
+	Datasource   Datasource `json:"datasource"`
+	Expr         string     `json:"expr"`
+	LegendFormat string     `json:"legendFormat"`
+}
+
+// panelQuery returns the query graphing a metric, broken down by its first label: the rate of counters, and the
+// value of gauges
+func (v *Vec) panelQuery() (expr, legend string) {
+	expr = v.name
+	if v.kind == "counter" {
+		expr = fmt.Sprintf("rate(%s[%s])", v.name, dashboardRateInterval)
+	}
+	if len(v.labels) == 0 {
+		return "sum(" + expr + ")", v.name
+	}
+	return fmt.Sprintf("sum by (%s) (%s)", v.labels[0], expr), "{{" + v.labels[0] + "}}"
+}
+
+// Dashboard generates a Grafana dashboard with a panel for each metric of the registry, sorted by name, so the
+// dashboard follows the metrics as they are added
+func (r *Registry) Dashboard() Dashboard {
+	r.mu.Lock()
+	metrics := make([]*Vec, 0, len(r.metrics))
+	for _, v := range r.metrics {
+		metrics = append(metrics, v)
+	}
+	r.mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+
+	datasource := Datasource{Type: "prometheus", UID: "${datasource}"}
+	dashboard := Dashboard{
+		UID:           DashboardUID,
+		Title:         "Ariane",
+		Tags:          []string{"ariane"},
+		SchemaVersion: 39,
+		Refresh:       "1m",
+		Time:          TimeRange{From: "now-24h", To: "now"},
+		Templating:    Templating{List: []Variable{{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"}}},
+		Panels:        make([]Panel, 0, len(metrics)),
+	}
+	for i, v := range metrics {
+		expr, legend := v.panelQuery()
+		dashboard.Panels = append(dashboard.Panels, Panel{
+			ID:          i + 1,
+			Type:        "timeseries",
+			Title:       v.name,
+			Description: v.help,
+			Datasource:  datasource,
+			GridPos:     GridPos{H: panelHeight, W: panelWidth, X: (i % 2) * panelWidth, Y: (i / 2) * panelHeight},
+			Targets:     []Target{{RefID: "A", Datasource: datasource, Expr: expr, LegendFormat: legend}},
+		})
+	}
+	return dashboard
+}
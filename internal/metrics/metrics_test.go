@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package metrics
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	decisions := r.register("test_decisions_total", "Decisions.", "counter", []string{"step", "reason"})
+	queued := r.register("test_queued", "Queued events.", "gauge", nil)
+
+	decisions.Inc("run", "paths_matched")
+	decisions.Add(2, "run", "paths_matched")
+	decisions.Inc("skip", `quoted "reason"`)
+	queued.Set(4)
+
+	assert.Equal(t, float64(3), decisions.Value("run", "paths_matched"))
+	assert.Panics(t, func() { decisions.Inc("run") }, "label values must match labels")
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.Write(&buf))
+	assert.Equal(t, `# HELP test_decisions_total Decisions.
+# TYPE test_decisions_total counter
+test_decisions_total{step="run",reason="paths_matched"} 3
+test_decisions_total{step="skip",reason="quoted \"reason\""} 1
+# HELP test_queued Queued events.
+# TYPE test_queued gauge
+test_queued 4
+`, buf.String())
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	r := NewRegistry()
+	avoided := r.register("test_avoided_total", "Avoided dispatches.", "counter", []string{"workflow"})
+
+	snapshot, err := NewSnapshot(path, avoided)
+	assert.NoError(t, err)
+	avoided.Add(2, "foo.yaml")
+	assert.NoError(t, snapshot.Save())
+
+	// values are restored by a new process
+	restarted := NewRegistry().register("test_avoided_total", "Avoided dispatches.", "counter", []string{"workflow"})
+	_, err = NewSnapshot(path, restarted)
+	assert.NoError(t, err)
+	restarted.Inc("foo.yaml")
+	assert.Equal(t, float64(3), restarted.Value("foo.yaml"))
+}
+
+func TestRegistryDashboard(t *testing.T) {
+	r := NewRegistry()
+	r.register("test_queued", "Queued events.", "gauge", nil)
+	r.register("test_decisions_total", "Decisions.", "counter", []string{"step", "reason"})
+
+	dashboard := r.Dashboard()
+	assert.Equal(t, DashboardUID, dashboard.UID)
+	if assert.Len(t, dashboard.Panels, 2) {
+		decisions := dashboard.Panels[0]
+		assert.Equal(t, "test_decisions_total", decisions.Title)
+		assert.Equal(t, "Decisions.", decisions.Description)
+		assert.Equal(t, GridPos{H: 8, W: 12, X: 0, Y: 0}, decisions.GridPos)
+		assert.Equal(t, "sum by (step) (rate(test_decisions_total[$__rate_interval]))", decisions.Targets[0].Expr)
+		assert.Equal(t, "{{step}}", decisions.Targets[0].LegendFormat)
+
+		queued := dashboard.Panels[1]
+		assert.Equal(t, GridPos{H: 8, W: 12, X: 12, Y: 0}, queued.GridPos)
+		assert.Equal(t, "sum(test_queued)", queued.Targets[0].Expr)
+	}
+}
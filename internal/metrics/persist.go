@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultSaveInterval = time.Minute
+
+// Snapshot persists the values of metrics to a JSON file, so they survive restarts
+type Snapshot struct {
+	mu   sync.Mutex
+	path string
+	vecs []*Vec
+}
+
+type sample struct {
+	Labels []string `json:"labels"`
+	Value  float64  `json:"value"`
+}
+
+// NewSnapshot creates a snapshot of the given metrics persisted to path, restoring the values already saved
+func NewSnapshot(path string, vecs ...*Vec) (*Snapshot, error) {
+	s := &Snapshot{path: path, vecs: vecs}
+
+	bytes, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed reading metrics snapshot: %w", err)
+	}
+	var saved map[string][]sample
+	if err := json.Unmarshal(bytes, &saved); err != nil {
+		return nil, fmt.Errorf("failed parsing metrics snapshot: %w", err)
+	}
+	for _, v := range vecs {
+		v.mu.Lock()
+		for _, sample := range saved[v.name] {
+			// skip samples saved with other labels, e.g. by a previous version
+			if len(sample.Labels) == len(v.labels) {
+				v.values[strings.Join(sample.Labels, "\x00")] = sample.Value
+			}
+		}
+		v.mu.Unlock()
+	}
+	return s, nil
+}
+
+// Save writes the current values of the metrics to the snapshot file
+func (s *Snapshot) Save() error {
+	saved := map[string][]sample{}
+	for _, v := range s.vecs {
+		v.mu.Lock()
+		samples := make([]sample, 0, len(v.values))
+		for key, value := range v.values {
+			samples = append(samples, sample{Labels: strings.Split(key, "\x00"), Value: value})
+		}
+		v.mu.Unlock()
+		saved[v.name] = samples
+	}
+	bytes, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// write then rename, so a crash never leaves a truncated snapshot
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, bytes, 0o640); err != nil {
+		return fmt.Errorf("failed writing metrics snapshot: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Run saves the snapshot every interval until ctx is done, and once more then
+func (s *Snapshot) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Save(); err != nil {
+				onError(err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.Save(); err != nil {
+				onError(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package notify signs the payloads Ariane sends to outbound webhooks, so receivers can authenticate Ariane as their
+// source the way they authenticate GitHub webhooks.
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SignatureHeader carries the signature of the payload, like X-Hub-Signature-256 for GitHub webhooks
+	SignatureHeader = "X-Ariane-Signature-256"
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the HMAC-SHA256 signature of payload with secret, as "sha256=<hex digest>"
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of payload with secret, comparing them in constant time
+func Verify(secret string, payload []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, payload)), []byte(signature))
+}
+
+// SignRequest sets the signature header of an outbound request sending payload, unless secret is empty
+func SignRequest(r *http.Request, secret string, payload []byte) {
+	if secret == "" {
+		return
+	}
+	r.Header.Set(SignatureHeader, Sign(secret, payload))
+}
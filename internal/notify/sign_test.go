@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package notify
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// the example of the GitHub documentation on validating webhook deliveries
+	signature := Sign("It's a Secret to Everybody", []byte("Hello, World!"))
+	assert.Equal(t, "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17", signature)
+
+	assert.True(t, Verify("It's a Secret to Everybody", []byte("Hello, World!"), signature))
+	assert.False(t, Verify("another secret", []byte("Hello, World!"), signature))
+	assert.False(t, Verify("It's a Secret to Everybody", []byte("Hello, World?"), signature))
+	assert.False(t, Verify("It's a Secret to Everybody", []byte("Hello, World!"), signature[len("sha256="):]))
+}
+
+func TestSignRequest(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+	SignRequest(r, "", []byte("{}"))
+	assert.Empty(t, r.Header.Get(SignatureHeader))
+
+	SignRequest(r, "secret", []byte("{}"))
+	assert.True(t, Verify("secret", []byte("{}"), r.Header.Get(SignatureHeader)))
+}
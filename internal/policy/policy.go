@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package policy evaluates the dispatch plans of trigger comments against an external policy engine, such as Open
+// Policy Agent, so security teams can allow, deny or narrow down dispatches without changing the decision code.
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds the evaluation of a dispatch plan
+	DefaultTimeout = 5 * time.Second
+)
+
+// Input is the dispatch plan of a trigger comment, which policies are evaluated over
+type Input struct {
+	// Repository is the "owner/repo" the trigger comment was posted in
+	Repository string `json:"repository"`
+	// Number is the pull request or issue the trigger comment was posted on
+	Number int  `json:"number"`
+	Issue  bool `json:"issue"`
+	// Author is the login of the comment author
+	Author string `json:"author"`
+	// Command is the trigger command, e.g. "/test"
+	Command string   `json:"command"`
+	Labels  []string `json:"labels"`
+	// Files are the files changed by the pull request, up to the pagination limit
+	Files      []string `json:"files"`
+	BaseBranch string   `json:"base_branch"`
+	// Ref and SHA are what the workflows are dispatched on
+	Ref string `json:"ref"`
+	SHA string `json:"sha"`
+	// Tag is set for tag triggers
+	Tag       string    `json:"tag,omitempty"`
+	Workflows []string  `json:"workflows"`
+	Time      time.Time `json:"time"`
+}
+
+// Result is the decision of a policy over a dispatch plan
+type Result struct {
+	// Allow runs the workflows of the plan, none being run otherwise
+	Allow bool `json:"allow"`
+	// Reason explains the decision to the comment author
+	Reason string `json:"reason"`
+	// Workflows, if set, narrows the workflows of the plan down to these, the others being skipped
+	Workflows []string `json:"workflows"`
+}
+
+// Engine evaluates dispatch plans with the data API of Open Policy Agent: the plan is posted as the input document
+// to URL, e.g. http://localhost:8181/v1/data/ariane/dispatch, and the result must be a Result document. Any server
+// implementing the same API can stand in for it.
+type Engine struct {
+	URL string
+	// Token is sent as a bearer token, if set
+	Token string
+	// Timeout bounds each evaluation, DefaultTimeout if zero
+	Timeout time.Duration
+	// HTTPClient posts the plans, http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Evaluate evaluates a dispatch plan. An undefined result, e.g. because the policy path does not exist, denies it.
+func (e *Engine) Evaluate(ctx context.Context, input Input) (Result, error) {
+	payload, err := json.Marshal(map[string]Input{"input": input})
+	if err != nil {
+		return Result{}, err
+	}
+	timeout := e.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(payload))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+e.Token)
+	}
+	httpClient := e.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to evaluate the dispatch policy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("failed to evaluate the dispatch policy: unexpected status %s", resp.Status)
+	}
+	var response struct {
+		Result *Result `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Result{}, fmt.Errorf("failed to decode the dispatch policy result: %w", err)
+	}
+	if response.Result == nil {
+		return Result{Reason: "the dispatch policy is undefined"}, nil
+	}
+	return *response.Result, nil
+}
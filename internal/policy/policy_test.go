@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngineEvaluate(t *testing.T) {
+	var input Input
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		var request struct {
+			Input Input `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		input = request.Input
+		switch r.URL.Path {
+		case "/v1/data/ariane/dispatch":
+			_, _ = w.Write([]byte(`{"result": {"allow": true, "workflows": ["foo.yaml"]}}`))
+		case "/v1/data/undefined":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	engine := &Engine{URL: server.URL + "/v1/data/ariane/dispatch", Token: "token"}
+	result, err := engine.Evaluate(context.Background(), Input{Repository: "owner/repo", Labels: []string{"area/ci"}})
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Allow: true, Workflows: []string{"foo.yaml"}}, result)
+	assert.Equal(t, "owner/repo", input.Repository)
+	assert.Equal(t, []string{"area/ci"}, input.Labels)
+
+	engine.URL = server.URL + "/v1/data/undefined"
+	result, err = engine.Evaluate(context.Background(), Input{})
+	assert.NoError(t, err)
+	assert.False(t, result.Allow)
+
+	engine.URL = server.URL + "/v1/data/failing"
+	_, err = engine.Evaluate(context.Background(), Input{})
+	assert.Error(t, err)
+}
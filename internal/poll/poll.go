@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package poll implements waiting on GitHub state, by polling it until a condition is met.
+package poll
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+const (
+	DefaultInterval = 5 * time.Second
+	DefaultTimeout  = 5 * time.Minute
+)
+
+var ErrTimeout = errors.New("timed out waiting for condition")
+
+// Condition reports whether the awaited state was reached. Returning an error stops polling.
+type Condition func(ctx context.Context) (bool, error)
+
+// Poller evaluates a condition every Interval until it is met, for at most Timeout.
+// A zero Interval defaults to DefaultInterval, a zero Timeout only stops on context cancellation.
+type Poller struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// Clock tells the time and waits between evaluations, scheduler.RealClock if nil
+	Clock scheduler.Clock
+}
+
+// Until evaluates the condition right away, then every interval, until it is met or returns an error.
+// It returns ErrTimeout if the timeout elapsed, or the context error if it was cancelled.
+func (p Poller) Until(ctx context.Context, condition Condition) error {
+	interval := p.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	clock := p.Clock
+	if clock == nil {
+		clock = scheduler.RealClock
+	}
+	var deadline time.Time
+	if p.Timeout > 0 {
+		deadline = clock.Now().Add(p.Timeout)
+	}
+
+	for {
+		done, err := condition(ctx)
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		wait := interval
+		if p.Timeout > 0 {
+			wait = min(wait, deadline.Sub(clock.Now()))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(wait):
+		}
+		if p.Timeout > 0 && !clock.Now().Before(deadline) {
+			return ErrTimeout
+		}
+	}
+}
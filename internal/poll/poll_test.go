@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package poll_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/poll"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+func TestPollerUntil(t *testing.T) {
+	poller := poll.Poller{Interval: time.Millisecond, Timeout: time.Second}
+
+	calls := 0
+	err := poller.Until(context.Background(), func(context.Context) (bool, error) {
+		calls++
+		return calls == 3, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	failure := errors.New("failure")
+	err = poller.Until(context.Background(), func(context.Context) (bool, error) { return false, failure })
+	assert.ErrorIs(t, err, failure)
+
+	poller.Timeout = 10 * time.Millisecond
+	err = poller.Until(context.Background(), func(context.Context) (bool, error) { return false, nil })
+	assert.ErrorIs(t, err, poll.ErrTimeout)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	poller.Timeout = 0
+	err = poller.Until(ctx, func(context.Context) (bool, error) { return false, nil })
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestPollerUntilFakeClock(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Now())
+	poller := poll.Poller{Interval: time.Minute, Timeout: 150 * time.Second, Clock: clock}
+
+	calls := 0
+	done := make(chan error)
+	go func() {
+		done <- poller.Until(context.Background(), func(context.Context) (bool, error) {
+			calls++
+			return false, nil
+		})
+	}()
+	for range 3 {
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+	}
+	assert.ErrorIs(t, <-done, poll.ErrTimeout)
+	assert.Equal(t, 3, calls, "the condition is evaluated every interval until the timeout elapses")
+}
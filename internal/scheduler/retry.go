@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Backoff configures retries with an exponential backoff
+type Backoff struct {
+	// Initial is the delay before the first retry, doubled on each subsequent retry
+	Initial time.Duration
+	// Max caps the delay between retries, unbounded if zero
+	Max time.Duration
+	// Attempts is the number of attempts including the first one, at least one
+	Attempts int
+}
+
+// Delay returns the delay before the given retry, the first retry being the second attempt
+func (b Backoff) Delay(retry int) time.Duration {
+	delay := b.Initial
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if b.Max > 0 && delay >= b.Max {
+			return b.Max
+		}
+	}
+	if b.Max > 0 && delay > b.Max {
+		return b.Max
+	}
+	return delay
+}
+
+// stopError stops retrying, see Stop
+type stopError struct {
+	err error
+}
+
+func (e stopError) Error() string {
+	return e.err.Error()
+}
+
+// Stop wraps the error of an attempt to stop retrying, e.g. as retrying cannot fix it. Retry returns err itself.
+func Stop(err error) error {
+	return stopError{err: err}
+}
+
+// Retry calls fn until it succeeds or all attempts failed, waiting on the clock between attempts, and returns
+// the error of the last attempt. It stops retrying once ctx is done, or fn returns an error wrapped with Stop.
+// attempt counts from 1.
+func Retry(ctx context.Context, clock Clock, backoff Backoff, fn func(ctx context.Context, attempt int) error) error {
+	if clock == nil {
+		clock = RealClock
+	}
+	attempts := max(backoff.Attempts, 1)
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(ctx, attempt); err == nil {
+			return nil
+		}
+		var stop stopError
+		if errors.As(err, &stop) {
+			return stop.err
+		}
+		if attempt == attempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-clock.After(backoff.Delay(attempt)):
+		}
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package scheduler runs background work: delayed jobs, periodic jobs, and retries with an exponential backoff,
+// all waiting on a Clock so they can be tested with a fake one.
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Job is work run in the background, which should return once ctx is done
+type Job func(ctx context.Context)
+
+// Scheduler runs jobs in the background and keeps track of them. The zero value is ready to use, on the RealClock.
+type Scheduler struct {
+	// Clock tells the time and waits for the delays of jobs, RealClock if nil
+	Clock Clock
+	// Foreground runs the jobs of Go in the caller rather than in the background, e.g. under AWS Lambda, which
+	// freezes the background work once the response of an invocation is returned
+	Foreground bool
+
+	wg sync.WaitGroup
+}
+
+func (s *Scheduler) clock() Clock {
+	if s.Clock == nil {
+		return RealClock
+	}
+	return s.Clock
+}
+
+// Now returns the current time of the scheduler clock
+func (s *Scheduler) Now() time.Time {
+	return s.clock().Now()
+}
+
+// After runs the job in the background once delay elapsed. The job is dropped if ctx is done or the returned
+// function is called before then, and its context is cancelled if they are afterwards. Jobs which must outlive
+// the caller should be given a context detached from its cancellation.
+func (s *Scheduler) After(ctx context.Context, delay time.Duration, job Job) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		if delay > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock().After(delay):
+			}
+		}
+		job(ctx)
+	}()
+	return cancel
+}
+
+// Go runs the job in the background right away, or in the caller if Foreground is set
+func (s *Scheduler) Go(ctx context.Context, job Job) context.CancelFunc {
+	if s.Foreground {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		job(ctx)
+		return cancel
+	}
+	return s.After(ctx, 0, job)
+}
+
+// Every runs the job in the background every interval, until ctx is done or the returned function is called.
+// Runs do not overlap: the next interval starts once the job returned.
+func (s *Scheduler) Every(ctx context.Context, interval time.Duration, job Job) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock().After(interval):
+				job(ctx)
+			}
+		}
+	}()
+	return cancel
+}
+
+// Wait waits for the jobs running or scheduled to return
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+func TestSchedulerAfter(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Now())
+	s := &scheduler.Scheduler{Clock: clock}
+
+	var runs atomic.Int32
+	s.After(context.Background(), time.Minute, func(context.Context) { runs.Add(1) })
+	clock.BlockUntil(1)
+	clock.Advance(59 * time.Second)
+	assert.Equal(t, int32(0), runs.Load(), "jobs do not run before their delay elapsed")
+	clock.Advance(time.Second)
+	s.Wait()
+	assert.Equal(t, int32(1), runs.Load())
+
+	cancel := s.After(context.Background(), time.Minute, func(context.Context) { runs.Add(1) })
+	clock.BlockUntil(1)
+	cancel()
+	s.Wait()
+	assert.Equal(t, int32(1), runs.Load(), "cancelled jobs are dropped")
+}
+
+func TestSchedulerForeground(t *testing.T) {
+	s := &scheduler.Scheduler{Foreground: true}
+
+	var runs int
+	s.Go(context.Background(), func(ctx context.Context) {
+		assert.NoError(t, ctx.Err())
+		runs++
+	})
+	assert.Equal(t, 1, runs, "jobs run in the caller")
+}
+
+func TestSchedulerEvery(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Now())
+	s := &scheduler.Scheduler{Clock: clock}
+
+	runs := make(chan struct{})
+	cancel := s.Every(context.Background(), time.Minute, func(context.Context) { runs <- struct{}{} })
+	for range 3 {
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		<-runs
+	}
+	cancel()
+	s.Wait()
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := scheduler.Backoff{Initial: time.Second, Max: 5 * time.Second}
+	assert.Equal(t, time.Second, backoff.Delay(1))
+	assert.Equal(t, 2*time.Second, backoff.Delay(2))
+	assert.Equal(t, 4*time.Second, backoff.Delay(3))
+	assert.Equal(t, 5*time.Second, backoff.Delay(4), "delays are capped")
+}
+
+func TestRetry(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Now())
+	start := clock.Now()
+	failure := errors.New("failure")
+
+	var attempts []time.Duration
+	done := make(chan error)
+	go func() {
+		done <- scheduler.Retry(context.Background(), clock, scheduler.Backoff{Initial: time.Second, Attempts: 3}, func(_ context.Context, attempt int) error {
+			attempts = append(attempts, clock.Now().Sub(start))
+			return failure
+		})
+	}()
+	for range 2 {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}
+	// the second retry waits 2s, it is not due after the first second
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	assert.ErrorIs(t, <-done, failure)
+	assert.Equal(t, []time.Duration{0, time.Second, 3 * time.Second}, attempts)
+
+	calls := 0
+	err := scheduler.Retry(context.Background(), clock, scheduler.Backoff{Initial: time.Second, Attempts: 3}, func(context.Context, int) error {
+		calls++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls, "successful attempts are not retried")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = scheduler.Retry(ctx, clock, scheduler.Backoff{Initial: time.Second, Attempts: 3}, func(context.Context, int) error {
+		calls++
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	assert.Equal(t, 1, calls, "retries stop once the context is done")
+}
+
+func TestRetryStop(t *testing.T) {
+	failure := errors.New("failure")
+	calls := 0
+	err := scheduler.Retry(context.Background(), nil, scheduler.Backoff{Initial: time.Hour, Attempts: 3}, func(context.Context, int) error {
+		calls++
+		return scheduler.Stop(failure)
+	})
+	assert.Equal(t, failure, err, "the stopping error is returned unwrapped")
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/metrics"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+const (
+	// maxRefusedDeliveries bounds how many refused deliveries are remembered until they are caught up with
+	maxRefusedDeliveries = 10000
+	// catchUpMaxPages bounds how many pages of the deliveries of the app are looked through for the refused ones
+	catchUpMaxPages = 50
+)
+
+var redeliveredTotal = metrics.NewCounterVec("ariane_webhook_redelivered_total",
+	"Webhooks refused under back pressure and redelivered once the intake recovered, by event type.",
+	"event")
+
+// catchUp redelivers the webhooks refused under back pressure once the intake recovered, through the webhook
+// deliveries API of the app, as GitHub does not redeliver failed deliveries by itself. Only the deliveries refused
+// by this replica are redelivered, and only if none of their attempts succeeded meanwhile.
+type catchUp struct {
+	// newAppClient returns a client authenticated as the app, which lists and redelivers its webhook deliveries
+	newAppClient func() (*github.Client, error)
+	tracker      *load.Tracker
+	logger       zerolog.Logger
+	// Scheduler runs the catch-ups
+	Scheduler scheduler.Scheduler
+
+	mu sync.Mutex
+	// refused maps the delivery IDs refused since the last catch-up to when they were refused
+	refused map[string]time.Time
+}
+
+func newCatchUp(newAppClient func() (*github.Client, error), tracker *load.Tracker, logger zerolog.Logger) *catchUp {
+	return &catchUp{newAppClient: newAppClient, tracker: tracker, logger: logger, refused: map[string]time.Time{}}
+}
+
+// refuse remembers a delivery refused under back pressure, to redeliver it once the intake recovered
+func (c *catchUp) refuse(deliveryID string) {
+	if deliveryID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.refused[deliveryID]; !ok && len(c.refused) >= maxRefusedDeliveries {
+		c.logger.Warn().Str("delivery_id", deliveryID).Msg("Too many refused deliveries, the delivery will not be redelivered")
+		return
+	}
+	c.refused[deliveryID] = c.Scheduler.Now()
+}
+
+// Run catches up with the refused deliveries every interval, until ctx is done or the returned function is called
+func (c *catchUp) Run(ctx context.Context, interval time.Duration) context.CancelFunc {
+	return c.Scheduler.Every(ctx, interval, c.redeliver)
+}
+
+// redeliver looks for the refused deliveries among the latest deliveries of the app, and redelivers them, unless
+// the intake is still refusing events. The deliveries which cannot be found are forgotten.
+func (c *catchUp) redeliver(ctx context.Context) {
+	c.mu.Lock()
+	refused := maps.Clone(c.refused)
+	c.mu.Unlock()
+	if len(refused) == 0 || c.tracker.Refuse() != "" {
+		return
+	}
+	since := c.Scheduler.Now()
+	for _, at := range refused {
+		if at.Before(since) {
+			since = at
+		}
+	}
+
+	client, err := c.newAppClient()
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to create app client to redeliver refused deliveries")
+		return
+	}
+	// the newest attempt of each refused delivery, unless one of its attempts succeeded
+	attempts := map[string]*github.HookDelivery{}
+	delivered := map[string]bool{}
+	opts := &github.ListCursorOptions{PerPage: 100}
+	for page := 0; page < catchUpMaxPages; page++ {
+		deliveries, response, err := client.Apps.ListHookDeliveries(ctx, opts)
+		if err != nil {
+			c.logger.Error().Err(err).Msg("Failed to list the webhook deliveries of the app")
+			return
+		}
+		// most recent deliveries come first
+		older := false
+		for _, delivery := range deliveries {
+			if delivery.GetDeliveredAt().Before(since.Add(-time.Minute)) {
+				older = true
+				break
+			}
+			guid := delivery.GetGUID()
+			if _, ok := refused[guid]; !ok {
+				continue
+			}
+			if code := delivery.GetStatusCode(); code >= 200 && code < 300 {
+				delivered[guid] = true
+			} else if _, ok := attempts[guid]; !ok {
+				attempts[guid] = delivery
+			}
+		}
+		if older || response.Cursor == "" {
+			break
+		}
+		opts.Cursor = response.Cursor
+	}
+
+	caughtUp := map[string]bool{}
+	for guid := range refused {
+		attempt, ok := attempts[guid]
+		switch {
+		case delivered[guid]:
+			caughtUp[guid] = true
+		case !ok:
+			c.logger.Warn().Str("delivery_id", guid).Msg("Refused delivery not found among the deliveries of the app, it will not be redelivered")
+			caughtUp[guid] = true
+		default:
+			// GitHub accepts redeliveries with a 202 status, which go-github reports as an error
+			var accepted *github.AcceptedError
+			if _, _, err := client.Apps.RedeliverHookDelivery(ctx, attempt.GetID()); err != nil && !errors.As(err, &accepted) {
+				c.logger.Error().Err(err).Str("delivery_id", guid).Msg("Failed to redeliver refused delivery")
+				continue
+			}
+			c.logger.Info().Str("delivery_id", guid).Str("event", attempt.GetEvent()).Msg("Redelivered refused delivery")
+			redeliveredTotal.Inc(attempt.GetEvent())
+			caughtUp[guid] = true
+		}
+	}
+	// the deliveries refused again meanwhile are caught up with next time
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for guid := range caughtUp {
+		if c.refused[guid].Equal(refused[guid]) {
+			delete(c.refused, guid)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/gregjones/httpcache"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/budget"
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/metrics"
+)
+
+var (
+	githubRequestsTotal = metrics.NewCounterVec("ariane_github_requests_total",
+		"GitHub API requests made by the installation clients, by installation, organization and status class.", "installation", "org", "status")
+	githubCachedRequestsTotal = metrics.NewCounterVec("ariane_github_cached_requests_total",
+		"GitHub API requests of the installation clients answered from the client cache.", "installation", "org")
+	githubRateLimit = metrics.NewGaugeVec("ariane_github_rate_limit",
+		"Requests allowed per hour to each installation, by rate limit resource.", "installation", "org", "resource")
+	githubRateRemaining = metrics.NewGaugeVec("ariane_github_rate_remaining",
+		"Requests remaining in the current rate limit window of each installation.", "installation", "org", "resource")
+	githubRateUsed = metrics.NewGaugeVec("ariane_github_rate_used",
+		"Requests used in the current rate limit window of each installation.", "installation", "org", "resource")
+	githubRateReset = metrics.NewGaugeVec("ariane_github_rate_reset_timestamp_seconds",
+		"Time the current rate limit window of each installation resets at.", "installation", "org", "resource")
+)
+
+// accountLookupTimeout bounds looking up the account of an installation
+const accountLookupTimeout = 5 * time.Second
+
+// instrumentedClientCreator records the metrics of the requests of the installation clients, labelled with the
+// installation and the login of the organization (or user) it belongs to, looked up once per installation. The
+// requests are accounted for in the budget of their installation, and refused once it is used up.
+type instrumentedClientCreator struct {
+	githubapp.ClientCreator
+	logger zerolog.Logger
+	budget *budget.Store
+
+	mu       sync.Mutex
+	accounts map[int64]string
+}
+
+func newInstrumentedClientCreator(cc githubapp.ClientCreator, budgets *budget.Store, logger zerolog.Logger) *instrumentedClientCreator {
+	return &instrumentedClientCreator{ClientCreator: cc, logger: logger, budget: budgets, accounts: map[int64]string{}}
+}
+
+func (c *instrumentedClientCreator) NewInstallationClient(installationID int64) (*github.Client, error) {
+	client, err := c.ClientCreator.NewInstallationClient(installationID)
+	if err != nil {
+		return nil, err
+	}
+	// the transport of a client cannot be replaced, a new client is created around the instrumented one
+	httpClient := client.Client()
+	httpClient.Transport = instrumentTransport(httpClient.Transport, installationID, c.account(installationID), c.budget)
+	instrumented := github.NewClient(httpClient)
+	instrumented.BaseURL = client.BaseURL
+	instrumented.UploadURL = client.UploadURL
+	instrumented.UserAgent = client.UserAgent
+	return instrumented, nil
+}
+
+// account returns the login of the account of an installation, empty if it cannot be looked up, in which case it is
+// looked up again by the next client
+func (c *instrumentedClientCreator) account(installationID int64) string {
+	c.mu.Lock()
+	login, ok := c.accounts[installationID]
+	c.mu.Unlock()
+	if ok {
+		return login
+	}
+
+	appClient, err := c.NewAppClient()
+	if err != nil {
+		c.logger.Warn().Err(err).Int64(githubapp.LogKeyInstallationID, installationID).Msg("Failed to create app client to look up installation")
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), accountLookupTimeout)
+	defer cancel()
+	installation, _, err := appClient.Apps.GetInstallation(ctx, installationID)
+	if err != nil {
+		c.logger.Warn().Err(err).Int64(githubapp.LogKeyInstallationID, installationID).Msg("Failed to look up installation")
+		return ""
+	}
+
+	login = installation.GetAccount().GetLogin()
+	c.mu.Lock()
+	c.accounts[installationID] = login
+	c.mu.Unlock()
+	return login
+}
+
+// instrumentTransport records the metrics of the requests sent through next, see githubapp.ClientMetrics, and
+// accounts for them in the budget of the installation
+func instrumentTransport(next http.RoundTripper, installationID int64, org string, budgets *budget.Store) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	installation := strconv.FormatInt(installationID, 10)
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if err := budgets.Request(installationID, budget.Repository(r)); err != nil {
+			githubRequestsTotal.Inc(installation, org, "budget")
+			return nil, err
+		}
+		res, err := next.RoundTrip(r)
+		if res == nil {
+			githubRequestsTotal.Inc(installation, org, "error")
+			return res, err
+		}
+
+		githubRequestsTotal.Inc(installation, org, strconv.Itoa(res.StatusCode/100)+"xx")
+		logFailedRequest(r, res)
+		if res.Header.Get(httpcache.XFromCache) != "" {
+			githubCachedRequestsTotal.Inc(installation, org)
+			// cached responses repeat the rate limit headers of when they were fetched
+			return res, err
+		}
+		resource := res.Header.Get("X-RateLimit-Resource")
+		if resource == "" {
+			resource = "core"
+		}
+		for header, gauge := range map[string]*metrics.Vec{
+			"X-RateLimit-Limit":     githubRateLimit,
+			"X-RateLimit-Remaining": githubRateRemaining,
+			"X-RateLimit-Used":      githubRateUsed,
+			"X-RateLimit-Reset":     githubRateReset,
+		} {
+			if value, err := strconv.ParseInt(res.Header.Get(header), 10, 64); err == nil {
+				gauge.Set(float64(value), installation, org, resource)
+			}
+		}
+		return res, err
+	})
+}
+
+// logFailedRequest logs the failed GitHub API calls with their request ID, for escalations to GitHub support. Client
+// errors are routine, e.g. looking up files which do not exist, and only logged at debug level, unless they are
+// permission or rate limit failures.
+func logFailedRequest(r *http.Request, res *http.Response) {
+	if res.StatusCode < http.StatusBadRequest {
+		return
+	}
+	logger := zerolog.Ctx(r.Context())
+	event := logger.Debug()
+	switch res.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		event = logger.Warn()
+	default:
+		if res.StatusCode >= http.StatusInternalServerError {
+			event = logger.Warn()
+		}
+	}
+	event.Str("method", r.Method).Str("path", r.URL.Path).Int("status", res.StatusCode).
+		Str(failure.LogKeyRequestID, res.Header.Get(failure.RequestIDHeader)).
+		Msg("GitHub API request failed")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/gregjones/httpcache"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClientCreator creates clients of a test server
+type fakeClientCreator struct {
+	githubapp.ClientCreator
+	baseURL *url.URL
+}
+
+func (c *fakeClientCreator) NewAppClient() (*github.Client, error) {
+	client := github.NewClient(nil)
+	client.BaseURL = c.baseURL
+	return client, nil
+}
+
+func (c *fakeClientCreator) NewInstallationClient(int64) (*github.Client, error) {
+	return c.NewAppClient()
+}
+
+func Test_instrumentedClientCreator(t *testing.T) {
+	lookups := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /app/installations/{id}", func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		_ = json.NewEncoder(w).Encode(github.Installation{Account: &github.User{Login: github.Ptr("cilium")}})
+	})
+	mux.HandleFunc("GET /repos/cilium/cilium", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4990")
+		w.Header().Set("X-RateLimit-Resource", "core")
+		if r.URL.Query().Get("cached") != "" {
+			w.Header().Set(httpcache.XFromCache, "1")
+			w.Header().Set("X-RateLimit-Remaining", "4000")
+		}
+		_ = json.NewEncoder(w).Encode(github.Repository{})
+	})
+	mux.HandleFunc("GET /repos/cilium/missing", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	baseURL, _ := url.Parse(server.URL + "/")
+
+	cc := newInstrumentedClientCreator(&fakeClientCreator{baseURL: baseURL}, nil, zerolog.Nop())
+	ctx := context.Background()
+	for range 2 {
+		client, err := cc.NewInstallationClient(42)
+		assert.NoError(t, err)
+		assert.Equal(t, baseURL, client.BaseURL)
+		_, _, err = client.Repositories.Get(ctx, "cilium", "cilium")
+		assert.NoError(t, err)
+	}
+	client, _ := cc.NewInstallationClient(42)
+	_, _, _ = client.Repositories.Get(ctx, "cilium", "missing")
+	req, _ := client.NewRequest(http.MethodGet, "repos/cilium/cilium?cached=1", nil)
+	_, _ = client.Do(ctx, req, nil)
+
+	assert.Equal(t, 1, lookups, "the account of an installation is looked up once")
+	assert.Equal(t, float64(3), githubRequestsTotal.Value("42", "cilium", "2xx"))
+	assert.Equal(t, float64(1), githubRequestsTotal.Value("42", "cilium", "4xx"))
+	assert.Equal(t, float64(1), githubCachedRequestsTotal.Value("42", "cilium"))
+	assert.Equal(t, float64(5000), githubRateLimit.Value("42", "cilium", "core"))
+	assert.Equal(t, float64(4990), githubRateRemaining.Value("42", "cilium", "core"), "cached responses do not update the rate limits")
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/metrics"
+	"github.com/cilium/ariane/internal/state"
+)
+
+const (
+	// DefaultDeliveryCacheSize is how many delivery IDs are remembered in memory
+	DefaultDeliveryCacheSize = 10000
+	// DefaultDeliveryTTL is how long delivery IDs are remembered in a state store shared by replicas
+	DefaultDeliveryTTL = 24 * time.Hour
+
+	deliveryStatePrefix = "delivery/"
+)
+
+var duplicatesTotal = metrics.NewCounterVec("ariane_webhook_duplicates_total",
+	"Webhooks ignored as their delivery ID was already accepted, by event type.",
+	"event")
+
+// deliveries remembers the IDs of the webhook deliveries accepted
+type deliveries interface {
+	// claim records a delivery ID, and reports whether it was not recorded yet
+	claim(ctx context.Context, id string) bool
+	// release forgets a delivery ID, so the delivery can be redelivered
+	release(ctx context.Context, id string)
+}
+
+// lruDeliveries remembers the most recent delivery IDs in memory
+type lruDeliveries struct {
+	cache *lru.Cache
+}
+
+func newLRUDeliveries(size int) (*lruDeliveries, error) {
+	if size <= 0 {
+		size = DefaultDeliveryCacheSize
+	}
+	cache, err := lru.New(size)
+	if err != nil {
+		return nil, err
+	}
+	return &lruDeliveries{cache: cache}, nil
+}
+
+func (d *lruDeliveries) claim(_ context.Context, id string) bool {
+	found, _ := d.cache.ContainsOrAdd(id, struct{}{})
+	return !found
+}
+
+func (d *lruDeliveries) release(_ context.Context, id string) {
+	d.cache.Remove(id)
+}
+
+// stateDeliveries remembers the delivery IDs in a state store, so replicas sharing it deduplicate the deliveries
+// received by one another. Failures of the state store are logged, and the deliveries handled.
+type stateDeliveries struct {
+	state state.Store
+	ttl   time.Duration
+}
+
+func (d *stateDeliveries) claim(ctx context.Context, id string) bool {
+	added, err := d.state.Add(ctx, deliveryStatePrefix+id, []byte("{}"), d.ttl)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Failed to record delivery ID in the state store")
+		return true
+	}
+	return added
+}
+
+func (d *stateDeliveries) release(ctx context.Context, id string) {
+	if _, err := d.state.Delete(ctx, deliveryStatePrefix+id); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("Failed to forget delivery ID in the state store")
+	}
+}
+
+// statusRecorder records the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// deduplicateDeliveries answers the webhooks whose delivery ID was already accepted with 200 OK, without passing them
+// to next, so events delivered more than once by GitHub do not dispatch workflows twice. Deliveries answered with an
+// error status are forgotten, so they can still be redelivered once the failure is fixed.
+func deduplicateDeliveries(d deliveries, next http.Handler, logger zerolog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := github.DeliveryID(r)
+		if id == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := logger.With().
+			Str(githubapp.LogKeyEventType, github.WebHookType(r)).
+			Str(githubapp.LogKeyDeliveryID, id).
+			Logger()
+		ctx := logger.WithContext(r.Context())
+		if !d.claim(ctx, id) {
+			duplicatesTotal.Inc(github.WebHookType(r))
+			logger.Info().Msg("Ignoring webhook, its delivery ID was already accepted")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("Duplicate delivery ignored"))
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status >= http.StatusBadRequest {
+			d.release(ctx, id)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/state"
+)
+
+func Test_deduplicateDeliveries(t *testing.T) {
+	lruAccepted, err := newLRUDeliveries(2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for idx, accepted := range []deliveries{lruAccepted, &stateDeliveries{state: state.NewMemory(), ttl: time.Hour}} {
+		status := http.StatusOK
+		handled := 0
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled++
+			w.WriteHeader(status)
+		})
+		handler := deduplicateDeliveries(accepted, next, zerolog.Nop())
+		deliver := func(deliveryID string) int {
+			r := httptest.NewRequest(http.MethodPost, "/api/github/hook", bytes.NewReader([]byte(`{}`)))
+			r.Header.Set(github.EventTypeHeader, "issue_comment")
+			if deliveryID != "" {
+				r.Header.Set(github.DeliveryIDHeader, deliveryID)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusOK, deliver("first"), "[TEST%v]", idx+1)
+		assert.Equal(t, http.StatusOK, deliver("first"), "[TEST%v]", idx+1)
+		assert.Equal(t, 1, handled, "[TEST%v] redelivered webhooks are not handled again", idx+1)
+
+		status = http.StatusServiceUnavailable
+		assert.Equal(t, http.StatusServiceUnavailable, deliver("second"), "[TEST%v]", idx+1)
+		status = http.StatusOK
+		assert.Equal(t, http.StatusOK, deliver("second"), "[TEST%v]", idx+1)
+		assert.Equal(t, 3, handled, "[TEST%v] failed deliveries can be redelivered", idx+1)
+
+		deliver("")
+		deliver("")
+		assert.Equal(t, 5, handled, "[TEST%v] webhooks without delivery ID are always handled", idx+1)
+	}
+
+	// the least recently accepted delivery IDs are forgotten first
+	lruAccepted.claim(t.Context(), "third")
+	assert.True(t, lruAccepted.claim(t.Context(), "first"))
+}
@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/fakegithub"
+)
+
+const (
+	e2eWebhookSecret = "secret"
+	e2eInstallation  = 42
+	// e2eConfig is the Ariane config of the repository of the end-to-end tests
+	e2eConfig = `
+allowed-teams:
+  - organization-members
+queued-checks: true
+triggers:
+  /test:
+    workflows:
+      - foo.yaml
+  /lint:
+    workflows:
+      - bar.yaml
+`
+)
+
+// e2e drives webhooks through the handler built by New, as GitHub delivers them, against a fake GitHub holding a
+// repository with a pull request
+type e2e struct {
+	t          *testing.T
+	github     *fakegithub.Server
+	repo       *fakegithub.Repo
+	handler    *Server
+	deliveries int
+}
+
+func newE2E(t *testing.T) *e2e {
+	fake := fakegithub.New()
+	t.Cleanup(fake.Close)
+	repo := fake.AddRepo("owner", "repo")
+	repo.SetFile(config.ArianeConfigPath, e2eConfig)
+	repo.AddWorkflow("foo.yaml", "Foo")
+	repo.AddWorkflow("bar.yaml", "Bar")
+	repo.AddPullRequest(fakegithub.PullRequest{Number: 1, Author: "contributor", HeadRef: "feature", HeadSHA: "head-sha", Files: []string{"main.go"}})
+	fake.AddTeamMember("owner", "organization-members", "maintainer", "active")
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	serverConfig := &config.ServerConfig{
+		Poll:                  config.PollConfig{Interval: 10 * time.Millisecond, Timeout: 5 * time.Second},
+		DispatchVerifyTimeout: 5 * time.Second,
+		Retry:                 config.RetryConfig{Attempts: 1},
+		BotLogin:              fakegithub.DefaultBotLogin,
+	}
+	serverConfig.Github.V3APIURL = fake.URL + "/"
+	serverConfig.Github.V4APIURL = fake.URL + "/graphql"
+	serverConfig.Github.App.IntegrationID = 1
+	serverConfig.Github.App.WebhookSecret = e2eWebhookSecret
+	serverConfig.Github.App.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)}))
+	handler, err := New(serverConfig, zerolog.Nop())
+	assert.NoError(t, err)
+	return &e2e{t: t, github: fake, repo: repo, handler: handler}
+}
+
+// deliver delivers a signed webhook, returning the response status
+func (e *e2e) deliver(eventType string, event any) int {
+	body, err := json.Marshal(event)
+	assert.NoError(e.t, err)
+	e.deliveries++
+	r := httptest.NewRequest(http.MethodPost, githubapp.DefaultWebhookRoute, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-GitHub-Event", eventType)
+	r.Header.Set("X-GitHub-Delivery", fmt.Sprintf("delivery-%d", e.deliveries))
+	r.Header.Set(github.SHA256SignatureHeader, sign(e2eWebhookSecret, body))
+	w := httptest.NewRecorder()
+	e.handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+// comment posts a comment on the pull request, delivering its issue_comment webhook
+func (e *e2e) comment(author, body string) *github.IssueComment {
+	comment := e.repo.AddComment(1, author, body)
+	status := e.deliver("issue_comment", &github.IssueCommentEvent{
+		Action:       github.Ptr("created"),
+		Issue:        &github.Issue{Number: github.Ptr(1), PullRequestLinks: &github.PullRequestLinks{URL: github.Ptr(e.github.URL + "/repos/owner/repo/pulls/1")}},
+		Comment:      comment,
+		Repo:         e.repo.Repository(),
+		Installation: &github.Installation{ID: github.Ptr(int64(e2eInstallation))},
+	})
+	assert.Equal(e.t, http.StatusOK, status)
+	return comment
+}
+
+// checkRun returns the check run of the pull request head with the given external ID, if any
+func (e *e2e) checkRun(externalID string) *github.CheckRun {
+	for _, checkRun := range e.repo.CheckRuns("head-sha") {
+		if checkRun.GetExternalID() == externalID {
+			return checkRun
+		}
+	}
+	return nil
+}
+
+// reactions returns the reactions of Ariane to a comment
+func (e *e2e) reactions(comment *github.IssueComment) []string {
+	var reactions []string
+	for _, reaction := range e.repo.Reactions(comment.GetID()) {
+		if reaction.GetUser().GetLogin() == fakegithub.DefaultBotLogin {
+			reactions = append(reactions, reaction.GetContent())
+		}
+	}
+	return reactions
+}
+
+func Test_e2eDispatchThenVerify(t *testing.T) {
+	e := newE2E(t)
+
+	comment := e.comment("maintainer", "/test")
+	dispatches := e.repo.Dispatches()
+	if !assert.Len(t, dispatches, 1) {
+		return
+	}
+	assert.Equal(t, "foo.yaml", dispatches[0].Workflow)
+	assert.Equal(t, "feature", dispatches[0].Ref)
+	assert.Equal(t, "1", dispatches[0].Inputs["PR-number"])
+	assert.Equal(t, "head-sha", dispatches[0].Inputs["SHA"])
+	assert.Equal(t, []string{config.DefaultDispatchedReaction}, e.reactions(comment))
+
+	// the queued check run follows the dispatched run once found
+	runID := dispatches[0].RunID
+	assert.Eventually(t, func() bool {
+		return e.checkRun(fmt.Sprintf("run/%d", runID)) != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "Foo", e.checkRun(fmt.Sprintf("run/%d", runID)).GetName())
+	assert.Equal(t, "queued", e.checkRun(fmt.Sprintf("run/%d", runID)).GetStatus())
+
+	// and completes with it
+	run := e.repo.UpdateRun(runID, "completed", "success")
+	status := e.deliver("workflow_run", &github.WorkflowRunEvent{
+		Action:       github.Ptr("completed"),
+		WorkflowRun:  run,
+		Repo:         e.repo.Repository(),
+		Installation: &github.Installation{ID: github.Ptr(int64(e2eInstallation))},
+	})
+	assert.Equal(t, http.StatusOK, status)
+	checkRun := e.checkRun(fmt.Sprintf("run/%d", runID))
+	assert.Equal(t, "completed", checkRun.GetStatus())
+	assert.Equal(t, "success", checkRun.GetConclusion())
+}
+
+func Test_e2eDrain(t *testing.T) {
+	e := newE2E(t)
+
+	e.comment("maintainer", "/test")
+	dispatches := e.repo.Dispatches()
+	if !assert.Len(t, dispatches, 1) {
+		return
+	}
+	// draining waits for the dispatched run to be looked up in the background
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	assert.NoError(t, e.handler.Drain(ctx))
+	assert.NotNil(t, e.checkRun(fmt.Sprintf("run/%d", dispatches[0].RunID)))
+}
+
+func Test_e2eSkipsSucceededWorkflows(t *testing.T) {
+	e := newE2E(t)
+	e.repo.AddRun("bar.yaml", "head-sha", "completed", "success")
+
+	comment := e.comment("maintainer", "/lint")
+	assert.Empty(t, e.repo.Dispatches())
+	assert.Equal(t, []string{config.DefaultNothingRunReaction}, e.reactions(comment))
+	// the run which succeeded already reports the workflow
+	assert.Empty(t, e.repo.CheckRuns("head-sha"))
+	// the author is told why nothing was run
+	comments := e.repo.Comments(1)
+	if assert.Len(t, comments, 2) {
+		assert.Equal(t, fakegithub.DefaultBotLogin, comments[1].GetUser().GetLogin())
+		assert.Contains(t, comments[1].GetBody(), "@maintainer")
+	}
+}
+
+func Test_e2eIgnoresNonMembers(t *testing.T) {
+	e := newE2E(t)
+
+	comment := e.comment("stranger", "/test")
+	assert.Empty(t, e.repo.Dispatches())
+	assert.Empty(t, e.reactions(comment))
+	assert.Empty(t, e.repo.CheckRuns("head-sha"))
+	assert.Len(t, e.repo.Comments(1), 1)
+}
+
+func Test_e2eDispatchWithPathInputs(t *testing.T) {
+	e := newE2E(t)
+	e.repo.SetFile(config.ArianeConfigPath, e2eConfig+`
+workflows:
+  foo.yaml:
+    path-inputs:
+      - paths-regex: bpf/
+        inputs:
+          test-bpf: true
+      - paths-regex: .*\.go$
+        inputs:
+          test-arm64: true
+          SHA: main-sha
+`)
+
+	e.comment("maintainer", "/test")
+	dispatches := e.repo.Dispatches()
+	if !assert.Len(t, dispatches, 1) {
+		return
+	}
+	assert.Equal(t, "true", dispatches[0].Inputs["test-arm64"])
+	assert.NotContains(t, dispatches[0].Inputs, "test-bpf")
+	// the inputs set by the dispatch are not overridden
+	assert.Equal(t, "head-sha", dispatches[0].Inputs["SHA"])
+}
+
+func Test_e2eIgnorePathsFilter(t *testing.T) {
+	e := newE2E(t)
+	e.repo.SetFile(config.ArianeConfigPath, e2eConfig+`
+ignore-paths-filter-teams:
+  - ci-maintainers
+workflows:
+  foo.yaml:
+    paths-regex: docs/
+`)
+	e.github.AddTeamMember("owner", "organization-members", "member", "active")
+	e.github.AddTeamMember("owner", "ci-maintainers", "maintainer", "active")
+	e.comment("maintainer", "/test")
+	assert.Empty(t, e.repo.Dispatches(), "the paths filters of foo.yaml do not match the changes")
+
+	// the paths filters of members outside of the trusted teams are not ignored
+	e.comment("member", "/test --ignore-paths-filter")
+	assert.Empty(t, e.repo.Dispatches())
+	comments := e.repo.Comments(1)
+	if assert.NotEmpty(t, comments) {
+		assert.Contains(t, comments[len(comments)-1].GetBody(), "member is not an active member of any ignore-paths-filter team")
+	}
+
+	e.comment("maintainer", "/test --ignore-paths-filter")
+	dispatches := e.repo.Dispatches()
+	if assert.Len(t, dispatches, 1) {
+		assert.Equal(t, "foo.yaml", dispatches[0].Workflow)
+	}
+}
@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/flags"
+)
+
+// registeredHandler is an event handler named after the event type it handles, the name the handlers feature
+// flags refer to
+type registeredHandler struct {
+	name    string
+	handler githubapp.EventHandler
+	// optIn handlers are disabled unless the handlers feature flags enable them
+	optIn bool
+}
+
+// registerHandlers returns the handlers of the registry enabled by the handlers feature flags. Handlers disabled
+// for the deployment and not enabled for any repository are not registered, and the others only handle the events
+// of the repositories they are enabled for, so new handlers can be rolled out gradually. With installation flags,
+// all the handlers are registered, as any installation may enable them.
+func registerHandlers(serverConfig *config.ServerConfig, registry []registeredHandler, installationFlags *flags.Store, logger zerolog.Logger) ([]githubapp.EventHandler, error) {
+	if err := validateHandlers(serverConfig, registry); err != nil {
+		return nil, err
+	}
+
+	global := config.RepositorySettings{Handlers: maps.Clone(serverConfig.Handlers)}
+	for _, registered := range registry {
+		if _, ok := global.Handlers[registered.name]; registered.optIn && !ok {
+			if global.Handlers == nil {
+				global.Handlers = map[string]bool{}
+			}
+			global.Handlers[registered.name] = false
+		}
+	}
+	var eventHandlers []githubapp.EventHandler
+	for _, registered := range registry {
+		var overridden []string
+		for name, overrides := range serverConfig.Repositories {
+			if _, ok := overrides.Handlers[registered.name]; ok {
+				overridden = append(overridden, name)
+			}
+		}
+		if len(overridden) == 0 && installationFlags == nil {
+			if !global.HandlerEnabled(registered.name) {
+				logger.Info().Str("handler", registered.name).Msg("Handler disabled")
+				continue
+			}
+			eventHandlers = append(eventHandlers, registered.handler)
+			continue
+		}
+		sort.Strings(overridden)
+		logger.Info().Str("handler", registered.name).Bool("enabled", global.HandlerEnabled(registered.name)).Strs("overridden", overridden).Msg("Handler enabled per repository")
+		eventHandlers = append(eventHandlers, &handlerGate{
+			EventHandler: registered.handler,
+			name:         registered.name,
+			overrides:    serverConfig.Repositories,
+			global:       global,
+			flags:        installationFlags,
+		})
+	}
+	return eventHandlers, nil
+}
+
+// validateHandlers checks the handlers feature flags only refer to handlers of the registry, returning all the
+// mistakes joined
+func validateHandlers(serverConfig *config.ServerConfig, registry []registeredHandler) error {
+	known := func(name string) bool {
+		return slices.ContainsFunc(registry, func(registered registeredHandler) bool { return registered.name == name })
+	}
+	var errs []error
+	for name := range serverConfig.Handlers {
+		if !known(name) {
+			errs = append(errs, fmt.Errorf("handlers: unknown handler %q", name))
+		}
+	}
+	for repo, overrides := range serverConfig.Repositories {
+		for name := range overrides.Handlers {
+			if !known(name) {
+				errs = append(errs, fmt.Errorf("repositories: %q: unknown handler %q", repo, name))
+			}
+		}
+	}
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+// handlerGate only passes the events of the repositories its handler is enabled for to the handler
+type handlerGate struct {
+	githubapp.EventHandler
+	name      string
+	overrides config.Overrides
+	global    config.RepositorySettings
+	// flags are applied over global, and under the repository overrides, if set
+	flags *flags.Store
+}
+
+// gatedEvent holds the fields of webhook payloads identifying the repository of an event
+type gatedEvent struct {
+	Repository struct {
+		Name  string `json:"name"`
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Installation struct {
+		ID int64 `json:"id"`
+	} `json:"installation"`
+}
+
+func (g *handlerGate) Handle(ctx context.Context, eventType, deliveryID string, payload []byte) error {
+	settings := g.global
+	var event gatedEvent
+	// let the handler report invalid payloads
+	if err := json.Unmarshal(payload, &event); err == nil && event.Repository.Name != "" {
+		global := g.global
+		if g.flags != nil && event.Installation.ID != 0 {
+			installationFlags, err := g.flags.For(ctx, event.Installation.ID, event.Repository.Owner.Login)
+			if err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Msg("Failed to read the installation flags, ignoring them")
+			} else {
+				global = installationFlags.Apply(event.Repository.Name, global)
+			}
+		}
+		settings = g.overrides.For(event.Repository.Owner.Login, event.Repository.Name, global)
+	}
+	if !settings.HandlerEnabled(g.name) {
+		zerolog.Ctx(ctx).Debug().
+			Str("handler", g.name).
+			Str("repository", event.Repository.Owner.Login+"/"+event.Repository.Name).
+			Msg("Handler disabled for the repository, ignoring event")
+		return nil
+	}
+	return g.EventHandler.Handle(ctx, eventType, deliveryID, payload)
+}
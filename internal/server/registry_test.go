@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/flags"
+)
+
+// recordingHandler records the repositories of the events it handles
+type recordingHandler struct {
+	eventType string
+	handled   []string
+}
+
+func (h *recordingHandler) Handles() []string {
+	return []string{h.eventType}
+}
+
+func (h *recordingHandler) Handle(_ context.Context, _, _ string, payload []byte) error {
+	h.handled = append(h.handled, string(payload))
+	return nil
+}
+
+func Test_registerHandlers(t *testing.T) {
+	mergeGroup := &recordingHandler{eventType: "merge_group"}
+	push := &recordingHandler{eventType: "push"}
+	pullRequest := &recordingHandler{eventType: "pull_request"}
+	registry := []registeredHandler{
+		{name: "merge_group", handler: mergeGroup},
+		{name: "push", handler: push},
+		{name: "pull_request", handler: pullRequest},
+	}
+	serverConfig := &config.ServerConfig{
+		Handlers: map[string]bool{"merge_group": false, "push": false},
+		Repositories: config.Overrides{
+			"cilium/cilium": {Handlers: map[string]bool{"merge_group": true}},
+			"cilium/docs":   {Handlers: map[string]bool{"pull_request": false}},
+		},
+	}
+
+	eventHandlers, err := registerHandlers(serverConfig, registry, nil, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.Len(t, eventHandlers, 2, "the handler disabled for all the repositories is not registered")
+	handles := func(eventType string) githubapp.EventHandler {
+		for _, handler := range eventHandlers {
+			if handler.Handles()[0] == eventType {
+				return handler
+			}
+		}
+		return nil
+	}
+	assert.Nil(t, handles("push"))
+
+	cilium := `{"repository": {"name": "cilium", "owner": {"login": "cilium"}}}`
+	docs := `{"repository": {"name": "docs", "owner": {"login": "cilium"}}}`
+	for _, payload := range []string{cilium, docs} {
+		assert.NoError(t, handles("merge_group").Handle(context.Background(), "merge_group", "delivery", []byte(payload)))
+		assert.NoError(t, handles("pull_request").Handle(context.Background(), "pull_request", "delivery", []byte(payload)))
+	}
+	assert.Equal(t, []string{cilium}, mergeGroup.handled, "the handler is only enabled for cilium/cilium")
+	assert.Equal(t, []string{cilium}, pullRequest.handled, "the handler is disabled for cilium/docs")
+
+	serverConfig.Handlers["merge_queue"] = true
+	serverConfig.Repositories["cilium/docs"].Handlers["pull_requests"] = false
+	_, err = registerHandlers(serverConfig, registry, nil, zerolog.Nop())
+	assert.ErrorContains(t, err, `handlers: unknown handler "merge_queue"`)
+	assert.ErrorContains(t, err, `repositories: "cilium/docs": unknown handler "pull_requests"`)
+}
+
+func Test_registerHandlersOptIn(t *testing.T) {
+	reviewComment := &recordingHandler{eventType: "pull_request_review_comment"}
+	registry := []registeredHandler{
+		{name: "pull_request_review_comment", handler: reviewComment, optIn: true},
+	}
+
+	eventHandlers, err := registerHandlers(&config.ServerConfig{}, registry, nil, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.Empty(t, eventHandlers, "opt-in handlers are disabled by default")
+
+	serverConfig := &config.ServerConfig{
+		Repositories: config.Overrides{
+			"cilium/cilium": {Handlers: map[string]bool{"pull_request_review_comment": true}},
+		},
+	}
+	eventHandlers, err = registerHandlers(serverConfig, registry, nil, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.Len(t, eventHandlers, 1)
+	cilium := `{"repository": {"name": "cilium", "owner": {"login": "cilium"}}}`
+	docs := `{"repository": {"name": "docs", "owner": {"login": "cilium"}}}`
+	for _, payload := range []string{cilium, docs} {
+		assert.NoError(t, eventHandlers[0].Handle(context.Background(), "pull_request_review_comment", "delivery", []byte(payload)))
+	}
+	assert.Equal(t, []string{cilium}, reviewComment.handled, "the handler is only enabled for cilium/cilium")
+	assert.Nil(t, serverConfig.Handlers, "the server config is left untouched")
+
+	eventHandlers, err = registerHandlers(&config.ServerConfig{Handlers: map[string]bool{"pull_request_review_comment": true}}, registry, nil, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.Equal(t, []githubapp.EventHandler{reviewComment}, eventHandlers)
+}
+
+func Test_registerHandlersInstallationFlags(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/cilium/.github/contents/ariane-flags.yaml", func(w http.ResponseWriter, r *http.Request) {
+		content := base64.StdEncoding.EncodeToString([]byte("handlers:\n  pull_request_review_comment: true\n"))
+		_ = json.NewEncoder(w).Encode(github.RepositoryContent{Content: github.Ptr(content), Encoding: github.Ptr("base64")})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	installationFlags := flags.NewStore(".github", "", 0, func(installationID int64) (*github.Client, error) {
+		client := github.NewClient(nil)
+		client.BaseURL, _ = url.Parse(server.URL + "/")
+		return client, nil
+	})
+
+	reviewComment := &recordingHandler{eventType: "pull_request_review_comment"}
+	registry := []registeredHandler{
+		{name: "pull_request_review_comment", handler: reviewComment, optIn: true},
+	}
+	serverConfig := &config.ServerConfig{
+		Repositories: config.Overrides{
+			"cilium/docs": {Handlers: map[string]bool{"pull_request_review_comment": false}},
+		},
+	}
+	eventHandlers, err := registerHandlers(serverConfig, registry, installationFlags, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.Len(t, eventHandlers, 1, "handlers disabled for the deployment are registered, as installations may enable them")
+
+	cilium := `{"repository": {"name": "cilium", "owner": {"login": "cilium"}}, "installation": {"id": 1}}`
+	docs := `{"repository": {"name": "docs", "owner": {"login": "cilium"}}, "installation": {"id": 1}}`
+	other := `{"repository": {"name": "other", "owner": {"login": "other"}}, "installation": {"id": 2}}`
+	for _, payload := range []string{cilium, docs, other} {
+		assert.NoError(t, eventHandlers[0].Handle(context.Background(), "pull_request_review_comment", "delivery", []byte(payload)))
+	}
+	assert.Equal(t, []string{cilium}, reviewComment.handled, "the installation enables the handler, under the repository overrides")
+}
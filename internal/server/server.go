@@ -0,0 +1,414 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gregjones/httpcache"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/admin"
+	"github.com/cilium/ariane/internal/archive"
+	"github.com/cilium/ariane/internal/budget"
+	"github.com/cilium/ariane/internal/chaos"
+	"github.com/cilium/ariane/internal/config"
+	"github.com/cilium/ariane/internal/credentials"
+	"github.com/cilium/ariane/internal/deadletter"
+	"github.com/cilium/ariane/internal/flags"
+	"github.com/cilium/ariane/internal/handlers"
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/metrics"
+	"github.com/cilium/ariane/internal/policy"
+	"github.com/cilium/ariane/internal/poll"
+	"github.com/cilium/ariane/internal/scheduler"
+	"github.com/cilium/ariane/internal/serverless"
+	"github.com/cilium/ariane/internal/state"
+)
+
+const (
+	DefaultHealthRoute  = "/healthz"
+	DefaultMetricsRoute = "/metrics"
+	DefaultRoute        = "/"
+)
+
+const (
+	// githubAPIHost serves the API of github.com, any other host is a GitHub Enterprise Server
+	githubAPIHost = "api.github.com"
+	// capabilitiesTimeout bounds the detection of the GitHub Enterprise Server capabilities at startup
+	capabilitiesTimeout = 10 * time.Second
+)
+
+// isEnterpriseServer reports whether the v3 API URL is the one of a GitHub Enterprise Server rather than github.com,
+// whatever its scheme, case or trailing slash
+func isEnterpriseServer(apiURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(apiURL))
+	if err != nil {
+		return true
+	}
+	return !strings.EqualFold(u.Hostname(), githubAPIHost)
+}
+
+// Server is the HTTP handler serving the GitHub webhook, along with the background work of its handlers
+type Server struct {
+	http.Handler
+	// cancel stops the periodic background jobs
+	cancel context.CancelFunc
+	// queue handles the events in the background, if enabled
+	queue *handlers.Queue
+	// schedulers run the background work spawned while handling events, e.g. the re-runs of failed jobs
+	schedulers []*scheduler.Scheduler
+	// wg tracks the background goroutines which must complete on shutdown, e.g. the last metrics snapshot
+	wg sync.WaitGroup
+	// state keeps the state of the handlers, closed once drained
+	state state.Store
+}
+
+// Drain stops the periodic background jobs, and waits for the queued events to be handled, if any, and for the
+// background work spawned while handling events to complete, then closes the state store, or returns once ctx is
+// done. The webhooks must not be served anymore.
+func (s *Server) Drain(ctx context.Context) error {
+	s.cancel()
+	if s.queue != nil {
+		if err := s.queue.Close(ctx); err != nil {
+			return err
+		}
+	}
+	done := make(chan struct{})
+	var closeErr error
+	go func() {
+		for _, jobs := range s.schedulers {
+			jobs.Wait()
+		}
+		s.wg.Wait()
+		closeErr = s.state.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// New builds the HTTP handler serving the GitHub webhook, the health check and the default route.
+// It is shared by the long-running server and the serverless entrypoints.
+func New(serverConfig *config.ServerConfig, logger zerolog.Logger) (*Server, error) {
+	// an empty secret would validate the webhooks signed by anyone
+	if serverConfig.Github.App.WebhookSecret == "" {
+		return nil, errors.New("github.app.webhook_secret must be set")
+	}
+	// the background work would be frozen along with the Lambda execution environment between invocations
+	if serverless.IsLambda() {
+		var err error
+		if serverConfig, err = serverlessConfig(serverConfig, logger); err != nil {
+			return nil, fmt.Errorf("invalid server config: %w", err)
+		}
+	}
+	s := &Server{}
+	// the periodic background jobs run until the server is drained
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+
+	// account for the GitHub API consumption of each installation, limiting it if configured
+	budgets := budget.NewStore(serverConfig.Budget.Window, serverConfig.Budget.Limit, serverConfig.Budget.Installations)
+	// inject GitHub API failures and latencies, in integration tests and staging only, see chaos.FromEnv
+	transport := http.DefaultTransport
+	chaosConfig, err := chaos.FromEnv()
+	if err != nil {
+		return nil, fmt.Errorf("invalid chaos mode: %w", err)
+	}
+	if chaosConfig.Enabled() {
+		logger.Warn().
+			Float64("error_rate", chaosConfig.ErrorRate).
+			Dur("latency", chaosConfig.Latency).
+			Dur("jitter", chaosConfig.Jitter).
+			Msg("Chaos mode enabled, injecting faults into GitHub API requests")
+		transport = chaos.Transport(transport, chaosConfig)
+	}
+	// record whether GitHub answers, for the status page
+	connectivity := &githubConnectivity{clock: scheduler.RealClock}
+	transport = connectivity.transport(transport)
+	newClientCreator := func(privateKey []byte) (githubapp.ClientCreator, error) {
+		githubConfig := serverConfig.Github
+		githubConfig.App.PrivateKey = string(privateKey)
+		return githubapp.NewDefaultCachingClientCreator(
+			githubConfig,
+			githubapp.WithClientUserAgent("cilium-ariane/0.0.1"),
+			githubapp.WithClientTimeout(3*time.Second),
+			githubapp.WithClientCaching(false, func() httpcache.Cache { return httpcache.NewMemoryCache() }),
+			githubapp.WithTransport(budgets.Transport(transport)),
+		)
+	}
+
+	var cc githubapp.ClientCreator
+	if len(serverConfig.PrivateKeyPaths) > 0 {
+		// reload the app private keys from disk when they change
+		reloading, err := credentials.NewReloadingClientCreator(ctx, serverConfig.PrivateKeyPaths, []byte(serverConfig.Github.App.PrivateKey), newClientCreator, credentials.ValidateAppAuthentication, logger)
+		if err != nil {
+			return nil, err
+		}
+		go reloading.Watch(ctx, serverConfig.PrivateKeyReloadInterval)
+		cc = reloading
+	} else {
+		cc, err = newClientCreator([]byte(serverConfig.Github.App.PrivateKey))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// expose the metrics of the requests of the installation clients, by installation and organization
+	cc = newInstrumentedClientCreator(cc, budgets, logger)
+
+	// keep the state of the handlers in memory, or in the configured backend shared by replicas
+	s.state, err = state.Open(serverConfig.State.Backend, serverConfig.State.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening the state store: %w", err)
+	}
+	configCache := config.NewCache(serverConfig.ConfigCacheTTL)
+	runChecks := handlers.NewRunChecks()
+	workflows := handlers.NewWorkflowCache(serverConfig.ConfigCacheTTL)
+	prCommentHandler := &handlers.PRCommentHandler{
+		ClientCreator:         cc,
+		ConfigCache:           configCache,
+		RunChecks:             runChecks,
+		Poll:                  poll.Poller{Interval: serverConfig.Poll.Interval, Timeout: serverConfig.Poll.Timeout},
+		DispatchVerifyTimeout: serverConfig.DispatchVerifyTimeout,
+		HandlerTimeout:        serverConfig.HandlerTimeout,
+		BotLogin:              serverConfig.BotLogin,
+		TrustedBots:           serverConfig.TrustedBots,
+		IssueCommands:         serverConfig.IssueCommands,
+		Overrides:             serverConfig.Repositories,
+		Pagination:            serverConfig.Pagination,
+		Workflows:             workflows,
+		Idempotency:           handlers.NewIdempotencyStore(s.state, handlers.DefaultIdempotencyExpiry),
+		Bursts:                handlers.NewBursts(serverConfig.BurstWindow),
+		Pools:                 handlers.NewPools(serverConfig.Pools.Limits, serverConfig.Pools.HoldTimeout),
+		Pauses:                handlers.NewPauseStore(s.state, handlers.DefaultPauseExpiry),
+		Retries:               handlers.NewRetryStore(s.state, handlers.DefaultRetryExpiry),
+		Green:                 handlers.NewGreenStore(handlers.DefaultGreenExpiry),
+		Heads:                 handlers.NewHeadStore(handlers.DefaultHeadExpiry),
+		History:               handlers.NewHistoryStore(handlers.DefaultHistorySize),
+		Version:               serverConfig.Version,
+	}
+	// fall back to the Actions API endpoints supported by GitHub Enterprise Server
+	if isEnterpriseServer(serverConfig.Github.V3APIURL) {
+		ctx, cancel := context.WithTimeout(ctx, capabilitiesTimeout)
+		prCommentHandler.Capabilities = handlers.DetectCapabilities(ctx, cc, logger)
+		cancel()
+	}
+	// evaluate the dispatch plans against the policy engine, if set
+	if serverConfig.Policy.URL != "" {
+		prCommentHandler.Policy = &policy.Engine{URL: serverConfig.Policy.URL, Token: serverConfig.Policy.Token, Timeout: serverConfig.Policy.Timeout}
+	}
+	// poll held trigger comments for approval reactions, unless disabled
+	if serverConfig.ApprovalPollInterval > 0 {
+		prCommentHandler.Approvals = handlers.NewApprovalStore(s.state, handlers.DefaultApprovalExpiry)
+		prCommentHandler.PollApprovals(ctx, serverConfig.ApprovalPollInterval)
+	}
+	mergeGroupHandler := &handlers.MergeGroupHandler{
+		ClientCreator:         cc,
+		ConfigCache:           configCache,
+		RunChecks:             runChecks,
+		Poll:                  prCommentHandler.Poll,
+		DispatchVerifyTimeout: serverConfig.DispatchVerifyTimeout,
+		Version:               serverConfig.Version,
+	}
+	s.schedulers = []*scheduler.Scheduler{&prCommentHandler.Scheduler, &mergeGroupHandler.Scheduler}
+	// the re-runs of failed jobs and the lookups of the runs dispatched for merge groups complete within the
+	// invocation under AWS Lambda
+	if serverless.IsLambda() {
+		prCommentHandler.Scheduler.Foreground = true
+		mergeGroupHandler.Scheduler.Foreground = true
+	}
+	pullRequestHandler := &handlers.PullRequestHandler{ClientCreator: cc, ConfigCache: configCache, Workflows: workflows, Pagination: serverConfig.Pagination, Overrides: serverConfig.Repositories, Pauses: prCommentHandler.Pauses, Comments: prCommentHandler}
+	pushHandler := &handlers.PushHandler{ClientCreator: cc, ConfigCache: configCache, Workflows: workflows}
+	workflowRunHandler := &handlers.WorkflowRunHandler{ClientCreator: cc, RunChecks: runChecks, Pools: prCommentHandler.Pools, Green: prCommentHandler.Green, Comments: prCommentHandler}
+	// post the failed runs dispatched by Ariane to the repositories configuring a digest, if enabled
+	if serverConfig.Digest.Interval > 0 {
+		digester := &handlers.Digester{
+			ClientCreator:   cc,
+			ConfigCache:     configCache,
+			Failures:        handlers.NewFailureDigest(),
+			SlackWebhookURL: serverConfig.Digest.SlackWebhookURL,
+			SigningSecret:   serverConfig.Notifications.SigningSecret,
+		}
+		workflowRunHandler.Digest = digester.Failures
+		digester.Run(ctx, serverConfig.Digest.Interval)
+	}
+	// let organization admins toggle the handlers for their repositories, if enabled
+	var installationFlags *flags.Store
+	if serverConfig.InstallationFlags.Repository != "" {
+		installationFlags = flags.NewStore(serverConfig.InstallationFlags.Repository, serverConfig.InstallationFlags.Path, serverConfig.InstallationFlags.TTL, cc.NewInstallationClient)
+	}
+	// register the handlers enabled by the handlers feature flags, see registerHandlers
+	eventHandlers, err := registerHandlers(serverConfig, []registeredHandler{
+		{name: "delete", handler: &handlers.DeleteHandler{ClientCreator: cc, RunChecks: runChecks}, optIn: true},
+		{name: "issue_comment", handler: prCommentHandler},
+		{name: "merge_group", handler: mergeGroupHandler},
+		{name: "pull_request", handler: pullRequestHandler},
+		{name: "pull_request_review_comment", handler: &handlers.PRReviewCommentHandler{Comments: prCommentHandler}, optIn: true},
+		{name: "push", handler: pushHandler},
+		{name: "workflow_run", handler: workflowRunHandler},
+	}, installationFlags, logger)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server config: %w", err)
+	}
+
+	// retry failed events, and record them as dead letters once all attempts failed
+	deadLetters, err := deadletter.NewStore(serverConfig.DeadLetterPath)
+	if err != nil {
+		return nil, err
+	}
+	scheduler := deadletter.NewScheduler(deadLetters, serverConfig.Retry.Attempts, serverConfig.Retry.Backoff, eventHandlers...)
+	scheduler.Timeout = serverConfig.HandlerTimeout
+	// expose the load of the events being handled, warning when it crosses the configured thresholds
+	scheduler.Load = load.NewTracker(load.Thresholds{
+		QueueDepth:  serverConfig.Load.WarnQueueDepth,
+		EventAge:    serverConfig.Load.WarnEventAge,
+		Utilization: serverConfig.Load.WarnUtilization,
+	}, logger)
+	scheduler.Load.MaxQueueDepth = serverConfig.Load.MaxQueueDepth
+	sampleInterval := serverConfig.Load.SampleInterval
+	if sampleInterval <= 0 {
+		sampleInterval = load.DefaultSampleInterval
+	}
+	if !serverConfig.Load.Disabled {
+		scheduler.Load.Run(ctx, sampleInterval)
+	}
+	// archive the scrubbed payloads of failed events, if enabled
+	if serverConfig.Archive.Path != "" {
+		scheduler.Archive, err = archive.NewStore(serverConfig.Archive.Path, serverConfig.Archive.Retention)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// handle the events in the background, acknowledging their webhooks right away, if enabled
+	var eventScheduler githubapp.Scheduler = scheduler
+	if serverConfig.Queue.Workers > 0 {
+		s.queue = handlers.NewQueue(scheduler, serverConfig.Queue.Workers, serverConfig.Queue.Size)
+		s.queue.Load = scheduler.Load
+		eventScheduler = s.queue
+		// persist the queued events, and replay the ones left unhandled when Ariane last stopped, if enabled
+		if serverConfig.Queue.Persist {
+			if serverConfig.State.Backend == "" || serverConfig.State.Backend == state.BackendMemory {
+				logger.Warn().Msg("The queued events are persisted in memory, set a state backend for them to survive restarts")
+			}
+			s.queue.State = s.state
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				if replayed := s.queue.Replay(logger.WithContext(ctx), eventHandlers...); replayed > 0 {
+					logger.Info().Msgf("Replaying %d events queued before Ariane last stopped", replayed)
+				}
+			}()
+		}
+	}
+	// signatures are validated beforehand against the current and previous webhook secrets
+	webhookHandler := githubapp.NewEventDispatcher(eventHandlers, "", githubapp.WithScheduler(eventScheduler), githubapp.WithErrorCallback(respondError))
+	webhookSecrets := append([]string{serverConfig.Github.App.WebhookSecret}, serverConfig.PreviousWebhookSecrets...)
+
+	webhook := allowOrganizations(serverConfig.AllowedOrganizations, webhookHandler, logger)
+	// ignore the webhooks delivered more than once, remembering their delivery IDs in the state store if it is
+	// shared by replicas, in memory otherwise, unless disabled
+	if !serverConfig.Deliveries.Disabled {
+		var accepted deliveries
+		switch serverConfig.State.Backend {
+		case state.BackendRedis, state.BackendPostgres:
+			ttl := serverConfig.Deliveries.TTL
+			if ttl <= 0 {
+				ttl = DefaultDeliveryTTL
+			}
+			accepted = &stateDeliveries{state: s.state, ttl: ttl}
+		default:
+			if accepted, err = newLRUDeliveries(serverConfig.Deliveries.CacheSize); err != nil {
+				return nil, err
+			}
+		}
+		webhook = deduplicateDeliveries(accepted, webhook, logger)
+	}
+
+	mux := http.NewServeMux()
+	// redeliver the webhooks refused under back pressure once the intake recovered
+	catchUp := newCatchUp(cc.NewAppClient, scheduler.Load, logger)
+	if !serverConfig.Load.Disabled {
+		catchUp.Run(ctx, sampleInterval)
+	}
+	s.schedulers = append(s.schedulers, &catchUp.Scheduler)
+	mux.Handle(githubapp.DefaultWebhookRoute, validateWebhook(webhookSecrets, backPressure(scheduler.Load, catchUp, webhook, logger), logger))
+
+	// add the admin API, if enabled
+	if serverConfig.Admin.Token != "" {
+		adminServer := admin.New(serverConfig.Admin.Token, logger)
+		adminServer.RegisterDeadLetters(scheduler)
+		adminServer.RegisterExplain(configCache)
+		adminServer.RegisterConfig(configCache)
+		adminServer.RegisterGraphQL(prCommentHandler.History, configCache)
+		adminServer.RegisterLastGreen(prCommentHandler.Green)
+		adminServer.RegisterDashboard(metrics.Default)
+		adminServer.RegisterBudget(budgets)
+		adminServer.RegisterPause(scheduler.Load)
+		if scheduler.Archive != nil {
+			adminServer.RegisterArchive(scheduler.Archive)
+		}
+		mux.Handle(admin.Route, adminServer)
+		// the metrics are labelled with the installations and repositories, which must not be public
+		mux.Handle(DefaultMetricsRoute, adminServer.Authorize(metrics.Default))
+	}
+
+	// add the public status page, rate limited across all clients, unless disabled
+	if !serverConfig.StatusPage.Disabled {
+		rateLimit := serverConfig.StatusPage.RateLimit
+		if rateLimit <= 0 {
+			rateLimit = DefaultStatusRateLimit
+		}
+		limiter := &rateLimiter{clock: connectivity.clock, limit: rateLimit, window: time.Minute}
+		mux.HandleFunc(DefaultStatusRoute, statusPage(serverConfig.Version, connectivity, scheduler.Load, limiter, logger))
+	}
+
+	// add a health check endpoint
+	mux.HandleFunc(DefaultHealthRoute, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("OK"))
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to write health check response")
+		}
+	})
+
+	// expose metrics to Prometheus, persisting the avoided dispatches so they survive restarts
+	if serverConfig.MetricsPath != "" {
+		snapshot, err := metrics.NewSnapshot(serverConfig.MetricsPath, handlers.SavingsMetrics()...)
+		if err != nil {
+			return nil, err
+		}
+		// the snapshot is saved one last time once the server is drained
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			snapshot.Run(ctx, metrics.DefaultSaveInterval, func(err error) {
+				logger.Error().Err(err).Msg("Failed to save metrics snapshot")
+			})
+		}()
+	}
+
+	// add a default route
+	mux.HandleFunc(DefaultRoute, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte("Ariane is running!" + "\nVersion: " + serverConfig.Version))
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to write default response")
+		}
+	})
+
+	s.Handler = mux
+	return s, nil
+}
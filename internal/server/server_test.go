@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_isEnterpriseServer(t *testing.T) {
+	testCases := []struct {
+		APIURL         string
+		ExpectedResult bool
+		ExpectedReason string
+	}{
+		{
+			APIURL:         "https://api.github.com/",
+			ExpectedResult: false,
+			ExpectedReason: "the API of github.com is not a GitHub Enterprise Server.",
+		},
+		{
+			APIURL:         "https://api.github.com",
+			ExpectedResult: false,
+			ExpectedReason: "the trailing slash does not matter.",
+		},
+		{
+			APIURL:         "https://API.GitHub.com/",
+			ExpectedResult: false,
+			ExpectedReason: "the case of the host does not matter.",
+		},
+		{
+			APIURL:         "https://api.github.com:443/",
+			ExpectedResult: false,
+			ExpectedReason: "the port does not matter.",
+		},
+		{
+			APIURL:         "https://github.example.com/api/v3/",
+			ExpectedResult: true,
+			ExpectedReason: "other hosts are GitHub Enterprise Servers.",
+		},
+		{
+			APIURL:         "https://api.github.com.example.com/",
+			ExpectedResult: true,
+			ExpectedReason: "hosts are compared whole.",
+		},
+	}
+
+	for idx, testCase := range testCases {
+		assert.Equal(t, testCase.ExpectedResult, isEnterpriseServer(testCase.APIURL), "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
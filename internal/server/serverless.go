@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"errors"
+	"maps"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+// serverlessConfig returns the server config to run under AWS Lambda, which freezes the execution environment once
+// the response of an invocation is returned. The background work outliving the invocations is disabled: the lookups
+// of dispatched runs, the bursts, the resource pools, the polling of held comments, the digests, the sampling of the
+// load along with the redelivery of refused webhooks, and the snapshots of the metrics. The queue is refused, as its
+// events would be acknowledged without ever being handled.
+func serverlessConfig(serverConfig *config.ServerConfig, logger zerolog.Logger) (*config.ServerConfig, error) {
+	if serverConfig.Queue.Workers > 0 {
+		return nil, errors.New("queue.workers must not be set under AWS Lambda")
+	}
+	disabled := *serverConfig
+	if disabled.DispatchVerifyTimeout > 0 {
+		logger.Warn().Msg("Dispatched runs are not linked under AWS Lambda")
+		disabled.DispatchVerifyTimeout = 0
+	}
+	disabled.Repositories = maps.Clone(serverConfig.Repositories)
+	for repository, overrides := range disabled.Repositories {
+		overrides.DispatchVerifyTimeout = nil
+		disabled.Repositories[repository] = overrides
+	}
+	if disabled.BurstWindow > 0 {
+		logger.Warn().Msg("Trigger comments are not held in bursts under AWS Lambda")
+		disabled.BurstWindow = 0
+	}
+	if len(disabled.Pools.Limits) > 0 {
+		logger.Warn().Msg("Resource pools are not limited under AWS Lambda")
+		disabled.Pools.Limits = nil
+	}
+	if disabled.ApprovalPollInterval > 0 {
+		logger.Warn().Msg("Held trigger comments are not polled for approvals under AWS Lambda")
+		disabled.ApprovalPollInterval = 0
+	}
+	if disabled.Digest.Interval > 0 {
+		logger.Warn().Msg("Digests are not posted under AWS Lambda")
+		disabled.Digest.Interval = 0
+	}
+	if !disabled.Load.Disabled {
+		logger.Warn().Msg("The load is not sampled, and refused webhooks are not redelivered under AWS Lambda")
+		disabled.Load.Disabled = true
+	}
+	if disabled.MetricsPath != "" {
+		logger.Warn().Msg("The avoided dispatches are not persisted under AWS Lambda")
+		disabled.MetricsPath = ""
+	}
+	return &disabled, nil
+}
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func Test_serverlessConfig(t *testing.T) {
+	timeout := 5 * time.Minute
+	serverConfig := &config.ServerConfig{
+		DispatchVerifyTimeout: time.Minute,
+		BurstWindow:           time.Second,
+		ApprovalPollInterval:  time.Minute,
+		Pools:                 config.PoolsConfig{Limits: map[string]int{"gpu": 1}},
+		Repositories:          config.Overrides{"owner/repo": {DispatchVerifyTimeout: &timeout}},
+		MetricsPath:           "/var/lib/ariane/metrics.json",
+	}
+	serverConfig.Digest.Interval = time.Hour
+
+	disabled, err := serverlessConfig(serverConfig, zerolog.Nop())
+	assert.NoError(t, err)
+	assert.Zero(t, disabled.DispatchVerifyTimeout)
+	assert.Zero(t, disabled.Repositories.For("owner", "repo", config.RepositorySettings{}).DispatchVerifyTimeout, "the repositories overrides do not enable the lookups of dispatched runs")
+	assert.Zero(t, disabled.BurstWindow)
+	assert.Zero(t, disabled.ApprovalPollInterval)
+	assert.Empty(t, disabled.Pools.Limits)
+	assert.Zero(t, disabled.Digest.Interval)
+	assert.True(t, disabled.Load.Disabled, "neither the load sampler nor the redelivery of refused webhooks run")
+	assert.Empty(t, disabled.MetricsPath, "the metrics snapshots are not saved")
+	assert.Equal(t, time.Minute, serverConfig.DispatchVerifyTimeout, "the server config is left as is")
+	assert.Equal(t, &timeout, serverConfig.Repositories["owner/repo"].DispatchVerifyTimeout)
+
+	serverConfig.Queue.Workers = 4
+	_, err = serverlessConfig(serverConfig, zerolog.Nop())
+	assert.EqualError(t, err, "queue.workers must not be set under AWS Lambda")
+}
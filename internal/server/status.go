@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+const (
+	DefaultStatusRoute = "/status"
+	// DefaultStatusRateLimit is how many requests per minute the status page answers, across all clients
+	DefaultStatusRateLimit = 60
+)
+
+// the states of Status
+const (
+	statusOperational = "operational"
+	statusDegraded    = "degraded"
+	statusPaused      = "paused"
+
+	githubReachable   = "reachable"
+	githubUnreachable = "unreachable"
+	githubUnknown     = "unknown"
+)
+
+// Status is the public status of Ariane, for contributors to check whether it is down. It tells nothing about the
+// repositories Ariane serves.
+type Status struct {
+	// Status is operational, degraded if GitHub is unreachable, or paused if the intake of events is paused
+	Status    string    `json:"status"`
+	Version   string    `json:"version"`
+	StartedAt time.Time `json:"startedAt"`
+	Uptime    string    `json:"uptime"`
+	// GitHub is reachable or unreachable, as of the last GitHub API request, or unknown if none was made yet
+	GitHub               string     `json:"github"`
+	LastGitHubResponseAt *time.Time `json:"lastGitHubResponseAt,omitempty"`
+	Paused               bool       `json:"paused"`
+}
+
+// githubConnectivity records whether the last GitHub API requests got a response, without probing GitHub, which
+// unauthenticated requests would soon be rate limited for
+type githubConnectivity struct {
+	clock scheduler.Clock
+
+	mu           sync.Mutex
+	lastResponse time.Time
+	lastFailure  time.Time
+}
+
+// transport records the outcome of the requests sent through next. Server errors count as failures, but not the
+// client errors, which GitHub answered.
+func (c *githubConnectivity) transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		res, err := next.RoundTrip(r)
+		switch {
+		case res == nil && errors.Is(err, context.Canceled):
+		case res == nil || res.StatusCode >= http.StatusInternalServerError:
+			c.record(&c.lastFailure)
+		default:
+			c.record(&c.lastResponse)
+		}
+		return res, err
+	})
+}
+
+func (c *githubConnectivity) record(at *time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*at = c.clock.Now()
+}
+
+// state returns whether GitHub is reachable, and when it last answered
+func (c *githubConnectivity) state() (string, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.lastResponse.IsZero() && c.lastFailure.IsZero():
+		return githubUnknown, c.lastResponse
+	case c.lastFailure.After(c.lastResponse):
+		return githubUnreachable, c.lastResponse
+	}
+	return githubReachable, c.lastResponse
+}
+
+// rateLimiter allows up to limit requests per fixed window
+type rateLimiter struct {
+	clock  scheduler.Clock
+	limit  int
+	window time.Duration
+
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+// allow reports whether a request is allowed, or else when the next window starts
+func (l *rateLimiter) allow() (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	if now.Sub(l.windowStart) >= l.window {
+		l.windowStart, l.count = now, 0
+	}
+	if l.count >= l.limit {
+		return false, l.windowStart.Add(l.window).Sub(now)
+	}
+	l.count++
+	return true, 0
+}
+
+var statusTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Ariane status</title></head>
+<body>
+<h1>Ariane is {{.Status}}</h1>
+<ul>
+<li>Version: {{.Version}}</li>
+<li>Up for {{.Uptime}}, since {{.StartedAt.Format "2006-01-02 15:04:05 MST"}}</li>
+<li>GitHub: {{.GitHub}}{{with .LastGitHubResponseAt}}, last answered at {{.Format "2006-01-02 15:04:05 MST"}}{{end}}</li>
+<li>Dispatching: {{if .Paused}}paused{{else}}running{{end}}</li>
+</ul>
+</body>
+</html>
+`))
+
+// statusPage serves the public status of Ariane, as HTML or as JSON if the client accepts it, answering
+// 429 Too Many Requests past the rate limit
+func statusPage(version string, github *githubConnectivity, tracker *load.Tracker, limiter *rateLimiter, logger zerolog.Logger) http.HandlerFunc {
+	startedAt := limiter.clock.Now()
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := limiter.allow(); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		now := limiter.clock.Now()
+		status := Status{
+			Status:    statusOperational,
+			Version:   version,
+			StartedAt: startedAt,
+			Uptime:    now.Sub(startedAt).Round(time.Second).String(),
+			Paused:    tracker.Paused(),
+		}
+		var lastResponse time.Time
+		status.GitHub, lastResponse = github.state()
+		if !lastResponse.IsZero() {
+			status.LastGitHubResponseAt = &lastResponse
+		}
+		switch {
+		case status.Paused:
+			status.Status = statusPaused
+		case status.GitHub == githubUnreachable:
+			status.Status = statusDegraded
+		}
+
+		var err error
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			err = json.NewEncoder(w).Encode(status)
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			err = statusTemplate.Execute(w, status)
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to write status page")
+		}
+	}
+}
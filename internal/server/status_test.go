@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/load"
+	"github.com/cilium/ariane/internal/scheduler"
+)
+
+func Test_statusPage(t *testing.T) {
+	clock := scheduler.NewFakeClock(time.Now())
+	connectivity := &githubConnectivity{clock: clock}
+	tracker := load.NewTracker(load.Thresholds{}, zerolog.Nop())
+	limiter := &rateLimiter{clock: clock, limit: 3, window: time.Minute}
+	handler := statusPage("1.2.3", connectivity, tracker, limiter, zerolog.Nop())
+	get := func() (*httptest.ResponseRecorder, Status) {
+		r := httptest.NewRequest(http.MethodGet, DefaultStatusRoute, nil)
+		r.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		var status Status
+		_ = json.Unmarshal(w.Body.Bytes(), &status)
+		return w, status
+	}
+	respond := func(status int, err error) {
+		transport := connectivity.transport(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if err != nil {
+				return nil, err
+			}
+			return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+		}))
+		_, _ = transport.RoundTrip(httptest.NewRequest(http.MethodGet, "https://api.github.com/", nil))
+	}
+
+	clock.Advance(time.Hour)
+	w, status := get()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, Status{Status: "operational", Version: "1.2.3", StartedAt: status.StartedAt, Uptime: "1h0m0s", GitHub: "unknown"}, status)
+
+	// client errors are answered by GitHub, server errors and network errors are not
+	respond(http.StatusNotFound, nil)
+	_, status = get()
+	assert.Equal(t, "reachable", status.GitHub)
+	assert.NotNil(t, status.LastGitHubResponseAt)
+	clock.Advance(time.Second)
+	respond(0, errors.New("connection refused"))
+	tracker.SetPaused(true)
+	_, status = get()
+	assert.Equal(t, "unreachable", status.GitHub)
+	assert.Equal(t, "paused", status.Status)
+	assert.True(t, status.Paused)
+
+	// the rate limit applies across all clients, until the next window
+	w, _ = get()
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	clock.Advance(time.Minute)
+	tracker.SetPaused(false)
+	respond(http.StatusServiceUnavailable, nil)
+	r := httptest.NewRequest(http.MethodGet, DefaultStatusRoute, nil)
+	w = httptest.NewRecorder()
+	handler(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<h1>Ariane is degraded</h1>")
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+
+	"github.com/cilium/ariane/internal/audit"
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/load"
+)
+
+// secretName identifies which of the webhook secrets validated a delivery
+func secretName(idx int) string {
+	if idx == 0 {
+		return "current"
+	}
+	return fmt.Sprintf("previous-%d", idx)
+}
+
+// validateWebhook checks webhook signatures against each of the given secrets, the current one first,
+// so secrets can be rotated without rejecting deliveries signed with the previous one. Empty secrets are ignored,
+// as anyone can sign a payload with an empty key.
+// Validated requests are passed to next without their signature, next must not validate them again.
+func validateWebhook(secrets []string, next http.Handler, logger zerolog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger := logger.With().
+			Str(githubapp.LogKeyEventType, github.WebHookType(r)).
+			Str(githubapp.LogKeyDeliveryID, github.DeliveryID(r)).
+			Logger()
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to read webhook payload")
+			http.Error(w, "Invalid webhook headers or payload", http.StatusBadRequest)
+			return
+		}
+
+		signature := r.Header.Get(github.SHA256SignatureHeader)
+		if signature == "" {
+			signature = r.Header.Get(github.SHA1SignatureHeader)
+		}
+
+		validated := -1
+		for idx, secret := range secrets {
+			if secret == "" {
+				continue
+			}
+			if github.ValidateSignature(signature, body, []byte(secret)) == nil {
+				validated = idx
+				break
+			}
+		}
+		if validated < 0 {
+			logger.Warn().Msg("Received webhook with an invalid signature")
+			http.Error(w, "Invalid webhook headers or payload", http.StatusBadRequest)
+			return
+		}
+
+		audit.Event(logger.WithContext(r.Context()), "webhook_validated").
+			Str("webhook_secret", secretName(validated)).
+			Send()
+
+		r.Header.Del(github.SHA256SignatureHeader)
+		r.Header.Del(github.SHA1SignatureHeader)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+// webhookAccount holds the fields of webhook payloads identifying the account an event belongs to
+type webhookAccount struct {
+	Organization struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+	Repository struct {
+		Owner struct {
+			Login string `json:"login"`
+		} `json:"owner"`
+	} `json:"repository"`
+	Installation struct {
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	} `json:"installation"`
+}
+
+// login returns the organization (or user) an event belongs to, if any
+func (a webhookAccount) login() string {
+	for _, login := range []string{a.Organization.Login, a.Repository.Owner.Login, a.Installation.Account.Login} {
+		if login != "" {
+			return login
+		}
+	}
+	return ""
+}
+
+// allowOrganizations only passes the events of the given organizations to next, before any API call is made,
+// so installations on other organizations do not consume the API quota. All events pass if organizations is empty.
+// Events not belonging to any organization, e.g. ping events, always pass.
+func allowOrganizations(organizations []string, next http.Handler, logger zerolog.Logger) http.Handler {
+	if len(organizations) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.Warn().Err(err).Msg("Failed to read webhook payload")
+			http.Error(w, "Invalid webhook headers or payload", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var account webhookAccount
+		if err := json.Unmarshal(body, &account); err != nil {
+			// let the event handlers report invalid payloads
+			next.ServeHTTP(w, r)
+			return
+		}
+		login := account.login()
+		if login == "" || slices.ContainsFunc(organizations, func(org string) bool { return strings.EqualFold(org, login) }) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		audit.Event(logger.WithContext(r.Context()), "organization_rejected").
+			Str(githubapp.LogKeyEventType, github.WebHookType(r)).
+			Str(githubapp.LogKeyDeliveryID, github.DeliveryID(r)).
+			Str("organization", login).
+			Send()
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// backPressureRetryAfter is the delay after which refused events may be redelivered, for the senders honouring it.
+// GitHub does not redeliver failed deliveries by itself.
+const backPressureRetryAfter = "60"
+
+// backPressure answers GitHub with a retriable status instead of passing events to next while the intake is paused
+// or the event queue is saturated, see load.Tracker.Refuse, so refused deliveries show as failed rather than being
+// accepted and dropped. They are redelivered by catchUp once the intake recovered. Ping events always pass.
+func backPressure(tracker *load.Tracker, catchUp *catchUp, next http.Handler, logger zerolog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if github.WebHookType(r) == "ping" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reason := tracker.Refuse()
+		if reason == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger.Warn().
+			Str(githubapp.LogKeyEventType, github.WebHookType(r)).
+			Str(githubapp.LogKeyDeliveryID, github.DeliveryID(r)).
+			Str("reason", reason).
+			Msg("Refusing event")
+		catchUp.refuse(github.DeliveryID(r))
+		w.Header().Set("Retry-After", backPressureRetryAfter)
+		http.Error(w, fmt.Sprintf("Event refused: %s", reason), http.StatusServiceUnavailable)
+	})
+}
+
+// respondError answers GitHub with the status of the handler failure category, see failure.Category.StatusCode.
+// Invalid webhooks and events dropped for lack of capacity are answered as githubapp does by default.
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr githubapp.ValidationError
+	if errors.As(err, &validationErr) || errors.Is(err, githubapp.ErrCapacityExceeded) {
+		githubapp.DefaultErrorCallback(w, r, err)
+		return
+	}
+
+	category := failure.CategoryOf(err)
+	status := category.StatusCode()
+	if status < http.StatusBadRequest {
+		w.WriteHeader(status)
+		_, _ = fmt.Fprintf(w, "Event dropped: %s", category)
+		return
+	}
+	http.Error(w, fmt.Sprintf("Event failed: %s", category), status)
+}
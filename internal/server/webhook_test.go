@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/palantir/go-githubapp/githubapp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cilium/ariane/internal/failure"
+	"github.com/cilium/ariane/internal/load"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func Test_validateWebhook(t *testing.T) {
+	body := []byte(`{"action": "created"}`)
+	var received []byte
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the signature is consumed by the validation
+		if r.Header.Get(github.SHA256SignatureHeader) != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		received, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := validateWebhook([]string{"current", "previous"}, next, zerolog.Nop())
+
+	testCases := []struct {
+		Signature      string
+		ExpectedStatus int
+		ExpectedReason string
+	}{
+		{
+			Signature:      sign("current", body),
+			ExpectedStatus: http.StatusOK,
+			ExpectedReason: "payload signed with the current secret.",
+		},
+		{
+			Signature:      sign("previous", body),
+			ExpectedStatus: http.StatusOK,
+			ExpectedReason: "payload signed with the previous secret, while rotating secrets.",
+		},
+		{
+			Signature:      sign("unknown", body),
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedReason: "payload signed with an unknown secret.",
+		},
+		{
+			ExpectedStatus: http.StatusBadRequest,
+			ExpectedReason: "payload not signed.",
+		},
+	}
+
+	for idx, testCase := range testCases {
+		received = nil
+		r := httptest.NewRequest(http.MethodPost, "/api/github/hook", bytes.NewReader(body))
+		if testCase.Signature != "" {
+			r.Header.Set(github.SHA256SignatureHeader, testCase.Signature)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		assert.Equal(t, testCase.ExpectedStatus, w.Code, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		if testCase.ExpectedStatus == http.StatusOK {
+			assert.Equal(t, body, received, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+		}
+	}
+
+	// e.g. a trailing comma in ARIANE_PREVIOUS_WEBHOOK_SECRETS, which must not let anyone sign webhooks
+	handler = validateWebhook([]string{"current", ""}, next, zerolog.Nop())
+	r := httptest.NewRequest(http.MethodPost, "/api/github/hook", bytes.NewReader(body))
+	r.Header.Set(github.SHA256SignatureHeader, sign("", body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "payloads signed with an empty key are rejected")
+}
+
+func Test_allowOrganizations(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := allowOrganizations([]string{"cilium"}, next, zerolog.Nop())
+
+	testCases := []struct {
+		Payload        string
+		ExpectedStatus int
+		ExpectedReason string
+	}{
+		{
+			Payload:        `{"repository": {"owner": {"login": "Cilium"}}}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedReason: "events of allowed organizations are handled, regardless of case.",
+		},
+		{
+			Payload:        `{"organization": {"login": "other"}, "repository": {"owner": {"login": "other"}}}`,
+			ExpectedStatus: http.StatusAccepted,
+			ExpectedReason: "events of other organizations are dropped.",
+		},
+		{
+			Payload:        `{"installation": {"account": {"login": "other"}}}`,
+			ExpectedStatus: http.StatusAccepted,
+			ExpectedReason: "installation events of other organizations are dropped.",
+		},
+		{
+			Payload:        `{"zen": "Keep it logically awesome."}`,
+			ExpectedStatus: http.StatusOK,
+			ExpectedReason: "events not belonging to any organization are handled.",
+		},
+	}
+	for idx, testCase := range testCases {
+		r := httptest.NewRequest(http.MethodPost, "/api/github/hook", bytes.NewReader([]byte(testCase.Payload)))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		assert.Equal(t, testCase.ExpectedStatus, w.Code, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
+
+func Test_backPressure(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	var redelivered []string
+	now := time.Now()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /app/hook/deliveries", func(w http.ResponseWriter, r *http.Request) {
+		// most recent deliveries come first, delivery-2 was redelivered successfully by hand already
+		_ = json.NewEncoder(w).Encode([]*github.HookDelivery{
+			{ID: github.Ptr(int64(4)), GUID: github.Ptr("delivery-2"), StatusCode: github.Ptr(http.StatusOK), DeliveredAt: &github.Timestamp{Time: now}},
+			{ID: github.Ptr(int64(3)), GUID: github.Ptr("delivery-2"), StatusCode: github.Ptr(http.StatusServiceUnavailable), DeliveredAt: &github.Timestamp{Time: now}},
+			{ID: github.Ptr(int64(2)), GUID: github.Ptr("delivery-1"), StatusCode: github.Ptr(http.StatusServiceUnavailable), DeliveredAt: &github.Timestamp{Time: now}, Event: github.Ptr("issue_comment")},
+			{ID: github.Ptr(int64(1)), GUID: github.Ptr("other"), StatusCode: github.Ptr(http.StatusServiceUnavailable), DeliveredAt: &github.Timestamp{Time: now}},
+		})
+	})
+	mux.HandleFunc("POST /app/hook/deliveries/{id}/attempts", func(w http.ResponseWriter, r *http.Request) {
+		redelivered = append(redelivered, r.PathValue("id"))
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(github.HookDelivery{})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	tracker := load.NewTracker(load.Thresholds{}, zerolog.Nop())
+	tracker.MaxQueueDepth = 1
+	catchUp := newCatchUp(func() (*github.Client, error) { return client, nil }, tracker, zerolog.Nop())
+	handler := backPressure(tracker, catchUp, next, zerolog.Nop())
+	deliver := func(eventType, deliveryID string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/github/hook", bytes.NewReader([]byte(`{}`)))
+		r.Header.Set(github.EventTypeHeader, eventType)
+		r.Header.Set(github.DeliveryIDHeader, deliveryID)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, deliver("issue_comment", "delivery-0").Code)
+
+	done := tracker.Start()
+	w := deliver("issue_comment", "delivery-1")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code, "events are refused while the queue is saturated")
+	assert.Equal(t, backPressureRetryAfter, w.Header().Get("Retry-After"))
+	done()
+
+	tracker.SetPaused(true)
+	assert.Equal(t, http.StatusServiceUnavailable, deliver("issue_comment", "delivery-2").Code, "events are refused while paused")
+	assert.Equal(t, http.StatusServiceUnavailable, deliver("issue_comment", "delivery-3").Code)
+	assert.Equal(t, http.StatusOK, deliver("ping", "delivery-4").Code, "ping events always pass")
+
+	catchUp.redeliver(context.Background())
+	assert.Empty(t, redelivered, "refused deliveries are not redelivered while the intake still refuses events")
+
+	tracker.SetPaused(false)
+	redeliveredEvents := redeliveredTotal.Value("issue_comment")
+	catchUp.redeliver(context.Background())
+	assert.Equal(t, []string{"2"}, redelivered, "only the refused deliveries without a successful attempt are redelivered")
+	assert.Equal(t, redeliveredEvents+1, redeliveredTotal.Value("issue_comment"))
+	assert.Empty(t, catchUp.refused, "the deliveries which cannot be found are forgotten")
+
+	catchUp.redeliver(context.Background())
+	assert.Len(t, redelivered, 1, "deliveries are redelivered once")
+}
+
+func Test_respondError(t *testing.T) {
+	testCases := []struct {
+		Err            error
+		ExpectedStatus int
+		ExpectedReason string
+	}{
+		{
+			Err:            failure.Errorf(failure.ConfigError, "failed parsing configuration file"),
+			ExpectedStatus: http.StatusOK,
+			ExpectedReason: "config errors are acknowledged, as redelivering them cannot succeed.",
+		},
+		{
+			Err:            &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusBadGateway}},
+			ExpectedStatus: http.StatusServiceUnavailable,
+			ExpectedReason: "transient GitHub failures are worth redelivering.",
+		},
+		{
+			Err:            &github.ErrorResponse{Response: &http.Response{StatusCode: http.StatusForbidden}},
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedReason: "permission failures are kept failed, to be redelivered once permissions are granted.",
+		},
+		{
+			Err:            githubapp.ErrCapacityExceeded,
+			ExpectedStatus: http.StatusServiceUnavailable,
+			ExpectedReason: "events dropped for lack of capacity are answered as by default.",
+		},
+	}
+	for idx, testCase := range testCases {
+		w := httptest.NewRecorder()
+		respondError(w, httptest.NewRequest(http.MethodPost, "/", nil), testCase.Err)
+		assert.Equal(t, testCase.ExpectedStatus, w.Code, "[TEST%v] %v", idx+1, testCase.ExpectedReason)
+	}
+}
@@ -0,0 +1,265 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package serverless runs Ariane's HTTP handler behind a function-as-a-service
+// platform instead of a persistent server.
+//
+// Only AWS Lambda has an entrypoint, through the Lambda Runtime API with API
+// Gateway (REST and HTTP API) proxy events. Cloud Run, and the Cloud Functions
+// (2nd gen) running on it, serve the regular HTTP server on $PORT instead.
+//
+// Webhook events are handled within the invocation, as Lambda freezes the
+// execution environment once the response is returned. The server disables the
+// background work which would outlive the invocations, and drains the rest once
+// Lambda shuts the environment down.
+package serverless
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// LambdaRuntimeAPIEnv is set by AWS Lambda to the host:port of the Runtime API.
+	LambdaRuntimeAPIEnv = "AWS_LAMBDA_RUNTIME_API"
+
+	lambdaRuntimeAPIVersion = "2018-06-01"
+	lambdaRequestIDHeader   = "Lambda-Runtime-Aws-Request-Id"
+)
+
+// IsLambda returns true if the process is running inside an AWS Lambda execution environment.
+func IsLambda() bool {
+	return os.Getenv(LambdaRuntimeAPIEnv) != ""
+}
+
+// APIGatewayRequest covers both the REST API (payload 1.0) and HTTP API (payload 2.0)
+// proxy integration events sent by API Gateway.
+type APIGatewayRequest struct {
+	// payload 1.0
+	HTTPMethod            string              `json:"httpMethod"`
+	Path                  string              `json:"path"`
+	MultiValueHeaders     map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters map[string]string   `json:"queryStringParameters"`
+	// payload 2.0
+	RawPath        string `json:"rawPath"`
+	RawQueryString string `json:"rawQueryString"`
+	RequestContext struct {
+		HTTP struct {
+			Method string `json:"method"`
+		} `json:"http"`
+	} `json:"requestContext"`
+	// common
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// APIGatewayResponse is the proxy integration response understood by both API Gateway payload versions.
+type APIGatewayResponse struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+// ToHTTPRequest translates an API Gateway proxy event into an *http.Request.
+func (e *APIGatewayRequest) ToHTTPRequest(ctx context.Context) (*http.Request, error) {
+	method := e.HTTPMethod
+	path := e.Path
+	query := e.RawQueryString
+	if method == "" {
+		method = e.RequestContext.HTTP.Method
+		path = e.RawPath
+	} else if len(e.QueryStringParameters) > 0 {
+		values := url.Values{}
+		for k, v := range e.QueryStringParameters {
+			values.Set(k, v)
+		}
+		query = values.Encode()
+	}
+	if method == "" {
+		return nil, fmt.Errorf("event is not an API Gateway proxy request")
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	body := []byte(e.Body)
+	if e.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(e.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed decoding request body: %w", err)
+		}
+		body = decoded
+	}
+
+	u := &url.URL{Path: path, RawQuery: query}
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, vs := range e.MultiValueHeaders {
+		for _, v := range vs {
+			r.Header.Add(k, v)
+		}
+	}
+	for k, v := range e.Headers {
+		if r.Header.Get(k) == "" {
+			r.Header.Set(k, v)
+		}
+	}
+	r.Host = r.Header.Get("Host")
+	return r, nil
+}
+
+// responseWriter buffers a handler's response so it can be returned as an APIGatewayResponse.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) toAPIGatewayResponse() APIGatewayResponse {
+	res := APIGatewayResponse{
+		StatusCode: w.status,
+		Headers:    map[string]string{},
+	}
+	if res.StatusCode == 0 {
+		res.StatusCode = http.StatusOK
+	}
+	for k := range w.header {
+		res.Headers[k] = strings.Join(w.header.Values(k), ",")
+	}
+	if utf8.Valid(w.body.Bytes()) {
+		res.Body = w.body.String()
+	} else {
+		res.Body = base64.StdEncoding.EncodeToString(w.body.Bytes())
+		res.IsBase64Encoded = true
+	}
+	return res
+}
+
+// HandleAPIGatewayEvent serves a single API Gateway proxy event with the given handler.
+func HandleAPIGatewayEvent(ctx context.Context, handler http.Handler, event []byte) (APIGatewayResponse, error) {
+	var req APIGatewayRequest
+	if err := json.Unmarshal(event, &req); err != nil {
+		return APIGatewayResponse{}, fmt.Errorf("failed parsing API Gateway event: %w", err)
+	}
+
+	r, err := req.ToHTTPRequest(ctx)
+	if err != nil {
+		return APIGatewayResponse{}, err
+	}
+
+	w := &responseWriter{header: http.Header{}}
+	handler.ServeHTTP(w, r)
+	return w.toAPIGatewayResponse(), nil
+}
+
+// StartLambda polls the Lambda Runtime API for invocations and serves each of them with the given handler.
+// It returns once ctx is done, e.g. when Lambda shuts the execution environment down, or if the Runtime API cannot
+// be reached.
+func StartLambda(ctx context.Context, handler http.Handler, logger zerolog.Logger) error {
+	return runLambda(ctx, http.DefaultClient, os.Getenv(LambdaRuntimeAPIEnv), handler, logger)
+}
+
+func runLambda(ctx context.Context, client *http.Client, runtimeAPI string, handler http.Handler, logger zerolog.Logger) error {
+	baseURL := fmt.Sprintf("http://%s/%s/runtime/invocation/", runtimeAPI, lambdaRuntimeAPIVersion)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		requestID, event, err := nextInvocation(ctx, client, baseURL)
+		if err != nil {
+			return err
+		}
+
+		res, err := HandleAPIGatewayEvent(ctx, handler, event)
+		if err != nil {
+			logger.Error().Err(err).Str("lambda_request_id", requestID).Msg("Failed to handle Lambda invocation")
+			if err := postInvocation(ctx, client, baseURL+requestID+"/error", map[string]string{
+				"errorMessage": err.Error(),
+				"errorType":    "InvalidEvent",
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := postInvocation(ctx, client, baseURL+requestID+"/response", res); err != nil {
+			return err
+		}
+	}
+}
+
+func nextInvocation(ctx context.Context, client *http.Client, baseURL string) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"next", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed retrieving next Lambda invocation: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("failed retrieving next Lambda invocation: unexpected status %d", res.StatusCode)
+	}
+	event, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed reading Lambda invocation: %w", err)
+	}
+	return res.Header.Get(lambdaRequestIDHeader), event, nil
+}
+
+func postInvocation(ctx context.Context, client *http.Client, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed posting Lambda invocation result: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("failed posting Lambda invocation result: unexpected status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package serverless
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func echoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-Event", r.Header.Get("X-GitHub-Event"))
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery + " " + string(body)))
+	})
+}
+
+func Test_HandleAPIGatewayEvent(t *testing.T) {
+	testCases := []struct {
+		Name           string
+		Event          string
+		ExpectedBody   string
+		ExpectedMethod string
+	}{
+		{
+			Name:           "REST API payload 1.0",
+			Event:          `{"httpMethod": "POST", "path": "/api/github/hook", "queryStringParameters": {"a": "b"}, "headers": {"X-GitHub-Event": "issue_comment"}, "body": "{}"}`,
+			ExpectedBody:   "/api/github/hook?a=b {}",
+			ExpectedMethod: "POST",
+		},
+		{
+			Name:           "HTTP API payload 2.0",
+			Event:          `{"rawPath": "/api/github/hook", "rawQueryString": "a=b", "requestContext": {"http": {"method": "POST"}}, "headers": {"x-github-event": "issue_comment"}, "body": "e30=", "isBase64Encoded": true}`,
+			ExpectedBody:   "/api/github/hook?a=b {}",
+			ExpectedMethod: "POST",
+		},
+	}
+
+	for _, testCase := range testCases {
+		res, err := HandleAPIGatewayEvent(context.Background(), echoHandler(), []byte(testCase.Event))
+		assert.NoError(t, err, testCase.Name)
+		assert.Equal(t, http.StatusAccepted, res.StatusCode, testCase.Name)
+		assert.Equal(t, testCase.ExpectedBody, res.Body, testCase.Name)
+		assert.Equal(t, testCase.ExpectedMethod, res.Headers["X-Method"], testCase.Name)
+		assert.Equal(t, "issue_comment", res.Headers["X-Event"], testCase.Name)
+	}
+
+	_, err := HandleAPIGatewayEvent(context.Background(), echoHandler(), []byte(`{"foo": "bar"}`))
+	assert.Error(t, err, "events which are not API Gateway proxy requests are rejected")
+}
+
+func Test_runLambda(t *testing.T) {
+	invocations := []string{
+		`{"httpMethod": "GET", "path": "/healthz"}`,
+		`{"foo": "bar"}`,
+	}
+	responses := map[string]string{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /2018-06-01/runtime/invocation/next", func(w http.ResponseWriter, r *http.Request) {
+		if len(invocations) == 0 {
+			cancel()
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(lambdaRequestIDHeader, "request-"+string(rune('0'+len(invocations))))
+		_, _ = w.Write([]byte(invocations[0]))
+		invocations = invocations[1:]
+	})
+	mux.HandleFunc("POST /2018-06-01/runtime/invocation/{id}/{kind}", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		responses[r.PathValue("id")+"/"+r.PathValue("kind")] = string(body)
+		w.WriteHeader(http.StatusAccepted)
+	})
+	runtimeAPI := httptest.NewServer(mux)
+	defer runtimeAPI.Close()
+
+	err := runLambda(ctx, runtimeAPI.Client(), strings.TrimPrefix(runtimeAPI.URL, "http://"), echoHandler(), zerolog.Nop())
+	assert.Error(t, err)
+
+	var res APIGatewayResponse
+	assert.NoError(t, json.Unmarshal([]byte(responses["request-2/response"]), &res))
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	assert.Equal(t, "/healthz? ", res.Body)
+	assert.Contains(t, responses["request-1/error"], "not an API Gateway proxy request")
+}
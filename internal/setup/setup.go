@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package setup implements the `ariane setup` command, creating the GitHub App through the
+// App Manifest flow and writing the resulting credentials into a server config file.
+// See https://docs.github.com/en/apps/sharing-github-apps/registering-a-github-app-from-a-manifest
+package setup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+// Permissions required by Ariane, see README.md. Issues are written to reply on plain issues, and to comment the
+// digests.
+var Permissions = map[string]string{
+	"actions":        "write",
+	"administration": "read",
+	"checks":         "write",
+	"statuses":       "write",
+	"contents":       "read",
+	"issues":         "write",
+	"merge_queues":   "read",
+	"pull_requests":  "write",
+	"members":        "read",
+}
+
+// Events Ariane subscribes to, see README.md
+var Events = []string{
+	"delete",
+	"issue_comment",
+	"merge_group",
+	"pull_request",
+	"pull_request_review_comment",
+	"push",
+	"workflow_run",
+}
+
+type Options struct {
+	// Name of the GitHub App to create
+	Name string
+	// Organization owning the app, the app is created for the authenticated user if empty
+	Organization string
+	// WebhookURL is the public URL GitHub sends webhooks to, e.g. https://ariane.example.com/api/github/hook
+	WebhookURL string
+	// Listen is the local address serving the manifest flow pages
+	Listen string
+	// Output is the path of the server config file to write
+	Output string
+	// GitHubURL and APIURL allow creating the app on GitHub Enterprise Server
+	GitHubURL string
+	APIURL    string
+}
+
+// Manifest returns the GitHub App manifest for the given options.
+func Manifest(opts Options, redirectURL string) map[string]any {
+	homepage := opts.WebhookURL
+	if u, err := url.Parse(opts.WebhookURL); err == nil {
+		homepage = u.Scheme + "://" + u.Host
+	}
+
+	return map[string]any{
+		"name":         opts.Name,
+		"url":          homepage,
+		"redirect_url": redirectURL,
+		"public":       false,
+		"hook_attributes": map[string]any{
+			"url":    opts.WebhookURL,
+			"active": true,
+		},
+		"default_permissions": Permissions,
+		"default_events":      Events,
+	}
+}
+
+// newAppURL returns the URL of the GitHub page creating an app from a manifest
+func newAppURL(opts Options, state string) string {
+	base := strings.TrimSuffix(opts.GitHubURL, "/")
+	if opts.Organization != "" {
+		return fmt.Sprintf("%s/organizations/%s/settings/apps/new?state=%s", base, url.PathEscape(opts.Organization), state)
+	}
+	return fmt.Sprintf("%s/settings/apps/new?state=%s", base, state)
+}
+
+// ServerConfig returns a server config using the credentials of the created app, and default values otherwise.
+func ServerConfig(opts Options, app *github.AppConfig) *config.ServerConfig {
+	c := &config.ServerConfig{
+		Server: config.HTTPConfig{
+			Address: config.DefaultServerAddress,
+			Port:    config.DefaultServerPort,
+		},
+		Poll: config.PollConfig{
+			Interval: config.DefaultPollInterval,
+			Timeout:  config.DefaultPollTimeout,
+		},
+		Pagination:               config.DefaultPagination,
+		ApprovalPollInterval:     config.DefaultApprovalPoll,
+		DispatchVerifyTimeout:    config.DefaultDispatchVerifyTimeout,
+		HandlerTimeout:           config.DefaultHandlerTimeout,
+		PrivateKeyReloadInterval: config.DefaultKeyReloadInterval,
+		Retry: config.RetryConfig{
+			Attempts: config.DefaultRetryAttempts,
+			Backoff:  config.DefaultRetryBackoff,
+		},
+	}
+	c.Github.V3APIURL = strings.TrimSuffix(opts.APIURL, "/") + "/"
+	c.Github.App.IntegrationID = app.GetID()
+	c.Github.App.WebhookSecret = app.GetWebhookSecret()
+	c.Github.App.PrivateKey = app.GetPEM()
+	c.Github.OAuth.ClientID = app.GetClientID()
+	c.Github.OAuth.ClientSecret = app.GetClientSecret()
+	if app.GetSlug() != "" {
+		c.BotLogin = app.GetSlug() + "[bot]"
+	}
+	return c
+}
+
+var formTemplate = template.Must(template.New("form").Parse(`<!DOCTYPE html>
+<html>
+<body>
+<p>Creating the GitHub App {{ .Name }}...</p>
+<form id="manifest" action="{{ .Action }}" method="post">
+<input type="hidden" name="manifest" value="{{ .Manifest }}">
+<input type="submit" value="Create GitHub App">
+</form>
+<script>document.getElementById("manifest").submit()</script>
+</body>
+</html>
+`))
+
+// Run parses the setup command line arguments, and runs the manifest flow until the server config is written.
+func Run(args []string, logger zerolog.Logger) error {
+	opts := Options{}
+	flags := flag.NewFlagSet("setup", flag.ContinueOnError)
+	flags.StringVar(&opts.Name, "name", "ariane", "name of the GitHub App to create")
+	flags.StringVar(&opts.Organization, "org", "", "organization owning the GitHub App (defaults to the authenticated user)")
+	flags.StringVar(&opts.WebhookURL, "webhook-url", "", "public URL of Ariane's webhook, e.g. https://ariane.example.com/api/github/hook (required)")
+	flags.StringVar(&opts.Listen, "listen", "localhost:3000", "local address serving the setup pages")
+	flags.StringVar(&opts.Output, "output", config.ServerConfigPath, "path of the server config file to write")
+	flags.StringVar(&opts.GitHubURL, "github-url", "https://github.com", "URL of GitHub")
+	flags.StringVar(&opts.APIURL, "api-url", "https://api.github.com", "URL of the GitHub API")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if opts.WebhookURL == "" {
+		return errors.New("-webhook-url is required")
+	}
+	if _, err := os.Stat(opts.Output); err == nil {
+		return fmt.Errorf("%s already exists, refusing to overwrite it", opts.Output)
+	}
+
+	listener, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	stateBytes := make([]byte, 16)
+	if _, err := rand.Read(stateBytes); err != nil {
+		return err
+	}
+	state := hex.EncodeToString(stateBytes)
+	localURL := "http://" + listener.Addr().String()
+
+	done := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		manifest, err := json.Marshal(Manifest(opts, localURL+"/callback"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data := map[string]string{"Name": opts.Name, "Action": newAppURL(opts, state), "Manifest": string(manifest)}
+		if err := formTemplate.Execute(w, data); err != nil {
+			logger.Error().Err(err).Msg("Failed to write setup page")
+		}
+	})
+	mux.HandleFunc("GET /callback", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("state") != state {
+			http.Error(w, "invalid state", http.StatusBadRequest)
+			return
+		}
+		app, err := complete(r.Context(), opts, r.FormValue("code"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			done <- err
+			return
+		}
+		fmt.Fprintf(w, "GitHub App %s created, and its configuration written to %s.\nInstall it on your repositories: %s/installations/new\n", app.GetName(), opts.Output, app.GetHTMLURL())
+		done <- nil
+	})
+
+	server := &http.Server{Handler: mux}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			done <- err
+		}
+	}()
+	defer server.Close()
+
+	logger.Info().Msgf("Open %s in your browser to create the GitHub App", localURL)
+	return <-done
+}
+
+// complete exchanges the temporary code for the app credentials, and writes the server config
+func complete(ctx context.Context, opts Options, code string) (*github.AppConfig, error) {
+	client := github.NewClient(nil)
+	apiURL, err := url.Parse(strings.TrimSuffix(opts.APIURL, "/") + "/")
+	if err != nil {
+		return nil, err
+	}
+	client.BaseURL = apiURL
+
+	app, _, err := client.Apps.CompleteAppManifest(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed completing the app manifest flow: %w", err)
+	}
+
+	bytes, err := yaml.Marshal(ServerConfig(opts, app))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(opts.Output, bytes, 0o600); err != nil {
+		return nil, fmt.Errorf("failed writing server config: %w", err)
+	}
+	return app, nil
+}
@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package setup
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v75/github"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cilium/ariane/internal/config"
+)
+
+func TestManifest(t *testing.T) {
+	opts := Options{Name: "ariane-test", WebhookURL: "https://ariane.example.com/api/github/hook"}
+	manifest := Manifest(opts, "http://localhost:3000/callback")
+
+	assert.Equal(t, "ariane-test", manifest["name"])
+	assert.Equal(t, "https://ariane.example.com", manifest["url"])
+	assert.Equal(t, "http://localhost:3000/callback", manifest["redirect_url"])
+	assert.Equal(t, "https://ariane.example.com/api/github/hook", manifest["hook_attributes"].(map[string]any)["url"])
+	assert.Equal(t, Events, manifest["default_events"])
+	assert.Equal(t, "write", manifest["default_permissions"].(map[string]string)["actions"])
+}
+
+func TestNewAppURL(t *testing.T) {
+	assert.Equal(t, "https://github.com/settings/apps/new?state=s", newAppURL(Options{GitHubURL: "https://github.com/"}, "s"))
+	assert.Equal(t, "https://github.com/organizations/cilium/settings/apps/new?state=s", newAppURL(Options{GitHubURL: "https://github.com", Organization: "cilium"}, "s"))
+}
+
+func TestServerConfig(t *testing.T) {
+	app := &github.AppConfig{
+		ID:            github.Ptr(int64(42)),
+		ClientID:      github.Ptr("client-id"),
+		ClientSecret:  github.Ptr("client-secret"),
+		WebhookSecret: github.Ptr("webhook-secret"),
+		PEM:           github.Ptr("pem"),
+		Slug:          github.Ptr("my-ariane"),
+	}
+	bytes, err := yaml.Marshal(ServerConfig(Options{APIURL: "https://api.github.com"}, app))
+	assert.NoError(t, err)
+
+	var c config.ServerConfig
+	assert.NoError(t, yaml.Unmarshal(bytes, &c))
+	assert.Equal(t, int64(42), c.Github.App.IntegrationID)
+	assert.Equal(t, "webhook-secret", c.Github.App.WebhookSecret)
+	assert.Equal(t, "pem", c.Github.App.PrivateKey)
+	assert.Equal(t, "client-id", c.Github.OAuth.ClientID)
+	assert.Equal(t, "https://api.github.com/", c.Github.V3APIURL)
+	assert.Equal(t, "my-ariane[bot]", c.BotLogin)
+	assert.Equal(t, config.DefaultPollInterval, c.Poll.Interval)
+	assert.Equal(t, config.DefaultRetryAttempts, c.Retry.Attempts)
+}
@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// File is a store keeping the state as one JSON file per key in a directory, surviving restarts of single-binary
+// deployments, but not shared by replicas
+type File struct {
+	dir string
+	// mu serializes the accesses to the files, so expired files are not dropped as they are set again
+	mu sync.Mutex
+}
+
+// fileEntry is the content of the file of a key
+type fileEntry struct {
+	Value     []byte     `json:"value"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (e fileEntry) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && !now.Before(*e.ExpiresAt)
+}
+
+// NewFile creates a store persisted in dir, created if missing
+func NewFile(dir string) (*File, error) {
+	if dir == "" {
+		return nil, errors.New("the file state backend requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed creating state directory: %w", err)
+	}
+	return &File{dir: dir}, nil
+}
+
+// path escapes the key into a file name, which the .json extension keeps from being "." or ".."
+func (f *File) path(key string) string {
+	return filepath.Join(f.dir, url.PathEscape(key)+".json")
+}
+
+// read reads the file of a key, f.mu being held
+func (f *File) read(path string) (fileEntry, bool, error) {
+	bytes, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fileEntry{}, false, nil
+	}
+	if err != nil {
+		return fileEntry{}, false, err
+	}
+	var e fileEntry
+	if err := json.Unmarshal(bytes, &e); err != nil {
+		return fileEntry{}, false, fmt.Errorf("failed parsing state file %s: %w", path, err)
+	}
+	if e.expired(time.Now()) {
+		// expired keys are dropped once read
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fileEntry{}, false, err
+		}
+		return fileEntry{}, false, nil
+	}
+	return e, true, nil
+}
+
+func (f *File) Get(_ context.Context, key string) ([]byte, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok, err := f.read(f.path(key))
+	return e.Value, ok, err
+}
+
+func (f *File) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.write(key, value, ttl)
+}
+
+func (f *File) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok, err := f.read(f.path(key)); err != nil || ok {
+		return false, err
+	}
+	return true, f.write(key, value, ttl)
+}
+
+// write writes the file of a key atomically, through a temporary file renamed over it, f.mu being held
+func (f *File) write(key string, value []byte, ttl time.Duration) error {
+	e := fileEntry{Value: value}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		e.ExpiresAt = &expiresAt
+	}
+	bytes, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(bytes); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+func (f *File) Delete(_ context.Context, key string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := f.path(key)
+	_, ok, err := f.read(path)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, err
+	}
+	return true, nil
+}
+
+func (f *File) List(_ context.Context, prefix string) (map[string][]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	files, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string][]byte{}
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), ".json")
+		if !ok || file.IsDir() {
+			continue
+		}
+		key, err := url.PathUnescape(name)
+		if err != nil || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		e, ok, err := f.read(filepath.Join(f.dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			values[key] = e.Value
+		}
+	}
+	return values, nil
+}
+
+func (f *File) Close() error {
+	return nil
+}
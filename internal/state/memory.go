@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package state
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	gocache "github.com/patrickmn/go-cache"
+)
+
+// memoryCleanupInterval is how often the expired keys of a memory store are dropped
+const memoryCleanupInterval = 10 * time.Minute
+
+// Memory is a store keeping the state in memory, lost on restart and not shared by replicas
+type Memory struct {
+	// mu makes Delete atomic
+	mu    sync.Mutex
+	cache *gocache.Cache
+}
+
+func NewMemory() *Memory {
+	return &Memory{cache: gocache.New(gocache.NoExpiration, memoryCleanupInterval)}
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	v, ok := m.cache.Get(key)
+	if !ok {
+		return nil, false, nil
+	}
+	return v.([]byte), true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = gocache.NoExpiration
+	}
+	// the value is copied, so callers can reuse it
+	m.cache.Set(key, append([]byte(nil), value...), ttl)
+	return nil
+}
+
+func (m *Memory) Add(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		ttl = gocache.NoExpiration
+	}
+	// Add fails if the key is set
+	return m.cache.Add(key, append([]byte(nil), value...), ttl) == nil, nil
+}
+
+func (m *Memory) Delete(_ context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.cache.Get(key)
+	m.cache.Delete(key)
+	return ok, nil
+}
+
+func (m *Memory) List(_ context.Context, prefix string) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	for key, item := range m.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			values[key] = item.Object.([]byte)
+		}
+	}
+	return values, nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
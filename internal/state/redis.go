@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisKeyPrefix namespaces the keys of Ariane, so a Redis database can be shared with other applications
+	redisKeyPrefix = "ariane:"
+	// redisScanCount is how many keys List asks Redis to scan per round trip
+	redisScanCount = 100
+)
+
+// Redis is a store keeping the state in Redis
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis connects to Redis at a redis:// URL, or rediss:// for TLS, e.g. redis://:password@localhost:6379/0
+func NewRedis(rawURL string) (*Redis, error) {
+	options, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	r := &Redis{client: redis.NewClient(options)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		_ = r.client.Close()
+		return nil, fmt.Errorf("failed connecting to Redis at %s: %w", options.Addr, err)
+	}
+	return r, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, redisKeyPrefix+key, value, ttl).Err()
+}
+
+func (r *Redis) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, redisKeyPrefix+key, value, ttl).Result()
+}
+
+func (r *Redis) Delete(ctx context.Context, key string) (bool, error) {
+	deleted, err := r.client.Del(ctx, redisKeyPrefix+key).Result()
+	return deleted > 0, err
+}
+
+// List scans the keys matching prefix, and gets their values in one round trip per scanned batch
+func (r *Redis) List(ctx context.Context, prefix string) (map[string][]byte, error) {
+	values := map[string][]byte{}
+	pattern := redisGlobEscaper.Replace(redisKeyPrefix+prefix) + "*"
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, pattern, redisScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			got, err := r.client.MGet(ctx, keys...).Result()
+			if err != nil {
+				return nil, err
+			}
+			// keys which expired since they were scanned have no value
+			for i, value := range got {
+				if v, ok := value.(string); ok {
+					values[strings.TrimPrefix(keys[i], redisKeyPrefix)] = []byte(v)
+				}
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return values, nil
+		}
+	}
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// redisGlobEscaper escapes the characters of Redis glob-style patterns
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
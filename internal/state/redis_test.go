@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package state
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis serves the commands of the Redis store from memory
+type fakeRedis struct {
+	password string
+
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	// commands records the commands received, for the tests to check them
+	commands []string
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	f := &fakeRedis{password: password, values: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, listener.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authenticated := f.password == ""
+	for {
+		args, err := readFakeRedisCommand(r)
+		if err != nil {
+			return
+		}
+		// commands are case-insensitive
+		args[0] = strings.ToUpper(args[0])
+		f.mu.Lock()
+		f.commands = append(f.commands, args[0])
+		var reply string
+		switch {
+		case args[0] == "HELLO":
+			// like Redis before 6.0, for the client to fall back to RESP2
+			reply = "-ERR unknown command 'HELLO'\r\n"
+		case args[0] == "AUTH":
+			authenticated = args[len(args)-1] == f.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = f.handle(args)
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) get(key string) (string, bool) {
+	if at, ok := f.expires[key]; ok && !time.Now().Before(at) {
+		delete(f.values, key)
+		delete(f.expires, key)
+	}
+	v, ok := f.values[key]
+	return v, ok
+}
+
+func (f *fakeRedis) handle(args []string) string {
+	bulk := func(v string, ok bool) string {
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	}
+	switch args[0] {
+	case "PING", "SELECT", "CLIENT":
+		return "+OK\r\n"
+	case "GET":
+		return bulk(f.get(args[1]))
+	case "SET":
+		var ttl time.Duration
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				if _, ok := f.get(args[1]); ok {
+					return "$-1\r\n"
+				}
+			case "PX":
+				i++
+				ms, _ := strconv.Atoi(args[i])
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+		}
+		f.values[args[1]] = args[2]
+		delete(f.expires, args[1])
+		if ttl > 0 {
+			f.expires[args[1]] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "SETNX":
+		if _, ok := f.get(args[1]); ok {
+			return ":0\r\n"
+		}
+		f.values[args[1]] = args[2]
+		delete(f.expires, args[1])
+		return ":1\r\n"
+	case "DEL":
+		_, ok := f.get(args[1])
+		delete(f.values, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "SCAN":
+		// the whole keyspace is scanned at once, matching the escaped prefix of the pattern
+		prefix := strings.NewReplacer(`\\`, `\`, `\*`, `*`, `\?`, `?`, `\[`, `[`, `\]`, `]`).Replace(strings.TrimSuffix(args[3], "*"))
+		var keys []string
+		for key := range f.values {
+			if _, ok := f.get(key); ok && strings.HasPrefix(key, prefix) {
+				keys = append(keys, bulk(key, true))
+			}
+		}
+		return fmt.Sprintf("*2\r\n$1\r\n0\r\n*%d\r\n%s", len(keys), strings.Join(keys, ""))
+	case "MGET":
+		var values []string
+		for _, key := range args[1:] {
+			values = append(values, bulk(f.get(key)))
+		}
+		return fmt.Sprintf("*%d\r\n%s", len(values), strings.Join(values, ""))
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func readFakeRedisCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedis(t *testing.T) {
+	fake, addr := startFakeRedis(t, "secret")
+
+	_, err := NewRedis("redis://:wrong@" + addr)
+	assert.ErrorContains(t, err, "WRONGPASS invalid password")
+
+	store, err := NewRedis("redis://:secret@" + addr + "/2")
+	if !assert.NoError(t, err) {
+		return
+	}
+	testStore(t, store)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	// the keys are namespaced, and the connections are reused
+	_, ok := fake.values["ariane:pause/owner/repo#2"]
+	assert.True(t, ok)
+	assert.Contains(t, fake.commands, "SELECT")
+	auths := 0
+	for _, command := range fake.commands {
+		if command == "AUTH" {
+			auths++
+		}
+	}
+	assert.Equal(t, 2, auths, "one connection failing to authenticate, and one used for all the commands")
+}
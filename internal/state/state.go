@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+// Package state stores the state of Ariane's features, e.g. the held trigger comments or the pull requests it is
+// paused on, as expiring key-value pairs. The state is kept in memory by default, in a directory so it survives
+// restarts, or in Redis or Postgres so it is also shared by replicas.
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// the backends of Open
+const (
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+	BackendRedis    = "redis"
+	BackendPostgres = "postgres"
+)
+
+// Store is a key-value store whose keys expire. Stores are safe for concurrent use.
+type Store interface {
+	// Get returns the value of a key, and whether it is set
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set sets the value of a key, expiring after ttl, or never if zero
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Add sets the value of a key like Set unless it is already set, and reports whether it was set. It is atomic,
+	// so replicas sharing a store can claim a key.
+	Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	// Delete unsets a key, and reports whether it was set
+	Delete(ctx context.Context, key string) (bool, error)
+	// List returns the values of the keys starting with prefix, keyed by key
+	List(ctx context.Context, prefix string) (map[string][]byte, error)
+	// Close releases the connections of the store
+	Close() error
+}
+
+// Open opens the store of a backend, memory if empty, at the given URL, which is a directory for the file backend
+func Open(backend, url string) (Store, error) {
+	switch backend {
+	case "", BackendMemory:
+		return NewMemory(), nil
+	case BackendFile:
+		return NewFile(url)
+	case BackendRedis:
+		return NewRedis(url)
+	case BackendPostgres:
+		return NewPostgres(url)
+	}
+	return nil, fmt.Errorf("unknown state backend %q, expected %s, %s, %s or %s", backend, BackendMemory, BackendFile, BackendRedis, BackendPostgres)
+}
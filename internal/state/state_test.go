@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testStore checks the behavior shared by all the backends
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	_, ok, err := store.Get(ctx, "pause/owner/repo#1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Set(ctx, "pause/owner/repo#1", []byte(`"alice"`), time.Hour))
+	assert.NoError(t, store.Set(ctx, "pause/owner/repo#2", []byte(`"bob"`), 0))
+	assert.NoError(t, store.Set(ctx, "pause/owner/repo#3", []byte(`"carol"`), time.Millisecond))
+	assert.NoError(t, store.Set(ctx, "retries/owner/repo/foo.yaml@sha", []byte(`[]`), 0))
+	value, ok, err := store.Get(ctx, "pause/owner/repo#1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `"alice"`, string(value))
+
+	// overwriting a key replaces its value, and the expired keys are ignored
+	assert.NoError(t, store.Set(ctx, "pause/owner/repo#1", []byte(`"dave"`), time.Hour))
+	time.Sleep(20 * time.Millisecond)
+	values, err := store.List(ctx, "pause/")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"pause/owner/repo#1": []byte(`"dave"`), "pause/owner/repo#2": []byte(`"bob"`)}, values)
+	_, ok, err = store.Get(ctx, "pause/owner/repo#3")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// keys are only added if unset, or expired
+	added, err := store.Add(ctx, "pause/owner/repo#2", []byte(`"erin"`), time.Hour)
+	assert.NoError(t, err)
+	assert.False(t, added)
+	added, err = store.Add(ctx, "pause/owner/repo#3", []byte(`"erin"`), time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, added)
+	value, _, err = store.Get(ctx, "pause/owner/repo#3")
+	assert.NoError(t, err)
+	assert.Equal(t, `"erin"`, string(value))
+
+	deleted, err := store.Delete(ctx, "pause/owner/repo#1")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = store.Delete(ctx, "pause/owner/repo#1")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	// the characters of patterns are matched literally
+	values, err = store.List(ctx, "pause/owner/repo#*")
+	assert.NoError(t, err)
+	assert.Empty(t, values)
+
+	assert.NoError(t, store.Close())
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFile(dir)
+	if !assert.NoError(t, err) {
+		return
+	}
+	testStore(t, store)
+
+	// the state survives restarts
+	reopened, err := Open(BackendFile, dir)
+	assert.NoError(t, err)
+	value, ok, err := reopened.Get(context.Background(), "pause/owner/repo#2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `"bob"`, string(value))
+}
+
+func TestOpen(t *testing.T) {
+	store, err := Open("", "")
+	assert.NoError(t, err)
+	assert.IsType(t, &Memory{}, store)
+
+	_, err = Open(BackendFile, "")
+	assert.ErrorContains(t, err, "requires a directory")
+	_, err = Open("etcd", "http://localhost:2379")
+	assert.ErrorContains(t, err, `unknown state backend "etcd"`)
+	_, err = Open(BackendRedis, "http://localhost:6379")
+	assert.ErrorContains(t, err, "invalid Redis URL")
+	_, err = Open(BackendPostgres, "postgres://localhost/ariane?sslmode=sometimes")
+	assert.ErrorContains(t, err, "invalid Postgres URL")
+}
@@ -4,25 +4,31 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"github.com/gregjones/httpcache"
-	"github.com/palantir/go-githubapp/githubapp"
 	"github.com/rs/zerolog"
 
 	"github.com/cilium/ariane/internal/config"
-	"github.com/cilium/ariane/internal/handlers"
-)
-
-const (
-	DefaultHealthRoute = "/healthz"
-	DefaultRoute       = "/"
+	"github.com/cilium/ariane/internal/server"
+	"github.com/cilium/ariane/internal/serverless"
+	"github.com/cilium/ariane/internal/setup"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "setup" {
+		logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr})
+		if err := setup.Run(os.Args[2:], logger); err != nil {
+			logger.Fatal().Err(err).Msg("Setup failed")
+		}
+		return
+	}
+
 	serverConfig, err := config.ReadServerConfig(config.ServerConfigPath)
 
 	if err != nil {
@@ -32,45 +38,66 @@ func main() {
 	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
 	zerolog.DefaultContextLogger = &logger
 
-	cc, err := githubapp.NewDefaultCachingClientCreator(
-		serverConfig.Github,
-		githubapp.WithClientUserAgent("cilium-ariane/0.0.1"),
-		githubapp.WithClientTimeout(3*time.Second),
-		githubapp.WithClientCaching(false, func() httpcache.Cache { return httpcache.NewMemoryCache() }),
-	)
-
+	handler, err := server.New(serverConfig, logger)
 	if err != nil {
 		panic(err)
 	}
 
-	prCommentHandler := &handlers.PRCommentHandler{ClientCreator: cc, RunDelay: serverConfig.RunDelay}
-	mergeGroupHandler := &handlers.MergeGroupHandler{ClientCreator: cc}
-	webhookHandler := githubapp.NewDefaultEventDispatcher(serverConfig.Github, prCommentHandler, mergeGroupHandler)
-
-	http.Handle(githubapp.DefaultWebhookRoute, webhookHandler)
-
-	// add a health check endpoint
-	http.HandleFunc(DefaultHealthRoute, func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, err := w.Write([]byte("OK"))
-		if err != nil {
-			logger.Error().Err(err).Msg("Failed to write health check response")
-		}
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	// add a default route
-	http.HandleFunc(DefaultRoute, func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, err := w.Write([]byte("Ariane is running!" + "\nVersion: " + serverConfig.Version))
-		if err != nil {
-			logger.Error().Err(err).Msg("Failed to write default response")
+	// when deployed as an AWS Lambda function, serve invocations from the Lambda Runtime API until Lambda shuts the
+	// execution environment down, then wait for the background work spawned while handling them
+	if serverless.IsLambda() {
+		logger.Info().Msg("Starting AWS Lambda handler...")
+		err := serverless.StartLambda(ctx, handler, logger)
+		shutdown := ctx.Err() != nil
+		stop()
+		drain(handler, serverConfig.Server.ShutdownTimeout, logger)
+		if !shutdown {
+			panic(err)
 		}
-	})
+		return
+	}
 
 	addr := fmt.Sprintf("%s:%d", serverConfig.Server.Address, serverConfig.Server.Port)
-	logger.Info().Msgf("Starting server on %s...", addr)
-	err = http.ListenAndServe(addr, nil)
-	if err != nil {
+	httpServer := &http.Server{Addr: addr, Handler: handler}
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info().Msgf("Starting server on %s...", addr)
+		errs <- httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
 		panic(err)
+	case <-ctx.Done():
+	}
+	// a second signal exits right away
+	stop()
+
+	// stop accepting webhooks, and wait for the in-flight ones and the background work they spawned, e.g. the
+	// re-runs of failed jobs, rather than dropping them
+	logger.Info().Msgf("Shutting down, waiting up to %s for in-flight events...", serverConfig.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverConfig.Server.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("In-flight webhooks did not complete before the shutdown timeout")
+	}
+	if err := handler.Drain(shutdownCtx); err != nil {
+		logger.Error().Err(err).Msg("Background work did not complete before the shutdown timeout")
+		return
+	}
+	logger.Info().Msg("Server stopped")
+}
+
+// drain waits up to timeout for the background work spawned while handling the events to complete
+func drain(handler *server.Server, timeout time.Duration, logger zerolog.Logger) {
+	logger.Info().Msgf("Shutting down, waiting up to %s for background work...", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := handler.Drain(ctx); err != nil {
+		logger.Error().Err(err).Msg("Background work did not complete before the shutdown timeout")
+		return
 	}
+	logger.Info().Msg("Server stopped")
 }